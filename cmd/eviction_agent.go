@@ -1,34 +1,156 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"math/rand"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"eviction-agent/cmd/options"
+	"eviction-agent/pkg/control"
 	"eviction-agent/pkg/evictionclient"
 	"eviction-agent/pkg/evictionmanager"
 	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/server"
+	"eviction-agent/pkg/tracing"
 )
 
 func main() {
 	rand.Seed(time.Now().UTC().UnixNano())
 
-	// Init from environment
+	if len(os.Args) > 1 {
+		if run, ok := subcommands[os.Args[1]]; ok {
+			os.Exit(run(os.Args[2:]))
+		}
+	}
+
+	// Init from command line and environment
 	eao := options.NewEvictionAgentOptions()
+	eao.AddFlags(flag.CommandLine)
+	flag.Parse()
+
+	eao.SetKubeconfigFile()
 	eao.SetNodeNameOrDie()
+	eao.SetPodIdentity()
 	eao.SetPolicyConfigFileOrDie()
 	eao.SetLogDirOrDie()
-	log.Config("info", eao.LogDir, false, 1*1024*1024, 5)
-
-	flag.Parse()
+	eao.ValidateProfileOrDie()
+	eao.ValidateLogOptionsOrDie()
+	eao.SetOTLPEndpoint()
+	eao.ValidateResizeOptionsOrDie()
+	eao.ValidatePinnedPodsOrDie()
+	eao.ValidatePlanOptionsOrDie()
+	eao.ValidateConcurrentPressureOrDie()
+	eao.ValidateSpotPreemptionOrDie()
+	eao.ValidateModeOrDie()
+	eao.ValidateEvictMarkOrDie()
+	eao.ValidateHistoryStoreOrDie()
+	eao.ValidateCalibrationOrDie()
+	log.Config(eao.LogLevel, eao.LogFormat, eao.LogDir, false, 1*1024*1024, 5, "node", eao.NodeName)
 
-	log.Infof("Start to run eviction agent on %v...", eao.NodeName)
+	log.Infof("Start to run eviction agent on %v in %s mode...", eao.NodeName, eao.Mode)
+	if eao.OTLPEndpoint != "" {
+		tracing.Init(eao.OTLPEndpoint, "eviction-agent", eao.NodeName)
+	}
 
 	c := evictionclient.NewClientOrDie(eao)
-	e := evictionmanager.NewEvictionManager(c, eao.PolicyConfigFile)
+	e := evictionmanager.NewEvictionManager(c, eao)
+
+	if eao.HealthAddress != "" {
+		s := server.NewServer(eao.HealthAddress)
+		s.AddHealthzCheck("eviction-manager", e.HealthCheck)
+		s.AddReadyzCheck("eviction-manager", e.ReadyCheck)
+		s.Start()
+	}
+
+	if eao.DebugAddress != "" {
+		d := server.NewDebugServer(eao.DebugAddress)
+		d.Handle("/debug/stats", server.JSONHandler(func() interface{} {
+			return e.GetStatsSamples()
+		}))
+		d.Handle("/debug/evictions", server.JSONHandler(func() interface{} {
+			return e.GetLastEvictions()
+		}))
+		d.Handle("/debug/history", server.JSONRequestHandler(func(r *http.Request) (interface{}, error) {
+			return e.GetHistory(r.URL.Query().Get("pod")), nil
+		}))
+		d.Start()
+	}
 
-	if err := e.Run(); err != nil {
+	if eao.APIAddress != "" {
+		a := server.NewAPIServer(eao.APIAddress, eao.GetAPITokenOrDie())
+		a.Handle("/v1/conditions", server.JSONHandler(func() interface{} {
+			return e.GetConditions()
+		}))
+		a.Handle("/v1/candidates", server.JSONRequestHandler(func(r *http.Request) (interface{}, error) {
+			return e.GetEvictionCandidates(r.URL.Query().Get("type"))
+		}))
+		a.Handle("/v1/history", server.JSONRequestHandler(func(r *http.Request) (interface{}, error) {
+			return e.GetHistory(r.URL.Query().Get("pod")), nil
+		}))
+		a.Handle("/v1/toppods", server.JSONRequestHandler(func(r *http.Request) (interface{}, error) {
+			return e.GetTopPods()
+		}))
+		a.Handle("/v1/explanation", server.JSONRequestHandler(func(r *http.Request) (interface{}, error) {
+			return e.GetExplanation(r.URL.Query().Get("pod"))
+		}))
+		if eao.APIManualEvictions {
+			a.Handle("/v1/evict", server.JSONActionHandler(func(r *http.Request) (interface{}, error) {
+				evictType := r.URL.Query().Get("type")
+				log.Warnf("Eviction of %s is requested by %s", evictType, r.RemoteAddr)
+				return e.Evict(r.Context(), evictType)
+			}))
+		}
+		a.Start()
+	}
+
+	if eao.ControlAddress != "" {
+		token, tlsConfig := eao.GetControlAuthOrDie()
+		control.NewServer(eao.ControlAddress, token, tlsConfig, e).Start()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go stopOnSignal(cancel, eao.ShutdownTimeout)
+	go shiftLogLevelOnSignal()
+	if err := e.Run(ctx); err != nil {
 		log.Fatalf("Eviction agent failed with error: %v", err)
 	}
+	tracing.Shutdown(5 * time.Second)
+	log.Infof("Eviction agent stopped")
+}
+
+// shiftLogLevelOnSignal lowers log level for more logs on SIGUSR1,
+// and raises it on SIGUSR2
+func shiftLogLevelOnSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1, syscall.SIGUSR2)
+	for sig := range signals {
+		n := 1
+		if sig == syscall.SIGUSR2 {
+			n = -1
+		}
+		// logged regardless of level
+		log.DefaultLogger.Warnf("Receive signal %v, log level is changed to %s", sig, log.ShiftLevel(n))
+	}
+}
+
+// stopOnSignal cancels on SIGTERM or SIGINT, and exits if agent
+// is not stopped in timeout or on the second signal
+func stopOnSignal(cancel context.CancelFunc, timeout time.Duration) {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	sig := <-signals
+	log.Infof("Receive signal %v, stop eviction agent", sig)
+	cancel()
+	select {
+	case sig = <-signals:
+		log.Errorf("Receive signal %v again, exit", sig)
+	case <-time.After(timeout):
+		log.Errorf("Eviction agent is not stopped in %v, exit", timeout)
+	}
+	os.Exit(1)
 }
@@ -3,7 +3,71 @@ package options
 import (
 	"os"
 	"fmt"
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"io/ioutil"
+	"strings"
+	"time"
+	"eviction-agent/pkg/config"
 	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/preemption"
+	"eviction-agent/pkg/probes"
+	"eviction-agent/pkg/server"
+	"eviction-agent/pkg/types"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Modes of --mode
+const (
+	// ModeFull taints node and evicts or labels pods
+	ModeFull = "full"
+	// ModeTaintOnly taints and untaints node, pods are never evicted or labeled
+	ModeTaintOnly = "taint-only"
+	// ModeMonitorOnly reports conditions by metrics and status only, neither node nor pods are changed
+	ModeMonitorOnly = "monitor-only"
+)
+
+// ClusterHeadroomNodes of --cluster-headroom computes spare capacity of cluster from nodes and pods
+const ClusterHeadroomNodes = "nodes"
+
+// Actions of --pinned-pods
+const (
+	// PinnedPodsExclude never chooses pods which can't be scheduled on other nodes
+	PinnedPodsExclude = "Exclude"
+	// PinnedPodsRank chooses pods which can't be scheduled on other nodes after the others
+	PinnedPodsRank = "Rank"
+)
+
+// Modes of --calibrate
+const (
+	CalibrateSuggest = "suggest"
+	CalibrateApply   = "apply"
+)
+
+// Stores of --history-store
+const (
+	// HistoryStoreMemory keeps decisions in memory only, they are lost on restart
+	HistoryStoreMemory = "memory"
+	// HistoryStoreFile keeps decisions in a file of --history-store-path
+	HistoryStoreFile = "file"
+	// HistoryStoreConfigMap keeps decisions in ConfigMap eviction-history-<node> of --status-namespace
+	HistoryStoreConfigMap = "configmap"
+)
+
+// Policies of --concurrent-pressure
+const (
+	// ConcurrentPressureEach evicts one pod for each busy condition in the order of their priorities
+	ConcurrentPressureEach = "each"
+	// ConcurrentPressureMostSevere evicts one pod for the most severe of busy conditions
+	ConcurrentPressureMostSevere = "most-severe"
+)
+
+// Marks of --evict-mark
+const (
+	EvictMarkLabel      = "label"
+	EvictMarkAnnotation = "annotation"
 )
 
 type EvictionAgentOptions struct {
@@ -15,36 +79,1181 @@ type EvictionAgentOptions struct {
 	KubeconfigFile string
 	// LogDir specifies the path to log file
 	LogDir string
+	// LogLevel is the initial log level, debug, info, warning or error.
+	LogLevel string
+	// LogFormat is the log format, text or json.
+	LogFormat string
 	// NodeName is the node name used to communicate with Kubernetes ApiServer.
 	NodeName string
+	// KubeAPIQPS is the QPS to use while talking with Kubernetes ApiServer.
+	KubeAPIQPS float64
+	// KubeAPIBurst is the burst to use while talking with Kubernetes ApiServer.
+	KubeAPIBurst int
+	// ClearLabelsQPS is the budget of api calls per second used to clear evict labels.
+	ClearLabelsQPS float64
+	// ClearLabelsBurst is the max api calls used to clear evict labels at once.
+	ClearLabelsBurst int
+	// EvictMark is label or annotation marking pods to evict.
+	EvictMark string
+	// EvictMarkPrefix is the prefix of keys of evict marks, e.g. eviction-agent.io/.
+	EvictMarkPrefix string
+	// EnablePolicyCRD enables EvictionPolicy custom resources selecting this node
+	// to be used instead of the policy configuration file.
+	EnablePolicyCRD bool
+	// NamespacePreferences enables eviction preferences of namespace annotations,
+	// i.e. weight, max evictions per hour and protection of their pods.
+	NamespacePreferences bool
+	// Profile is the built-in policy profile used by policies which set no profile.
+	Profile string
+	// PolicyOverrides are key=value overriding fields of policy, after EVICTION_POLICY_* environment.
+	PolicyOverrides stringList
+	// DisabledConditions are conditions neither monitored nor tainted, in addition to the policy.
+	DisabledConditions string
+	// StatusNamespace is the namespace of NodeEvictionStatus reporting agent state,
+	// no status is reported if it's empty.
+	StatusNamespace string
+	// HealthAddress is the address serving /healthz, /readyz and /metrics, disabled if empty.
+	HealthAddress string
+	// EvaluationPeriod is the period of stats sync and condition evaluation.
+	EvaluationPeriod time.Duration
+	// EvaluationJitter is the max factor of EvaluationPeriod added randomly to each period.
+	EvaluationJitter float64
+	// ConditionPeriods are comma separated condition=period evaluated at their own period.
+	ConditionPeriods string
+	// NodeProblemConditions are comma separated node conditions mapped to actions, e.g. KernelDeadlock=Taint.
+	NodeProblemConditions string
+	// KubeletPrecedence are comma separated condition=Kubelet or condition=Agent, evictions
+	// of conditions with Kubelet are left to kubelet while it reports pressure of the resource.
+	KubeletPrecedence string
+	// StatsTimeout is the timeout of kubelet stats request and of each stats source.
+	StatsTimeout time.Duration
+	// StatsConcurrency is the max number of stats sources collected at once.
+	StatsConcurrency int
+	// MaxPodStats is the max number of pods kept in each stats sample, unlimited if zero.
+	MaxPodStats int
+	// PodStatsWorkers is the max number of pods whose stats are collected at once.
+	PodStatsWorkers int
+	// PodStatsTimeout is the timeout of stats of each pod, unlimited if zero.
+	PodStatsTimeout time.Duration
+	// ShutdownTimeout is the max time to wait for the action in flight on SIGTERM.
+	ShutdownTimeout time.Duration
+	// HealthStuckThreshold is the max duration of taint loop or stats sync without progress.
+	HealthStuckThreshold time.Duration
+	// WatchdogPeriods is the number of periods of stats sync or taint loop without a completed cycle after which it's stalled, disabled if 0.
+	WatchdogPeriods int
+	// WatchdogRestart restarts stats sync once it's stalled.
+	WatchdogRestart bool
+	// SafeModeFailures is the number of consecutive failed taints, evictions and labels after which actions are stopped, disabled if 0.
+	SafeModeFailures int
+	// SafeModeRetryPeriod is the period of attempting actions again in safe mode.
+	SafeModeRetryPeriod time.Duration
+	// DebugAddress is the address serving pprof and debug info, disabled if empty.
+	DebugAddress string
+	// RecordFile is the file which stats samples are appended to for replay, disabled if empty.
+	RecordFile string
+	// WebhookURL is the url notified on taint, untaint and eviction, disabled if empty.
+	WebhookURL string
+	// WebhookTimeout is the timeout of each webhook request.
+	WebhookTimeout time.Duration
+	// WebhookRetries is the number of retries after webhook request fails.
+	WebhookRetries int
+	// AlertmanagerURL is the Alertmanager receiving alerts of taints, disabled if empty.
+	AlertmanagerURL string
+	// AlertmanagerTimeout is the timeout of each request to Alertmanager.
+	AlertmanagerTimeout time.Duration
+	// AlertmanagerResend is the period of sending firing alerts again.
+	AlertmanagerResend time.Duration
+	// APIAddress is the address serving read-only json api, disabled if empty.
+	APIAddress string
+	// APITokenFile is the file containing the bearer token required by api.
+	APITokenFile string
+	// ControlAddress is the address of control api for node-local tooling, host:port or unix:///path, disabled if empty.
+	ControlAddress string
+	// ControlTokenFile is the file containing the bearer token required by control api.
+	ControlTokenFile string
+	// ControlTLSCertFile and ControlTLSKeyFile are the certificate and key of https of control api.
+	ControlTLSCertFile string
+	ControlTLSKeyFile  string
+	// ControlClientCAFile is the CA verifying client certificates of control api, mTLS is required if set.
+	ControlClientCAFile string
+	// APIManualEvictions enables POST /v1/evict of api, which evicts a pod of
+	// a condition on demand.
+	APIManualEvictions bool
+	// AuditLogFile is the append-only audit log of evictions and labels, disabled if empty.
+	AuditLogFile string
+	// AuditLogMaxSize is the max size in MB of audit log before it's rotated.
+	AuditLogMaxSize int
+	// AuditLogMaxBackups is the max number of rotated audit logs kept.
+	AuditLogMaxBackups int
+	// HistorySize is the max number of eviction decisions kept in memory.
+	HistorySize int
+	// HistoryStore is where eviction decisions are kept across restarts, memory, file or configmap.
+	HistoryStore string
+	// HistoryStorePath is the bolt file of decisions of file history store.
+	HistoryStorePath string
+	// TextfileDir is the directory of textfile collector of node_exporter conditions are written into, disabled if empty.
+	TextfileDir string
+	// OTLPEndpoint is the OTLP/HTTP endpoint receiving traces, disabled if empty.
+	OTLPEndpoint string
+	// TopPodsPeriod is the period of annotating node with top pods by usage, disabled if zero.
+	TopPodsPeriod time.Duration
+	// TopPodsCount is the number of top pods of each resource.
+	TopPodsCount int
+	// SummaryPeriod is the period of summary events of activity, disabled if zero.
+	SummaryPeriod time.Duration
+	// RebalanceHints annotates node with resources tainted for RebalanceHintDelay.
+	RebalanceHints bool
+	// RebalanceHintDelay is the min taint duration of resources in rebalance hints.
+	RebalanceHintDelay time.Duration
+	// Mode is full, taint-only or monitor-only.
+	Mode string
+	// ObserveFor is the canary duration in which no action is taken, disabled if zero.
+	ObserveFor time.Duration
+	// ResizeAction is Resize or Recommend taken instead of evicting pods using
+	// more than ResizeRatio times of their cpu or memory requests, disabled if empty.
+	ResizeAction string
+	// ResizeRatio is the min ratio of usage to requests of pods resized.
+	ResizeRatio float64
+	// BurstCreditsProvider is aws, azure or an http url of burst credits, disabled if empty.
+	BurstCreditsProvider string
+	// BurstCreditsPeriod is the period of probing burst credits.
+	BurstCreditsPeriod time.Duration
+	// BurstCreditsLow is the fraction of burst credits below which thresholds are tightened.
+	BurstCreditsLow float64
+	// BurstMinFactor is the factor of thresholds when burst credits are used up.
+	BurstMinFactor float64
+	// ExtendedResources are comma separated extended resources of device plugins
+	// with optional thresholds of allocatable, e.g. intel.com/fpga=90%.
+	ExtendedResources string
+	// ExtendedResourceUsageURL is the url of usage of extended resources on node.
+	ExtendedResourceUsageURL string
+	// CriticalServices are comma separated systemd units setting NodeServiceDegraded
+	// condition while they are degraded, disabled if empty.
+	CriticalServices string
+	// ServiceCheckPeriod is the period of checking critical services.
+	ServiceCheckPeriod time.Duration
+	// ServiceJournalErrors is the max errors of a service in journald in a period, disabled if zero.
+	ServiceJournalErrors int
+	// ServiceDegradedAction is Taint or Evict:<type> of NodeServiceDegraded condition.
+	ServiceDegradedAction string
+	// ServiceCommandPrefix runs systemctl, journalctl, chronyc and ntpq, e.g. in host namespaces.
+	ServiceCommandPrefix string
+	// MonitoredLinks are comma separated network interfaces setting NetworkLinkDegraded
+	// condition while they are down or flapping, disabled if empty.
+	MonitoredLinks string
+	// LinkCheckPeriod is the period of checking monitored links.
+	LinkCheckPeriod time.Duration
+	// LinkFlaps is the max carrier changes of a link in a period.
+	LinkFlaps int
+	// LinkDegradedAction is Taint or Evict of NetworkLinkDegraded condition.
+	LinkDegradedAction string
+	// DNSProbeNames are comma separated names resolved to set DNSUnhealthy
+	// condition while they fail, disabled if empty.
+	DNSProbeNames string
+	// DNSProbeServer is host:port of the DNS server probed, node's resolver if empty.
+	DNSProbeServer string
+	// DNSProbePeriod and DNSProbeTimeout are the period and timeout of probes.
+	DNSProbePeriod  time.Duration
+	DNSProbeTimeout time.Duration
+	// DNSFailureThreshold is the consecutive failures of a name setting DNSUnhealthy.
+	DNSFailureThreshold int
+	// MaxClockOffset is the max offset of clock to its time source, ClockSkewed
+	// condition is set while it's over or clock is not synchronized, disabled if zero.
+	MaxClockOffset time.Duration
+	// ClockCheckPeriod is the period of checking clock.
+	ClockCheckPeriod time.Duration
+	// ClockSkewedAction is Taint or Evict:<type> of ClockSkewed condition.
+	ClockSkewedAction string
+	// KubeletCertificates are comma separated pem files of certificates of kubelet
+	// setting KubeletCertificateExpiring condition, disabled if empty.
+	KubeletCertificates string
+	// CertificateCheckPeriod is the period of checking kubelet certificates.
+	CertificateCheckPeriod time.Duration
+	// CertificateExpiryThreshold is the time before expiry a certificate not rotated is expiring.
+	CertificateExpiryThreshold time.Duration
+	// PodName and PodNamespace are the pod of eviction agent, which is never evicted.
+	PodName      string
+	PodNamespace string
+	// ProtectInfraPods never chooses pods of DaemonSets and static pods.
+	ProtectInfraPods bool
+	// TolerationAware ranks pods tolerating the taint of condition or required
+	// to run on node after the others, they are scheduled back once evicted.
+	TolerationAware bool
+	// JobCompletionAware weights scores of pods of Jobs by the rest of their progress.
+	JobCompletionAware bool
+	// PinnedPods is Exclude or Rank of pods which can't be scheduled on other
+	// nodes, disabled if empty.
+	PinnedPods string
+	// SelfLimitRatio is the ratio of usage to limits of agent pod above which
+	// stats are sampled less often, disabled if zero.
+	SelfLimitRatio float64
+	// TenantLabel is the namespace label whose value is the tenant of namespace.
+	TenantLabel string
+	// TenantEvictionBudget is the max evictions of a tenant in TenantBudgetWindow, disabled if zero.
+	TenantEvictionBudget int
+	// TenantBudgetWindow is the window of tenant eviction budget.
+	TenantBudgetWindow time.Duration
+	// QuarantineThreshold is the evictions of pods of a workload for the same
+	// condition in QuarantineWindow which quarantine it, disabled if zero.
+	QuarantineThreshold int
+	// QuarantineWindow is the window of QuarantineThreshold.
+	QuarantineWindow time.Duration
+	// DrainAfter is how long a condition stays busy despite evictions before
+	// node is drained, disabled if zero.
+	DrainAfter time.Duration
+	// DrainPace is the min interval between evictions of drain.
+	DrainPace time.Duration
+	// SpotPreemption is the cloud provider whose preemption notice of spot
+	// instance drains node fast, disabled if empty.
+	SpotPreemption string
+	// SpotPreemptionPeriod is the period of checking preemption notice.
+	SpotPreemptionPeriod time.Duration
+	// SpotDrainPace is the min interval between evictions of drain of preemption.
+	SpotDrainPace time.Duration
+	// RuntimeExemption is how long DiskIo and NetworkIo evictions are not
+	// triggered after containers are created on node, disabled if zero.
+	RuntimeExemption time.Duration
+	// PlacementWait is how long the next eviction waits for the replacement of
+	// the evicted pod to be scheduled, disabled if zero.
+	PlacementWait time.Duration
+	// PlacementReturns are consecutive replacements scheduled back on node
+	// stopping evictions for PlacementBackoff.
+	PlacementReturns int
+	PlacementBackoff time.Duration
+	// PlanVictims plans the fewest pods whose usage relieves the excess over
+	// threshold, evicted at PlanPace, instead of the top consumer each time.
+	PlanVictims bool
+	// PlanPace is the min interval between evictions of a plan.
+	PlanPace time.Duration
+	// PlanMaxVictims is the max number of pods of a plan.
+	PlanMaxVictims int
+	// ConditionPriority is the comma separated evict types handled first in the order listed, e.g. CPUBusy,MemBusy.
+	ConditionPriority string
+	// ConcurrentPressure is how busy conditions of a cycle are evicted for, each or most-severe.
+	ConcurrentPressure string
+	// ConcurrentPressurePace is the min interval between evictions of busy conditions.
+	ConcurrentPressurePace time.Duration
+	// ClusterHeadroom is nodes or the url of spare capacity of cluster, node is
+	// not tainted and pods are labeled instead of evicted without it, disabled if empty.
+	ClusterHeadroom string
+	// ClusterHeadroomMin is the min spare capacity of cluster, e.g. cpu=1,memory=1Gi,pods=10.
+	ClusterHeadroomMin string
+	// ClusterHeadroomPeriod is the period of checking spare capacity of cluster.
+	ClusterHeadroomPeriod time.Duration
+	// TaintDelay is how long a condition stays busy in soft pressure before
+	// node is tainted by it, critical conditions taint node at once.
+	TaintDelay time.Duration
+	// UntaintProbes are semicolon separated condition=probe run before untainting
+	// node of the condition, which is kept tainted if the probe fails.
+	UntaintProbes string
+	// UntaintProbeTimeout is the timeout of each untaint probe.
+	UntaintProbeTimeout time.Duration
+	// Calibrate is suggest or apply of thresholds calibrated from baseline usage, disabled if empty.
+	Calibrate string
+	// CalibrationDays are the days of baseline usage kept, thresholds are applied after them.
+	CalibrationDays int
+	// CalibrationFactor is the factor of baseline usage of calibrated thresholds.
+	CalibrationFactor float64
+	// CalibrationMin and CalibrationMax are the bounds of calibrated thresholds as ratios of capacity.
+	CalibrationMin float64
+	CalibrationMax float64
 }
 
 func NewEvictionAgentOptions() *EvictionAgentOptions {
-	return &EvictionAgentOptions{}
+	return &EvictionAgentOptions{
+		Mode:                 ModeFull,
+		KubeAPIQPS:           5,
+		KubeAPIBurst:         10,
+		ClearLabelsQPS:       1,
+		ClearLabelsBurst:     10,
+		EvictMark:            EvictMarkLabel,
+		HealthAddress:        ":10270",
+		HealthStuckThreshold: 2 * time.Minute,
+		WatchdogPeriods:      5,
+		SafeModeFailures:     5,
+		SafeModeRetryPeriod:  5 * time.Minute,
+		ShutdownTimeout:      30 * time.Second,
+		EvaluationPeriod:     10 * time.Second,
+		EvaluationJitter:     0.1,
+		StatsTimeout:         5 * time.Second,
+		StatsConcurrency:     3,
+		MaxPodStats:          1024,
+		PodStatsWorkers:      8,
+		PodStatsTimeout:      time.Second,
+		WebhookTimeout:       5 * time.Second,
+		WebhookRetries:       3,
+		AlertmanagerTimeout:  5 * time.Second,
+		AlertmanagerResend:   time.Minute,
+		AuditLogMaxSize:      100,
+		AuditLogMaxBackups:   5,
+		LogLevel:             "info",
+		LogFormat:            log.TextFormat,
+		HistorySize:          100,
+		HistoryStore:         HistoryStoreMemory,
+		ConcurrentPressure:   ConcurrentPressureEach,
+		HistoryStorePath:     "/var/lib/eviction-agent/history.db",
+		TopPodsCount:         5,
+		KubeletPrecedence:    "Memory=Kubelet,DiskIo=Kubelet",
+		RebalanceHintDelay:   5 * time.Minute,
+		ResizeRatio:          2,
+		BurstCreditsPeriod:   5 * time.Minute,
+		BurstCreditsLow:      0.5,
+		BurstMinFactor:       0.5,
+		ServiceCheckPeriod:   30 * time.Second,
+		ServiceJournalErrors: 10,
+		ServiceDegradedAction: "Taint",
+		LinkCheckPeriod:      10 * time.Second,
+		LinkFlaps:            2,
+		LinkDegradedAction:   "Taint",
+		DNSProbePeriod:       10 * time.Second,
+		DNSProbeTimeout:      2 * time.Second,
+		DNSFailureThreshold:  3,
+		ClockCheckPeriod:     time.Minute,
+		ClockSkewedAction:    "Taint",
+		CertificateCheckPeriod: 10 * time.Minute,
+		CertificateExpiryThreshold: 72 * time.Hour,
+		ProtectInfraPods:     true,
+		TolerationAware:      true,
+		PinnedPods:           PinnedPodsExclude,
+		JobCompletionAware:   true,
+		SelfLimitRatio:       0.8,
+		TenantBudgetWindow:   time.Hour,
+		QuarantineWindow:     24 * time.Hour,
+		DrainPace:            30 * time.Second,
+		SpotPreemptionPeriod: 5 * time.Second,
+		SpotDrainPace:        500 * time.Millisecond,
+		UntaintProbeTimeout:  30 * time.Second,
+		PlacementReturns:     2,
+		PlacementBackoff:     30 * time.Minute,
+		PlanPace:             10 * time.Second,
+		PlanMaxVictims:       5,
+		ClusterHeadroomMin:   "cpu=1,memory=1Gi",
+		ClusterHeadroomPeriod: time.Minute,
+		CalibrationDays:      7,
+		CalibrationFactor:    1.5,
+		CalibrationMin:       0.5,
+		CalibrationMax:       0.95,
+	}
+}
+
+// AddFlags adds command line flags of eviction agent,
+// flags take precedence over environment variables.
+func (eao *EvictionAgentOptions) AddFlags(fs *flag.FlagSet) {
+	fs.StringVar(&eao.KubeconfigFile, "kubeconfig", eao.KubeconfigFile,
+		"Path to kubeconfig file, in-cluster config is used if not set.")
+	fs.StringVar(&eao.NodeName, "node-name", eao.NodeName,
+		"Name of the node to watch, default to NODE_NAME environment.")
+	fs.StringVar(&eao.PolicyConfigFile, "policy-config-file", eao.PolicyConfigFile,
+		"Path to policy configuration file, default to POLICY_CONFIG_FILE environment.")
+	fs.StringVar(&eao.LogDir, "log-dir", eao.LogDir,
+		"Path to log directory, default to LOG_DIR environment.")
+	fs.StringVar(&eao.LogLevel, "log-level", eao.LogLevel,
+		"Initial log level, debug, info, warning or error.")
+	fs.StringVar(&eao.LogFormat, "log-format", eao.LogFormat,
+		"Log format, text or json with structured fields like node, condition and pod.")
+	fs.Var(&eao.PolicyOverrides, "policy-override",
+		"Override a field of policy as key=value, e.g. taintThreshold.CPU=85%, can be repeated. "+
+			"Flags take precedence over EVICTION_POLICY_* environment, e.g. EVICTION_POLICY_TAINT_THRESHOLD_CPU.")
+	fs.StringVar(&eao.Profile, "profile", eao.Profile,
+		"Built-in policy profile used by policies which set no profile, one of conservative, balanced and aggressive.")
+	fs.StringVar(&eao.DisabledConditions, "disabled-conditions", eao.DisabledConditions,
+		"Comma separated conditions neither monitored nor tainted, some of CPU, Memory, DiskIo, NetworkIo.")
+	fs.BoolVar(&eao.EnablePolicyCRD, "enable-policy-crd", eao.EnablePolicyCRD,
+		"Use EvictionPolicy custom resource selecting this node instead of the policy configuration file.")
+	fs.BoolVar(&eao.NamespacePreferences, "namespace-preferences", eao.NamespacePreferences,
+		"Read eviction preferences of pods from annotations of their namespaces, sncloud.com/evictionWeight, "+
+			"sncloud.com/maxEvictionsPerHour and sncloud.com/evictionProtected.")
+	fs.StringVar(&eao.StatusNamespace, "status-namespace", eao.StatusNamespace,
+		"Namespace of NodeEvictionStatus reporting agent state, disabled if empty.")
+	fs.StringVar(&eao.HealthAddress, "health-address", eao.HealthAddress,
+		"Address serving /healthz, /readyz and /metrics, disabled if empty.")
+	fs.DurationVar(&eao.EvaluationPeriod, "evaluation-period", eao.EvaluationPeriod,
+		"Period of stats sync and condition evaluation.")
+	fs.Float64Var(&eao.EvaluationJitter, "evaluation-jitter", eao.EvaluationJitter,
+		"Max factor of evaluation period added randomly to each period, so that agents don't hit api server in lockstep.")
+	fs.StringVar(&eao.ConditionPeriods, "condition-periods", eao.ConditionPeriods,
+		"Comma separated condition=period evaluated at their own period, e.g. Memory=2s,NetworkIo=30s.")
+	fs.StringVar(&eao.NodeProblemConditions, "node-problem-conditions", eao.NodeProblemConditions,
+		"Comma separated node conditions, e.g. of node problem detector, mapped to actions. Node is tainted with the condition "+
+			"type while it's True for Taint, and pods using most of the resource are evicted too for Evict:<type>, "+
+			"e.g. KernelDeadlock=Taint,ReadonlyFilesystem=Evict:DiskIOBusy.")
+	fs.StringVar(&eao.KubeletPrecedence, "kubelet-precedence", eao.KubeletPrecedence,
+		"Comma separated condition=Kubelet or condition=Agent of Memory and DiskIo. Evictions of conditions with Kubelet "+
+			"are skipped while kubelet reports MemoryPressure or DiskPressure, so that pods are not evicted twice.")
+	fs.DurationVar(&eao.StatsTimeout, "stats-timeout", eao.StatsTimeout,
+		"Timeout of kubelet stats request and of each stats source, stats of a source timed out are kept from the last sync.")
+	fs.IntVar(&eao.StatsConcurrency, "stats-concurrency", eao.StatsConcurrency,
+		"Max number of stats sources (cpu, memory, network, disk, pod, reclaim, writeback, ports) collected at once.")
+	fs.IntVar(&eao.MaxPodStats, "max-pod-stats", eao.MaxPodStats,
+		"Max number of pods kept in each stats sample, pods beyond it by namespace and name are dropped "+
+			"and never chosen. Unlimited if zero.")
+	fs.IntVar(&eao.PodStatsWorkers, "pod-stats-workers", eao.PodStatsWorkers,
+		"Max number of pods whose stats, cgroup writeback and sockets are collected at once, one while agent is throttled.")
+	fs.DurationVar(&eao.PodStatsTimeout, "pod-stats-timeout", eao.PodStatsTimeout,
+		"Timeout of stats of each pod, a pod timed out is left out of the stats sample. Unlimited if zero.")
+	fs.DurationVar(&eao.ShutdownTimeout, "shutdown-timeout", eao.ShutdownTimeout,
+		"Max time to wait for the action in flight on SIGTERM or SIGINT.")
+	fs.DurationVar(&eao.HealthStuckThreshold, "health-stuck-threshold", eao.HealthStuckThreshold,
+		"Agent is unhealthy if taint loop or stats sync has no progress for this duration.")
+	fs.IntVar(&eao.WatchdogPeriods, "watchdog-periods", eao.WatchdogPeriods,
+		"Stats sync or taint loop is stalled if it completes no cycle in this many of its periods, agent is not ready while any is stalled. Disabled if 0.")
+	fs.BoolVar(&eao.WatchdogRestart, "watchdog-restart", eao.WatchdogRestart,
+		"Abandon stalled stats sync and start it again, the abandoned one exits once its cycle returns.")
+	fs.IntVar(&eao.SafeModeFailures, "safe-mode-failures", eao.SafeModeFailures,
+		"Enter safe mode after this many consecutive failures of taints, evictions and labels, e.g. of RBAC revoked or a webhook denying evictions. "+
+			"Actions are stopped and agent is not ready in safe mode. Disabled if 0.")
+	fs.DurationVar(&eao.SafeModeRetryPeriod, "safe-mode-retry-period", eao.SafeModeRetryPeriod,
+		"Period of attempting actions again in safe mode, the first one succeeding leaves it.")
+	fs.StringVar(&eao.DebugAddress, "debug-address", eao.DebugAddress,
+		"Address serving pprof, stats samples and eviction decisions, disabled if empty.")
+	fs.StringVar(&eao.RecordFile, "record", eao.RecordFile,
+		"File which stats samples with pod priorities are appended to as json lines, replayed by "+
+			"\"eviction-agent replay\". Pods are listed once more per sample, disabled if empty.")
+	fs.StringVar(&eao.WebhookURL, "webhook-url", eao.WebhookURL,
+		"Url notified with json on taint, untaint and eviction, disabled if empty.")
+	fs.DurationVar(&eao.WebhookTimeout, "webhook-timeout", eao.WebhookTimeout,
+		"Timeout of each webhook request.")
+	fs.IntVar(&eao.WebhookRetries, "webhook-retries", eao.WebhookRetries,
+		"Number of retries after webhook request fails.")
+	fs.StringVar(&eao.AlertmanagerURL, "alertmanager-url", eao.AlertmanagerURL,
+		"Url of Alertmanager, e.g. http://alertmanager:9093, which alerts like NodeDiskIOPressure are fired to while "+
+			"node is tainted and resolved once it's untainted, labeled with node, condition and top offender, "+
+			"disabled if empty.")
+	fs.DurationVar(&eao.AlertmanagerTimeout, "alertmanager-timeout", eao.AlertmanagerTimeout,
+		"Timeout of each request to Alertmanager.")
+	fs.DurationVar(&eao.AlertmanagerResend, "alertmanager-resend", eao.AlertmanagerResend,
+		"Period of sending firing alerts again, it should be shorter than resolve_timeout of Alertmanager.")
+	fs.StringVar(&eao.APIAddress, "api-address", eao.APIAddress,
+		"Address serving read-only json api, disabled if empty.")
+	fs.StringVar(&eao.APITokenFile, "api-token-file", eao.APITokenFile,
+		"File containing the bearer token required by api.")
+	fs.StringVar(&eao.ControlAddress, "control-address", eao.ControlAddress,
+		"Address of grpc control api v1 for node-local tooling, unix:///path, or host:port which requires --control-tls-cert-file, disabled if empty.")
+	fs.StringVar(&eao.ControlTokenFile, "control-token-file", eao.ControlTokenFile,
+		"File containing the bearer token required by control api.")
+	fs.StringVar(&eao.ControlTLSCertFile, "control-tls-cert-file", eao.ControlTLSCertFile,
+		"Certificate of https of control api.")
+	fs.StringVar(&eao.ControlTLSKeyFile, "control-tls-key-file", eao.ControlTLSKeyFile,
+		"Key of --control-tls-cert-file.")
+	fs.StringVar(&eao.ControlClientCAFile, "control-client-ca-file", eao.ControlClientCAFile,
+		"CA verifying client certificates of control api, clients must present certificates signed by it if set. "+
+			"Control api requires it or --control-token-file.")
+	fs.BoolVar(&eao.APIManualEvictions, "api-manual-evictions", eao.APIManualEvictions,
+		"Enable POST /v1/evict?type=<condition> of api, which chooses and evicts a pod of the condition now "+
+			"with the same scoring and filters, e.g. of DiskIOBusy.")
+	fs.StringVar(&eao.AuditLogFile, "audit-log-file", eao.AuditLogFile,
+		"Append-only audit log of evictions and labels, disabled if empty.")
+	fs.IntVar(&eao.AuditLogMaxSize, "audit-log-max-size", eao.AuditLogMaxSize,
+		"Max size in MB of audit log before it's rotated.")
+	fs.IntVar(&eao.AuditLogMaxBackups, "audit-log-max-backups", eao.AuditLogMaxBackups,
+		"Max number of rotated audit logs kept.")
+	fs.IntVar(&eao.HistorySize, "history-size", eao.HistorySize,
+		"Max number of eviction decisions kept in memory for /v1/history and /debug/history.")
+	fs.StringVar(&eao.HistoryStore, "history-store", eao.HistoryStore,
+		"Where eviction decisions are kept across restarts: memory, file of --history-store-path, or configmap eviction-history-<node> of --status-namespace.")
+	fs.StringVar(&eao.HistoryStorePath, "history-store-path", eao.HistoryStorePath,
+		"Bolt file of eviction decisions of --history-store=file, e.g. on a hostPath volume.")
+	fs.StringVar(&eao.TextfileDir, "textfile-dir", eao.TextfileDir,
+		"Directory of textfile collector of node_exporter, conditions, taints and phases are written into eviction_agent.prom in it each cycle, disabled if empty.")
+	fs.DurationVar(&eao.TopPodsPeriod, "top-pods-period", eao.TopPodsPeriod,
+		"Period of annotating node with top pods by cpu, memory, disk io and network usage even if no threshold is crossed, disabled if zero.")
+	fs.IntVar(&eao.TopPodsCount, "top-pods-count", eao.TopPodsCount,
+		"Number of top pods of each resource in node annotation and /v1/toppods.")
+	fs.DurationVar(&eao.SummaryPeriod, "summary-period", eao.SummaryPeriod,
+		"Period of node events EvictionAgentSummary and annotation sncloud.com/activitySummary with counts of taints, "+
+			"actions, suppressed decisions and top pressure sources since the last summary, e.g. 1h, disabled if zero.")
+	fs.BoolVar(&eao.RebalanceHints, "rebalance-hints", eao.RebalanceHints,
+		"Annotate node with sncloud.com/needsRebalance and sncloud.com/pressuredResources while resources are tainted "+
+			"for --rebalance-hint-delay, hints for descheduler policies and cluster autoscaler.")
+	fs.DurationVar(&eao.RebalanceHintDelay, "rebalance-hint-delay", eao.RebalanceHintDelay,
+		"Min taint duration of a resource before it's in rebalance hints, so that short pressure is not hinted.")
+	fs.StringVar(&eao.Mode, "mode", eao.Mode,
+		"Operating mode, full taints node and evicts or labels pods, taint-only never evicts or labels pods, "+
+			"monitor-only only reports conditions by metrics and status, for staged rollout or clusters "+
+			"where eviction is not granted.")
+	fs.DurationVar(&eao.ObserveFor, "observe-for", eao.ObserveFor,
+		"Canary duration from the first start on node, e.g. 72h, in which every decision is recorded in history "+
+			"but no action is taken, then agent takes actions in --mode, disabled if zero.")
+	fs.StringVar(&eao.ResizeAction, "resize-action", eao.ResizeAction,
+		"Action instead of evicting pods using more than --resize-ratio times of their cpu or memory requests, "+
+			"Resize patches requests in place (needs InPlacePodVerticalScaling) and evicts pod if resize fails, "+
+			"Recommend records an event with the recommended requests, disabled if empty.")
+	fs.Float64Var(&eao.ResizeRatio, "resize-ratio", eao.ResizeRatio,
+		"Min ratio of cpu or memory usage to requests of pods resized instead of evicted.")
+	fs.StringVar(&eao.BurstCreditsProvider, "burst-credits-provider", eao.BurstCreditsProvider,
+		"Provider of burst credits tightening CPU and DiskIo thresholds as credits run out, aws (EBS gp2 burst balance "+
+			"and EC2 cpu credits), azure (disk bursting credits) or an http url returning json like {\"DiskIo\": 0.3}, disabled if empty.")
+	fs.DurationVar(&eao.BurstCreditsPeriod, "burst-credits-period", eao.BurstCreditsPeriod,
+		"Period of probing burst credits, thresholds are not tightened if credits are not probed for 3 periods.")
+	fs.Float64Var(&eao.BurstCreditsLow, "burst-credits-low", eao.BurstCreditsLow,
+		"Fraction of burst credits left below which thresholds are tightened.")
+	fs.Float64Var(&eao.BurstMinFactor, "burst-min-factor", eao.BurstMinFactor,
+		"Factor of thresholds when burst credits are used up, thresholds are scaled linearly from --burst-credits-low.")
+	fs.StringVar(&eao.ExtendedResources, "extended-resources", eao.ExtendedResources,
+		"Comma separated extended resources of device plugins with optional thresholds of allocatable, e.g. "+
+			"intel.com/fpga=90%,smarter-devices/fuse. Node is tainted with <resource>Busy, e.g. intel.com/fpgaBusy, "+
+			"while usage of --extended-resource-usage-url is over the threshold, default to 90%.")
+	fs.StringVar(&eao.ExtendedResourceUsageURL, "extended-resource-usage-url", eao.ExtendedResourceUsageURL,
+		"Url returning usage of extended resources as json like "+
+			"{\"node\": {\"intel.com/fpga\": 3}, \"pods\": {\"default/trainer\": {\"intel.com/fpga\": 2}}}, "+
+			"pods with the most usage are evicted.")
+	fs.StringVar(&eao.CriticalServices, "critical-services", eao.CriticalServices,
+		"Comma separated systemd units, e.g. containerd.service,kubelet.service,systemd-networkd.service. Node condition "+
+			"NodeServiceDegraded is set while any of them is not active, restarted or logging errors, disabled if empty.")
+	fs.DurationVar(&eao.ServiceCheckPeriod, "service-check-period", eao.ServiceCheckPeriod,
+		"Period of checking critical services.")
+	fs.IntVar(&eao.ServiceJournalErrors, "service-journal-errors", eao.ServiceJournalErrors,
+		"Max entries of priority err of a critical service in journald in a check period, disabled if zero.")
+	fs.StringVar(&eao.ServiceDegradedAction, "service-degraded-action", eao.ServiceDegradedAction,
+		"Action of NodeServiceDegraded, Taint or Evict:<type> evicting lower priority pods and labeling others like "+
+			"--node-problem-conditions, e.g. Evict:CPUBusy.")
+	fs.StringVar(&eao.ServiceCommandPrefix, "service-command-prefix", eao.ServiceCommandPrefix,
+		"Command running systemctl, journalctl, chronyc and ntpq, e.g. \"nsenter -t 1 -m --\" in host mount "+
+			"namespace with hostPID.")
+	fs.StringVar(&eao.MonitoredLinks, "monitored-links", eao.MonitoredLinks,
+		"Comma separated network interfaces of host, e.g. eth0,bond0. Node condition NetworkLinkDegraded is set while "+
+			"any of them is down, flapping or has a bond member failed, and node is tainted PreferNoSchedule, disabled if empty.")
+	fs.DurationVar(&eao.LinkCheckPeriod, "link-check-period", eao.LinkCheckPeriod,
+		"Period of checking monitored links.")
+	fs.IntVar(&eao.LinkFlaps, "link-flaps", eao.LinkFlaps,
+		"Max carrier changes of a monitored link in a check period, a link changing more is flapping.")
+	fs.StringVar(&eao.LinkDegradedAction, "link-degraded-action", eao.LinkDegradedAction,
+		"Action of NetworkLinkDegraded, Taint or Evict evicting pods annotated "+types.LatencySensitiveAnnotation+
+			"=true to healthier nodes.")
+	fs.StringVar(&eao.DNSProbeNames, "dns-probe-names", eao.DNSProbeNames,
+		"Comma separated names resolved periodically, e.g. kubernetes.default.svc.cluster.local. Node condition "+
+			"DNSUnhealthy is set and node is tainted while any of them fails to resolve, disabled if empty.")
+	fs.StringVar(&eao.DNSProbeServer, "dns-probe-server", eao.DNSProbeServer,
+		"Host:port of the DNS server probed, e.g. 169.254.20.10:53 of node-local DNS cache, the resolver of node if empty.")
+	fs.DurationVar(&eao.DNSProbePeriod, "dns-probe-period", eao.DNSProbePeriod,
+		"Period of probing names.")
+	fs.DurationVar(&eao.DNSProbeTimeout, "dns-probe-timeout", eao.DNSProbeTimeout,
+		"Timeout of resolving a name.")
+	fs.IntVar(&eao.DNSFailureThreshold, "dns-failure-threshold", eao.DNSFailureThreshold,
+		"Consecutive failures of a name setting DNSUnhealthy, so that a single lost packet doesn't taint node.")
+	fs.DurationVar(&eao.MaxClockOffset, "max-clock-offset", eao.MaxClockOffset,
+		"Max offset of clock of node to its time source by chronyd or ntpd, e.g. 500ms. Node condition ClockSkewed "+
+			"is set while the offset is over or clock is not synchronized, disabled if zero.")
+	fs.DurationVar(&eao.ClockCheckPeriod, "clock-check-period", eao.ClockCheckPeriod,
+		"Period of checking clock.")
+	fs.StringVar(&eao.ClockSkewedAction, "clock-skewed-action", eao.ClockSkewedAction,
+		"Action of ClockSkewed, Taint or Evict:<type> like --service-degraded-action.")
+	fs.StringVar(&eao.KubeletCertificates, "kubelet-certificates", eao.KubeletCertificates,
+		"Comma separated pem files of certificates of kubelet, e.g. /var/lib/kubelet/pki/kubelet-client-current.pem,"+
+			"/var/lib/kubelet/pki/kubelet-server-current.pem. Node condition KubeletCertificateExpiring is set and node "+
+			"is tainted PreferNoSchedule while any of them expires in --certificate-expiry-threshold and is past its "+
+			"rotation deadline, disabled if empty.")
+	fs.DurationVar(&eao.CertificateCheckPeriod, "certificate-check-period", eao.CertificateCheckPeriod,
+		"Period of checking kubelet certificates.")
+	fs.DurationVar(&eao.CertificateExpiryThreshold, "certificate-expiry-threshold", eao.CertificateExpiryThreshold,
+		"Time before expiry a kubelet certificate not rotated is expiring.")
+	fs.StringVar(&eao.OTLPEndpoint, "otlp-endpoint", eao.OTLPEndpoint,
+		"OTLP/HTTP endpoint receiving traces of stats sync, evaluation and eviction, e.g. http://otel-collector:4318, "+
+			"default to OTEL_EXPORTER_OTLP_ENDPOINT environment, disabled if empty.")
+	fs.StringVar(&eao.PodName, "pod-name", eao.PodName,
+		"Name of the pod of eviction agent, which is never evicted, default to POD_NAME environment.")
+	fs.StringVar(&eao.PodNamespace, "pod-namespace", eao.PodNamespace,
+		"Namespace of the pod of eviction agent, default to POD_NAMESPACE environment.")
+	fs.BoolVar(&eao.TolerationAware, "toleration-aware", eao.TolerationAware,
+		"Choose pods tolerating the taint of the busy condition, or required to run on node by node selector or "+
+			"node affinity of hostname, only if no other pod consumes the resource, they are scheduled back once evicted.")
+	fs.BoolVar(&eao.JobCompletionAware, "job-completion-aware", eao.JobCompletionAware,
+		"Weight scores of pods of Jobs by the rest of progress of their Jobs, of annotation sncloud.com/progress "+
+			"of pod or Job like 95%, or else elapsed time of activeDeadlineSeconds, so that Jobs close to completion "+
+			"are chosen after the others.")
+	fs.StringVar(&eao.PinnedPods, "pinned-pods", eao.PinnedPods,
+		"How pods which can't be scheduled on other nodes are chosen, by node selector or required node affinity "+
+			"matching labels of no other node, or node affinity of their persistent volumes like local volumes. "+
+			"Exclude never chooses them, Rank chooses them after the others, disabled if empty.")
+	fs.BoolVar(&eao.ProtectInfraPods, "protect-infra-pods", eao.ProtectInfraPods,
+		"Never choose pods of DaemonSets and static pods, e.g. monitoring agents, they are recreated on the same node anyway.")
+	fs.Float64Var(&eao.SelfLimitRatio, "self-limit-ratio", eao.SelfLimitRatio,
+		"Ratio of cpu or memory usage to limits of agent pod above which stats are sampled less often and fewer "+
+			"samples are kept, disabled if zero or agent pod has no limits.")
+	fs.StringVar(&eao.TenantLabel, "tenant-label", eao.TenantLabel,
+		"Namespace label whose value is the tenant of namespace, e.g. tenant, namespaces without it have no eviction budget.")
+	fs.IntVar(&eao.TenantEvictionBudget, "tenant-eviction-budget", eao.TenantEvictionBudget,
+		"Max pods of a tenant evicted in --tenant-budget-window, candidates of other tenants are chosen after that, disabled if zero.")
+	fs.DurationVar(&eao.TenantBudgetWindow, "tenant-budget-window", eao.TenantBudgetWindow,
+		"Window of tenant eviction budget.")
+	fs.IntVar(&eao.QuarantineThreshold, "quarantine-threshold", eao.QuarantineThreshold,
+		"Evictions of pods of a workload for the same condition in --quarantine-window, counted by agents of all nodes, "+
+			"which label the workload sncloud.com/quarantined=true with an event, disabled if zero.")
+	fs.DurationVar(&eao.QuarantineWindow, "quarantine-window", eao.QuarantineWindow,
+		"Window of --quarantine-threshold.")
+	fs.DurationVar(&eao.DrainAfter, "drain-after", eao.DrainAfter,
+		"Duration a condition stays busy despite evictions, e.g. of a dying disk or NIC, after which node is "+
+			"cordoned and all pods not protected are evicted at --drain-pace respecting PDBs, disabled if zero.")
+	fs.DurationVar(&eao.DrainPace, "drain-pace", eao.DrainPace,
+		"Min interval between evictions of drain.")
+	fs.StringVar(&eao.SpotPreemption, "spot-preemption", eao.SpotPreemption,
+		fmt.Sprintf("Cloud provider of spot instance of node, one of %v, whose preemption notice from the metadata server "+
+			"cordons node and evicts pods not protected at --spot-drain-pace, pods of higher priority first, disabled if empty.",
+			preemption.Providers()))
+	fs.DurationVar(&eao.SpotPreemptionPeriod, "spot-preemption-period", eao.SpotPreemptionPeriod,
+		"Period of checking preemption notice of spot instance.")
+	fs.DurationVar(&eao.SpotDrainPace, "spot-drain-pace", eao.SpotDrainPace,
+		"Min interval between evictions of drain of preemption.")
+	fs.DurationVar(&eao.RuntimeExemption, "runtime-exemption", eao.RuntimeExemption,
+		"Duration after containers are created on node, e.g. by image pulls of rolling deployments, during which "+
+			"DiskIo and NetworkIo taint node without triggering evictions, disabled if zero.")
+	fs.DurationVar(&eao.PlacementWait, "placement-wait", eao.PlacementWait,
+		"Max duration the next eviction waits for the replacement of the evicted pod to be scheduled, so that pods are "+
+			"evicted by scheduler placement instead of all at once, disabled if zero.")
+	fs.IntVar(&eao.PlacementReturns, "placement-returns", eao.PlacementReturns,
+		"Consecutive replacements of evicted pods scheduled back on node, e.g. tolerating its taints, after which "+
+			"evictions are stopped for --placement-backoff with a warning event EvictionChurn, to avoid churn loops.")
+	fs.DurationVar(&eao.PlacementBackoff, "placement-backoff", eao.PlacementBackoff,
+		"Duration evictions are stopped by --placement-returns.")
+	fs.BoolVar(&eao.PlanVictims, "plan-victims", eao.PlanVictims,
+		"Plan the fewest pods whose usage relieves the excess of usage over threshold, respecting disruptions allowed "+
+			"by PodDisruptionBudgets, and evict them at --plan-pace, instead of evicting the top consumer each time. "+
+			"The plan is shown in decision explanations.")
+	fs.DurationVar(&eao.PlanPace, "plan-pace", eao.PlanPace,
+		"Min interval between evictions of a plan of --plan-victims.")
+	fs.IntVar(&eao.PlanMaxVictims, "plan-max-victims", eao.PlanMaxVictims,
+		"Max number of pods of a plan of --plan-victims.")
+	fs.StringVar(&eao.ConditionPriority, "condition-priority", eao.ConditionPriority,
+		"Comma separated evict types handled first in the order listed when conditions are busy at the same time, e.g. CPUBusy,MemBusy. "+
+			"The others follow in the default order, MemBusy, CPUBusy, DiskIOBusy and WritebackBusy, network and ephemeral ports, "+
+			"extended resources, then node problems.")
+	fs.StringVar(&eao.ConcurrentPressure, "concurrent-pressure", eao.ConcurrentPressure,
+		"How conditions busy at the same time are evicted for: each evicts one pod for each of them in the order of their priorities, "+
+			"most-severe evicts one pod for the most severe of them by severity and excess over threshold, the others wait for next cycles.")
+	fs.DurationVar(&eao.ConcurrentPressurePace, "concurrent-pressure-pace", eao.ConcurrentPressurePace,
+		"Min interval between evictions of busy conditions, so that usage is measured again before the next one, disabled if zero.")
+	fs.StringVar(&eao.ClusterHeadroom, "cluster-headroom", eao.ClusterHeadroom,
+		"Check spare capacity of cluster every --cluster-headroom-period, by allocatable minus requests of other ready "+
+			"and schedulable nodes if nodes, or by json {\"cpu\": cores, \"memory\": bytes, \"pods\": n} of an http(s) "+
+			"url, e.g. of cluster autoscaler. While it's below --cluster-headroom-min, node is not tainted NoSchedule and "+
+			"pods are labeled instead of evicted, evicted pods would be Pending. Disabled if empty.")
+	fs.StringVar(&eao.ClusterHeadroomMin, "cluster-headroom-min", eao.ClusterHeadroomMin,
+		"Comma separated min spare capacity of cluster of --cluster-headroom, e.g. cpu=1,memory=1Gi,pods=10.")
+	fs.DurationVar(&eao.ClusterHeadroomPeriod, "cluster-headroom-period", eao.ClusterHeadroomPeriod,
+		"Period of checking spare capacity of cluster of --cluster-headroom.")
+	fs.DurationVar(&eao.TaintDelay, "taint-delay", eao.TaintDelay,
+		"Duration a condition stays busy in SoftPressure before node is tainted by it and pods are evicted, so "+
+			"that short spikes are ignored. Critical conditions of policy severity taint node at once. Zero taints at once.")
+	fs.StringVar(&eao.UntaintProbes, "untaint-probes", eao.UntaintProbes,
+		"Semicolon separated condition=probe run after untaint grace period before untainting node of the condition, "+
+			"e.g. \"DiskIo=disk /var/lib/kubelet 64Mi 50Mi/s;NetworkIo=http http://mirror/probe 10Mi/s\". Probes are "+
+			"disk <dir> <size> <min rate>, http <url> <min rate> or exec <command> [args...], node is kept tainted "+
+			"for another grace period if the probe fails, so that an idle resource whose load is evicted is not "+
+			"taken as healthy.")
+	fs.DurationVar(&eao.UntaintProbeTimeout, "untaint-probe-timeout", eao.UntaintProbeTimeout,
+		"Timeout of each untaint probe.")
+	fs.StringVar(&eao.Calibrate, "calibrate", eao.Calibrate,
+		"Calibrate taint thresholds as --calibration-factor times of the baseline usage of node, the median of daily "+
+			"p95 usage, suggest logs and exports them, apply uses them after --calibration-days, disabled if empty.")
+	fs.IntVar(&eao.CalibrationDays, "calibration-days", eao.CalibrationDays,
+		"Days of baseline usage kept in node annotation sncloud.com/usageBaseline.")
+	fs.Float64Var(&eao.CalibrationFactor, "calibration-factor", eao.CalibrationFactor,
+		"Factor of baseline usage of calibrated thresholds.")
+	fs.Float64Var(&eao.CalibrationMin, "calibration-min", eao.CalibrationMin,
+		"Min calibrated threshold as a ratio of capacity.")
+	fs.Float64Var(&eao.CalibrationMax, "calibration-max", eao.CalibrationMax,
+		"Max calibrated threshold as a ratio of capacity.")
+	fs.Float64Var(&eao.KubeAPIQPS, "kube-api-qps", eao.KubeAPIQPS,
+		"QPS to use while talking with kubernetes apiserver.")
+	fs.IntVar(&eao.KubeAPIBurst, "kube-api-burst", eao.KubeAPIBurst,
+		"Burst to use while talking with kubernetes apiserver.")
+	fs.Float64Var(&eao.ClearLabelsQPS, "clear-labels-qps", eao.ClearLabelsQPS,
+		"Api calls per second allowed to clear evict labels from pods.")
+	fs.IntVar(&eao.ClearLabelsBurst, "clear-labels-burst", eao.ClearLabelsBurst,
+		"Max api calls allowed to clear evict labels from pods at once.")
+	fs.StringVar(&eao.EvictMark, "evict-mark", eao.EvictMark,
+		"How pods to evict are marked, label or annotation, whose key is --evict-mark-prefix and priority "+
+			"NeedsEviction or EvictionCandidate, and value is priority and evict types joined by dots.")
+	fs.StringVar(&eao.EvictMarkPrefix, "evict-mark-prefix", eao.EvictMarkPrefix,
+		"Prefix of keys of evict marks, e.g. eviction-agent.io/, so that they don't collide with user labels.")
+}
+
+// SetKubeconfigFile sets `KubeconfigFile` field from environment if it's not set by flag
+func (eao *EvictionAgentOptions) SetKubeconfigFile() {
+	if eao.KubeconfigFile == "" {
+		eao.KubeconfigFile = os.Getenv("KUBECONFIG")
+	}
+}
+
+// SetOTLPEndpoint sets `OTLPEndpoint` field from environment if it's not set by flag
+func (eao *EvictionAgentOptions) SetOTLPEndpoint() {
+	if eao.OTLPEndpoint == "" {
+		eao.OTLPEndpoint = os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	}
+	eao.OTLPEndpoint = strings.TrimSuffix(eao.OTLPEndpoint, "/")
 }
 
 // SetNodeNameOrDie sets `NodeName` field with valid value
 func (eao *EvictionAgentOptions) SetNodeNameOrDie() {
-	// Get node name from environment variable NODE_NAME
+	// Get node name from environment variable NODE_NAME if it's not set by flag
 	// By default, assume that the NODE_NAME env should have been set with
 	// downward api or user defined exported environment variable.
-	eao.NodeName = os.Getenv("NODE_NAME")
+	if eao.NodeName == "" {
+		eao.NodeName = os.Getenv("NODE_NAME")
+	}
 	if eao.NodeName == "" {
 		log.Errorf("Failed to get node node from environment")
 		panic(fmt.Errorf("failed to get node name from environment"))
 	}
 }
 
+// SetPodIdentity sets `PodName` and `PodNamespace` fields from environment if they're not set by flag
+func (eao *EvictionAgentOptions) SetPodIdentity() {
+	if eao.PodName == "" {
+		eao.PodName = os.Getenv("POD_NAME")
+	}
+	if eao.PodNamespace == "" {
+		eao.PodNamespace = os.Getenv("POD_NAMESPACE")
+	}
+}
+
 func (eao *EvictionAgentOptions) SetPolicyConfigFileOrDie() {
-	eao.PolicyConfigFile = os.Getenv("POLICY_CONFIG_FILE")
 	if eao.PolicyConfigFile == "" {
+		eao.PolicyConfigFile = os.Getenv("POLICY_CONFIG_FILE")
+	}
+	// policy configuration file is optional if EvictionPolicy is used
+	if eao.PolicyConfigFile == "" && !eao.EnablePolicyCRD {
 		log.Errorf("Failed to get policy configure file")
 		panic(fmt.Errorf("failed to get policy configuration file"))
 	}
 }
 
+// GetAPITokenOrDie reads the api bearer token from APITokenFile
+func (eao *EvictionAgentOptions) GetAPITokenOrDie() string {
+	if eao.APITokenFile == "" {
+		log.Errorf("Api token file is required by api")
+		panic(fmt.Errorf("api token file is required by api"))
+	}
+	data, err := ioutil.ReadFile(eao.APITokenFile)
+	if err != nil {
+		log.Errorf("Failed to read api token file %v: %v", eao.APITokenFile, err)
+		panic(err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		log.Errorf("Api token file %v is empty", eao.APITokenFile)
+		panic(fmt.Errorf("api token file %v is empty", eao.APITokenFile))
+	}
+	return token
+}
+
+// GetControlAuthOrDie returns the bearer token and tls config of control
+// api. Control api requires a token, client certificates or both, and tls
+// unless it's on a unix socket.
+func (eao *EvictionAgentOptions) GetControlAuthOrDie() (string, *tls.Config) {
+	var token string
+	if eao.ControlTokenFile != "" {
+		data, err := ioutil.ReadFile(eao.ControlTokenFile)
+		if err != nil {
+			log.Errorf("Failed to read control token file %v: %v", eao.ControlTokenFile, err)
+			panic(err)
+		}
+		token = strings.TrimSpace(string(data))
+		if token == "" {
+			log.Errorf("Control token file %v is empty", eao.ControlTokenFile)
+			panic(fmt.Errorf("control token file %v is empty", eao.ControlTokenFile))
+		}
+	}
+	if token == "" && eao.ControlClientCAFile == "" {
+		log.Errorf("Control token file or client CA file is required by control api")
+		panic(fmt.Errorf("control token file or client CA file is required by control api"))
+	}
+	if eao.ControlTLSCertFile == "" && eao.ControlClientCAFile == "" {
+		// the token is sent in plaintext, which only the owner of socket can read
+		if !strings.HasPrefix(eao.ControlAddress, server.UnixPrefix) {
+			err := fmt.Errorf("control api on tcp address %s requires tls or mTLS, plaintext is only allowed on unix socket", eao.ControlAddress)
+			log.Errorf("Invalid control address: %v", err)
+			panic(err)
+		}
+		return token, nil
+	}
+	if eao.ControlTLSCertFile == "" || eao.ControlTLSKeyFile == "" {
+		log.Errorf("Control tls cert file and key file are required by https of control api")
+		panic(fmt.Errorf("control tls cert file and key file are required by https of control api"))
+	}
+	cert, err := tls.LoadX509KeyPair(eao.ControlTLSCertFile, eao.ControlTLSKeyFile)
+	if err != nil {
+		log.Errorf("Failed to load control tls cert: %v", err)
+		panic(err)
+	}
+	config := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if eao.ControlClientCAFile != "" {
+		data, err := ioutil.ReadFile(eao.ControlClientCAFile)
+		if err != nil {
+			log.Errorf("Failed to read control client CA file %v: %v", eao.ControlClientCAFile, err)
+			panic(err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			log.Errorf("No certificate in control client CA file %v", eao.ControlClientCAFile)
+			panic(fmt.Errorf("no certificate in control client CA file %v", eao.ControlClientCAFile))
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return token, config
+}
+
+// ValidateProfileOrDie checks the default policy profile
+func (eao *EvictionAgentOptions) ValidateProfileOrDie() {
+	if err := config.ValidateProfile(eao.Profile); err != nil {
+		log.Errorf("Invalid --profile: %v", err)
+		panic(err)
+	}
+}
+
+// GetPolicyOverridesOrDie returns overrides of EVICTION_POLICY_* environment followed by --policy-override
+func (eao *EvictionAgentOptions) GetPolicyOverridesOrDie() []config.Override {
+	overrides := config.EnvOverrides(os.LookupEnv)
+	for _, s := range eao.PolicyOverrides {
+		o, err := config.ParseOverride(s)
+		if err != nil {
+			log.Errorf("Invalid --policy-override: %v", err)
+			panic(err)
+		}
+		overrides = append(overrides, o)
+	}
+	return overrides
+}
+
+// stringList is a flag which can be repeated
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// GetConditionPeriodsOrDie returns periods of ConditionPeriods
+func (eao *EvictionAgentOptions) GetConditionPeriodsOrDie() map[string]time.Duration {
+	periods := make(map[string]time.Duration)
+	for _, item := range strings.Split(eao.ConditionPeriods, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		parts := strings.SplitN(item, "=", 2)
+		err := fmt.Errorf("invalid --condition-periods %q, should be condition=period", item)
+		if len(parts) == 2 {
+			err = config.ValidateConditions([]string{parts[0]})
+		}
+		var period time.Duration
+		if err == nil {
+			period, err = time.ParseDuration(parts[1])
+		}
+		if err == nil && period <= 0 {
+			err = fmt.Errorf("period of %s should be positive", parts[0])
+		}
+		if err != nil {
+			log.Errorf("Invalid --condition-periods: %v", err)
+			panic(err)
+		}
+		periods[parts[0]] = period
+	}
+	return periods
+}
+
+// nodeProblemEvictTypes are the evict types choosing pods of node problems
+var nodeProblemEvictTypes = map[string]bool{
+	types.CPUBusy:       true,
+	types.MemBusy:       true,
+	types.DiskIO:        true,
+	types.NetworkRxBusy: true,
+	types.NetworkTxBusy: true,
+}
+
+// reservedNodeConditions are node conditions owned by kubelet
+var reservedNodeConditions = map[string]bool{
+	"Ready":              true,
+	"MemoryPressure":     true,
+	"DiskPressure":       true,
+	"PIDPressure":        true,
+	"NetworkUnavailable": true,
+}
+
+// GetNodeProblemConditionsOrDie returns node conditions of NodeProblemConditions,
+// mapped to the evict type choosing pods to evict, empty if node is only tainted
+func (eao *EvictionAgentOptions) GetNodeProblemConditionsOrDie() map[string]string {
+	problems := make(map[string]string)
+	for _, item := range strings.Split(eao.NodeProblemConditions, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		parts := strings.SplitN(item, "=", 2)
+		var err error
+		switch {
+		case len(parts) != 2 || parts[0] == "":
+			err = fmt.Errorf("invalid --node-problem-conditions %q, should be condition=Taint or condition=Evict:<type>", item)
+		case reservedNodeConditions[parts[0]] || nodeProblemEvictTypes[parts[0]] || parts[0] == types.NetworkIO:
+			err = fmt.Errorf("node condition %s is reserved", parts[0])
+		default:
+			problems[parts[0]], err = nodeProblemAction(parts[0], parts[1])
+		}
+		if err != nil {
+			log.Errorf("Invalid --node-problem-conditions: %v", err)
+			panic(err)
+		}
+	}
+	// critical services are handled as a node problem unless it's set above
+	if _, ok := problems[types.NodeServiceDegraded]; eao.CriticalServices != "" && !ok {
+		rankBy, err := nodeProblemAction(types.NodeServiceDegraded, eao.ServiceDegradedAction)
+		if err != nil {
+			log.Errorf("Invalid --service-degraded-action: %v", err)
+			panic(err)
+		}
+		problems[types.NodeServiceDegraded] = rankBy
+	}
+	// so are monitored links, pods are chosen by latency sensitive annotation
+	if _, ok := problems[types.NetworkLinkDegraded]; eao.MonitoredLinks != "" && !ok {
+		switch eao.LinkDegradedAction {
+		case "Taint":
+			problems[types.NetworkLinkDegraded] = ""
+		case "Evict":
+			problems[types.NetworkLinkDegraded] = types.NetworkLinkDegraded
+		default:
+			err := fmt.Errorf("invalid action %q of %s, should be Taint or Evict", eao.LinkDegradedAction,
+				types.NetworkLinkDegraded)
+			log.Errorf("Invalid --link-degraded-action: %v", err)
+			panic(err)
+		}
+	}
+	// and failures of DNS, evicting pods doesn't fix them
+	if _, ok := problems[types.DNSUnhealthy]; eao.DNSProbeNames != "" && !ok {
+		problems[types.DNSUnhealthy] = ""
+	}
+	if _, ok := problems[types.ClockSkewed]; eao.MaxClockOffset > 0 && !ok {
+		rankBy, err := nodeProblemAction(types.ClockSkewed, eao.ClockSkewedAction)
+		if err != nil {
+			log.Errorf("Invalid --clock-skewed-action: %v", err)
+			panic(err)
+		}
+		problems[types.ClockSkewed] = rankBy
+	}
+	// expiring certificates are renewed by operators, pods are not evicted for them
+	if _, ok := problems[types.KubeletCertificateExpiring]; eao.KubeletCertificates != "" && !ok {
+		problems[types.KubeletCertificateExpiring] = ""
+	}
+	return problems
+}
+
+// nodeProblemAction returns the evict type choosing pods of action of node
+// condition, empty if node is only tainted
+func nodeProblemAction(condition, action string) (string, error) {
+	switch {
+	case action == "Taint":
+		return "", nil
+	case strings.HasPrefix(action, "Evict:") && nodeProblemEvictTypes[strings.TrimPrefix(action, "Evict:")]:
+		return strings.TrimPrefix(action, "Evict:"), nil
+	}
+	return "", fmt.Errorf("invalid action %q of %s, should be Taint or Evict:<type>, type is one of "+
+		"CPUBusy, MemBusy, DiskIOBusy, NetworkRxBusy, NetworkTxBusy", action, condition)
+}
+
+// GetCriticalServices returns the systemd units of CriticalServices
+func (eao *EvictionAgentOptions) GetCriticalServices() []string {
+	var units []string
+	for _, unit := range strings.Split(eao.CriticalServices, ",") {
+		if unit = strings.TrimSpace(unit); unit != "" {
+			units = append(units, unit)
+		}
+	}
+	return units
+}
+
+// GetMonitoredLinks returns the network interfaces of MonitoredLinks
+func (eao *EvictionAgentOptions) GetMonitoredLinks() []string {
+	var links []string
+	for _, link := range strings.Split(eao.MonitoredLinks, ",") {
+		if link = strings.TrimSpace(link); link != "" {
+			links = append(links, link)
+		}
+	}
+	return links
+}
+
+// GetDNSProbeNames returns the names of DNSProbeNames
+func (eao *EvictionAgentOptions) GetDNSProbeNames() []string {
+	var names []string
+	for _, name := range strings.Split(eao.DNSProbeNames, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// GetKubeletCertificates returns the pem files of KubeletCertificates
+func (eao *EvictionAgentOptions) GetKubeletCertificates() []string {
+	var paths []string
+	for _, path := range strings.Split(eao.KubeletCertificates, ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// GetUntaintProbesOrDie returns probes of UntaintProbes keyed by condition
+func (eao *EvictionAgentOptions) GetUntaintProbesOrDie() map[string]probes.Probe {
+	untaintProbes := make(map[string]probes.Probe)
+	for _, item := range strings.Split(eao.UntaintProbes, ";") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		parts := strings.SplitN(item, "=", 2)
+		var probe probes.Probe
+		err := fmt.Errorf("invalid --untaint-probes %q, should be condition=probe", item)
+		if len(parts) == 2 && strings.TrimSpace(parts[0]) != "" {
+			probe, err = probes.Parse(parts[1])
+		}
+		if err != nil {
+			log.Errorf("Invalid --untaint-probes: %v", err)
+			panic(err)
+		}
+		untaintProbes[strings.TrimSpace(parts[0])] = probe
+	}
+	return untaintProbes
+}
+
+// GetKubeletPrecedenceOrDie returns conditions of KubeletPrecedence,
+// true if kubelet takes precedence
+func (eao *EvictionAgentOptions) GetKubeletPrecedenceOrDie() map[string]bool {
+	precedence := make(map[string]bool)
+	for _, item := range strings.Split(eao.KubeletPrecedence, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		parts := strings.SplitN(item, "=", 2)
+		var err error
+		switch {
+		case len(parts) != 2:
+			err = fmt.Errorf("invalid --kubelet-precedence %q, should be condition=Kubelet or condition=Agent", item)
+		case parts[0] != config.MemoryCondition && parts[0] != config.DiskIOCondition:
+			err = fmt.Errorf("kubelet evicts for %s and %s only, not %s", config.MemoryCondition, config.DiskIOCondition, parts[0])
+		case parts[1] != "Kubelet" && parts[1] != "Agent":
+			err = fmt.Errorf("precedence of %s should be Kubelet or Agent, not %q", parts[0], parts[1])
+		}
+		if err != nil {
+			log.Errorf("Invalid --kubelet-precedence: %v", err)
+			panic(err)
+		}
+		precedence[parts[0]] = parts[1] == "Kubelet"
+	}
+	return precedence
+}
+
+// GetClusterHeadroomMinOrDie returns the min spare capacity of cluster of
+// ClusterHeadroom, which should be nodes or an http(s) url
+func (eao *EvictionAgentOptions) GetClusterHeadroomMinOrDie() types.ClusterHeadroom {
+	var min types.ClusterHeadroom
+	if eao.ClusterHeadroom == "" {
+		return min
+	}
+	var err error
+	if eao.ClusterHeadroom != ClusterHeadroomNodes && !strings.HasPrefix(eao.ClusterHeadroom, "http://") &&
+		!strings.HasPrefix(eao.ClusterHeadroom, "https://") {
+		err = fmt.Errorf("cluster headroom should be %s or an http(s) url, not %q", ClusterHeadroomNodes, eao.ClusterHeadroom)
+	}
+	for _, item := range strings.Split(eao.ClusterHeadroomMin, ",") {
+		if item = strings.TrimSpace(item); item == "" || err != nil {
+			continue
+		}
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 {
+			err = fmt.Errorf("%q should be resource=value", item)
+			break
+		}
+		value, e := config.ParseValue(parts[1])
+		switch {
+		case e != nil:
+			err = fmt.Errorf("invalid value of %s: %v", parts[0], e)
+		case parts[0] == "cpu":
+			min.CPU = value
+		case parts[0] == "memory":
+			min.Memory = value
+		case parts[0] == "pods":
+			min.Pods = int(value)
+		default:
+			err = fmt.Errorf("unknown resource %q, should be cpu, memory or pods", parts[0])
+		}
+	}
+	if err != nil {
+		log.Errorf("Invalid --cluster-headroom: %v", err)
+		panic(err)
+	}
+	return min
+}
+
+// defaultExtendedResourceThreshold is the threshold of extended resources without one
+const defaultExtendedResourceThreshold = 0.9
+
+// GetExtendedResourcesOrDie returns thresholds of ExtendedResources keyed by resource
+func (eao *EvictionAgentOptions) GetExtendedResourcesOrDie() map[string]config.Threshold {
+	resources := make(map[string]config.Threshold)
+	for _, item := range strings.Split(eao.ExtendedResources, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		parts := strings.SplitN(item, "=", 2)
+		threshold := config.Threshold{Ratio: defaultExtendedResourceThreshold}
+		var err error
+		name := strings.SplitN(parts[0], "/", 2)
+		switch {
+		case len(name) != 2 || name[0] == "" || name[1] == "":
+			err = fmt.Errorf("extended resource %q should be domain/name, e.g. intel.com/fpga", parts[0])
+		case name[0] == "kubernetes.io" || strings.HasSuffix(name[0], ".kubernetes.io"):
+			err = fmt.Errorf("%s is not an extended resource", parts[0])
+		case len(types.ExtendedResourceTaintKey(name[1])) > 63:
+			err = fmt.Errorf("taint key of %s is longer than 63 characters", parts[0])
+		case len(parts) == 2:
+			threshold, err = config.ParseThreshold(parts[1])
+		}
+		if err == nil && (threshold.Absolute < 0 || threshold.Absolute == 0 && (threshold.Ratio <= 0 || threshold.Ratio > 1)) {
+			err = fmt.Errorf("threshold of %s should be in (0, 100%%]", parts[0])
+		}
+		if err != nil {
+			log.Errorf("Invalid --extended-resources: %v", err)
+			panic(err)
+		}
+		resources[parts[0]] = threshold
+	}
+	if len(resources) != 0 && eao.ExtendedResourceUsageURL == "" {
+		err := fmt.Errorf("--extended-resource-usage-url is required by --extended-resources")
+		log.Errorf("Invalid --extended-resources: %v", err)
+		panic(err)
+	}
+	return resources
+}
+
+// GetStatsPeriod returns the shortest evaluation period, stats are synced at this period
+func (eao *EvictionAgentOptions) GetStatsPeriod() time.Duration {
+	period := eao.EvaluationPeriod
+	for _, p := range eao.GetConditionPeriodsOrDie() {
+		if p < period {
+			period = p
+		}
+	}
+	return period
+}
+
+// GetDisabledConditions returns the comma separated DisabledConditions
+func (eao *EvictionAgentOptions) GetDisabledConditions() []string {
+	var conditions []string
+	for _, condition := range strings.Split(eao.DisabledConditions, ",") {
+		if condition = strings.TrimSpace(condition); condition != "" {
+			conditions = append(conditions, condition)
+		}
+	}
+	return conditions
+}
+
 func(eao *EvictionAgentOptions) SetLogDirOrDie() {
-	eao.LogDir = os.Getenv("LOG_DIR")
+	if eao.LogDir == "" {
+		eao.LogDir = os.Getenv("LOG_DIR")
+	}
 	if eao.LogDir == "" {
 		log.Errorf("Failed to get log dir configure")
 		panic(fmt.Errorf("failed to get log dir configure"))
@@ -58,4 +1267,156 @@ func(eao *EvictionAgentOptions) SetLogDirOrDie() {
 			}
 		}
 	}
+}
+
+// ValidateModeOrDie checks Mode
+func (eao *EvictionAgentOptions) ValidateModeOrDie() {
+	if eao.Mode != ModeFull && eao.Mode != ModeTaintOnly && eao.Mode != ModeMonitorOnly {
+		err := fmt.Errorf("mode should be %s, %s or %s, not %q", ModeFull, ModeTaintOnly, ModeMonitorOnly, eao.Mode)
+		log.Errorf("Invalid mode: %v", err)
+		panic(err)
+	}
+}
+
+// ValidateHistoryStoreOrDie checks HistoryStore
+func (eao *EvictionAgentOptions) ValidateHistoryStoreOrDie() {
+	switch eao.HistoryStore {
+	case HistoryStoreMemory, HistoryStoreConfigMap:
+	case HistoryStoreFile:
+		if eao.HistoryStorePath == "" {
+			err := fmt.Errorf("history store path should not be empty of history store %s", HistoryStoreFile)
+			log.Errorf("Invalid history store: %v", err)
+			panic(err)
+		}
+	default:
+		err := fmt.Errorf("history store should be %s, %s or %s, not %q",
+			HistoryStoreMemory, HistoryStoreFile, HistoryStoreConfigMap, eao.HistoryStore)
+		log.Errorf("Invalid history store: %v", err)
+		panic(err)
+	}
+}
+
+// ValidateEvictMarkOrDie checks EvictMark and EvictMarkPrefix
+func (eao *EvictionAgentOptions) ValidateEvictMarkOrDie() {
+	var err error
+	if eao.EvictMark != EvictMarkLabel && eao.EvictMark != EvictMarkAnnotation {
+		err = fmt.Errorf("evict mark should be %s or %s, not %q", EvictMarkLabel, EvictMarkAnnotation, eao.EvictMark)
+	} else if errs := validation.IsQualifiedName(eao.EvictMarkPrefix + types.NeedEvict); len(errs) > 0 {
+		err = fmt.Errorf("evict mark prefix %q is invalid: %s", eao.EvictMarkPrefix, strings.Join(errs, "; "))
+	}
+	if err != nil {
+		log.Errorf("Invalid evict mark options: %v", err)
+		panic(err)
+	}
+}
+
+// ValidateCalibrationOrDie checks Calibrate and its bounds
+func (eao *EvictionAgentOptions) ValidateCalibrationOrDie() {
+	var err error
+	if eao.Calibrate != "" && eao.Calibrate != CalibrateSuggest && eao.Calibrate != CalibrateApply {
+		err = fmt.Errorf("calibrate should be %s or %s, not %q", CalibrateSuggest, CalibrateApply, eao.Calibrate)
+	} else if eao.CalibrationDays < 1 {
+		err = fmt.Errorf("calibration days should be at least 1, not %d", eao.CalibrationDays)
+	} else if eao.CalibrationFactor <= 0 {
+		err = fmt.Errorf("calibration factor should be positive, not %v", eao.CalibrationFactor)
+	} else if eao.CalibrationMin <= 0 || eao.CalibrationMax > 1 || eao.CalibrationMin > eao.CalibrationMax {
+		err = fmt.Errorf("calibration bounds [%v, %v] should be in (0, 1]", eao.CalibrationMin, eao.CalibrationMax)
+	}
+	if err != nil {
+		log.Errorf("Invalid calibration options: %v", err)
+		panic(err)
+	}
+}
+
+// ValidateResizeOptionsOrDie checks ResizeAction and ResizeRatio
+func (eao *EvictionAgentOptions) ValidateResizeOptionsOrDie() {
+	var err error
+	if eao.ResizeAction != "" && eao.ResizeAction != "Resize" && eao.ResizeAction != "Recommend" {
+		err = fmt.Errorf("resize action should be Resize or Recommend, not %q", eao.ResizeAction)
+	} else if eao.ResizeRatio < 1 {
+		err = fmt.Errorf("resize ratio should be at least 1, not %v", eao.ResizeRatio)
+	}
+	if err != nil {
+		log.Errorf("Invalid resize options: %v", err)
+		panic(err)
+	}
+}
+
+// ValidatePinnedPodsOrDie checks PinnedPods
+func (eao *EvictionAgentOptions) ValidatePinnedPodsOrDie() {
+	if eao.PinnedPods != "" && eao.PinnedPods != PinnedPodsExclude && eao.PinnedPods != PinnedPodsRank {
+		err := fmt.Errorf("pinned pods should be %s or %s, not %q", PinnedPodsExclude, PinnedPodsRank, eao.PinnedPods)
+		log.Errorf("Invalid pinned pods: %v", err)
+		panic(err)
+	}
+}
+
+// ValidatePlanOptionsOrDie checks PlanMaxVictims if PlanVictims is set
+func (eao *EvictionAgentOptions) ValidatePlanOptionsOrDie() {
+	if eao.PlanVictims && eao.PlanMaxVictims < 1 {
+		err := fmt.Errorf("plan max victims %d should be at least 1", eao.PlanMaxVictims)
+		log.Errorf("Invalid plan options: %v", err)
+		panic(err)
+	}
+}
+
+// GetConditionPriorityOrDie returns evict types of ConditionPriority in order
+func (eao *EvictionAgentOptions) GetConditionPriorityOrDie() []string {
+	var evictTypes []string
+	seen := make(map[string]bool)
+	for _, evictType := range strings.Split(eao.ConditionPriority, ",") {
+		if evictType = strings.TrimSpace(evictType); evictType == "" {
+			continue
+		}
+		if seen[evictType] {
+			err := fmt.Errorf("evict type %s is listed twice", evictType)
+			log.Errorf("Invalid --condition-priority: %v", err)
+			panic(err)
+		}
+		seen[evictType] = true
+		evictTypes = append(evictTypes, evictType)
+	}
+	return evictTypes
+}
+
+// ValidateConcurrentPressureOrDie checks ConcurrentPressure and its pace
+func (eao *EvictionAgentOptions) ValidateConcurrentPressureOrDie() {
+	var err error
+	if eao.ConcurrentPressure != ConcurrentPressureEach && eao.ConcurrentPressure != ConcurrentPressureMostSevere {
+		err = fmt.Errorf("concurrent pressure should be %s or %s, not %q",
+			ConcurrentPressureEach, ConcurrentPressureMostSevere, eao.ConcurrentPressure)
+	} else if eao.ConcurrentPressurePace < 0 {
+		err = fmt.Errorf("concurrent pressure pace %v should not be negative", eao.ConcurrentPressurePace)
+	}
+	if err != nil {
+		log.Errorf("Invalid concurrent pressure: %v", err)
+		panic(err)
+	}
+}
+
+// ValidateSpotPreemptionOrDie checks SpotPreemption
+func (eao *EvictionAgentOptions) ValidateSpotPreemptionOrDie() {
+	if eao.SpotPreemption == "" {
+		return
+	}
+	for _, provider := range preemption.Providers() {
+		if eao.SpotPreemption == provider {
+			return
+		}
+	}
+	err := fmt.Errorf("unknown provider %q, should be one of %v", eao.SpotPreemption, preemption.Providers())
+	log.Errorf("Invalid --spot-preemption: %v", err)
+	panic(err)
+}
+
+// ValidateLogOptionsOrDie checks LogLevel and LogFormat
+func (eao *EvictionAgentOptions) ValidateLogOptionsOrDie() {
+	err := log.ValidateLevel(eao.LogLevel)
+	if err == nil {
+		err = log.ValidateFormat(eao.LogFormat)
+	}
+	if err != nil {
+		log.Errorf("Invalid log options: %v", err)
+		panic(err)
+	}
 }
\ No newline at end of file
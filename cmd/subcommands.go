@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"eviction-agent/cmd/options"
+	"eviction-agent/pkg/condition"
+	"eviction-agent/pkg/config"
+	"eviction-agent/pkg/evictionclient"
+	"eviction-agent/pkg/evictionmanager"
+)
+
+// subcommands run instead of the agent when the first argument matches
+var subcommands = map[string]func(args []string) int{
+	"validate-config":      validateConfig,
+	"print-default-config": printDefaultConfig,
+	"replay":               replay,
+	"diagnose":             diagnose,
+}
+
+// maxDiagnosedCandidates is the max number of candidates printed of each condition
+const maxDiagnosedCandidates = 5
+
+// validateConfig parses and validates the policy configuration files with
+// the default profile of --profile, returns 1 if any of them is invalid
+func validateConfig(args []string) int {
+	fs := flag.NewFlagSet("validate-config", flag.ContinueOnError)
+	profile := fs.String("profile", "",
+		"Built-in policy profile used by files which set no profile, one of conservative, balanced and aggressive.")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s validate-config [--profile=PROFILE] FILE...\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	if err := config.ValidateProfile(*profile); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid --profile: %v\n", err)
+		return 2
+	}
+	code := 0
+	for _, file := range fs.Args() {
+		policy, err := config.LoadFile(file, *profile)
+		if err == nil {
+			err = policy.Validate()
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: invalid: %v\n", file, err)
+			code = 1
+			continue
+		}
+		for _, warning := range policy.Warnings() {
+			fmt.Fprintf(os.Stderr, "%s: warning: %s\n", file, warning)
+		}
+		fmt.Printf("%s: ok\n", file)
+	}
+	return code
+}
+
+// printDefaultConfig prints the commented default policy configuration
+func printDefaultConfig(args []string) int {
+	fmt.Print(config.DefaultConfig())
+	return 0
+}
+
+// replay replays stats recorded by --record with a policy configuration, and
+// prints taints and evictions which would have occurred
+func replay(args []string) int {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	policyFile := fs.String("policy-config-file", "", "Policy configuration to replay, required.")
+	profile := fs.String("profile", "", "Built-in policy profile used if the policy configuration sets no profile.")
+	jsonOutput := fs.Bool("json", false, "Print events as json lines.")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s replay --policy-config-file=FILE RECORD_FILE\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *policyFile == "" || fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	policy, err := config.LoadFile(*policyFile, *profile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: invalid: %v\n", *policyFile, err)
+		return 1
+	}
+	records, err := condition.LoadRecords(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", fs.Arg(0), err)
+		return 1
+	}
+	counts := make(map[string]int)
+	err = evictionmanager.Replay(policy, records, func(event evictionmanager.ReplayEvent) {
+		counts[event.Action]++
+		if *jsonOutput {
+			data, _ := json.Marshal(event)
+			fmt.Println(string(data))
+			return
+		}
+		target := event.Taint
+		if event.Pod != "" {
+			target = event.Pod + " for " + event.EvictType
+		}
+		fmt.Printf("%s %s %s: %s\n", event.Time.Format(time.RFC3339), event.Action, target, event.Message)
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "replay error: %v\n", err)
+		return 1
+	}
+	if !*jsonOutput {
+		fmt.Printf("%d records, %d taints, %d untaints, %d evictions, %d labels\n", len(records),
+			counts[evictionmanager.ReplayTaint], counts[evictionmanager.ReplayUntaint],
+			counts[evictionmanager.ReplayEvict], counts[evictionmanager.ReplayLabel])
+	}
+	return 0
+}
+
+// diagnose collects stats of node once with the flags of agent, and prints
+// conditions compared with thresholds and the ranked candidates of each of them
+func diagnose(args []string) int {
+	fs := flag.NewFlagSet("diagnose", flag.ContinueOnError)
+	eao := options.NewEvictionAgentOptions()
+	eao.AddFlags(fs)
+	interval := fs.Duration("interval", 0, "Interval between the two samples of io rates, the stats period if zero.")
+	jsonOutput := fs.Bool("json", false, "Print the diagnosis as json.")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	eao.SetKubeconfigFile()
+	eao.SetNodeNameOrDie()
+	eao.SetPodIdentity()
+	eao.SetPolicyConfigFileOrDie()
+	if *interval <= 0 {
+		*interval = eao.GetStatsPeriod()
+	}
+
+	c := evictionclient.NewClientOrDie(eao)
+	ctx, cancel := context.WithTimeout(context.Background(), *interval+time.Minute)
+	defer cancel()
+	d, err := condition.NewConditionManager(c, eao).Diagnose(ctx, *interval)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "diagnose error: %v\n", err)
+		return 1
+	}
+	if *jsonOutput {
+		data, _ := json.MarshalIndent(d, "", "  ")
+		fmt.Println(string(data))
+		return 0
+	}
+
+	fmt.Printf("Node %s at %s\n\n", eao.NodeName, d.Time.Format(time.RFC3339))
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "CONDITION\tVALUE\tTHRESHOLD\tSTATUS")
+	for _, cd := range d.Conditions {
+		status := "Available"
+		switch {
+		case cd.Disabled:
+			status = "Disabled"
+		case !cd.Available:
+			status = "Busy"
+		}
+		fmt.Fprintf(w, "%s\t%.4g\t%.4g\t%s\n", cd.EvictType, cd.Value, cd.Threshold, status)
+	}
+	w.Flush()
+	for name, share := range d.Daemons {
+		fmt.Printf("system daemons use %.4g of %s, %.4g reserved\n", share.Usage, name, share.Reserved)
+	}
+
+	for _, cd := range d.Conditions {
+		fmt.Printf("\nCandidates of %s:\n", cd.EvictType)
+		if len(cd.Candidates) == 0 {
+			fmt.Println("  none")
+		} else {
+			w = tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "  POD\tPRIORITY\tUSAGE\tSCORE\tLABEL")
+			for i, candidate := range cd.Candidates {
+				if i == maxDiagnosedCandidates {
+					fmt.Fprintf(w, "  ... %d more\t\t\t\t\n", len(cd.Candidates)-i)
+					break
+				}
+				fmt.Fprintf(w, "  %s/%s\t%d\t%.4g\t%.4g\t%s\n", candidate.Pod.Namespace, candidate.Pod.Name,
+					candidate.Pod.Priority, candidate.Usage, candidate.Score, candidate.Label)
+			}
+			w.Flush()
+		}
+		counts := make(map[string]int)
+		for _, exclusion := range cd.Excluded {
+			counts[exclusion.Reason]++
+		}
+		var reasons []string
+		for reason := range counts {
+			reasons = append(reasons, reason)
+		}
+		sort.Strings(reasons)
+		for i, reason := range reasons {
+			reasons[i] = fmt.Sprintf("%d %s", counts[reason], reason)
+		}
+		if len(reasons) != 0 {
+			fmt.Printf("  excluded: %s\n", strings.Join(reasons, ", "))
+		}
+	}
+	return 0
+}
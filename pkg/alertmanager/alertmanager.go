@@ -0,0 +1,179 @@
+package alertmanager
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/metrics"
+)
+
+// queueLen is the max number of updates waiting to be sent
+const queueLen = 100
+
+var (
+	alertFailures = metrics.NewCounterVec("eviction_agent_alertmanager_failures_total",
+		"Number of alerts failed to send to Alertmanager or dropped.", "alertname")
+)
+
+// Alert is an alert of api v2 of Alertmanager. It's firing while EndsAt is
+// nil, and resolved once EndsAt is sent.
+type Alert struct {
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	StartsAt    time.Time         `json:"startsAt"`
+	EndsAt      *time.Time        `json:"endsAt,omitempty"`
+}
+
+// Client fires and resolves alerts keyed by condition. Firing alerts are
+// sent again every resend, so that Alertmanager doesn't resolve them by its
+// resolve_timeout while they last.
+type Client struct {
+	url    string
+	resend time.Duration
+	client *http.Client
+	queue  chan []Alert
+	lock   sync.Mutex
+	firing map[string]*Alert
+}
+
+// NewClient creates a client of Alertmanager at url, e.g. http://alertmanager:9093
+func NewClient(url string, timeout, resend time.Duration) *Client {
+	return &Client{
+		url:    strings.TrimSuffix(url, "/") + "/api/v2/alerts",
+		resend: resend,
+		client: &http.Client{Timeout: timeout},
+		queue:  make(chan []Alert, queueLen),
+		firing: make(map[string]*Alert),
+	}
+}
+
+// AlertName returns the alert name of taint key, e.g. NodeDiskIOPressure of
+// DiskIOBusy, taint keys of node problems are alert names themselves
+func AlertName(taintKey string) string {
+	if !strings.HasSuffix(taintKey, "Busy") {
+		return taintKey
+	}
+	resource := strings.TrimSuffix(taintKey, "Busy")
+	if resource == "Mem" {
+		resource = "Memory"
+	}
+	return "Node" + resource + "Pressure"
+}
+
+// Fire fires the alert of key, an alert already firing is replaced if labels
+// are changed. It's a no-op on nil client.
+func (c *Client) Fire(key string, labels, annotations map[string]string) {
+	if c == nil {
+		return
+	}
+	now := time.Now()
+	c.lock.Lock()
+	var alerts []Alert
+	if last, ok := c.firing[key]; ok {
+		if equalLabels(last.Labels, labels) {
+			c.lock.Unlock()
+			return
+		}
+		// labels identify alerts, the last one is resolved
+		last.EndsAt = &now
+		alerts = append(alerts, *last)
+	}
+	alert := &Alert{Labels: labels, Annotations: annotations, StartsAt: now}
+	c.firing[key] = alert
+	alerts = append(alerts, *alert)
+	c.lock.Unlock()
+	c.send(alerts)
+}
+
+// Resolve resolves the alert of key if it's firing. It's a no-op on nil client.
+func (c *Client) Resolve(key string) {
+	if c == nil {
+		return
+	}
+	c.lock.Lock()
+	alert, ok := c.firing[key]
+	delete(c.firing, key)
+	c.lock.Unlock()
+	if !ok {
+		return
+	}
+	now := time.Now()
+	alert.EndsAt = &now
+	c.send([]Alert{*alert})
+}
+
+// send queues alerts, they are dropped if the queue is full
+func (c *Client) send(alerts []Alert) {
+	select {
+	case c.queue <- alerts:
+	default:
+		for _, alert := range alerts {
+			alertFailures.Inc(alert.Labels["alertname"])
+		}
+		log.Errorf("alertmanager queue is full, drop %d alerts", len(alerts))
+	}
+}
+
+// Run sends queued alerts, and firing alerts again every resend until ctx is done
+func (c *Client) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.resend)
+	defer ticker.Stop()
+	for {
+		var alerts []Alert
+		select {
+		case <-ctx.Done():
+			return
+		case alerts = <-c.queue:
+		case <-ticker.C:
+			c.lock.Lock()
+			for _, alert := range c.firing {
+				alerts = append(alerts, *alert)
+			}
+			c.lock.Unlock()
+		}
+		if len(alerts) == 0 {
+			continue
+		}
+		if err := c.post(alerts); err != nil {
+			for _, alert := range alerts {
+				alertFailures.Inc(alert.Labels["alertname"])
+			}
+			log.Errorf("send %d alerts to alertmanager error: %v", len(alerts), err)
+		}
+	}
+}
+
+func (c *Client) post(alerts []Alert) error {
+	body, err := json.Marshal(alerts)
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.Post(c.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("alertmanager responds %v", resp.Status)
+	}
+	return nil
+}
+
+func equalLabels(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, value := range a {
+		if v, ok := b[key]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
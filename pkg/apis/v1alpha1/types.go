@@ -0,0 +1,155 @@
+package v1alpha1
+
+import (
+	"encoding/json"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"eviction-agent/pkg/config"
+)
+
+const (
+	// GroupName is the api group of eviction agent custom resources
+	GroupName = "eviction-agent.io"
+	// Version is the api version of eviction agent custom resources
+	Version = "v1alpha1"
+	// EvictionPolicyPlural is the resource name of EvictionPolicy
+	EvictionPolicyPlural = "evictionpolicies"
+)
+
+// EvictionPolicy is a cluster scoped eviction policy, which can be used
+// instead of the policy configuration file.
+type EvictionPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec EvictionPolicySpec `json:"spec"`
+}
+
+// EvictionPolicySpec has the same fields as the policy configuration file,
+// with a node selector specifying which nodes the policy applies to.
+type EvictionPolicySpec struct {
+	// NodeSelector selects nodes by labels, empty selector selects all nodes.
+	// The most specific policy is used if several policies select the node.
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	config.PolicyConfig `json:",inline"`
+
+	// raw is the decoded spec, the default profile is applied to it by Policy
+	raw json.RawMessage
+}
+
+// UnmarshalJSON decodes the spec and applies the profile of policy
+func (s *EvictionPolicySpec) UnmarshalJSON(data []byte) error {
+	type spec EvictionPolicySpec
+	var decoded spec
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	if err := config.ApplyProfile(&decoded.PolicyConfig, data, ""); err != nil {
+		return err
+	}
+	*s = EvictionPolicySpec(decoded)
+	s.raw = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+// Policy returns the policy of spec, defaultProfile is applied if the spec
+// sets no profile
+func (s *EvictionPolicySpec) Policy(defaultProfile string) (*config.PolicyConfig, error) {
+	policy := s.PolicyConfig
+	if policy.Profile != "" || defaultProfile == "" || s.raw == nil {
+		return &policy, nil
+	}
+	if err := config.ApplyProfile(&policy, s.raw, defaultProfile); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// EvictionPolicyList is a list of EvictionPolicy
+type EvictionPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []EvictionPolicy `json:"items"`
+}
+
+const (
+	// NodeEvictionStatusPlural is the resource name of NodeEvictionStatus
+	NodeEvictionStatusPlural = "nodeevictionstatuses"
+)
+
+// NodeEvictionStatus is a namespaced resource named after the node,
+// which reports the state of eviction agent running on that node.
+type NodeEvictionStatus struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Status AgentStatus `json:"status"`
+}
+
+// AgentStatus is the state of eviction agent
+type AgentStatus struct {
+	NodeName   string      `json:"nodeName"`
+	UpdateTime metav1.Time `json:"updateTime"`
+	// Conditions are the latest node conditions
+	Conditions []ConditionStatus `json:"conditions"`
+	// Taints are the active taints added by agent
+	Taints []string `json:"taints"`
+	// LastEvictions are the latest eviction or label actions
+	LastEvictions []EvictionRecord `json:"lastEvictions"`
+	// LastErrors are the latest errors of agent
+	LastErrors []ErrorRecord `json:"lastErrors"`
+}
+
+// ConditionStatus is the state of one node condition
+type ConditionStatus struct {
+	Type      string `json:"type"`
+	Available bool   `json:"available"`
+	Message   string `json:"message"`
+	// Phase is the phase of the condition in taint process, e.g. Healthy or Evicting
+	Phase string `json:"phase,omitempty"`
+	// Transitions are the latest phase transitions of the condition, the oldest first
+	Transitions []TransitionRecord `json:"transitions,omitempty"`
+}
+
+// TransitionRecord is a phase transition of a condition
+type TransitionRecord struct {
+	From string      `json:"from"`
+	To   string      `json:"to"`
+	Time metav1.Time `json:"time"`
+	// Duration is how long the condition was in phase from, e.g. 5m0s
+	Duration string `json:"duration"`
+	// Peak is the peak of measured values by ratio of threshold in phase from
+	Peak float64 `json:"peak,omitempty"`
+}
+
+// EvictionRecord is an eviction or label action on pod
+type EvictionRecord struct {
+	Time      metav1.Time `json:"time"`
+	Condition string      `json:"condition"`
+	Pod       string      `json:"pod"`
+	Action    string      `json:"action"`
+	Error     string      `json:"error,omitempty"`
+	// Snapshot is the usage of pods the pod is chosen from, the best scored
+	// candidates first and then the excluded pods
+	Snapshot []PodUsage `json:"snapshot,omitempty"`
+}
+
+// PodUsage is the usage of a pod used for a decision
+type PodUsage struct {
+	Pod   string  `json:"pod"`
+	Usage float64 `json:"usage,omitempty"`
+	Score float64 `json:"score,omitempty"`
+	// Label is NeedsEviction or EvictionCandidate of candidates
+	Label string `json:"label,omitempty"`
+	// Excluded is why pod is not a candidate, empty for candidates
+	Excluded string `json:"excluded,omitempty"`
+}
+
+// ErrorRecord is an error of agent
+type ErrorRecord struct {
+	Time    metav1.Time `json:"time"`
+	Message string      `json:"message"`
+}
@@ -0,0 +1,133 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"eviction-agent/pkg/apis/v1alpha1"
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/metrics"
+)
+
+var (
+	auditFailures = metrics.NewCounterVec("eviction_agent_audit_failures_total",
+		"Number of audit records failed to write.", "action")
+)
+
+// Record is one line of audit log
+type Record struct {
+	Time         time.Time          `json:"time"`
+	Node         string             `json:"node"`
+	Action       string             `json:"action"`
+	Condition    string             `json:"condition"`
+	Measurements map[string]float64 `json:"measurements"`
+	Pod          string             `json:"pod"`
+	PodUID       string             `json:"podUID,omitempty"`
+	Container    string             `json:"container,omitempty"`
+	Priority     int                `json:"priority,omitempty"`
+	Owner        string             `json:"owner,omitempty"`
+	Outcome      string             `json:"outcome"`
+	Error        string             `json:"error,omitempty"`
+	// Snapshot is the usage of all pods the pod is chosen from, so that a
+	// decision can be reviewed after the pods are gone
+	Snapshot []v1alpha1.PodUsage `json:"snapshot,omitempty"`
+}
+
+// Logger appends json encoded records to file, the file is rotated to
+// file.1 ... file.<maxBackups> when it grows larger than maxSize bytes
+type Logger struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+	lock       sync.Mutex
+	file       *os.File
+	size       int64
+}
+
+// NewLogger opens or creates the audit log file
+func NewLogger(path string, maxSize int64, maxBackups int) (*Logger, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return nil, err
+	}
+	l := &Logger{
+		path:       path,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+	}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *Logger) open() error {
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	l.file = file
+	l.size = info.Size()
+	return nil
+}
+
+// Write appends the record and syncs it to disk, errors are only logged.
+// It's a no-op on nil logger.
+func (l *Logger) Write(record *Record) {
+	if l == nil {
+		return
+	}
+	if err := l.write(record); err != nil {
+		auditFailures.Inc(record.Action)
+		log.Errorf("write audit record %s %s error: %v", record.Action, record.Pod, err)
+	}
+}
+
+func (l *Logger) write(record *Record) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.size > 0 && l.size+int64(len(line)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			return fmt.Errorf("rotate audit log: %v", err)
+		}
+	}
+	n, err := l.file.Write(line)
+	l.size += int64(n)
+	if err != nil {
+		return err
+	}
+	return l.file.Sync()
+}
+
+// rotate shifts file.N-1 to file.N, drops the oldest backup and reopens file
+func (l *Logger) rotate() error {
+	if err := l.file.Close(); err != nil {
+		log.Warnf("close audit log %s error: %v", l.path, err)
+	}
+	if l.maxBackups > 0 {
+		os.Remove(fmt.Sprintf("%s.%d", l.path, l.maxBackups))
+		for i := l.maxBackups - 1; i > 0; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", l.path, i), fmt.Sprintf("%s.%d", l.path, i+1))
+		}
+		if err := os.Rename(l.path, l.path+".1"); err != nil {
+			return err
+		}
+	} else if err := os.Remove(l.path); err != nil {
+		return err
+	}
+	return l.open()
+}
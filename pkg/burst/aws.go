@@ -0,0 +1,243 @@
+package burst
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"eviction-agent/pkg/config"
+)
+
+const (
+	awsMetadataURL = "http://169.254.169.254/latest"
+	// cloudwatch metrics of ebs and ec2 are of 5 minutes by default
+	awsMetricPeriod = 5 * time.Minute
+)
+
+// awsCreditsPerHour are cpu credits earned per hour of burstable instances,
+// a t3 instance accrues at most 24 hours of credits. t3a and t4g are the same as t3.
+var awsCreditsPerHour = map[string]float64{
+	"t2.nano": 3, "t2.micro": 6, "t2.small": 12, "t2.medium": 24,
+	"t2.large": 36, "t2.xlarge": 54, "t2.2xlarge": 81.6,
+	"t3.nano": 6, "t3.micro": 12, "t3.small": 24, "t3.medium": 24,
+	"t3.large": 36, "t3.xlarge": 96, "t3.2xlarge": 192,
+}
+
+// awsProbe gets credits of the instance agent runs on, the instance role
+// needs ec2:DescribeVolumes and cloudwatch:GetMetricStatistics. DiskIo is
+// the lowest burst balance of gp2 volumes attached, gp3 volumes have no burst
+// credits. CPU is the cpu credit balance of burstable instances.
+type awsProbe struct {
+	client *http.Client
+}
+
+type awsCredentials struct {
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string `json:"SecretAccessKey"`
+	Token           string `json:"Token"`
+}
+
+func (p *awsProbe) Name() string {
+	return AWSProvider
+}
+
+func (p *awsProbe) Credits(ctx context.Context) (Credits, error) {
+	token, err := p.metadataToken(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get metadata token: %v", err)
+	}
+	var instanceID, instanceType, region, role string
+	for path, value := range map[string]*string{
+		"/meta-data/instance-id":               &instanceID,
+		"/meta-data/instance-type":             &instanceType,
+		"/meta-data/placement/region":          &region,
+		"/meta-data/iam/security-credentials/": &role,
+	} {
+		if *value, err = p.metadata(ctx, token, path); err != nil {
+			return nil, err
+		}
+	}
+	var creds awsCredentials
+	// the first role if there are several
+	role = strings.SplitN(role, "\n", 2)[0]
+	data, err := p.metadata(ctx, token, "/meta-data/iam/security-credentials/"+role)
+	if err == nil {
+		err = json.Unmarshal([]byte(data), &creds)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get credentials of instance role: %v", err)
+	}
+
+	credits := Credits{}
+	balance, err := p.ebsBurstBalance(ctx, &creds, region, instanceID)
+	if err != nil {
+		return nil, err
+	}
+	if balance >= 0 {
+		credits[config.DiskIOCondition] = balance
+	}
+	family := strings.Replace(strings.Replace(instanceType, "t3a.", "t3.", 1), "t4g.", "t3.", 1)
+	if perHour, ok := awsCreditsPerHour[family]; ok {
+		value, err := p.metric(ctx, &creds, region, "AWS/EC2", "CPUCreditBalance", "InstanceId", instanceID)
+		if err != nil {
+			return nil, err
+		}
+		if value >= 0 {
+			credits[config.CPUCondition] = value / (24 * perHour)
+		}
+	}
+	return credits, nil
+}
+
+// ebsBurstBalance returns the lowest burst balance of gp2 volumes of
+// instance, -1 if there are none
+func (p *awsProbe) ebsBurstBalance(ctx context.Context, creds *awsCredentials, region, instanceID string) (float64, error) {
+	body, err := p.query(ctx, creds, region, "ec2", url.Values{
+		"Action":           {"DescribeVolumes"},
+		"Version":          {"2016-11-15"},
+		"Filter.1.Name":    {"attachment.instance-id"},
+		"Filter.1.Value.1": {instanceID},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("describe volumes: %v", err)
+	}
+	var volumes struct {
+		Items []struct {
+			ID   string `xml:"volumeId"`
+			Type string `xml:"volumeType"`
+		} `xml:"volumeSet>item"`
+	}
+	if err := xml.Unmarshal(body, &volumes); err != nil {
+		return 0, err
+	}
+	lowest := -1.0
+	for _, volume := range volumes.Items {
+		if volume.Type != "gp2" {
+			continue
+		}
+		value, err := p.metric(ctx, creds, region, "AWS/EBS", "BurstBalance", "VolumeId", volume.ID)
+		if err != nil {
+			return 0, err
+		}
+		// burst balance is a percentage
+		if value >= 0 && (lowest < 0 || value/100 < lowest) {
+			lowest = value / 100
+		}
+	}
+	return lowest, nil
+}
+
+// metric returns the latest minimum of metric, -1 if there are no datapoints
+func (p *awsProbe) metric(ctx context.Context, creds *awsCredentials, region, namespace, name, dimension, value string) (float64, error) {
+	now := time.Now().UTC()
+	body, err := p.query(ctx, creds, region, "monitoring", url.Values{
+		"Action":                    {"GetMetricStatistics"},
+		"Version":                   {"2010-08-01"},
+		"Namespace":                 {namespace},
+		"MetricName":                {name},
+		"Dimensions.member.1.Name":  {dimension},
+		"Dimensions.member.1.Value": {value},
+		"StartTime":                 {now.Add(-3 * awsMetricPeriod).Format(time.RFC3339)},
+		"EndTime":                   {now.Format(time.RFC3339)},
+		"Period":                    {fmt.Sprint(int(awsMetricPeriod.Seconds()))},
+		"Statistics.member.1":       {"Minimum"},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("get %s of %s: %v", name, value, err)
+	}
+	var result struct {
+		Datapoints []struct {
+			Timestamp time.Time `xml:"Timestamp"`
+			Minimum   float64   `xml:"Minimum"`
+		} `xml:"GetMetricStatisticsResult>Datapoints>member"`
+	}
+	if err := xml.Unmarshal(body, &result); err != nil {
+		return 0, err
+	}
+	latest, minimum := time.Time{}, -1.0
+	for _, point := range result.Datapoints {
+		if point.Timestamp.After(latest) {
+			latest, minimum = point.Timestamp, point.Minimum
+		}
+	}
+	return minimum, nil
+}
+
+// query sends a signed request of aws query api
+func (p *awsProbe) query(ctx context.Context, creds *awsCredentials, region, service string, params url.Values) ([]byte, error) {
+	host := fmt.Sprintf("%s.%s.amazonaws.com", service, region)
+	// sigv4 escapes spaces as %20
+	query := strings.Replace(params.Encode(), "+", "%20", -1)
+	req, err := http.NewRequest(http.MethodGet, "https://"+host+"/?"+query, nil)
+	if err != nil {
+		return nil, err
+	}
+	signV4(req, creds, region, service, query, time.Now().UTC())
+	return do(p.client, req.WithContext(ctx))
+}
+
+// signV4 signs a GET request without body by aws signature version 4
+func signV4(req *http.Request, creds *awsCredentials, region, service, query string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	headers := "host:" + req.URL.Host + "\nx-amz-date:" + amzDate + "\n"
+	signedHeaders := "host;x-amz-date"
+	if creds.Token != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.Token)
+		headers += "x-amz-security-token:" + creds.Token + "\n"
+		signedHeaders += ";x-amz-security-token"
+	}
+	emptyHash := sha256.Sum256(nil)
+	canonical := strings.Join([]string{"GET", "/", query, headers, signedHeaders,
+		hex.EncodeToString(emptyHash[:])}, "\n")
+	canonicalHash := sha256.Sum256([]byte(canonical))
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalHash[:])
+
+	key := []byte("AWS4" + creds.SecretAccessKey)
+	for _, part := range []string{date, region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// metadataToken gets a token of instance metadata service v2
+func (p *awsProbe) metadataToken(ctx context.Context) (string, error) {
+	req, err := http.NewRequest(http.MethodPut, awsMetadataURL+"/api/token", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "300")
+	body, err := do(p.client, req.WithContext(ctx))
+	return string(body), err
+}
+
+func (p *awsProbe) metadata(ctx context.Context, token, path string) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, awsMetadataURL+path, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token", token)
+	body, err := do(p.client, req.WithContext(ctx))
+	if err != nil {
+		return "", fmt.Errorf("get instance metadata %s: %v", path, err)
+	}
+	return strings.TrimSpace(string(body)), nil
+}
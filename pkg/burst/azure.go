@@ -0,0 +1,105 @@
+package burst
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"eviction-agent/pkg/config"
+)
+
+const (
+	azureMetadataURL   = "http://169.254.169.254/metadata"
+	azureManagementURL = "https://management.azure.com"
+)
+
+// azureDiskMetrics are the percentages of burst io credits used of disks of vm
+var azureDiskMetrics = []string{
+	"OS Disk Used Burst IO Credits Percentage",
+	"Data Disk Used Burst IO Credits Percentage",
+}
+
+// azureProbe gets disk burst credits of the vm agent runs on by its managed
+// identity, which needs Monitoring Reader of the vm. DiskIo is the lowest
+// burst credits left of os and data disks.
+type azureProbe struct {
+	client *http.Client
+}
+
+func (p *azureProbe) Name() string {
+	return AzureProvider
+}
+
+func (p *azureProbe) Credits(ctx context.Context) (Credits, error) {
+	var instance struct {
+		Compute struct {
+			ResourceID string `json:"resourceId"`
+		} `json:"compute"`
+	}
+	if err := p.metadata(ctx, "/instance?api-version=2021-02-01", &instance); err != nil {
+		return nil, fmt.Errorf("get instance metadata: %v", err)
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	resource := url.QueryEscape(azureManagementURL + "/")
+	if err := p.metadata(ctx, "/identity/oauth2/token?api-version=2018-02-01&resource="+resource, &token); err != nil {
+		return nil, fmt.Errorf("get token of managed identity: %v", err)
+	}
+
+	query := url.Values{
+		"api-version": {"2018-01-01"},
+		"metricnames": {azureDiskMetrics[0] + "," + azureDiskMetrics[1]},
+		"timespan":    {"PT15M"},
+		"interval":    {"PT1M"},
+		"aggregation": {"Maximum"},
+	}
+	req, err := http.NewRequest(http.MethodGet, azureManagementURL+instance.Compute.ResourceID+
+		"/providers/microsoft.insights/metrics?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	var metrics struct {
+		Value []struct {
+			Timeseries []struct {
+				Data []struct {
+					Maximum *float64 `json:"maximum"`
+				} `json:"data"`
+			} `json:"timeseries"`
+		} `json:"value"`
+	}
+	if err := getJSON(p.client, req.WithContext(ctx), &metrics); err != nil {
+		return nil, fmt.Errorf("get disk metrics: %v", err)
+	}
+
+	// the latest of each metric, the highest used is the lowest left
+	used := -1.0
+	for _, metric := range metrics.Value {
+		for _, series := range metric.Timeseries {
+			for i := len(series.Data) - 1; i >= 0; i-- {
+				if series.Data[i].Maximum != nil {
+					if *series.Data[i].Maximum > used {
+						used = *series.Data[i].Maximum
+					}
+					break
+				}
+			}
+		}
+	}
+	credits := Credits{}
+	if used >= 0 {
+		credits[config.DiskIOCondition] = 1 - used/100
+	}
+	return credits, nil
+}
+
+func (p *azureProbe) metadata(ctx context.Context, path string, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, azureMetadataURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Metadata", "true")
+	return getJSON(p.client, req.WithContext(ctx), v)
+}
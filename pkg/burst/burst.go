@@ -0,0 +1,193 @@
+package burst
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"eviction-agent/pkg/config"
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/metrics"
+)
+
+// providers of burst credits
+const (
+	// AWSProvider gets EBS gp2 burst balance and EC2 cpu credits from CloudWatch
+	AWSProvider = "aws"
+	// AzureProvider gets disk burst io credits from Azure Monitor
+	AzureProvider = "azure"
+)
+
+var (
+	burstCredits = metrics.NewGaugeVec("eviction_agent_burst_credits",
+		"Fraction of burst credits left of each condition, from 0 to 1.", "condition")
+	thresholdFactor = metrics.NewGaugeVec("eviction_agent_burst_threshold_factor",
+		"Factor of taint threshold of each condition tightened by burst credits.", "condition")
+	probeErrors = metrics.NewCounterVec("eviction_agent_burst_probe_errors_total",
+		"Number of burst credits probes failed.", "provider")
+)
+
+// Credits are the fractions of burst credits left, from 0 to 1, keyed by
+// condition, e.g. config.DiskIOCondition. Conditions without burst credits
+// are not in it.
+type Credits map[string]float64
+
+// Probe gets burst credits of node from cloud provider
+type Probe interface {
+	// Name is the provider of probe
+	Name() string
+	// Credits returns burst credits of node
+	Credits(ctx context.Context) (Credits, error)
+}
+
+// NewProbe creates probe of provider, it's aws, azure, or an http url
+// returning credits as json, e.g. {"CPU": 0.8, "DiskIo": 0.3}
+func NewProbe(provider string, timeout time.Duration) (Probe, error) {
+	client := &http.Client{Timeout: timeout}
+	switch {
+	case provider == AWSProvider:
+		return &awsProbe{client: client}, nil
+	case provider == AzureProvider:
+		return &azureProbe{client: client}, nil
+	case strings.HasPrefix(provider, "http://") || strings.HasPrefix(provider, "https://"):
+		return &httpProbe{url: provider, client: client}, nil
+	}
+	return nil, fmt.Errorf("unknown burst credits provider %q, should be aws, azure or an http url", provider)
+}
+
+// Monitor probes burst credits periodically, and tightens taint thresholds
+// as credits run out. Thresholds are not changed if credits are stale.
+type Monitor struct {
+	probe  Probe
+	period time.Duration
+	// low is the fraction of credits below which thresholds are tightened
+	low float64
+	// minFactor is the factor of thresholds when credits are used up
+	minFactor float64
+
+	lock    sync.RWMutex
+	credits Credits
+	updated time.Time
+}
+
+// NewMonitor creates monitor probing every period. Thresholds are scaled
+// linearly from 1 at low credits to minFactor at no credits.
+func NewMonitor(probe Probe, period time.Duration, low, minFactor float64) *Monitor {
+	return &Monitor{
+		probe:     probe,
+		period:    period,
+		low:       low,
+		minFactor: minFactor,
+	}
+}
+
+// Run probes credits until ctx is done
+func (m *Monitor) Run(ctx context.Context) {
+	for {
+		m.update(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(m.period):
+		}
+	}
+}
+
+func (m *Monitor) update(ctx context.Context) {
+	credits, err := m.probe.Credits(ctx)
+	if err != nil {
+		probeErrors.Inc(m.probe.Name())
+		log.Errorf("probe burst credits of %s error: %v", m.probe.Name(), err)
+		return
+	}
+	for condition, value := range credits {
+		burstCredits.Set(value, condition)
+		thresholdFactor.Set(m.factor(value), condition)
+		log.Debugw("burst credits", "condition", condition, "credits", value)
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.credits = credits
+	m.updated = time.Now()
+}
+
+// Factor returns the factor of taint threshold of condition, 1 if the
+// condition has no burst credits or credits are not probed for 3 periods
+func (m *Monitor) Factor(condition string) float64 {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	value, ok := m.credits[condition]
+	if !ok || time.Since(m.updated) > 3*m.period {
+		return 1
+	}
+	return m.factor(value)
+}
+
+func (m *Monitor) factor(credits float64) float64 {
+	if credits >= m.low || m.low <= 0 {
+		return 1
+	}
+	if credits < 0 {
+		credits = 0
+	}
+	return m.minFactor + (1-m.minFactor)*credits/m.low
+}
+
+// httpProbe gets credits from url, e.g. an exporter of cloud metrics
+type httpProbe struct {
+	url    string
+	client *http.Client
+}
+
+func (p *httpProbe) Name() string {
+	return "http"
+}
+
+func (p *httpProbe) Credits(ctx context.Context) (Credits, error) {
+	req, err := http.NewRequest(http.MethodGet, p.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	var credits Credits
+	if err := getJSON(p.client, req.WithContext(ctx), &credits); err != nil {
+		return nil, err
+	}
+	for condition := range credits {
+		if condition != config.CPUCondition && condition != config.DiskIOCondition {
+			return nil, fmt.Errorf("unexpected condition %q, should be CPU or DiskIo", condition)
+		}
+	}
+	return credits, nil
+}
+
+// getJSON sends req and decodes json response into v
+func getJSON(client *http.Client, req *http.Request, v interface{}) error {
+	body, err := do(client, req)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+// do sends req and returns response body, non 2xx responses are errors
+func do(client *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("%s %s: unexpected status %s: %s", req.Method, req.URL.Path, resp.Status,
+			strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
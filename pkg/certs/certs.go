@@ -0,0 +1,105 @@
+package certs
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/metrics"
+)
+
+// rotationDeadline is the ratio of lifetime of a certificate after which
+// kubelet has rotated it, kubelet rotates at a jittered 70%-90% of lifetime
+const rotationDeadline = 0.9
+
+var (
+	certificateExpiry = metrics.NewGaugeVec("eviction_agent_certificate_expiry_seconds",
+		"Seconds until the certificate expires, negative if it's expired.", "path")
+	checkErrors = metrics.NewCounterVec("eviction_agent_certificate_check_errors_total",
+		"Number of checks of certificates failed to read or parse them.", "path")
+)
+
+// Monitor checks certificates of kubelet periodically, e.g. its client and
+// serving certificates. A certificate is expiring if it expires in threshold
+// and it's past the rotation deadline, i.e. rotation of kubelet appears stuck.
+// Certificates renewed in time are never expiring however short they live.
+type Monitor struct {
+	paths     []string
+	period    time.Duration
+	threshold time.Duration
+}
+
+// NewMonitor creates monitor of pem files of certificates, e.g.
+// /var/lib/kubelet/pki/kubelet-client-current.pem
+func NewMonitor(paths []string, period, threshold time.Duration) *Monitor {
+	return &Monitor{
+		paths:     paths,
+		period:    period,
+		threshold: threshold,
+	}
+}
+
+// Run checks certificates until ctx is done, report is called after each check
+// with the failures of certificates, empty if none of them is expiring.
+// Certificates which can't be checked are neither healthy nor expiring, report
+// is skipped if all of them can't be checked.
+func (m *Monitor) Run(ctx context.Context, report func(failures []string)) {
+	for {
+		if failures, ok := m.check(time.Now()); ok {
+			report(failures)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(m.period):
+		}
+	}
+}
+
+func (m *Monitor) check(now time.Time) ([]string, bool) {
+	var failures []string
+	checked := false
+	for _, path := range m.paths {
+		cert, err := readCertificate(path)
+		if err != nil {
+			checkErrors.Inc(path)
+			log.Errorf("check certificate %s error: %v", path, err)
+			continue
+		}
+		checked = true
+		remaining := cert.NotAfter.Sub(now)
+		certificateExpiry.Set(remaining.Seconds(), path)
+		lifetime := cert.NotAfter.Sub(cert.NotBefore)
+		pastDeadline := lifetime <= 0 || float64(now.Sub(cert.NotBefore)) > rotationDeadline*float64(lifetime)
+		if remaining < m.threshold && pastDeadline {
+			failure := fmt.Sprintf("certificate %s of %s expires at %s and is not rotated",
+				path, cert.Subject.CommonName, cert.NotAfter.Format(time.RFC3339))
+			failures = append(failures, failure)
+			log.Warnw("certificate expiring", "path", path, "notAfter", cert.NotAfter.Format(time.RFC3339))
+		}
+	}
+	return failures, checked
+}
+
+// readCertificate returns the first certificate of a pem file, which may have
+// the private key too, e.g. kubelet-client-current.pem
+func readCertificate(path string) (*x509.Certificate, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("no certificate in %s", path)
+		}
+		if block.Type == "CERTIFICATE" {
+			return x509.ParseCertificate(block.Bytes)
+		}
+	}
+}
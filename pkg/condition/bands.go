@@ -0,0 +1,243 @@
+package condition
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"eviction-agent/pkg/config"
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/types"
+)
+
+// bandSeparator separates evict type and band name in evict types of bands
+const bandSeparator = "@"
+
+// bandEvictTypes are evict types of conditions limited by priority bands
+var bandEvictTypes = map[string][]string{
+	config.CPUCondition:       {types.CPUBusy},
+	config.MemoryCondition:    {types.MemBusy},
+	config.DiskIOCondition:    {types.DiskIO},
+	config.NetworkIOCondition: {types.NetworkRxBusy, types.NetworkTxBusy},
+}
+
+// BandCondition is the usage of pods of a priority band by evict type
+type BandCondition struct {
+	Band      string
+	EvictType string // e.g. DiskIOBusy
+	Usage     float64
+	Limit     float64
+	Available bool
+}
+
+// BandEvictType returns the evict type of evictType limited by band, pods
+// are chosen within the band by it, e.g. DiskIOBusy@best-effort
+func BandEvictType(evictType, band string) string {
+	return evictType + bandSeparator + band
+}
+
+// ParseBandEvictType returns evict type and band of an evict type of
+// BandEvictType, false if it's not of a band
+func ParseBandEvictType(bandEvictType string) (string, string, bool) {
+	i := strings.LastIndex(bandEvictType, bandSeparator)
+	if i <= 0 || i == len(bandEvictType)-1 {
+		return "", "", false
+	}
+	return bandEvictType[:i], bandEvictType[i+1:], true
+}
+
+// syncPodPriorities gets priorities of pods on node for priority bands, the
+// last ones are kept if it fails
+func (c *conditionManager) syncPodPriorities() {
+	c.policyLock.RLock()
+	enabled := len(c.priorityBands) != 0
+	c.policyLock.RUnlock()
+	if !enabled {
+		return
+	}
+	pods, err := c.client.GetPods()
+	if err != nil {
+		log.Errorf("sync pod priorities error: %v", err)
+		return
+	}
+	priorities := make(map[string]int, len(pods))
+	for _, pod := range pods {
+		priorities[pod.Namespace+"."+pod.Name] = pod.Priority
+	}
+	c.statsLock.Lock()
+	c.podPriorities = priorities
+	c.statsLock.Unlock()
+}
+
+// bandCapacity returns the capacity of the resource of evictType, statsLock
+// and policyLock must be held
+func (c *conditionManager) bandCapacity(evictType string) float64 {
+	switch evictType {
+	case types.CPUBusy:
+		return float64(c.cpuTotal)
+	case types.MemBusy:
+		return float64(c.memTotal)
+	case types.DiskIO:
+		return float64(c.diskIoTotal)
+	case types.NetworkRxBusy, types.NetworkTxBusy:
+		return float64(len(c.networkInterfaces)) * float64(c.networkIoTotal)
+	}
+	return 0
+}
+
+// bandConditions returns conditions of priority bands ordered by band and
+// evict type, limits of disabled conditions are ignored. statsLock and
+// policyLock must be held.
+func (c *conditionManager) bandConditions() []BandCondition {
+	var conditions []BandCondition
+	for i := range c.priorityBands {
+		band := &c.priorityBands[i]
+		for condition, limit := range band.Limits {
+			if c.disabledConditions[condition] {
+				continue
+			}
+			for _, evictType := range bandEvictTypes[condition] {
+				capacity := c.bandCapacity(evictType)
+				if capacity <= 0 {
+					continue
+				}
+				bandCondition := BandCondition{
+					Band:      band.Name,
+					EvictType: evictType,
+					Usage:     c.bandUsage(band, evictType),
+					Limit:     limit.Value(capacity),
+					Available: true,
+				}
+				if bandCondition.Usage > bandCondition.Limit {
+					log.Infof("priority band %s out of limits of %s, usage: %v, limit: %v",
+						band.Name, evictType, bandCondition.Usage, bandCondition.Limit)
+					bandCondition.Available = false
+				}
+				conditions = append(conditions, bandCondition)
+			}
+		}
+	}
+	sort.Slice(conditions, func(i, j int) bool {
+		if conditions[i].Band != conditions[j].Band {
+			return conditions[i].Band < conditions[j].Band
+		}
+		return conditions[i].EvictType < conditions[j].EvictType
+	})
+	return conditions
+}
+
+// bandUsage returns the usage of evictType by pods of band, statsLock must be held
+func (c *conditionManager) bandUsage(band *config.PriorityBand, evictType string) float64 {
+	var usage float64
+	for keyName := range c.nodeStats.last().podStats {
+		if priority, ok := c.podPriorities[keyName]; !ok || !band.Contains(priority) {
+			continue
+		}
+		if podUsage, ok := c.podUsage(evictType, keyName, false); ok && podUsage > 0 {
+			usage += podUsage
+		}
+	}
+	return usage
+}
+
+// priorityBand returns the band of name, nil if there is none. policyLock
+// must be held.
+func (c *conditionManager) priorityBand(name string) *config.PriorityBand {
+	for i := range c.priorityBands {
+		if c.priorityBands[i].Name == name {
+			return &c.priorityBands[i]
+		}
+	}
+	return nil
+}
+
+// chooseBandPod chooses the pod of band consuming most of evictType, pods out
+// of the band are never chosen even if the band has no pod to evict
+func (c *conditionManager) chooseBandPod(evictType, bandName string) (*types.PodInfo, bool, string, error) {
+	protected, err := c.protectedPods()
+	if err != nil {
+		return nil, false, "", err
+	}
+	profiles, err := c.podProfiles()
+	if err != nil {
+		return nil, false, "", err
+	}
+	c.statsLock.RLock()
+	defer c.statsLock.RUnlock()
+	c.policyLock.RLock()
+	defer c.policyLock.RUnlock()
+	band := c.priorityBand(bandName)
+	if band == nil {
+		return nil, false, "", fmt.Errorf("unknown priority band %s", bandName)
+	}
+	// the last pod chosen is still on node, it's evicting
+	if c.autoEvict && c.podToEvict.Name != "" {
+		if _, ok := c.nodeStats.last().podStats[c.podToEvict.Namespace+"."+c.podToEvict.Name]; ok {
+			if priority, ok := c.podPriorities[c.podToEvict.Namespace+"."+c.podToEvict.Name]; ok && band.Contains(priority) {
+				return nil, false, "", fmt.Errorf("Pod: %v is evicting...", c.podToEvict.Name)
+			}
+		}
+	}
+	c.lastRanking = c.rankBandCandidates(evictType, band, protected, profiles)
+	if len(c.lastRanking.Candidates) == 0 {
+		c.podToEvict = types.PodInfo{}
+		return nil, false, "", fmt.Errorf("no pod of priority band %s to evict for %s", band.Name, evictType)
+	}
+	evil := c.lastRanking.Candidates[0]
+	log.Infof("get evil pod of priority band %s: %v, usage: %v, priority: %v, %s",
+		band.Name, evil.Pod.Name, evil.Usage, evil.Pod.Priority, evictType)
+	c.podToEvict = evil.Pod
+	return &c.podToEvict, c.autoEvict, evil.Label, nil
+}
+
+// rankBandCandidates returns pods of band ordered by weighted usage of evictType, all
+// of them need eviction. Pods out of the band are not listed as excluded.
+// statsLock and policyLock must be held.
+func (c *conditionManager) rankBandCandidates(evictType string, band *config.PriorityBand,
+	protected map[string]string, profiles map[string]types.PodProfile) Ranking {
+	var ranking Ranking
+	for keyName, stats := range c.nodeStats.last().podStats {
+		priority, ok := c.podPriorities[keyName]
+		if !ok || !band.Contains(priority) {
+			continue
+		}
+		pod := stats.podInfo()
+		pod.Priority = priority
+		if reason := c.protectedReason(pod, protected); reason != "" {
+			ranking.exclude(pod, reason)
+			continue
+		}
+		if reason, ok := c.ioBurstExcluded(evictType, keyName); ok {
+			ranking.excludeFor(pod, ExcludedIOBurst, reason)
+			continue
+		}
+		usage, ok := c.podUsage(evictType, keyName, false)
+		if !ok {
+			ranking.exclude(pod, ExcludedNoStats)
+			continue
+		}
+		scored := usage - c.infraUsage(evictType, keyName, profiles)
+		if scored <= 0 {
+			ranking.exclude(pod, ExcludedNoUsage)
+			continue
+		}
+		ranking.Candidates = append(ranking.Candidates, Candidate{
+			Pod:   pod,
+			Usage: usage,
+			Score: scored * c.namespaceWeight(pod.Namespace),
+			Label: types.NeedEvict,
+		})
+	}
+	sort.Slice(ranking.Candidates, func(i, j int) bool {
+		return ranking.Candidates[i].Score > ranking.Candidates[j].Score
+	})
+	c.setOffenderContainers(evictType, &ranking)
+	sort.Slice(ranking.Excluded, func(i, j int) bool {
+		a, b := ranking.Excluded[i], ranking.Excluded[j]
+		if a.Reason != b.Reason {
+			return a.Reason < b.Reason
+		}
+		return a.Pod < b.Pod
+	})
+	return ranking
+}
@@ -0,0 +1,258 @@
+package condition
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"eviction-agent/cmd/options"
+	"eviction-agent/pkg/config"
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/metrics"
+	"eviction-agent/pkg/types"
+)
+
+// calibrationBuckets are the buckets of usage histograms, 1% of capacity each
+const calibrationBuckets = 100
+
+// calibrationPercentile is the percentile of daily usage in baseline
+const calibrationPercentile = 0.95
+
+var (
+	calibratedThresholds = metrics.NewGaugeVec("eviction_agent_calibrated_threshold",
+		"Taint threshold calibrated from baseline usage as a ratio of capacity by condition.", "condition")
+)
+
+// baselineDay is the p95 usage of conditions of a day as ratios of capacity
+type baselineDay struct {
+	Date  string             `json:"date"`
+	Usage map[string]float64 `json:"usage"`
+}
+
+// calibration records the usage of node over days and calibrates taint
+// thresholds as factor times of the baseline, the median of daily p95 usage,
+// bounded by min and max. Completed days are kept in a node annotation, so
+// that restarts lose only the current day.
+type calibration struct {
+	lock   sync.Mutex
+	apply  bool
+	days   int
+	factor float64
+	min    float64
+	max    float64
+	// today is the date of histograms, of UTC
+	today      string
+	histograms map[string]*[calibrationBuckets + 1]int
+	lastSample time.Time
+	baseline   []baselineDay // the oldest first
+	thresholds map[string]float64
+}
+
+func newCalibration(eao *options.EvictionAgentOptions) *calibration {
+	if eao.Calibrate == "" {
+		return nil
+	}
+	return &calibration{
+		apply:      eao.Calibrate == options.CalibrateApply,
+		days:       eao.CalibrationDays,
+		factor:     eao.CalibrationFactor,
+		min:        eao.CalibrationMin,
+		max:        eao.CalibrationMax,
+		histograms: make(map[string]*[calibrationBuckets + 1]int),
+	}
+}
+
+// restoreCalibration loads the baseline from node annotation
+func (c *conditionManager) restoreCalibration() {
+	if c.calibration == nil {
+		return
+	}
+	annotations, err := c.client.GetNodeAnnotations()
+	if err != nil {
+		log.Errorf("restore usage baseline error, start a new one: %v", err)
+		return
+	}
+	data, ok := annotations[types.UsageBaselineAnnotation]
+	if !ok {
+		return
+	}
+	var baseline []baselineDay
+	if err := json.Unmarshal([]byte(data), &baseline); err != nil {
+		log.Warnf("invalid %s, start a new one: %v", types.UsageBaselineAnnotation, err)
+		return
+	}
+	cal := c.calibration
+	cal.lock.Lock()
+	defer cal.lock.Unlock()
+	cal.baseline = baseline
+	cal.calibrate()
+}
+
+// calibrate records the usage of the last stats, and persists the baseline
+// once a day is completed. It's called by stats sync.
+func (c *conditionManager) calibrate() {
+	cal := c.calibration
+	if cal == nil {
+		return
+	}
+	sampleTime, ratios := c.usageRatios()
+	if ratios == nil {
+		return
+	}
+	cal.lock.Lock()
+	if !sampleTime.After(cal.lastSample) {
+		cal.lock.Unlock()
+		return
+	}
+	cal.lastSample = sampleTime
+	date := sampleTime.UTC().Format("2006-01-02")
+	var completed bool
+	if cal.today != date {
+		completed = cal.completeDay()
+		cal.today = date
+	}
+	for condition, ratio := range ratios {
+		histogram, ok := cal.histograms[condition]
+		if !ok {
+			histogram = &[calibrationBuckets + 1]int{}
+			cal.histograms[condition] = histogram
+		}
+		bucket := int(math.Min(math.Max(ratio, 0), 1) * calibrationBuckets)
+		histogram[bucket]++
+	}
+	var data []byte
+	if completed {
+		data, _ = json.Marshal(cal.baseline)
+	}
+	cal.lock.Unlock()
+	if data != nil {
+		if err := c.client.AnnotateNode(map[string]string{types.UsageBaselineAnnotation: string(data)}); err != nil {
+			log.Errorf("save usage baseline error: %v", err)
+		}
+	}
+}
+
+// completeDay adds p95 usage of histograms of today to baseline, and
+// calibrates thresholds again. Returns false if there is no sample today.
+// lock must be held.
+func (cal *calibration) completeDay() bool {
+	if cal.today == "" || len(cal.histograms) == 0 {
+		return false
+	}
+	day := baselineDay{Date: cal.today, Usage: make(map[string]float64)}
+	for condition, histogram := range cal.histograms {
+		day.Usage[condition] = percentile(histogram, calibrationPercentile)
+	}
+	cal.histograms = make(map[string]*[calibrationBuckets + 1]int)
+	cal.baseline = append(cal.baseline, day)
+	if len(cal.baseline) > cal.days {
+		cal.baseline = cal.baseline[len(cal.baseline)-cal.days:]
+	}
+	cal.calibrate()
+	return true
+}
+
+// calibrate computes thresholds from baseline, lock must be held
+func (cal *calibration) calibrate() {
+	usages := make(map[string][]float64)
+	for _, day := range cal.baseline {
+		for condition, usage := range day.Usage {
+			usages[condition] = append(usages[condition], usage)
+		}
+	}
+	cal.thresholds = make(map[string]float64)
+	for condition, values := range usages {
+		sort.Float64s(values)
+		median := values[len(values)/2]
+		if len(values)%2 == 0 {
+			median = (values[len(values)/2-1] + median) / 2
+		}
+		threshold := math.Min(math.Max(median*cal.factor, cal.min), cal.max)
+		cal.thresholds[condition] = threshold
+		calibratedThresholds.Set(threshold, condition)
+	}
+	applied := cal.apply && len(cal.baseline) >= cal.days
+	log.Infof("Calibrated thresholds of %d days of baseline: %v, applied: %v", len(cal.baseline), cal.thresholds, applied)
+}
+
+// threshold returns the calibrated threshold of condition, false if it's not applied
+func (cal *calibration) threshold(condition string) (config.Threshold, bool) {
+	if cal == nil {
+		return config.Threshold{}, false
+	}
+	cal.lock.Lock()
+	defer cal.lock.Unlock()
+	ratio, ok := cal.thresholds[condition]
+	if !ok || !cal.apply || len(cal.baseline) < cal.days {
+		return config.Threshold{}, false
+	}
+	return config.Threshold{Ratio: ratio}, true
+}
+
+// percentile returns the upper bound of the bucket holding the percentile p
+func percentile(histogram *[calibrationBuckets + 1]int, p float64) float64 {
+	total := 0
+	for _, n := range histogram {
+		total += n
+	}
+	count := 0
+	for bucket, n := range histogram {
+		count += n
+		if float64(count) >= p*float64(total) {
+			return math.Min(float64(bucket+1)/calibrationBuckets, 1)
+		}
+	}
+	return 1
+}
+
+// threshold returns the taint threshold of condition, the calibrated one if
+// it's applied. policyLock must be held.
+func (c *conditionManager) threshold(condition string) config.Threshold {
+	if threshold, ok := c.calibration.threshold(condition); ok {
+		return threshold
+	}
+	return c.taintThreshold[condition]
+}
+
+// usageRatios returns the time of the last stats and usages of conditions
+// as ratios of capacity, nil if there are not enough stats
+func (c *conditionManager) usageRatios() (time.Time, map[string]float64) {
+	c.statsLock.RLock()
+	defer c.statsLock.RUnlock()
+	c.policyLock.RLock()
+	defer c.policyLock.RUnlock()
+	n := c.nodeStats.len()
+	if n < 2 {
+		return time.Time{}, nil
+	}
+	newStats, lastStats := c.nodeStats.last(), c.nodeStats.at(n-2)
+	ratios := make(map[string]float64)
+	if c.cpuTotal > 0 {
+		ratios[config.CPUCondition] = newStats.cpuUsage / float64(c.cpuTotal)
+	}
+	if c.memTotal > 0 {
+		ratios[config.MemoryCondition] = float64(newStats.memoryUsage) / float64(c.memTotal)
+	}
+	seconds := newStats.time.Sub(lastStats.time).Seconds()
+	if seconds <= 0 {
+		return newStats.time, ratios
+	}
+	rate := func(newValue, lastValue uint64) float64 {
+		if newValue < lastValue {
+			// counters are reset
+			return 0
+		}
+		return float64(newValue-lastValue) / seconds
+	}
+	if c.diskIoTotal > 0 {
+		disk, last := newStats.diskIOStats, lastStats.diskIOStats
+		ratios[config.DiskIOCondition] = rate(disk.rx+disk.tx, last.rx+last.tx) / float64(c.diskIoTotal)
+	}
+	if capacity := float64(len(c.networkInterfaces)) * float64(c.networkIoTotal); capacity > 0 {
+		network, last := newStats.netIOStats, lastStats.netIOStats
+		ratios[config.NetworkIOCondition] = math.Max(rate(network.rx, last.rx), rate(network.tx, last.tx)) / capacity
+	}
+	return newStats.time, ratios
+}
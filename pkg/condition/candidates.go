@@ -0,0 +1,518 @@
+package condition
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/types"
+)
+
+// Candidate is a pod which may be chosen to evict
+type Candidate struct {
+	Pod types.PodInfo `json:"pod"`
+	// Usage is the resource usage of the condition, e.g. iops for DiskIOBusy
+	Usage float64 `json:"usage"`
+	// Score is usage weighted by priority, the highest score is chosen
+	Score float64 `json:"score"`
+	// Label is NeedsEviction for lower priority pods and pods matching label
+	// policy, or EvictionCandidate
+	Label string `json:"label"`
+	// LabelReason is why pod has the label, e.g. LowPriority
+	LabelReason string `json:"labelReason,omitempty"`
+	// Progress is the progress of Job of pod to completion, score is weighted
+	// by the rest of it
+	Progress float64 `json:"progress,omitempty"`
+	// Cost is the cost of pod to restart, score is weighted by it if cost is
+	// a scoring factor
+	Cost float64 `json:"cost,omitempty"`
+	// Returns is true if pod tolerates the taint or is required to run on
+	// node, it's scheduled back once evicted and ranked after the others
+	Returns bool `json:"returns,omitempty"`
+}
+
+// filters excluding pods from candidates
+const (
+	// ExcludedProtectedNamespace is a pod in protected namespaces
+	ExcludedProtectedNamespace = "ProtectedNamespace"
+	// ExcludedHigherPriority is a pod above low priority threshold, it's
+	// considered only if no lower priority pod consumes the resource
+	ExcludedHigherPriority = "HigherPriority"
+	// ExcludedNoStats is a pod without enough stats to compute its usage
+	ExcludedNoStats = "NoStats"
+	// ExcludedNoUsage is a pod consuming nothing of the resource
+	ExcludedNoUsage = "NoUsage"
+	// ExcludedSelf is the pod of eviction agent
+	ExcludedSelf = "Self"
+	// ExcludedInfrastructure is a pod of DaemonSets or a static pod, e.g. a
+	// monitoring agent, which is recreated on the same node
+	ExcludedInfrastructure = "Infrastructure"
+	// ExcludedTenantBudget is a pod of a tenant which is out of eviction budget
+	ExcludedTenantBudget = "TenantBudget"
+	// ExcludedNamespaceBudget is a pod of a namespace which has max evictions
+	// per hour of its preference
+	ExcludedNamespaceBudget = "NamespaceBudget"
+	// ExcludedTrafficClass is a pod not of the busy network traffic classes,
+	// e.g. a pod of pod network while only host traffic is busy
+	ExcludedTrafficClass = "TrafficClass"
+	// ExcludedStatefulSet is a pod of a StatefulSet kept by its ordering
+	// rules, ordinal 0 of a quorum or more replicas are unavailable than allowed
+	ExcludedStatefulSet = "StatefulSet"
+	// ExcludedPinned is a pod which can't be scheduled on other nodes, e.g.
+	// of a local volume, it would be Pending or scheduled back once evicted
+	ExcludedPinned = "PinnedToNode"
+	// ExcludedIOBurst is a pod in its declared high io phase, e.g. a
+	// checkpoint, it's counted for io once the phase ends
+	ExcludedIOBurst = "IOBurst"
+)
+
+// Exclusion is a pod not ranked as candidate
+type Exclusion struct {
+	// Pod is namespace/name of the pod
+	Pod string `json:"pod"`
+	// Reason is the filter excluding the pod, e.g. ProtectedNamespace
+	Reason string `json:"reason"`
+	// Detail is why the filter excludes the pod, e.g. the volume pinning it
+	Detail string `json:"detail,omitempty"`
+}
+
+// key returns the key of pod in pod stats
+func (e Exclusion) key() string {
+	return strings.Replace(e.Pod, "/", ".", 1)
+}
+
+// Ranking is the result of ranking pods for an eviction
+type Ranking struct {
+	// Candidates are ordered by score, the first one is chosen
+	Candidates []Candidate `json:"candidates"`
+	// Excluded are the pods filtered out, ordered by reason
+	Excluded []Exclusion `json:"excluded"`
+}
+
+func (r *Ranking) exclude(pod types.PodInfo, reason string) {
+	r.excludeFor(pod, reason, "")
+}
+
+func (r *Ranking) excludeFor(pod types.PodInfo, reason, detail string) {
+	r.Excluded = append(r.Excluded, Exclusion{Pod: pod.Namespace + "/" + pod.Name, Reason: reason, Detail: detail})
+}
+
+// GetEvictionCandidates returns the ranked candidates of evictType
+// without choosing any pod, the first one would be evicted next
+func (c *conditionManager) GetEvictionCandidates(evictType string) ([]Candidate, error) {
+	if !c.HasSynced() {
+		return nil, fmt.Errorf("there are no enough stats")
+	}
+	lowPriorityPods, err := c.client.GetLowerPriorityPods(c.getLowPriorityThreshold())
+	if err != nil {
+		return nil, err
+	}
+	protected, err := c.protectedPods()
+	if err != nil {
+		return nil, err
+	}
+	profiles, err := c.podProfiles()
+	if err != nil {
+		return nil, err
+	}
+
+	c.statsLock.RLock()
+	defer c.statsLock.RUnlock()
+	c.policyLock.RLock()
+	defer c.policyLock.RUnlock()
+	return c.rankCandidates(evictType, c.filterProtectedPods(lowPriorityPods, protected), protected, profiles).Candidates, nil
+}
+
+// GetDrainCandidates returns pods on node evicted to drain it, ordered by
+// priority and then by name. Protected pods are never evicted, pods of
+// DaemonSets and static pods are not either, since they stay on node.
+func (c *conditionManager) GetDrainCandidates() ([]types.PodInfo, error) {
+	pods, err := c.client.GetPods()
+	if err != nil {
+		return nil, err
+	}
+	protected, err := c.protectedPods()
+	if err != nil {
+		return nil, err
+	}
+	infraPods, err := c.client.GetInfraPods()
+	if err != nil {
+		return nil, err
+	}
+	c.policyLock.RLock()
+	defer c.policyLock.RUnlock()
+	var candidates []types.PodInfo
+	for _, pod := range pods {
+		key := pod.Namespace + "/" + pod.Name
+		if infraPods[key] || c.protectedNamespaces[pod.Namespace] || protected[key] != "" ||
+			c.namespacePreferences[pod.Namespace].Protected {
+			continue
+		}
+		candidates = append(candidates, pod)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.Priority != b.Priority {
+			return a.Priority < b.Priority
+		}
+		return a.Namespace+"/"+a.Name < b.Namespace+"/"+b.Name
+	})
+	return candidates, nil
+}
+
+// protectedPods returns the exclusion of agent itself, infra pods and pods
+// kept by ordering rules of their StatefulSets keyed by namespace/name, they
+// are never chosen
+func (c *conditionManager) protectedPods() (map[string]string, error) {
+	protected := make(map[string]string)
+	if c.protectInfraPods {
+		pods, err := c.client.GetInfraPods()
+		if err != nil {
+			return nil, err
+		}
+		for pod := range pods {
+			protected[pod] = ExcludedInfrastructure
+		}
+	}
+	if c.selfPod.Name != "" {
+		protected[c.selfPod.Namespace+"/"+c.selfPod.Name] = ExcludedSelf
+	}
+	statefulSetPods, err := c.client.GetStatefulSetPods()
+	if err != nil {
+		return nil, err
+	}
+	for pod, statefulSetPod := range statefulSetPods {
+		if _, ok := protected[pod]; ok {
+			continue
+		}
+		if reason := statefulSetKept(statefulSetPod); reason != "" {
+			log.Infof("keep pod %s of StatefulSet %s, %s", pod, statefulSetPod.StatefulSet, reason)
+			protected[pod] = ExcludedStatefulSet
+		}
+	}
+	return protected, nil
+}
+
+// statefulSetKept returns why pod of StatefulSet is not evicted by ordering
+// rules of StatefulSet, empty if it may be evicted
+func statefulSetKept(pod types.StatefulSetPod) string {
+	if pod.Quorum && pod.Ordinal == 0 {
+		return "ordinal 0 of quorum is never evicted"
+	}
+	if pod.MaxUnavailable >= 0 && pod.Ready && pod.Unavailable >= pod.MaxUnavailable {
+		return fmt.Sprintf("%d replicas are unavailable, max %d", pod.Unavailable, pod.MaxUnavailable)
+	}
+	return ""
+}
+
+// protectedReason returns the exclusion of pod which is never chosen, empty
+// if it may be chosen. policyLock must be held.
+func (c *conditionManager) protectedReason(pod types.PodInfo, protected map[string]string) string {
+	if c.protectedNamespaces[pod.Namespace] {
+		return ExcludedProtectedNamespace
+	}
+	if reason, ok := protected[pod.Namespace+"/"+pod.Name]; ok {
+		return reason
+	}
+	if c.skippedNamespaces[pod.Namespace] {
+		return ExcludedTenantBudget
+	}
+	return c.namespaceExcluded(pod.Namespace)
+}
+
+// SetSkippedNamespaces sets namespaces whose pods are not chosen, e.g. of
+// tenants out of eviction budget
+func (c *conditionManager) SetSkippedNamespaces(namespaces map[string]bool) {
+	c.policyLock.Lock()
+	defer c.policyLock.Unlock()
+	c.skippedNamespaces = namespaces
+}
+
+// GetLastRanking returns the ranking of the last ChooseOnePodToEvict
+func (c *conditionManager) GetLastRanking() Ranking {
+	return c.lastRanking
+}
+
+// getLowPriorityThreshold returns the current low priority threshold
+func (c *conditionManager) getLowPriorityThreshold() int {
+	c.policyLock.RLock()
+	defer c.policyLock.RUnlock()
+	return c.lowPriorityThreshold
+}
+
+// filterProtectedPods drops pods in protected namespaces and pods in protected,
+// policyLock must be held
+func (c *conditionManager) filterProtectedPods(pods []types.PodInfo, protected map[string]string) []types.PodInfo {
+	var filtered []types.PodInfo
+	for _, pod := range pods {
+		if c.protectedReason(pod, protected) == "" {
+			filtered = append(filtered, pod)
+		}
+	}
+	return filtered
+}
+
+// rankCandidates returns candidates ordered by score. Lower priority pods
+// are weighted by priority and preferred, other pods are considered only if
+// no lower priority pod consumes the resource. Pods scheduled back on node
+// are ordered after the others. Pods consuming nothing and
+// protected pods are ignored, usage of infrastructure containers is
+// discounted from scores. Pods not ranked are returned with the filter
+// excluding them. Labels are set by label policy, profiles are pods of
+// GetPodProfiles. statsLock and policyLock must be held.
+func (c *conditionManager) rankCandidates(evictType string, pods []types.PodInfo, protected map[string]string,
+	profiles map[string]types.PodProfile) Ranking {
+	var ranking Ranking
+	podStats := c.nodeStats.last().podStats
+	lowPriority := make(map[string]bool)
+	for _, pod := range pods {
+		keyName := pod.Namespace + "." + pod.Name
+		lowPriority[keyName] = true
+		if c.trafficExcluded(evictType, keyName) {
+			ranking.exclude(pod, ExcludedTrafficClass)
+			continue
+		}
+		if reason, ok := c.pinnedExcluded(keyName); ok {
+			ranking.excludeFor(pod, ExcludedPinned, reason)
+			continue
+		}
+		if reason, ok := c.ioBurstExcluded(evictType, keyName); ok {
+			ranking.excludeFor(pod, ExcludedIOBurst, reason)
+			continue
+		}
+		usage, ok := c.podUsage(evictType, keyName, false)
+		if !ok {
+			ranking.exclude(pod, ExcludedNoStats)
+			continue
+		}
+		// choose the bigger weight
+		score := usage - c.infraUsage(evictType, keyName, profiles)
+		if pod.Priority != 0 {
+			score /= float64(pod.Priority)
+		}
+		score *= c.scoreWeight(keyName)
+		if score > 0 {
+			ranking.Candidates = append(ranking.Candidates, Candidate{
+				Pod:      pod,
+				Usage:    usage,
+				Score:    score,
+				Label:    types.NeedEvict,
+				Progress: c.jobProgress[keyName],
+				Cost:     c.podCosts[keyName],
+				Returns:  c.returns(evictType, keyName),
+			})
+		} else {
+			ranking.exclude(pod, ExcludedNoUsage)
+		}
+	}
+
+	if len(ranking.Candidates) != 0 {
+		for keyName, pod := range podStats {
+			if lowPriority[keyName] {
+				continue
+			}
+			if reason := c.protectedReason(pod.podInfo(), protected); reason != "" {
+				ranking.exclude(pod.podInfo(), reason)
+			} else {
+				ranking.exclude(pod.podInfo(), ExcludedHigherPriority)
+			}
+		}
+	} else {
+		// all pods are ranked again, only keep pods without stats at all
+		var excluded []Exclusion
+		for _, exclusion := range ranking.Excluded {
+			if _, ok := podStats[exclusion.key()]; !ok {
+				excluded = append(excluded, exclusion)
+			}
+		}
+		ranking.Excluded = excluded
+		for keyName, pod := range podStats {
+			if reason := c.protectedReason(pod.podInfo(), protected); reason != "" {
+				ranking.exclude(pod.podInfo(), reason)
+				continue
+			}
+			if c.trafficExcluded(evictType, keyName) {
+				ranking.exclude(pod.podInfo(), ExcludedTrafficClass)
+				continue
+			}
+			if reason, ok := c.pinnedExcluded(keyName); ok {
+				ranking.excludeFor(pod.podInfo(), ExcludedPinned, reason)
+				continue
+			}
+			if reason, ok := c.ioBurstExcluded(evictType, keyName); ok {
+				ranking.excludeFor(pod.podInfo(), ExcludedIOBurst, reason)
+				continue
+			}
+			usage, ok := c.podUsage(evictType, keyName, true)
+			if !ok {
+				ranking.exclude(pod.podInfo(), ExcludedNoStats)
+				continue
+			}
+			scored := usage - c.infraUsage(evictType, keyName, profiles)
+			if scored <= 0 {
+				ranking.exclude(pod.podInfo(), ExcludedNoUsage)
+				continue
+			}
+			ranking.Candidates = append(ranking.Candidates, Candidate{
+				Pod:      pod.podInfo(),
+				Usage:    usage,
+				Score:    scored * c.scoreWeight(keyName),
+				Label:    types.EvictCandidate,
+				Progress: c.jobProgress[keyName],
+				Cost:     c.podCosts[keyName],
+				Returns:  c.returns(evictType, keyName),
+			})
+		}
+	}
+
+	// pods scheduled back don't relieve node, they are chosen after the others
+	sort.Slice(ranking.Candidates, func(i, j int) bool {
+		a, b := ranking.Candidates[i], ranking.Candidates[j]
+		if a.Returns != b.Returns {
+			return b.Returns
+		}
+		return a.Score > b.Score
+	})
+	sort.Slice(ranking.Excluded, func(i, j int) bool {
+		a, b := ranking.Excluded[i], ranking.Excluded[j]
+		if a.Reason != b.Reason {
+			return a.Reason < b.Reason
+		}
+		return a.Pod < b.Pod
+	})
+	c.setOffenderContainers(evictType, &ranking)
+	c.applyLabelPolicy(evictType, &ranking, profiles)
+	return ranking
+}
+
+// measuredDevices returns the disk devices or network interfaces whose io
+// pods are ranked by for evictType, nil for other resources
+func (c *conditionManager) measuredDevices(evictType string) []string {
+	switch evictType {
+	case types.DiskIO:
+		if len(c.nodeCondition.DiskDevices) != 0 {
+			return c.nodeCondition.DiskDevices
+		}
+		if c.nodeCondition.DiskDevice != "" {
+			return []string{c.nodeCondition.DiskDevice}
+		}
+	case types.NetworkRxBusy, types.NetworkTxBusy:
+		return c.nodeCondition.NetworkInterfaces
+	}
+	return nil
+}
+
+// fitRequest orders candidates of ranking for request. Pods are ranked by io
+// on the devices measured now, so request of other devices, e.g. queued
+// before the policy changed, is refused. At Critical severity the pod of the
+// most usage is chosen to relieve node fastest, or else the best scored pod
+// whose usage covers the excess of Value over Threshold alone, if any, so
+// that one eviction is enough. Pods scheduled back stay after the others.
+func (c *conditionManager) fitRequest(request types.Condition, ranking *Ranking) error {
+	if measured := c.measuredDevices(request.Type); len(request.Devices) != 0 && len(measured) != 0 {
+		overlap := false
+		for _, device := range request.Devices {
+			for _, m := range measured {
+				overlap = overlap || device == m
+			}
+		}
+		if !overlap {
+			return fmt.Errorf("%s of %s is requested, io of pods is measured on %s",
+				request.Type, strings.Join(request.Devices, ","), strings.Join(measured, ","))
+		}
+	}
+	n := 0
+	for n < len(ranking.Candidates) && !ranking.Candidates[n].Returns {
+		n++
+	}
+	if n == 0 {
+		n = len(ranking.Candidates)
+	}
+	candidates := ranking.Candidates[:n]
+	best := -1
+	if request.Severity == types.SeverityCritical {
+		for i := range candidates {
+			if best < 0 || candidates[i].Usage > candidates[best].Usage {
+				best = i
+			}
+		}
+	} else if excess := request.Value - request.Threshold; request.Threshold > 0 && excess > 0 {
+		for i := range candidates {
+			if candidates[i].Usage >= excess {
+				best = i
+				break
+			}
+		}
+	}
+	if best > 0 {
+		chosen := candidates[best]
+		copy(candidates[1:best+1], candidates[:best])
+		candidates[0] = chosen
+	}
+	return nil
+}
+
+// podUsage returns resource usage of pod for evictType, io usages are computed from
+// the last two stats, extended resources are from their usage url. Rx and tx are summed together if combineNetwork is true.
+// Returns false if there are no stats of the pod.
+func (c *conditionManager) podUsage(evictType, keyName string, combineNetwork bool) (float64, bool) {
+	if resource, ok := c.extendedTaints[evictType]; ok {
+		return c.extendedPodUsage(resource, keyName)
+	}
+	if evictType == types.WritebackBusy {
+		backlog, ok := c.nodeStats.last().podWriteback[keyName]
+		return float64(backlog), ok
+	}
+	if evictType == types.EphemeralPortsBusy {
+		sockets, ok := c.nodeStats.last().podSockets[keyName]
+		return float64(sockets), ok
+	}
+	newStats, ok := c.nodeStats.last().podStats[keyName]
+	if !ok {
+		return 0, false
+	}
+	if evictType == types.CPUBusy {
+		return newStats.cpuUsage, true
+	}
+	if evictType == types.MemBusy {
+		return float64(newStats.memoryUsage), true
+	}
+
+	lastStats, ok := c.nodeStats.at(c.nodeStats.len() - 2).podStats[keyName]
+	if !ok {
+		return 0, false
+	}
+	var newIO, lastIO statType
+	switch evictType {
+	case types.DiskIO:
+		newIO, lastIO = newStats.diskIOStats, lastStats.diskIOStats
+	case types.NetworkRxBusy, types.NetworkTxBusy:
+		newIO, lastIO = newStats.netIOStats, lastStats.netIOStats
+	default:
+		return 0, false
+	}
+	duration := float64(newIO.time.UnixNano() - lastIO.time.UnixNano())
+	if duration <= 0 {
+		return 0, false
+	}
+	var delta float64
+	switch {
+	case evictType == types.DiskIO || combineNetwork:
+		delta = float64(newIO.rx+newIO.tx) - float64(lastIO.rx+lastIO.tx)
+	case evictType == types.NetworkTxBusy:
+		delta = float64(newIO.tx) - float64(lastIO.tx)
+	default:
+		delta = float64(newIO.rx) - float64(lastIO.rx)
+	}
+	return 1e9 * delta / duration, true
+}
+
+// podInfo returns the pod of stats, priority is unknown
+func (p podStatType) podInfo() types.PodInfo {
+	return types.PodInfo{
+		Name:      p.name,
+		Namespace: p.namespace,
+		UID:       p.uid,
+	}
+}
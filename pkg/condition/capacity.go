@@ -0,0 +1,131 @@
+package condition
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/metrics"
+)
+
+// sysClassNet is where NIC speed is read, the agent runs in host network
+const sysClassNet = "/sys/class/net"
+
+// networkCapacityPeriod is the period of detecting bandwidth again, links
+// may renegotiate their speed or bond members may go down
+const networkCapacityPeriod = time.Minute
+
+var (
+	networkCapacity = metrics.NewGaugeVec("eviction_agent_network_capacity_bytes",
+		"Bandwidth in bytes per second of network interfaces detected from link speed.", "interface")
+)
+
+// detectNetworkBPSTotal returns the mean bandwidth in bytes per second of
+// interfaces, since the total is per interface and the node total is it times
+// the number of interfaces. Returns 0 if speed of any of them is unknown.
+func detectNetworkBPSTotal(interfaces []string) int64 {
+	var total int64
+	for _, iface := range interfaces {
+		bps, err := interfaceBPS(iface)
+		if err != nil {
+			log.Warnf("detect bandwidth of network interface %s error: %v", iface, err)
+			return 0
+		}
+		networkCapacity.Set(float64(bps), iface)
+		total += bps
+	}
+	if total == 0 {
+		return 0
+	}
+	return total / int64(len(interfaces))
+}
+
+// interfaceBPS returns the bandwidth of iface from its speed. Bandwidth of a
+// bond is the sum of its members which are up, or of the active member in
+// active-backup mode.
+func interfaceBPS(iface string) (int64, error) {
+	dir := filepath.Join(sysClassNet, iface)
+	members, err := readSysfs(filepath.Join(dir, "bonding", "slaves"))
+	if err != nil {
+		return linkBPS(dir)
+	}
+	mode, err := readSysfs(filepath.Join(dir, "bonding", "mode"))
+	if err != nil {
+		return 0, err
+	}
+	// e.g. active-backup 1
+	if strings.HasPrefix(mode, "active-backup") {
+		active, err := readSysfs(filepath.Join(dir, "bonding", "active_slave"))
+		if err != nil || active == "" {
+			return 0, fmt.Errorf("bond has no active member")
+		}
+		return linkBPS(filepath.Join(sysClassNet, active))
+	}
+	var total int64
+	for _, member := range strings.Fields(members) {
+		memberDir := filepath.Join(sysClassNet, member)
+		if state, _ := readSysfs(filepath.Join(memberDir, "operstate")); state != "up" {
+			continue
+		}
+		bps, err := linkBPS(memberDir)
+		if err != nil {
+			return 0, fmt.Errorf("member %s: %v", member, err)
+		}
+		total += bps
+	}
+	if total == 0 {
+		return 0, fmt.Errorf("bond has no member up")
+	}
+	return total, nil
+}
+
+// linkBPS returns the bandwidth of the link of the interface of dir
+func linkBPS(dir string) (int64, error) {
+	data, err := readSysfs(filepath.Join(dir, "speed"))
+	if err != nil {
+		return 0, err
+	}
+	// Mb/s, -1 for virtual interfaces and interfaces which are down
+	speed, err := strconv.ParseInt(data, 10, 64)
+	if err != nil || speed <= 0 {
+		return 0, fmt.Errorf("unknown speed %q", data)
+	}
+	return speed * 1000 * 1000 / 8, nil
+}
+
+func readSysfs(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// networkCapacityWatcher detects bandwidth again every period while it's
+// detected instead of annotated or configured, thresholds of ratios follow
+// links renegotiating their speed
+func (c *conditionManager) networkCapacityWatcher(ctx context.Context) {
+	for c.sleep(ctx, networkCapacityPeriod) {
+		c.policyLock.RLock()
+		detected, interfaces, last := c.networkDetected, c.networkInterfaces, c.networkIoTotal
+		c.policyLock.RUnlock()
+		if !detected {
+			continue
+		}
+		total := detectNetworkBPSTotal(interfaces)
+		if total == 0 || total == last {
+			continue
+		}
+		log.Infof("network bandwidth of %v changed from %v to %v Bytes/s", interfaces, last, total)
+		c.policyLock.Lock()
+		if c.networkDetected {
+			c.networkIoTotal = total
+		}
+		c.policyLock.Unlock()
+	}
+}
@@ -0,0 +1,406 @@
+package condition
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	cadvisorapiv1 "github.com/google/cadvisor/info/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/metrics"
+	"eviction-agent/pkg/summary"
+)
+
+var (
+	statsSourceErrors = metrics.NewCounterVec("eviction_agent_stats_source_errors_total",
+		"Number of stats sources failed or timed out.", "source")
+	podStatsErrors = metrics.NewCounterVec("eviction_agent_pod_stats_errors_total",
+		"Number of pods whose stats failed or timed out by source.", "source")
+)
+
+// stats sources, each fills its own part of node stats
+const (
+	cpuSource     = "cpu"
+	memorySource  = "memory"
+	networkSource = "network"
+	diskSource    = "disk"
+	podSource     = "pod"
+)
+
+// collectInput is the policy used by stats sources
+type collectInput struct {
+	networkInterfaces []string
+	overlayInterfaces []string
+	reclaim           bool // reads reclaim activity of /proc/vmstat
+	writeback         bool // reads dirty and writeback pages of node and pods
+	ports             bool // reads ephemeral ports of node and sockets of pods
+	diskDevName       string
+	diskTopology      *diskTopology // io is of physical devices of diskDevName if it's resolved
+	maxPods           int           // max pods kept, unlimited if zero
+	podWorkers        int           // max pods attributed at once, all of them if zero
+	podTimeout        time.Duration // timeout of each pod, unlimited if zero
+	clock             clock.Clock
+}
+
+// statsSource collects one part of node stats from the summary
+type statsSource struct {
+	name    string
+	collect func(stats *summary.ConditionStats, in collectInput, out *nodeStatsType)
+}
+
+var statsSources = []statsSource{
+	{name: cpuSource, collect: collectCPU},
+	{name: memorySource, collect: collectMemory},
+	{name: networkSource, collect: collectNetwork},
+	{name: diskSource, collect: collectDisk},
+	{name: podSource, collect: collectPods},
+	{name: reclaimSource, collect: collectReclaim},
+	{name: writebackSource, collect: collectWriteback},
+	{name: portsSource, collect: collectPorts},
+}
+
+// collectStats runs sources concurrently, at most concurrency of them at once.
+// Sources of reclaim, writeback and ports read /proc and cgroup files, which
+// may hang, so a source not done in timeout is abandoned, it releases its slot
+// so that others still run, and its partial result is never read. A source
+// which panics, e.g. of a missing part of summary, fails alone. Returns
+// results of sources succeeded and errors of the others.
+func collectStats(clk clock.Clock, timeout time.Duration, concurrency int, sources []statsSource,
+	stats *summary.ConditionStats, in collectInput) (map[string]*nodeStatsType, map[string]error) {
+	if concurrency <= 0 {
+		concurrency = len(sources)
+	}
+	var (
+		lock    sync.Mutex
+		wg      sync.WaitGroup
+		results = make(map[string]*nodeStatsType)
+		errs    = make(map[string]error)
+		slots   = make(chan struct{}, concurrency)
+	)
+	for _, source := range sources {
+		wg.Add(1)
+		go func(source statsSource) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			out := &nodeStatsType{podStats: make(map[string]podStatType)}
+			done := make(chan error, 1)
+			go func() {
+				defer func() {
+					if r := recover(); r != nil {
+						done <- fmt.Errorf("panic: %v", r)
+					}
+				}()
+				source.collect(stats, in, out)
+				done <- nil
+			}()
+			var err error
+			select {
+			case err = <-done:
+			case <-clk.After(timeout):
+				err = fmt.Errorf("timed out after %v", timeout)
+			}
+
+			lock.Lock()
+			defer lock.Unlock()
+			if err != nil {
+				statsSourceErrors.Inc(source.name)
+				log.Errorf("collect %s stats error: %v", source.name, err)
+				errs[source.name] = err
+				return
+			}
+			results[source.name] = out
+		}(source)
+	}
+	wg.Wait()
+	return results, errs
+}
+
+// mergeStats builds node stats of sources, parts of failed sources are taken
+// from prev so that rates of them are zero instead of wrong. Returns false if
+// some source failed without prev.
+func mergeStats(results map[string]*nodeStatsType, prev *nodeStatsType) (nodeStatsType, bool) {
+	merged := nodeStatsType{podStats: make(map[string]podStatType)}
+	if len(results) != len(statsSources) && prev == nil {
+		return merged, false
+	}
+	if r, ok := results[cpuSource]; ok {
+		merged.cpuUsage = r.cpuUsage
+	} else {
+		merged.cpuUsage = prev.cpuUsage
+	}
+	if r, ok := results[memorySource]; ok {
+		merged.memoryUsage = r.memoryUsage
+	} else {
+		merged.memoryUsage = prev.memoryUsage
+	}
+	if r, ok := results[networkSource]; ok {
+		merged.netIOStats = r.netIOStats
+		merged.overlayIOStats = r.overlayIOStats
+	} else {
+		merged.netIOStats = prev.netIOStats
+		merged.overlayIOStats = prev.overlayIOStats
+	}
+	if r, ok := results[podSource]; ok {
+		merged.podStats = r.podStats
+	} else {
+		merged.podStats = prev.podStats
+	}
+	if r, ok := results[reclaimSource]; ok {
+		merged.reclaimStats = r.reclaimStats
+	} else {
+		merged.reclaimStats = prev.reclaimStats
+	}
+	if r, ok := results[writebackSource]; ok {
+		merged.writebackStats = r.writebackStats
+		merged.podWriteback = r.podWriteback
+	} else {
+		merged.writebackStats = prev.writebackStats
+		merged.podWriteback = prev.podWriteback
+	}
+	if r, ok := results[portsSource]; ok {
+		merged.portStats = r.portStats
+		merged.podSockets = r.podSockets
+	} else {
+		merged.portStats = prev.portStats
+		merged.podSockets = prev.podSockets
+	}
+	// node disk io includes user pods, both of them are needed
+	disk, diskOK := results[diskSource]
+	_, podOK := results[podSource]
+	if diskOK && podOK {
+		merged.diskIOStats = disk.diskIOStats
+		for _, pod := range merged.podStats {
+			merged.diskIOStats.rx += pod.diskIOStats.rx
+			merged.diskIOStats.tx += pod.diskIOStats.tx
+		}
+	} else {
+		merged.diskIOStats = prev.diskIOStats
+	}
+	return merged, true
+}
+
+func collectCPU(stats *summary.ConditionStats, in collectInput, out *nodeStatsType) {
+	if stats.NodeCPUStats != nil && stats.NodeCPUStats.UsageNanoCores != nil {
+		out.cpuUsage = float64(*stats.NodeCPUStats.UsageNanoCores) / 1e9
+	}
+}
+
+func collectMemory(stats *summary.ConditionStats, in collectInput, out *nodeStatsType) {
+	if stats.NodeMemoryStats != nil && stats.NodeMemoryStats.UsageBytes != nil {
+		out.memoryUsage = *stats.NodeMemoryStats.UsageBytes
+	}
+}
+
+// collectNetwork sums io of the configured interfaces, and of overlay
+// interfaces separately
+func collectNetwork(stats *summary.ConditionStats, in collectInput, out *nodeStatsType) {
+	netStats := stats.NodeNetStats
+	out.netIOStats.time = netStats.Time.Time
+	out.overlayIOStats.time = netStats.Time.Time
+	for _, netName := range in.networkInterfaces {
+		out.netIOStats.name += netName + "."
+	}
+	for _, iface := range netStats.Interfaces {
+		if iface.RxBytes == nil || iface.TxBytes == nil {
+			continue
+		}
+		for _, netName := range in.networkInterfaces {
+			if iface.Name == netName {
+				out.netIOStats.rx += *iface.RxBytes
+				out.netIOStats.tx += *iface.TxBytes
+			}
+		}
+		for _, netName := range in.overlayInterfaces {
+			if iface.Name == netName {
+				out.overlayIOStats.rx += *iface.RxBytes
+				out.overlayIOStats.tx += *iface.TxBytes
+			}
+		}
+	}
+}
+
+// collectDisk sums io of system containers, user pods are added by mergeStats
+func collectDisk(stats *summary.ConditionStats, in collectInput, out *nodeStatsType) {
+	out.diskIOStats.time = stats.NodeDiskIoStats.Time.Time
+	for _, container := range stats.SysContainers {
+		if container.Diskio == nil || container.Diskio.DiskIoStats == nil {
+			continue
+		}
+		disk := diskIOStat(container.Diskio.DiskIoStats.IoServiced, in.diskDevName, in.diskTopology)
+		if disk.name != "" {
+			out.diskIOStats.name = disk.name
+		}
+		out.diskIOStats.rx += disk.rx
+		out.diskIOStats.tx += disk.tx
+	}
+}
+
+// collectPods collects stats of all pods, keyed by PodNamespace.Name
+func collectPods(stats *summary.ConditionStats, in collectInput, out *nodeStatsType) {
+	keys := make([]string, 0, len(stats.PodStats))
+	for _, pod := range stats.PodStats {
+		keys = append(keys, pod.PodRef.Namespace+"."+pod.PodRef.Name)
+	}
+	kept := keptPods(podSource, keys, in.maxPods)
+	var pods []int
+	for i, pod := range stats.PodStats {
+		if kept == nil || kept[pod.PodRef.Namespace+"."+pod.PodRef.Name] {
+			pods = append(pods, i)
+		}
+	}
+	out.podStats = make(map[string]podStatType, len(pods))
+	attributePods(podSource, stats, pods, in, func(i int) (interface{}, error) {
+		return podStatOf(stats, i, in.diskDevName, in.diskTopology), nil
+	}, func(i int, value interface{}) {
+		podStat := value.(podStatType)
+		out.podStats[podStat.namespace+"."+podStat.name] = podStat
+	})
+}
+
+// attributePods runs attribute of the pods at indexes of stats.PodStats, at
+// most in.podWorkers of them at once. A pod whose attribute fails, panics or
+// is not done in in.podTimeout, e.g. of a hung read of its cgroup or /proc,
+// is counted, abandoned and left out, so that it doesn't block the others.
+// keep is called with the value of each pod done, one at a time.
+func attributePods(source string, stats *summary.ConditionStats, pods []int, in collectInput,
+	attribute func(i int) (interface{}, error), keep func(i int, value interface{})) {
+	workers := in.podWorkers
+	if workers <= 0 || workers > len(pods) {
+		workers = len(pods)
+	}
+	var (
+		lock sync.Mutex
+		wg   sync.WaitGroup
+		next = make(chan int)
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				value, err := attributePod(attribute, i, in)
+				if err != nil {
+					podStatsErrors.Inc(source)
+					log.Warnf("collect %s stats of pod %s/%s error: %v", source,
+						stats.PodStats[i].PodRef.Namespace, stats.PodStats[i].PodRef.Name, err)
+					continue
+				}
+				lock.Lock()
+				keep(i, value)
+				lock.Unlock()
+			}
+		}()
+	}
+	for _, i := range pods {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+}
+
+// attributePod runs attribute of the i-th pod in podTimeout, it's abandoned
+// if timed out or panics, its value is never read then
+func attributePod(attribute func(i int) (interface{}, error), i int, in collectInput) (interface{}, error) {
+	type result struct {
+		value interface{}
+		err   error
+	}
+	done := make(chan result, 1)
+	run := func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- result{err: fmt.Errorf("panic: %v", r)}
+			}
+		}()
+		value, err := attribute(i)
+		done <- result{value: value, err: err}
+	}
+	if in.podTimeout <= 0 || in.clock == nil {
+		run()
+		r := <-done
+		return r.value, r.err
+	}
+	go run()
+	select {
+	case r := <-done:
+		return r.value, r.err
+	case <-in.clock.After(in.podTimeout):
+		return nil, fmt.Errorf("timed out after %v", in.podTimeout)
+	}
+}
+
+// podStatOf sums stats of containers of the i-th pod
+func podStatOf(stats *summary.ConditionStats, i int, diskDevName string, topology *diskTopology) podStatType {
+	pod := &stats.PodStats[i]
+	podStat := podStatType{
+		name:      pod.PodRef.Name,
+		namespace: pod.PodRef.Namespace,
+		uid:       pod.PodRef.UID,
+		time:      pod.StartTime.Time,
+	}
+	// Sum all containers' stats together
+	// Maybe some pod doesn't has DiskIoStats, set them to ZERO
+	podStat.containers = make(map[string]containerStatType, len(pod.Containers))
+	for _, container := range pod.Containers {
+		var containerStat containerStatType
+		if container.CPU != nil && container.CPU.UsageNanoCores != nil {
+			containerStat.cpuUsage = float64(*container.CPU.UsageNanoCores) / 1e9
+		}
+		if container.Memory != nil && container.Memory.UsageBytes != nil {
+			containerStat.memoryUsage = *container.Memory.UsageBytes
+		}
+		podStat.containers[container.Name] = containerStat
+		if container.Diskio == nil || container.Diskio.DiskIoStats == nil {
+			continue
+		}
+		disk := diskIOStat(container.Diskio.DiskIoStats.IoServiced, diskDevName, topology)
+		if disk.name == "" {
+			continue
+		}
+		containerStat.diskIOStats = disk
+		containerStat.diskIOStats.time = container.Diskio.Time.Time
+		podStat.containers[container.Name] = containerStat
+		podStat.diskIOStats.name = disk.name
+		podStat.diskIOStats.time = container.Diskio.Time.Time
+		podStat.diskIOStats.rx += disk.rx
+		podStat.diskIOStats.tx += disk.tx
+	}
+	if pod.Network != nil {
+		podStat.netIOStats.name = pod.Network.Name
+		podStat.netIOStats.time = pod.Network.Time.Time
+		if pod.Network.RxBytes != nil && pod.Network.TxBytes != nil {
+			podStat.netIOStats.rx = *pod.Network.RxBytes
+			podStat.netIOStats.tx = *pod.Network.TxBytes
+		}
+	}
+	if pod.CPU != nil && pod.CPU.UsageNanoCores != nil {
+		podStat.cpuUsage = float64(*pod.CPU.UsageNanoCores) / 1e9
+	}
+	if pod.Memory != nil && pod.Memory.UsageBytes != nil {
+		podStat.memoryUsage = *pod.Memory.UsageBytes
+	}
+	return podStat
+}
+
+// diskIOStat returns reads and writes of device, or of the first device if
+// device is empty, or of the physical devices of topology if it's resolved
+func diskIOStat(ioServiced []cadvisorapiv1.PerDiskStats, device string, topology *diskTopology) statType {
+	if topology != nil {
+		return topology.stat(ioServiced)
+	}
+	disk := statType{name: device}
+	for i, io := range ioServiced {
+		if device == "" && i == 0 {
+			disk.name = io.Device
+		} else if device == "" || io.Device != device {
+			continue
+		}
+		disk.rx += io.Stats["Read"]
+		disk.tx += io.Stats["Write"]
+	}
+	return disk
+}
@@ -0,0 +1,70 @@
+package condition
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/clock"
+	statsapi "k8s.io/kubernetes/pkg/kubelet/apis/stats/v1alpha1"
+
+	"eviction-agent/pkg/summary"
+)
+
+func TestCollectStatsAbandonsHungSource(t *testing.T) {
+	hung := make(chan struct{})
+	defer close(hung)
+	sources := []statsSource{
+		{name: "hung", collect: func(*summary.ConditionStats, collectInput, *nodeStatsType) { <-hung }},
+		{name: "panics", collect: func(*summary.ConditionStats, collectInput, *nodeStatsType) { panic("no summary") }},
+		{name: cpuSource, collect: func(_ *summary.ConditionStats, _ collectInput, out *nodeStatsType) {
+			out.cpuUsage = 1
+		}},
+	}
+
+	const timeout = 100 * time.Millisecond
+	start := time.Now()
+	// one slot, so the hung source must release it for the others to run
+	results, errs := collectStats(clock.RealClock{}, timeout, 1, sources, &summary.ConditionStats{}, collectInput{})
+	if elapsed := time.Since(start); elapsed > 10*timeout {
+		t.Fatalf("collect takes %v with source timeout %v", elapsed, timeout)
+	}
+	if errs["hung"] == nil || errs["panics"] == nil {
+		t.Errorf("errors are %v, want errors of hung and panics", errs)
+	}
+	if r, ok := results[cpuSource]; !ok || r.cpuUsage != 1 {
+		t.Errorf("results are %v, want stats of %s", results, cpuSource)
+	}
+}
+
+func TestAttributePodsSkipsHungPod(t *testing.T) {
+	stats := &summary.ConditionStats{}
+	for _, name := range []string{"hung", "panics", "ok-1", "ok-2"} {
+		var pod statsapi.PodStats
+		pod.PodRef.Namespace, pod.PodRef.Name = "default", name
+		stats.PodStats = append(stats.PodStats, pod)
+	}
+	hung := make(chan struct{})
+	defer close(hung)
+
+	const timeout = 100 * time.Millisecond
+	in := collectInput{podWorkers: 2, podTimeout: timeout, clock: clock.RealClock{}}
+	kept := make(map[string]bool)
+	start := time.Now()
+	attributePods(podSource, stats, []int{0, 1, 2, 3}, in, func(i int) (interface{}, error) {
+		switch stats.PodStats[i].PodRef.Name {
+		case "hung":
+			<-hung
+		case "panics":
+			panic("no cgroup")
+		}
+		return stats.PodStats[i].PodRef.Name, nil
+	}, func(i int, value interface{}) {
+		kept[value.(string)] = true
+	})
+	if elapsed := time.Since(start); elapsed > 10*timeout {
+		t.Fatalf("attribution takes %v with pod timeout %v", elapsed, timeout)
+	}
+	if len(kept) != 2 || !kept["ok-1"] || !kept["ok-2"] {
+		t.Errorf("pods kept are %v, want ok-1 and ok-2", kept)
+	}
+}
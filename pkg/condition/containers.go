@@ -0,0 +1,104 @@
+package condition
+
+import (
+	"strings"
+
+	"eviction-agent/pkg/types"
+)
+
+// containerStatType is the usage of a container of a pod
+type containerStatType struct {
+	cpuUsage    float64
+	memoryUsage uint64
+	diskIOStats statType
+}
+
+// containerUsages returns the usage of the resource of evictType by containers
+// of the pod of keyName, io usages are computed from the last two stats. Returns
+// nil if the resource is not measured by container, e.g. network of the pod
+// sandbox. statsLock must be held.
+func (c *conditionManager) containerUsages(evictType, keyName string) map[string]float64 {
+	if c.nodeStats.len() == 0 {
+		return nil
+	}
+	newStats, ok := c.nodeStats.last().podStats[keyName]
+	if !ok {
+		return nil
+	}
+	var lastStats podStatType
+	if evictType == types.DiskIO {
+		if c.nodeStats.len() < 2 {
+			return nil
+		}
+		if lastStats, ok = c.nodeStats.at(c.nodeStats.len() - 2).podStats[keyName]; !ok {
+			return nil
+		}
+	}
+	usages := make(map[string]float64, len(newStats.containers))
+	for name, container := range newStats.containers {
+		switch evictType {
+		case types.CPUBusy:
+			usages[name] = container.cpuUsage
+		case types.MemBusy:
+			usages[name] = float64(container.memoryUsage)
+		case types.DiskIO:
+			last, ok := lastStats.containers[name]
+			if !ok {
+				continue
+			}
+			duration := float64(container.diskIOStats.time.UnixNano() - last.diskIOStats.time.UnixNano())
+			if duration <= 0 {
+				continue
+			}
+			delta := float64(container.diskIOStats.rx+container.diskIOStats.tx) -
+				float64(last.diskIOStats.rx+last.diskIOStats.tx)
+			usages[name] = 1e9 * delta / duration
+		default:
+			return nil
+		}
+	}
+	return usages
+}
+
+// offenderContainer returns the container using the most of the resource of
+// evictType in the pod of keyName, empty if pod has a single container or
+// the resource is not measured by container. statsLock must be held.
+func (c *conditionManager) offenderContainer(evictType, keyName string) string {
+	usages := c.containerUsages(evictType, keyName)
+	if len(usages) < 2 {
+		return ""
+	}
+	offender, most := "", 0.0
+	for name, usage := range usages {
+		if usage > most || (usage == most && offender != "" && name < offender) {
+			offender, most = name, usage
+		}
+	}
+	return offender
+}
+
+// setOffenderContainers sets the offender containers of candidates of ranking,
+// statsLock must be held
+func (c *conditionManager) setOffenderContainers(evictType string, ranking *Ranking) {
+	for i := range ranking.Candidates {
+		pod := &ranking.Candidates[i].Pod
+		pod.Container = c.offenderContainer(evictType, pod.Namespace+"."+pod.Name)
+	}
+}
+
+// infraUsage returns the usage of the resource of evictType by infrastructure
+// containers of the pod of keyName not counted for its score, images of
+// containers are of profiles. statsLock and policyLock must be held.
+func (c *conditionManager) infraUsage(evictType, keyName string, profiles map[string]types.PodProfile) float64 {
+	if !c.infraContainers.Enabled() {
+		return 0
+	}
+	images := profiles[strings.Replace(keyName, ".", "/", 1)].Images
+	discounted := 0.0
+	for name, usage := range c.containerUsages(evictType, keyName) {
+		if c.infraContainers.Matches(name, images[name]) {
+			discounted += usage
+		}
+	}
+	return discounted * c.infraContainers.DiscountRatio()
+}
@@ -0,0 +1,105 @@
+package condition
+
+import (
+	"strings"
+	"time"
+)
+
+// IOSample is a snapshot of accumulated io stats
+type IOSample struct {
+	Time time.Time `json:"time"`
+	Name string    `json:"name"`
+	Rx   uint64    `json:"rx"`
+	Tx   uint64    `json:"tx"`
+}
+
+// PodSample is a snapshot of pod stats
+type PodSample struct {
+	CPUUsage    float64  `json:"cpuUsage"`
+	MemoryUsage uint64   `json:"memoryUsage"`
+	NetworkIO   IOSample `json:"networkIO"`
+	DiskIO      IOSample `json:"diskIO"`
+}
+
+// StatsSample is a snapshot of node stats, used for debugging
+type StatsSample struct {
+	Time        time.Time            `json:"time"`
+	CPUUsage    float64              `json:"cpuUsage"`
+	MemoryUsage uint64               `json:"memoryUsage"`
+	NetworkIO   IOSample             `json:"networkIO"`
+	DiskIO      IOSample             `json:"diskIO"`
+	Pods        map[string]PodSample `json:"pods"`
+}
+
+func newIOSample(stat statType) IOSample {
+	return IOSample{
+		Time: stat.time,
+		Name: stat.name,
+		Rx:   stat.rx,
+		Tx:   stat.tx,
+	}
+}
+
+// GetStatsSamples returns snapshots of stats in memory, the oldest first
+func (c *conditionManager) GetStatsSamples() []StatsSample {
+	c.statsLock.RLock()
+	defer c.statsLock.RUnlock()
+
+	samples := make([]StatsSample, 0, c.nodeStats.len())
+	for i := 0; i < c.nodeStats.len(); i++ {
+		samples = append(samples, newStatsSample(c.nodeStats.at(i)))
+	}
+	return samples
+}
+
+func newStatsSample(stats *nodeStatsType) StatsSample {
+	sample := StatsSample{
+		Time:        stats.time,
+		CPUUsage:    stats.cpuUsage,
+		MemoryUsage: stats.memoryUsage,
+		NetworkIO:   newIOSample(stats.netIOStats),
+		DiskIO:      newIOSample(stats.diskIOStats),
+		Pods:        make(map[string]PodSample, len(stats.podStats)),
+	}
+	for key, pod := range stats.podStats {
+		sample.Pods[key] = PodSample{
+			CPUUsage:    pod.cpuUsage,
+			MemoryUsage: pod.memoryUsage,
+			NetworkIO:   newIOSample(pod.netIOStats),
+			DiskIO:      newIOSample(pod.diskIOStats),
+		}
+	}
+	return sample
+}
+
+func (s IOSample) stat() statType {
+	return statType{time: s.Time, name: s.Name, rx: s.Rx, tx: s.Tx}
+}
+
+// nodeStats returns the node stats of sample, pods are keyed by namespace.name
+func (s *StatsSample) nodeStats() nodeStatsType {
+	stats := nodeStatsType{
+		time:        s.Time,
+		cpuUsage:    s.CPUUsage,
+		memoryUsage: s.MemoryUsage,
+		netIOStats:  s.NetworkIO.stat(),
+		diskIOStats: s.DiskIO.stat(),
+		podStats:    make(map[string]podStatType, len(s.Pods)),
+	}
+	for key, pod := range s.Pods {
+		parts := strings.SplitN(key, ".", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		stats.podStats[key] = podStatType{
+			time:        s.Time,
+			namespace:   parts[0],
+			name:        parts[1],
+			cpuUsage:    pod.CPUUsage,
+			memoryUsage: pod.MemoryUsage,
+			netIOStats:  pod.NetworkIO.stat(),
+			diskIOStats: pod.DiskIO.stat(),
+		}
+	}
+	return stats
+}
@@ -0,0 +1,120 @@
+package condition
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"eviction-agent/pkg/config"
+	"eviction-agent/pkg/types"
+)
+
+// Diagnosis is the conditions of node compared with thresholds, and the
+// ranked candidates of each of them
+type Diagnosis struct {
+	Time       time.Time              `json:"time"`
+	Conditions []ConditionDiagnosis   `json:"conditions"`
+	Daemons    map[string]DaemonShare `json:"daemons,omitempty"`
+}
+
+// ConditionDiagnosis is the measured value of an evict type compared with its threshold
+type ConditionDiagnosis struct {
+	EvictType string  `json:"evictType"`
+	Value     float64 `json:"value"`
+	Threshold float64 `json:"threshold"`
+	Available bool    `json:"available"`
+	Disabled  bool    `json:"disabled"`
+	// Candidates are ranked as if the evict type is busy, the first one would be chosen
+	Candidates []Candidate `json:"candidates"`
+	Excluded   []Exclusion `json:"excluded,omitempty"`
+}
+
+// Diagnose gets totals of node, loads policy configuration and collects stats
+// twice in interval for io rates, without watchers or stats sync
+func (c *conditionManager) Diagnose(ctx context.Context, interval time.Duration) (*Diagnosis, error) {
+	if err := c.init(); err != nil {
+		return nil, err
+	}
+	for i := 0; i < minStatsBufferLen; i++ {
+		if i != 0 && !c.sleep(ctx, interval) {
+			return nil, ctx.Err()
+		}
+		if err := c.syncStatsOnce(ctx); err != nil {
+			return nil, err
+		}
+	}
+	c.statsLock.RLock()
+	n := c.nodeStats.len()
+	updated := n == minStatsBufferLen && c.nodeStats.last().time.After(c.nodeStats.at(n-2).time)
+	c.statsLock.RUnlock()
+	if !updated {
+		return nil, fmt.Errorf("stats are not updated by kubelet in %v, try a longer interval", interval)
+	}
+	// two stats are enough for rates
+	atomic.StoreInt32(&c.synced, 1)
+	nodeCondition := *c.GetNodeCondition()
+
+	lowPriorityPods, err := c.client.GetLowerPriorityPods(c.getLowPriorityThreshold())
+	if err != nil {
+		return nil, err
+	}
+	protected, err := c.protectedPods()
+	if err != nil {
+		return nil, err
+	}
+	profiles, err := c.podProfiles()
+	if err != nil {
+		return nil, err
+	}
+	c.statsLock.RLock()
+	defer c.statsLock.RUnlock()
+	c.policyLock.RLock()
+	defer c.policyLock.RUnlock()
+	pods := c.filterProtectedPods(lowPriorityPods, protected)
+	diagnosis := &Diagnosis{Time: c.nodeStats.last().time, Daemons: nodeCondition.Daemons}
+	add := func(evictType, condition string, value, threshold float64, available bool) {
+		ranking := c.rankCandidates(evictType, pods, protected, profiles)
+		diagnosis.Conditions = append(diagnosis.Conditions, ConditionDiagnosis{
+			EvictType:  evictType,
+			Value:      value,
+			Threshold:  threshold,
+			Available:  available,
+			Disabled:   c.disabledConditions[condition],
+			Candidates: ranking.Candidates,
+			Excluded:   ranking.Excluded,
+		})
+	}
+	cpuUsage, _ := c.checkedUsage(config.CPUCondition, nodeCondition.CPUUsage, float64(c.cpuTotal),
+		nodeCondition.Daemons)
+	memoryUsage, _ := c.checkedUsage(config.MemoryCondition, float64(nodeCondition.MemoryUsage),
+		float64(c.memTotal), nodeCondition.Daemons)
+	thresholds := nodeCondition.Thresholds
+	add(types.CPUBusy, config.CPUCondition, cpuUsage, thresholds[types.CPUBusy], nodeCondition.CPUAvailable)
+	add(types.MemBusy, config.MemoryCondition, memoryUsage, thresholds[types.MemBusy], nodeCondition.MemoryAvailable)
+	add(types.DiskIO, config.DiskIOCondition, nodeCondition.DiskIOPS, thresholds[types.DiskIO],
+		nodeCondition.DiskIOAvailable)
+	add(types.NetworkRxBusy, config.NetworkIOCondition, nodeCondition.NetworkRxBps, thresholds[types.NetworkRxBusy],
+		nodeCondition.NetworkRxAvailable)
+	add(types.NetworkTxBusy, config.NetworkIOCondition, nodeCondition.NetworkTxBps, thresholds[types.NetworkTxBusy],
+		nodeCondition.NetworkTxAvailable)
+	if w := nodeCondition.Writeback; w != nil {
+		add(types.WritebackBusy, config.WritebackCondition, float64(w.DirtyBytes+w.WritebackBytes), w.Threshold, !w.Busy)
+	}
+	if p := nodeCondition.EphemeralPorts; p != nil {
+		add(types.EphemeralPortsBusy, config.EphemeralPortsCondition, float64(p.Used), p.Threshold, !p.Busy)
+	}
+
+	var resources []string
+	for resource := range nodeCondition.Extended {
+		resources = append(resources, resource)
+	}
+	sort.Strings(resources)
+	for _, resource := range resources {
+		extended := nodeCondition.Extended[resource]
+		add(types.ExtendedResourceTaintKey(resource), resource, extended.Usage,
+			c.extendedThresholds[resource].Value(extended.Allocatable), extended.Available)
+	}
+	return diagnosis, nil
+}
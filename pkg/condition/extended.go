@@ -0,0 +1,154 @@
+package condition
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/tracing"
+)
+
+// extendedSource is the stats source of extended resources
+const extendedSource = "extended"
+
+// ExtendedResourceCondition is the condition of an extended resource of device plugins
+type ExtendedResourceCondition struct {
+	Allocatable float64
+	Usage       float64
+	Available   bool
+}
+
+// extendedUsage is the json of usage url, pods are keyed by namespace/name.
+// Node usage is the sum of pods if it's not given.
+type extendedUsage struct {
+	Node map[string]float64            `json:"node"`
+	Pods map[string]map[string]float64 `json:"pods"`
+}
+
+// extendedStats are the latest usage and allocatable of extended resources
+type extendedStats struct {
+	time        time.Time
+	allocatable map[string]float64
+	node        map[string]float64
+	pods        map[string]map[string]float64 // key=PodNamespace.Name
+}
+
+// syncExtendedResources gets usage and allocatable of extended resources,
+// the last ones are kept if it fails
+func (c *conditionManager) syncExtendedResources(ctx context.Context) {
+	if len(c.extendedThresholds) == 0 {
+		return
+	}
+	ctx, span := tracing.StartClient(ctx, "extended.usage")
+	defer span.End()
+	stats, err := c.getExtendedStats(ctx)
+	span.SetError(err)
+	if err != nil {
+		statsSourceErrors.Inc(extendedSource)
+		log.Errorf("sync extended resources error: %v", err)
+		return
+	}
+	c.statsLock.Lock()
+	c.extended = stats
+	c.updateStatsMemory()
+	c.statsLock.Unlock()
+}
+
+func (c *conditionManager) getExtendedStats(ctx context.Context) (extendedStats, error) {
+	allocatable, err := c.client.WithContext(ctx).GetNodeAllocatable()
+	if err != nil {
+		return extendedStats{}, fmt.Errorf("get node allocatable: %v", err)
+	}
+	req, err := http.NewRequest("GET", c.extendedUsageURL, nil)
+	if err != nil {
+		return extendedStats{}, err
+	}
+	tracing.Inject(ctx, req.Header)
+	resp, err := c.extendedClient.Do(req)
+	if err != nil {
+		return extendedStats{}, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return extendedStats{}, err
+	}
+	if resp.StatusCode/100 != 2 {
+		return extendedStats{}, fmt.Errorf("get %s: unexpected status %s", c.extendedUsageURL, resp.Status)
+	}
+	var usage extendedUsage
+	if err := json.Unmarshal(body, &usage); err != nil {
+		return extendedStats{}, fmt.Errorf("decode usage of %s: %v", c.extendedUsageURL, err)
+	}
+
+	stats := extendedStats{
+		time:        c.clock.Now(),
+		allocatable: allocatable,
+		node:        make(map[string]float64),
+		pods:        make(map[string]map[string]float64),
+	}
+	keys := make([]string, 0, len(usage.Pods))
+	for pod := range usage.Pods {
+		keys = append(keys, pod)
+	}
+	kept := keptPods(extendedSource, keys, c.maxPodStats)
+	for pod, usages := range usage.Pods {
+		if kept == nil || kept[pod] {
+			stats.pods[strings.Replace(pod, "/", ".", 1)] = usages
+		}
+	}
+	for resource := range c.extendedThresholds {
+		if value, ok := usage.Node[resource]; ok {
+			stats.node[resource] = value
+			continue
+		}
+		for _, usages := range usage.Pods {
+			if value, ok := usages[resource]; ok {
+				stats.node[resource] += value
+			}
+		}
+	}
+	return stats, nil
+}
+
+// extendedConditions returns conditions of extended resources, a resource is
+// available if its usage or allocatable is unknown, or usage is not synced for
+// statsBufferLen periods. statsLock must be held.
+func (c *conditionManager) extendedConditions() map[string]ExtendedResourceCondition {
+	if len(c.extendedThresholds) == 0 {
+		return nil
+	}
+	fresh := c.clock.Since(c.extended.time) <= statsBufferLen*c.samplingPeriod()
+	conditions := make(map[string]ExtendedResourceCondition)
+	for resource, threshold := range c.extendedThresholds {
+		usage, ok := c.extended.node[resource]
+		resourceCondition := ExtendedResourceCondition{
+			Allocatable: c.extended.allocatable[resource],
+			Usage:       usage,
+			Available:   true,
+		}
+		if fresh && ok && resourceCondition.Allocatable > 0 &&
+			usage > threshold.Value(resourceCondition.Allocatable) {
+			log.Infof("extended resource %s out of limits, usage: %v, allocatable: %v",
+				resource, usage, resourceCondition.Allocatable)
+			resourceCondition.Available = false
+		}
+		conditions[resource] = resourceCondition
+	}
+	return conditions
+}
+
+// extendedPodUsage returns usage of resource of pod, pods not in usage use
+// nothing. Returns false if usage is not synced for statsBufferLen periods.
+// statsLock must be held.
+func (c *conditionManager) extendedPodUsage(resource, keyName string) (float64, bool) {
+	if c.clock.Since(c.extended.time) > statsBufferLen*c.samplingPeriod() {
+		return 0, false
+	}
+	return c.extended.pods[keyName][resource], true
+}
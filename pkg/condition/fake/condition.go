@@ -0,0 +1,311 @@
+// Package fake provides a scriptable condition.ConditionManager for testing
+// eviction flows without kubelet stats.
+package fake
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/clock"
+
+	"eviction-agent/pkg/condition"
+	"eviction-agent/pkg/types"
+)
+
+var _ condition.ConditionManager = &ConditionManager{}
+
+// ConditionManager is a fake condition manager returning conditions and
+// candidates set by test. It's synced and all conditions are available until
+// they are set.
+type ConditionManager struct {
+	lock       sync.Mutex
+	clock      clock.Clock // stamps the last sync
+	condition  condition.NodeCondition
+	synced     bool
+	lastSync   time.Time
+	candidates map[string][]condition.Candidate
+	autoEvict  bool
+	disabled   map[string]bool
+	actions    map[string]bool // disabled actions
+	skipped    map[string]bool
+	grace      time.Duration
+	ranking    condition.Ranking // of the last ChooseOnePodToEvict
+	chosen     []string
+	drain      []types.PodInfo
+	evicted    []types.PodInfo
+}
+
+// NewConditionManager creates a synced fake condition manager of a healthy node
+func NewConditionManager() *ConditionManager {
+	return NewConditionManagerWithClock(clock.RealClock{})
+}
+
+// NewConditionManagerWithClock creates a synced fake condition manager of a
+// healthy node, syncs are at the time of clk, e.g. the fake clock of eviction
+// manager
+func NewConditionManagerWithClock(clk clock.Clock) *ConditionManager {
+	return &ConditionManager{
+		clock: clk,
+		condition: condition.NodeCondition{
+			CPUAvailable:       true,
+			MemoryAvailable:    true,
+			DiskIOAvailable:    true,
+			NetworkRxAvailable: true,
+			NetworkTxAvailable: true,
+		},
+		synced:     true,
+		lastSync:   clk.Now(),
+		candidates: make(map[string][]condition.Candidate),
+		disabled:   make(map[string]bool),
+		actions:    make(map[string]bool),
+		skipped:    make(map[string]bool),
+		grace:      time.Minute,
+	}
+}
+
+// SetCondition sets the node condition returned by GetNodeCondition
+func (m *ConditionManager) SetCondition(nodeCondition condition.NodeCondition) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.condition = nodeCondition
+	m.lastSync = m.clock.Now()
+}
+
+// Update changes the node condition returned by GetNodeCondition, e.g.
+// func(c *condition.NodeCondition) { c.CPUAvailable = false }
+func (m *ConditionManager) Update(update func(*condition.NodeCondition)) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	update(&m.condition)
+	m.lastSync = m.clock.Now()
+}
+
+// SetSynced sets whether there are enough stats
+func (m *ConditionManager) SetSynced(synced bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.synced = synced
+}
+
+// SetCandidates sets candidates of evictType, the one of the highest score is chosen
+func (m *ConditionManager) SetCandidates(evictType string, candidates ...condition.Candidate) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	sorted := append([]condition.Candidate(nil), candidates...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Score > sorted[j].Score })
+	m.candidates[evictType] = sorted
+}
+
+// SetAutoEvict sets whether lower priority pods are evicted instead of labeled
+func (m *ConditionManager) SetAutoEvict(autoEvict bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.autoEvict = autoEvict
+}
+
+// SetActionDisabled disables or enables an action of policy, e.g. Evict
+func (m *ConditionManager) SetActionDisabled(action string, disabled bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.actions[action] = disabled
+}
+
+// SetDisabled disables or enables a condition, e.g. CPU
+func (m *ConditionManager) SetDisabled(conditionType string, disabled bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.disabled[conditionType] = disabled
+}
+
+// SetUnTaintGracePeriod sets the untaint grace period, a minute by default
+func (m *ConditionManager) SetUnTaintGracePeriod(grace time.Duration) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.grace = grace
+}
+
+// SetDrainCandidates sets pods returned by GetDrainCandidates
+func (m *ConditionManager) SetDrainCandidates(pods ...types.PodInfo) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.drain = append([]types.PodInfo(nil), pods...)
+}
+
+// Evicted returns pods of RecordEviction calls so far
+func (m *ConditionManager) Evicted() []types.PodInfo {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return append([]types.PodInfo(nil), m.evicted...)
+}
+
+// Chosen returns evict types of ChooseOnePodToEvict calls so far
+func (m *ConditionManager) Chosen() []string {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return append([]string(nil), m.chosen...)
+}
+
+// Start does nothing, conditions are set by test
+func (m *ConditionManager) Start(ctx context.Context) error {
+	return nil
+}
+
+func (m *ConditionManager) GetNodeCondition() *condition.NodeCondition {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	nodeCondition := m.condition
+	return &nodeCondition
+}
+
+// ChooseOnePodToEvict chooses the first candidate of evictType not in skipped
+// namespaces, it's evicted if auto evict is set and it's a lower priority pod
+func (m *ConditionManager) ChooseOnePodToEvict(request types.Condition) (*types.PodInfo, bool, string, error) {
+	evictType := request.Type
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.chosen = append(m.chosen, evictType)
+	if !m.synced {
+		return nil, false, "", fmt.Errorf("wait for a minute")
+	}
+	m.ranking = condition.Ranking{}
+	for _, candidate := range m.candidates[evictType] {
+		if m.skipped[candidate.Pod.Namespace] {
+			m.ranking.Excluded = append(m.ranking.Excluded, condition.Exclusion{
+				Pod:    candidate.Pod.Namespace + "/" + candidate.Pod.Name,
+				Reason: condition.ExcludedTenantBudget,
+			})
+			continue
+		}
+		m.ranking.Candidates = append(m.ranking.Candidates, candidate)
+	}
+	if len(m.ranking.Candidates) == 0 {
+		return nil, false, "", fmt.Errorf("no pod to evict for %s", evictType)
+	}
+	chosen := m.ranking.Candidates[0]
+	pod := chosen.Pod
+	return &pod, m.autoEvict && chosen.Label == types.NeedEvict, chosen.Label, nil
+}
+
+func (m *ConditionManager) GetLastRanking() condition.Ranking {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.ranking
+}
+
+func (m *ConditionManager) GetUnTaintGracePeriod() time.Duration {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.grace
+}
+
+func (m *ConditionManager) HasSynced() bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.synced
+}
+
+func (m *ConditionManager) LastSyncTime() time.Time {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.lastSync
+}
+
+// StatsCycle returns the last sync time and a period of 10 seconds
+func (m *ConditionManager) StatsCycle() (time.Time, time.Duration) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.lastSync, 10 * time.Second
+}
+
+// RestartStatsSync sets the last sync time to now
+func (m *ConditionManager) RestartStatsSync() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.lastSync = m.clock.Now()
+}
+
+// GetStatsSamples returns no samples, there are no stats
+func (m *ConditionManager) GetStatsSamples() []condition.StatsSample {
+	return nil
+}
+
+func (m *ConditionManager) GetEvictionCandidates(evictType string) ([]condition.Candidate, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return append([]condition.Candidate(nil), m.candidates[evictType]...), nil
+}
+
+func (m *ConditionManager) GetDrainCandidates() ([]types.PodInfo, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return append([]types.PodInfo(nil), m.drain...), nil
+}
+
+// GetTopPods returns the top k candidates of each resource by usage
+func (m *ConditionManager) GetTopPods(k int) (*condition.TopPods, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	top := func(evictType string) []condition.PodUsage {
+		usages := []condition.PodUsage{}
+		for _, candidate := range m.candidates[evictType] {
+			usages = append(usages, condition.PodUsage{
+				Pod:   candidate.Pod.Namespace + "/" + candidate.Pod.Name,
+				Usage: candidate.Usage,
+			})
+		}
+		sort.SliceStable(usages, func(i, j int) bool { return usages[i].Usage > usages[j].Usage })
+		if len(usages) > k {
+			usages = usages[:k]
+		}
+		return usages
+	}
+	return &condition.TopPods{
+		CPU:     top(types.CPUBusy),
+		Memory:  top(types.MemBusy),
+		DiskIO:  top(types.DiskIO),
+		Network: top(types.NetworkRxBusy),
+	}, nil
+}
+
+func (m *ConditionManager) ConditionEnabled(conditionType string) bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return !m.disabled[conditionType]
+}
+
+func (m *ConditionManager) ActionEnabled(action string) bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return !m.actions[action]
+}
+
+func (m *ConditionManager) SetSkippedNamespaces(namespaces map[string]bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.skipped = make(map[string]bool, len(namespaces))
+	for namespace, skipped := range namespaces {
+		m.skipped[namespace] = skipped
+	}
+}
+
+// RecordEviction records pod, it's returned by Evicted
+func (m *ConditionManager) RecordEviction(pod types.PodInfo) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.evicted = append(m.evicted, pod)
+}
+
+// Fatal returns no error, stats never fail
+func (m *ConditionManager) Fatal() error {
+	return nil
+}
+
+// Diagnose returns the node condition without candidates
+func (m *ConditionManager) Diagnose(ctx context.Context, interval time.Duration) (*condition.Diagnosis, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return &condition.Diagnosis{Time: m.lastSync, Daemons: m.condition.Daemons}, nil
+}
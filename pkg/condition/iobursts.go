@@ -0,0 +1,83 @@
+package condition
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/types"
+)
+
+// defaultMaxIOBurst is the max duration of high io phases declared by pods if
+// maxIOBurst of policy is not set
+const defaultMaxIOBurst = 30 * time.Minute
+
+// ioBurstTypes are conditions of io for which pods in their declared high io
+// phases are not chosen
+var ioBurstTypes = map[string]bool{
+	types.DiskIO:        true,
+	types.WritebackBusy: true,
+}
+
+// ioBurst is a high io phase declared by a pod, e.g. a checkpoint of a
+// database, the pod is not counted as an offender for io until it ends
+type ioBurst struct {
+	declared types.IOBurst
+	start    time.Time
+	until    time.Time
+}
+
+// syncIOBursts gets high io phases declared by pods. A phase without start
+// time starts when its declaration is seen first, it's capped by maxIOBurst
+// of policy. The last ones are kept if it fails.
+func (c *conditionManager) syncIOBursts() {
+	declared, err := c.client.GetIOBursts()
+	if err != nil {
+		log.Errorf("sync high io phases of pods error: %v", err)
+		return
+	}
+	c.policyLock.RLock()
+	max := c.maxIOBurst
+	c.policyLock.RUnlock()
+	now := c.clock.Now()
+	c.statsLock.Lock()
+	defer c.statsLock.Unlock()
+	ioBursts := make(map[string]ioBurst, len(declared))
+	for pod, d := range declared {
+		keyName := strings.Replace(pod, "/", ".", 1)
+		burst, ok := c.ioBursts[keyName]
+		if !ok || burst.declared != d {
+			burst = ioBurst{declared: d, start: d.Start}
+			if burst.start.IsZero() {
+				burst.start = now
+			}
+			duration := d.Duration
+			if duration > max {
+				duration = max
+			}
+			burst.until = burst.start.Add(duration)
+			log.Infof("pod %s declares high io from %s until %s", pod,
+				burst.start.Format(time.RFC3339), burst.until.Format(time.RFC3339))
+		}
+		ioBursts[keyName] = burst
+	}
+	c.ioBursts = ioBursts
+}
+
+// ioBurstExcluded returns why pod is excluded if it's in its declared high io
+// phase and evictType is a condition of io. statsLock must be held.
+func (c *conditionManager) ioBurstExcluded(evictType, keyName string) (string, bool) {
+	if !ioBurstTypes[evictType] {
+		return "", false
+	}
+	burst, ok := c.ioBursts[keyName]
+	if !ok {
+		return "", false
+	}
+	now := c.clock.Now()
+	if now.Before(burst.start) || !now.Before(burst.until) {
+		return "", false
+	}
+	return fmt.Sprintf("declared high io until %s", burst.until.Format(time.RFC3339)), true
+}
@@ -0,0 +1,55 @@
+package condition
+
+import (
+	"strings"
+	"time"
+
+	"eviction-agent/pkg/log"
+)
+
+// jobProgressPeriod is the min interval of syncing progress of Jobs, which
+// gets Jobs without progress annotations on pods
+const jobProgressPeriod = time.Minute
+
+// minProgressWeight keeps pods of Jobs about to complete ranked, they are
+// chosen if no other pod consumes the resource
+const minProgressWeight = 0.01
+
+// syncJobProgress gets progress of pods of Jobs at most once per
+// jobProgressPeriod. The last ones are kept if it fails.
+func (c *conditionManager) syncJobProgress() {
+	if !c.jobCompletionAware {
+		return
+	}
+	now := c.clock.Now()
+	if now.Sub(c.jobProgressSyncTime) < jobProgressPeriod {
+		return
+	}
+	pods, err := c.client.GetJobProgress()
+	if err != nil {
+		log.Errorf("sync progress of jobs error: %v", err)
+		return
+	}
+	c.jobProgressSyncTime = now
+	jobProgress := make(map[string]float64, len(pods))
+	for pod, progress := range pods {
+		jobProgress[strings.Replace(pod, "/", ".", 1)] = progress
+	}
+	c.statsLock.Lock()
+	c.jobProgress = jobProgress
+	c.statsLock.Unlock()
+}
+
+// progressWeight returns the weight of score of pod by progress of its Job,
+// a Job closer to completion wastes more work once evicted. StatsLock must
+// be held.
+func (c *conditionManager) progressWeight(keyName string) float64 {
+	progress, ok := c.jobProgress[keyName]
+	if !ok {
+		return 1
+	}
+	if weight := 1 - progress; weight > minProgressWeight {
+		return weight
+	}
+	return minProgressWeight
+}
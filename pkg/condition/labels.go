@@ -0,0 +1,76 @@
+package condition
+
+import (
+	"eviction-agent/pkg/types"
+)
+
+// Reasons of the label of candidates. They are the contract with consumers of
+// evict labels, e.g. a cluster rebalancer: NeedsEviction pods should be moved
+// off the node, EvictionCandidate pods may be moved if the pressure stays.
+const (
+	// LabelLowPriority is a lower priority pod, it's NeedsEviction
+	LabelLowPriority = "LowPriority"
+	// LabelUsageShare is a pod using at least usageShare of labelPolicy, it's NeedsEviction
+	LabelUsageShare = "UsageShare"
+	// LabelQOSClass is a pod of qosClasses of labelPolicy, it's NeedsEviction
+	LabelQOSClass = "QOSClass"
+	// LabelOwnerKind is a pod of ownerKinds of labelPolicy, it's NeedsEviction
+	LabelOwnerKind = "OwnerKind"
+	// LabelDefault is a pod matching no rule, it's EvictionCandidate
+	LabelDefault = "Default"
+)
+
+// podProfiles returns QoS classes, controller kinds and images of pods if
+// label policy or infrastructure containers need them, otherwise nil
+func (c *conditionManager) podProfiles() (map[string]types.PodProfile, error) {
+	c.policyLock.RLock()
+	needed := c.labelPolicy.NeedsPods() || len(c.infraContainers.Images) > 0
+	c.policyLock.RUnlock()
+	if !needed {
+		return nil, nil
+	}
+	return c.client.GetPodProfiles()
+}
+
+// applyLabelPolicy sets labels of candidates by label policy, lower priority
+// pods are always NeedsEviction. statsLock and policyLock must be held.
+func (c *conditionManager) applyLabelPolicy(evictType string, ranking *Ranking, profiles map[string]types.PodProfile) {
+	policy := c.labelPolicy
+	total := 0.0
+	if policy.UsageShare > 0 {
+		for keyName := range c.nodeStats.last().podStats {
+			if usage, ok := c.podUsage(evictType, keyName, true); ok && usage > 0 {
+				total += usage
+			}
+		}
+	}
+	for i := range ranking.Candidates {
+		candidate := &ranking.Candidates[i]
+		if candidate.Label == types.NeedEvict {
+			candidate.LabelReason = LabelLowPriority
+			continue
+		}
+		profile := profiles[candidate.Pod.Namespace+"/"+candidate.Pod.Name]
+		switch {
+		case policy.UsageShare > 0 && total > 0 && candidate.Usage/total >= policy.UsageShare:
+			candidate.LabelReason = LabelUsageShare
+		case profile.QOSClass != "" && containsString(policy.QOSClasses, profile.QOSClass):
+			candidate.LabelReason = LabelQOSClass
+		case profile.OwnerKind != "" && containsString(policy.OwnerKinds, profile.OwnerKind):
+			candidate.LabelReason = LabelOwnerKind
+		default:
+			candidate.LabelReason = LabelDefault
+			continue
+		}
+		candidate.Label = types.NeedEvict
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
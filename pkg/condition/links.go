@@ -0,0 +1,84 @@
+package condition
+
+import (
+	"fmt"
+	"sort"
+
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/types"
+)
+
+// chooseLatencySensitivePod chooses the pod of LatencySensitiveAnnotation
+// consuming most of network, so that it's rescheduled to nodes of healthier
+// links. Other pods are never chosen, they are not hurt by degraded links.
+func (c *conditionManager) chooseLatencySensitivePod() (*types.PodInfo, bool, string, error) {
+	sensitive, err := c.client.GetLatencySensitivePods()
+	if err != nil {
+		return nil, false, "", err
+	}
+	protected, err := c.protectedPods()
+	if err != nil {
+		return nil, false, "", err
+	}
+	c.statsLock.RLock()
+	defer c.statsLock.RUnlock()
+	c.policyLock.RLock()
+	defer c.policyLock.RUnlock()
+	// the last pod chosen is still on node, it's evicting
+	if c.autoEvict && c.podToEvict.Name != "" && sensitive[c.podToEvict.Namespace+"/"+c.podToEvict.Name] {
+		if _, ok := c.nodeStats.last().podStats[c.podToEvict.Namespace+"."+c.podToEvict.Name]; ok {
+			return nil, false, "", fmt.Errorf("Pod: %v is evicting...", c.podToEvict.Name)
+		}
+	}
+	c.lastRanking = c.rankLatencySensitive(sensitive, protected)
+	if len(c.lastRanking.Candidates) == 0 {
+		c.podToEvict = types.PodInfo{}
+		return nil, false, "", fmt.Errorf("no latency sensitive pod to evict for %s", types.NetworkLinkDegraded)
+	}
+	evil := c.lastRanking.Candidates[0]
+	log.Infof("get latency sensitive pod: %v, network usage: %v, %s", evil.Pod.Name, evil.Usage, types.NetworkLinkDegraded)
+	c.podToEvict = evil.Pod
+	return &c.podToEvict, c.autoEvict, evil.Label, nil
+}
+
+// rankLatencySensitive returns sensitive pods on node ordered by weighted
+// network usage, pods without usage are chosen too. statsLock and policyLock
+// must be held.
+func (c *conditionManager) rankLatencySensitive(sensitive map[string]bool, protected map[string]string) Ranking {
+	var ranking Ranking
+	for keyName, stats := range c.nodeStats.last().podStats {
+		pod := stats.podInfo()
+		if !sensitive[pod.Namespace+"/"+pod.Name] {
+			continue
+		}
+		if reason := c.protectedReason(pod, protected); reason != "" {
+			ranking.exclude(pod, reason)
+			continue
+		}
+		usage, ok := c.podUsage(types.NetworkRxBusy, keyName, true)
+		if !ok || usage < 0 {
+			usage = 0
+		}
+		ranking.Candidates = append(ranking.Candidates, Candidate{
+			Pod:   pod,
+			Usage: usage,
+			Score: usage * c.namespaceWeight(pod.Namespace),
+			Label: types.NeedEvict,
+		})
+	}
+	sort.Slice(ranking.Candidates, func(i, j int) bool {
+		a, b := ranking.Candidates[i], ranking.Candidates[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		return a.Pod.Namespace+"/"+a.Pod.Name < b.Pod.Namespace+"/"+b.Pod.Name
+	})
+	sort.Slice(ranking.Excluded, func(i, j int) bool {
+		a, b := ranking.Excluded[i], ranking.Excluded[j]
+		if a.Reason != b.Reason {
+			return a.Reason < b.Reason
+		}
+		return a.Pod < b.Pod
+	})
+	return ranking
+}
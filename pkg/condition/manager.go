@@ -1,35 +1,96 @@
 package condition
 
 import (
+	"context"
+	"crypto/sha256"
 	"time"
-	"os"
 	"fmt"
 	"io/ioutil"
-	"encoding/json"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"syscall"
 
 	"github.com/fsnotify/fsnotify"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/apimachinery/pkg/util/wait"
 
+	"eviction-agent/cmd/options"
+	"eviction-agent/pkg/apis/v1alpha1"
+	"eviction-agent/pkg/burst"
+	"eviction-agent/pkg/config"
 	"eviction-agent/pkg/types"
 	"eviction-agent/pkg/evictionclient"
 	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/metrics"
+	"eviction-agent/pkg/summary"
+	"eviction-agent/pkg/tracing"
 )
 
 const (
-	// updatePeriod is the period
-	updatePeriod = 10 * time.Second
 	statsBufferLen = 3
+	// minStatsBufferLen is the stats kept while agent is throttled, rates need two of them
+	minStatsBufferLen = 2
 	taintThreshold = 0.9
-	defaultDiskIOTotal = 10000
-	defaultNetwortIOTotal = 100000000
-	unTaintGracePeriod = 5 * time.Minute // Minutes
+	defaultDiskIOTotal = config.DefaultDiskIOPSTotal
+	defaultNetwortIOTotal = config.DefaultNetworkBPSTotal
+	unTaintGracePeriod = config.DefaultUntaintGracePeriod * time.Minute // Minutes
+	// configMapDataDir is the symlink swapped by kubelet on ConfigMap volume updates
+	configMapDataDir = "..data"
+	// statsGoneTimeout is how long summary api is unavailable before it's fatal
+	statsGoneTimeout = 5 * time.Minute
+)
+
+var (
+	configReloads = metrics.NewCounterVec("eviction_agent_config_reloads_total",
+		"Number of policy configuration reloads by result.", "result")
 )
 
 type NodeCondition struct {
 	DiskIOAvailable    bool
-	NetworkRxAvailabel bool
-	NetworkTxAvailabel bool
+	// json of network availability keeps the names of api and records before they're fixed
+	NetworkRxAvailable bool `json:"NetworkRxAvailabel"`
+	NetworkTxAvailable bool `json:"NetworkTxAvailabel"`
 	CPUAvailable       bool
 	MemoryAvailable    bool
+	// measured values of the latest stats
+	CPUUsage           float64 // cores
+	MemoryUsage        uint64  // bytes
+	DiskIOPS           float64
+	NetworkRxBps       float64 // Bytes/s
+	NetworkTxBps       float64 // Bytes/s
+	// DiskDevice and NetworkInterfaces are measured by DiskIOPS and network rates
+	DiskDevice        string
+	// DiskDevices are the physical devices of DiskDevice, e.g. under LVM or RAID
+	DiskDevices       []string
+	NetworkInterfaces []string
+	// Problems are node conditions of status True, set by eviction manager
+	// from node, e.g. KernelDeadlock of node problem detector
+	Problems map[string]bool
+	// Extended are conditions of extended resources keyed by resource name
+	Extended map[string]ExtendedResourceCondition
+	// Daemons are shares of system daemons of conditions with reserved headroom
+	Daemons map[string]DaemonShare
+	// Thresholds are the thresholds compared with measured values by evict type
+	Thresholds map[string]float64
+	// Severities are the grades of evict types measured past their
+	// thresholds, nil if conditions are not graded by policy
+	Severities map[string]types.Severity
+	// Bands are conditions of priority bands, pods are evicted within a band
+	// by evict types of BandEvictType
+	Bands []BandCondition
+	// TrafficClasses are conditions of network traffic classes, network is
+	// available if all of them are
+	TrafficClasses []TrafficClassCondition
+	// MemoryReclaim is kernel reclaim activity, memory is busy if it is
+	MemoryReclaim *ReclaimCondition
+	// Writeback is the backlog of dirty and writeback pages, nil if it's not checked
+	Writeback *WritebackCondition
+	// EphemeralPorts is the usage of ephemeral ports, nil if it's not checked
+	EphemeralPorts *PortsCondition
 }
 
 type statType struct {
@@ -43,10 +104,12 @@ type podStatType struct {
 	time        time.Time
 	name        string
 	namespace   string
+	uid         string
 	cpuUsage    float64
 	memoryUsage uint64
 	netIOStats  statType
 	diskIOStats statType
+	containers  map[string]containerStatType // keyed by container name
 }
 
 type nodeStatsType struct {
@@ -56,72 +119,291 @@ type nodeStatsType struct {
 	cpuUsage    float64
 	memoryUsage uint64
 	podStats    map[string]podStatType  // key=PodNamespace.Name
+	overlayIOStats statType // of overlay interfaces, for traffic classes
+	reclaimStats   reclaimStats // of /proc/vmstat, for memory reclaim
+	writebackStats writebackStats // of /proc/vmstat, for writeback
+	podWriteback   map[string]uint64 // key=PodNamespace.Name, bytes of dirty and writeback pages of cgroups
+	portStats      portStats // of /proc/net/tcp, for ephemeral ports
+	podSockets     map[string]uint64 // key=PodNamespace.Name, open sockets of processes of cgroups
 }
 
 type ConditionManager interface {
 	// Start starts the condition manager
-	Start() error
+	Start(ctx context.Context) error
 	// Get node condition
 	GetNodeCondition() (*NodeCondition)
 	// Choose one pod to evict, according priority or some policies
-	ChooseOnePodToEvict(string) (*types.PodInfo, bool, string, error)
+	ChooseOnePodToEvict(types.Condition) (*types.PodInfo, bool, string, error)
+	// GetLastRanking returns the ranking of the last ChooseOnePodToEvict
+	GetLastRanking() Ranking
 	// GetUnTaintGracePeriod get value from policy file
 	GetUnTaintGracePeriod() time.Duration
+	// HasSynced returns true if there are enough stats to compute node condition
+	HasSynced() bool
+	// LastSyncTime returns the last time stats are synced successfully
+	LastSyncTime() time.Time
+	// StatsCycle returns the last time stats sync completed a cycle, successful or not, and its period
+	StatsCycle() (time.Time, time.Duration)
+	// RestartStatsSync abandons stats sync and starts it again, e.g. when it's stalled
+	RestartStatsSync()
+	// GetStatsSamples returns the stats samples in memory
+	GetStatsSamples() []StatsSample
+	// GetEvictionCandidates returns ranked candidates without choosing any of them
+	GetEvictionCandidates(string) ([]Candidate, error)
+	// GetDrainCandidates returns pods evicted to drain node, the lowest priority first
+	GetDrainCandidates() ([]types.PodInfo, error)
+	// GetTopPods returns the top k pods by usage of each resource
+	GetTopPods(k int) (*TopPods, error)
+	// ConditionEnabled returns false if the condition is disabled by flag or policy
+	ConditionEnabled(string) bool
+	// ActionEnabled returns false if the action is not one of actions of policy
+	ActionEnabled(string) bool
+	// SetSkippedNamespaces sets namespaces whose pods are not chosen
+	SetSkippedNamespaces(map[string]bool)
+	// Diagnose collects stats once without starting the condition manager
+	Diagnose(ctx context.Context, interval time.Duration) (*Diagnosis, error)
+	// Fatal returns the error stats sync can't recover from, nil if there is none
+	Fatal() error
+	// RecordEviction counts an eviction of pod against its namespace preference
+	RecordEviction(types.PodInfo)
 }
 
 type conditionManager struct {
 	client               evictionclient.Client
 	policyConfigFile     string
-	taintThreshold       map[string]float64
+	profile              string // default profile of policies which set no profile
+	policy               *config.PolicyConfig // static policy of WithPolicy, used instead of policyConfigFile
+	taintThreshold       map[string]config.Threshold
 	untaintGracePeriod   time.Duration   // minutes
 	nodeCondition        NodeCondition
 	podToEvict           types.PodInfo
-	nodeStats            []nodeStatsType
+	lastRanking          Ranking // of the last ChooseOnePodToEvict, only used by eviction goroutine
+	statsLock            sync.RWMutex // protects nodeStats
+	policyLock           sync.RWMutex // protects policy configuration and evictionPolicy, taken after statsLock
+	policyFileHash       [sha256.Size]byte // content hash of policy file, only used by file watcher
+	nodeStats            statsRing
 	autoEvict            bool
+	actions              map[string]bool // enabled actions of policy, nil if all of them are
 	networkInterfaces    []string
 	diskDevName          string
+	diskTopology         *diskTopology // physical devices of diskDevName, nil if it's not resolved
 	diskIoTotal          int64
 	networkIoTotal       int64
+	networkDetected      bool // networkIoTotal is detected from link speed, again when links renegotiate
 	invalidEvictCount    int32
 	cpuTotal             int64
 	memTotal             int64
 	lowPriorityThreshold int
+	protectedNamespaces  map[string]bool
+	skippedNamespaces    map[string]bool // e.g. of tenants out of eviction budget, protected by policyLock
+	disabledByFlag       []string // disabled conditions of --disabled-conditions
+	policyOverrides      []config.Override // overrides of environment and flags
+	disabledConditions   map[string]bool // disabled by flag or policy
+	systemReserved       map[string]config.Threshold // headroom of system daemons by condition
+	labelPolicy          config.LabelPolicy // priority of pods labeled instead of evicted
+	priorityBands        []config.PriorityBand // usage of pods of bands is limited together
+	podPriorities        map[string]int // key=PodNamespace.Name, of priority bands, protected by statsLock
+	trafficClasses       map[string]config.Threshold // of network capacity, the node total is checked if empty
+	overlayInterfaces    []string
+	hostNetworkPods      map[string]bool // key=PodNamespace.Name, of traffic classes, protected by statsLock
+	memoryReclaim        config.MemoryReclaim // rates of kernel reclaim over which Memory is busy
+	writeback            config.Writeback // backlog of dirty and writeback pages over which node is tainted
+	writebackSince       time.Time // backlog is over threshold since, only used by GetNodeCondition
+	ephemeralPorts       config.EphemeralPorts // used ephemeral ports over which node is tainted
+	calibration          *calibration // thresholds of baseline usage, disabled if nil
+	enablePolicyCRD      bool
+	namespacePreferences map[string]NamespacePreference // of namespace annotations, protected by policyLock, disabled if nil
+	namespaceEvictions   namespaceEvictions // for max evictions per hour of namespace preferences
+	evictionPolicy       *v1alpha1.EvictionPolicy // EvictionPolicy selecting this node
+	evictionPolicies     map[string]v1alpha1.EvictionPolicy // listed and watched EvictionPolicy keyed by name
+	nodePools            []config.NodePool // of the loaded policy, protected by policyLock
+	nodePool             string // name of the node pool selecting this node, protected by policyLock
+	synced               int32 // set to 1 after the first valid sample
+	lastSyncTime         int64 // unix nano
+	lastCycleTime        int64 // unix nano, of the last cycle of stats sync successful or not
+	syncCtx              context.Context // of Start, stats sync is started again under it
+	syncCancel           context.CancelFunc // cancels the running stats sync, protected by syncCancelLock
+	syncCancelLock       sync.Mutex
+	clock                clock.Clock
+	syncPeriod           time.Duration
+	syncJitter           float64
+	statsTimeout         time.Duration // timeout of each stats source
+	statsConcurrency     int // max stats sources run at once
+	maxPodStats          int // max pods kept in each stats, unlimited if zero
+	podStatsWorkers      int // max pods collected at once
+	podStatsTimeout      time.Duration // timeout of each pod, unlimited if zero
+	burst                *burst.Monitor // tightens thresholds by burst credits, nil if disabled
+	extendedThresholds   map[string]config.Threshold // of allocatable, keyed by extended resource
+	extendedTaints       map[string]string // extended resources keyed by taint key
+	extendedUsageURL     string
+	extendedClient       *http.Client
+	extended             extendedStats // protected by statsLock
+	selfPod              types.PodInfo // pod of eviction agent, never chosen
+	protectInfraPods     bool // pods of DaemonSets and static pods are never chosen
+	tolerationAware      bool // pods scheduled back on node are chosen after others
+	returningPods        map[string]map[string]bool // key=PodNamespace.Name, taint keys pods are scheduled back despite, protected by statsLock
+	pinnedPodsAction     string // Exclude or Rank of pods pinned to node, disabled if empty
+	pinnedPods           map[string]string // key=PodNamespace.Name, why pods can't be scheduled elsewhere, protected by statsLock
+	pinnedSyncTime       time.Time // only used by stats sync
+	jobCompletionAware   bool // pods of Jobs are weighted by progress of Jobs
+	jobProgress          map[string]float64 // key=PodNamespace.Name, progress of Jobs in [0, 1], protected by statsLock
+	jobProgressSyncTime  time.Time // only used by stats sync
+	scoring              config.Scoring // factors weighting scores of candidates, protected by policyLock
+	sidecarContainers    map[string]bool // pods whose offender is one of them are labeled instead of evicted
+	infraContainers      config.InfrastructureContainers // containers whose usage is discounted when scoring pods
+	maxIOBurst           time.Duration // max duration of high io phases declared by pods, protected by policyLock
+	ioBursts             map[string]ioBurst // key=PodNamespace.Name, high io phases declared by pods, protected by statsLock
+	severity             config.Severity // grades of busy conditions of resources
+	podLifecycles        map[string]*podLifecycle // key=PodNamespace.Name, start times and restarts of pods, protected by statsLock
+	podCosts             map[string]float64 // key=PodNamespace.Name, costs of pods, protected by statsLock
+	podCostsKey          string // label or annotation of podCosts, only used by stats sync
+	podCostsSyncTime     time.Time // only used by stats sync
+	selfLimitRatio       float64 // of limits of agent pod above which agent is throttled
+	selfLimits           selfLimits // only used by stats sync
+	selfThrottle         int32 // factor of sampling period, 1 if agent is not throttled
+	recordPath           string // file of --record, disabled if empty
+	recordFile           *os.File // only used by stats sync
+	unavailableSince     time.Time // since when summary api is unavailable, only used by stats sync
+	fatal                atomic.Value // error stats sync can't recover from
 }
 
-type policyConfig struct {
-	UntaintGracePeriod   int32               `json:"untaintGracePeriod"`
-	TaintThreshold       map[string]float64  `json:"taintThreshold"`
-	AutoEvictFlag        bool                `json:"autoEvictFlag"`
-	//Resource total
-	NetworkInterfaces    []string            `json:"networkInterfaces"`
-	NetworkBPSTotal      int64               `json:"networkBPSTotal"`
-	DiskDevName          string              `json:"diskDevName"`
-	DiskIOPSTotal        int64               `json:"diskIOPSTotal"`
-	LowPriorityThreshold int                 `json:"lowPriorityThreshold"`
+// NewConditionManager creates a condition manager
+func NewConditionManager(client evictionclient.Client, eao *options.EvictionAgentOptions) ConditionManager {
+	return NewConditionManagerWithClock(client, eao, clock.RealClock{})
 }
 
-// NewConditionManager creates a condition manager
-func NewConditionManager(client evictionclient.Client, configFile string) ConditionManager {
+// NewConditionManagerWithClock creates a condition manager using clk for all timing
+func NewConditionManagerWithClock(client evictionclient.Client, eao *options.EvictionAgentOptions, clk clock.Clock) ConditionManager {
+	extendedThresholds := eao.GetExtendedResourcesOrDie()
+	extendedTaints := make(map[string]string)
+	for resource := range extendedThresholds {
+		extendedTaints[types.ExtendedResourceTaintKey(resource)] = resource
+	}
 	return &conditionManager{
 		client:     client,
-		policyConfigFile: configFile,
+		clock:      clk,
+		syncPeriod: eao.GetStatsPeriod(),
+		syncJitter: eao.EvaluationJitter,
+		statsTimeout: eao.StatsTimeout,
+		statsConcurrency: eao.StatsConcurrency,
+		maxPodStats: eao.MaxPodStats,
+		podStatsWorkers: eao.PodStatsWorkers,
+		podStatsTimeout: eao.PodStatsTimeout,
+		policyConfigFile: eao.PolicyConfigFile,
+		profile: eao.Profile,
+		enablePolicyCRD: eao.EnablePolicyCRD,
 		nodeCondition: NodeCondition{
 			CPUAvailable: true,
 			MemoryAvailable: true,
 			DiskIOAvailable:    true,
-			NetworkRxAvailabel: true,
-			NetworkTxAvailabel: true,
+			NetworkRxAvailable: true,
+			NetworkTxAvailable: true,
 		},
-		taintThreshold: make(map[string]float64),
+		taintThreshold: make(map[string]config.Threshold),
 		autoEvict: false,
 		diskIoTotal: defaultDiskIOTotal,
 		networkIoTotal: defaultNetwortIOTotal,
 		untaintGracePeriod: unTaintGracePeriod,
+		protectedNamespaces: make(map[string]bool),
+		disabledByFlag: eao.GetDisabledConditions(),
+		policyOverrides: eao.GetPolicyOverridesOrDie(),
+		disabledConditions: make(map[string]bool),
+		burst: newBurstMonitorOrDie(eao),
+		extendedThresholds: extendedThresholds,
+		extendedTaints: extendedTaints,
+		extendedUsageURL: eao.ExtendedResourceUsageURL,
+		extendedClient: &http.Client{Timeout: eao.StatsTimeout},
+		selfPod: types.PodInfo{Name: eao.PodName, Namespace: eao.PodNamespace},
+		protectInfraPods: eao.ProtectInfraPods,
+		tolerationAware: eao.TolerationAware,
+		pinnedPodsAction: eao.PinnedPods,
+		jobCompletionAware: eao.JobCompletionAware,
+		selfLimitRatio: eao.SelfLimitRatio,
+		selfThrottle: 1,
+		recordPath: eao.RecordFile,
+		calibration: newCalibration(eao),
+		namespacePreferences: newNamespacePreferences(eao),
 	}
 }
 
-func (c *conditionManager) Start() error {
+// setDefaultThresholds sets taint thresholds of all conditions to the default
+func (c *conditionManager) setDefaultThresholds() {
+	defaultThreshold := config.Threshold{Ratio: config.DefaultTaintThreshold}
+	c.taintThreshold["CPU"] = defaultThreshold
+	c.taintThreshold["DiskIo"] = defaultThreshold
+	c.taintThreshold["NetworkIo"] = defaultThreshold
+	c.taintThreshold["Memory"] = defaultThreshold
+}
+
+// newBurstMonitorOrDie creates the burst credits monitor, returns nil if no provider is configured
+func newBurstMonitorOrDie(eao *options.EvictionAgentOptions) *burst.Monitor {
+	if eao.BurstCreditsProvider == "" {
+		return nil
+	}
+	probe, err := burst.NewProbe(eao.BurstCreditsProvider, eao.StatsTimeout)
+	if err == nil && (eao.BurstCreditsLow <= 0 || eao.BurstCreditsLow > 1 || eao.BurstMinFactor <= 0 || eao.BurstMinFactor > 1) {
+		err = fmt.Errorf("--burst-credits-low and --burst-min-factor should be in (0, 1]")
+	}
+	if err != nil {
+		log.Errorf("Invalid --burst-credits-provider: %v", err)
+		panic(err)
+	}
+	return burst.NewMonitor(probe, eao.BurstCreditsPeriod, eao.BurstCreditsLow, eao.BurstMinFactor)
+}
+
+// burstFactor returns the factor of taint threshold of condition tightened by burst credits
+func (c *conditionManager) burstFactor(condition string) float64 {
+	if c.burst == nil {
+		return 1
+	}
+	return c.burst.Factor(condition)
+}
+
+// Start starts watchers and stats sync, which stop when ctx is done
+func (c *conditionManager) Start(ctx context.Context) error {
 	log.Infof("Start condition manager\n")
+	if err := c.init(); err != nil {
+		return err
+	}
+
+	// watch policy configuration
+	if c.policyConfigFile != "" {
+		if data, err := ioutil.ReadFile(c.policyConfigFile); err == nil {
+			c.policyFileHash = sha256.Sum256(data)
+		}
+		go c.policyConfigFileWatcher(ctx)
+	}
+	go c.reloadOnSignal(ctx)
+	if c.enablePolicyCRD {
+		go c.evictionPolicyWatcher(ctx)
+	}
+	go c.nodePoolWatcher(ctx)
+	go c.networkCapacityWatcher(ctx)
+	if c.namespacePreferences != nil {
+		go c.namespacePreferenceWatcher(ctx)
+	}
+
+	if err := c.openRecord(); err != nil {
+		return err
+	}
+
+	// get node stats periodically
+	c.loadSelfLimits()
+	atomic.StoreInt64(&c.lastSyncTime, c.clock.Now().UnixNano())
+	c.syncCtx = ctx
+	c.RestartStatsSync()
+	if c.burst != nil {
+		go c.burst.Run(ctx)
+	}
+
+	return nil
+}
+
+// init gets totals of node and loads policy configuration
+func (c *conditionManager) init() error {
+	if err := config.ValidateConditions(c.disabledByFlag); err != nil {
+		return types.NewFatalError(types.FatalConfigInvalid, fmt.Errorf("invalid --disabled-conditions: %v", err))
+	}
 
 	// get node iops total value
 	nodeIOPSTotal, err := c.client.GetResourcesTotalFromAnnotations()
@@ -133,34 +415,54 @@ func (c *conditionManager) Start() error {
 	c.diskIoTotal = nodeIOPSTotal.DiskIOPSTotal
 	c.cpuTotal = nodeIOPSTotal.CPUTotal
 	c.memTotal = nodeIOPSTotal.MemoryTotal
-	c.taintThreshold["CPU"] = 1
-	c.taintThreshold["DiskIo"] = 1
-	c.taintThreshold["NetworkIo"] = 1
-	c.taintThreshold["Memory"] = 1
+	c.setDefaultThresholds()
 	log.Infof("Get total value, networkBPS: %v, diskIOPS: %v, cpu: %v, memory: %v",
 		c.networkIoTotal, c.diskIoTotal, c.cpuTotal, c.memTotal)
 
+	// get EvictionPolicy selecting this node
+	if c.enablePolicyCRD {
+		if _, err := c.listEvictionPolicies(); err != nil {
+			log.Errorf("List eviction policies error: %v", err)
+		} else if _, err := c.syncEvictionPolicy(); err != nil {
+			log.Errorf("Sync eviction policy error: %v", err)
+		}
+	}
+
+	// get preferences of namespace annotations
+	if c.namespacePreferences != nil {
+		if err := c.syncNamespacePreferences(); err != nil {
+			log.Errorf("Sync namespace preferences error: %v", err)
+		}
+	}
+
 	// load policy configuration
 	err = c.loadPolicyConfig()
 	if err != nil {
-		return err
+		return types.NewFatalError(types.FatalConfigInvalid, err)
 	}
 
-	if c.networkIoTotal == 0 || c.diskIoTotal == 0 {
-		return fmt.Errorf("IOPS config is not in pod annotations or configuration file.")
+	// detect network bandwidth from NIC speed if it's neither annotated nor
+	// configured, or it's configured auto
+	if c.networkIoTotal == 0 || c.networkDetected {
+		c.networkDetected = true
+		if total := detectNetworkBPSTotal(c.networkInterfaces); total != 0 {
+			log.Infof("Detect network bandwidth %v Bytes/s from speed of %v", total, c.networkInterfaces)
+			c.networkIoTotal = total
+		}
 	}
 
-	// watch policy configuration
-	go c.policyConfigFileWatcher()
-
-	// get node stats periodically
-	go c.syncStats()
-
+	if c.networkIoTotal == 0 || c.diskIoTotal == 0 {
+		return types.NewFatalError(types.FatalConfigInvalid,
+			fmt.Errorf("IOPS config is not in pod annotations or configuration file."))
+	}
+	c.restoreCalibration()
 	return nil
 }
 
-// policyFileWatcher watch policy file for updating
-func (c *conditionManager) policyConfigFileWatcher() {
+// policyFileWatcher watch policy file for updating. The directory is watched
+// instead of the file, so that replaced files and ConfigMap volumes, which are
+// updated by swapping the ..data symlink, are detected as well.
+func (c *conditionManager) policyConfigFileWatcher(ctx context.Context) {
 	log.Infof("Start policy file watcher\n")
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
@@ -169,7 +471,8 @@ func (c *conditionManager) policyConfigFileWatcher() {
 	}
 	defer watcher.Close()
 
-	if err := watcher.Add(c.policyConfigFile); err != nil {
+	dir := filepath.Dir(c.policyConfigFile)
+	if err := watcher.Add(dir); err != nil {
 		log.Errorf("add policy config file watcher error %v\n", err)
 		return
 	}
@@ -178,279 +481,428 @@ func (c *conditionManager) policyConfigFileWatcher() {
 		select {
 		// watch for events
 		case event := <- watcher.Events:
-			if event.Op == fsnotify.Write || event.Op == fsnotify.Create {
-				c.loadPolicyConfig()
+			name := filepath.Base(event.Name)
+			if name == filepath.Base(c.policyConfigFile) || name == configMapDataDir {
+				c.checkPolicyConfigFile()
 			}
+		case err := <- watcher.Errors:
+			log.Errorf("policy config file watcher error %v\n", err)
+		case <-ctx.Done():
+			log.Infof("Stop policy file watcher")
+			return
 		}
 	}
 }
 
-// loadPolicyConfig read configuration from policyConfigFile
-func (c *conditionManager) loadPolicyConfig() error {
-	configFile, err := os.Open(c.policyConfigFile)
+// checkPolicyConfigFile reloads policy configuration if the content of policy file is changed
+func (c *conditionManager) checkPolicyConfigFile() {
+	data, err := ioutil.ReadFile(c.policyConfigFile)
 	if err != nil {
-		log.Errorf("open policy config file error: %v", err)
-		return err
+		// the file may be missing for a moment while it's replaced
+		log.Warnf("read policy config file %v error: %v", c.policyConfigFile, err)
+		return
+	}
+	hash := sha256.Sum256(data)
+	if hash == c.policyFileHash {
+		return
 	}
-	defer configFile.Close()
+	c.policyFileHash = hash
+	c.reloadPolicyConfig("policy file changed")
+}
 
-	byteValue, _ := ioutil.ReadAll(configFile)
+// reloadOnSignal reloads policy configuration on SIGHUP
+func (c *conditionManager) reloadOnSignal(ctx context.Context) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	defer signal.Stop(signals)
+	for {
+		select {
+		case <-signals:
+			c.reloadPolicyConfig("SIGHUP")
+		case <-ctx.Done():
+			return
+		}
+	}
+}
 
-	var config policyConfig
-	err = json.Unmarshal(byteValue, &config)
-	if err != nil {
-		log.Errorf("json unmarshal failed for file: %v, error: %v", c.policyConfigFile, err)
-		return err
+// reloadPolicyConfig reloads policy configuration, the current one
+// is kept if the new one is invalid
+func (c *conditionManager) reloadPolicyConfig(reason string) {
+	log.Infof("Reload policy configuration, reason: %s", reason)
+	if err := c.loadPolicyConfig(); err != nil {
+		configReloads.Inc("Rejected")
+		log.Errorf("Reload policy configuration error, keep the current one: %v", err)
+		c.client.RecordNodeEvent(types.WarningEvent, types.PolicyRejectedReason,
+			fmt.Sprintf("Policy configuration is rejected, keep the current one: %v", err))
+		return
+	}
+	configReloads.Inc("Applied")
+}
+
+// loadPolicyConfig read configuration from EvictionPolicy selecting this node,
+// or from policyConfigFile if there is no such EvictionPolicy
+func (c *conditionManager) loadPolicyConfig() error {
+	c.policyLock.Lock()
+	defer c.policyLock.Unlock()
+
+	var policy *config.PolicyConfig
+	if c.evictionPolicy != nil {
+		log.Infof("Load policy from EvictionPolicy %v", c.evictionPolicy.Name)
+		var err error
+		policy, err = c.evictionPolicy.Spec.Policy(c.profile)
+		if err != nil {
+			return err
+		}
+	} else if c.policy != nil {
+		policy = c.policy
+	} else if c.policyConfigFile != "" {
+		var err error
+		policy, err = config.LoadFile(c.policyConfigFile, c.profile)
+		if err != nil {
+			return err
+		}
+	} else {
+		return fmt.Errorf("there is neither policy configuration file nor EvictionPolicy for this node")
+	}
+	// defaults <- profile <- file or EvictionPolicy <- node pool <- environment <- flags
+	nodePools := policy.NodePools
+	pool := ""
+	if len(nodePools) != 0 {
+		nodeLabels, err := c.client.GetNodeLabels()
+		if err != nil {
+			return fmt.Errorf("get node labels for nodePools error: %v", err)
+		}
+		if policy, pool, err = policy.ForNode(nodeLabels); err != nil {
+			return err
+		}
+	}
+	if len(c.policyOverrides) != 0 {
+		policy = policy.DeepCopy()
+		if err := config.ApplyOverrides(policy, c.policyOverrides); err != nil {
+			return err
+		}
 	}
+	if err := policy.Validate(); err != nil {
+		return fmt.Errorf("invalid policy configuration: %v", err)
+	}
+	if pool != "" {
+		log.Infof("Use policy of node pool %s", pool)
+	}
+	c.nodePools = nodePools
+	c.nodePool = pool
+	c.applyPolicy(policy)
+	return nil
+}
 
+// applyPolicy sets policy configuration, policyLock must be held
+func (c *conditionManager) applyPolicy(policy *config.PolicyConfig) {
 	// TODO: add other configure here
-	if config.UntaintGracePeriod != 0 {
-		c.untaintGracePeriod = time.Minute * time.Duration(config.UntaintGracePeriod)
+	if policy.UntaintGracePeriod != 0 {
+		c.untaintGracePeriod = time.Duration(policy.UntaintGracePeriod)
 	}
 
-	if config.DiskDevName != "" {
-		c.diskDevName = config.DiskDevName
+	if policy.DiskDevName != "" {
+		c.diskDevName = policy.DiskDevName
+	}
+	c.diskTopology = nil
+	if c.diskDevName != "" {
+		topology, err := resolveDiskTopology(c.diskDevName)
+		if err != nil {
+			log.Warnf("resolve topology of disk %s error, check the device by name: %v", c.diskDevName, err)
+		} else {
+			log.Infof("Resolve disk %s to %s on physical devices %v, stacked devices %v, mountpoints %v",
+				c.diskDevName, topology.name, topology.devices, topology.stacked, topology.mounts)
+			c.diskTopology = topology
+		}
 	}
 
-	if config.DiskIOPSTotal != 0 {
-		c.diskIoTotal = config.DiskIOPSTotal
+	if policy.DiskIOPSTotal != 0 {
+		c.diskIoTotal = policy.DiskIOPSTotal
 	}
-	if config.TaintThreshold != nil {
-		if v, ok := config.TaintThreshold["CPU"]; ok && v > 0 {
+	if policy.TaintThreshold != nil {
+		if v, ok := policy.TaintThreshold["CPU"]; ok {
 			c.taintThreshold["CPU"] = v
 		}
-		if v, ok := config.TaintThreshold["DiskIo"]; ok && v > 0 {
+		if v, ok := policy.TaintThreshold["DiskIo"]; ok {
 			c.taintThreshold["DiskIo"] = v
 		}
-		if v, ok := config.TaintThreshold["NetworkIo"]; ok && v > 0 {
+		if v, ok := policy.TaintThreshold["NetworkIo"]; ok {
 			c.taintThreshold["NetworkIo"] = v
 		}
-		if v, ok := config.TaintThreshold["Memory"]; ok && v > 0 {
+		if v, ok := policy.TaintThreshold["Memory"]; ok {
 			c.taintThreshold["Memory"] = v
 		}
 	}
-	if config.NetworkBPSTotal != 0 {
-		c.networkIoTotal = config.NetworkBPSTotal
+	if policy.NetworkBPSTotal == config.AutoByteRate {
+		c.networkDetected = true
+	} else if policy.NetworkBPSTotal != 0 {
+		c.networkIoTotal = int64(policy.NetworkBPSTotal)
+		c.networkDetected = false
+	}
+	if policy.NetworkInterfaces != nil {
+		c.networkInterfaces = policy.NetworkInterfaces
+	}
+	if policy.LowPriorityThreshold != 0 {
+		c.lowPriorityThreshold = policy.LowPriorityThreshold
+	}
+	c.protectedNamespaces = make(map[string]bool)
+	for _, ns := range policy.ProtectedNamespaces {
+		c.protectedNamespaces[ns] = true
+	}
+	c.disabledConditions = make(map[string]bool)
+	for _, condition := range append(append([]string{}, c.disabledByFlag...), policy.DisabledConditions...) {
+		c.disabledConditions[condition] = true
 	}
-	if config.NetworkInterfaces != nil {
-		c.networkInterfaces = config.NetworkInterfaces
+	c.autoEvict = policy.AutoEvictFlag
+	c.actions = nil
+	if len(policy.Actions) != 0 {
+		c.actions = make(map[string]bool)
+		for _, action := range policy.Actions {
+			c.actions[action] = true
+		}
+	}
+	c.systemReserved = policy.SystemReserved
+	c.labelPolicy = policy.LabelPolicy
+	c.priorityBands = policy.PriorityBands
+	c.trafficClasses = policy.TrafficClasses
+	c.overlayInterfaces = policy.OverlayInterfaces
+	c.memoryReclaim = policy.MemoryReclaim
+	c.writeback = policy.Writeback
+	c.ephemeralPorts = policy.EphemeralPorts
+	c.scoring = policy.Scoring
+	c.severity = policy.Severity
+	c.infraContainers = policy.InfrastructureContainers
+	c.maxIOBurst = defaultMaxIOBurst
+	if policy.MaxIOBurst > 0 {
+		c.maxIOBurst = time.Duration(policy.MaxIOBurst)
 	}
-	if config.LowPriorityThreshold != 0 {
-		c.lowPriorityThreshold = config.LowPriorityThreshold
+	c.sidecarContainers = make(map[string]bool)
+	for _, name := range policy.SidecarContainers {
+		c.sidecarContainers[name] = true
 	}
-	c.autoEvict = config.AutoEvictFlag
 	log.Infof("Get configuration --diskIoTotal=%v, --taintThreshold=%v, --network interfaces=%v, " +
-		"--networkIOTotal=%v, --autoEvictFlag=%v, --diskDevName=%v, --untaintGracePeriod=%v, " +
-		"--lowPriorityThreshold=%v",
+		"--networkIOTotal=%v, --autoEvictFlag=%v, --actions=%v, --diskDevName=%v, --untaintGracePeriod=%v, " +
+		"--lowPriorityThreshold=%v, --protectedNamespaces=%v, --disabledConditions=%v, --systemReserved=%v, --labelPolicy=%+v, " +
+		"--priorityBands=%+v, --trafficClasses=%v, --overlayInterfaces=%v, --memoryReclaim=%+v, --writeback=%v for %v, --ephemeralPorts=%v, " +
+		"--scoring age=%+v restarts=%+v cost=%+v, --severity=%+v, --sidecarContainers=%v, --infrastructureContainers=%+v, --maxIOBurst=%v",
 		c.diskIoTotal, c.taintThreshold, c.networkInterfaces,
-		c.networkIoTotal, c.autoEvict, c.diskDevName, c.untaintGracePeriod,
-		c.lowPriorityThreshold)
+		c.networkIoTotal, c.autoEvict, policy.Actions, c.diskDevName, c.untaintGracePeriod,
+		c.lowPriorityThreshold, policy.ProtectedNamespaces, c.disabledConditions, c.systemReserved, c.labelPolicy,
+		c.priorityBands, c.trafficClasses, c.overlayInterfaces, c.memoryReclaim, c.writeback.Threshold, time.Duration(c.writeback.SustainedFor),
+		c.ephemeralPorts.Threshold, c.scoring.Age, c.scoring.Restarts, c.scoring.Cost, c.severity, policy.SidecarContainers, c.infraContainers, c.maxIOBurst)
+}
 
-	return nil
+// ConditionEnabled returns false if the condition is disabled by flag or policy
+func (c *conditionManager) ConditionEnabled(condition string) bool {
+	c.policyLock.RLock()
+	defer c.policyLock.RUnlock()
+	return !c.disabledConditions[condition]
+}
+
+// ActionEnabled returns false if the action is not one of actions of policy
+func (c *conditionManager) ActionEnabled(action string) bool {
+	c.policyLock.RLock()
+	defer c.policyLock.RUnlock()
+	return c.actions == nil || c.actions[action]
+}
+
+// HasSynced returns true after the first valid sample
+func (c *conditionManager) HasSynced() bool {
+	return atomic.LoadInt32(&c.synced) == 1
+}
+
+// LastSyncTime returns the last time stats are synced successfully
+func (c *conditionManager) LastSyncTime() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&c.lastSyncTime))
+}
+
+// StatsCycle returns the last time stats sync completed a cycle and the
+// sampling period, which is longer while agent is throttled
+func (c *conditionManager) StatsCycle() (time.Time, time.Duration) {
+	return time.Unix(0, atomic.LoadInt64(&c.lastCycleTime)), c.samplingPeriod()
+}
+
+// RestartStatsSync cancels the running stats sync and starts a new one. A
+// stalled cycle can't be interrupted, the abandoned sync exits once it
+// returns without keeping its stats. A deadlock is not resolved by it.
+func (c *conditionManager) RestartStatsSync() {
+	c.syncCancelLock.Lock()
+	defer c.syncCancelLock.Unlock()
+	if c.syncCancel != nil {
+		c.syncCancel()
+	}
+	ctx, cancel := context.WithCancel(c.syncCtx)
+	c.syncCancel = cancel
+	atomic.StoreInt64(&c.lastCycleTime, c.clock.Now().UnixNano())
+	go c.syncStats(ctx)
 }
 
 // GetUnTaintGracePeriod return un-Taint grace period to taint process
-func (c conditionManager) GetUnTaintGracePeriod() time.Duration {
+func (c *conditionManager) GetUnTaintGracePeriod() time.Duration {
+	c.policyLock.RLock()
+	defer c.policyLock.RUnlock()
 	return c.untaintGracePeriod
 }
 
+// Jitter returns period plus a random duration up to factor * period
+func Jitter(period time.Duration, factor float64) time.Duration {
+	if factor <= 0 {
+		return period
+	}
+	return wait.Jitter(period, factor)
+}
+
+// sleep waits for d, returns false if ctx is done before that
+func (c *conditionManager) sleep(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-c.clock.After(d):
+		return true
+	}
+}
+
 // syncStats
-func (c *conditionManager) syncStats() {
+func (c *conditionManager) syncStats(ctx context.Context) {
 	log.Infof("Start sync stats\n")
 	for {
-		// Get summary stats
-		stats, err := c.client.GetSummaryStats()
-		if err != nil {
-			log.Errorf("sync stats get summary stats error: %v", err)
-			continue
-		}
-
-		newNodeStats := nodeStatsType{}
-		newNodeStats.podStats = make(map[string]podStatType)
-		newNodeStats.time = stats.NodeNetStats.Time.Time
-
-		// Get CPU and Memory stats
-		if stats.NodeCPUStats != nil {
-			if stats.NodeCPUStats.UsageNanoCores != nil {
-				newNodeStats.cpuUsage = float64(*stats.NodeCPUStats.UsageNanoCores) / 1e9
-			}
+		c.syncStatsOnce(ctx)
+		if ctx.Err() == nil {
+			atomic.StoreInt64(&c.lastCycleTime, c.clock.Now().UnixNano())
 		}
-		if stats.NodeMemoryStats != nil {
-			if stats.NodeMemoryStats.UsageBytes != nil {
-				newNodeStats.memoryUsage = *stats.NodeMemoryStats.UsageBytes
-			}
+		if !c.sleep(ctx, Jitter(c.samplingPeriod(), c.syncJitter)) {
+			break
 		}
-		log.Debugf("Get cpu: %v, memory: %v Bytes.", newNodeStats.cpuUsage, newNodeStats.memoryUsage)
+	}
+	log.Infof("Sync stats stop")
+}
 
-		// Get Network IO stats, add it to nodeStats
-		netStats := stats.NodeNetStats
-		newNodeStats.netIOStats.time = netStats.Time.Time
-		for _, netName := range c.networkInterfaces {
-			newNodeStats.netIOStats.name += netName + "."
-		}
-		for _, iface := range netStats.Interfaces {
-			net := statType{}
-			if iface.RxBytes != nil && iface.TxBytes != nil {
-				net.rx = *iface.RxBytes
-				net.tx = *iface.TxBytes
-			}
-			// traverse all net interfaces
-			for _, netName := range c.networkInterfaces {
-				if iface.Name == netName {
-					newNodeStats.netIOStats.rx += net.rx
-					newNodeStats.netIOStats.tx += net.tx
-				}
-			}
-		}
+// syncStatsOnce collects stats of one sampling, returns error if they are abandoned
+func (c *conditionManager) syncStatsOnce(ctx context.Context) error {
+	cycleCtx, span := tracing.Start(ctx, "stats.sync")
+	defer span.End()
+	c.syncExtendedResources(cycleCtx)
+	// pods on node are listed once for priority bands, traffic classes, tolerations and scoring
+	c.client.BeginCycle()
+	c.syncPodPriorities()
+	c.syncHostNetworkPods()
+	c.syncReturningPods()
+	c.syncPinnedPods()
+	c.syncJobProgress()
+	c.syncPodLifecycles()
+	c.syncIOBursts()
+	c.syncPodCosts()
+	c.client.EndCycle()
+	// Get summary stats
+	summaryCtx, summarySpan := tracing.StartClient(cycleCtx, "kubelet.summary")
+	stats, err := c.client.WithContext(summaryCtx).GetSummaryStats()
+	summarySpan.SetError(err)
+	summarySpan.End()
+	c.checkStatsSource(err)
+	if err != nil {
+		log.Errorf("sync stats get summary stats error: %v", err)
+		span.SetError(err)
+		return err
+	}
 
-		// Get all pods stats, add them to nodeStats. podStats := stats.PodStats
-		podStats := stats.PodStats
-		for _, pod := range podStats {
-			diskStats := statType{}
-			// Sum all containers' stats together
-			// Maybe some pod doesn't has DiskIoStats, set them to ZERO
-			for _, container := range pod.Containers {
-				if container.Diskio != nil {
-					if container.Diskio.DiskIoStats != nil {
-						ioServiced := container.Diskio.DiskIoStats.IoServiced
-						if c.diskDevName != "" {
-							// care about the specified name
-							diskStats.name = c.diskDevName
-							diskStats.time = container.Diskio.Time.Time
-							for _, io := range ioServiced {
-								if io.Device == c.diskDevName {
-									if v, ok := io.Stats["Read"]; ok {
-										diskStats.rx += v
-									}
-									if v, ok := io.Stats["Write"]; ok {
-										diskStats.tx += v
-									}
-								}
-							}
-						} else if len(ioServiced) != 0 {
-							// choose the first one
-							diskStats.time = container.Diskio.Time.Time
-							diskStats.name = ioServiced[0].Device
-							if v, ok := ioServiced[0].Stats["Read"]; ok {
-								diskStats.rx += v
-							}
-							if v, ok := ioServiced[0].Stats["Write"]; ok {
-								diskStats.tx += v
-							}
-						}
-					}
-				}
-			}
-			netIoStats := statType{}
-			if pod.Network != nil {
-				if pod.Network.RxBytes != nil && pod.Network.TxBytes != nil {
-					netIoStats.rx = *pod.Network.RxBytes
-					netIoStats.tx = *pod.Network.TxBytes
-				}
-			}
-			podStat := podStatType{
-				name: pod.PodRef.Name,
-				namespace: pod.PodRef.Namespace,
-				time: pod.StartTime.Time,
-				netIOStats: statType{
-					name: pod.Network.Name,
-					time: pod.Network.Time.Time,
-					rx:   netIoStats.rx,
-					tx:   netIoStats.tx,
-				},
-				diskIOStats: diskStats,
-			}
-			if pod.CPU != nil {
-				if pod.CPU.UsageNanoCores != nil {
-					podStat.cpuUsage = float64(*pod.CPU.UsageNanoCores) / 1e9
-				}
-			}
-			if pod.Memory != nil {
-				if pod.Memory.UsageBytes != nil {
-					podStat.memoryUsage = *pod.Memory.UsageBytes
-				}
-			}
-			keyName := podStat.namespace + "." + podStat.name
-			newNodeStats.podStats[keyName] = podStat
-		}
+	c.policyLock.RLock()
+	networkInterfaces := c.networkInterfaces
+	diskDevName := c.diskDevName
+	topology := c.diskTopology
+	overlayInterfaces := c.overlayInterfaces
+	reclaim := c.memoryReclaim.Enabled()
+	writeback := c.writeback.Threshold != nil
+	ports := c.ephemeralPorts.Threshold != nil
+	c.policyLock.RUnlock()
 
-		// Get disk stats together, include system containers and user pods
-		newNodeStats.diskIOStats.time = stats.NodeDiskIoStats.Time.Time
-		// add system container disk-io stats to node stats
-		sysContainers := stats.SysContainers
-		for _, container := range sysContainers {
-			if container.Diskio != nil {
-				if container.Diskio.DiskIoStats != nil {
-					ioServiced := container.Diskio.DiskIoStats.IoServiced
-					if c.diskDevName != "" {
-						// care about the specified name
-						newNodeStats.diskIOStats.name = c.diskDevName
-						for _, io := range ioServiced {
-							if io.Device == c.diskDevName {
-								if v, ok := io.Stats["Read"]; ok {
-									newNodeStats.diskIOStats.rx += v
-								}
-								if v, ok := io.Stats["Write"]; ok {
-									newNodeStats.diskIOStats.tx += v
-								}
-							}
-						}
-					} else if len(ioServiced) != 0 {
-						if name := ioServiced[0].Device; name != "" {
-							newNodeStats.diskIOStats.name = name
-						}
-						if v, ok := ioServiced[0].Stats["Read"]; ok {
-							newNodeStats.diskIOStats.rx += v
-						}
-						if v, ok := ioServiced[0].Stats["Write"]; ok {
-							newNodeStats.diskIOStats.tx += v
-						}
-					}
-				}
-			}
-		}
-		// add user pod disk-io stats to node stats
-		for _, pod := range newNodeStats.podStats {
-			newNodeStats.diskIOStats.rx += pod.diskIOStats.rx
-			newNodeStats.diskIOStats.tx += pod.diskIOStats.tx
-		}
+	_, collectSpan := tracing.Start(cycleCtx, "stats.collect")
+	results, errs := collectStats(c.clock, c.statsTimeout, c.collectConcurrency(), statsSources, stats,
+		collectInput{networkInterfaces: networkInterfaces, overlayInterfaces: overlayInterfaces, reclaim: reclaim,
+			writeback: writeback, ports: ports, diskDevName: diskDevName, diskTopology: topology, maxPods: c.maxPodStats,
+			podWorkers: c.podWorkers(), podTimeout: c.podStatsTimeout, clock: c.clock})
+	collectSpan.SetAttribute("failed_sources", len(errs))
+	collectSpan.End()
+	c.statsLock.RLock()
+	var prev *nodeStatsType
+	if c.nodeStats.len() != 0 {
+		prev = c.nodeStats.last()
+	}
+	newNodeStats, ok := mergeStats(results, prev)
+	c.statsLock.RUnlock()
+	if !ok {
+		log.Errorf("sync stats abandon the first stats with sources failed")
+		err := fmt.Errorf("%d stats sources failed", len(errs))
+		span.SetError(err)
+		return err
+	}
+	newNodeStats.time = stats.NodeNetStats.Time.Time
+	log.Debugf("Get cpu: %v, memory: %v Bytes.", newNodeStats.cpuUsage, newNodeStats.memoryUsage)
 
-		// add new node stats to list
-		if len(c.nodeStats) == statsBufferLen {
-			// If get the same time, ignore it.
-			if newNodeStats.time != c.nodeStats[statsBufferLen - 1].time {
-				c.nodeStats = append(c.nodeStats[1:], newNodeStats)
-			} else {
-				log.Debugf("Abandon this stats at: %v", newNodeStats.time)
-			}
+	// add new node stats to list, fewer stats are kept while agent is throttled
+	c.throttleSelf(&newNodeStats)
+	window := c.statsWindow()
+	c.statsLock.Lock()
+	// stats of an abandoned sync are older than the ones of its replacement
+	if ctx.Err() != nil {
+		c.statsLock.Unlock()
+		return ctx.Err()
+	}
+	if c.nodeStats.len() >= window {
+		// If get the same time, ignore it.
+		if newNodeStats.time != c.nodeStats.last().time {
+			c.nodeStats.push(newNodeStats, window)
 		} else {
-			c.nodeStats = append(c.nodeStats, newNodeStats)
+			log.Debugf("Abandon this stats at: %v", newNodeStats.time)
 		}
-		time.Sleep(updatePeriod)
+	} else {
+		c.nodeStats.push(newNodeStats, window)
+	}
+	if c.nodeStats.len() == window {
+		atomic.StoreInt32(&c.synced, 1)
 	}
-	log.Errorf("Sync stats stop")
+	c.updateStatsMemory()
+	c.statsLock.Unlock()
+	c.recordStats(&newNodeStats)
+	c.calibrate()
+	atomic.StoreInt64(&c.lastSyncTime, c.clock.Now().UnixNano())
+	return nil
 }
 
 // GetNodeCondition
 func (c *conditionManager) GetNodeCondition() (*NodeCondition) {
+	c.statsLock.RLock()
+	defer c.statsLock.RUnlock()
+	c.policyLock.RLock()
+	defer c.policyLock.RUnlock()
 	// Return directly, there are no enough stats
-	if len(c.nodeStats) != statsBufferLen {
+	if !c.HasSynced() {
 		return &c.nodeCondition
 	}
-	newStats := c.nodeStats[statsBufferLen - 1]
-	lastStats := c.nodeStats[statsBufferLen - 2]
-	// CPU check
-	if newStats.cpuUsage < float64(c.cpuTotal) * c.taintThreshold["CPU"] {
+	newStats := *c.nodeStats.last()
+	lastStats := *c.nodeStats.at(c.nodeStats.len() - 2)
+	// usage of pods is checked if headroom is reserved for system daemons
+	c.nodeCondition.Daemons = c.daemonShares(&newStats)
+	cpuUsage, cpuThreshold := c.checkedUsage(config.CPUCondition, newStats.cpuUsage, float64(c.cpuTotal),
+		c.nodeCondition.Daemons)
+	memoryUsage, memoryThreshold := c.checkedUsage(config.MemoryCondition, float64(newStats.memoryUsage),
+		float64(c.memTotal), c.nodeCondition.Daemons)
+	// CPU check, disabled conditions are always available
+	if c.disabledConditions[config.CPUCondition] ||
+		cpuUsage < cpuThreshold*c.burstFactor(config.CPUCondition) {
 		c.nodeCondition.CPUAvailable = true
 	} else {
 		c.nodeCondition.CPUAvailable = false
 	}
-	// Memory check
-	if float64(newStats.memoryUsage) < float64(c.memTotal) * c.taintThreshold["Memory"] {
+	// Memory check, reclaim activity is an early signal before the threshold
+	c.nodeCondition.MemoryReclaim = c.reclaimCondition(&newStats, &lastStats)
+	reclaimBusy := c.nodeCondition.MemoryReclaim != nil && c.nodeCondition.MemoryReclaim.Busy
+	if c.disabledConditions[config.MemoryCondition] || (memoryUsage < memoryThreshold && !reclaimBusy) {
 		c.nodeCondition.MemoryAvailable = true
 	} else {
 		c.nodeCondition.MemoryAvailable = false
 	}
+	c.nodeCondition.CPUUsage = newStats.cpuUsage
+	c.nodeCondition.MemoryUsage = newStats.memoryUsage
 	log.Infof("Get CPU: %v, Memory: %v", newStats.cpuUsage, newStats.memoryUsage)
 	// Compute Network IOPS. IOPS = (newIO - lastIO) / duration_time
 	newNetworkStat := statType{
@@ -477,6 +929,8 @@ func (c *conditionManager) GetNodeCondition() (*NodeCondition) {
 		log.Errorf("get network iops error, a negative value, ignore it")
 		networkTxBps = 0
 	}
+	c.nodeCondition.NetworkRxBps = networkRxBps
+	c.nodeCondition.NetworkTxBps = networkTxBps
 	log.Infof("get network %s Rx bps: %v Bytes/s, Tx bps: %v Bytes/s ",
 		newNetworkStat.name, int(networkRxBps), int(networkTxBps))
 
@@ -499,9 +953,17 @@ func (c *conditionManager) GetNodeCondition() (*NodeCondition) {
 		log.Errorf("get disk iops error, a negative value, ignore it")
 		diskIOPS = 0
 	}
+	c.nodeCondition.DiskIOPS = diskIOPS
+	c.nodeCondition.DiskDevice = newDiskIoStat.name
+	c.nodeCondition.DiskDevices = nil
+	if c.diskTopology != nil {
+		c.nodeCondition.DiskDevices = c.diskTopology.devices
+	}
+	c.nodeCondition.NetworkInterfaces = c.networkInterfaces
 	log.Infof("get disk %s, iops: %v", newDiskIoStat.name, int(diskIOPS))
 
-	if diskIOPS > float64(c.diskIoTotal) * c.taintThreshold["DiskIo"] {
+	if !c.disabledConditions[config.DiskIOCondition] &&
+		diskIOPS > c.threshold(config.DiskIOCondition).Value(float64(c.diskIoTotal))*c.burstFactor(config.DiskIOCondition) {
 			log.Infof("disk %s out of limits, iops: %v", newDiskIoStat.name, int(diskIOPS))
 			c.nodeCondition.DiskIOAvailable = false
 	} else {
@@ -509,37 +971,88 @@ func (c *conditionManager) GetNodeCondition() (*NodeCondition) {
 	}
 
 	// sum all network interfaces together
-	if networkRxBps > float64(len(c.networkInterfaces)) * float64(c.networkIoTotal) * c.taintThreshold["NetworkIo"]  {
+	networkThreshold := c.threshold(config.NetworkIOCondition).Value(float64(len(c.networkInterfaces)) * float64(c.networkIoTotal))
+	networkEnabled := !c.disabledConditions[config.NetworkIOCondition]
+	if networkEnabled && networkRxBps > networkThreshold {
 		log.Infof("network %s out of limis, Rx bps: %v", newNetworkStat.name, int(networkRxBps))
-		c.nodeCondition.NetworkRxAvailabel = false
+		c.nodeCondition.NetworkRxAvailable = false
 	} else {
-		c.nodeCondition.NetworkRxAvailabel = true
+		c.nodeCondition.NetworkRxAvailable = true
 	}
-	if networkTxBps > float64(len(c.networkInterfaces)) * float64(c.networkIoTotal) * c.taintThreshold["NetworkIo"]  {
+	if networkEnabled && networkTxBps > networkThreshold {
 		log.Infof("network %s out of limis, Tx bps: %v", newNetworkStat.name, int(networkTxBps))
-		c.nodeCondition.NetworkTxAvailabel = false
+		c.nodeCondition.NetworkTxAvailable = false
 	} else {
-		c.nodeCondition.NetworkTxAvailabel = true
+		c.nodeCondition.NetworkTxAvailable = true
 	}
-
+	c.nodeCondition.Thresholds = map[string]float64{
+		types.CPUBusy:       cpuThreshold * c.burstFactor(config.CPUCondition),
+		types.MemBusy:       memoryThreshold,
+		types.DiskIO:        c.threshold(config.DiskIOCondition).Value(float64(c.diskIoTotal)) * c.burstFactor(config.DiskIOCondition),
+		types.NetworkRxBusy: networkThreshold,
+		types.NetworkTxBusy: networkThreshold,
+	}
+	c.nodeCondition.Writeback = c.writebackCondition(&newStats)
+	if c.nodeCondition.Writeback != nil {
+		c.nodeCondition.Thresholds[types.WritebackBusy] = c.nodeCondition.Writeback.Threshold
+	}
+	c.nodeCondition.EphemeralPorts = c.portsCondition(&newStats)
+	if c.nodeCondition.EphemeralPorts != nil {
+		c.nodeCondition.Thresholds[types.EphemeralPortsBusy] = c.nodeCondition.EphemeralPorts.Threshold
+	}
+	c.nodeCondition.Extended = c.extendedConditions()
+	c.nodeCondition.Bands = c.bandConditions()
+	// traffic classes are checked instead of the node total if they are set
+	c.nodeCondition.TrafficClasses = nil
+	if networkEnabled {
+		c.nodeCondition.TrafficClasses = c.trafficClassConditions(&newStats, &lastStats, networkRxBps, networkTxBps)
+	}
+	if len(c.nodeCondition.TrafficClasses) != 0 {
+		c.nodeCondition.NetworkRxAvailable = trafficAvailable(c.nodeCondition.TrafficClasses, types.NetworkRxBusy)
+		c.nodeCondition.NetworkTxAvailable = trafficAvailable(c.nodeCondition.TrafficClasses, types.NetworkTxBusy)
+	}
+	c.nodeCondition.Severities = c.severities(&c.nodeCondition)
 
 	return &c.nodeCondition
 }
 
-// ChooseOnePodToEvict
-func (c *conditionManager) ChooseOnePodToEvict(evictType string) (*types.PodInfo, bool, string, error) {
+// ChooseOnePodToEvict chooses a pod for the eviction request, returns whether
+// it's evicted or labeled and the label
+func (c *conditionManager) ChooseOnePodToEvict(request types.Condition) (*types.PodInfo, bool, string, error) {
+	evictType := request.Type
 	isEvict := false
-	if len(c.nodeStats) != statsBufferLen {
+	if !c.HasSynced() {
 		log.Infof("wait for a minute")
 		return nil, isEvict, "", fmt.Errorf("wait for a minute")
 	}
+	if bandEvictType, band, ok := ParseBandEvictType(evictType); ok {
+		pod, isEvict, label, err := c.chooseBandPod(bandEvictType, band)
+		return pod, c.evictUnlessSidecar(pod, isEvict), label, err
+	}
+	if evictType == types.NetworkLinkDegraded {
+		return c.chooseLatencySensitivePod()
+	}
 
 	// Get lower priority pod, if autoEvict
-	pods, err := c.client.GetLowerPriorityPods(c.lowPriorityThreshold)
+	lowPriorityPods, err := c.client.GetLowerPriorityPods(c.getLowPriorityThreshold())
+	if err != nil {
+		return nil, isEvict, "", err
+	}
+
+	protected, err := c.protectedPods()
+	if err != nil {
+		return nil, isEvict, "", err
+	}
+	profiles, err := c.podProfiles()
 	if err != nil {
 		return nil, isEvict, "", err
 	}
 
+	c.statsLock.RLock()
+	c.policyLock.RLock()
+	// pods in protected namespaces, agent itself and infra pods are never chosen
+	pods := c.filterProtectedPods(lowPriorityPods, protected)
+
 	// if auto-evict and there are some lower priority pods, evict pod in agent.
 	if c.autoEvict {
 		if len(pods) != 0 {
@@ -548,201 +1061,87 @@ func (c *conditionManager) ChooseOnePodToEvict(evictType string) (*types.PodInfo
 	}
 
 	// Get pod which consume resource seriously
-	isEvicting, priority := c.getEvilPod(evictType, pods)
+	isEvicting, priority, err := c.getEvilPod(request, pods, protected, profiles)
+	c.policyLock.RUnlock()
+	c.statsLock.RUnlock()
+	if err != nil {
+		return nil, isEvict, "", err
+	}
 	if isEvicting {
 		return nil, isEvict, "", fmt.Errorf("Pod: %v is evicting...", c.podToEvict.Name)
 	}
 
-	return &c.podToEvict, isEvict, priority, nil
+	return &c.podToEvict, c.evictUnlessSidecar(&c.podToEvict, isEvict), priority, nil
+}
+
+// evictUnlessSidecar returns false if the offender container of pod is a
+// sidecar shared by pods, its owner fixes it instead of the owner of pod, or
+// else isEvict
+func (c *conditionManager) evictUnlessSidecar(pod *types.PodInfo, isEvict bool) bool {
+	if !isEvict || pod == nil || pod.Container == "" {
+		return isEvict
+	}
+	c.policyLock.RLock()
+	defer c.policyLock.RUnlock()
+	if c.sidecarContainers[pod.Container] {
+		log.Infof("offender container %s of pod %s/%s is a sidecar, label pod instead of evicting it",
+			pod.Container, pod.Namespace, pod.Name)
+		return false
+	}
+	return isEvict
 }
 
-// getEvilPod pick the pod which consume the resource most
-func (c *conditionManager) getEvilPod(evictType string, pods []types.PodInfo) (bool, string) {
+// getEvilPod pick the pod which consume the resource most, fit for request
+func (c *conditionManager) getEvilPod(request types.Condition, pods []types.PodInfo, protected map[string]string,
+	profiles map[string]types.PodProfile) (bool, string, error) {
+	evictType := request.Type
 	// check if it is evicting
 	priority := types.NeedEvict
+	c.lastRanking = Ranking{}
 	if len(pods) != 0 && c.autoEvict {
 		for _, pod := range pods {
 			if pod.Name == c.podToEvict.Name && pod.Namespace == c.podToEvict.Namespace {
-				return true, priority
+				return true, priority, nil
 			}
 		}
 	}
 	// compute and get the evil pod
-	if evictType == types.DiskIO {
-		evilValue := 0.0
-		weight := 0.0
-		evilPod := types.PodInfo{}
-		if len(pods) != 0 {
-			for _, pod := range pods {
-				keyName := pod.Namespace + "." + pod.Name
-				newPodDiskStats := c.nodeStats[statsBufferLen - 1].podStats[keyName].diskIOStats
-				lastPodDiskStats := c.nodeStats[statsBufferLen - 2].podStats[keyName].diskIOStats
-				iops := 1e9 * float64(newPodDiskStats.rx + newPodDiskStats.tx -
-					lastPodDiskStats.rx - lastPodDiskStats.tx) /
-					float64(newPodDiskStats.time.UnixNano() - lastPodDiskStats.time.UnixNano())
-				// choose the bigger weight
-				if pod.Priority == 0 {
-					weight = iops
-				} else {
-					weight = iops / float64(pod.Priority)
-				}
-
-				if weight > evilValue {
-					evilValue = weight
-					evilPod.Name = pod.Name
-					evilPod.Namespace = pod.Namespace
-					evilPod.Priority = pod.Priority
-				}
-			}
-			priority = types.NeedEvict
-			log.Infof("get evil pod: %v, iops: %v, priority: %v, diskio busy", evilPod.Name, evilValue, evilPod.Priority)
-		}
+	c.lastRanking = c.rankCandidates(evictType, pods, protected, profiles)
+	if err := c.fitRequest(request, &c.lastRanking); err != nil {
+		return false, priority, err
+	}
+	candidates := c.lastRanking.Candidates
+	if len(candidates) == 0 {
 		// find no pod consume these resources
-		if evilPod.Name == "" {
-			for keyName, pod := range c.nodeStats[statsBufferLen - 1].podStats {
-				newPodDiskStats := pod.diskIOStats
-				lastStats, ok := c.nodeStats[statsBufferLen - 2 ].podStats[keyName]
-				if ok {
-					lastPodDiskStats := lastStats.diskIOStats
-					iops := 1e9 * float64(newPodDiskStats.rx + newPodDiskStats.tx -
-						lastPodDiskStats.rx - lastPodDiskStats.tx) /
-						float64(newPodDiskStats.time.UnixNano() - lastPodDiskStats.time.UnixNano())
-					if iops > evilValue {
-						evilValue = iops
-						evilPod.Name = pod.name
-						evilPod.Namespace = pod.namespace
-					}
-				}
-			}
-			priority = types.EvictCandidate
-			log.Infof("get evil pod: %v, iops: %v from other pods, diskio busy", evilPod.Name, evilValue)
-		}
-		c.podToEvict = evilPod
-	} else if evictType == types.NetworkRxBusy || evictType == types.NetworkTxBusy {
-		evilValue := 0.0
-		weight := 0.0
-		evilPod := types.PodInfo{}
-		if len(pods) != 0 {
-			for _, pod := range pods {
-				bps := 0.0
-				keyName := pod.Namespace + "." + pod.Name
-				newPodNetStats := c.nodeStats[statsBufferLen - 1].podStats[keyName].netIOStats
-				lastPodNetStats := c.nodeStats[statsBufferLen - 2].podStats[keyName].netIOStats
-				if evictType == types.NetworkTxBusy {
-					bps = 1e9 * float64(newPodNetStats.tx - lastPodNetStats.tx) /
-						float64(newPodNetStats.time.UnixNano() - lastPodNetStats.time.UnixNano())
-				} else {
-					bps = 1e9 * float64(newPodNetStats.rx - lastPodNetStats.rx) /
-						float64(newPodNetStats.time.UnixNano() - lastPodNetStats.time.UnixNano())
-				}
-
-				// choose the bigger weight
-				if pod.Priority == 0 {
-					weight = bps
-				} else {
-					weight = bps / float64(pod.Priority)
-				}
-
-				if weight > evilValue {
-					evilValue = weight
-					evilPod.Name = pod.Name
-					evilPod.Namespace = pod.Namespace
-					evilPod.Priority = pod.Priority
-				}
-			}
-			priority = types.NeedEvict
-			log.Infof("get evil pod: %v, bps: %v, priority: %v, network busy", evilPod.Name, evilValue, evilPod.Priority)
-		}
-		if evilPod.Name == "" {
-			for keyName, pod := range c.nodeStats[statsBufferLen - 1].podStats {
-				newPodNetStats := pod.netIOStats
-				lastStats, ok := c.nodeStats[statsBufferLen - 2 ].podStats[keyName]
-				if ok {
-					lastPodNetStats := lastStats.netIOStats
-					iops := 1e9 * float64(newPodNetStats.rx + newPodNetStats.tx -
-						lastPodNetStats.rx - lastPodNetStats.tx) /
-						float64(newPodNetStats.time.UnixNano() - lastPodNetStats.time.UnixNano())
-					if iops > evilValue {
-						evilValue = iops
-						evilPod.Name = pod.name
-						evilPod.Namespace = pod.namespace
-					}
-				}
-			}
-			priority = types.EvictCandidate
-			log.Infof("get evil pod: %v, iops: %v from other pods, network busy", evilPod.Name, evilValue)
-		}
-		c.podToEvict = evilPod
-	} else if evictType == types.CPUBusy {
-		evilValue := 0.0
-		weight := 0.0
-		evilPod := types.PodInfo{}
-		if len(pods) != 0 {
-			for _, pod := range pods {
-				keyName := pod.Namespace + "." + pod.Name
-				cpuStats := c.nodeStats[statsBufferLen - 1].podStats[keyName].cpuUsage
-				// choose the bigger weight
-				if pod.Priority == 0 {
-					weight = cpuStats
-				} else {
-					weight = cpuStats / float64(pod.Priority)
-				}
-				if weight > evilValue {
-					evilValue = weight
-					evilPod.Name = pod.Name
-					evilPod.Namespace = pod.Namespace
-				}
-			}
-			priority = types.NeedEvict
-		}
-		if evilPod.Name == "" {
-			for _, pod := range c.nodeStats[statsBufferLen - 1].podStats {
-				cpuStats := pod.cpuUsage
-				if cpuStats > evilValue {
-					evilValue = cpuStats
-					evilPod.Name = pod.name
-					evilPod.Namespace = pod.namespace
-				}
-			}
-			priority = types.EvictCandidate
-		}
-		log.Infof("get evil pod: %v, cpu: %v", evilPod.Name, evilValue)
-		c.podToEvict = evilPod
-	} else if evictType == types.MemBusy{
-		evilValue := 0.0
-		weight := 0.0
-		evilPod := types.PodInfo{}
-		if len(pods) != 0 {
-			for _, pod := range pods {
-				keyName := pod.Namespace + "." + pod.Name
-				memStats := c.nodeStats[statsBufferLen - 1].podStats[keyName].memoryUsage
-				// choose the bigger weight
-				if pod.Priority == 0 {
-					weight = float64(memStats)
-				} else {
-					weight = float64(memStats) / float64(pod.Priority)
-				}
-				if weight > evilValue {
-					evilValue = weight
-					evilPod.Name = pod.Name
-					evilPod.Namespace = pod.Namespace
-				}
-			}
-			priority = types.NeedEvict
-		}
-		if evilPod.Name == "" {
-			for _, pod := range c.nodeStats[statsBufferLen - 1].podStats {
-				memStats := pod.memoryUsage
-				if float64(memStats) > evilValue {
-					evilValue = float64(memStats)
-					evilPod.Name = pod.name
-					evilPod.Namespace = pod.namespace
-				}
-			}
-			priority = types.EvictCandidate
-		}
-		log.Infof("get evil pod: %v, memory: %v", evilPod.Name, evilValue)
-		c.podToEvict = evilPod
+		log.Infof("get no evil pod, %s", evictType)
+		c.podToEvict = types.PodInfo{}
+		return false, types.EvictCandidate, nil
+	}
+	evil := candidates[0]
+	log.Infof("get evil pod: %v, usage: %v, score: %v, priority: %v, %s",
+		evil.Pod.Name, evil.Usage, evil.Score, evil.Pod.Priority, evictType)
+	c.podToEvict = evil.Pod
+	return false, evil.Label, nil
+}
+
+// checkStatsSource records a fatal error if summary api is unavailable for
+// statsGoneTimeout, err is the error of getting summary
+func (c *conditionManager) checkStatsSource(err error) {
+	if !summary.IsUnavailable(err) {
+		c.unavailableSince = time.Time{}
+		return
 	}
-	return false, priority
+	now := c.clock.Now()
+	if c.unavailableSince.IsZero() {
+		c.unavailableSince = now
+	} else if now.Sub(c.unavailableSince) >= statsGoneTimeout {
+		c.fatal.Store(types.NewFatalError(types.FatalStatsSourceGone,
+			fmt.Errorf("kubelet summary api is unavailable for %v: %v", now.Sub(c.unavailableSince), err)))
+	}
+}
+
+// Fatal returns the error stats sync can't recover from
+func (c *conditionManager) Fatal() error {
+	err, _ := c.fatal.Load().(error)
+	return err
 }
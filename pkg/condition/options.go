@@ -0,0 +1,37 @@
+package condition
+
+import (
+	"k8s.io/apimachinery/pkg/util/clock"
+
+	"eviction-agent/cmd/options"
+	"eviction-agent/pkg/config"
+	"eviction-agent/pkg/evictionclient"
+)
+
+// Option configures the condition manager created by New
+type Option func(*conditionManager)
+
+// WithClock uses clk for all timing
+func WithClock(clk clock.Clock) Option {
+	return func(c *conditionManager) {
+		c.clock = clk
+	}
+}
+
+// WithPolicy uses policy instead of the policy configuration file, an
+// EvictionPolicy selecting the node still takes precedence if it's enabled.
+// Overrides of environment and flags are applied on top of it.
+func WithPolicy(policy *config.PolicyConfig) Option {
+	return func(c *conditionManager) {
+		c.policy = policy.DeepCopy()
+	}
+}
+
+// New creates a condition manager of client configured by eao and opts
+func New(client evictionclient.Client, eao *options.EvictionAgentOptions, opts ...Option) ConditionManager {
+	c := NewConditionManagerWithClock(client, eao, clock.RealClock{}).(*conditionManager)
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
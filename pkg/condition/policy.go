@@ -0,0 +1,205 @@
+package condition
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/watch"
+
+	"eviction-agent/pkg/apis/v1alpha1"
+	"eviction-agent/pkg/config"
+	"eviction-agent/pkg/log"
+)
+
+const (
+	// evictionPolicyRetryPeriod is the period to wait before listing
+	// EvictionPolicy custom resources again after list or watch fails
+	evictionPolicyRetryPeriod = 10 * time.Second
+	// nodePoolSyncPeriod is the period to check labels of node against node
+	// pools and selectors of EvictionPolicy
+	nodePoolSyncPeriod = 30 * time.Second
+)
+
+// evictionPolicyWatcher lists EvictionPolicy custom resources and watches
+// their changes, reload policy configuration if the policy selecting this
+// node is changed. They are listed again if the watch fails, e.g. its
+// resource version is too old.
+func (c *conditionManager) evictionPolicyWatcher(ctx context.Context) {
+	log.Infof("Start eviction policy watcher\n")
+	resourceVersion := ""
+	for {
+		if resourceVersion == "" {
+			var err error
+			resourceVersion, err = c.listEvictionPolicies()
+			if err != nil {
+				log.Errorf("list eviction policies error: %v", err)
+				if !c.sleep(ctx, evictionPolicyRetryPeriod) {
+					return
+				}
+				continue
+			}
+			c.reselectEvictionPolicy()
+		}
+		var err error
+		resourceVersion, err = c.watchEvictionPolicies(ctx, resourceVersion)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Errorf("watch eviction policies error: %v", err)
+			resourceVersion = ""
+			if !c.sleep(ctx, evictionPolicyRetryPeriod) {
+				return
+			}
+		}
+	}
+}
+
+// listEvictionPolicies lists EvictionPolicy custom resources, returns the
+// resource version of the list to watch from
+func (c *conditionManager) listEvictionPolicies() (string, error) {
+	policies, err := c.client.ListEvictionPolicies()
+	if err != nil {
+		return "", err
+	}
+	items := make(map[string]v1alpha1.EvictionPolicy, len(policies.Items))
+	for _, policy := range policies.Items {
+		items[policy.Name] = policy
+	}
+	c.policyLock.Lock()
+	c.evictionPolicies = items
+	c.policyLock.Unlock()
+	return policies.ResourceVersion, nil
+}
+
+// watchEvictionPolicies applies changes of EvictionPolicy custom resources
+// after resourceVersion until the watch ends, returns the resource version
+// of the last change
+func (c *conditionManager) watchEvictionPolicies(ctx context.Context, resourceVersion string) (string, error) {
+	w, err := c.client.WatchEvictionPolicies(resourceVersion)
+	if err != nil {
+		return resourceVersion, err
+	}
+	defer w.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return resourceVersion, nil
+		case event, ok := <-w.ResultChan():
+			if !ok {
+				return resourceVersion, w.Err()
+			}
+			resourceVersion = event.Policy.ResourceVersion
+			c.policyLock.Lock()
+			if event.Type == watch.Deleted {
+				delete(c.evictionPolicies, event.Policy.Name)
+			} else {
+				c.evictionPolicies[event.Policy.Name] = event.Policy
+			}
+			c.policyLock.Unlock()
+			c.reselectEvictionPolicy()
+		}
+	}
+}
+
+// reselectEvictionPolicy reload policy configuration if another
+// EvictionPolicy, or a changed one, selects this node
+func (c *conditionManager) reselectEvictionPolicy() {
+	changed, err := c.syncEvictionPolicy()
+	if err != nil {
+		log.Errorf("sync eviction policy error: %v", err)
+		return
+	}
+	if changed {
+		c.reloadPolicyConfig("EvictionPolicy changed")
+	}
+}
+
+// syncEvictionPolicy gets the EvictionPolicy selecting this node of the
+// listed and watched ones, returns true if it's different from the current one
+func (c *conditionManager) syncEvictionPolicy() (bool, error) {
+	nodeLabels, err := c.client.GetNodeLabels()
+	if err != nil {
+		return false, err
+	}
+
+	c.policyLock.RLock()
+	policies := make([]v1alpha1.EvictionPolicy, 0, len(c.evictionPolicies))
+	for _, policy := range c.evictionPolicies {
+		policies = append(policies, policy)
+	}
+	c.policyLock.RUnlock()
+	policy := selectEvictionPolicy(policies, nodeLabels)
+	if policy == nil && c.evictionPolicy == nil {
+		return false, nil
+	}
+	if policy != nil && c.evictionPolicy != nil && policy.UID == c.evictionPolicy.UID &&
+		policy.ResourceVersion == c.evictionPolicy.ResourceVersion {
+		return false, nil
+	}
+
+	if policy != nil {
+		log.Infof("EvictionPolicy %v (resourceVersion %v) selects this node",
+			policy.Name, policy.ResourceVersion)
+	} else {
+		log.Infof("There is no EvictionPolicy selecting this node, use policy configuration file")
+	}
+	c.policyLock.Lock()
+	c.evictionPolicy = policy
+	c.policyLock.Unlock()
+	return true, nil
+}
+
+// selectEvictionPolicy returns the most specific policy whose node selector
+// matches node labels, policies with the same number of selectors are ordered by name
+func selectEvictionPolicy(policies []v1alpha1.EvictionPolicy, nodeLabels map[string]string) *v1alpha1.EvictionPolicy {
+	var matched []v1alpha1.EvictionPolicy
+	for _, policy := range policies {
+		selector := labels.SelectorFromSet(labels.Set(policy.Spec.NodeSelector))
+		if selector.Matches(labels.Set(nodeLabels)) {
+			matched = append(matched, policy)
+		}
+	}
+	if len(matched) == 0 {
+		return nil
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		if len(matched[i].Spec.NodeSelector) != len(matched[j].Spec.NodeSelector) {
+			return len(matched[i].Spec.NodeSelector) > len(matched[j].Spec.NodeSelector)
+		}
+		return matched[i].Name < matched[j].Name
+	})
+	return &matched[0]
+}
+
+// nodePoolWatcher checks labels of node periodically against selectors of
+// EvictionPolicy and node pools of the policy, reload policy configuration
+// if another policy or pool selects this node
+func (c *conditionManager) nodePoolWatcher(ctx context.Context) {
+	for c.sleep(ctx, nodePoolSyncPeriod) {
+		if c.enablePolicyCRD {
+			c.reselectEvictionPolicy()
+		}
+		c.policyLock.RLock()
+		pools, current := c.nodePools, c.nodePool
+		c.policyLock.RUnlock()
+		if len(pools) == 0 {
+			continue
+		}
+		nodeLabels, err := c.client.GetNodeLabels()
+		if err != nil {
+			log.Errorf("get node labels for node pools error: %v", err)
+			continue
+		}
+		selected := ""
+		if pool := config.SelectNodePool(pools, nodeLabels); pool != nil {
+			selected = pool.Name
+		}
+		if selected != current {
+			c.reloadPolicyConfig(fmt.Sprintf("node pool changed from %q to %q", current, selected))
+		}
+	}
+}
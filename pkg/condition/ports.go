@@ -0,0 +1,201 @@
+package condition
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/metrics"
+	"eviction-agent/pkg/summary"
+)
+
+// portsSource is the stats source of ephemeral ports and sockets of pods
+const portsSource = "ports"
+
+const (
+	// procPortRange is the range of ephemeral ports of node
+	procPortRange = "/proc/sys/net/ipv4/ip_local_port_range"
+	// tcpTimeWait is the state of TIME_WAIT sockets in /proc/net/tcp
+	tcpTimeWait = "06"
+)
+
+// procNetTCP are tcp sockets of network namespace of node
+var procNetTCP = []string{"/proc/net/tcp", "/proc/net/tcp6"}
+
+var (
+	ephemeralPorts = metrics.NewGaugeVec("eviction_agent_ephemeral_ports",
+		"Ephemeral ports of node of the last stats by state, used, time_wait or total.", "state")
+)
+
+// portStats are ephemeral ports of network namespace of node
+type portStats struct {
+	ok       bool // false if sockets are not read
+	total    int  // size of ip_local_port_range
+	used     int  // local ports of sockets in range, including TIME_WAIT
+	timeWait int
+}
+
+// PortsCondition is the usage of ephemeral ports of node
+type PortsCondition struct {
+	Used      int
+	TimeWait  int
+	Total     int
+	Threshold float64
+	// Busy is true if used ports are over ephemeralPorts of policy
+	Busy bool
+}
+
+// collectPorts counts used ephemeral ports of node, and open sockets of pods
+// by processes of their cgroups, if ephemeral ports are checked
+func collectPorts(stats *summary.ConditionStats, in collectInput, out *nodeStatsType) {
+	if !in.ports {
+		return
+	}
+	ports, err := readPortStats()
+	if err != nil {
+		log.Errorf("read ephemeral ports error: %v", err)
+		return
+	}
+	out.portStats = ports
+
+	cgroups := podCgroups()
+	pods := cgroupPods(stats, cgroups)
+	out.podSockets = make(map[string]uint64, len(pods))
+	attributePods(portsSource, stats, pods, in, func(i int) (interface{}, error) {
+		return uint64(countSockets(cgroupProcs(cgroups[stats.PodStats[i].PodRef.UID]))), nil
+	}, func(i int, value interface{}) {
+		pod := stats.PodStats[i].PodRef
+		out.podSockets[pod.Namespace+"."+pod.Name] = value.(uint64)
+	})
+}
+
+// readPortStats reads the ephemeral port range and tcp sockets of node
+func readPortStats() (portStats, error) {
+	data, err := ioutil.ReadFile(procPortRange)
+	if err != nil {
+		return portStats{}, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) != 2 {
+		return portStats{}, fmt.Errorf("invalid %s: %q", procPortRange, data)
+	}
+	low, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return portStats{}, fmt.Errorf("invalid %s: %q", procPortRange, data)
+	}
+	high, err := strconv.Atoi(fields[1])
+	if err != nil || high < low {
+		return portStats{}, fmt.Errorf("invalid %s: %q", procPortRange, data)
+	}
+	stats := portStats{ok: true, total: high - low + 1}
+	for _, file := range procNetTCP {
+		data, err := ioutil.ReadFile(file)
+		if os.IsNotExist(err) {
+			// ipv6 is disabled
+			continue
+		}
+		if err != nil {
+			return portStats{}, err
+		}
+		used, timeWait := parseNetTCP(data, low, high)
+		stats.used += used
+		stats.timeWait += timeWait
+	}
+	return stats, nil
+}
+
+// parseNetTCP returns sockets of /proc/net/tcp whose local ports are in
+// [low, high], and those of them in TIME_WAIT
+func parseNetTCP(data []byte, low, high int) (int, int) {
+	var used, timeWait int
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	// the first line is the header
+	scanner.Scan()
+	for scanner.Scan() {
+		// sl local_address rem_address st ..., e.g. 0: 0100007F:9C40 0100007F:1F90 06
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+		i := strings.LastIndex(fields[1], ":")
+		if i < 0 {
+			continue
+		}
+		port, err := strconv.ParseInt(fields[1][i+1:], 16, 32)
+		if err != nil || int(port) < low || int(port) > high {
+			continue
+		}
+		used++
+		if fields[3] == tcpTimeWait {
+			timeWait++
+		}
+	}
+	return used, timeWait
+}
+
+// cgroupProcs returns processes of cgroup dir and cgroups of its containers
+func cgroupProcs(dir string) map[string]bool {
+	pids := make(map[string]bool)
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
+			return nil
+		}
+		data, err := ioutil.ReadFile(filepath.Join(path, "cgroup.procs"))
+		if err != nil {
+			return nil
+		}
+		for _, pid := range strings.Fields(string(data)) {
+			pids[pid] = true
+		}
+		return nil
+	})
+	return pids
+}
+
+// countSockets returns open sockets of processes, processes exited are ignored
+func countSockets(pids map[string]bool) int {
+	sockets := 0
+	for pid := range pids {
+		fdDir := filepath.Join("/proc", pid, "fd")
+		fds, err := ioutil.ReadDir(fdDir)
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			if link, err := os.Readlink(filepath.Join(fdDir, fd.Name())); err == nil && strings.HasPrefix(link, "socket:") {
+				sockets++
+			}
+		}
+	}
+	return sockets
+}
+
+// portsCondition returns the usage of ephemeral ports of the last stats, nil
+// if they are not checked. policyLock must be held.
+func (c *conditionManager) portsCondition(newStats *nodeStatsType) *PortsCondition {
+	if c.ephemeralPorts.Threshold == nil || !newStats.portStats.ok {
+		return nil
+	}
+	stats := newStats.portStats
+	ports := &PortsCondition{
+		Used:      stats.used,
+		TimeWait:  stats.timeWait,
+		Total:     stats.total,
+		Threshold: c.ephemeralPorts.Threshold.Value(float64(stats.total)),
+	}
+	ephemeralPorts.Set(float64(stats.used), "used")
+	ephemeralPorts.Set(float64(stats.timeWait), "time_wait")
+	ephemeralPorts.Set(float64(stats.total), "total")
+	ports.Busy = float64(stats.used) > ports.Threshold
+	if ports.Busy {
+		log.Infof("ephemeral ports out of limits, used: %d of %d, time wait: %d",
+			stats.used, stats.total, stats.timeWait)
+	}
+	return ports
+}
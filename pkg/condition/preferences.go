@@ -0,0 +1,181 @@
+package condition
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"eviction-agent/cmd/options"
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/types"
+)
+
+const (
+	// namespacePreferenceSyncPeriod is the period to sync annotations of namespaces
+	namespacePreferenceSyncPeriod = 30 * time.Second
+	// namespaceBudgetWindow is the window of max evictions per hour
+	namespaceBudgetWindow = time.Hour
+)
+
+// NamespacePreference is the eviction preference of pods of a namespace by
+// its annotations, tenant teams set it without changing policy of cluster
+type NamespacePreference struct {
+	// Weight multiplies scores of pods, 1 if not set
+	Weight float64
+	// MaxEvictionsPerHour limits evictions of pods, unlimited if zero
+	MaxEvictionsPerHour int
+	// Protected pods are never chosen
+	Protected bool
+}
+
+// namespaceEvictions are eviction times of namespaces in the last hour, for
+// max evictions per hour
+type namespaceEvictions struct {
+	lock      sync.Mutex
+	evictions map[string][]time.Time // by namespace, the oldest first
+}
+
+// newNamespacePreferences returns no preference if they are enabled, nil if they aren't
+func newNamespacePreferences(eao *options.EvictionAgentOptions) map[string]NamespacePreference {
+	if !eao.NamespacePreferences {
+		return nil
+	}
+	return make(map[string]NamespacePreference)
+}
+
+// parseNamespacePreference returns the preference of namespace annotations,
+// false if there is none. Invalid values are ignored.
+func parseNamespacePreference(namespace string, annotations map[string]string) (NamespacePreference, bool) {
+	preference := NamespacePreference{Weight: 1}
+	found := false
+	if value, ok := annotations[types.EvictionWeightAnnotation]; ok {
+		if weight, err := strconv.ParseFloat(value, 64); err == nil && weight > 0 {
+			preference.Weight = weight
+			found = true
+		} else {
+			log.Warnf("ignore invalid %s %q of namespace %s, should be a positive number",
+				types.EvictionWeightAnnotation, value, namespace)
+		}
+	}
+	if value, ok := annotations[types.MaxEvictionsPerHourAnnotation]; ok {
+		if max, err := strconv.Atoi(value); err == nil && max > 0 {
+			preference.MaxEvictionsPerHour = max
+			found = true
+		} else {
+			log.Warnf("ignore invalid %s %q of namespace %s, should be a positive integer",
+				types.MaxEvictionsPerHourAnnotation, value, namespace)
+		}
+	}
+	if value, ok := annotations[types.EvictionProtectedAnnotation]; ok {
+		if protected, err := strconv.ParseBool(value); err == nil {
+			preference.Protected = protected
+			found = true
+		} else {
+			log.Warnf("ignore invalid %s %q of namespace %s, should be true or false",
+				types.EvictionProtectedAnnotation, value, namespace)
+		}
+	}
+	return preference, found
+}
+
+// namespacePreferenceWatcher syncs preferences of namespaces periodically,
+// the last ones are kept if it fails
+func (c *conditionManager) namespacePreferenceWatcher(ctx context.Context) {
+	log.Infof("Start namespace preference watcher")
+	for c.sleep(ctx, namespacePreferenceSyncPeriod) {
+		if err := c.syncNamespacePreferences(); err != nil {
+			log.Errorf("sync namespace preferences error: %v", err)
+		}
+	}
+}
+
+// syncNamespacePreferences gets preferences of annotations of namespaces
+func (c *conditionManager) syncNamespacePreferences() error {
+	namespaces, err := c.client.GetNamespaceAnnotations()
+	if err != nil {
+		return err
+	}
+	preferences := make(map[string]NamespacePreference)
+	for namespace, annotations := range namespaces {
+		if preference, ok := parseNamespacePreference(namespace, annotations); ok {
+			preferences[namespace] = preference
+		}
+	}
+	c.policyLock.Lock()
+	c.namespacePreferences = preferences
+	c.policyLock.Unlock()
+	return nil
+}
+
+// namespaceWeight returns the weight of scores of pods of namespace,
+// policyLock must be held
+func (c *conditionManager) namespaceWeight(namespace string) float64 {
+	if preference, ok := c.namespacePreferences[namespace]; ok {
+		return preference.Weight
+	}
+	return 1
+}
+
+// namespaceExcluded returns why pods of namespace are never chosen by its
+// preference, empty if they may be. policyLock must be held.
+func (c *conditionManager) namespaceExcluded(namespace string) string {
+	preference, ok := c.namespacePreferences[namespace]
+	if !ok {
+		return ""
+	}
+	if preference.Protected {
+		return ExcludedProtectedNamespace
+	}
+	if preference.MaxEvictionsPerHour > 0 &&
+		c.namespaceEvictions.count(namespace, c.clock.Now()) >= preference.MaxEvictionsPerHour {
+		return ExcludedNamespaceBudget
+	}
+	return ""
+}
+
+// RecordEviction counts an eviction of pod against max evictions per hour of
+// its namespace
+func (c *conditionManager) RecordEviction(pod types.PodInfo) {
+	c.policyLock.RLock()
+	enabled := c.namespacePreferences != nil
+	c.policyLock.RUnlock()
+	if !enabled {
+		return
+	}
+	c.namespaceEvictions.record(pod.Namespace, c.clock.Now())
+}
+
+func (n *namespaceEvictions) record(namespace string, now time.Time) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	if n.evictions == nil {
+		n.evictions = make(map[string][]time.Time)
+	}
+	n.prune(now)
+	n.evictions[namespace] = append(n.evictions[namespace], now)
+}
+
+// count returns evictions of namespace in the last hour
+func (n *namespaceEvictions) count(namespace string, now time.Time) int {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.prune(now)
+	return len(n.evictions[namespace])
+}
+
+// prune drops evictions out of window, lock must be held
+func (n *namespaceEvictions) prune(now time.Time) {
+	start := now.Add(-namespaceBudgetWindow)
+	for namespace, times := range n.evictions {
+		i := 0
+		for i < len(times) && times[i].Before(start) {
+			i++
+		}
+		if i == len(times) {
+			delete(n.evictions, namespace)
+		} else {
+			n.evictions[namespace] = times[i:]
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package condition
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/metrics"
+	"eviction-agent/pkg/summary"
+)
+
+// procVmstat is where reclaim activity is read, it's of the whole node
+const procVmstat = "/proc/vmstat"
+
+// reclaimSource is the stats source of reclaim activity
+const reclaimSource = "reclaim"
+
+var (
+	memoryReclaimRates = metrics.NewGaugeVec("eviction_agent_memory_reclaim_rate",
+		"Rates of kernel reclaim activity per second of the last stats by signal.", "signal")
+)
+
+// reclaimStats are counters of reclaim activity, summed over zones of kernels
+// counting them by zone, e.g. pgscan_kswapd_normal
+type reclaimStats struct {
+	ok          bool // false if vmstat is not read
+	kswapdScan  uint64
+	directScan  uint64
+	allocStalls uint64
+}
+
+// ReclaimCondition is the rates of reclaim activity per second
+type ReclaimCondition struct {
+	KswapdScanRate float64
+	DirectScanRate float64
+	AllocStallRate float64
+	// Busy is true if any rate is over memoryReclaim of policy
+	Busy bool
+}
+
+// collectReclaim reads reclaim counters of /proc/vmstat if memory reclaim is checked
+func collectReclaim(stats *summary.ConditionStats, in collectInput, out *nodeStatsType) {
+	if !in.reclaim {
+		return
+	}
+	data, err := ioutil.ReadFile(procVmstat)
+	if err != nil {
+		log.Errorf("read %s error: %v", procVmstat, err)
+		return
+	}
+	out.reclaimStats = parseVmstat(data)
+}
+
+// parseVmstat sums counters of reclaim activity in vmstat
+func parseVmstat(data []byte) reclaimStats {
+	stats := reclaimStats{ok: true}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		value, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		switch name := fields[0]; {
+		case strings.HasPrefix(name, "pgscan_kswapd"):
+			stats.kswapdScan += value
+		case strings.HasPrefix(name, "pgscan_direct") && !strings.HasPrefix(name, "pgscan_direct_throttle"):
+			stats.directScan += value
+		case name == "allocstall" || strings.HasPrefix(name, "allocstall_"):
+			stats.allocStalls += value
+		}
+	}
+	return stats
+}
+
+// reclaimCondition returns rates of reclaim activity between the last two
+// stats, nil if memory reclaim is not checked. policyLock must be held.
+func (c *conditionManager) reclaimCondition(newStats, lastStats *nodeStatsType) *ReclaimCondition {
+	if !c.memoryReclaim.Enabled() {
+		return nil
+	}
+	seconds := newStats.time.Sub(lastStats.time).Seconds()
+	if seconds <= 0 || !newStats.reclaimStats.ok || !lastStats.reclaimStats.ok {
+		return nil
+	}
+	rate := func(newValue, lastValue uint64) float64 {
+		if newValue < lastValue {
+			// counters are reset
+			return 0
+		}
+		return float64(newValue-lastValue) / seconds
+	}
+	newReclaim, lastReclaim := newStats.reclaimStats, lastStats.reclaimStats
+	reclaim := &ReclaimCondition{
+		KswapdScanRate: rate(newReclaim.kswapdScan, lastReclaim.kswapdScan),
+		DirectScanRate: rate(newReclaim.directScan, lastReclaim.directScan),
+		AllocStallRate: rate(newReclaim.allocStalls, lastReclaim.allocStalls),
+	}
+	memoryReclaimRates.Set(reclaim.KswapdScanRate, "kswapd_scan")
+	memoryReclaimRates.Set(reclaim.DirectScanRate, "direct_scan")
+	memoryReclaimRates.Set(reclaim.AllocStallRate, "alloc_stall")
+	reclaim.Busy = exceeds(reclaim.KswapdScanRate, c.memoryReclaim.KswapdScanRate) ||
+		exceeds(reclaim.DirectScanRate, c.memoryReclaim.DirectScanRate) ||
+		exceeds(reclaim.AllocStallRate, c.memoryReclaim.AllocStallRate)
+	if reclaim.Busy {
+		log.Infof("memory reclaim out of limits, kswapd scan: %.0f pages/s, direct scan: %.0f pages/s, "+
+			"allocation stalls: %.2f/s", reclaim.KswapdScanRate, reclaim.DirectScanRate, reclaim.AllocStallRate)
+	}
+	return reclaim
+}
+
+// exceeds returns true if rate is over limit, a limit of zero is not checked
+func exceeds(rate, limit float64) bool {
+	return limit > 0 && rate > limit
+}
@@ -0,0 +1,194 @@
+package condition
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/clock"
+
+	"eviction-agent/pkg/config"
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/types"
+)
+
+// Record is a stats sample recorded by --record, with what is needed to rank
+// candidates offline
+type Record struct {
+	StatsSample
+	// Totals are the capacities of node at recording time
+	Totals types.NodeIOPSTotal `json:"totals"`
+	// Priorities are priorities of pods with one, keyed by namespace/name
+	Priorities map[string]int `json:"priorities,omitempty"`
+	// Protected are exclusions of agent itself and infra pods keyed by namespace/name
+	Protected map[string]string `json:"protected,omitempty"`
+}
+
+// openRecord opens the file of --record, records are appended to it
+func (c *conditionManager) openRecord() error {
+	if c.recordPath == "" {
+		return nil
+	}
+	file, err := os.OpenFile(c.recordPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("open record file %s: %v", c.recordPath, err)
+	}
+	c.recordFile = file
+	log.Infof("Record stats samples to %s", c.recordPath)
+	return nil
+}
+
+// recordStats appends stats with pod priorities and protected pods to the
+// record file as a json line, they are recorded without pods if listing fails
+func (c *conditionManager) recordStats(stats *nodeStatsType) {
+	if c.recordFile == nil {
+		return
+	}
+	record := Record{StatsSample: newStatsSample(stats)}
+	c.policyLock.RLock()
+	record.Totals = types.NodeIOPSTotal{
+		DiskIOPSTotal:   c.diskIoTotal,
+		NetworkBPSTotal: c.networkIoTotal,
+		CPUTotal:        c.cpuTotal,
+		MemoryTotal:     c.memTotal,
+	}
+	c.policyLock.RUnlock()
+	if pods, err := c.client.GetLowerPriorityPods(math.MaxInt32); err != nil {
+		log.Warnf("record priorities of pods error: %v", err)
+	} else {
+		record.Priorities = make(map[string]int, len(pods))
+		for _, pod := range pods {
+			record.Priorities[pod.Namespace+"/"+pod.Name] = pod.Priority
+		}
+	}
+	if protected, err := c.protectedPods(); err != nil {
+		log.Warnf("record protected pods error: %v", err)
+	} else {
+		record.Protected = protected
+	}
+	data, err := json.Marshal(record)
+	if err == nil {
+		_, err = c.recordFile.Write(append(data, '\n'))
+	}
+	if err != nil {
+		log.Errorf("record stats to %s error: %v", c.recordPath, err)
+	}
+}
+
+// LoadRecords reads records of --record from file
+func LoadRecords(file string) ([]Record, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var records []Record
+	decoder := json.NewDecoder(f)
+	for {
+		var record Record
+		if err := decoder.Decode(&record); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("record %d of %s: %v", len(records)+1, file, err)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// Replayer feeds records through condition checks and candidate ranking of a
+// policy offline, the same way as stats sync does
+type Replayer struct {
+	c *conditionManager
+}
+
+// NewReplayer creates a replayer of policy on a node of totals, capacities
+// set in policy override them
+func NewReplayer(policy *config.PolicyConfig, totals types.NodeIOPSTotal) (*Replayer, error) {
+	if err := policy.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid policy configuration: %v", err)
+	}
+	c := &conditionManager{
+		clock: clock.RealClock{},
+		nodeCondition: NodeCondition{
+			CPUAvailable:       true,
+			MemoryAvailable:    true,
+			DiskIOAvailable:    true,
+			NetworkRxAvailable: true,
+			NetworkTxAvailable: true,
+		},
+		taintThreshold:      make(map[string]config.Threshold),
+		diskIoTotal:         totals.DiskIOPSTotal,
+		networkIoTotal:      totals.NetworkBPSTotal,
+		cpuTotal:            totals.CPUTotal,
+		memTotal:            totals.MemoryTotal,
+		untaintGracePeriod:  unTaintGracePeriod,
+		protectedNamespaces: make(map[string]bool),
+		disabledConditions:  make(map[string]bool),
+		selfThrottle:        1,
+	}
+	c.setDefaultThresholds()
+	c.policyLock.Lock()
+	c.applyPolicy(policy)
+	c.policyLock.Unlock()
+	return &Replayer{c: c}, nil
+}
+
+// Add adds stats of record, returns the node condition, or nil if there are
+// not enough stats or the record is not newer than the last one
+func (r *Replayer) Add(record *Record) *NodeCondition {
+	c := r.c
+	stats := record.nodeStats()
+	c.statsLock.Lock()
+	if c.nodeStats.len() != 0 && !stats.time.After(c.nodeStats.last().time) {
+		c.statsLock.Unlock()
+		return nil
+	}
+	c.nodeStats.push(stats, statsBufferLen)
+	if c.nodeStats.len() == statsBufferLen {
+		atomic.StoreInt32(&c.synced, 1)
+	}
+	c.statsLock.Unlock()
+	if !c.HasSynced() {
+		return nil
+	}
+	nodeCondition := *c.GetNodeCondition()
+	return &nodeCondition
+}
+
+// Rank ranks candidates of evictType in the last added record, lower priority
+// pods and protected pods are from record
+func (r *Replayer) Rank(evictType string, record *Record) Ranking {
+	c := r.c
+	c.statsLock.RLock()
+	defer c.statsLock.RUnlock()
+	c.policyLock.RLock()
+	defer c.policyLock.RUnlock()
+	var pods []types.PodInfo
+	for key, priority := range record.Priorities {
+		parts := strings.SplitN(key, "/", 2)
+		if len(parts) == 2 && priority > 0 && priority <= c.lowPriorityThreshold {
+			pods = append(pods, types.PodInfo{Namespace: parts[0], Name: parts[1], Priority: priority})
+		}
+	}
+	sort.Slice(pods, func(i, j int) bool {
+		return pods[i].Namespace+"/"+pods[i].Name < pods[j].Namespace+"/"+pods[j].Name
+	})
+	return c.rankCandidates(evictType, c.filterProtectedPods(pods, record.Protected), record.Protected, nil)
+}
+
+// AutoEvict returns true if lower priority pods are evicted instead of labeled
+func (r *Replayer) AutoEvict() bool {
+	return r.c.autoEvict
+}
+
+// UntaintGracePeriod returns the untaint grace period of policy
+func (r *Replayer) UntaintGracePeriod() time.Duration {
+	return r.c.GetUnTaintGracePeriod()
+}
@@ -0,0 +1,53 @@
+package condition
+
+import (
+	"math"
+
+	"eviction-agent/pkg/config"
+)
+
+// DaemonShare is the usage of a resource by system daemons, i.e. the usage of
+// node not by pods, and the headroom reserved for them
+type DaemonShare struct {
+	Usage    float64 `json:"usage"`
+	Reserved float64 `json:"reserved"`
+}
+
+// daemonShares returns shares of system daemons of conditions with reserved
+// headroom in stats, policyLock must be held
+func (c *conditionManager) daemonShares(stats *nodeStatsType) map[string]DaemonShare {
+	if len(c.systemReserved) == 0 {
+		return nil
+	}
+	var podsCPU, podsMemory float64
+	for _, pod := range stats.podStats {
+		podsCPU += pod.cpuUsage
+		podsMemory += float64(pod.memoryUsage)
+	}
+	shares := make(map[string]DaemonShare)
+	if reserved, ok := c.systemReserved[config.CPUCondition]; ok {
+		shares[config.CPUCondition] = DaemonShare{
+			Usage:    math.Max(stats.cpuUsage-podsCPU, 0),
+			Reserved: reserved.Value(float64(c.cpuTotal)),
+		}
+	}
+	if reserved, ok := c.systemReserved[config.MemoryCondition]; ok {
+		shares[config.MemoryCondition] = DaemonShare{
+			Usage:    math.Max(float64(stats.memoryUsage)-podsMemory, 0),
+			Reserved: reserved.Value(float64(c.memTotal)),
+		}
+	}
+	return shares
+}
+
+// checkedUsage returns the usage checked against threshold of condition and the
+// threshold, usage of pods and the threshold of capacity minus the headroom if
+// headroom is reserved for system daemons
+func (c *conditionManager) checkedUsage(condition string, usage, capacity float64,
+	shares map[string]DaemonShare) (float64, float64) {
+	if share, ok := shares[condition]; ok {
+		usage -= share.Usage
+		capacity = math.Max(capacity-share.Reserved, 0)
+	}
+	return usage, c.threshold(condition).Value(capacity)
+}
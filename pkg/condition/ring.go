@@ -0,0 +1,117 @@
+package condition
+
+import (
+	"reflect"
+	"sort"
+	"unsafe"
+
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/metrics"
+)
+
+// mapEntryOverhead is the estimated bytes of buckets and hashes per map entry
+const mapEntryOverhead = 16
+
+var (
+	statsMemoryBytes = metrics.NewGaugeVec("eviction_agent_stats_memory_bytes",
+		"Estimated bytes of stats samples kept in memory.")
+	droppedPods = metrics.NewGaugeVec("eviction_agent_stats_dropped_pods",
+		"Number of pods beyond --max-pod-stats dropped from the last stats by source.", "source")
+)
+
+// statsRing is the pre-allocated buffer of the last statsBufferLen node stats
+type statsRing struct {
+	samples [statsBufferLen]nodeStatsType
+	start   int // index of the oldest stats
+	count   int
+}
+
+func (r *statsRing) len() int {
+	return r.count
+}
+
+// at returns the i-th oldest stats
+func (r *statsRing) at(i int) *nodeStatsType {
+	return &r.samples[(r.start+i)%statsBufferLen]
+}
+
+// last returns the newest stats, the ring must not be empty
+func (r *statsRing) last() *nodeStatsType {
+	return r.at(r.count - 1)
+}
+
+// push adds stats after dropping the oldest ones, so that at most window
+// stats are kept. Dropped slots are cleared to release their pods.
+func (r *statsRing) push(stats nodeStatsType, window int) {
+	if window > statsBufferLen {
+		window = statsBufferLen
+	}
+	for r.count > 0 && r.count >= window {
+		r.samples[r.start] = nodeStatsType{}
+		r.start = (r.start + 1) % statsBufferLen
+		r.count--
+	}
+	r.samples[(r.start+r.count)%statsBufferLen] = stats
+	r.count++
+}
+
+// memoryBytes estimates bytes of stats in ring, pods shared by stats of a
+// failed pod source are counted once
+func (r *statsRing) memoryBytes() int {
+	size := int(unsafe.Sizeof(*r))
+	counted := make(map[uintptr]bool)
+	for i := 0; i < r.count; i++ {
+		stats := r.at(i)
+		size += len(stats.netIOStats.name) + len(stats.diskIOStats.name)
+		if p := reflect.ValueOf(stats.podStats).Pointer(); p != 0 && !counted[p] {
+			counted[p] = true
+			for key, pod := range stats.podStats {
+				size += int(unsafe.Sizeof(pod)) + mapEntryOverhead + len(key) + len(pod.name) +
+					len(pod.namespace) + len(pod.uid) + len(pod.netIOStats.name) + len(pod.diskIOStats.name)
+			}
+		}
+	}
+	return size
+}
+
+// memoryBytes estimates bytes of extended stats
+func (s *extendedStats) memoryBytes() int {
+	size := int(unsafe.Sizeof(*s))
+	for resource := range s.allocatable {
+		size += len(resource) + 8 + mapEntryOverhead
+	}
+	for resource := range s.node {
+		size += len(resource) + 8 + mapEntryOverhead
+	}
+	for pod, usages := range s.pods {
+		size += len(pod) + mapEntryOverhead
+		for resource := range usages {
+			size += len(resource) + 8 + mapEntryOverhead
+		}
+	}
+	return size
+}
+
+// updateStatsMemory sets the stats memory metric, statsLock must be held
+func (c *conditionManager) updateStatsMemory() {
+	statsMemoryBytes.Set(float64(c.nodeStats.memoryBytes() + c.extended.memoryBytes()))
+}
+
+// keptPods returns the pod keys of a sample kept within max, the first ones
+// by key so that the same pods are kept across samples for rates. Returns nil
+// if all of them are kept, or max is zero.
+func keptPods(source string, keys []string, max int) map[string]bool {
+	if max <= 0 || len(keys) <= max {
+		droppedPods.Set(0, source)
+		return nil
+	}
+	sort.Strings(keys)
+	log.Warnf("%d pods of %s stats are beyond max pod stats %d, they are dropped",
+		len(keys)-max, source, max)
+	droppedPods.Set(float64(len(keys)-max), source)
+	kept := make(map[string]bool, max)
+	for _, key := range keys[:max] {
+		kept[key] = true
+	}
+	return kept
+}
@@ -0,0 +1,185 @@
+package condition
+
+import (
+	"strings"
+	"time"
+
+	"eviction-agent/pkg/log"
+)
+
+// scoreFactor returns the weight of score of pod keyed by namespace.name, 1
+// if it's not weighted. statsLock and policyLock must be held.
+type scoreFactor func(c *conditionManager, keyName string) float64
+
+// scoreFactors weight scores of candidates besides usage and priority, a new
+// factor is added here
+var scoreFactors = []scoreFactor{
+	func(c *conditionManager, keyName string) float64 {
+		return c.namespaceWeight(keyName[:strings.Index(keyName, ".")])
+	},
+	(*conditionManager).progressWeight,
+	(*conditionManager).ageWeight,
+	(*conditionManager).restartsWeight,
+	(*conditionManager).costWeight,
+}
+
+// podCostsPeriod is the min interval of syncing costs of pods, which gets
+// workloads of pods without cost
+const podCostsPeriod = time.Minute
+
+// scoreWeight returns the product of all score factors of pod. statsLock and
+// policyLock must be held.
+func (c *conditionManager) scoreWeight(keyName string) float64 {
+	weight := 1.0
+	for _, factor := range scoreFactors {
+		weight *= factor(c, keyName)
+	}
+	return weight
+}
+
+// restartSample is the restarts of containers of a pod since a sync
+type restartSample struct {
+	time     time.Time
+	restarts int
+}
+
+// podLifecycle is the start time of a pod and its restarts in window
+type podLifecycle struct {
+	startTime time.Time
+	samples   []restartSample // oldest first when restarts changed, the first one is at or before window
+}
+
+// syncPodLifecycles gets start times and restarts of pods if age or restarts
+// are scoring factors, restarts are sampled at each sync when they change to
+// count the ones in window. The last ones are kept if it fails.
+func (c *conditionManager) syncPodLifecycles() {
+	c.policyLock.RLock()
+	scoring := c.scoring
+	c.policyLock.RUnlock()
+	if scoring.Age == nil && scoring.Restarts == nil {
+		return
+	}
+	pods, err := c.client.GetPodLifecycles()
+	if err != nil {
+		log.Errorf("sync lifecycles of pods error: %v", err)
+		return
+	}
+	now := c.clock.Now()
+	c.statsLock.Lock()
+	defer c.statsLock.Unlock()
+	lifecycles := make(map[string]*podLifecycle, len(pods))
+	for pod, lifecycle := range pods {
+		keyName := strings.Replace(pod, "/", ".", 1)
+		sample := restartSample{time: now, restarts: lifecycle.Restarts}
+		last, ok := c.podLifecycles[keyName]
+		if !ok || !last.startTime.Equal(lifecycle.StartTime) {
+			lifecycles[keyName] = &podLifecycle{startTime: lifecycle.StartTime, samples: []restartSample{sample}}
+			continue
+		}
+		samples := last.samples
+		if samples[len(samples)-1].restarts != sample.restarts {
+			samples = append(samples, sample)
+		}
+		if scoring.Restarts != nil {
+			window := now.Add(-time.Duration(scoring.Restarts.Window))
+			// keep the last sample at or before window
+			i := 0
+			for i+1 < len(samples) && !samples[i+1].time.After(window) {
+				i++
+			}
+			samples = samples[i:]
+		} else {
+			samples = samples[len(samples)-1:]
+		}
+		last.samples = samples
+		lifecycles[keyName] = last
+	}
+	c.podLifecycles = lifecycles
+}
+
+// ageWeight prefers young pods whose caches are cold with a positive weight,
+// or old pods with a negative one. statsLock and policyLock must be held.
+func (c *conditionManager) ageWeight(keyName string) float64 {
+	factor := c.scoring.Age
+	lifecycle, ok := c.podLifecycles[keyName]
+	if factor == nil || !ok {
+		return 1
+	}
+	age := c.clock.Since(lifecycle.startTime)
+	if age < 0 {
+		age = 0
+	}
+	halfLife := time.Duration(factor.HalfLife)
+	return 1 + factor.Weight*float64(halfLife)/float64(age+halfLife)
+}
+
+// restartsWeight prefers pods restarting in window, e.g. crash-looping, with a
+// positive weight, or avoids them with a negative one. statsLock and
+// policyLock must be held.
+func (c *conditionManager) restartsWeight(keyName string) float64 {
+	factor := c.scoring.Restarts
+	restarts, ok := c.recentRestarts(keyName)
+	if factor == nil || !ok || restarts == 0 {
+		return 1
+	}
+	return 1 + factor.Weight*float64(restarts)/float64(restarts+factor.Half)
+}
+
+// recentRestarts returns restarts of containers of pod in window, all its
+// restarts if it's started in window. statsLock and policyLock must be held.
+func (c *conditionManager) recentRestarts(keyName string) (int, bool) {
+	lifecycle, ok := c.podLifecycles[keyName]
+	if !ok || c.scoring.Restarts == nil {
+		return 0, false
+	}
+	last := lifecycle.samples[len(lifecycle.samples)-1]
+	if c.clock.Since(lifecycle.startTime) <= time.Duration(c.scoring.Restarts.Window) {
+		return last.restarts, true
+	}
+	restarts := last.restarts - lifecycle.samples[0].restarts
+	if restarts < 0 {
+		restarts = 0
+	}
+	return restarts, true
+}
+
+// syncPodCosts gets costs of pods at most once per podCostsPeriod if cost is
+// a scoring factor. The last ones are kept if it fails.
+func (c *conditionManager) syncPodCosts() {
+	c.policyLock.RLock()
+	factor := c.scoring.Cost
+	c.policyLock.RUnlock()
+	if factor == nil {
+		return
+	}
+	now := c.clock.Now()
+	if now.Sub(c.podCostsSyncTime) < podCostsPeriod && factor.CostKey() == c.podCostsKey {
+		return
+	}
+	pods, err := c.client.GetPodCosts(factor.CostKey())
+	if err != nil {
+		log.Errorf("sync costs of pods error: %v", err)
+		return
+	}
+	c.podCostsSyncTime = now
+	c.podCostsKey = factor.CostKey()
+	podCosts := make(map[string]float64, len(pods))
+	for pod, cost := range pods {
+		podCosts[strings.Replace(pod, "/", ".", 1)] = cost
+	}
+	c.statsLock.Lock()
+	c.podCosts = podCosts
+	c.statsLock.Unlock()
+}
+
+// costWeight prefers pods cheaper to restart with a positive weight, among
+// comparable offenders the cheaper one is evicted first. statsLock and
+// policyLock must be held.
+func (c *conditionManager) costWeight(keyName string) float64 {
+	factor := c.scoring.Cost
+	cost, ok := c.podCosts[keyName]
+	if factor == nil || !ok {
+		return 1
+	}
+	return 1 + factor.Weight*factor.Reference/(cost+factor.Reference)
+}
@@ -0,0 +1,128 @@
+package condition
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/metrics"
+)
+
+var (
+	selfUsageRatio = metrics.NewGaugeVec("eviction_agent_self_usage_ratio",
+		"Ratio of usage to limits of eviction agent pod.", "resource")
+	selfThrottleFactor = metrics.NewGaugeVec("eviction_agent_self_throttle_factor",
+		"Factor of stats sampling period while eviction agent is near its own limits.")
+)
+
+// maxSelfThrottle is the max factor of sampling period while throttled
+const maxSelfThrottle = 4
+
+// selfLimits are limits of agent pod, zero if the resource is unlimited
+type selfLimits struct {
+	cpu    float64 // cores
+	memory float64 // bytes
+}
+
+// loadSelfLimits gets limits of agent pod, a resource is unlimited if any
+// container has no limit of it
+func (c *conditionManager) loadSelfLimits() {
+	if c.selfLimitRatio <= 0 || c.selfPod.Name == "" {
+		return
+	}
+	containers, err := c.client.GetPodResources(&c.selfPod)
+	if err != nil {
+		log.Warnf("get limits of agent pod error, self limiting is disabled: %v", err)
+		return
+	}
+	cpuLimited, memoryLimited := len(containers) != 0, len(containers) != 0
+	var limits selfLimits
+	for _, container := range containers {
+		limits.cpu += container.CPULimit
+		limits.memory += container.MemoryLimit
+		cpuLimited = cpuLimited && container.CPULimit > 0
+		memoryLimited = memoryLimited && container.MemoryLimit > 0
+	}
+	if !cpuLimited {
+		limits.cpu = 0
+	}
+	if !memoryLimited {
+		limits.memory = 0
+	}
+	c.selfLimits = limits
+	log.Infof("Get limits of agent pod %s/%s, cpu: %v, memory: %v",
+		c.selfPod.Namespace, c.selfPod.Name, limits.cpu, limits.memory)
+}
+
+// throttleSelf adjusts sampling by usage of agent pod in stats. The sampling
+// period is doubled up to maxSelfThrottle times while usage is over
+// selfLimitRatio of limits, and halved back once it's below 3/4 of that.
+func (c *conditionManager) throttleSelf(stats *nodeStatsType) {
+	if c.selfLimits.cpu == 0 && c.selfLimits.memory == 0 {
+		return
+	}
+	pod, ok := stats.podStats[c.selfPod.Namespace+"."+c.selfPod.Name]
+	if !ok {
+		return
+	}
+	var ratio float64
+	if c.selfLimits.cpu > 0 {
+		r := pod.cpuUsage / c.selfLimits.cpu
+		selfUsageRatio.Set(r, "cpu")
+		ratio = math.Max(ratio, r)
+	}
+	if c.selfLimits.memory > 0 {
+		r := float64(pod.memoryUsage) / c.selfLimits.memory
+		selfUsageRatio.Set(r, "memory")
+		ratio = math.Max(ratio, r)
+	}
+
+	throttle := atomic.LoadInt32(&c.selfThrottle)
+	switch {
+	case ratio > c.selfLimitRatio && throttle < maxSelfThrottle:
+		throttle *= 2
+		log.Warnf("agent uses %.0f%% of its limits, sample stats every %v", ratio*100,
+			c.syncPeriod*time.Duration(throttle))
+	case ratio < c.selfLimitRatio*3/4 && throttle > 1:
+		throttle /= 2
+		log.Infof("agent uses %.0f%% of its limits, sample stats every %v", ratio*100,
+			c.syncPeriod*time.Duration(throttle))
+	default:
+		return
+	}
+	atomic.StoreInt32(&c.selfThrottle, throttle)
+	selfThrottleFactor.Set(float64(throttle))
+}
+
+// samplingPeriod returns the period of stats sync, longer while agent is throttled
+func (c *conditionManager) samplingPeriod() time.Duration {
+	return c.syncPeriod * time.Duration(atomic.LoadInt32(&c.selfThrottle))
+}
+
+// statsWindow returns the number of stats kept, the fewest needed for rates
+// while agent is throttled
+func (c *conditionManager) statsWindow() int {
+	if atomic.LoadInt32(&c.selfThrottle) > 1 {
+		return minStatsBufferLen
+	}
+	return statsBufferLen
+}
+
+// collectConcurrency returns the max stats sources collected at once, one
+// while agent is throttled
+func (c *conditionManager) collectConcurrency() int {
+	if atomic.LoadInt32(&c.selfThrottle) > 1 {
+		return 1
+	}
+	return c.statsConcurrency
+}
+
+// podWorkers returns the max pods whose stats are collected at once, one
+// while agent is throttled
+func (c *conditionManager) podWorkers() int {
+	if atomic.LoadInt32(&c.selfThrottle) > 1 {
+		return 1
+	}
+	return c.podStatsWorkers
+}
@@ -0,0 +1,85 @@
+package condition
+
+import (
+	"eviction-agent/pkg/types"
+)
+
+// severityEvictTypes are the evict types of resources graded by severity
+var severityEvictTypes = []string{
+	types.CPUBusy,
+	types.MemBusy,
+	types.DiskIO,
+	types.NetworkRxBusy,
+	types.NetworkTxBusy,
+	types.WritebackBusy,
+	types.EphemeralPortsBusy,
+}
+
+// severities grades evict types of resources and bands measured past their
+// thresholds, critical from criticalExcess of threshold past it. Returns nil
+// if conditions are not graded.
+func (c *conditionManager) severities(nodeCondition *NodeCondition) map[string]types.Severity {
+	criticalExcess := c.severity.CriticalExcess
+	if criticalExcess <= 0 {
+		return nil
+	}
+	evictTypes := append([]string{}, severityEvictTypes...)
+	for _, band := range nodeCondition.Bands {
+		evictTypes = append(evictTypes, BandEvictType(band.EvictType, band.Band))
+	}
+	severities := make(map[string]types.Severity)
+	for _, evictType := range evictTypes {
+		value, threshold, ok := nodeCondition.Measured(evictType)
+		if !ok || value <= threshold {
+			continue
+		}
+		severities[evictType] = types.SeverityWarning
+		if value-threshold >= criticalExcess*threshold {
+			severities[evictType] = types.SeverityCritical
+		}
+	}
+	return severities
+}
+
+// Measured returns the measured value of evictType and its threshold, or of
+// the band of it, false if evictType is not measured against a threshold
+func (nc *NodeCondition) Measured(evictType string) (float64, float64, bool) {
+	if bandEvictType, band, ok := ParseBandEvictType(evictType); ok {
+		for _, b := range nc.Bands {
+			if b.Band == band && b.EvictType == bandEvictType {
+				return b.Usage, b.Limit, true
+			}
+		}
+		return 0, 0, false
+	}
+	threshold, ok := nc.Thresholds[evictType]
+	if !ok {
+		return 0, 0, false
+	}
+	var value float64
+	switch evictType {
+	case types.CPUBusy:
+		value = nc.CPUUsage
+	case types.MemBusy:
+		value = float64(nc.MemoryUsage)
+	case types.DiskIO:
+		value = nc.DiskIOPS
+	case types.NetworkRxBusy:
+		value = nc.NetworkRxBps
+	case types.NetworkTxBusy:
+		value = nc.NetworkTxBps
+	case types.WritebackBusy:
+		if nc.Writeback == nil {
+			return 0, 0, false
+		}
+		value = float64(nc.Writeback.DirtyBytes + nc.Writeback.WritebackBytes)
+	case types.EphemeralPortsBusy:
+		if nc.EphemeralPorts == nil {
+			return 0, 0, false
+		}
+		value = float64(nc.EphemeralPorts.Used)
+	default:
+		return 0, 0, false
+	}
+	return value, threshold, true
+}
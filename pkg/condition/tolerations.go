@@ -0,0 +1,104 @@
+package condition
+
+import (
+	"strings"
+	"time"
+
+	"eviction-agent/cmd/options"
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/types"
+)
+
+// pinnedPodsPeriod is the min interval of syncing pinned pods, which lists
+// all nodes of cluster
+const pinnedPodsPeriod = time.Minute
+
+// resourceTaintKeys are the taints of resources pods may tolerate, extended
+// resources are added by their taints
+var resourceTaintKeys = []string{
+	types.CPUBusy,
+	types.MemBusy,
+	types.DiskIO,
+	types.NetworkIO,
+	types.WritebackBusy,
+	types.EphemeralPortsBusy,
+}
+
+// syncReturningPods gets pods tolerating taints of resources or required to
+// run on node, they are scheduled back once evicted. The last ones are kept
+// if it fails.
+func (c *conditionManager) syncReturningPods() {
+	if !c.tolerationAware {
+		return
+	}
+	taintKeys := append([]string{}, resourceTaintKeys...)
+	for taintKey := range c.extendedTaints {
+		taintKeys = append(taintKeys, taintKey)
+	}
+	pods, err := c.client.GetToleratedTaints(taintKeys)
+	if err != nil {
+		log.Errorf("sync pods tolerating taints error: %v", err)
+		return
+	}
+	returningPods := make(map[string]map[string]bool, len(pods))
+	for pod, tolerated := range pods {
+		keys := make(map[string]bool, len(tolerated))
+		for _, taintKey := range tolerated {
+			keys[taintKey] = true
+		}
+		returningPods[strings.Replace(pod, "/", ".", 1)] = keys
+	}
+	c.statsLock.Lock()
+	c.returningPods = returningPods
+	c.statsLock.Unlock()
+}
+
+// returns returns true if pod is scheduled back on node tainted for evictType,
+// or it can't be scheduled on other nodes. Rx and tx share the taint of
+// network. statsLock must be held.
+func (c *conditionManager) returns(evictType, keyName string) bool {
+	if _, ok := c.pinnedPods[keyName]; ok {
+		return true
+	}
+	taintKey := evictType
+	if evictType == types.NetworkRxBusy || evictType == types.NetworkTxBusy {
+		taintKey = types.NetworkIO
+	}
+	return c.returningPods[keyName][taintKey]
+}
+
+// syncPinnedPods gets pods which can't be scheduled on other nodes at most
+// once per pinnedPodsPeriod, nodes are listed for them. The last ones are
+// kept if it fails.
+func (c *conditionManager) syncPinnedPods() {
+	if c.pinnedPodsAction == "" {
+		return
+	}
+	now := c.clock.Now()
+	if now.Sub(c.pinnedSyncTime) < pinnedPodsPeriod {
+		return
+	}
+	pods, err := c.client.GetPinnedPods()
+	if err != nil {
+		log.Errorf("sync pods pinned to node error: %v", err)
+		return
+	}
+	c.pinnedSyncTime = now
+	pinnedPods := make(map[string]string, len(pods))
+	for pod, reason := range pods {
+		pinnedPods[strings.Replace(pod, "/", ".", 1)] = reason
+	}
+	c.statsLock.Lock()
+	c.pinnedPods = pinnedPods
+	c.statsLock.Unlock()
+}
+
+// pinnedExcluded returns why pod is excluded if it can't be scheduled on
+// other nodes and pinned pods are excluded. statsLock must be held.
+func (c *conditionManager) pinnedExcluded(keyName string) (string, bool) {
+	if c.pinnedPodsAction != options.PinnedPodsExclude {
+		return "", false
+	}
+	reason, ok := c.pinnedPods[keyName]
+	return reason, ok
+}
@@ -0,0 +1,279 @@
+package condition
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	cadvisorapiv1 "github.com/google/cadvisor/info/v1"
+)
+
+const (
+	// sysClassBlock has block devices and their stacking by slaves and holders
+	sysClassBlock = "/sys/class/block"
+	// procMountinfo is the mount table of host, the agent runs in host pid namespace
+	procMountinfo = "/proc/1/mountinfo"
+)
+
+// diskTopology is the physical devices of the checked disk and the devices
+// stacked on them, e.g. device-mapper of LVM and md of RAID. Pods doing io
+// on a logical volume load the physical devices under it, so io of stacked
+// devices is attributed to physical devices.
+type diskTopology struct {
+	// name is the device of diskDevName, e.g. dm-0 of /dev/mapper/vg-lv
+	name string
+	// physical are major:minor of the physical devices of the disk
+	physical map[string]bool
+	// stacked are shares of io of the top stacked devices on the physical
+	// devices by major:minor, io of a stacked device is split evenly among
+	// the physical devices under it
+	stacked map[string]float64
+	// devices are names of the physical devices
+	devices []string
+	// mounts are the mountpoints of devices on the physical devices
+	mounts []string
+}
+
+// blockDevice is a block device of sysfs
+type blockDevice struct {
+	name string
+	// dev is major:minor
+	dev string
+	// disk is the whole device of a partition, or the name of the device itself
+	disk string
+	// dmName is the name of a device-mapper device, e.g. vg-lv
+	dmName  string
+	slaves  []string
+	holders []string
+}
+
+type mountPoint struct {
+	dev   string
+	point string
+}
+
+// resolveDiskTopology resolves device, a device name like sda or
+// /dev/mapper/vg-lv, or a mountpoint like /var/lib/kubelet, to its physical
+// devices and devices stacked on them
+func resolveDiskTopology(device string) (*diskTopology, error) {
+	devices, err := readBlockDevices(sysClassBlock)
+	if err != nil {
+		return nil, err
+	}
+	mounts, err := readMountinfo(procMountinfo)
+	if err != nil {
+		return nil, err
+	}
+	name, err := blockDeviceName(device, devices, mounts)
+	if err != nil {
+		return nil, err
+	}
+	topology := &diskTopology{
+		name:     name,
+		physical: make(map[string]bool),
+		stacked:  make(map[string]float64),
+	}
+	physical := leafDevices(devices[name].disk, devices, map[string]bool{})
+	for disk := range physical {
+		topology.physical[devices[disk].dev] = true
+		topology.devices = append(topology.devices, disk)
+	}
+	sort.Strings(topology.devices)
+	for _, d := range devices {
+		if d.disk != d.name || physical[d.name] || len(d.slaves) == 0 || len(wholeHolders(d.name, devices)) != 0 {
+			continue
+		}
+		leaves := leafDevices(d.name, devices, map[string]bool{})
+		shared := 0
+		for leaf := range leaves {
+			if physical[leaf] {
+				shared++
+			}
+		}
+		if shared != 0 {
+			topology.stacked[d.dev] = float64(shared) / float64(len(leaves))
+		}
+	}
+	byDev := make(map[string]*blockDevice, len(devices))
+	for _, d := range devices {
+		byDev[d.dev] = d
+	}
+	for _, m := range mounts {
+		d, ok := byDev[m.dev]
+		if !ok {
+			continue
+		}
+		for leaf := range leafDevices(d.disk, devices, map[string]bool{}) {
+			if physical[leaf] {
+				topology.mounts = append(topology.mounts, m.point)
+				break
+			}
+		}
+	}
+	return topology, nil
+}
+
+// stat returns reads and writes of the physical devices of the disk. Kernels
+// accounting io of cgroups to each layer of devices report io of physical
+// devices, which is taken as it is. Or else io of stacked devices is taken by
+// their shares.
+func (t *diskTopology) stat(ioServiced []cadvisorapiv1.PerDiskStats) statType {
+	disk := statType{name: t.name}
+	var stacked statType
+	physical := false
+	for _, io := range ioServiced {
+		dev := fmt.Sprintf("%d:%d", io.Major, io.Minor)
+		if t.physical[dev] {
+			physical = true
+			disk.rx += io.Stats["Read"]
+			disk.tx += io.Stats["Write"]
+		} else if share, ok := t.stacked[dev]; ok {
+			stacked.rx += uint64(float64(io.Stats["Read"]) * share)
+			stacked.tx += uint64(float64(io.Stats["Write"]) * share)
+		}
+	}
+	if !physical {
+		disk.rx, disk.tx = stacked.rx, stacked.tx
+	}
+	return disk
+}
+
+// blockDeviceName returns the block device of device name or mountpoint
+func blockDeviceName(device string, devices map[string]*blockDevice, mounts []mountPoint) (string, error) {
+	name := device
+	switch {
+	case strings.HasPrefix(device, "/dev/mapper/"):
+		dmName := strings.TrimPrefix(device, "/dev/mapper/")
+		name = ""
+		for _, d := range devices {
+			if d.dmName == dmName {
+				name = d.name
+			}
+		}
+	case strings.HasPrefix(device, "/dev/"):
+		name = strings.TrimPrefix(device, "/dev/")
+	case strings.HasPrefix(device, "/"):
+		// the mountpoint containing the path, the last one of the same point
+		// is over the others
+		var dev, point string
+		for _, m := range mounts {
+			contains := m.point == "/" || device == m.point || strings.HasPrefix(device, m.point+"/")
+			if contains && len(m.point) >= len(point) {
+				dev, point = m.dev, m.point
+			}
+		}
+		name = ""
+		for _, d := range devices {
+			if dev != "" && d.dev == dev {
+				name = d.name
+			}
+		}
+	}
+	if _, ok := devices[name]; !ok {
+		return "", fmt.Errorf("no block device of %s", device)
+	}
+	return name, nil
+}
+
+// leafDevices returns the whole physical devices under device, device itself
+// if nothing is under it
+func leafDevices(name string, devices map[string]*blockDevice, visited map[string]bool) map[string]bool {
+	leaves := make(map[string]bool)
+	d, ok := devices[name]
+	if !ok || visited[name] {
+		return leaves
+	}
+	visited[name] = true
+	if len(d.slaves) == 0 {
+		leaves[d.disk] = true
+		return leaves
+	}
+	for _, slave := range d.slaves {
+		s, ok := devices[slave]
+		if !ok {
+			continue
+		}
+		for leaf := range leafDevices(s.disk, devices, visited) {
+			leaves[leaf] = true
+		}
+	}
+	return leaves
+}
+
+// wholeHolders returns the holders of a whole device and its partitions
+func wholeHolders(disk string, devices map[string]*blockDevice) []string {
+	var holders []string
+	for _, d := range devices {
+		if d.disk == disk {
+			holders = append(holders, d.holders...)
+		}
+	}
+	return holders
+}
+
+// readBlockDevices reads block devices of sysfs keyed by name
+func readBlockDevices(root string) (map[string]*blockDevice, error) {
+	entries, err := ioutil.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+	devices := make(map[string]*blockDevice, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		dir := filepath.Join(root, name)
+		data, err := ioutil.ReadFile(filepath.Join(dir, "dev"))
+		if err != nil {
+			return nil, err
+		}
+		d := &blockDevice{name: name, dev: strings.TrimSpace(string(data)), disk: name}
+		if _, err := os.Stat(filepath.Join(dir, "partition")); err == nil {
+			// a partition is under its whole device in sysfs
+			if path, err := filepath.EvalSymlinks(dir); err == nil {
+				d.disk = filepath.Base(filepath.Dir(path))
+			}
+		}
+		if data, err := ioutil.ReadFile(filepath.Join(dir, "dm", "name")); err == nil {
+			d.dmName = strings.TrimSpace(string(data))
+		}
+		d.slaves = dirNames(filepath.Join(dir, "slaves"))
+		d.holders = dirNames(filepath.Join(dir, "holders"))
+		devices[name] = d
+	}
+	return devices, nil
+}
+
+func dirNames(dir string) []string {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+// readMountinfo reads major:minor and mountpoints of the mount table
+func readMountinfo(path string) ([]mountPoint, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var mounts []mountPoint
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// 36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 {
+			continue
+		}
+		mounts = append(mounts, mountPoint{dev: fields[2], point: fields[4]})
+	}
+	return mounts, scanner.Err()
+}
@@ -0,0 +1,62 @@
+package condition
+
+import (
+	"fmt"
+	"sort"
+
+	"eviction-agent/pkg/types"
+)
+
+// PodUsage is the resource usage of a pod
+type PodUsage struct {
+	// Pod is namespace/name of the pod
+	Pod   string  `json:"pod"`
+	Usage float64 `json:"usage"`
+}
+
+// TopPods are the pods consuming most of each resource, ordered by usage
+type TopPods struct {
+	CPU     []PodUsage `json:"cpu"`     // cores
+	Memory  []PodUsage `json:"memory"`  // bytes
+	DiskIO  []PodUsage `json:"diskIO"`  // iops
+	Network []PodUsage `json:"network"` // Bytes/s, rx and tx
+}
+
+// GetTopPods returns at most k pods consuming most of each resource in the
+// latest stats, pods in protected namespaces are included
+func (c *conditionManager) GetTopPods(k int) (*TopPods, error) {
+	if !c.HasSynced() {
+		return nil, fmt.Errorf("there are no enough stats")
+	}
+	c.statsLock.RLock()
+	defer c.statsLock.RUnlock()
+	return &TopPods{
+		CPU:     c.topPods(types.CPUBusy, k),
+		Memory:  c.topPods(types.MemBusy, k),
+		DiskIO:  c.topPods(types.DiskIO, k),
+		Network: c.topPods(types.NetworkRxBusy, k),
+	}, nil
+}
+
+// topPods returns at most k pods with the highest usage of evictType,
+// pods consuming nothing are ignored. statsLock must be held.
+func (c *conditionManager) topPods(evictType string, k int) []PodUsage {
+	usages := []PodUsage{}
+	for keyName, pod := range c.nodeStats.last().podStats {
+		usage, ok := c.podUsage(evictType, keyName, true)
+		if !ok || usage <= 0 {
+			continue
+		}
+		usages = append(usages, PodUsage{Pod: pod.namespace + "/" + pod.name, Usage: usage})
+	}
+	sort.Slice(usages, func(i, j int) bool {
+		if usages[i].Usage != usages[j].Usage {
+			return usages[i].Usage > usages[j].Usage
+		}
+		return usages[i].Pod < usages[j].Pod
+	})
+	if len(usages) > k {
+		usages = usages[:k]
+	}
+	return usages
+}
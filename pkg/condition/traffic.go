@@ -0,0 +1,141 @@
+package condition
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"eviction-agent/pkg/config"
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/metrics"
+	"eviction-agent/pkg/types"
+)
+
+var (
+	trafficBps = metrics.NewGaugeVec("eviction_agent_network_traffic_bps",
+		"Network traffic in Bytes/s of the last stats by traffic class and condition.", "class", "condition")
+)
+
+// TrafficClassCondition is the condition of a network traffic class by direction
+type TrafficClassCondition struct {
+	Class     string  // pod, host or overlay
+	EvictType string  // NetworkRxBusy or NetworkTxBusy
+	Bps       float64 // Bytes/s
+	Threshold float64
+	Available bool
+}
+
+// syncHostNetworkPods gets pods of host network for traffic classes, the
+// last ones are kept if it fails
+func (c *conditionManager) syncHostNetworkPods() {
+	c.policyLock.RLock()
+	enabled := len(c.trafficClasses) != 0
+	c.policyLock.RUnlock()
+	if !enabled {
+		return
+	}
+	pods, err := c.client.GetHostNetworkPods()
+	if err != nil {
+		log.Errorf("sync pods of host network error: %v", err)
+		return
+	}
+	hostNetworkPods := make(map[string]bool, len(pods))
+	for pod := range pods {
+		hostNetworkPods[strings.Replace(pod, "/", ".", 1)] = true
+	}
+	c.statsLock.Lock()
+	c.hostNetworkPods = hostNetworkPods
+	c.statsLock.Unlock()
+}
+
+// trafficClassConditions returns conditions of traffic classes ordered by
+// class and direction, rx and tx are of network interfaces. Pod traffic is the
+// sum of pods of pod network, the rest of interfaces is host traffic, since
+// traffic of pods to other nodes goes through interfaces once encapsulated.
+// statsLock and policyLock must be held.
+func (c *conditionManager) trafficClassConditions(newStats, lastStats *nodeStatsType, rx, tx float64) []TrafficClassCondition {
+	if len(c.trafficClasses) == 0 {
+		return nil
+	}
+	capacity := float64(len(c.networkInterfaces)) * float64(c.networkIoTotal)
+	var podRx, podTx float64
+	for keyName := range newStats.podStats {
+		if c.hostNetworkPods[keyName] {
+			continue
+		}
+		if usage, ok := c.podUsage(types.NetworkRxBusy, keyName, false); ok && usage > 0 {
+			podRx += usage
+		}
+		if usage, ok := c.podUsage(types.NetworkTxBusy, keyName, false); ok && usage > 0 {
+			podTx += usage
+		}
+	}
+	var overlayRx, overlayTx float64
+	if seconds := newStats.overlayIOStats.time.Sub(lastStats.overlayIOStats.time).Seconds(); seconds > 0 {
+		overlay, last := newStats.overlayIOStats, lastStats.overlayIOStats
+		if overlay.rx >= last.rx && overlay.tx >= last.tx {
+			overlayRx = float64(overlay.rx-last.rx) / seconds
+			overlayTx = float64(overlay.tx-last.tx) / seconds
+		}
+	}
+	bps := map[string][2]float64{
+		config.PodTraffic:     {podRx, podTx},
+		config.HostTraffic:    {math.Max(rx-podRx, 0), math.Max(tx-podTx, 0)},
+		config.OverlayTraffic: {overlayRx, overlayTx},
+	}
+
+	var conditions []TrafficClassCondition
+	for class, threshold := range c.trafficClasses {
+		for i, evictType := range []string{types.NetworkRxBusy, types.NetworkTxBusy} {
+			trafficCondition := TrafficClassCondition{
+				Class:     class,
+				EvictType: evictType,
+				Bps:       bps[class][i],
+				Threshold: threshold.Value(capacity),
+				Available: true,
+			}
+			trafficBps.Set(trafficCondition.Bps, class, evictType)
+			if trafficCondition.Bps > trafficCondition.Threshold {
+				log.Infof("%s traffic out of limits of %s, bps: %v Bytes/s", class, evictType, int(trafficCondition.Bps))
+				trafficCondition.Available = false
+			}
+			conditions = append(conditions, trafficCondition)
+		}
+	}
+	sort.Slice(conditions, func(i, j int) bool {
+		if conditions[i].Class != conditions[j].Class {
+			return conditions[i].Class < conditions[j].Class
+		}
+		return conditions[i].EvictType < conditions[j].EvictType
+	})
+	return conditions
+}
+
+// trafficAvailable returns true if all traffic classes of evictType are available
+func trafficAvailable(conditions []TrafficClassCondition, evictType string) bool {
+	for _, condition := range conditions {
+		if condition.EvictType == evictType && !condition.Available {
+			return false
+		}
+	}
+	return true
+}
+
+// trafficExcluded returns true if pod is not chosen for evictType by traffic
+// classes. Only pods of pod network are chosen, and only if pod or overlay
+// traffic is busy, so that host traffic never evicts them. statsLock must be held.
+func (c *conditionManager) trafficExcluded(evictType, keyName string) bool {
+	if evictType != types.NetworkRxBusy && evictType != types.NetworkTxBusy {
+		return false
+	}
+	if len(c.nodeCondition.TrafficClasses) == 0 {
+		return false
+	}
+	podsBusy := false
+	for _, condition := range c.nodeCondition.TrafficClasses {
+		if condition.EvictType == evictType && !condition.Available && condition.Class != config.HostTraffic {
+			podsBusy = true
+		}
+	}
+	return !podsBusy || c.hostNetworkPods[keyName]
+}
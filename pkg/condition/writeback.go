@@ -0,0 +1,182 @@
+package condition
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/metrics"
+	"eviction-agent/pkg/summary"
+)
+
+// writebackSource is the stats source of dirty and writeback pages
+const writebackSource = "writeback"
+
+// podCgroupRoots are memory cgroups of pods by cgroup driver, of cgroup v1
+// and v2. Dirty pages of pods are read from memory.stat of their cgroups.
+var podCgroupRoots = []string{
+	"/sys/fs/cgroup/memory/kubepods",
+	"/sys/fs/cgroup/memory/kubepods.slice",
+	"/sys/fs/cgroup/kubepods",
+	"/sys/fs/cgroup/kubepods.slice",
+}
+
+var (
+	writebackBytes = metrics.NewGaugeVec("eviction_agent_writeback_bytes",
+		"Bytes of dirty and writeback pages of node of the last stats by state.", "state")
+)
+
+// writebackStats are bytes of dirty and writeback pages of node
+type writebackStats struct {
+	ok        bool // false if vmstat is not read
+	dirty     uint64
+	writeback uint64
+}
+
+// WritebackCondition is the backlog of dirty and writeback pages of node
+type WritebackCondition struct {
+	DirtyBytes     uint64
+	WritebackBytes uint64
+	Threshold      float64 // bytes
+	// Since is the time of the first stats over threshold, zero if it's not
+	Since time.Time
+	// Busy is true if the backlog is over threshold for sustainedFor of policy
+	Busy bool
+}
+
+// collectWriteback reads dirty and writeback pages of /proc/vmstat, and of
+// memory cgroups of pods, if writeback is checked
+func collectWriteback(stats *summary.ConditionStats, in collectInput, out *nodeStatsType) {
+	if !in.writeback {
+		return
+	}
+	data, err := ioutil.ReadFile(procVmstat)
+	if err != nil {
+		log.Errorf("read %s error: %v", procVmstat, err)
+		return
+	}
+	counters := parseCounters(data)
+	pageSize := uint64(os.Getpagesize())
+	out.writebackStats = writebackStats{
+		ok:        true,
+		dirty:     counters["nr_dirty"] * pageSize,
+		writeback: counters["nr_writeback"] * pageSize,
+	}
+
+	cgroups := podCgroups()
+	pods := cgroupPods(stats, cgroups)
+	out.podWriteback = make(map[string]uint64, len(pods))
+	attributePods(writebackSource, stats, pods, in, func(i int) (interface{}, error) {
+		data, err := ioutil.ReadFile(filepath.Join(cgroups[stats.PodStats[i].PodRef.UID], "memory.stat"))
+		if err != nil {
+			return nil, err
+		}
+		counters := parseCounters(data)
+		// hierarchical counters of cgroup v1, or counters of cgroup v2
+		backlog := counters["total_dirty"] + counters["total_writeback"]
+		if _, ok := counters["file_dirty"]; ok {
+			backlog = counters["file_dirty"] + counters["file_writeback"]
+		}
+		return backlog, nil
+	}, func(i int, value interface{}) {
+		pod := stats.PodStats[i].PodRef
+		out.podWriteback[pod.Namespace+"."+pod.Name] = value.(uint64)
+	})
+}
+
+// cgroupPods returns indexes of pods of stats which have cgroups
+func cgroupPods(stats *summary.ConditionStats, cgroups map[string]string) []int {
+	var pods []int
+	for i, pod := range stats.PodStats {
+		if _, ok := cgroups[pod.PodRef.UID]; ok {
+			pods = append(pods, i)
+		}
+	}
+	return pods
+}
+
+// parseCounters returns counters of lines of a name and a value, like those
+// of vmstat and memory.stat
+func parseCounters(data []byte) map[string]uint64 {
+	counters := make(map[string]uint64)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		if value, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+			counters[fields[0]] = value
+		}
+	}
+	return counters
+}
+
+// podCgroups returns memory cgroups of pods keyed by pod uid
+func podCgroups() map[string]string {
+	cgroups := make(map[string]string)
+	for _, root := range podCgroupRoots {
+		filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil || !info.IsDir() {
+				return nil
+			}
+			if uid, ok := podCgroupUID(info.Name()); ok {
+				cgroups[uid] = path
+				// cgroups of containers are not needed
+				return filepath.SkipDir
+			}
+			return nil
+		})
+	}
+	return cgroups
+}
+
+// podCgroupUID returns the pod uid of a cgroup directory, e.g. pod<uid> of
+// cgroupfs driver or kubepods-burstable-pod<uid>.slice of systemd driver
+// whose uid has underscores instead of dashes
+func podCgroupUID(name string) (string, bool) {
+	name = strings.TrimSuffix(name, ".slice")
+	i := strings.LastIndex(name, "pod")
+	if i < 0 || (i > 0 && name[i-1] != '-') || i+len("pod") == len(name) {
+		return "", false
+	}
+	return strings.Replace(name[i+len("pod"):], "_", "-", -1), true
+}
+
+// writebackCondition returns the writeback backlog of the last stats, and
+// keeps the time it has been over threshold since. Returns nil if writeback
+// is not checked. statsLock and policyLock must be held.
+func (c *conditionManager) writebackCondition(newStats *nodeStatsType) *WritebackCondition {
+	if c.writeback.Threshold == nil || !newStats.writebackStats.ok {
+		c.writebackSince = time.Time{}
+		return nil
+	}
+	stats := newStats.writebackStats
+	writeback := &WritebackCondition{
+		DirtyBytes:     stats.dirty,
+		WritebackBytes: stats.writeback,
+		Threshold:      c.writeback.Threshold.Value(float64(c.memTotal)),
+	}
+	writebackBytes.Set(float64(stats.dirty), "dirty")
+	writebackBytes.Set(float64(stats.writeback), "writeback")
+	if float64(stats.dirty+stats.writeback) <= writeback.Threshold {
+		c.writebackSince = time.Time{}
+		return writeback
+	}
+	if c.writebackSince.IsZero() || newStats.time.Before(c.writebackSince) {
+		c.writebackSince = newStats.time
+	}
+	writeback.Since = c.writebackSince
+	writeback.Busy = newStats.time.Sub(c.writebackSince) >= time.Duration(c.writeback.SustainedFor)
+	if writeback.Busy {
+		log.Infof("writeback backlog out of limits since %v, dirty: %v Bytes, writeback: %v Bytes",
+			writeback.Since, stats.dirty, stats.writeback)
+	}
+	return writeback
+}
@@ -0,0 +1,641 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/ghodss/yaml"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"eviction-agent/pkg/log"
+)
+
+// Conditions checked by eviction agent, they are the keys of taintThreshold
+const (
+	CPUCondition       = "CPU"
+	MemoryCondition    = "Memory"
+	DiskIOCondition    = "DiskIo"
+	NetworkIOCondition = "NetworkIo"
+)
+
+// Conditions checked by their own fields of policy instead of taintThreshold
+const (
+	// WritebackCondition is the backlog of dirty and writeback pages, of writeback
+	WritebackCondition = "Writeback"
+	// EphemeralPortsCondition is used ephemeral ports of node, of ephemeralPorts
+	EphemeralPortsCondition = "EphemeralPorts"
+)
+
+// Traffic classes of network, they are the keys of trafficClasses
+const (
+	// PodTraffic is traffic of pods of pod network on their veth
+	PodTraffic = "pod"
+	// HostTraffic is traffic of node interfaces not by pods of pod network,
+	// e.g. of image pulls, backups and pods of host network
+	HostTraffic = "host"
+	// OverlayTraffic is traffic of overlay interfaces, e.g. VXLAN encapsulation
+	OverlayTraffic = "overlay"
+)
+
+// Actions taken on busy conditions, they are the values of actions
+const (
+	// TaintAction taints node by the condition
+	TaintAction = "Taint"
+	// EvictAction evicts the chosen pod, if autoEvictFlag is set
+	EvictAction = "Evict"
+	// LabelAction labels the chosen pod which is not evicted
+	LabelAction = "Label"
+)
+
+var policyActions = map[string]bool{
+	TaintAction: true,
+	EvictAction: true,
+	LabelAction: true,
+}
+
+var trafficClasses = map[string]bool{
+	PodTraffic:     true,
+	HostTraffic:    true,
+	OverlayTraffic: true,
+}
+
+// thresholdKeys are the valid keys of taintThreshold and disabledConditions
+var thresholdKeys = map[string]bool{
+	CPUCondition:       true,
+	MemoryCondition:    true,
+	DiskIOCondition:    true,
+	NetworkIOCondition: true,
+}
+
+// ValidateConditions checks all conditions are known
+func ValidateConditions(conditions []string) error {
+	var errs []error
+	for _, condition := range conditions {
+		if !thresholdKeys[condition] {
+			errs = append(errs, fmt.Errorf("unknown condition %q, should be one of CPU, Memory, DiskIo, NetworkIo", condition))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// PolicyConfig is the eviction policy, which is loaded from the policy
+// configuration file or the spec of EvictionPolicy custom resource.
+type PolicyConfig struct {
+	// Profile is the built-in preset which fields of policy override
+	Profile            string               `json:"profile,omitempty"`
+	UntaintGracePeriod GracePeriod          `json:"untaintGracePeriod"`
+	TaintThreshold     map[string]Threshold `json:"taintThreshold"`
+	AutoEvictFlag      bool                 `json:"autoEvictFlag"`
+	// Actions are actions taken on busy conditions, some of Taint, Evict and
+	// Label, all of them if not set
+	Actions []string `json:"actions,omitempty"`
+	//Resource total
+	NetworkInterfaces    []string `json:"networkInterfaces"`
+	NetworkBPSTotal      ByteRate `json:"networkBPSTotal"`
+	DiskDevName          string   `json:"diskDevName"`
+	DiskIOPSTotal        int64    `json:"diskIOPSTotal"`
+	LowPriorityThreshold int      `json:"lowPriorityThreshold"`
+	// ProtectedNamespaces are namespaces whose pods are never evicted or labeled
+	ProtectedNamespaces []string `json:"protectedNamespaces"`
+	// DisabledConditions are neither monitored nor tainted, e.g. Memory if kubelet eviction owns it
+	DisabledConditions []string `json:"disabledConditions"`
+	// SystemReserved is the headroom of CPU and Memory kept for system daemons
+	// like --system-reserved of kubelet, usage of pods instead of node is
+	// checked against the threshold of capacity minus the headroom
+	SystemReserved map[string]Threshold `json:"systemReserved,omitempty"`
+	// LabelPolicy chooses the priority of pods labeled instead of evicted
+	LabelPolicy LabelPolicy `json:"labelPolicy"`
+	// PriorityBands limit usage of pods of priority bands together, pods of
+	// a band are evicted when it exceeds its limit
+	PriorityBands []PriorityBand `json:"priorityBands,omitempty"`
+	// TrafficClasses are thresholds of network traffic classes of the
+	// capacity of NetworkIo, they are checked instead of the node total if set
+	TrafficClasses map[string]Threshold `json:"trafficClasses,omitempty"`
+	// OverlayInterfaces are interfaces of overlay traffic, e.g. flannel.1
+	OverlayInterfaces []string `json:"overlayInterfaces,omitempty"`
+	// MemoryReclaim are rates of kernel reclaim over which Memory is busy
+	// before its threshold, an early signal of memory pressure
+	MemoryReclaim MemoryReclaim `json:"memoryReclaim"`
+	// Writeback is the backlog of dirty and writeback pages over which node
+	// is tainted WritebackBusy, it predicts io stalls better than throughput
+	Writeback Writeback `json:"writeback"`
+	// EphemeralPorts are used ephemeral ports over which node is tainted
+	// EphemeralPortsBusy, before connections fail for port exhaustion
+	EphemeralPorts EphemeralPorts `json:"ephemeralPorts"`
+	// Scoring are factors weighting scores of candidates besides usage and
+	// priority, e.g. by age and restarts of pods
+	Scoring Scoring `json:"scoring"`
+	// SidecarContainers are names of sidecar containers shared by pods, e.g. a
+	// logging agent, pods whose offender is one of them are labeled for the
+	// owner of the sidecar instead of evicted
+	SidecarContainers []string `json:"sidecarContainers,omitempty"`
+	// Severity grades busy conditions of resources by how far usage is past
+	// their thresholds, warning ones are handled softer than critical ones
+	Severity Severity `json:"severity"`
+	// InfrastructureContainers are containers whose usage is discounted when
+	// scoring pods
+	InfrastructureContainers InfrastructureContainers `json:"infrastructureContainers"`
+	// MaxIOBurst is the max duration of high io phases declared by pods, e.g.
+	// checkpoints, during which pods are not chosen for io, 30m if not set
+	MaxIOBurst GracePeriod `json:"maxIOBurst,omitempty"`
+	// NodePools are sections of policy for nodes selected by labels, the
+	// most specific one selecting node overrides fields of this policy
+	NodePools []NodePool `json:"nodePools,omitempty"`
+}
+
+// maxScoreWeight is the max weight of score factors
+const maxScoreWeight = 10
+
+// Scoring are factors weighting scores of candidates, a score is multiplied
+// by 1+weight*x of each factor, x is in [0, 1]. A factor not set is disabled.
+type Scoring struct {
+	// Age prefers young pods whose caches are cold with a positive weight, or
+	// old pods with a negative one, x is halfLife/(age+halfLife)
+	Age *AgeFactor `json:"age,omitempty"`
+	// Restarts prefers pods restarting recently, e.g. crash-looping, with a
+	// positive weight, or avoids them with a negative one, x is
+	// restarts/(restarts+half) of restarts in window
+	Restarts *RestartsFactor `json:"restarts,omitempty"`
+	// Cost prefers pods cheaper to restart with a positive weight, by the
+	// cost label or annotation of pods or their workloads, e.g. from a
+	// chargeback system, x is reference/(cost+reference)
+	Cost *CostFactor `json:"cost,omitempty"`
+}
+
+// DefaultCostKey is the default label or annotation of cost of pods
+const DefaultCostKey = "sncloud.com/cost"
+
+// CostFactor weights scores by cost of pods, x is 1 for a pod of no cost and
+// 0.5 at reference. Pods without cost are not weighted.
+type CostFactor struct {
+	Weight    float64 `json:"weight"`
+	Reference float64 `json:"reference"`
+	// Key is the label or annotation of cost, DefaultCostKey if empty
+	Key string `json:"key,omitempty"`
+}
+
+// CostKey returns the label or annotation of cost
+func (f *CostFactor) CostKey() string {
+	if f.Key == "" {
+		return DefaultCostKey
+	}
+	return f.Key
+}
+
+// AgeFactor weights scores by age of pods, x is 1 for a new pod and 0.5 at halfLife
+type AgeFactor struct {
+	Weight   float64     `json:"weight"`
+	HalfLife GracePeriod `json:"halfLife"`
+}
+
+// RestartsFactor weights scores by restarts of containers of pods in window,
+// x is 0.5 at half restarts
+type RestartsFactor struct {
+	Weight float64     `json:"weight"`
+	Half   int         `json:"half"`
+	Window GracePeriod `json:"window"`
+}
+
+func validateScoreWeight(weight float64) error {
+	if weight <= -1 || weight > maxScoreWeight {
+		return fmt.Errorf("weight %v should be in (-1, %d]", weight, maxScoreWeight)
+	}
+	return nil
+}
+
+func (s *Scoring) validate() error {
+	var errs []error
+	if s.Age != nil {
+		if err := validateScoreWeight(s.Age.Weight); err != nil {
+			errs = append(errs, fmt.Errorf("age: %v", err))
+		}
+		if s.Age.HalfLife <= 0 {
+			errs = append(errs, fmt.Errorf("age: halfLife %v should be positive", time.Duration(s.Age.HalfLife)))
+		}
+	}
+	if s.Restarts != nil {
+		if err := validateScoreWeight(s.Restarts.Weight); err != nil {
+			errs = append(errs, fmt.Errorf("restarts: %v", err))
+		}
+		if s.Restarts.Half <= 0 {
+			errs = append(errs, fmt.Errorf("restarts: half %v should be positive", s.Restarts.Half))
+		}
+		if s.Restarts.Window <= 0 {
+			errs = append(errs, fmt.Errorf("restarts: window %v should be positive", time.Duration(s.Restarts.Window)))
+		}
+	}
+	if s.Cost != nil {
+		if err := validateScoreWeight(s.Cost.Weight); err != nil {
+			errs = append(errs, fmt.Errorf("cost: %v", err))
+		}
+		if s.Cost.Reference <= 0 {
+			errs = append(errs, fmt.Errorf("cost: reference %v should be positive", s.Cost.Reference))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// EphemeralPorts is busy if used ephemeral ports of node are over threshold
+type EphemeralPorts struct {
+	// Threshold is a count or a ratio of ip_local_port_range, disabled if not set
+	Threshold *Threshold `json:"threshold,omitempty"`
+}
+
+func (e *EphemeralPorts) validate() error {
+	if e.Threshold == nil {
+		return nil
+	}
+	if err := e.Threshold.validate(); err != nil {
+		return fmt.Errorf("threshold: %v", err)
+	}
+	return nil
+}
+
+// Severity grades busy conditions of resources, disabled if CriticalExcess is
+// not set. Node is tainted PreferNoSchedule and pods are labeled under
+// warning, node is tainted NoSchedule and pods are evicted at once under
+// critical, without exemptions and pace of plans.
+type Severity struct {
+	// CriticalExcess is the excess over threshold by ratio of threshold from
+	// which a condition is critical, e.g. 0.1 for usage 10% past threshold
+	CriticalExcess float64 `json:"criticalExcess,omitempty"`
+}
+
+func (s *Severity) validate() error {
+	if s.CriticalExcess < 0 {
+		return fmt.Errorf("criticalExcess %v is negative", s.CriticalExcess)
+	}
+	return nil
+}
+
+// InfrastructureContainers are containers run in pods for the platform and
+// not controlled by owners of pods, e.g. service mesh proxies and log
+// shippers. Their usage is discounted when scoring pods, so that pods are not
+// evicted for the overhead of them.
+type InfrastructureContainers struct {
+	// Names are patterns of container names, e.g. istio-proxy or fluent-*
+	Names []string `json:"names,omitempty"`
+	// Images are patterns of images matching the image or its name without
+	// registry, path and tag, e.g. envoy or docker.io/istio/proxyv2:*
+	Images []string `json:"images,omitempty"`
+	// Discount is the ratio of usage of the containers not counted, 1 if not set
+	Discount *float64 `json:"discount,omitempty"`
+}
+
+// Enabled returns true if any container is matched
+func (i *InfrastructureContainers) Enabled() bool {
+	return len(i.Names) > 0 || len(i.Images) > 0
+}
+
+// DiscountRatio returns the ratio of usage of the containers not counted
+func (i *InfrastructureContainers) DiscountRatio() float64 {
+	if i.Discount == nil {
+		return 1
+	}
+	return *i.Discount
+}
+
+// Matches returns true if container of name and image is an infrastructure container
+func (i *InfrastructureContainers) Matches(name, image string) bool {
+	for _, pattern := range i.Names {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	if image == "" {
+		return false
+	}
+	// e.g. envoy of docker.io/envoyproxy/envoy:v1.14 or envoy@sha256:...
+	base := path.Base(image)
+	if i := strings.IndexAny(base, ":@"); i >= 0 {
+		base = base[:i]
+	}
+	for _, pattern := range i.Images {
+		if ok, _ := path.Match(pattern, image); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, base); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (i *InfrastructureContainers) validate() error {
+	var errs []error
+	for _, pattern := range append(append([]string{}, i.Names...), i.Images...) {
+		if _, err := path.Match(pattern, ""); pattern == "" || err != nil {
+			errs = append(errs, fmt.Errorf("bad pattern %q", pattern))
+		}
+	}
+	if i.Discount != nil && (*i.Discount < 0 || *i.Discount > 1) {
+		errs = append(errs, fmt.Errorf("discount %v should be in [0, 1]", *i.Discount))
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// Writeback is busy if dirty and writeback pages stay over threshold for sustainedFor
+type Writeback struct {
+	// Threshold is bytes or a ratio of memory capacity, disabled if not set
+	Threshold *Threshold `json:"threshold,omitempty"`
+	// SustainedFor is how long the backlog stays over threshold, zero for at once
+	SustainedFor GracePeriod `json:"sustainedFor,omitempty"`
+}
+
+func (w *Writeback) validate() error {
+	var errs []error
+	if w.Threshold != nil {
+		if err := w.Threshold.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("threshold: %v", err))
+		}
+	}
+	if w.SustainedFor < 0 {
+		errs = append(errs, fmt.Errorf("sustainedFor %v is negative", time.Duration(w.SustainedFor)))
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// MemoryReclaim are rates of reclaim activity of /proc/vmstat, Memory is busy
+// if any of them is exceeded. A rate of zero is not checked.
+type MemoryReclaim struct {
+	// KswapdScanRate is pages scanned by kswapd per second
+	KswapdScanRate float64 `json:"kswapdScanRate,omitempty"`
+	// DirectScanRate is pages scanned by direct reclaim of allocations per second
+	DirectScanRate float64 `json:"directScanRate,omitempty"`
+	// AllocStallRate is allocations stalled for direct reclaim per second
+	AllocStallRate float64 `json:"allocStallRate,omitempty"`
+}
+
+// Enabled returns true if any rate is checked
+func (r *MemoryReclaim) Enabled() bool {
+	return r.KswapdScanRate > 0 || r.DirectScanRate > 0 || r.AllocStallRate > 0
+}
+
+func (r *MemoryReclaim) validate() error {
+	if r.KswapdScanRate < 0 || r.DirectScanRate < 0 || r.AllocStallRate < 0 {
+		return fmt.Errorf("rates must not be negative")
+	}
+	return nil
+}
+
+// PriorityBand is the pods of priorities in [minPriority, maxPriority], whose
+// usage together is limited by limits of conditions in the format of
+// taintThreshold, ratios are of the capacity of node
+type PriorityBand struct {
+	Name        string               `json:"name"`
+	MinPriority int                  `json:"minPriority"`
+	MaxPriority int                  `json:"maxPriority"`
+	Limits      map[string]Threshold `json:"limits"`
+}
+
+// Contains returns true if priority is in the band
+func (b *PriorityBand) Contains(priority int) bool {
+	return priority >= b.MinPriority && priority <= b.MaxPriority
+}
+
+func (b *PriorityBand) validate() error {
+	var errs []error
+	if msgs := validation.IsDNS1123Label(b.Name); len(msgs) != 0 {
+		errs = append(errs, fmt.Errorf("name %q is invalid: %s", b.Name, strings.Join(msgs, "; ")))
+	}
+	if b.MinPriority > b.MaxPriority {
+		errs = append(errs, fmt.Errorf("minPriority %d is above maxPriority %d", b.MinPriority, b.MaxPriority))
+	}
+	if len(b.Limits) == 0 {
+		errs = append(errs, fmt.Errorf("limits are empty"))
+	}
+	for key, value := range b.Limits {
+		if !thresholdKeys[key] {
+			errs = append(errs, fmt.Errorf("unknown limits %q, should be one of CPU, Memory, DiskIo, NetworkIo", key))
+			continue
+		}
+		if err := value.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("limits %s: %v", key, err))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// QoS classes of pods in labelPolicy
+var qosClasses = map[string]bool{
+	"Guaranteed": true,
+	"Burstable":  true,
+	"BestEffort": true,
+}
+
+// LabelPolicy chooses the priority of evict labels: lower priority pods and
+// pods matching any rule are labeled NeedsEviction, other pods are labeled
+// EvictionCandidate. Without rules only lower priority pods are NeedsEviction.
+type LabelPolicy struct {
+	// UsageShare is the min share of the usage of all pods of the resource, e.g. 0.5
+	UsageShare float64 `json:"usageShare,omitempty"`
+	// QOSClasses are QoS classes of pods, e.g. BestEffort
+	QOSClasses []string `json:"qosClasses,omitempty"`
+	// OwnerKinds are kinds of controllers of pods, e.g. Job
+	OwnerKinds []string `json:"ownerKinds,omitempty"`
+}
+
+// NeedsPods returns true if rules need the QoS class or controller of pods
+func (p *LabelPolicy) NeedsPods() bool {
+	return len(p.QOSClasses) > 0 || len(p.OwnerKinds) > 0
+}
+
+func (p *LabelPolicy) validate() error {
+	var errs []error
+	if p.UsageShare < 0 || p.UsageShare > 1 {
+		errs = append(errs, fmt.Errorf("usageShare %v should be in [0, 1]", p.UsageShare))
+	}
+	for _, class := range p.QOSClasses {
+		if !qosClasses[class] {
+			errs = append(errs, fmt.Errorf("unknown qosClasses %q, should be one of Guaranteed, Burstable, BestEffort", class))
+		}
+	}
+	for _, kind := range p.OwnerKinds {
+		if kind == "" {
+			errs = append(errs, fmt.Errorf("ownerKinds has an empty kind"))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// LoadFile reads policy configuration from file, defaultProfile is the
+// profile if it sets none
+func LoadFile(file, defaultProfile string) (*PolicyConfig, error) {
+	configFile, err := os.Open(file)
+	if err != nil {
+		log.Errorf("open policy config file error: %v", err)
+		return nil, err
+	}
+	defer configFile.Close()
+
+	byteValue, err := ioutil.ReadAll(configFile)
+	if err != nil {
+		log.Errorf("read policy config file %v error: %v", file, err)
+		return nil, err
+	}
+
+	config, err := Parse(byteValue, defaultProfile)
+	if err != nil {
+		log.Errorf("json unmarshal failed for file: %v, error: %v", file, err)
+		return nil, err
+	}
+	return config, nil
+}
+
+// Parse decodes policy configuration in json or yaml strictly, unknown fields
+// are rejected, defaultProfile is the profile if it sets none
+func Parse(data []byte, defaultProfile string) (*PolicyConfig, error) {
+	data, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	var config PolicyConfig
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&config); err != nil {
+		return nil, err
+	}
+	if err := ApplyProfile(&config, data, defaultProfile); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// Validate checks all values of policy, an invalid policy must not be used
+func (p *PolicyConfig) Validate() error {
+	var errs []error
+	if _, ok := profiles[p.Profile]; p.Profile != "" && !ok {
+		errs = append(errs, fmt.Errorf("unknown profile %q, should be one of %v", p.Profile, Profiles()))
+	}
+	if p.UntaintGracePeriod < 0 {
+		errs = append(errs, fmt.Errorf("untaintGracePeriod %v is negative", time.Duration(p.UntaintGracePeriod)))
+	}
+	if p.MaxIOBurst < 0 {
+		errs = append(errs, fmt.Errorf("maxIOBurst %v is negative", time.Duration(p.MaxIOBurst)))
+	}
+	for key, value := range p.TaintThreshold {
+		if !thresholdKeys[key] {
+			errs = append(errs, fmt.Errorf("unknown taintThreshold %q, should be one of CPU, Memory, DiskIo, NetworkIo", key))
+			continue
+		}
+		if err := value.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("taintThreshold %s: %v", key, err))
+		}
+	}
+	for _, iface := range p.NetworkInterfaces {
+		if iface == "" {
+			errs = append(errs, fmt.Errorf("networkInterfaces has an empty name"))
+		}
+	}
+	if p.NetworkBPSTotal < 0 && p.NetworkBPSTotal != AutoByteRate {
+		errs = append(errs, fmt.Errorf("networkBPSTotal %v is negative", p.NetworkBPSTotal))
+	}
+	if p.DiskIOPSTotal < 0 {
+		errs = append(errs, fmt.Errorf("diskIOPSTotal %v is negative", p.DiskIOPSTotal))
+	}
+	if p.LowPriorityThreshold < 0 {
+		errs = append(errs, fmt.Errorf("lowPriorityThreshold %v is negative", p.LowPriorityThreshold))
+	}
+	for _, ns := range p.ProtectedNamespaces {
+		if ns == "" {
+			errs = append(errs, fmt.Errorf("protectedNamespaces has an empty name"))
+		}
+	}
+	for key, value := range p.SystemReserved {
+		if key != CPUCondition && key != MemoryCondition {
+			errs = append(errs, fmt.Errorf("unknown systemReserved %q, should be one of CPU, Memory", key))
+			continue
+		}
+		if err := value.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("systemReserved %s: %v", key, err))
+		}
+	}
+	if err := ValidateConditions(p.DisabledConditions); err != nil {
+		errs = append(errs, fmt.Errorf("disabledConditions: %v", err))
+	}
+	for _, action := range p.Actions {
+		if !policyActions[action] {
+			errs = append(errs, fmt.Errorf("unknown actions %q, should be one of Taint, Evict, Label", action))
+		}
+	}
+	if err := p.LabelPolicy.validate(); err != nil {
+		errs = append(errs, fmt.Errorf("labelPolicy: %v", err))
+	}
+	for key, value := range p.TrafficClasses {
+		if !trafficClasses[key] {
+			errs = append(errs, fmt.Errorf("unknown trafficClasses %q, should be one of pod, host, overlay", key))
+			continue
+		}
+		if err := value.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("trafficClasses %s: %v", key, err))
+		}
+	}
+	if _, ok := p.TrafficClasses[OverlayTraffic]; ok && len(p.OverlayInterfaces) == 0 {
+		errs = append(errs, fmt.Errorf("trafficClasses overlay needs overlayInterfaces"))
+	}
+	for _, iface := range p.OverlayInterfaces {
+		if iface == "" {
+			errs = append(errs, fmt.Errorf("overlayInterfaces has an empty name"))
+		}
+	}
+	if err := p.MemoryReclaim.validate(); err != nil {
+		errs = append(errs, fmt.Errorf("memoryReclaim: %v", err))
+	}
+	if err := p.Writeback.validate(); err != nil {
+		errs = append(errs, fmt.Errorf("writeback: %v", err))
+	}
+	if err := p.EphemeralPorts.validate(); err != nil {
+		errs = append(errs, fmt.Errorf("ephemeralPorts: %v", err))
+	}
+	if err := p.Scoring.validate(); err != nil {
+		errs = append(errs, fmt.Errorf("scoring: %v", err))
+	}
+	for _, name := range p.SidecarContainers {
+		if name == "" {
+			errs = append(errs, fmt.Errorf("sidecarContainers has an empty name"))
+		}
+	}
+	if err := p.InfrastructureContainers.validate(); err != nil {
+		errs = append(errs, fmt.Errorf("infrastructureContainers: %v", err))
+	}
+	if err := p.Severity.validate(); err != nil {
+		errs = append(errs, fmt.Errorf("severity: %v", err))
+	}
+	names := make(map[string]bool)
+	for i := range p.PriorityBands {
+		band := &p.PriorityBands[i]
+		if names[band.Name] {
+			errs = append(errs, fmt.Errorf("priorityBands has duplicated name %q", band.Name))
+		}
+		names[band.Name] = true
+		if err := band.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("priorityBands %s: %v", band.Name, err))
+		}
+	}
+	if err := p.validateNodePools(); err != nil {
+		errs = append(errs, err)
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// Warnings returns settings which are valid but probably not intended
+func (p *PolicyConfig) Warnings() []string {
+	var warnings []string
+	if p.AutoEvictFlag && p.LowPriorityThreshold == 0 {
+		warnings = append(warnings, "autoEvictFlag is set but lowPriorityThreshold is 0, no pod would be evicted")
+	}
+	if len(p.NetworkInterfaces) == 0 {
+		warnings = append(warnings, "networkInterfaces is empty, network io is never checked")
+	}
+	if len(p.ProtectedNamespaces) == 0 {
+		warnings = append(warnings, "protectedNamespaces is empty, system pods may be evicted")
+	}
+	return warnings
+}
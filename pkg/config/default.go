@@ -0,0 +1,173 @@
+package config
+
+import (
+	"fmt"
+)
+
+// Default values used when they are not set in policy configuration
+const (
+	DefaultUntaintGracePeriod = 5 // minutes
+	DefaultTaintThreshold     = 1.0
+	DefaultDiskIOPSTotal      = 10000
+	DefaultNetworkBPSTotal    = 100000000
+)
+
+// defaultConfigTemplate is the commented default policy configuration in yaml
+const defaultConfigTemplate = `# Policy configuration of eviction agent, json is accepted as well.
+
+# Built-in preset overridden field by field by this file, one of
+# conservative, balanced and aggressive, or empty.
+profile: ""
+
+# Minutes, or a duration like "30s", to wait after a condition recovers
+# before the taint is removed.
+untaintGracePeriod: %d
+
+# Usage above which the node is tainted. A number in (0, 1] or a percentage
+# like "85%%" is a ratio of the capacity, a string like "3" (cores), "8Gi",
+# "5k" (iops), "200Mi/s" or "1.5Gbps" is an absolute value. Memory capacity
+# is from node status, network bandwidth is from NIC speed if not set.
+taintThreshold:
+  CPU: %v
+  Memory: %v
+  DiskIo: %v
+  NetworkIo: %v
+
+# Evict lower priority pods directly, otherwise they are only labeled.
+autoEvictFlag: false
+
+# Actions taken on busy conditions, some of Taint, Evict and Label, all of them
+# if empty. Without Taint pods are evicted or labeled on an untainted node,
+# without Evict pods are labeled even if autoEvictFlag is set, and without
+# Label pods which are not evicted are left alone, e.g. [Taint, Label].
+actions: []
+
+# Network interfaces whose traffic is summed and checked.
+networkInterfaces: []
+
+# Bytes per second of each network interface, e.g. 125000000 or "1Gbps", or
+# "auto" to detect it from link speed, of a bond the sum of its members which
+# are up, again every minute as links renegotiate. A value other than 0
+# overrides node annotation sncloud.com/networkBandwidthCapacity, 0 uses the
+# annotation, or detects it from link speed if node is not annotated.
+networkBPSTotal: 0
+
+# Disk device to check, the first device of each container if empty. A name
+# like sda, /dev/mapper/vg-lv or a mountpoint like /var/lib/kubelet is resolved
+# to its physical devices under LVM, device-mapper and RAID, io of devices
+# stacked on them is attributed to them.
+diskDevName: ""
+
+# IOPS of the disk. A value other than 0 overrides node annotation
+# sncloud.com/diskIOPSCapacity, 0 uses the annotation, which is required then.
+diskIOPSTotal: 0
+
+# Pods with a priority in (0, lowPriorityThreshold] are lower priority pods.
+lowPriorityThreshold: 0
+
+# Pods in these namespaces are never evicted or labeled.
+protectedNamespaces:
+- kube-system
+
+# Conditions neither monitored nor tainted, some of CPU, Memory, DiskIo, NetworkIo.
+disabledConditions: []
+
+# Headroom of CPU and Memory reserved for system daemons like kubelet, the
+# container runtime and monitoring, in the format of taintThreshold. If set,
+# usage of pods instead of node is checked against the threshold of the
+# capacity minus the headroom, e.g. {CPU: "1", Memory: "2Gi"}.
+systemReserved: {}
+
+# Priority of pods labeled instead of evicted. Lower priority pods and pods
+# matching any rule are labeled NeedsEviction, other pods EvictionCandidate:
+# usageShare is the min share of the usage of all pods of the resource,
+# qosClasses are QoS classes like BestEffort, ownerKinds are kinds of
+# controllers like Job.
+labelPolicy: {}
+
+# Usage of pods of priority bands together is limited, in the format of
+# taintThreshold of the capacity of node. Pods of a band exceeding a limit are
+# evicted, or labeled, within the band only, and node is not tainted, e.g.
+# - {name: best-effort, minPriority: 0, maxPriority: 100, limits: {DiskIo: "30%%"}}
+priorityBands: []
+
+# Thresholds of network traffic classes in the format of taintThreshold of the
+# capacity of NetworkIo, checked instead of the total of networkInterfaces if
+# set: pod is traffic of pods of pod network, host is the rest of node
+# interfaces, e.g. image pulls and pods of host network, overlay is traffic of
+# overlayInterfaces. NetworkIo is busy if any class is, and only pods of pod
+# network are evicted, for pod and overlay, e.g.
+# {pod: "70%%", host: "90%%", overlay: "60%%"} with overlayInterfaces [flannel.1].
+trafficClasses: {}
+overlayInterfaces: []
+
+# Rates of kernel reclaim of /proc/vmstat over which Memory is busy before its
+# threshold, so that pods are evicted gracefully before the OOM killer: pages
+# scanned by kswapd and by direct reclaim per second, and allocation stalls per
+# second. Zero is not checked, e.g. {directScanRate: 1000, allocStallRate: 1}.
+memoryReclaim: {}
+
+# Backlog of dirty and writeback pages over which node is tainted WritebackBusy
+# and pods with most dirty pages of their cgroups are evicted, in the format of
+# taintThreshold of memory capacity, once it's sustained for sustainedFor in
+# the format of untaintGracePeriod, e.g. {threshold: "4Gi", sustainedFor: "1m"}.
+writeback: {}
+
+# Used ephemeral ports of node over which node is tainted EphemeralPortsBusy and
+# pods with most open sockets are evicted, a count or in the format of
+# taintThreshold of ip_local_port_range, e.g. {threshold: "80%%"}.
+ephemeralPorts: {}
+
+# Factors weighting scores of candidates, a score is multiplied by 1+weight*x
+# of each factor, weight is in (-1, 10] and x in [0, 1]. Age prefers young pods
+# whose caches are cold with a positive weight, x is 0.5 at halfLife. Restarts
+# prefers pods restarting in window, e.g. crash-looping, with a positive
+# weight, or avoids them with a negative one, x is 0.5 at half restarts, e.g.
+# {age: {weight: 1, halfLife: "1h"}, restarts: {weight: -0.5, half: 3, window: "1h"}}.
+# Cost prefers pods cheaper to restart with a positive weight, by the label or
+# annotation key (sncloud.com/cost by default) of pods or their workloads, x is
+# 0.5 at reference cost, e.g. {cost: {weight: 1, reference: 10}}.
+scoring: {}
+
+# Names of sidecar containers shared by pods, e.g. [fluent-bit]. The container
+# using the most of the resource of a chosen pod is its offender, the pod is
+# labeled instead of evicted if the offender is one of them, so that the owner
+# of the sidecar fixes it.
+sidecarContainers: []
+
+# Grades of busy conditions of resources by how far usage is past threshold, a
+# condition is critical from criticalExcess of threshold past it, and warning
+# below. Node is tainted PreferNoSchedule and pods are labeled under warning,
+# node is tainted NoSchedule and pods are evicted at once under critical,
+# e.g. {criticalExcess: 0.1}. Conditions are not graded if it's not set.
+severity: {}
+
+# Containers run in pods for the platform, e.g. service mesh proxies and log
+# shippers, by patterns of names or images, an image pattern matches the image
+# or its name without registry, path and tag. Discount of their usage, 1 by
+# default, is not counted when scoring pods, so that pods are not evicted for
+# the overhead of them, e.g. {names: [istio-proxy], images: [envoy], discount: 1}.
+infrastructureContainers: {}
+
+# Max duration in the format of untaintGracePeriod of high io phases declared
+# by pods with annotation sncloud.com/ioBurst, e.g. checkpoints and compactions
+# of databases, pods are not chosen for DiskIo and writeback during them. The
+# annotation is a duration from when it's seen, e.g. "10m", or a start time and
+# a duration, e.g. "2026-10-14T06:00:00Z/10m". 30m if it's not set.
+maxIOBurst: 0
+
+# Sections of policy for nodes selected by labels, fields of policy of the most
+# specific pool selecting node override the ones above, pools with the same
+# number of selectors are ordered as listed, policy of a pool can't set profile
+# or nodePools. Pools are selected again when labels of node change, e.g.
+# - name: spot
+#   nodeSelector: {pool: spot}
+#   policy: {taintThreshold: {CPU: "80%%"}, autoEvictFlag: true}
+nodePools: []
+`
+
+// DefaultConfig returns the commented default policy configuration
+func DefaultConfig() string {
+	return fmt.Sprintf(defaultConfigTemplate, DefaultUntaintGracePeriod,
+		DefaultTaintThreshold, DefaultTaintThreshold, DefaultTaintThreshold, DefaultTaintThreshold)
+}
@@ -0,0 +1,112 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/labels"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// NodePool is a section of policy for nodes selected by labels, e.g.
+// role=ingress, pool=spot or storage=nvme, so that one policy serves
+// heterogeneous nodes with their own thresholds and actions
+type NodePool struct {
+	Name string `json:"name"`
+	// NodeSelector selects nodes by labels, it must not be empty
+	NodeSelector map[string]string `json:"nodeSelector"`
+	// Policy are fields of policy overriding the ones of the base policy field
+	// by field, except profile and nodePools
+	Policy json.RawMessage `json:"policy"`
+}
+
+// SelectNodePool returns the most specific pool whose node selector matches
+// node labels, pools with the same number of selectors are ordered as listed.
+// Returns nil if there is none.
+func SelectNodePool(pools []NodePool, nodeLabels map[string]string) *NodePool {
+	var matched []*NodePool
+	for i := range pools {
+		selector := labels.SelectorFromSet(labels.Set(pools[i].NodeSelector))
+		if len(pools[i].NodeSelector) != 0 && selector.Matches(labels.Set(nodeLabels)) {
+			matched = append(matched, &pools[i])
+		}
+	}
+	if len(matched) == 0 {
+		return nil
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		return len(matched[i].NodeSelector) > len(matched[j].NodeSelector)
+	})
+	return matched[0]
+}
+
+// ForNode returns the policy of the node pool selecting node labels merged
+// over the base policy and the name of the pool, or the base policy and an
+// empty name if no pool selects node. Node pools of the returned policy are
+// dropped.
+func (p *PolicyConfig) ForNode(nodeLabels map[string]string) (*PolicyConfig, string, error) {
+	pool := SelectNodePool(p.NodePools, nodeLabels)
+	if pool == nil {
+		return p, "", nil
+	}
+	merged, err := p.merge(pool)
+	if err != nil {
+		return nil, "", fmt.Errorf("nodePools %s: %v", pool.Name, err)
+	}
+	return merged, pool.Name, nil
+}
+
+// merge decodes policy of pool over a copy of the base policy strictly
+func (p *PolicyConfig) merge(pool *NodePool) (*PolicyConfig, error) {
+	merged := p.DeepCopy()
+	merged.NodePools = nil
+	if len(pool.Policy) == 0 {
+		return merged, nil
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(pool.Policy, &fields); err != nil {
+		return nil, fmt.Errorf("policy should be an object: %v", err)
+	}
+	for _, key := range []string{"profile", "nodePools"} {
+		if _, ok := fields[key]; ok {
+			return nil, fmt.Errorf("policy can't set %s", key)
+		}
+	}
+	decoder := json.NewDecoder(bytes.NewReader(pool.Policy))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(merged); err != nil {
+		return nil, err
+	}
+	return merged, nil
+}
+
+// validateNodePools checks all pools and their policies merged over the base policy
+func (p *PolicyConfig) validateNodePools() error {
+	var errs []error
+	names := make(map[string]bool)
+	for i := range p.NodePools {
+		pool := &p.NodePools[i]
+		if pool.Name == "" {
+			errs = append(errs, fmt.Errorf("nodePools[%d] has no name", i))
+			continue
+		}
+		if names[pool.Name] {
+			errs = append(errs, fmt.Errorf("nodePools has duplicated name %q", pool.Name))
+		}
+		names[pool.Name] = true
+		if len(pool.NodeSelector) == 0 {
+			errs = append(errs, fmt.Errorf("nodePools %s: nodeSelector should not be empty", pool.Name))
+		}
+		merged, err := p.merge(pool)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("nodePools %s: %v", pool.Name, err))
+			continue
+		}
+		if err := merged.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("nodePools %s: %v", pool.Name, err))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
@@ -0,0 +1,200 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// EnvPrefix is the prefix of environment variables overriding policy fields,
+// e.g. EVICTION_POLICY_UNTAINT_GRACE_PERIOD or EVICTION_POLICY_TAINT_THRESHOLD_CPU
+const EnvPrefix = "EVICTION_POLICY_"
+
+// notOverridable are fields which can't be overridden, profile is set by
+// --profile, labelPolicy, priorityBands, memoryReclaim, writeback,
+// ephemeralPorts, scoring, severity, infrastructureContainers and nodePools
+// have fields of their own
+var notOverridable = map[string]bool{
+	"profile":                  true,
+	"labelPolicy":              true,
+	"priorityBands":            true,
+	"memoryReclaim":            true,
+	"writeback":                true,
+	"ephemeralPorts":           true,
+	"scoring":                  true,
+	"severity":                 true,
+	"nodePools":                true,
+	"infrastructureContainers": true,
+}
+
+// Override sets one field of policy, Key is the json name of the field,
+// or taintThreshold.<condition> for thresholds
+type Override struct {
+	Key   string
+	Value string
+}
+
+// ParseOverride parses key=value
+func ParseOverride(s string) (Override, error) {
+	parts := strings.SplitN(s, "=", 2)
+	if len(parts) != 2 {
+		return Override{}, fmt.Errorf("override %q should be key=value", s)
+	}
+	o := Override{Key: strings.TrimSpace(parts[0]), Value: strings.TrimSpace(parts[1])}
+	if _, err := o.field(); err != nil {
+		return Override{}, err
+	}
+	return o, nil
+}
+
+// OverrideKeys returns all keys which can be overridden
+func OverrideKeys() []string {
+	var keys []string
+	t := reflect.TypeOf(PolicyConfig{})
+	for i := 0; i < t.NumField(); i++ {
+		name := jsonName(t.Field(i))
+		if name == "" || notOverridable[name] {
+			continue
+		}
+		if t.Field(i).Type.Kind() == reflect.Map {
+			for condition := range thresholdKeys {
+				keys = append(keys, name+"."+condition)
+			}
+			continue
+		}
+		keys = append(keys, name)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// EnvOverrides returns overrides from environment variables found by lookup
+func EnvOverrides(lookup func(string) (string, bool)) []Override {
+	var overrides []Override
+	for _, key := range OverrideKeys() {
+		if value, ok := lookup(EnvName(key)); ok {
+			overrides = append(overrides, Override{Key: key, Value: value})
+		}
+	}
+	return overrides
+}
+
+// EnvName returns the environment variable of key,
+// e.g. EVICTION_POLICY_TAINT_THRESHOLD_DISK_IO for taintThreshold.DiskIo
+func EnvName(key string) string {
+	runes := []rune(key)
+	var name []rune
+	for i, r := range runes {
+		if r == '.' {
+			name = append(name, '_')
+			continue
+		}
+		// a word starts at an upper case letter after a lower case one,
+		// or at the last upper case letter of an acronym, e.g. BPS in BPSTotal
+		if i > 0 && unicode.IsUpper(r) && runes[i-1] != '.' && (unicode.IsLower(runes[i-1]) ||
+			(i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))) {
+			name = append(name, '_')
+		}
+		name = append(name, unicode.ToUpper(r))
+	}
+	return EnvPrefix + string(name)
+}
+
+// field returns the struct field of key
+func (o Override) field() (reflect.StructField, error) {
+	parts := strings.Split(o.Key, ".")
+	t := reflect.TypeOf(PolicyConfig{})
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if jsonName(f) != parts[0] || notOverridable[parts[0]] {
+			continue
+		}
+		isMap := f.Type.Kind() == reflect.Map
+		if isMap && (len(parts) != 2 || !thresholdKeys[parts[1]]) {
+			return f, fmt.Errorf("unknown override %q, should be %s.<CPU|Memory|DiskIo|NetworkIo>", o.Key, parts[0])
+		}
+		if !isMap && len(parts) != 1 {
+			break
+		}
+		return f, nil
+	}
+	return reflect.StructField{}, fmt.Errorf("unknown override %q, should be one of %v", o.Key, OverrideKeys())
+}
+
+// jsonValue converts the value into json of the field type, lists are comma separated
+func (o Override) jsonValue(f reflect.StructField) interface{} {
+	kind := f.Type.Kind()
+	if kind == reflect.Map {
+		kind = f.Type.Elem().Kind()
+	}
+	switch kind {
+	case reflect.String:
+		return o.Value
+	case reflect.Bool:
+		if b, err := strconv.ParseBool(o.Value); err == nil {
+			return b
+		}
+	case reflect.Slice:
+		list := []string{}
+		for _, item := range strings.Split(o.Value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		return list
+	default:
+		if _, err := strconv.ParseFloat(o.Value, 64); err == nil {
+			return json.Number(o.Value)
+		}
+	}
+	// let the field decoder report it, or parse the unit
+	return o.Value
+}
+
+// ApplyOverrides sets fields of policy in order, later overrides win
+func ApplyOverrides(policy *PolicyConfig, overrides []Override) error {
+	for _, o := range overrides {
+		f, err := o.field()
+		if err != nil {
+			return err
+		}
+		var value interface{} = o.jsonValue(f)
+		parts := strings.Split(o.Key, ".")
+		if len(parts) == 2 {
+			value = map[string]interface{}{parts[1]: value}
+		}
+		data, err := json.Marshal(map[string]interface{}{parts[0]: value})
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, policy); err != nil {
+			return fmt.Errorf("override %s=%s: %v", o.Key, o.Value, err)
+		}
+	}
+	return nil
+}
+
+// DeepCopy returns a copy of policy sharing no maps or slices
+func (p *PolicyConfig) DeepCopy() *PolicyConfig {
+	data, err := json.Marshal(p)
+	if err != nil {
+		panic(err)
+	}
+	var copied PolicyConfig
+	if err := json.Unmarshal(data, &copied); err != nil {
+		panic(err)
+	}
+	return &copied
+}
+
+func jsonName(f reflect.StructField) string {
+	name := strings.Split(f.Tag.Get("json"), ",")[0]
+	if name == "-" {
+		return ""
+	}
+	return name
+}
@@ -0,0 +1,93 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Built-in profiles
+const (
+	ConservativeProfile = "conservative"
+	BalancedProfile     = "balanced"
+	AggressiveProfile   = "aggressive"
+)
+
+// profiles returns a new preset of each built-in profile
+var profiles = map[string]func() PolicyConfig{
+	// label pods only, taint late and untaint late
+	ConservativeProfile: func() PolicyConfig {
+		return PolicyConfig{
+			UntaintGracePeriod:  GracePeriod(10 * time.Minute),
+			TaintThreshold:      ratioThresholds(0.95),
+			AutoEvictFlag:       false,
+			ProtectedNamespaces: []string{"kube-system"},
+		}
+	},
+	BalancedProfile: func() PolicyConfig {
+		return PolicyConfig{
+			UntaintGracePeriod:  GracePeriod(5 * time.Minute),
+			TaintThreshold:      ratioThresholds(0.9),
+			AutoEvictFlag:       true,
+			ProtectedNamespaces: []string{"kube-system"},
+		}
+	},
+	// evict pods early, untaint soon
+	AggressiveProfile: func() PolicyConfig {
+		return PolicyConfig{
+			UntaintGracePeriod:  GracePeriod(3 * time.Minute),
+			TaintThreshold:      ratioThresholds(0.8),
+			AutoEvictFlag:       true,
+			ProtectedNamespaces: []string{"kube-system"},
+		}
+	},
+}
+
+func ratioThresholds(ratio float64) map[string]Threshold {
+	return map[string]Threshold{
+		CPUCondition:       {Ratio: ratio},
+		MemoryCondition:    {Ratio: ratio},
+		DiskIOCondition:    {Ratio: ratio},
+		NetworkIOCondition: {Ratio: ratio},
+	}
+}
+
+// Profiles returns names of all built-in profiles
+func Profiles() []string {
+	var names []string
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ValidateProfile returns error if name is neither empty nor a built-in profile
+func ValidateProfile(name string) error {
+	if _, ok := profiles[name]; name != "" && !ok {
+		return fmt.Errorf("unknown profile %q, should be one of %v", name, Profiles())
+	}
+	return nil
+}
+
+// ApplyProfile decodes data again over the preset of the profile of policy,
+// or defaultProfile if it sets none, so that fields set in data override the
+// preset field by field. Unknown profiles are left to Validate.
+func ApplyProfile(policy *PolicyConfig, data []byte, defaultProfile string) error {
+	name := policy.Profile
+	if name == "" {
+		name = defaultProfile
+	}
+	preset, ok := profiles[name]
+	if !ok {
+		return nil
+	}
+	merged := preset()
+	if err := json.Unmarshal(data, &merged); err != nil {
+		return err
+	}
+	merged.Profile = name
+	*policy = merged
+	return nil
+}
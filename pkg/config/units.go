@@ -0,0 +1,184 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// bitRatePrefixes are the decimal prefixes of bit rates like 1.5Gbps
+var bitRatePrefixes = map[string]float64{
+	"":  1,
+	"k": 1e3,
+	"K": 1e3,
+	"M": 1e6,
+	"G": 1e9,
+	"T": 1e12,
+}
+
+// Threshold is a ratio of the capacity, or an absolute value in base unit,
+// i.e. cores, bytes, iops or bytes per second. A json number is a ratio,
+// a string is either a percentage like "85%" or an absolute value like
+// "3", "500m", "8Gi", "5k", "200Mi/s" or "1.5Gbps".
+type Threshold struct {
+	Ratio    float64
+	Absolute float64
+}
+
+// Value returns the threshold of the given capacity
+func (t Threshold) Value(capacity float64) float64 {
+	if t.Absolute > 0 {
+		return t.Absolute
+	}
+	return capacity * t.Ratio
+}
+
+func (t Threshold) String() string {
+	if t.Absolute > 0 {
+		return strconv.FormatFloat(t.Absolute, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(t.Ratio*100, 'f', -1, 64) + "%"
+}
+
+// validate checks ratio is in (0, 1] or absolute value is positive
+func (t Threshold) validate() error {
+	if t.Absolute == 0 && (t.Ratio <= 0 || t.Ratio > 1) {
+		return fmt.Errorf("ratio %v should be in (0, 1]", t.Ratio)
+	}
+	if t.Absolute < 0 {
+		return fmt.Errorf("value %v is negative", t.Absolute)
+	}
+	return nil
+}
+
+// UnmarshalJSON decodes a ratio number or a string with unit
+func (t *Threshold) UnmarshalJSON(data []byte) error {
+	var ratio float64
+	if err := json.Unmarshal(data, &ratio); err == nil {
+		*t = Threshold{Ratio: ratio}
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("threshold should be a number or a string, got %s", data)
+	}
+	threshold, err := ParseThreshold(s)
+	if err != nil {
+		return err
+	}
+	*t = threshold
+	return nil
+}
+
+// MarshalJSON encodes ratio as a number and absolute value as a string
+func (t Threshold) MarshalJSON() ([]byte, error) {
+	if t.Absolute > 0 {
+		return json.Marshal(strconv.FormatFloat(t.Absolute, 'f', -1, 64))
+	}
+	return json.Marshal(t.Ratio)
+}
+
+// ParseThreshold parses a percentage or a value with unit
+func ParseThreshold(s string) (Threshold, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasSuffix(s, "%") {
+		v, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(s, "%")), 64)
+		if err != nil {
+			return Threshold{}, fmt.Errorf("invalid percentage %q", s)
+		}
+		return Threshold{Ratio: v / 100}, nil
+	}
+	v, err := ParseValue(s)
+	if err != nil {
+		return Threshold{}, err
+	}
+	return Threshold{Absolute: v}, nil
+}
+
+// ParseValue parses a value with unit into base unit. Bit rates like
+// "1.5Gbps" are converted to bytes per second, "/s" is ignored so that
+// "200Mi/s" is 200Mi bytes per second, others are kubernetes quantities.
+func ParseValue(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasSuffix(s, "bps") {
+		number := strings.TrimSuffix(s, "bps")
+		prefix := ""
+		if n := len(number); n > 0 {
+			if _, ok := bitRatePrefixes[number[n-1:]]; ok {
+				prefix, number = number[n-1:], number[:n-1]
+			}
+		}
+		v, err := strconv.ParseFloat(number, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid bit rate %q", s)
+		}
+		return v * bitRatePrefixes[prefix] / 8, nil
+	}
+	q, err := resource.ParseQuantity(strings.TrimSuffix(s, "/s"))
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q: %v", s, err)
+	}
+	return float64(q.MilliValue()) / 1000, nil
+}
+
+// ByteRate is bytes per second, decoded from a json number
+// or a string like "200Mi/s" or "1.5Gbps"
+type ByteRate int64
+
+// AutoByteRate is the byte rate of "auto", detected from capacity of devices
+const AutoByteRate ByteRate = -1
+
+// UnmarshalJSON decodes a number or a string with unit
+func (r *ByteRate) UnmarshalJSON(data []byte) error {
+	var n int64
+	if err := json.Unmarshal(data, &n); err == nil {
+		*r = ByteRate(n)
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("byte rate should be a number or a string, got %s", data)
+	}
+	if s == "auto" {
+		*r = AutoByteRate
+		return nil
+	}
+	v, err := ParseValue(s)
+	if err != nil {
+		return err
+	}
+	*r = ByteRate(v)
+	return nil
+}
+
+// GracePeriod is decoded from a json number of minutes, or a duration
+// string like "30s" or "1m30s" for periods shorter than a minute
+type GracePeriod time.Duration
+
+// UnmarshalJSON decodes minutes or a duration string
+func (g *GracePeriod) UnmarshalJSON(data []byte) error {
+	var minutes float64
+	if err := json.Unmarshal(data, &minutes); err == nil {
+		*g = GracePeriod(minutes * float64(time.Minute))
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("grace period should be minutes or a duration string, got %s", data)
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("invalid grace period %q: %v", s, err)
+	}
+	*g = GracePeriod(d)
+	return nil
+}
+
+// MarshalJSON encodes a duration string
+func (g GracePeriod) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(g).String())
+}
@@ -0,0 +1,210 @@
+package control
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"eviction-agent/pkg/condition"
+	"eviction-agent/pkg/control/v1"
+	"eviction-agent/pkg/evictionmanager"
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/server"
+	"eviction-agent/pkg/tracing"
+)
+
+// Manager is the eviction manager served by control api
+type Manager interface {
+	GetConditions() *evictionmanager.Conditions
+	GetEvictionCandidates(evictType string) ([]condition.Candidate, error)
+	Pause(until time.Time) (*evictionmanager.ControlResult, error)
+	Resume() (*evictionmanager.ControlResult, error)
+	TriggerEvaluation() *evictionmanager.ControlResult
+}
+
+// Server serves control api v1 of manager over grpc
+type Server struct {
+	addr string
+	grpc *grpc.Server
+}
+
+// NewServer creates a server of control api listening on addr, a unix socket
+// like unix:///var/run/eviction-agent/control.sock or host:port. All calls
+// require the bearer token if it's not empty, tls is served with tlsConfig if
+// it's not nil, which may require client certificates.
+func NewServer(addr, token string, tlsConfig *tls.Config, manager Manager) *Server {
+	options := []grpc.ServerOption{grpc.UnaryInterceptor(intercept(token))}
+	if tlsConfig != nil {
+		options = append(options, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	s := &Server{addr: addr, grpc: grpc.NewServer(options...)}
+	v1.RegisterControlServer(s.grpc, &controlService{manager: manager})
+	return s
+}
+
+// Start serves grpc calls in background
+func (s *Server) Start() {
+	log.Infof("Start control server on %v", s.addr)
+	listener, err := server.Listen(s.addr)
+	if err != nil {
+		log.Errorf("control server on %v stopped: %v", s.addr, err)
+		return
+	}
+	go func() {
+		if err := s.grpc.Serve(listener); err != nil {
+			log.Errorf("control server on %v stopped: %v", s.addr, err)
+		}
+	}()
+}
+
+// intercept rejects calls without the bearer token if it's not empty, and
+// traces calls as children of their traceparent
+func intercept(token string) grpc.UnaryServerInterceptor {
+	expected := []byte("Bearer " + token)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		if token != "" {
+			var got []byte
+			if values := md.Get("authorization"); len(values) > 0 {
+				got = []byte(values[0])
+			}
+			if subtle.ConstantTimeCompare(got, expected) != 1 {
+				return nil, status.Error(codes.Unauthenticated, "unauthorized")
+			}
+		}
+		header := http.Header{}
+		for key, values := range md {
+			for _, value := range values {
+				header.Add(key, value)
+			}
+		}
+		ctx, span := tracing.StartServer(tracing.Extract(ctx, header), "grpc "+info.FullMethod)
+		if span == nil {
+			return handler(ctx, req)
+		}
+		defer span.End()
+		span.SetAttribute("rpc.method", info.FullMethod)
+		resp, err := handler(ctx, req)
+		span.SetAttribute("rpc.grpc.status_code", int(status.Code(err)))
+		if err != nil {
+			span.SetError(err)
+		}
+		return resp, err
+	}
+}
+
+// controlService implements v1.ControlServer by manager
+type controlService struct {
+	manager Manager
+}
+
+func (c *controlService) GetConditions(ctx context.Context, req *v1.GetConditionsRequest) (*v1.GetConditionsResponse, error) {
+	conditions := c.manager.GetConditions()
+	resp := &v1.GetConditionsResponse{
+		Mode:         conditions.Mode,
+		Measurements: conditions.Measurements(),
+		Taints:       sortedKeys(conditions.Taints.Taints),
+		Problems:     sortedKeys(conditions.Taints.Problems),
+	}
+	for _, phase := range conditions.Phases {
+		since, err := ptypes.TimestampProto(phase.Since)
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		resp.Phases = append(resp.Phases, &v1.PhaseStatus{
+			Condition: phase.Condition,
+			TaintKey:  phase.TaintKey,
+			Phase:     string(phase.Phase),
+			Since:     since,
+		})
+	}
+	return resp, nil
+}
+
+func (c *controlService) ListCandidates(ctx context.Context, req *v1.ListCandidatesRequest) (*v1.ListCandidatesResponse, error) {
+	candidates, err := c.manager.GetEvictionCandidates(req.Type)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	resp := &v1.ListCandidatesResponse{}
+	for _, candidate := range candidates {
+		resp.Candidates = append(resp.Candidates, &v1.Candidate{
+			Namespace:   candidate.Pod.Namespace,
+			Name:        candidate.Pod.Name,
+			Uid:         candidate.Pod.UID,
+			Usage:       candidate.Usage,
+			Score:       candidate.Score,
+			Label:       candidate.Label,
+			LabelReason: candidate.LabelReason,
+			Returns:     candidate.Returns,
+		})
+	}
+	return resp, nil
+}
+
+func (c *controlService) Pause(ctx context.Context, req *v1.PauseRequest) (*v1.ActionResponse, error) {
+	var until time.Time
+	if req.Until != nil {
+		t, err := ptypes.Timestamp(req.Until)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		if !t.After(time.Now()) {
+			return nil, status.Errorf(codes.InvalidArgument, "pause until %s is not in the future", t.Format(time.RFC3339))
+		}
+		until = t
+	}
+	log.Warnf("Pause is requested by control api %s", remoteAddr(ctx))
+	result, err := c.manager.Pause(until)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return actionResponse(result), nil
+}
+
+func (c *controlService) Resume(ctx context.Context, req *v1.ResumeRequest) (*v1.ActionResponse, error) {
+	log.Warnf("Resume is requested by control api %s", remoteAddr(ctx))
+	result, err := c.manager.Resume()
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return actionResponse(result), nil
+}
+
+func (c *controlService) TriggerEvaluation(ctx context.Context, req *v1.TriggerEvaluationRequest) (*v1.ActionResponse, error) {
+	return actionResponse(c.manager.TriggerEvaluation()), nil
+}
+
+func actionResponse(result *evictionmanager.ControlResult) *v1.ActionResponse {
+	return &v1.ActionResponse{Action: result.Action, Message: result.Message}
+}
+
+// remoteAddr returns the address of the caller, empty on unix socket
+func remoteAddr(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		return p.Addr.String()
+	}
+	return ""
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key, ok := range set {
+		if ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,123 @@
+package control
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"eviction-agent/pkg/condition"
+	"eviction-agent/pkg/control/v1"
+	"eviction-agent/pkg/evictionmanager"
+	"eviction-agent/pkg/types"
+)
+
+const testToken = "secret"
+
+// fakeManager records the pause requested, and has candidates of CPUBusy
+type fakeManager struct {
+	paused time.Time
+}
+
+func (m *fakeManager) GetConditions() *evictionmanager.Conditions {
+	return &evictionmanager.Conditions{Mode: "full"}
+}
+
+func (m *fakeManager) GetEvictionCandidates(evictType string) ([]condition.Candidate, error) {
+	if evictType != types.CPUBusy {
+		return nil, fmt.Errorf("unknown type %q", evictType)
+	}
+	return []condition.Candidate{{Pod: types.PodInfo{Namespace: "default", Name: "busy"}, Score: 1}}, nil
+}
+
+func (m *fakeManager) Pause(until time.Time) (*evictionmanager.ControlResult, error) {
+	m.paused = until
+	return &evictionmanager.ControlResult{Action: "Pause"}, nil
+}
+
+func (m *fakeManager) Resume() (*evictionmanager.ControlResult, error) {
+	return &evictionmanager.ControlResult{Action: "Resume"}, nil
+}
+
+func (m *fakeManager) TriggerEvaluation() *evictionmanager.ControlResult {
+	return &evictionmanager.ControlResult{Action: "TriggerEvaluation"}
+}
+
+// startTestServer serves manager on a unix socket, it's stopped when t ends
+func startTestServer(t *testing.T, manager Manager) v1.ControlClient {
+	socket := filepath.Join(t.TempDir(), "control.sock")
+	s := NewServer("unix://"+socket, testToken, nil, manager)
+	s.Start()
+	t.Cleanup(s.grpc.Stop)
+
+	conn, err := grpc.Dial(socket, grpc.WithInsecure(), grpc.WithBlock(), grpc.WithTimeout(5*time.Second),
+		grpc.WithDialer(func(addr string, timeout time.Duration) (net.Conn, error) {
+			return net.DialTimeout("unix", addr, timeout)
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return v1.NewControlClient(conn)
+}
+
+func withToken(token string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+func TestCallsRequireToken(t *testing.T) {
+	client := startTestServer(t, &fakeManager{})
+	for name, ctx := range map[string]context.Context{
+		"no token":    context.Background(),
+		"wrong token": withToken("wrong"),
+	} {
+		_, err := client.TriggerEvaluation(ctx, &v1.TriggerEvaluationRequest{})
+		if status.Code(err) != codes.Unauthenticated {
+			t.Errorf("call with %s returns %v, want %s", name, err, codes.Unauthenticated)
+		}
+	}
+	if _, err := client.TriggerEvaluation(withToken(testToken), &v1.TriggerEvaluationRequest{}); err != nil {
+		t.Errorf("call with token error: %v", err)
+	}
+}
+
+func TestPauseUntil(t *testing.T) {
+	manager := &fakeManager{}
+	client := startTestServer(t, manager)
+	until := time.Now().Add(time.Hour).Truncate(time.Second)
+	timestamp, _ := ptypes.TimestampProto(until)
+	if _, err := client.Pause(withToken(testToken), &v1.PauseRequest{Until: timestamp}); err != nil {
+		t.Fatal(err)
+	}
+	if !manager.paused.Equal(until) {
+		t.Errorf("paused until %v, want %v", manager.paused, until)
+	}
+
+	past, _ := ptypes.TimestampProto(time.Now().Add(-time.Hour))
+	if _, err := client.Pause(withToken(testToken), &v1.PauseRequest{Until: past}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("pause until the past returns %v, want %s", err, codes.InvalidArgument)
+	}
+}
+
+func TestListCandidates(t *testing.T) {
+	client := startTestServer(t, &fakeManager{})
+	resp, err := client.ListCandidates(withToken(testToken), &v1.ListCandidatesRequest{Type: types.CPUBusy})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Candidates) != 1 || resp.Candidates[0].Namespace != "default" || resp.Candidates[0].Name != "busy" {
+		t.Errorf("candidates are %+v, want default/busy", resp.Candidates)
+	}
+	_, err = client.ListCandidates(withToken(testToken), &v1.ListCandidatesRequest{Type: "Unknown"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("candidates of unknown type returns %v, want %s", err, codes.InvalidArgument)
+	}
+}
@@ -0,0 +1,793 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: pkg/control/v1/control.proto
+
+package v1 // import "eviction-agent/pkg/control/v1"
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+import timestamp "github.com/golang/protobuf/ptypes/timestamp"
+
+import (
+	context "golang.org/x/net/context"
+	grpc "google.golang.org/grpc"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
+
+type GetConditionsRequest struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetConditionsRequest) Reset()         { *m = GetConditionsRequest{} }
+func (m *GetConditionsRequest) String() string { return proto.CompactTextString(m) }
+func (*GetConditionsRequest) ProtoMessage()    {}
+func (*GetConditionsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_control_2b89c6e8593df7c1, []int{0}
+}
+func (m *GetConditionsRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetConditionsRequest.Unmarshal(m, b)
+}
+func (m *GetConditionsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetConditionsRequest.Marshal(b, m, deterministic)
+}
+func (dst *GetConditionsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetConditionsRequest.Merge(dst, src)
+}
+func (m *GetConditionsRequest) XXX_Size() int {
+	return xxx_messageInfo_GetConditionsRequest.Size(m)
+}
+func (m *GetConditionsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetConditionsRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetConditionsRequest proto.InternalMessageInfo
+
+type GetConditionsResponse struct {
+	// mode is full, taint-only or monitor-only
+	Mode string `protobuf:"bytes,1,opt,name=mode,proto3" json:"mode,omitempty"`
+	// measurements are the measured values of node, e.g. cpuUsage
+	Measurements map[string]float64 `protobuf:"bytes,2,rep,name=measurements,proto3" json:"measurements,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"fixed64,2,opt,name=value,proto3"`
+	// taints are keys of all taints on node
+	Taints []string `protobuf:"bytes,3,rep,name=taints,proto3" json:"taints,omitempty"`
+	// problems are node conditions of status True, e.g. KernelDeadlock
+	Problems             []string       `protobuf:"bytes,4,rep,name=problems,proto3" json:"problems,omitempty"`
+	Phases               []*PhaseStatus `protobuf:"bytes,5,rep,name=phases,proto3" json:"phases,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}       `json:"-"`
+	XXX_unrecognized     []byte         `json:"-"`
+	XXX_sizecache        int32          `json:"-"`
+}
+
+func (m *GetConditionsResponse) Reset()         { *m = GetConditionsResponse{} }
+func (m *GetConditionsResponse) String() string { return proto.CompactTextString(m) }
+func (*GetConditionsResponse) ProtoMessage()    {}
+func (*GetConditionsResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_control_2b89c6e8593df7c1, []int{1}
+}
+func (m *GetConditionsResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetConditionsResponse.Unmarshal(m, b)
+}
+func (m *GetConditionsResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetConditionsResponse.Marshal(b, m, deterministic)
+}
+func (dst *GetConditionsResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetConditionsResponse.Merge(dst, src)
+}
+func (m *GetConditionsResponse) XXX_Size() int {
+	return xxx_messageInfo_GetConditionsResponse.Size(m)
+}
+func (m *GetConditionsResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetConditionsResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetConditionsResponse proto.InternalMessageInfo
+
+func (m *GetConditionsResponse) GetMode() string {
+	if m != nil {
+		return m.Mode
+	}
+	return ""
+}
+
+func (m *GetConditionsResponse) GetMeasurements() map[string]float64 {
+	if m != nil {
+		return m.Measurements
+	}
+	return nil
+}
+
+func (m *GetConditionsResponse) GetTaints() []string {
+	if m != nil {
+		return m.Taints
+	}
+	return nil
+}
+
+func (m *GetConditionsResponse) GetProblems() []string {
+	if m != nil {
+		return m.Problems
+	}
+	return nil
+}
+
+func (m *GetConditionsResponse) GetPhases() []*PhaseStatus {
+	if m != nil {
+		return m.Phases
+	}
+	return nil
+}
+
+// PhaseStatus is the phase of a condition
+type PhaseStatus struct {
+	Condition string `protobuf:"bytes,1,opt,name=condition,proto3" json:"condition,omitempty"`
+	TaintKey  string `protobuf:"bytes,2,opt,name=taint_key,json=taintKey,proto3" json:"taint_key,omitempty"`
+	// phase is Healthy, SoftPressure, Tainted, Evicting or Recovering
+	Phase                string               `protobuf:"bytes,3,opt,name=phase,proto3" json:"phase,omitempty"`
+	Since                *timestamp.Timestamp `protobuf:"bytes,4,opt,name=since,proto3" json:"since,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
+}
+
+func (m *PhaseStatus) Reset()         { *m = PhaseStatus{} }
+func (m *PhaseStatus) String() string { return proto.CompactTextString(m) }
+func (*PhaseStatus) ProtoMessage()    {}
+func (*PhaseStatus) Descriptor() ([]byte, []int) {
+	return fileDescriptor_control_2b89c6e8593df7c1, []int{2}
+}
+func (m *PhaseStatus) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_PhaseStatus.Unmarshal(m, b)
+}
+func (m *PhaseStatus) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_PhaseStatus.Marshal(b, m, deterministic)
+}
+func (dst *PhaseStatus) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PhaseStatus.Merge(dst, src)
+}
+func (m *PhaseStatus) XXX_Size() int {
+	return xxx_messageInfo_PhaseStatus.Size(m)
+}
+func (m *PhaseStatus) XXX_DiscardUnknown() {
+	xxx_messageInfo_PhaseStatus.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_PhaseStatus proto.InternalMessageInfo
+
+func (m *PhaseStatus) GetCondition() string {
+	if m != nil {
+		return m.Condition
+	}
+	return ""
+}
+
+func (m *PhaseStatus) GetTaintKey() string {
+	if m != nil {
+		return m.TaintKey
+	}
+	return ""
+}
+
+func (m *PhaseStatus) GetPhase() string {
+	if m != nil {
+		return m.Phase
+	}
+	return ""
+}
+
+func (m *PhaseStatus) GetSince() *timestamp.Timestamp {
+	if m != nil {
+		return m.Since
+	}
+	return nil
+}
+
+type ListCandidatesRequest struct {
+	// type is the condition type, e.g. CPUBusy
+	Type                 string   `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListCandidatesRequest) Reset()         { *m = ListCandidatesRequest{} }
+func (m *ListCandidatesRequest) String() string { return proto.CompactTextString(m) }
+func (*ListCandidatesRequest) ProtoMessage()    {}
+func (*ListCandidatesRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_control_2b89c6e8593df7c1, []int{3}
+}
+func (m *ListCandidatesRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListCandidatesRequest.Unmarshal(m, b)
+}
+func (m *ListCandidatesRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListCandidatesRequest.Marshal(b, m, deterministic)
+}
+func (dst *ListCandidatesRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListCandidatesRequest.Merge(dst, src)
+}
+func (m *ListCandidatesRequest) XXX_Size() int {
+	return xxx_messageInfo_ListCandidatesRequest.Size(m)
+}
+func (m *ListCandidatesRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListCandidatesRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListCandidatesRequest proto.InternalMessageInfo
+
+func (m *ListCandidatesRequest) GetType() string {
+	if m != nil {
+		return m.Type
+	}
+	return ""
+}
+
+type ListCandidatesResponse struct {
+	// candidates are ranked, the first one is chosen
+	Candidates           []*Candidate `protobuf:"bytes,1,rep,name=candidates,proto3" json:"candidates,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}     `json:"-"`
+	XXX_unrecognized     []byte       `json:"-"`
+	XXX_sizecache        int32        `json:"-"`
+}
+
+func (m *ListCandidatesResponse) Reset()         { *m = ListCandidatesResponse{} }
+func (m *ListCandidatesResponse) String() string { return proto.CompactTextString(m) }
+func (*ListCandidatesResponse) ProtoMessage()    {}
+func (*ListCandidatesResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_control_2b89c6e8593df7c1, []int{4}
+}
+func (m *ListCandidatesResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListCandidatesResponse.Unmarshal(m, b)
+}
+func (m *ListCandidatesResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListCandidatesResponse.Marshal(b, m, deterministic)
+}
+func (dst *ListCandidatesResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListCandidatesResponse.Merge(dst, src)
+}
+func (m *ListCandidatesResponse) XXX_Size() int {
+	return xxx_messageInfo_ListCandidatesResponse.Size(m)
+}
+func (m *ListCandidatesResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListCandidatesResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListCandidatesResponse proto.InternalMessageInfo
+
+func (m *ListCandidatesResponse) GetCandidates() []*Candidate {
+	if m != nil {
+		return m.Candidates
+	}
+	return nil
+}
+
+// Candidate is a pod ranked for eviction of a condition
+type Candidate struct {
+	Namespace string `protobuf:"bytes,1,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	Name      string `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Uid       string `protobuf:"bytes,3,opt,name=uid,proto3" json:"uid,omitempty"`
+	// usage is the resource usage of the condition, e.g. iops for DiskIOBusy
+	Usage float64 `protobuf:"fixed64,4,opt,name=usage,proto3" json:"usage,omitempty"`
+	// score is usage weighted by priority, the highest score is chosen
+	Score       float64 `protobuf:"fixed64,5,opt,name=score,proto3" json:"score,omitempty"`
+	Label       string  `protobuf:"bytes,6,opt,name=label,proto3" json:"label,omitempty"`
+	LabelReason string  `protobuf:"bytes,7,opt,name=label_reason,json=labelReason,proto3" json:"label_reason,omitempty"`
+	// returns is true if pod is scheduled back once evicted
+	Returns              bool     `protobuf:"varint,8,opt,name=returns,proto3" json:"returns,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Candidate) Reset()         { *m = Candidate{} }
+func (m *Candidate) String() string { return proto.CompactTextString(m) }
+func (*Candidate) ProtoMessage()    {}
+func (*Candidate) Descriptor() ([]byte, []int) {
+	return fileDescriptor_control_2b89c6e8593df7c1, []int{5}
+}
+func (m *Candidate) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Candidate.Unmarshal(m, b)
+}
+func (m *Candidate) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Candidate.Marshal(b, m, deterministic)
+}
+func (dst *Candidate) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Candidate.Merge(dst, src)
+}
+func (m *Candidate) XXX_Size() int {
+	return xxx_messageInfo_Candidate.Size(m)
+}
+func (m *Candidate) XXX_DiscardUnknown() {
+	xxx_messageInfo_Candidate.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Candidate proto.InternalMessageInfo
+
+func (m *Candidate) GetNamespace() string {
+	if m != nil {
+		return m.Namespace
+	}
+	return ""
+}
+
+func (m *Candidate) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *Candidate) GetUid() string {
+	if m != nil {
+		return m.Uid
+	}
+	return ""
+}
+
+func (m *Candidate) GetUsage() float64 {
+	if m != nil {
+		return m.Usage
+	}
+	return 0
+}
+
+func (m *Candidate) GetScore() float64 {
+	if m != nil {
+		return m.Score
+	}
+	return 0
+}
+
+func (m *Candidate) GetLabel() string {
+	if m != nil {
+		return m.Label
+	}
+	return ""
+}
+
+func (m *Candidate) GetLabelReason() string {
+	if m != nil {
+		return m.LabelReason
+	}
+	return ""
+}
+
+func (m *Candidate) GetReturns() bool {
+	if m != nil {
+		return m.Returns
+	}
+	return false
+}
+
+type PauseRequest struct {
+	// until is the end of the pause, actions are paused until Resume if unset
+	Until                *timestamp.Timestamp `protobuf:"bytes,1,opt,name=until,proto3" json:"until,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
+}
+
+func (m *PauseRequest) Reset()         { *m = PauseRequest{} }
+func (m *PauseRequest) String() string { return proto.CompactTextString(m) }
+func (*PauseRequest) ProtoMessage()    {}
+func (*PauseRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_control_2b89c6e8593df7c1, []int{6}
+}
+func (m *PauseRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_PauseRequest.Unmarshal(m, b)
+}
+func (m *PauseRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_PauseRequest.Marshal(b, m, deterministic)
+}
+func (dst *PauseRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PauseRequest.Merge(dst, src)
+}
+func (m *PauseRequest) XXX_Size() int {
+	return xxx_messageInfo_PauseRequest.Size(m)
+}
+func (m *PauseRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_PauseRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_PauseRequest proto.InternalMessageInfo
+
+func (m *PauseRequest) GetUntil() *timestamp.Timestamp {
+	if m != nil {
+		return m.Until
+	}
+	return nil
+}
+
+type ResumeRequest struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ResumeRequest) Reset()         { *m = ResumeRequest{} }
+func (m *ResumeRequest) String() string { return proto.CompactTextString(m) }
+func (*ResumeRequest) ProtoMessage()    {}
+func (*ResumeRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_control_2b89c6e8593df7c1, []int{7}
+}
+func (m *ResumeRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ResumeRequest.Unmarshal(m, b)
+}
+func (m *ResumeRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ResumeRequest.Marshal(b, m, deterministic)
+}
+func (dst *ResumeRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ResumeRequest.Merge(dst, src)
+}
+func (m *ResumeRequest) XXX_Size() int {
+	return xxx_messageInfo_ResumeRequest.Size(m)
+}
+func (m *ResumeRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ResumeRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ResumeRequest proto.InternalMessageInfo
+
+type TriggerEvaluationRequest struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *TriggerEvaluationRequest) Reset()         { *m = TriggerEvaluationRequest{} }
+func (m *TriggerEvaluationRequest) String() string { return proto.CompactTextString(m) }
+func (*TriggerEvaluationRequest) ProtoMessage()    {}
+func (*TriggerEvaluationRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_control_2b89c6e8593df7c1, []int{8}
+}
+func (m *TriggerEvaluationRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_TriggerEvaluationRequest.Unmarshal(m, b)
+}
+func (m *TriggerEvaluationRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_TriggerEvaluationRequest.Marshal(b, m, deterministic)
+}
+func (dst *TriggerEvaluationRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TriggerEvaluationRequest.Merge(dst, src)
+}
+func (m *TriggerEvaluationRequest) XXX_Size() int {
+	return xxx_messageInfo_TriggerEvaluationRequest.Size(m)
+}
+func (m *TriggerEvaluationRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_TriggerEvaluationRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TriggerEvaluationRequest proto.InternalMessageInfo
+
+// ActionResponse is the result of an action
+type ActionResponse struct {
+	Action               string   `protobuf:"bytes,1,opt,name=action,proto3" json:"action,omitempty"`
+	Message              string   `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ActionResponse) Reset()         { *m = ActionResponse{} }
+func (m *ActionResponse) String() string { return proto.CompactTextString(m) }
+func (*ActionResponse) ProtoMessage()    {}
+func (*ActionResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_control_2b89c6e8593df7c1, []int{9}
+}
+func (m *ActionResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ActionResponse.Unmarshal(m, b)
+}
+func (m *ActionResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ActionResponse.Marshal(b, m, deterministic)
+}
+func (dst *ActionResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ActionResponse.Merge(dst, src)
+}
+func (m *ActionResponse) XXX_Size() int {
+	return xxx_messageInfo_ActionResponse.Size(m)
+}
+func (m *ActionResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ActionResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ActionResponse proto.InternalMessageInfo
+
+func (m *ActionResponse) GetAction() string {
+	if m != nil {
+		return m.Action
+	}
+	return ""
+}
+
+func (m *ActionResponse) GetMessage() string {
+	if m != nil {
+		return m.Message
+	}
+	return ""
+}
+
+func init() {
+	proto.RegisterType((*GetConditionsRequest)(nil), "evictionagent.control.v1.GetConditionsRequest")
+	proto.RegisterType((*GetConditionsResponse)(nil), "evictionagent.control.v1.GetConditionsResponse")
+	proto.RegisterMapType((map[string]float64)(nil), "evictionagent.control.v1.GetConditionsResponse.MeasurementsEntry")
+	proto.RegisterType((*PhaseStatus)(nil), "evictionagent.control.v1.PhaseStatus")
+	proto.RegisterType((*ListCandidatesRequest)(nil), "evictionagent.control.v1.ListCandidatesRequest")
+	proto.RegisterType((*ListCandidatesResponse)(nil), "evictionagent.control.v1.ListCandidatesResponse")
+	proto.RegisterType((*Candidate)(nil), "evictionagent.control.v1.Candidate")
+	proto.RegisterType((*PauseRequest)(nil), "evictionagent.control.v1.PauseRequest")
+	proto.RegisterType((*ResumeRequest)(nil), "evictionagent.control.v1.ResumeRequest")
+	proto.RegisterType((*TriggerEvaluationRequest)(nil), "evictionagent.control.v1.TriggerEvaluationRequest")
+	proto.RegisterType((*ActionResponse)(nil), "evictionagent.control.v1.ActionResponse")
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// ControlClient is the client API for Control service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type ControlClient interface {
+	// GetConditions returns node conditions, taints and phases of the latest cycle
+	GetConditions(ctx context.Context, in *GetConditionsRequest, opts ...grpc.CallOption) (*GetConditionsResponse, error)
+	// ListCandidates returns the ranked candidates of a condition type
+	ListCandidates(ctx context.Context, in *ListCandidatesRequest, opts ...grpc.CallOption) (*ListCandidatesResponse, error)
+	// Pause pauses actions by the paused annotation of node
+	Pause(ctx context.Context, in *PauseRequest, opts ...grpc.CallOption) (*ActionResponse, error)
+	// Resume removes the paused annotation of node
+	Resume(ctx context.Context, in *ResumeRequest, opts ...grpc.CallOption) (*ActionResponse, error)
+	// TriggerEvaluation evaluates conditions now instead of at the next tick
+	TriggerEvaluation(ctx context.Context, in *TriggerEvaluationRequest, opts ...grpc.CallOption) (*ActionResponse, error)
+}
+
+type controlClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewControlClient(cc *grpc.ClientConn) ControlClient {
+	return &controlClient{cc}
+}
+
+func (c *controlClient) GetConditions(ctx context.Context, in *GetConditionsRequest, opts ...grpc.CallOption) (*GetConditionsResponse, error) {
+	out := new(GetConditionsResponse)
+	err := c.cc.Invoke(ctx, "/evictionagent.control.v1.Control/GetConditions", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controlClient) ListCandidates(ctx context.Context, in *ListCandidatesRequest, opts ...grpc.CallOption) (*ListCandidatesResponse, error) {
+	out := new(ListCandidatesResponse)
+	err := c.cc.Invoke(ctx, "/evictionagent.control.v1.Control/ListCandidates", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controlClient) Pause(ctx context.Context, in *PauseRequest, opts ...grpc.CallOption) (*ActionResponse, error) {
+	out := new(ActionResponse)
+	err := c.cc.Invoke(ctx, "/evictionagent.control.v1.Control/Pause", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controlClient) Resume(ctx context.Context, in *ResumeRequest, opts ...grpc.CallOption) (*ActionResponse, error) {
+	out := new(ActionResponse)
+	err := c.cc.Invoke(ctx, "/evictionagent.control.v1.Control/Resume", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controlClient) TriggerEvaluation(ctx context.Context, in *TriggerEvaluationRequest, opts ...grpc.CallOption) (*ActionResponse, error) {
+	out := new(ActionResponse)
+	err := c.cc.Invoke(ctx, "/evictionagent.control.v1.Control/TriggerEvaluation", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ControlServer is the server API for Control service.
+type ControlServer interface {
+	// GetConditions returns node conditions, taints and phases of the latest cycle
+	GetConditions(context.Context, *GetConditionsRequest) (*GetConditionsResponse, error)
+	// ListCandidates returns the ranked candidates of a condition type
+	ListCandidates(context.Context, *ListCandidatesRequest) (*ListCandidatesResponse, error)
+	// Pause pauses actions by the paused annotation of node
+	Pause(context.Context, *PauseRequest) (*ActionResponse, error)
+	// Resume removes the paused annotation of node
+	Resume(context.Context, *ResumeRequest) (*ActionResponse, error)
+	// TriggerEvaluation evaluates conditions now instead of at the next tick
+	TriggerEvaluation(context.Context, *TriggerEvaluationRequest) (*ActionResponse, error)
+}
+
+func RegisterControlServer(s *grpc.Server, srv ControlServer) {
+	s.RegisterService(&_Control_serviceDesc, srv)
+}
+
+func _Control_GetConditions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetConditionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControlServer).GetConditions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/evictionagent.control.v1.Control/GetConditions",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControlServer).GetConditions(ctx, req.(*GetConditionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Control_ListCandidates_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListCandidatesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControlServer).ListCandidates(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/evictionagent.control.v1.Control/ListCandidates",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControlServer).ListCandidates(ctx, req.(*ListCandidatesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Control_Pause_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PauseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControlServer).Pause(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/evictionagent.control.v1.Control/Pause",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControlServer).Pause(ctx, req.(*PauseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Control_Resume_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ResumeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControlServer).Resume(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/evictionagent.control.v1.Control/Resume",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControlServer).Resume(ctx, req.(*ResumeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Control_TriggerEvaluation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TriggerEvaluationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControlServer).TriggerEvaluation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/evictionagent.control.v1.Control/TriggerEvaluation",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControlServer).TriggerEvaluation(ctx, req.(*TriggerEvaluationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Control_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "evictionagent.control.v1.Control",
+	HandlerType: (*ControlServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetConditions",
+			Handler:    _Control_GetConditions_Handler,
+		},
+		{
+			MethodName: "ListCandidates",
+			Handler:    _Control_ListCandidates_Handler,
+		},
+		{
+			MethodName: "Pause",
+			Handler:    _Control_Pause_Handler,
+		},
+		{
+			MethodName: "Resume",
+			Handler:    _Control_Resume_Handler,
+		},
+		{
+			MethodName: "TriggerEvaluation",
+			Handler:    _Control_TriggerEvaluation_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "pkg/control/v1/control.proto",
+}
+
+func init() {
+	proto.RegisterFile("pkg/control/v1/control.proto", fileDescriptor_control_2b89c6e8593df7c1)
+}
+
+var fileDescriptor_control_2b89c6e8593df7c1 = []byte{
+	// 672 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x55, 0xdd, 0x6e, 0xd3, 0x4c,
+	0x10, 0x95, 0xf3, 0xd7, 0x64, 0xd2, 0xf6, 0xfb, 0xba, 0x6a, 0xa3, 0x95, 0xa9, 0x84, 0x31, 0x02,
+	0x2c, 0x21, 0x9c, 0x36, 0xdc, 0x20, 0x10, 0x82, 0x36, 0xaa, 0xb8, 0x00, 0xa4, 0xca, 0xf4, 0x06,
+	0x10, 0xaa, 0x36, 0xce, 0x60, 0xac, 0xfa, 0xaf, 0xde, 0x75, 0xa4, 0xbe, 0x05, 0xf7, 0xbc, 0x0f,
+	0x97, 0x3c, 0x13, 0xda, 0x5d, 0x3b, 0x4d, 0xda, 0xba, 0xa4, 0x77, 0x73, 0x66, 0x66, 0xcf, 0x8e,
+	0xcf, 0x99, 0x4d, 0x60, 0x37, 0x3b, 0x0b, 0x86, 0x7e, 0x9a, 0x88, 0x3c, 0x8d, 0x86, 0xb3, 0xfd,
+	0x2a, 0x74, 0xb3, 0x3c, 0x15, 0x29, 0xa1, 0x38, 0x0b, 0x7d, 0x11, 0xa6, 0x09, 0x0b, 0x30, 0x11,
+	0x6e, 0x55, 0x9c, 0xed, 0x9b, 0xf7, 0x83, 0x34, 0x0d, 0x22, 0x1c, 0xaa, 0xbe, 0x49, 0xf1, 0x7d,
+	0x28, 0xc2, 0x18, 0xb9, 0x60, 0x71, 0xa6, 0x8f, 0xda, 0x03, 0xd8, 0x7e, 0x87, 0x62, 0x9c, 0x26,
+	0xd3, 0x50, 0x12, 0x70, 0x0f, 0xcf, 0x0b, 0xe4, 0xc2, 0xfe, 0xdd, 0x80, 0x9d, 0x2b, 0x05, 0x9e,
+	0xa5, 0x09, 0x47, 0x42, 0xa0, 0x15, 0xa7, 0x53, 0xa4, 0x86, 0x65, 0x38, 0x3d, 0x4f, 0xc5, 0x04,
+	0x61, 0x3d, 0x46, 0xc6, 0x8b, 0x1c, 0x63, 0x4c, 0x04, 0xa7, 0x0d, 0xab, 0xe9, 0xf4, 0x47, 0x07,
+	0x6e, 0xdd, 0x5c, 0xee, 0x8d, 0xd4, 0xee, 0xc7, 0x05, 0x8e, 0xa3, 0x44, 0xe4, 0x17, 0xde, 0x12,
+	0x2d, 0x19, 0x40, 0x47, 0xb0, 0x50, 0x5e, 0xd0, 0xb4, 0x9a, 0x4e, 0xcf, 0x2b, 0x11, 0x31, 0xa1,
+	0x9b, 0xe5, 0xe9, 0x24, 0xc2, 0x98, 0xd3, 0x96, 0xaa, 0xcc, 0x31, 0x79, 0x0d, 0x9d, 0xec, 0x07,
+	0xe3, 0xc8, 0x69, 0x5b, 0x0d, 0xf5, 0xa8, 0x7e, 0xa8, 0x63, 0xd9, 0xf7, 0x49, 0x30, 0x51, 0x70,
+	0xaf, 0x3c, 0x64, 0xbe, 0x81, 0xad, 0x6b, 0x53, 0x91, 0xff, 0xa1, 0x79, 0x86, 0x17, 0xa5, 0x02,
+	0x32, 0x24, 0xdb, 0xd0, 0x9e, 0xb1, 0xa8, 0x40, 0xda, 0xb0, 0x0c, 0xc7, 0xf0, 0x34, 0x78, 0xd9,
+	0x78, 0x61, 0xd8, 0x3f, 0x0d, 0xe8, 0x2f, 0x10, 0x93, 0x5d, 0xe8, 0xf9, 0xd5, 0x97, 0x97, 0x0c,
+	0x97, 0x09, 0x72, 0x0f, 0x7a, 0xea, 0x9b, 0x4e, 0x25, 0x7f, 0x43, 0x55, 0xbb, 0x2a, 0xf1, 0x5e,
+	0x5f, 0xa2, 0xa6, 0xa2, 0x4d, 0x55, 0xd0, 0x80, 0xec, 0x41, 0x9b, 0x87, 0x89, 0x8f, 0xb4, 0x65,
+	0x19, 0x4e, 0x7f, 0x64, 0xba, 0xda, 0x72, 0xb7, 0xb2, 0xdc, 0x3d, 0xa9, 0x2c, 0xf7, 0x74, 0xa3,
+	0xfd, 0x14, 0x76, 0x3e, 0x84, 0x5c, 0x8c, 0x59, 0x32, 0x0d, 0xa7, 0x4c, 0x60, 0x65, 0xba, 0xb4,
+	0x56, 0x5c, 0x64, 0x73, 0x6b, 0x65, 0x6c, 0x7f, 0x83, 0xc1, 0xd5, 0xe6, 0x72, 0x11, 0xc6, 0x00,
+	0xfe, 0x3c, 0x4b, 0x0d, 0xa5, 0xee, 0xc3, 0x7a, 0x75, 0xe7, 0x0c, 0xde, 0xc2, 0x31, 0xfb, 0x8f,
+	0x01, 0xbd, 0x79, 0x45, 0x8a, 0x93, 0xb0, 0x18, 0x79, 0xc6, 0xfc, 0x6a, 0x8a, 0xcb, 0x84, 0x1c,
+	0x4f, 0x82, 0x52, 0x17, 0x15, 0x4b, 0x2b, 0x8a, 0x70, 0x5a, 0x2a, 0x22, 0x43, 0xa9, 0x52, 0xc1,
+	0x59, 0xa0, 0xf5, 0x30, 0x3c, 0x0d, 0x64, 0x96, 0xfb, 0x69, 0x8e, 0xb4, 0xad, 0xb3, 0x0a, 0xc8,
+	0x6c, 0xc4, 0x26, 0x18, 0xd1, 0x8e, 0x56, 0x54, 0x01, 0xf2, 0x00, 0xd6, 0x55, 0x70, 0x9a, 0x23,
+	0xe3, 0x69, 0x42, 0xd7, 0x54, 0xb1, 0xaf, 0x72, 0x9e, 0x4a, 0x11, 0x0a, 0x6b, 0x39, 0x8a, 0x22,
+	0x4f, 0x38, 0xed, 0x5a, 0x86, 0xd3, 0xf5, 0x2a, 0x68, 0xbf, 0x85, 0xf5, 0x63, 0x56, 0x70, 0xac,
+	0x34, 0xdd, 0x83, 0x76, 0x91, 0x88, 0x30, 0xa2, 0xc6, 0xbf, 0xed, 0x51, 0x8d, 0xf6, 0x7f, 0xb0,
+	0xe1, 0x21, 0x2f, 0xe2, 0x8a, 0xc2, 0x36, 0x81, 0x9e, 0xe4, 0x61, 0x10, 0x60, 0x7e, 0x24, 0xf7,
+	0x8a, 0x49, 0x79, 0xab, 0xda, 0x21, 0x6c, 0x1e, 0xf8, 0x3a, 0x51, 0xda, 0x32, 0x80, 0x0e, 0xf3,
+	0x17, 0xb6, 0xab, 0x44, 0x72, 0xe4, 0x18, 0xb9, 0x52, 0x46, 0x0b, 0x58, 0xc1, 0xd1, 0xaf, 0x16,
+	0xac, 0x8d, 0xb5, 0x51, 0x24, 0x83, 0x8d, 0xa5, 0xb7, 0x49, 0xdc, 0x95, 0x1f, 0xb1, 0x1a, 0xc8,
+	0x1c, 0xde, 0xf1, 0xd1, 0x13, 0x0e, 0x9b, 0xcb, 0x0b, 0x46, 0x6e, 0xa1, 0xb8, 0x71, 0x6f, 0xcd,
+	0xbd, 0xd5, 0x0f, 0x94, 0x97, 0x7e, 0x86, 0xb6, 0x72, 0x89, 0x3c, 0xbe, 0xe5, 0xe7, 0x60, 0xc1,
+	0x46, 0xd3, 0xa9, 0xef, 0xbb, 0xa2, 0xff, 0x57, 0xe8, 0x68, 0xfb, 0xc8, 0x93, 0xfa, 0x33, 0x4b,
+	0x06, 0xdf, 0x81, 0xfc, 0x1c, 0xb6, 0xae, 0xad, 0x02, 0x19, 0xd5, 0x1f, 0xaf, 0xdb, 0x9b, 0xd5,
+	0xaf, 0x3c, 0xb4, 0xbf, 0x58, 0x55, 0xeb, 0x33, 0xd5, 0x3b, 0x5c, 0xfe, 0x2f, 0x7a, 0x35, 0xdb,
+	0x9f, 0x74, 0xd4, 0x36, 0x3f, 0xff, 0x3b, 0x00, 0x4d, 0x94, 0xbb, 0x54, 0xa7, 0x06, 0x00, 0x00,
+}
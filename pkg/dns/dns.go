@@ -0,0 +1,107 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/metrics"
+)
+
+var (
+	nameUnhealthy = metrics.NewGaugeVec("eviction_agent_dns_unhealthy",
+		"1 if resolving the name fails in consecutive probes of the failure threshold.", "name")
+	probeFailures = metrics.NewCounterVec("eviction_agent_dns_probe_failures_total",
+		"Number of probes of names failed or timed out.", "name")
+	probeDuration = metrics.NewGaugeVec("eviction_agent_dns_probe_seconds",
+		"Duration of the last probe of the name in seconds.", "name")
+)
+
+// Prober resolves names periodically through the resolver of node or a
+// server like the node-local DNS cache. A name is unhealthy once its probes
+// fail failureThreshold times in a row, so that a single lost packet doesn't
+// taint node.
+type Prober struct {
+	names    []string
+	period   time.Duration
+	timeout  time.Duration
+	resolver *net.Resolver
+	// failureThreshold is the consecutive failures of an unhealthy name
+	failureThreshold int
+	// failures are consecutive failures of names
+	failures map[string]int
+}
+
+// NewProber creates prober of names, server is host:port of the DNS server,
+// e.g. 169.254.20.10:53, the resolver of node is used if it's empty
+func NewProber(names []string, server string, period, timeout time.Duration, failureThreshold int) *Prober {
+	resolver := net.DefaultResolver
+	if server != "" {
+		resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, server)
+			},
+		}
+	}
+	return &Prober{
+		names:            names,
+		period:           period,
+		timeout:          timeout,
+		resolver:         resolver,
+		failureThreshold: failureThreshold,
+		failures:         make(map[string]int),
+	}
+}
+
+// Run probes names until ctx is done, report is called after each probe with
+// the failures of unhealthy names, empty if all of them are healthy
+func (p *Prober) Run(ctx context.Context, report func(failures []string)) {
+	for {
+		report(p.probe(ctx))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(p.period):
+		}
+	}
+}
+
+func (p *Prober) probe(ctx context.Context) []string {
+	var failures []string
+	for _, name := range p.names {
+		err := p.resolve(ctx, name)
+		if err == nil {
+			p.failures[name] = 0
+			nameUnhealthy.Set(0, name)
+			continue
+		}
+		probeFailures.Inc(name)
+		p.failures[name]++
+		log.Warnw("resolve name failed", "name", name, "failures", p.failures[name], "error", err.Error())
+		if p.failures[name] >= p.failureThreshold {
+			nameUnhealthy.Set(1, name)
+			failures = append(failures, fmt.Sprintf("resolve %s failed %d times: %v", name, p.failures[name], err))
+		}
+	}
+	return failures
+}
+
+// resolve looks up name in timeout
+func (p *Prober) resolve(ctx context.Context, name string) error {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+	start := time.Now()
+	addrs, err := p.resolver.LookupHost(ctx, name)
+	probeDuration.Set(time.Since(start).Seconds(), name)
+	if err != nil {
+		return err
+	}
+	if len(addrs) == 0 {
+		return fmt.Errorf("no address")
+	}
+	return nil
+}
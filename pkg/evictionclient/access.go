@@ -0,0 +1,51 @@
+package evictionclient
+
+import (
+	authorizationv1 "k8s.io/api/authorization/v1"
+)
+
+// Permission is an api verb on a resource in all namespaces, e.g. create
+// pods/eviction
+type Permission struct {
+	Verb        string
+	Group       string
+	Resource    string
+	Subresource string
+}
+
+func (p Permission) String() string {
+	resource := p.Resource
+	if p.Group != "" {
+		resource += "." + p.Group
+	}
+	if p.Subresource != "" {
+		resource += "/" + p.Subresource
+	}
+	return p.Verb + " " + resource
+}
+
+// CheckPermissions returns permissions denied to the agent by
+// SelfSubjectAccessReview
+func (c *evictionClient) CheckPermissions(permissions []Permission) ([]Permission, error) {
+	var denied []Permission
+	for _, permission := range permissions {
+		review := &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Verb:        permission.Verb,
+					Group:       permission.Group,
+					Resource:    permission.Resource,
+					Subresource: permission.Subresource,
+				},
+			},
+		}
+		result, err := c.client.AuthorizationV1().SelfSubjectAccessReviews().Create(review)
+		if err != nil {
+			return nil, unauthorizedError("review access of", permission.String(), err)
+		}
+		if !result.Status.Allowed {
+			denied = append(denied, permission)
+		}
+	}
+	return denied, nil
+}
@@ -0,0 +1,57 @@
+package evictionclient
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/types"
+)
+
+// GetDisruptionBudgets return the PodDisruptionBudgets of pods on current
+// node keyed by namespace/name, PDBs are listed in namespaces of pods. A pod
+// of several PDBs gets the one allowing the fewest disruptions, pods without
+// PDB are not returned.
+func (c *evictionClient) GetDisruptionBudgets() (map[string]types.DisruptionBudget, error) {
+	podList, err := c.listPods()
+	if err != nil {
+		log.Errorf("List pods on %s error %v", c.nodeName, err)
+		return nil, err
+	}
+	budgets := make(map[string]types.DisruptionBudget)
+	listed := make(map[string]bool)
+	for i := range podList {
+		namespace := podList[i].Namespace
+		if listed[namespace] {
+			continue
+		}
+		listed[namespace] = true
+		pdbList, err := c.client.PolicyV1beta1().PodDisruptionBudgets(namespace).List(metav1.ListOptions{})
+		if err != nil {
+			log.Errorf("List PodDisruptionBudgets of %s error %v", namespace, err)
+			return nil, err
+		}
+		for _, pdb := range pdbList.Items {
+			selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+			if err != nil || selector.Empty() {
+				// the eviction api ignores PDBs of invalid or empty selectors too
+				continue
+			}
+			budget := types.DisruptionBudget{
+				Name:    pdb.Namespace + "/" + pdb.Name,
+				Allowed: int(pdb.Status.PodDisruptionsAllowed),
+			}
+			for j := range podList {
+				pod := &podList[j]
+				if pod.Namespace != namespace || !selector.Matches(labels.Set(pod.Labels)) {
+					continue
+				}
+				key := pod.Namespace + "/" + pod.Name
+				if last, ok := budgets[key]; !ok || budget.Allowed < last.Allowed {
+					budgets[key] = budget
+				}
+			}
+		}
+	}
+	return budgets, nil
+}
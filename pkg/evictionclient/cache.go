@@ -0,0 +1,117 @@
+package evictionclient
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// cycleCacheMaxAge is the max age of cached node and pods. Cycles of stats,
+// taints and evictions overlap, so the cache may never be dropped by EndCycle.
+const cycleCacheMaxAge = 5 * time.Second
+
+// cycleCache shares the node and pods on node among calls between BeginCycle
+// and EndCycle, e.g. of one evaluation cycle. Cycles may overlap, the cache is
+// dropped when the last of them ends, and each of node and pods is got again
+// once it's older than cycleCacheMaxAge. Changes by this client invalidate it.
+type cycleCache struct {
+	lock     sync.Mutex
+	cycles   int
+	node     *v1.Node
+	nodeTime time.Time // when node is got
+	pods     []v1.Pod
+	podsTime time.Time // when pods are listed
+	listed   bool
+}
+
+// BeginCycle starts caching node and pods on node
+func (c *evictionClient) BeginCycle() {
+	c.cache.lock.Lock()
+	defer c.cache.lock.Unlock()
+	c.cache.cycles++
+}
+
+// EndCycle drops cached node and pods once no cycle is left
+func (c *evictionClient) EndCycle() {
+	c.cache.lock.Lock()
+	defer c.cache.lock.Unlock()
+	if c.cache.cycles > 0 {
+		c.cache.cycles--
+	}
+	if c.cache.cycles == 0 {
+		c.cache.node = nil
+		c.cache.pods = nil
+		c.cache.listed = false
+	}
+}
+
+// getNode returns current node, cached in a cycle. It must not be changed.
+func (c *evictionClient) getNode() (*v1.Node, error) {
+	c.cache.lock.Lock()
+	if node := c.cache.node; node != nil && time.Since(c.cache.nodeTime) < cycleCacheMaxAge {
+		c.cache.lock.Unlock()
+		return node, nil
+	}
+	c.cache.lock.Unlock()
+	node, err := c.client.CoreV1().Nodes().Get(c.nodeName, metav1.GetOptions{})
+	if err != nil {
+		return nil, unauthorizedError("get node", c.nodeName, err)
+	}
+	c.updateNode(node)
+	return node, nil
+}
+
+// listPods returns pods on current node, cached in a cycle. They must not be changed.
+func (c *evictionClient) listPods() ([]v1.Pod, error) {
+	c.cache.lock.Lock()
+	if c.cache.listed && time.Since(c.cache.podsTime) < cycleCacheMaxAge {
+		pods := c.cache.pods
+		c.cache.lock.Unlock()
+		return pods, nil
+	}
+	c.cache.lock.Unlock()
+	options := metav1.ListOptions{
+		FieldSelector: fmt.Sprintf("spec.nodeName=%s", c.nodeName),
+	}
+	podLists, err := c.client.CoreV1().Pods(metav1.NamespaceAll).List(options)
+	if err != nil {
+		return nil, unauthorizedError("list pods on", c.nodeName, err)
+	}
+	c.cache.lock.Lock()
+	if c.cache.cycles > 0 {
+		c.cache.pods = podLists.Items
+		c.cache.podsTime = time.Now()
+		c.cache.listed = true
+	}
+	c.cache.lock.Unlock()
+	return podLists.Items, nil
+}
+
+// inCycle returns true if node and pods are cached
+func (c *evictionClient) inCycle() bool {
+	c.cache.lock.Lock()
+	defer c.cache.lock.Unlock()
+	return c.cache.cycles > 0
+}
+
+// updateNode caches node returned by a change of it in a cycle, nil drops
+// cached node after it's changed
+func (c *evictionClient) updateNode(node *v1.Node) {
+	c.cache.lock.Lock()
+	if c.cache.cycles > 0 {
+		c.cache.node = node
+		c.cache.nodeTime = time.Now()
+	}
+	c.cache.lock.Unlock()
+}
+
+// invalidatePods drops cached pods after any of them is changed
+func (c *evictionClient) invalidatePods() {
+	c.cache.lock.Lock()
+	c.cache.pods = nil
+	c.cache.listed = false
+	c.cache.lock.Unlock()
+}
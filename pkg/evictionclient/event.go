@@ -0,0 +1,70 @@
+package evictionclient
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/types"
+)
+
+const (
+	// eventComponent is the source component of all events emitted by agent
+	eventComponent = "eviction-agent"
+)
+
+// RecordNodeEvent records an event on current node
+func (c *evictionClient) RecordNodeEvent(eventType, reason, message string) {
+	// kubelet uses node name as UID of node reference, so does kubectl describe
+	ref := &v1.ObjectReference{
+		Kind: "Node",
+		Name: c.nodeName,
+		UID:  k8stypes.UID(c.nodeName),
+	}
+	c.recordEvent(ref, metav1.NamespaceDefault, eventType, reason, message)
+}
+
+// RecordPodEvent records an event on the given pod
+func (c *evictionClient) RecordPodEvent(podInfo *types.PodInfo, eventType, reason, message string) {
+	if podInfo == nil || podInfo.Name == "" {
+		return
+	}
+	ref := &v1.ObjectReference{
+		Kind:      "Pod",
+		Name:      podInfo.Name,
+		Namespace: podInfo.Namespace,
+		UID:       k8stypes.UID(podInfo.UID),
+	}
+	c.recordEvent(ref, podInfo.Namespace, eventType, reason, message)
+}
+
+// recordEvent creates the event, errors are only logged
+func (c *evictionClient) recordEvent(ref *v1.ObjectReference, namespace, eventType, reason, message string) {
+	now := metav1.NewTime(time.Now())
+	event := &v1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%v.%x", ref.Name, now.UnixNano()),
+			Namespace: namespace,
+		},
+		InvolvedObject: *ref,
+		Reason:         reason,
+		Message:        message,
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+		Type:           eventType,
+		Source: v1.EventSource{
+			Component: eventComponent,
+			Host:      c.nodeName,
+		},
+	}
+	if _, err := c.client.CoreV1().Events(namespace).Create(event); err != nil {
+		log.Errorf("record event %s on %s %s error: %v", reason, ref.Kind, ref.Name, err)
+		return
+	}
+	log.Infof("Record event %s on %s %s: %s", reason, ref.Kind, ref.Name, message)
+}
@@ -1,21 +1,31 @@
 package evictionclient
 
 import (
+	"context"
 	"encoding/json"
+	"net/http"
 	"fmt"
+	"sort"
+	"strings"
+	"time"
 	"k8s.io/api/core/v1"
 	policyv1 "k8s.io/api/policy/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	k8stypes "k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/strategicpatch"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/util/flowcontrol"
+	"golang.org/x/time/rate"
 
 	"eviction-agent/cmd/options"
+	"eviction-agent/pkg/apis/v1alpha1"
 	"eviction-agent/pkg/summary"
 	"eviction-agent/pkg/types"
 	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/tracing"
 	"strconv"
 )
 
@@ -23,7 +33,8 @@ import (
 type Client interface {
 	// GetTaintConditions get all specific taint conditions of current node
 	GetTaintConditions() (types.NodeTaintInfo, error)
-	// SetTaintConditions set or update taint conditions of current node
+	// SetTaintConditions set or update taint conditions of current node, by
+	// action Taint, SoftTaint of PreferNoSchedule, or UnTaint
 	SetTaintConditions(string, string) error
 	// GetSummaryStats get node/pod stats from summary API
 	GetSummaryStats() (*summary.ConditionStats, error)
@@ -31,12 +42,98 @@ type Client interface {
 	EvictOnePod(*types.PodInfo) error
 	// GetLowerPriorityPods
 	GetLowerPriorityPods(int) ([]types.PodInfo, error)
-	// LabelPod
-	LabelPod(podInfo *types.PodInfo, priority string, action string) error
+	// GetPods get pods on current node which are not terminated
+	GetPods() ([]types.PodInfo, error)
+	// CordonNode mark current node unschedulable
+	CordonNode() error
+	// GetInfraPods get pods of DaemonSets and static pods on current node
+	GetInfraPods() (map[string]bool, error)
+	// GetHostNetworkPods get pods of host network on current node
+	GetHostNetworkPods() (map[string]bool, error)
+	// GetLatencySensitivePods get pods annotated latency sensitive on current node
+	GetLatencySensitivePods() (map[string]bool, error)
+	// GetToleratedTaints get taint keys of taintKeys tolerated by pods on current node keyed by namespace/name
+	GetToleratedTaints(taintKeys []string) (map[string][]string, error)
+	// GetPinnedPods get why pods on current node can't be scheduled on other nodes keyed by namespace/name
+	GetPinnedPods() (map[string]string, error)
+	// GetStatefulSetPods get pods of StatefulSets on current node keyed by namespace/name
+	GetStatefulSetPods() (map[string]types.StatefulSetPod, error)
+	// GetJobProgress get progress of pods of Jobs on current node to completion in [0, 1] keyed by namespace/name
+	GetJobProgress() (map[string]float64, error)
+	// GetPodLifecycles get start times and restarts of pods on current node keyed by namespace/name
+	GetPodLifecycles() (map[string]types.PodLifecycle, error)
+	// GetIOBursts get high io phases declared by pods on current node keyed by namespace/name
+	GetIOBursts() (map[string]types.IOBurst, error)
+	// GetPodCosts get costs of pods on current node by label or annotation key keyed by namespace/name
+	GetPodCosts(key string) (map[string]float64, error)
+	// GetDisruptionBudgets get PodDisruptionBudgets of pods on current node keyed by namespace/name
+	GetDisruptionBudgets() (map[string]types.DisruptionBudget, error)
+	// GetClusterHeadroom get spare capacity of other schedulable nodes of cluster
+	GetClusterHeadroom() (*types.ClusterHeadroom, error)
+	// GetCreatingContainers get containers being created on current node
+	GetCreatingContainers() ([]string, error)
+	// LabelPod add or delete evict label priority of evictType on pod
+	LabelPod(podInfo *types.PodInfo, priority string, evictType string, action string) error
 	// GetIOPSTotalFromAnnotations
 	GetResourcesTotalFromAnnotations() (*types.NodeIOPSTotal, error)
 	//ClearAllEvictLabels
 	ClearAllEvictLabels() error
+	// ClearEvictLabels clear evict labels added for evictTypes
+	ClearEvictLabels(evictTypes []string) error
+	// RecordNodeEvent record an event on current node
+	RecordNodeEvent(eventType, reason, message string)
+	// RecordPodEvent record an event on pod
+	RecordPodEvent(podInfo *types.PodInfo, eventType, reason, message string)
+	// GetTenantNamespaces get namespaces with label mapped to value of it
+	GetTenantNamespaces(label string) (map[string]string, error)
+	// GetNamespaceAnnotations get annotations of all namespaces keyed by namespace
+	GetNamespaceAnnotations() (map[string]map[string]string, error)
+	// GetNodeLabels get labels of current node
+	GetNodeLabels() (map[string]string, error)
+	// GetNodeAnnotations get annotations of current node
+	GetNodeAnnotations() (map[string]string, error)
+	// SetNodeCondition set a condition in status of current node
+	SetNodeCondition(conditionType string, status bool, reason, message string) error
+	// GetNodeAllocatable get allocatable of resources of current node
+	GetNodeAllocatable() (map[string]float64, error)
+	// AnnotateNode set annotations of current node, empty values are removed
+	AnnotateNode(annotations map[string]string) error
+	// GetPodResources get requests and limits of containers of pod
+	GetPodResources(podInfo *types.PodInfo) ([]types.ContainerResources, error)
+	// ResizePod patch resources of containers of pod in place
+	ResizePod(podInfo *types.PodInfo, containers []types.ContainerResources) error
+	// GetPodOwner get the controller of pod as kind/name
+	GetPodOwner(podInfo *types.PodInfo) (string, error)
+	// GetPodProfiles get QoS classes, controller kinds and images of pods on current node keyed by namespace/name
+	GetPodProfiles() (map[string]types.PodProfile, error)
+	// GetPodWorkload get the top controller of pod, nil if pod has no controller
+	GetPodWorkload(podInfo *types.PodInfo) (*types.Workload, error)
+	// GetPodController get the controller of pod, nil if pod has no controller
+	GetPodController(podInfo *types.PodInfo) (*types.Workload, error)
+	// GetReplacementPod get the first pod of controller created since, nil if there is none
+	GetReplacementPod(controller *types.Workload, since time.Time) (*types.Replacement, error)
+	// UpdateWorkloadMetadata update labels and annotations of workload
+	UpdateWorkloadMetadata(workload *types.Workload, update func(labels, annotations map[string]string) bool) error
+	// RecordWorkloadEvent record an event on workload
+	RecordWorkloadEvent(workload *types.Workload, eventType, reason, message string)
+	// ListEvictionPolicies list all EvictionPolicy custom resources
+	ListEvictionPolicies() (*v1alpha1.EvictionPolicyList, error)
+	// WatchEvictionPolicies watch changes of EvictionPolicy custom resources after resourceVersion
+	WatchEvictionPolicies(resourceVersion string) (PolicyWatch, error)
+	// UpdateNodeEvictionStatus create or update NodeEvictionStatus of current node
+	UpdateNodeEvictionStatus(namespace string, status *v1alpha1.AgentStatus) error
+	// GetConfigMap get data of ConfigMap, nil if it's not found
+	GetConfigMap(namespace, name string) (map[string]string, error)
+	// UpdateConfigMap create ConfigMap or replace its data
+	UpdateConfigMap(namespace, name string, data map[string]string) error
+	// BeginCycle start sharing node and pods on node among calls until EndCycle
+	BeginCycle()
+	// EndCycle stop sharing node and pods of BeginCycle
+	EndCycle()
+	// CheckPermissions returns permissions denied to the agent
+	CheckPermissions(permissions []Permission) ([]Permission, error)
+	// WithContext returns the client whose api server and kubelet requests carry traceparent of span in ctx
+	WithContext(ctx context.Context) Client
 }
 
 type evictionClient struct {
@@ -44,10 +141,30 @@ type evictionClient struct {
 	client     *kubernetes.Clientset
 	nodeInfo   summary.NodeInfo
 	summaryApi summary.SummaryStatsApi
+	// clearLabelsBudget limits api calls of ClearAllEvictLabels
+	clearLabelsBudget *rate.Limiter
+	// labels are pods marked by agent, whose marks are cleared
+	labels *labelIndex
+	// cache shares node and pods on node in a cycle
+	cache *cycleCache
+	// marker marks pods to evict by labels or annotations
+	marker evictMarker
+	// config and transport create clients of WithContext
+	config    *rest.Config
+	transport http.RoundTripper
 }
 
 // NewClientOrDie creates a new eviction client, panics if error occurs.
 func NewClientOrDie(eao *options.EvictionAgentOptions) Client {
+	c, err := NewClient(eao)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// NewClient creates a new eviction client of eao.NodeName
+func NewClient(eao *options.EvictionAgentOptions) (Client, error) {
 	c := &evictionClient{}
 	var config *rest.Config
 	var err error
@@ -56,47 +173,92 @@ func NewClientOrDie(eao *options.EvictionAgentOptions) Client {
 	if kubeconfigFile != "" {
 		config, err = clientcmd.BuildConfigFromFlags("", kubeconfigFile)
 		if err != nil {
-			panic(err)
+			log.Errorf("Failed to create config from kubeconfig file %s: %v", kubeconfigFile, err)
+			return nil, err
 		}
 		log.Infof("Create client using kubeconfig file %s", kubeconfigFile)
 	} else {
 		config, err = rest.InClusterConfig()
 		if err != nil {
-			panic(err)
+			log.Errorf("Failed to create in-cluster config, use --kubeconfig to run out of cluster: %v", err)
+			return nil, err
 		}
 		log.Infof("Create client using in-cluster config")
 	}
+	config.QPS = float32(eao.KubeAPIQPS)
+	config.Burst = eao.KubeAPIBurst
+	// clients of WithContext share the rate limit
+	config.RateLimiter = flowcontrol.NewTokenBucketRateLimiter(config.QPS, config.Burst)
 
 	clientSet, err := kubernetes.NewForConfig(config)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
 	c.client = clientSet
 	c.nodeName = eao.NodeName
+	c.clearLabelsBudget = rate.NewLimiter(rate.Limit(eao.ClearLabelsQPS), eao.ClearLabelsBurst)
+	c.labels = newLabelIndex()
+	c.cache = &cycleCache{}
+	c.marker = evictMarker{annotation: eao.EvictMark == options.EvictMarkAnnotation, prefix: eao.EvictMarkPrefix}
 
 	ipAddr, err := c.getNodeAddress()
 	if err != nil {
 		log.Errorf("%v", err)
-		panic(err)
+		return nil, err
 	}
 
 	transport, err := rest.TransportFor(config)
 	if err != nil {
 		log.Errorf("get transport error: %v", err)
-		panic(err)
+		return nil, err
 	}
 
 	c.nodeInfo = summary.NodeInfo{
 		Name:           c.nodeName,
 		Port:           10255, // get port from node?
 		ConnectAddress: ipAddr,
+		Timeout:        eao.StatsTimeout,
 	}
 
 	// NewSummaryStatsApi
 	c.summaryApi, err = summary.NewSummaryStatsApi(transport, c.nodeInfo)
+	if err != nil {
+		return nil, err
+	}
+	c.config = config
+	c.transport = transport
+	return c, nil
+}
 
-	return c
+// WithContext returns a copy of c sharing its state, whose requests carry
+// traceparent of span in ctx. c is returned if ctx has no span.
+func (c *evictionClient) WithContext(ctx context.Context) Client {
+	if !tracing.Traced(ctx) {
+		return c
+	}
+	config := rest.CopyConfig(c.config)
+	wrap := config.WrapTransport
+	config.WrapTransport = func(rt http.RoundTripper) http.RoundTripper {
+		if wrap != nil {
+			rt = wrap(rt)
+		}
+		return tracing.Transport(ctx, rt)
+	}
+	clientSet, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		log.Warnf("create traced client error, requests are not traced: %v", err)
+		return c
+	}
+	summaryApi, err := summary.NewSummaryStatsApi(tracing.Transport(ctx, c.transport), c.nodeInfo)
+	if err != nil {
+		log.Warnf("create traced summary client error, requests are not traced: %v", err)
+		return c
+	}
+	traced := *c
+	traced.client = clientSet
+	traced.summaryApi = summaryApi
+	return &traced
 }
 
 func (c *evictionClient) getNodeAddress() (string, error) {
@@ -115,7 +277,7 @@ func (c *evictionClient) getNodeAddress() (string, error) {
 }
 
 func (c evictionClient) GetResourcesTotalFromAnnotations() (*types.NodeIOPSTotal, error) {
-	node, err := c.client.CoreV1().Nodes().Get(c.nodeName, metav1.GetOptions{})
+	node, err := c.getNode()
 	if err != nil {
 		log.Errorf("get node taint condition error %v", err)
 		return nil, err
@@ -165,14 +327,22 @@ func (c *evictionClient) GetTaintConditions() (types.NodeTaintInfo, error) {
 		Memory:    false,
 	}
 
-	node, err := c.client.CoreV1().Nodes().Get(c.nodeName, metav1.GetOptions{})
+	node, err := c.getNode()
 	if err != nil {
 		log.Errorf("get node taint condition error %v", err)
 		return nodeTaintInfo, err
 	}
 	taints := node.Spec.Taints
 
+	nodeTaintInfo.Taints = make(map[string]bool)
+	nodeTaintInfo.Problems = make(map[string]bool)
+	for _, condition := range node.Status.Conditions {
+		if condition.Status == v1.ConditionTrue {
+			nodeTaintInfo.Problems[string(condition.Type)] = true
+		}
+	}
 	for _, t := range taints {
+		nodeTaintInfo.Taints[t.Key] = true
 		if t.Key == types.NetworkIO {
 			nodeTaintInfo.NetworkIO = true
 		}
@@ -189,8 +359,16 @@ func (c *evictionClient) GetTaintConditions() (types.NodeTaintInfo, error) {
 	return nodeTaintInfo, nil
 }
 
+// SetTaintConditions taint or untaint current node, retry on transient errors.
+// SoftTaint taints node PreferNoSchedule, and a soft taint is hardened by Taint.
 func (c *evictionClient) SetTaintConditions(taintKey string, action string) error {
-	oldNode, err := c.client.CoreV1().Nodes().Get(c.nodeName, metav1.GetOptions{})
+	return c.retry(action, "node "+taintKey, func() error {
+		return c.setTaintConditions(taintKey, action)
+	})
+}
+
+func (c *evictionClient) setTaintConditions(taintKey string, action string) error {
+	oldNode, err := c.getNode()
 	if err != nil {
 		log.Errorf("get node taint condition error %v", err)
 		return err
@@ -201,16 +379,35 @@ func (c *evictionClient) SetTaintConditions(taintKey string, action string) erro
 		return fmt.Errorf("failed to marshal old node for node %v : %v", c.nodeName, err)
 	}
 
-	newTaints := oldNode.Spec.Taints
+	// taints of the clone are changed, old node may be shared in a cycle
 	newNodeClone := oldNode.DeepCopy()
+	newTaints := newNodeClone.Spec.Taints
 
-	if action == "Taint" {
-		currentTaint := v1.Taint{
-			Key:    taintKey,
-			Value:  "True",
-			Effect: "NoSchedule",
+	if action == "Taint" || action == "SoftTaint" {
+		effect := v1.TaintEffect(types.TaintEffect(taintKey))
+		if action == "SoftTaint" {
+			effect = v1.TaintEffectPreferNoSchedule
+		}
+		tainted := false
+		for i, t := range newTaints {
+			if taintKey != t.Key {
+				continue
+			}
+			// tainted by the last try, a taint is never softened
+			if t.Effect == effect || action == "SoftTaint" {
+				return nil
+			}
+			newTaints[i].Effect = effect
+			tainted = true
+		}
+		if !tainted {
+			currentTaint := v1.Taint{
+				Key:    taintKey,
+				Value:  "True",
+				Effect: effect,
+			}
+			newTaints = append(newTaints, currentTaint)
 		}
-		newTaints = append(newTaints, currentTaint)
 	} else if action == "UnTaint" {
 		for i, t := range newTaints {
 			if taintKey == t.Key {
@@ -230,7 +427,11 @@ func (c *evictionClient) SetTaintConditions(taintKey string, action string) erro
 		return fmt.Errorf("failed to create patch for node %v", c.nodeName)
 	}
 
-	_, err = c.client.CoreV1().Nodes().Patch(c.nodeName, k8stypes.StrategicMergePatchType, patchBytes)
+	node, err := c.client.CoreV1().Nodes().Patch(c.nodeName, k8stypes.StrategicMergePatchType, patchBytes)
+	if err != nil {
+		node = nil
+	}
+	c.updateNode(node)
 	return err
 }
 
@@ -239,11 +440,18 @@ func (c *evictionClient) GetSummaryStats() (*summary.ConditionStats, error) {
 	return stats, err
 }
 
-// EvictOnePodByName call evict-api to evict one pod
+// EvictOnePodByName call evict-api to evict one pod, retry on transient errors,
+// evictions blocked by PodDisruptionBudget return at once
 func (c *evictionClient) EvictOnePod(podToEvict *types.PodInfo) error {
 	if podToEvict.Name == "" {
 		return fmt.Errorf("pod name should not be empty")
 	}
+	return c.retryExcept("Evict", "pod "+podToEvict.Namespace+"/"+podToEvict.Name, IsBlockedByBudget, func() error {
+		return c.evictOnePod(podToEvict)
+	})
+}
+
+func (c *evictionClient) evictOnePod(podToEvict *types.PodInfo) error {
 	eviction := policyv1.Eviction{
 		TypeMeta: metav1.TypeMeta{},
 		ObjectMeta: metav1.ObjectMeta{
@@ -253,21 +461,19 @@ func (c *evictionClient) EvictOnePod(podToEvict *types.PodInfo) error {
 		DeleteOptions: &metav1.DeleteOptions{},
 	}
 	err := c.client.CoreV1().Pods(eviction.Namespace).Evict(&eviction)
+	c.invalidatePods()
 	return err
 }
 
 // GetLowerPriorityPods return pods which are set low priority
 func (c *evictionClient) GetLowerPriorityPods(lowPriorityThreshold int) ([]types.PodInfo, error) {
 	var pods []types.PodInfo
-	options := metav1.ListOptions{
-		FieldSelector: fmt.Sprintf("spec.nodeName=%s", c.nodeName),
-	}
-	podLists, err := c.client.CoreV1().Pods(metav1.NamespaceAll).List(options)
+	podList, err := c.listPods()
 	if err != nil {
 		log.Errorf("List pods on %s error\n", c.nodeName)
 		return nil, err
 	}
-	for _, pod := range podLists.Items {
+	for _, pod := range podList {
 		// default is High priority
 		priority := types.LowestPriority
 		if pod.Spec.Priority != nil {
@@ -281,6 +487,7 @@ func (c *evictionClient) GetLowerPriorityPods(lowPriorityThreshold int) ([]types
 			newPod := types.PodInfo{
 				Name:      pod.Name,
 				Namespace: pod.Namespace,
+				UID:       string(pod.UID),
 				Priority:  priority,
 			}
 			pods = append(pods, newPod)
@@ -290,72 +497,521 @@ func (c *evictionClient) GetLowerPriorityPods(lowPriorityThreshold int) ([]types
 	return pods, nil
 }
 
-// LabelPod add an evict label on pod
-func (c *evictionClient) LabelPod(podInfo *types.PodInfo, priority string, action string) error {
-	if podInfo.Name == "" {
-		return fmt.Errorf("pod name should not be empty")
-	}
-	oldPod, err := c.client.CoreV1().Pods(podInfo.Namespace).Get(podInfo.Name, metav1.GetOptions{})
+// GetPods return pods on current node which are not terminated, priority is
+// zero if it's not set
+func (c *evictionClient) GetPods() ([]types.PodInfo, error) {
+	podList, err := c.listPods()
 	if err != nil {
-		log.Errorf("get pod %s error", podInfo.Name)
-		return err
+		log.Errorf("List pods on %s error %v", c.nodeName, err)
+		return nil, err
+	}
+	var pods []types.PodInfo
+	for _, pod := range podList {
+		if pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed || pod.DeletionTimestamp != nil {
+			continue
+		}
+		priority := types.LowestPriority
+		if pod.Spec.Priority != nil {
+			priority = int(*pod.Spec.Priority)
+		}
+		pods = append(pods, types.PodInfo{
+			Name:      pod.Name,
+			Namespace: pod.Namespace,
+			UID:       string(pod.UID),
+			Priority:  priority,
+		})
 	}
-	oldData, err := json.Marshal(oldPod)
+	return pods, nil
+}
+
+// CordonNode marks current node unschedulable, retry on transient errors
+func (c *evictionClient) CordonNode() error {
+	patch := []byte(`{"spec":{"unschedulable":true}}`)
+	return c.retry("Cordon", "node "+c.nodeName, func() error {
+		_, err := c.client.CoreV1().Nodes().Patch(c.nodeName, k8stypes.MergePatchType, patch)
+		c.updateNode(nil)
+		return err
+	})
+}
+
+// GetInfraPods return namespace/name of pods of DaemonSets and static pods on
+// current node, e.g. monitoring agents and eviction agent itself. They are
+// recreated on the same node if they are evicted.
+func (c *evictionClient) GetInfraPods() (map[string]bool, error) {
+	podList, err := c.listPods()
 	if err != nil {
-		return fmt.Errorf("failed to marshal old node for node %v : %v", c.nodeName, err)
+		log.Errorf("List pods on %s error %v", c.nodeName, err)
+		return nil, err
+	}
+	pods := make(map[string]bool)
+	for _, pod := range podList {
+		owner := metav1.GetControllerOf(&pod)
+		_, mirror := pod.Annotations[v1.MirrorPodAnnotationKey]
+		if mirror || (owner != nil && owner.Kind == "DaemonSet") {
+			pods[pod.Namespace+"/"+pod.Name] = true
+		}
 	}
-	newPod := oldPod.DeepCopy()
+	return pods, nil
+}
 
-	if newPod.Labels == nil {
-		log.Infof("there is no label on this pod: %v, create it", podInfo.Name)
-		newPod.Labels = make(map[string]string)
+// GetHostNetworkPods return namespace/name of pods of host network on current
+// node, their network stats are of node interfaces
+func (c *evictionClient) GetHostNetworkPods() (map[string]bool, error) {
+	podList, err := c.listPods()
+	if err != nil {
+		log.Errorf("List pods on %s error %v", c.nodeName, err)
+		return nil, err
 	}
-	if action == "Add" {
-		newPod.Labels[priority] = "true"
-	} else if action == "Delete" {
-		delete(newPod.Labels, priority)
+	pods := make(map[string]bool)
+	for _, pod := range podList {
+		if pod.Spec.HostNetwork {
+			pods[pod.Namespace+"/"+pod.Name] = true
+		}
 	}
+	return pods, nil
+}
 
-	newData, err := json.Marshal(newPod)
+// GetLatencySensitivePods return namespace/name of pods of LatencySensitiveAnnotation on current node
+func (c *evictionClient) GetLatencySensitivePods() (map[string]bool, error) {
+	podList, err := c.listPods()
 	if err != nil {
-		return fmt.Errorf("failed to marshal new pod %v : %v", podInfo.Name, err)
+		log.Errorf("List pods on %s error %v", c.nodeName, err)
+		return nil, err
+	}
+	pods := make(map[string]bool)
+	for _, pod := range podList {
+		if pod.Annotations[types.LatencySensitiveAnnotation] == "true" {
+			pods[pod.Namespace+"/"+pod.Name] = true
+		}
 	}
+	return pods, nil
+}
 
-	patchBytes, err := strategicpatch.CreateTwoWayMergePatch(oldData, newData, v1.Pod{})
+// GetPodLifecycles return start times and restarts of containers of pods on
+// current node, pods not started by kubelet yet are not returned
+func (c *evictionClient) GetPodLifecycles() (map[string]types.PodLifecycle, error) {
+	podList, err := c.listPods()
 	if err != nil {
-		return fmt.Errorf("failed to create patch for pod %v", podInfo.Name)
+		log.Errorf("List pods on %s error %v", c.nodeName, err)
+		return nil, err
+	}
+	lifecycles := make(map[string]types.PodLifecycle, len(podList))
+	for _, pod := range podList {
+		if pod.Status.StartTime == nil {
+			continue
+		}
+		lifecycle := types.PodLifecycle{StartTime: pod.Status.StartTime.Time}
+		for _, status := range pod.Status.ContainerStatuses {
+			lifecycle.Restarts += int(status.RestartCount)
+		}
+		lifecycles[pod.Namespace+"/"+pod.Name] = lifecycle
 	}
-	_, err = c.client.CoreV1().Pods(oldPod.Namespace).Patch(oldPod.Name, k8stypes.StrategicMergePatchType, patchBytes)
+	return lifecycles, nil
+}
 
-	log.Infof("Label pod: %v, action:%v", podInfo.Name, action)
-	return err
+// GetIOBursts return high io phases declared by IOBurstAnnotation of pods on
+// current node, invalid declarations are logged and ignored
+func (c *evictionClient) GetIOBursts() (map[string]types.IOBurst, error) {
+	podList, err := c.listPods()
+	if err != nil {
+		log.Errorf("List pods on %s error %v", c.nodeName, err)
+		return nil, err
+	}
+	bursts := make(map[string]types.IOBurst)
+	for _, pod := range podList {
+		value, ok := pod.Annotations[types.IOBurstAnnotation]
+		if !ok {
+			continue
+		}
+		burst, err := parseIOBurst(value)
+		if err != nil {
+			log.Warnf("ignore %s of pod %s/%s: %v", types.IOBurstAnnotation, pod.Namespace, pod.Name, err)
+			continue
+		}
+		bursts[pod.Namespace+"/"+pod.Name] = burst
+	}
+	return bursts, nil
 }
 
-// ClearAllEvictLabels clear evict labels from pod if node is not in bad condition
-func (c *evictionClient) ClearAllEvictLabels() error {
-	options := metav1.ListOptions{
-		FieldSelector: fmt.Sprintf("spec.nodeName=%s", c.nodeName),
+// parseIOBurst parses a duration, or a RFC3339 start time and a duration
+// separated by "/"
+func parseIOBurst(value string) (types.IOBurst, error) {
+	var burst types.IOBurst
+	duration := value
+	if i := strings.LastIndex(value, "/"); i >= 0 {
+		start, err := time.Parse(time.RFC3339, value[:i])
+		if err != nil {
+			return burst, err
+		}
+		burst.Start, duration = start, value[i+1:]
 	}
-	podLists, err := c.client.CoreV1().Pods(metav1.NamespaceAll).List(options)
+	d, err := time.ParseDuration(duration)
 	if err != nil {
-		log.Errorf("List pods on %s error", c.nodeName)
-		return err
+		return burst, err
+	}
+	if d <= 0 {
+		return burst, fmt.Errorf("duration %v should be positive", d)
 	}
+	burst.Duration = d
+	return burst, nil
+}
 
-	for _, pod := range podLists.Items {
-		for k := range pod.Labels {
-			podInfo := types.PodInfo{
-				Name:      pod.Name,
-				Namespace: pod.Namespace,
-			}
-			if k == types.EvictCandidate {
-				c.LabelPod(&podInfo, k, "Delete")
+// hostnameLabel is the label of node name, pods selecting a single value of
+// it are required to run on the node
+const hostnameLabel = "kubernetes.io/hostname"
+
+// GetToleratedTaints return taint keys of taintKeys tolerated by pods on
+// current node, of the effects set by agent. Pods required to run on current
+// node by node selector or node affinity tolerate all of them, they are
+// scheduled back anyway. Pods tolerating none of them are not returned.
+func (c *evictionClient) GetToleratedTaints(taintKeys []string) (map[string][]string, error) {
+	podList, err := c.listPods()
+	if err != nil {
+		log.Errorf("List pods on %s error %v", c.nodeName, err)
+		return nil, err
+	}
+	pods := make(map[string][]string)
+	for i := range podList {
+		pod := &podList[i]
+		pinned := requiredOnNode(pod)
+		var tolerated []string
+		for _, key := range taintKeys {
+			taint := &v1.Taint{Key: key, Value: "True", Effect: v1.TaintEffect(types.TaintEffect(key))}
+			if pinned || toleratesTaint(pod.Spec.Tolerations, taint) {
+				tolerated = append(tolerated, key)
 			}
-			if k == types.NeedEvict {
-				c.LabelPod(&podInfo, k, "Delete")
+		}
+		if len(tolerated) != 0 {
+			pods[pod.Namespace+"/"+pod.Name] = tolerated
+		}
+	}
+	return pods, nil
+}
+
+func toleratesTaint(tolerations []v1.Toleration, taint *v1.Taint) bool {
+	for i := range tolerations {
+		if tolerations[i].ToleratesTaint(taint) {
+			return true
+		}
+	}
+	return false
+}
+
+// requiredOnNode returns true if pod can only be scheduled on the node it's
+// running on, by node selector of hostname, or required node affinity whose
+// terms all select a single hostname or node name
+func requiredOnNode(pod *v1.Pod) bool {
+	if _, ok := pod.Spec.NodeSelector[hostnameLabel]; ok {
+		return true
+	}
+	affinity := pod.Spec.Affinity
+	if affinity == nil || affinity.NodeAffinity == nil ||
+		affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		return false
+	}
+	terms := affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+	if len(terms) == 0 {
+		return false
+	}
+	for _, term := range terms {
+		if !selectsSingleNode(term.MatchExpressions, hostnameLabel) && !selectsSingleNode(term.MatchFields, "metadata.name") {
+			return false
+		}
+	}
+	return true
+}
+
+func selectsSingleNode(requirements []v1.NodeSelectorRequirement, key string) bool {
+	for _, requirement := range requirements {
+		if requirement.Key == key && requirement.Operator == v1.NodeSelectorOpIn && len(requirement.Values) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// GetCreatingContainers return namespace/name/container of containers waiting
+// to be created on current node, e.g. pulling images or starting, by their
+// states reported by kubelet
+func (c *evictionClient) GetCreatingContainers() ([]string, error) {
+	podList, err := c.listPods()
+	if err != nil {
+		log.Errorf("List pods on %s error %v", c.nodeName, err)
+		return nil, err
+	}
+	var containers []string
+	for _, pod := range podList {
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+		statuses := append(append([]v1.ContainerStatus{}, pod.Status.InitContainerStatuses...),
+			pod.Status.ContainerStatuses...)
+		for _, status := range statuses {
+			if waiting := status.State.Waiting; waiting != nil &&
+				(waiting.Reason == "ContainerCreating" || waiting.Reason == "PodInitializing") {
+				containers = append(containers, pod.Namespace+"/"+pod.Name+"/"+status.Name)
 			}
 		}
 	}
+	return containers, nil
+}
+
+// GetTenantNamespaces return namespaces with label mapped to the value of
+// label, i.e. the tenant of namespace
+func (c *evictionClient) GetTenantNamespaces(label string) (map[string]string, error) {
+	list, err := c.client.CoreV1().Namespaces().List(metav1.ListOptions{LabelSelector: label})
+	if err != nil {
+		log.Errorf("list namespaces with label %s error %v", label, err)
+		return nil, err
+	}
+	namespaces := make(map[string]string)
+	for _, namespace := range list.Items {
+		namespaces[namespace.Name] = namespace.Labels[label]
+	}
+	return namespaces, nil
+}
+
+// GetNamespaceAnnotations return annotations of all namespaces keyed by namespace
+func (c *evictionClient) GetNamespaceAnnotations() (map[string]map[string]string, error) {
+	list, err := c.client.CoreV1().Namespaces().List(metav1.ListOptions{})
+	if err != nil {
+		log.Errorf("list namespaces error %v", err)
+		return nil, err
+	}
+	namespaces := make(map[string]map[string]string)
+	for _, namespace := range list.Items {
+		namespaces[namespace.Name] = namespace.Annotations
+	}
+	return namespaces, nil
+}
+
+// GetNodeLabels return labels of current node
+func (c *evictionClient) GetNodeLabels() (map[string]string, error) {
+	node, err := c.getNode()
+	if err != nil {
+		log.Errorf("get node labels error %v", err)
+		return nil, err
+	}
+	return node.Labels, nil
+}
+
+// GetNodeAnnotations return annotations of current node
+func (c *evictionClient) GetNodeAnnotations() (map[string]string, error) {
+	node, err := c.getNode()
+	if err != nil {
+		log.Errorf("get node annotations error %v", err)
+		return nil, err
+	}
+	return node.Annotations, nil
+}
+
+// SetNodeCondition set a condition in status of current node, node is not
+// updated if the condition is unchanged. Retry on transient errors.
+func (c *evictionClient) SetNodeCondition(conditionType string, status bool, reason, message string) error {
+	node, err := c.getNode()
+	if err != nil {
+		log.Errorf("get node condition error %v", err)
+		return err
+	}
+	conditionStatus := v1.ConditionFalse
+	if status {
+		conditionStatus = v1.ConditionTrue
+	}
+	now := metav1.Now()
+	newCondition := v1.NodeCondition{
+		Type:               v1.NodeConditionType(conditionType),
+		Status:             conditionStatus,
+		LastHeartbeatTime:  now,
+		LastTransitionTime: now,
+		Reason:             reason,
+		Message:            message,
+	}
+	for _, old := range node.Status.Conditions {
+		if string(old.Type) != conditionType {
+			continue
+		}
+		if old.Status == conditionStatus && old.Reason == reason && old.Message == message {
+			return nil
+		}
+		if old.Status == conditionStatus {
+			newCondition.LastTransitionTime = old.LastTransitionTime
+		}
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"status": map[string]interface{}{
+			"conditions": []v1.NodeCondition{newCondition},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return c.retry("SetCondition", "node condition "+conditionType, func() error {
+		_, err := c.client.CoreV1().Nodes().Patch(c.nodeName, k8stypes.StrategicMergePatchType, patch, "status")
+		c.updateNode(nil)
+		return err
+	})
+}
 
-	return nil
+// GetNodeAllocatable return allocatable of current node keyed by resource name,
+// e.g. extended resources of device plugins
+func (c *evictionClient) GetNodeAllocatable() (map[string]float64, error) {
+	node, err := c.getNode()
+	if err != nil {
+		log.Errorf("get node allocatable error %v", err)
+		return nil, err
+	}
+	allocatable := make(map[string]float64)
+	for name, quantity := range node.Status.Allocatable {
+		allocatable[string(name)] = float64(quantity.MilliValue()) / 1000
+	}
+	return allocatable, nil
 }
+
+// AnnotateNode set annotations of current node, annotations of empty
+// values are removed. Retry on transient errors.
+func (c *evictionClient) AnnotateNode(annotations map[string]string) error {
+	values := make(map[string]interface{})
+	var keys []string
+	for key, value := range annotations {
+		keys = append(keys, key)
+		if value == "" {
+			// null removes the annotation in merge patch
+			values[key] = nil
+		} else {
+			values[key] = value
+		}
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": values,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	sort.Strings(keys)
+	return c.retry("Annotate", "node annotations "+strings.Join(keys, ","), func() error {
+		_, err := c.client.CoreV1().Nodes().Patch(c.nodeName, k8stypes.MergePatchType, patch)
+		c.updateNode(nil)
+		return err
+	})
+}
+
+// GetPodOwner return the controller of pod as kind/name, empty if pod has no controller
+func (c *evictionClient) GetPodOwner(podInfo *types.PodInfo) (string, error) {
+	pod, err := c.client.CoreV1().Pods(podInfo.Namespace).Get(podInfo.Name, metav1.GetOptions{})
+	if err != nil {
+		log.Errorf("get pod %s/%s error %v", podInfo.Namespace, podInfo.Name, err)
+		return "", err
+	}
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return "", nil
+	}
+	return owner.Kind + "/" + owner.Name, nil
+}
+
+// GetPodProfiles return QoS classes, controller kinds and images of pods on current node
+func (c *evictionClient) GetPodProfiles() (map[string]types.PodProfile, error) {
+	podList, err := c.listPods()
+	if err != nil {
+		log.Errorf("List pods on %s error %v", c.nodeName, err)
+		return nil, err
+	}
+	profiles := make(map[string]types.PodProfile, len(podList))
+	for _, pod := range podList {
+		profile := types.PodProfile{QOSClass: string(pod.Status.QOSClass)}
+		if owner := metav1.GetControllerOf(&pod); owner != nil {
+			profile.OwnerKind = owner.Kind
+		}
+		profile.Images = make(map[string]string, len(pod.Spec.Containers))
+		for _, container := range pod.Spec.Containers {
+			profile.Images[container.Name] = container.Image
+		}
+		profiles[pod.Namespace+"/"+pod.Name] = profile
+	}
+	return profiles, nil
+}
+
+// ListEvictionPolicies return all EvictionPolicy custom resources
+func (c *evictionClient) ListEvictionPolicies() (*v1alpha1.EvictionPolicyList, error) {
+	body, err := c.client.CoreV1().RESTClient().Get().
+		AbsPath("/apis", v1alpha1.GroupName, v1alpha1.Version, v1alpha1.EvictionPolicyPlural).
+		DoRaw()
+	if err != nil {
+		log.Errorf("list eviction policies error %v", err)
+		return nil, err
+	}
+	policies := &v1alpha1.EvictionPolicyList{}
+	if err := json.Unmarshal(body, policies); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal eviction policies: %v", err)
+	}
+	return policies, nil
+}
+
+// UpdateNodeEvictionStatus creates NodeEvictionStatus named after current node
+// or replaces the status of existing one
+func (c *evictionClient) UpdateNodeEvictionStatus(namespace string, status *v1alpha1.AgentStatus) error {
+	restClient := c.client.CoreV1().RESTClient()
+	path := []string{"/apis", v1alpha1.GroupName, v1alpha1.Version,
+		"namespaces", namespace, v1alpha1.NodeEvictionStatusPlural}
+
+	nodeStatus := &v1alpha1.NodeEvictionStatus{}
+	body, err := restClient.Get().AbsPath(append(path, c.nodeName)...).DoRaw()
+	if apierrors.IsNotFound(err) {
+		nodeStatus.APIVersion = v1alpha1.GroupName + "/" + v1alpha1.Version
+		nodeStatus.Kind = "NodeEvictionStatus"
+		nodeStatus.Name = c.nodeName
+		nodeStatus.Namespace = namespace
+		nodeStatus.Status = *status
+		data, err := json.Marshal(nodeStatus)
+		if err != nil {
+			return err
+		}
+		_, err = restClient.Post().AbsPath(path...).Body(data).DoRaw()
+		return err
+	} else if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(body, nodeStatus); err != nil {
+		return fmt.Errorf("failed to unmarshal node eviction status: %v", err)
+	}
+	nodeStatus.Status = *status
+	data, err := json.Marshal(nodeStatus)
+	if err != nil {
+		return err
+	}
+	_, err = restClient.Put().AbsPath(append(path, c.nodeName)...).Body(data).DoRaw()
+	return err
+}
+
+// GetConfigMap returns data of ConfigMap, nil if it's not found
+func (c *evictionClient) GetConfigMap(namespace, name string) (map[string]string, error) {
+	configMap, err := c.client.CoreV1().ConfigMaps(namespace).Get(name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return configMap.Data, nil
+}
+
+// UpdateConfigMap creates ConfigMap with data or replaces data of existing one
+func (c *evictionClient) UpdateConfigMap(namespace, name string, data map[string]string) error {
+	configMaps := c.client.CoreV1().ConfigMaps(namespace)
+	configMap, err := configMaps.Get(name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = configMaps.Create(&v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Data:       data,
+		})
+		return err
+	} else if err != nil {
+		return err
+	}
+	configMap.Data = data
+	_, err = configMaps.Update(configMap)
+	return err
+}
+
@@ -0,0 +1,971 @@
+// Package fake provides an in-memory evictionclient.Client for testing
+// eviction flows without a cluster.
+package fake
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/watch"
+
+	"eviction-agent/pkg/apis/v1alpha1"
+	"eviction-agent/pkg/evictionclient"
+	"eviction-agent/pkg/summary"
+	"eviction-agent/pkg/types"
+)
+
+var _ evictionclient.Client = &Client{}
+
+// Action is a call of fake client changing the node, pods or workloads, or
+// recording an event
+type Action struct {
+	// Verb is the method changing objects, e.g. SetTaintConditions, or Event
+	Verb string
+	// Target is the taint key, namespace/name of pod, kind/namespace/name of workload
+	// or the object of event
+	Target string
+	// Detail is the action of taint and label, or reason and message of event
+	Detail string
+}
+
+func (a Action) String() string {
+	return fmt.Sprintf("%s %s %s", a.Verb, a.Target, a.Detail)
+}
+
+// Client is a fake eviction client of a node in memory. Fields are the state
+// of node and pods, they may be set before use and read with Lock held after.
+// Changes are applied to fields and recorded as actions.
+type Client struct {
+	sync.Mutex
+	// Taints are keys of taints on node
+	Taints map[string]bool
+	// Problems are node conditions of status True
+	Problems map[string]bool
+	// Unschedulable is true after node is cordoned
+	Unschedulable bool
+	// Labels and Annotations are of node
+	Labels      map[string]string
+	Annotations map[string]string
+	Allocatable map[string]float64
+	Totals      types.NodeIOPSTotal
+	// Pods are pods on node, those of priority in (0, threshold] are lower priority pods
+	Pods []types.PodInfo
+	// InfraPods are namespace/name of DaemonSet and static pods
+	InfraPods map[string]bool
+	// HostNetworkPods are namespace/name of pods of host network
+	HostNetworkPods map[string]bool
+	// LatencySensitivePods are namespace/name of pods annotated latency sensitive
+	LatencySensitivePods map[string]bool
+	// ToleratedTaints are taint keys tolerated by pods keyed by namespace/name
+	ToleratedTaints map[string][]string
+	// PinnedPods are why pods can't be scheduled on other nodes keyed by namespace/name
+	PinnedPods map[string]string
+	// StatefulSetPods are pods of StatefulSets keyed by namespace/name
+	StatefulSetPods map[string]types.StatefulSetPod
+	// JobProgress are progress of pods of Jobs keyed by namespace/name
+	JobProgress map[string]float64
+	// Lifecycles are start times and restarts of pods keyed by namespace/name
+	Lifecycles map[string]types.PodLifecycle
+	// IOBursts are high io phases declared by pods keyed by namespace/name
+	IOBursts map[string]types.IOBurst
+	// Costs are costs of pods keyed by namespace/name
+	Costs map[string]float64
+	// Budgets are PodDisruptionBudgets of pods keyed by namespace/name
+	Budgets map[string]types.DisruptionBudget
+	// Headroom is the spare capacity of cluster
+	Headroom types.ClusterHeadroom
+	// CreatingContainers are namespace/name/container of containers being created
+	CreatingContainers []string
+	// Workloads are top controllers of pods keyed by namespace/name
+	Workloads map[string]*types.Workload
+	// Controllers are controllers of pods keyed by namespace/name, e.g. ReplicaSets
+	Controllers map[string]*types.Workload
+	// Replacements are pods created by controllers keyed by uid of controller
+	Replacements map[string]*types.Replacement
+	// WorkloadLabels and WorkloadAnnotations are keyed by kind/namespace/name
+	WorkloadLabels      map[string]map[string]string
+	WorkloadAnnotations map[string]map[string]string
+	// Resources are containers of pods keyed by namespace/name
+	Resources map[string][]types.ContainerResources
+	// Profiles are QoS classes and controller kinds of pods keyed by namespace/name
+	Profiles map[string]types.PodProfile
+	// NamespaceLabels are labels of namespaces keyed by namespace
+	NamespaceLabels map[string]map[string]string
+	// NamespaceAnnotations are annotations of namespaces keyed by namespace
+	NamespaceAnnotations map[string]map[string]string
+	// EvictLabels are evict labels of pods keyed by namespace/name, mapped to evict types
+	EvictLabels map[string]map[string][]string
+	// Stats is returned by GetSummaryStats
+	Stats    *summary.ConditionStats
+	Policies v1alpha1.EvictionPolicyList
+	// Statuses are NodeEvictionStatus updated keyed by namespace
+	Statuses map[string]v1alpha1.AgentStatus
+	// ConfigMaps are data of ConfigMaps keyed by namespace/name
+	ConfigMaps map[string]map[string]string
+	// Errors are returned by calls of methods keyed by method name, e.g. EvictOnePod
+	Errors map[string]error
+	// Denied are permissions denied to the agent, e.g. "create pods/eviction"
+	Denied map[string]bool
+
+	actions []Action
+	// policyWatches are open watches of WatchEvictionPolicies
+	policyWatches []*policyWatch
+}
+
+// NewClient creates a fake client of an empty node
+func NewClient() *Client {
+	return &Client{
+		Taints:              make(map[string]bool),
+		Problems:            make(map[string]bool),
+		Labels:              make(map[string]string),
+		Annotations:         make(map[string]string),
+		Allocatable:         make(map[string]float64),
+		InfraPods:           make(map[string]bool),
+		Workloads:           make(map[string]*types.Workload),
+		Controllers:         make(map[string]*types.Workload),
+		Replacements:        make(map[string]*types.Replacement),
+		WorkloadLabels:      make(map[string]map[string]string),
+		WorkloadAnnotations: make(map[string]map[string]string),
+		Resources:           make(map[string][]types.ContainerResources),
+		Profiles:            make(map[string]types.PodProfile),
+		NamespaceLabels:     make(map[string]map[string]string),
+		EvictLabels:         make(map[string]map[string][]string),
+		Statuses:            make(map[string]v1alpha1.AgentStatus),
+		ConfigMaps:          make(map[string]map[string]string),
+		Errors:              make(map[string]error),
+		Denied:              make(map[string]bool),
+	}
+}
+
+// Actions returns actions recorded so far, the oldest first
+func (c *Client) Actions() []Action {
+	c.Lock()
+	defer c.Unlock()
+	return append([]Action(nil), c.actions...)
+}
+
+// ClearActions forgets actions recorded so far
+func (c *Client) ClearActions() {
+	c.Lock()
+	defer c.Unlock()
+	c.actions = nil
+}
+
+// SetProblem sets a node condition of node problems
+func (c *Client) SetProblem(conditionType string, status bool) {
+	c.Lock()
+	defer c.Unlock()
+	c.Problems[conditionType] = status
+}
+
+// record records an action, Lock must be held
+func (c *Client) record(verb, target, detail string) {
+	c.actions = append(c.actions, Action{Verb: verb, Target: target, Detail: detail})
+}
+
+func podKey(pod *types.PodInfo) string {
+	return pod.Namespace + "/" + pod.Name
+}
+
+func workloadKey(workload *types.Workload) string {
+	return workload.Kind + "/" + workload.Namespace + "/" + workload.Name
+}
+
+func (c *Client) GetTaintConditions() (types.NodeTaintInfo, error) {
+	c.Lock()
+	defer c.Unlock()
+	info := types.NodeTaintInfo{Taints: make(map[string]bool), Problems: make(map[string]bool)}
+	if err := c.Errors["GetTaintConditions"]; err != nil {
+		return info, err
+	}
+	for key, tainted := range c.Taints {
+		if !tainted {
+			continue
+		}
+		info.Taints[key] = true
+		switch key {
+		case types.NetworkIO:
+			info.NetworkIO = true
+		case types.DiskIO:
+			info.DiskIO = true
+		case types.CPUBusy:
+			info.CPU = true
+		case types.MemBusy:
+			info.Memory = true
+		}
+	}
+	for conditionType, status := range c.Problems {
+		if status {
+			info.Problems[conditionType] = true
+		}
+	}
+	return info, nil
+}
+
+func (c *Client) SetTaintConditions(taintKey string, action string) error {
+	c.Lock()
+	defer c.Unlock()
+	if err := c.Errors["SetTaintConditions"]; err != nil {
+		return err
+	}
+	c.record("SetTaintConditions", taintKey, action)
+	if action == "Taint" || action == "SoftTaint" {
+		c.Taints[taintKey] = true
+	} else if action == "UnTaint" {
+		delete(c.Taints, taintKey)
+	}
+	return nil
+}
+
+func (c *Client) GetSummaryStats() (*summary.ConditionStats, error) {
+	c.Lock()
+	defer c.Unlock()
+	if err := c.Errors["GetSummaryStats"]; err != nil {
+		return nil, err
+	}
+	if c.Stats == nil {
+		return nil, fmt.Errorf("no summary stats")
+	}
+	return c.Stats, nil
+}
+
+func (c *Client) EvictOnePod(pod *types.PodInfo) error {
+	c.Lock()
+	defer c.Unlock()
+	if err := c.Errors["EvictOnePod"]; err != nil {
+		return err
+	}
+	c.record("EvictOnePod", podKey(pod), "")
+	for i := range c.Pods {
+		if podKey(&c.Pods[i]) == podKey(pod) {
+			c.Pods = append(c.Pods[:i], c.Pods[i+1:]...)
+			break
+		}
+	}
+	delete(c.EvictLabels, podKey(pod))
+	return nil
+}
+
+func (c *Client) GetLowerPriorityPods(threshold int) ([]types.PodInfo, error) {
+	c.Lock()
+	defer c.Unlock()
+	if err := c.Errors["GetLowerPriorityPods"]; err != nil {
+		return nil, err
+	}
+	var pods []types.PodInfo
+	for _, pod := range c.Pods {
+		if pod.Priority > 0 && pod.Priority <= threshold {
+			pods = append(pods, pod)
+		}
+	}
+	return pods, nil
+}
+
+// GetPods returns all pods, there are no terminated pods
+func (c *Client) GetPods() ([]types.PodInfo, error) {
+	c.Lock()
+	defer c.Unlock()
+	if err := c.Errors["GetPods"]; err != nil {
+		return nil, err
+	}
+	return append([]types.PodInfo(nil), c.Pods...), nil
+}
+
+func (c *Client) CordonNode() error {
+	c.Lock()
+	defer c.Unlock()
+	if err := c.Errors["CordonNode"]; err != nil {
+		return err
+	}
+	c.record("CordonNode", "node", "")
+	c.Unschedulable = true
+	return nil
+}
+
+func (c *Client) GetInfraPods() (map[string]bool, error) {
+	c.Lock()
+	defer c.Unlock()
+	if err := c.Errors["GetInfraPods"]; err != nil {
+		return nil, err
+	}
+	pods := make(map[string]bool, len(c.InfraPods))
+	for key, infra := range c.InfraPods {
+		pods[key] = infra
+	}
+	return pods, nil
+}
+
+func (c *Client) GetCreatingContainers() ([]string, error) {
+	c.Lock()
+	defer c.Unlock()
+	if err := c.Errors["GetCreatingContainers"]; err != nil {
+		return nil, err
+	}
+	return append([]string(nil), c.CreatingContainers...), nil
+}
+
+func (c *Client) GetHostNetworkPods() (map[string]bool, error) {
+	c.Lock()
+	defer c.Unlock()
+	if err := c.Errors["GetHostNetworkPods"]; err != nil {
+		return nil, err
+	}
+	pods := make(map[string]bool, len(c.HostNetworkPods))
+	for key, host := range c.HostNetworkPods {
+		pods[key] = host
+	}
+	return pods, nil
+}
+
+func (c *Client) GetLatencySensitivePods() (map[string]bool, error) {
+	c.Lock()
+	defer c.Unlock()
+	if err := c.Errors["GetLatencySensitivePods"]; err != nil {
+		return nil, err
+	}
+	pods := make(map[string]bool, len(c.LatencySensitivePods))
+	for key, sensitive := range c.LatencySensitivePods {
+		pods[key] = sensitive
+	}
+	return pods, nil
+}
+
+func (c *Client) GetToleratedTaints(taintKeys []string) (map[string][]string, error) {
+	c.Lock()
+	defer c.Unlock()
+	if err := c.Errors["GetToleratedTaints"]; err != nil {
+		return nil, err
+	}
+	pods := make(map[string][]string)
+	for key, tolerated := range c.ToleratedTaints {
+		for _, taintKey := range taintKeys {
+			if contains(tolerated, taintKey) {
+				pods[key] = append(pods[key], taintKey)
+			}
+		}
+	}
+	return pods, nil
+}
+
+func (c *Client) GetPinnedPods() (map[string]string, error) {
+	c.Lock()
+	defer c.Unlock()
+	if err := c.Errors["GetPinnedPods"]; err != nil {
+		return nil, err
+	}
+	pods := make(map[string]string, len(c.PinnedPods))
+	for key, reason := range c.PinnedPods {
+		pods[key] = reason
+	}
+	return pods, nil
+}
+
+func (c *Client) GetStatefulSetPods() (map[string]types.StatefulSetPod, error) {
+	c.Lock()
+	defer c.Unlock()
+	if err := c.Errors["GetStatefulSetPods"]; err != nil {
+		return nil, err
+	}
+	pods := make(map[string]types.StatefulSetPod, len(c.StatefulSetPods))
+	for key, pod := range c.StatefulSetPods {
+		pods[key] = pod
+	}
+	return pods, nil
+}
+
+func (c *Client) GetJobProgress() (map[string]float64, error) {
+	c.Lock()
+	defer c.Unlock()
+	if err := c.Errors["GetJobProgress"]; err != nil {
+		return nil, err
+	}
+	pods := make(map[string]float64, len(c.JobProgress))
+	for key, progress := range c.JobProgress {
+		pods[key] = progress
+	}
+	return pods, nil
+}
+
+func (c *Client) GetIOBursts() (map[string]types.IOBurst, error) {
+	c.Lock()
+	defer c.Unlock()
+	if err := c.Errors["GetIOBursts"]; err != nil {
+		return nil, err
+	}
+	bursts := make(map[string]types.IOBurst, len(c.IOBursts))
+	for key, burst := range c.IOBursts {
+		bursts[key] = burst
+	}
+	return bursts, nil
+}
+
+func (c *Client) GetPodLifecycles() (map[string]types.PodLifecycle, error) {
+	c.Lock()
+	defer c.Unlock()
+	if err := c.Errors["GetPodLifecycles"]; err != nil {
+		return nil, err
+	}
+	lifecycles := make(map[string]types.PodLifecycle, len(c.Lifecycles))
+	for key, lifecycle := range c.Lifecycles {
+		lifecycles[key] = lifecycle
+	}
+	return lifecycles, nil
+}
+
+func (c *Client) GetPodCosts(key string) (map[string]float64, error) {
+	c.Lock()
+	defer c.Unlock()
+	if err := c.Errors["GetPodCosts"]; err != nil {
+		return nil, err
+	}
+	costs := make(map[string]float64, len(c.Costs))
+	for pod, cost := range c.Costs {
+		costs[pod] = cost
+	}
+	return costs, nil
+}
+
+func (c *Client) GetDisruptionBudgets() (map[string]types.DisruptionBudget, error) {
+	c.Lock()
+	defer c.Unlock()
+	if err := c.Errors["GetDisruptionBudgets"]; err != nil {
+		return nil, err
+	}
+	budgets := make(map[string]types.DisruptionBudget, len(c.Budgets))
+	for pod, budget := range c.Budgets {
+		budgets[pod] = budget
+	}
+	return budgets, nil
+}
+
+func (c *Client) GetClusterHeadroom() (*types.ClusterHeadroom, error) {
+	c.Lock()
+	defer c.Unlock()
+	if err := c.Errors["GetClusterHeadroom"]; err != nil {
+		return nil, err
+	}
+	headroom := c.Headroom
+	return &headroom, nil
+}
+
+func (c *Client) LabelPod(pod *types.PodInfo, priority string, evictType string, action string) error {
+	c.Lock()
+	defer c.Unlock()
+	if err := c.Errors["LabelPod"]; err != nil {
+		return err
+	}
+	key := podKey(pod)
+	c.record("LabelPod", key, action+" "+priority+" "+evictType)
+	if action == "Add" {
+		if c.EvictLabels[key] == nil {
+			c.EvictLabels[key] = make(map[string][]string)
+		}
+		if !contains(c.EvictLabels[key][priority], evictType) {
+			c.EvictLabels[key][priority] = append(c.EvictLabels[key][priority], evictType)
+		}
+	} else {
+		// only evictType is removed, like the real client
+		for p, evictTypes := range c.EvictLabels[key] {
+			var left []string
+			for _, t := range evictTypes {
+				if t != evictType {
+					left = append(left, t)
+				}
+			}
+			if len(left) == 0 {
+				delete(c.EvictLabels[key], p)
+			} else {
+				c.EvictLabels[key][p] = left
+			}
+		}
+		if len(c.EvictLabels[key]) == 0 {
+			delete(c.EvictLabels, key)
+		}
+	}
+	return nil
+}
+
+func (c *Client) GetResourcesTotalFromAnnotations() (*types.NodeIOPSTotal, error) {
+	c.Lock()
+	defer c.Unlock()
+	if err := c.Errors["GetResourcesTotalFromAnnotations"]; err != nil {
+		return nil, err
+	}
+	totals := c.Totals
+	return &totals, nil
+}
+
+func (c *Client) ClearAllEvictLabels() error {
+	c.Lock()
+	defer c.Unlock()
+	if err := c.Errors["ClearAllEvictLabels"]; err != nil {
+		return err
+	}
+	for _, key := range sortedKeys(c.EvictLabels) {
+		c.record("ClearAllEvictLabels", key, "")
+	}
+	c.EvictLabels = make(map[string]map[string][]string)
+	return nil
+}
+
+func (c *Client) ClearEvictLabels(evictTypes []string) error {
+	c.Lock()
+	defer c.Unlock()
+	if err := c.Errors["ClearEvictLabels"]; err != nil {
+		return err
+	}
+	for _, key := range sortedKeys(c.EvictLabels) {
+		changed := false
+		for label, labeled := range c.EvictLabels[key] {
+			var left []string
+			for _, evictType := range labeled {
+				if contains(evictTypes, evictType) {
+					changed = true
+				} else {
+					left = append(left, evictType)
+				}
+			}
+			if len(left) == 0 {
+				delete(c.EvictLabels[key], label)
+			} else {
+				c.EvictLabels[key][label] = left
+			}
+		}
+		if len(c.EvictLabels[key]) == 0 {
+			delete(c.EvictLabels, key)
+		}
+		if changed {
+			c.record("ClearEvictLabels", key, strings.Join(evictTypes, ","))
+		}
+	}
+	return nil
+}
+
+func (c *Client) RecordNodeEvent(eventType, reason, message string) {
+	c.Lock()
+	defer c.Unlock()
+	c.record("Event", "node", eventType+" "+reason+": "+message)
+}
+
+func (c *Client) RecordPodEvent(pod *types.PodInfo, eventType, reason, message string) {
+	c.Lock()
+	defer c.Unlock()
+	c.record("Event", "pod "+podKey(pod), eventType+" "+reason+": "+message)
+}
+
+func (c *Client) GetTenantNamespaces(label string) (map[string]string, error) {
+	c.Lock()
+	defer c.Unlock()
+	if err := c.Errors["GetTenantNamespaces"]; err != nil {
+		return nil, err
+	}
+	namespaces := make(map[string]string)
+	for namespace, labels := range c.NamespaceLabels {
+		if value, ok := labels[label]; ok {
+			namespaces[namespace] = value
+		}
+	}
+	return namespaces, nil
+}
+
+func (c *Client) GetNamespaceAnnotations() (map[string]map[string]string, error) {
+	c.Lock()
+	defer c.Unlock()
+	if err := c.Errors["GetNamespaceAnnotations"]; err != nil {
+		return nil, err
+	}
+	namespaces := make(map[string]map[string]string)
+	for namespace, annotations := range c.NamespaceAnnotations {
+		namespaces[namespace] = copyMap(annotations)
+	}
+	return namespaces, nil
+}
+
+func (c *Client) GetNodeLabels() (map[string]string, error) {
+	c.Lock()
+	defer c.Unlock()
+	if err := c.Errors["GetNodeLabels"]; err != nil {
+		return nil, err
+	}
+	return copyMap(c.Labels), nil
+}
+
+func (c *Client) GetNodeAnnotations() (map[string]string, error) {
+	c.Lock()
+	defer c.Unlock()
+	if err := c.Errors["GetNodeAnnotations"]; err != nil {
+		return nil, err
+	}
+	return copyMap(c.Annotations), nil
+}
+
+func (c *Client) SetNodeCondition(conditionType string, status bool, reason, message string) error {
+	c.Lock()
+	defer c.Unlock()
+	if err := c.Errors["SetNodeCondition"]; err != nil {
+		return err
+	}
+	if c.Problems[conditionType] == status {
+		return nil
+	}
+	c.record("SetNodeCondition", conditionType, fmt.Sprintf("%v %s: %s", status, reason, message))
+	c.Problems[conditionType] = status
+	return nil
+}
+
+func (c *Client) GetNodeAllocatable() (map[string]float64, error) {
+	c.Lock()
+	defer c.Unlock()
+	if err := c.Errors["GetNodeAllocatable"]; err != nil {
+		return nil, err
+	}
+	allocatable := make(map[string]float64, len(c.Allocatable))
+	for resource, value := range c.Allocatable {
+		allocatable[resource] = value
+	}
+	return allocatable, nil
+}
+
+func (c *Client) AnnotateNode(annotations map[string]string) error {
+	c.Lock()
+	defer c.Unlock()
+	if err := c.Errors["AnnotateNode"]; err != nil {
+		return err
+	}
+	for _, key := range sortedKeys(annotations) {
+		c.record("AnnotateNode", key, annotations[key])
+		if annotations[key] == "" {
+			delete(c.Annotations, key)
+		} else {
+			c.Annotations[key] = annotations[key]
+		}
+	}
+	return nil
+}
+
+func (c *Client) GetPodResources(pod *types.PodInfo) ([]types.ContainerResources, error) {
+	c.Lock()
+	defer c.Unlock()
+	if err := c.Errors["GetPodResources"]; err != nil {
+		return nil, err
+	}
+	return append([]types.ContainerResources(nil), c.Resources[podKey(pod)]...), nil
+}
+
+func (c *Client) ResizePod(pod *types.PodInfo, containers []types.ContainerResources) error {
+	c.Lock()
+	defer c.Unlock()
+	if err := c.Errors["ResizePod"]; err != nil {
+		return err
+	}
+	c.record("ResizePod", podKey(pod), fmt.Sprintf("%+v", containers))
+	c.Resources[podKey(pod)] = append([]types.ContainerResources(nil), containers...)
+	return nil
+}
+
+func (c *Client) GetPodOwner(pod *types.PodInfo) (string, error) {
+	c.Lock()
+	defer c.Unlock()
+	if err := c.Errors["GetPodOwner"]; err != nil {
+		return "", err
+	}
+	if workload := c.Workloads[podKey(pod)]; workload != nil {
+		return workload.Kind + "/" + workload.Name, nil
+	}
+	return "", nil
+}
+
+func (c *Client) GetPodProfiles() (map[string]types.PodProfile, error) {
+	c.Lock()
+	defer c.Unlock()
+	if err := c.Errors["GetPodProfiles"]; err != nil {
+		return nil, err
+	}
+	profiles := make(map[string]types.PodProfile, len(c.Profiles))
+	for key, profile := range c.Profiles {
+		profiles[key] = profile
+	}
+	return profiles, nil
+}
+
+func (c *Client) GetPodWorkload(pod *types.PodInfo) (*types.Workload, error) {
+	c.Lock()
+	defer c.Unlock()
+	if err := c.Errors["GetPodWorkload"]; err != nil {
+		return nil, err
+	}
+	if workload := c.Workloads[podKey(pod)]; workload != nil {
+		w := *workload
+		return &w, nil
+	}
+	return nil, nil
+}
+
+func (c *Client) GetPodController(pod *types.PodInfo) (*types.Workload, error) {
+	c.Lock()
+	defer c.Unlock()
+	if err := c.Errors["GetPodController"]; err != nil {
+		return nil, err
+	}
+	if controller := c.Controllers[podKey(pod)]; controller != nil {
+		w := *controller
+		return &w, nil
+	}
+	return nil, nil
+}
+
+// GetReplacementPod returns the replacement of controller regardless of since
+func (c *Client) GetReplacementPod(controller *types.Workload, since time.Time) (*types.Replacement, error) {
+	c.Lock()
+	defer c.Unlock()
+	if err := c.Errors["GetReplacementPod"]; err != nil {
+		return nil, err
+	}
+	if replacement := c.Replacements[controller.UID]; replacement != nil {
+		r := *replacement
+		return &r, nil
+	}
+	return nil, nil
+}
+
+func (c *Client) UpdateWorkloadMetadata(workload *types.Workload,
+	update func(labels, annotations map[string]string) bool) error {
+	c.Lock()
+	defer c.Unlock()
+	if err := c.Errors["UpdateWorkloadMetadata"]; err != nil {
+		return err
+	}
+	key := workloadKey(workload)
+	labels, annotations := copyMap(c.WorkloadLabels[key]), copyMap(c.WorkloadAnnotations[key])
+	if !update(labels, annotations) {
+		return nil
+	}
+	c.record("UpdateWorkloadMetadata", key, "")
+	c.WorkloadLabels[key], c.WorkloadAnnotations[key] = labels, annotations
+	return nil
+}
+
+func (c *Client) RecordWorkloadEvent(workload *types.Workload, eventType, reason, message string) {
+	c.Lock()
+	defer c.Unlock()
+	c.record("Event", "workload "+workloadKey(workload), eventType+" "+reason+": "+message)
+}
+
+func (c *Client) ListEvictionPolicies() (*v1alpha1.EvictionPolicyList, error) {
+	c.Lock()
+	defer c.Unlock()
+	if err := c.Errors["ListEvictionPolicies"]; err != nil {
+		return nil, err
+	}
+	policies := c.Policies
+	policies.Items = append([]v1alpha1.EvictionPolicy(nil), c.Policies.Items...)
+	return &policies, nil
+}
+
+func (c *Client) WatchEvictionPolicies(resourceVersion string) (evictionclient.PolicyWatch, error) {
+	c.Lock()
+	defer c.Unlock()
+	if err := c.Errors["WatchEvictionPolicies"]; err != nil {
+		return nil, err
+	}
+	w := &policyWatch{result: make(chan evictionclient.PolicyEvent), done: make(chan struct{})}
+	c.policyWatches = append(c.policyWatches, w)
+	return w, nil
+}
+
+// UpdatePolicy applies a change of policy to Policies by its name and sends it
+// to watches of policies, e.g. watch.Modified
+func (c *Client) UpdatePolicy(eventType watch.EventType, policy v1alpha1.EvictionPolicy) {
+	c.Lock()
+	var items []v1alpha1.EvictionPolicy
+	for _, item := range c.Policies.Items {
+		if item.Name != policy.Name {
+			items = append(items, item)
+		}
+	}
+	if eventType != watch.Deleted {
+		items = append(items, policy)
+	}
+	c.Policies.Items = items
+	c.Policies.ResourceVersion = policy.ResourceVersion
+	var watches []*policyWatch
+	for _, w := range c.policyWatches {
+		if !w.stopped() {
+			watches = append(watches, w)
+		}
+	}
+	c.policyWatches = watches
+	c.Unlock()
+	for _, w := range watches {
+		w.send(evictionclient.PolicyEvent{Type: eventType, Policy: policy})
+	}
+}
+
+// CloseWatches ends watches of policies with err, e.g. an expired resource version
+func (c *Client) CloseWatches(err error) {
+	c.Lock()
+	watches := c.policyWatches
+	c.policyWatches = nil
+	c.Unlock()
+	for _, w := range watches {
+		w.close(err)
+	}
+}
+
+// policyWatch is a watch of policies ended by Stop or CloseWatches
+type policyWatch struct {
+	lock   sync.Mutex // held by send, result is closed with it held
+	result chan evictionclient.PolicyEvent
+	done   chan struct{}
+	once   sync.Once
+	err    error
+}
+
+func (w *policyWatch) ResultChan() <-chan evictionclient.PolicyEvent {
+	return w.result
+}
+
+func (w *policyWatch) Err() error {
+	return w.err
+}
+
+func (w *policyWatch) Stop() {
+	w.close(nil)
+}
+
+func (w *policyWatch) close(err error) {
+	w.once.Do(func() {
+		w.err = err
+		close(w.done)
+		w.lock.Lock()
+		close(w.result)
+		w.lock.Unlock()
+	})
+}
+
+// send blocks until event is received or the watch ends
+func (w *policyWatch) send(event evictionclient.PolicyEvent) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.stopped() {
+		return
+	}
+	select {
+	case w.result <- event:
+	case <-w.done:
+	}
+}
+
+func (w *policyWatch) stopped() bool {
+	select {
+	case <-w.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *Client) UpdateNodeEvictionStatus(namespace string, status *v1alpha1.AgentStatus) error {
+	c.Lock()
+	defer c.Unlock()
+	if err := c.Errors["UpdateNodeEvictionStatus"]; err != nil {
+		return err
+	}
+	c.Statuses[namespace] = *status
+	return nil
+}
+
+func (c *Client) GetConfigMap(namespace, name string) (map[string]string, error) {
+	c.Lock()
+	defer c.Unlock()
+	if err := c.Errors["GetConfigMap"]; err != nil {
+		return nil, err
+	}
+	data, ok := c.ConfigMaps[namespace+"/"+name]
+	if !ok {
+		return nil, nil
+	}
+	copied := make(map[string]string, len(data))
+	for key, value := range data {
+		copied[key] = value
+	}
+	return copied, nil
+}
+
+func (c *Client) UpdateConfigMap(namespace, name string, data map[string]string) error {
+	c.Lock()
+	defer c.Unlock()
+	if err := c.Errors["UpdateConfigMap"]; err != nil {
+		return err
+	}
+	copied := make(map[string]string, len(data))
+	for key, value := range data {
+		copied[key] = value
+	}
+	c.ConfigMaps[namespace+"/"+name] = copied
+	return nil
+}
+
+// BeginCycle does nothing, there is nothing to cache
+func (c *Client) BeginCycle() {}
+
+// EndCycle does nothing
+func (c *Client) EndCycle() {}
+
+// WithContext returns c, requests are not sent
+func (c *Client) WithContext(ctx context.Context) evictionclient.Client {
+	return c
+}
+
+func (c *Client) CheckPermissions(permissions []evictionclient.Permission) ([]evictionclient.Permission, error) {
+	c.Lock()
+	defer c.Unlock()
+	if err := c.Errors["CheckPermissions"]; err != nil {
+		return nil, err
+	}
+	var denied []evictionclient.Permission
+	for _, permission := range permissions {
+		if c.Denied[permission.String()] {
+			denied = append(denied, permission)
+		}
+	}
+	return denied, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func copyMap(m map[string]string) map[string]string {
+	copied := make(map[string]string, len(m))
+	for key, value := range m {
+		copied[key] = value
+	}
+	return copied
+}
+
+// sortedKeys returns keys of a map keyed by string in order
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	switch m := m.(type) {
+	case map[string]string:
+		for key := range m {
+			keys = append(keys, key)
+		}
+	case map[string]map[string][]string:
+		for key := range m {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
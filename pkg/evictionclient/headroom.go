@@ -0,0 +1,62 @@
+package evictionclient
+
+import (
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/types"
+)
+
+// GetClusterHeadroom return the spare capacity of ready and schedulable nodes
+// other than current node, allocatable minus requests of their pods not
+// terminated. Nodes and pods are listed from the cache of api server.
+func (c *evictionClient) GetClusterHeadroom() (*types.ClusterHeadroom, error) {
+	cached := metav1.ListOptions{ResourceVersion: "0"}
+	nodeList, err := c.client.CoreV1().Nodes().List(cached)
+	if err != nil {
+		log.Errorf("List nodes error %v", err)
+		return nil, err
+	}
+	headroom := &types.ClusterHeadroom{}
+	schedulable := make(map[string]bool)
+	for i := range nodeList.Items {
+		node := &nodeList.Items[i]
+		if node.Name == c.nodeName || node.Spec.Unschedulable || !nodeReady(node) {
+			continue
+		}
+		schedulable[node.Name] = true
+		headroom.CPU += float64(node.Status.Allocatable.Cpu().MilliValue()) / 1000
+		headroom.Memory += float64(node.Status.Allocatable.Memory().Value())
+		headroom.Pods += int(node.Status.Allocatable.Pods().Value())
+	}
+	podList, err := c.client.CoreV1().Pods(metav1.NamespaceAll).List(metav1.ListOptions{
+		ResourceVersion: "0",
+		FieldSelector:   "status.phase!=" + string(v1.PodSucceeded) + ",status.phase!=" + string(v1.PodFailed),
+	})
+	if err != nil {
+		log.Errorf("List pods of cluster error %v", err)
+		return nil, err
+	}
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if !schedulable[pod.Spec.NodeName] {
+			continue
+		}
+		for _, container := range pod.Spec.Containers {
+			headroom.CPU -= float64(container.Resources.Requests.Cpu().MilliValue()) / 1000
+			headroom.Memory -= float64(container.Resources.Requests.Memory().Value())
+		}
+		headroom.Pods--
+	}
+	return headroom, nil
+}
+
+func nodeReady(node *v1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == v1.NodeReady {
+			return condition.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
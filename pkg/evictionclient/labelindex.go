@@ -0,0 +1,198 @@
+package evictionclient
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+
+	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/metrics"
+	"eviction-agent/pkg/types"
+)
+
+var (
+	labeledPods = metrics.NewGaugeVec("eviction_agent_labeled_pods",
+		"Number of pods on node with evict marks added by agent.")
+)
+
+// labelIndex is the set of pods marked by agent keyed by namespace/name. It's
+// kept in memory and in LabeledPodsAnnotation of node, so that marks are
+// cleared from these pods only instead of listing and patching pods on node.
+type labelIndex struct {
+	lock sync.Mutex
+	// loaded is true once the index is read from node
+	loaded bool
+	pods   map[string]bool
+	// dirty is true if pods are changed since they're saved on node
+	dirty bool
+}
+
+func newLabelIndex() *labelIndex {
+	return &labelIndex{pods: make(map[string]bool)}
+}
+
+// set adds or removes pod of key, returns true if the index is changed
+func (i *labelIndex) set(key string, marked bool) bool {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	if i.pods[key] == marked {
+		return false
+	}
+	if marked {
+		i.pods[key] = true
+	} else {
+		delete(i.pods, key)
+	}
+	i.dirty = true
+	labeledPods.Set(float64(len(i.pods)))
+	return true
+}
+
+// keys returns sorted keys of pods of the index
+func (i *labelIndex) keys() []string {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	keys := make([]string, 0, len(i.pods))
+	for key := range i.pods {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// loadLabelIndex reads the index from node once, pods in memory are kept.
+// Node without the index may have marks of agents before it, they're found
+// by listing marked pods once.
+func (c *evictionClient) loadLabelIndex() error {
+	c.labels.lock.Lock()
+	loaded := c.labels.loaded
+	c.labels.lock.Unlock()
+	if loaded {
+		return nil
+	}
+	node, err := c.getNode()
+	if err != nil {
+		log.Errorf("get labeled pods of node error %v", err)
+		return err
+	}
+	var keys []string
+	if value, ok := node.Annotations[types.LabeledPodsAnnotation]; ok {
+		if value != "" {
+			keys = strings.Split(value, ",")
+		}
+	} else {
+		pods, err := c.listEvictLabeledPods()
+		if err != nil {
+			return err
+		}
+		for _, pod := range pods {
+			keys = append(keys, pod.Namespace+"/"+pod.Name)
+		}
+		log.Infof("Node has no index of labeled pods, %d marked pods are found", len(keys))
+	}
+	c.labels.lock.Lock()
+	for _, key := range keys {
+		c.labels.pods[key] = true
+	}
+	// the index is created on node if it's not there
+	_, ok := node.Annotations[types.LabeledPodsAnnotation]
+	c.labels.dirty = c.labels.dirty || !ok
+	c.labels.loaded = true
+	labeledPods.Set(float64(len(c.labels.pods)))
+	c.labels.lock.Unlock()
+	return nil
+}
+
+// saveLabelIndex saves the index on node if it's changed, an empty index
+// is kept as an empty annotation so that it's not looked for again
+func (c *evictionClient) saveLabelIndex() error {
+	c.labels.lock.Lock()
+	if !c.labels.dirty || !c.labels.loaded {
+		c.labels.lock.Unlock()
+		return nil
+	}
+	c.labels.dirty = false
+	c.labels.lock.Unlock()
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{types.LabeledPodsAnnotation: strings.Join(c.labels.keys(), ",")},
+		},
+	})
+	if err == nil {
+		err = c.retry("Annotate", "node annotations "+types.LabeledPodsAnnotation, func() error {
+			_, err := c.client.CoreV1().Nodes().Patch(c.nodeName, k8stypes.MergePatchType, patch)
+			c.updateNode(nil)
+			return err
+		})
+	}
+	if err != nil {
+		c.labels.lock.Lock()
+		c.labels.dirty = true
+		c.labels.lock.Unlock()
+	}
+	return err
+}
+
+// indexedPods returns pods of the index which still have evict marks, pods
+// gone or cleared by others are removed from the index. Pods are of the cycle
+// if it's in one, or else got one by one.
+func (c *evictionClient) indexedPods() ([]v1.Pod, error) {
+	keys := c.labels.keys()
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	byKey := make(map[string]*v1.Pod, len(keys))
+	if c.inCycle() {
+		var podList []v1.Pod
+		err := c.retry("List", "pods", func() error {
+			var err error
+			podList, err = c.listPods()
+			return err
+		})
+		if err != nil {
+			log.Errorf("List pods on %s error", c.nodeName)
+			return nil, err
+		}
+		for i := range podList {
+			byKey[podList[i].Namespace+"/"+podList[i].Name] = &podList[i]
+		}
+	} else {
+		for _, key := range keys {
+			parts := strings.SplitN(key, "/", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			var pod *v1.Pod
+			err := c.retry("Get", "pod "+key, func() error {
+				var err error
+				pod, err = c.client.CoreV1().Pods(parts[0]).Get(parts[1], metav1.GetOptions{})
+				if apierrors.IsNotFound(err) {
+					pod, err = nil, nil
+				}
+				return err
+			})
+			if err != nil {
+				return nil, err
+			}
+			if pod != nil && pod.Spec.NodeName == c.nodeName {
+				byKey[key] = pod
+			}
+		}
+	}
+	var pods []v1.Pod
+	for _, key := range keys {
+		pod, ok := byKey[key]
+		if !ok || !c.marker.marked(pod) {
+			c.labels.set(key, false)
+			continue
+		}
+		pods = append(pods, *pod)
+	}
+	return pods, nil
+}
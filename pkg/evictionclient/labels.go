@@ -0,0 +1,352 @@
+package evictionclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/types"
+)
+
+// maxLabelValueLength is the max length of label values
+const maxLabelValueLength = 63
+
+// evictPriorities are the marks added on pods by agent
+var evictPriorities = []string{types.EvictCandidate, types.NeedEvict}
+
+// evictMarker marks pods to evict by labels or annotations, the key is prefix
+// and priority, the value is priority and evict types of pod joined by dots
+type evictMarker struct {
+	annotation bool
+	prefix     string
+}
+
+func (m evictMarker) key(priority string) string {
+	return m.prefix + priority
+}
+
+// marks returns labels or annotations of pod holding the marks
+func (m evictMarker) marks(pod *v1.Pod) map[string]string {
+	if m.annotation {
+		return pod.Annotations
+	}
+	return pod.Labels
+}
+
+// value returns the mark of priority for evictTypes, label values have
+// invalid characters replaced and are truncated
+func (m evictMarker) value(priority string, evictTypes []string) string {
+	value := strings.Join(append([]string{priority}, evictTypes...), ".")
+	if m.annotation {
+		return value
+	}
+	value = strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.' {
+			return r
+		}
+		return '_'
+	}, value)
+	if len(value) > maxLabelValueLength {
+		value = value[:maxLabelValueLength]
+	}
+	return strings.TrimRight(value, "-_.")
+}
+
+// add adds the mark of priority on pod, marks are set for its current evict types
+func (m evictMarker) add(pod *v1.Pod, priority string) {
+	m.marks(pod)[m.key(priority)] = ""
+	m.update(pod)
+}
+
+// marked returns true if pod has any evict mark
+func (m evictMarker) marked(pod *v1.Pod) bool {
+	marks := m.marks(pod)
+	for _, priority := range evictPriorities {
+		if _, ok := marks[m.key(priority)]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// update sets marks of pod for its current evict types, they are removed if
+// there is no evict type left
+func (m evictMarker) update(pod *v1.Pod) {
+	marks := m.marks(pod)
+	evictTypes := getEvictTypes(pod)
+	for _, priority := range evictPriorities {
+		if _, ok := marks[m.key(priority)]; !ok {
+			continue
+		}
+		if len(evictTypes) == 0 {
+			delete(marks, m.key(priority))
+		} else {
+			marks[m.key(priority)] = m.value(priority, evictTypes)
+		}
+	}
+}
+
+// LabelPod add or delete an evict mark on pod, retry on transient errors.
+// The evict types marking pod are kept in EvictTypesAnnotation, and marked
+// pods in the index of node. Delete removes only evictType like clearPod, the
+// marks are removed if no evict type is left.
+func (c *evictionClient) LabelPod(podInfo *types.PodInfo, priority string, evictType string, action string) error {
+	if podInfo.Name == "" {
+		return fmt.Errorf("pod name should not be empty")
+	}
+	if err := c.loadLabelIndex(); err != nil {
+		log.Warnf("load index of labeled pods error, it's loaded later: %v", err)
+	}
+	key := podInfo.Namespace + "/" + podInfo.Name
+	var marked bool
+	err := c.retry(action+"Label", "pod "+key, func() error {
+		return c.patchPod(podInfo.Namespace, podInfo.Name, func(pod *v1.Pod) {
+			if action == "Add" {
+				setEvictTypes(pod, append(getEvictTypes(pod), evictType))
+				c.marker.add(pod, priority)
+				if podInfo.Container != "" {
+					pod.Annotations[types.OffenderContainerAnnotation] = podInfo.Container
+				}
+			} else if action == "Delete" {
+				var left []string
+				for _, t := range getEvictTypes(pod) {
+					if t != evictType {
+						left = append(left, t)
+					}
+				}
+				setEvictTypes(pod, left)
+				c.marker.update(pod)
+				if !c.marker.marked(pod) {
+					delete(pod.Annotations, types.OffenderContainerAnnotation)
+				}
+			}
+			marked = c.marker.marked(pod)
+		})
+	})
+	if err != nil {
+		return err
+	}
+	if c.labels.set(key, marked) {
+		if err := c.saveLabelIndex(); err != nil {
+			log.Warnf("save index of labeled pods error, it's saved later: %v", err)
+		}
+	}
+	return nil
+}
+
+// patchPod gets pod, changes it by mutate and patches the difference
+func (c *evictionClient) patchPod(namespace, name string, mutate func(pod *v1.Pod)) error {
+	oldPod, err := c.client.CoreV1().Pods(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		log.Errorf("get pod %s error", name)
+		return err
+	}
+	oldData, err := json.Marshal(oldPod)
+	if err != nil {
+		return fmt.Errorf("failed to marshal old pod %v : %v", name, err)
+	}
+	newPod := oldPod.DeepCopy()
+	if newPod.Labels == nil {
+		newPod.Labels = make(map[string]string)
+	}
+	if newPod.Annotations == nil {
+		newPod.Annotations = make(map[string]string)
+	}
+	mutate(newPod)
+
+	newData, err := json.Marshal(newPod)
+	if err != nil {
+		return fmt.Errorf("failed to marshal new pod %v : %v", name, err)
+	}
+	patchBytes, err := strategicpatch.CreateTwoWayMergePatch(oldData, newData, v1.Pod{})
+	if err != nil {
+		return fmt.Errorf("failed to create patch for pod %v", name)
+	}
+	_, err = c.client.CoreV1().Pods(namespace).Patch(name, k8stypes.StrategicMergePatchType, patchBytes)
+	c.invalidatePods()
+	if err != nil {
+		return err
+	}
+	log.Infof("Patch pod: %v labels: %v", name, newPod.Labels)
+	return nil
+}
+
+// listEvictLabeledPods lists pods on node with any evict mark
+func (c *evictionClient) listEvictLabeledPods() ([]v1.Pod, error) {
+	var pods []v1.Pod
+	if c.inCycle() || c.marker.annotation {
+		// filter pods on node shared in the cycle, annotations can't be selected
+		var podList []v1.Pod
+		err := c.retry("List", "pods", func() error {
+			var err error
+			podList, err = c.listPods()
+			return err
+		})
+		if err != nil {
+			log.Errorf("List pods on %s error", c.nodeName)
+			return nil, err
+		}
+		for _, pod := range podList {
+			if c.marker.marked(&pod) {
+				pods = append(pods, pod)
+			}
+		}
+		return pods, nil
+	}
+	seen := make(map[string]bool)
+	for _, priority := range evictPriorities {
+		// only list pods with evict label, instead of all pods on node
+		options := metav1.ListOptions{
+			FieldSelector: fmt.Sprintf("spec.nodeName=%s", c.nodeName),
+			LabelSelector: c.marker.key(priority),
+		}
+		var podLists *v1.PodList
+		err := c.retry("List", "pods", func() error {
+			var err error
+			podLists, err = c.client.CoreV1().Pods(metav1.NamespaceAll).List(options)
+			return err
+		})
+		if err != nil {
+			log.Errorf("List pods on %s error", c.nodeName)
+			return nil, err
+		}
+		for _, pod := range podLists.Items {
+			key := pod.Namespace + "/" + pod.Name
+			if !seen[key] {
+				seen[key] = true
+				pods = append(pods, pod)
+			}
+		}
+	}
+	return pods, nil
+}
+
+// ClearAllEvictLabels clear evict labels from pods labeled by agent if node is
+// not in bad condition, pods of the index are cleared only. Api calls are
+// limited by clearLabelsBudget, labels left are cleared in next calls. It
+// does nothing if no pod is labeled.
+func (c *evictionClient) ClearAllEvictLabels() error {
+	return c.clearLabels(nil)
+}
+
+// ClearEvictLabels removes evictTypes from pods labeled for them, evict labels
+// are removed from pods not labeled for other evict types. Pods left by
+// clearLabelsBudget, or labeled without evict types, are cleared by ClearAllEvictLabels.
+func (c *evictionClient) ClearEvictLabels(evictTypes []string) error {
+	return c.clearLabels(evictTypes)
+}
+
+// clearLabels removes evictTypes from pods of the index, or all evict types if
+// evictTypes is nil. Clearing stops when clearLabelsBudget is exhausted or api
+// server throttles, the rest are left for next calls.
+func (c *evictionClient) clearLabels(evictTypes []string) error {
+	if err := c.loadLabelIndex(); err != nil {
+		return err
+	}
+	pods, err := c.indexedPods()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for i, pod := range pods {
+		if evictTypes != nil && !containsAny(getEvictTypes(&pod), evictTypes) {
+			continue
+		}
+		if !c.clearLabelsBudget.Allow() {
+			log.Infof("Clear labels budget is exhausted, %d pods are left for next time", len(pods)-i)
+			break
+		}
+		marked, err := c.clearPod(&pod, evictTypes)
+		if err != nil {
+			errs = append(errs, err)
+			if apierrors.IsTooManyRequests(err) {
+				log.Infof("Api server throttles clearing labels, %d pods are left for next time", len(pods)-i-1)
+				break
+			}
+			continue
+		}
+		c.labels.set(pod.Namespace+"/"+pod.Name, marked)
+	}
+	if err := c.saveLabelIndex(); err != nil {
+		errs = append(errs, err)
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// clearPod removes evictTypes from pod, and evict labels if no evict type is
+// left. All evict types are removed if evictTypes is nil. Returns true if pod
+// still has evict marks.
+func (c *evictionClient) clearPod(pod *v1.Pod, evictTypes []string) (bool, error) {
+	marked := false
+	err := c.retry("DeleteLabel", "pod "+pod.Namespace+"/"+pod.Name, func() error {
+		return c.patchPod(pod.Namespace, pod.Name, func(pod *v1.Pod) {
+			var left []string
+			if evictTypes != nil {
+				for _, evictType := range getEvictTypes(pod) {
+					if !containsAny([]string{evictType}, evictTypes) {
+						left = append(left, evictType)
+					}
+				}
+			}
+			setEvictTypes(pod, left)
+			c.marker.update(pod)
+			marked = c.marker.marked(pod)
+			if !marked {
+				delete(pod.Annotations, types.OffenderContainerAnnotation)
+			}
+		})
+	})
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	return marked, err
+}
+
+// getEvictTypes returns the evict types in annotation of pod
+func getEvictTypes(pod *v1.Pod) []string {
+	value := pod.Annotations[types.EvictTypesAnnotation]
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+// setEvictTypes sets the sorted and deduplicated evict types in annotation
+// of pod, the annotation is deleted if evictTypes is empty
+func setEvictTypes(pod *v1.Pod, evictTypes []string) {
+	set := make(map[string]bool)
+	var unique []string
+	for _, evictType := range evictTypes {
+		if evictType != "" && !set[evictType] {
+			set[evictType] = true
+			unique = append(unique, evictType)
+		}
+	}
+	if len(unique) == 0 {
+		delete(pod.Annotations, types.EvictTypesAnnotation)
+		return
+	}
+	sort.Strings(unique)
+	pod.Annotations[types.EvictTypesAnnotation] = strings.Join(unique, ",")
+}
+
+func containsAny(list []string, items []string) bool {
+	for _, a := range list {
+		for _, b := range items {
+			if a == b {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,182 @@
+package evictionclient
+
+import (
+	"fmt"
+	"strconv"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"eviction-agent/pkg/log"
+)
+
+// GetPinnedPods return why pods on current node can't be scheduled on any
+// other node keyed by namespace/name, by node selector and required node
+// affinity of pod, or node affinity of persistent volumes of pod, e.g. local
+// volumes. Only node labels are matched, taints and capacity of other nodes
+// are not. Nodes are listed only if there are pods with such constraints.
+func (c *evictionClient) GetPinnedPods() (map[string]string, error) {
+	podList, err := c.listPods()
+	if err != nil {
+		log.Errorf("List pods on %s error %v", c.nodeName, err)
+		return nil, err
+	}
+	var nodes []v1.Node
+	listNodes := func() ([]v1.Node, error) {
+		if nodes == nil {
+			nodeList, err := c.client.CoreV1().Nodes().List(metav1.ListOptions{})
+			if err != nil {
+				return nil, err
+			}
+			nodes = nodeList.Items
+		}
+		return nodes, nil
+	}
+	pinned := make(map[string]string)
+	for i := range podList {
+		pod := &podList[i]
+		if reason, err := c.pinnedReason(pod, listNodes); err != nil {
+			log.Errorf("check placement of pod %s/%s error %v", pod.Namespace, pod.Name, err)
+		} else if reason != "" {
+			pinned[pod.Namespace+"/"+pod.Name] = reason
+		}
+	}
+	return pinned, nil
+}
+
+// pinnedReason returns why pod can only be scheduled on current node, empty
+// if it can be scheduled elsewhere
+func (c *evictionClient) pinnedReason(pod *v1.Pod, listNodes func() ([]v1.Node, error)) (string, error) {
+	var required *v1.NodeSelector
+	if affinity := pod.Spec.Affinity; affinity != nil && affinity.NodeAffinity != nil {
+		required = affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+	}
+	if len(pod.Spec.NodeSelector) != 0 || required != nil {
+		nodes, err := listNodes()
+		if err != nil {
+			return "", err
+		}
+		if !c.schedulableElsewhere(nodes, pod.Spec.NodeSelector, required) {
+			return "node selector or node affinity matches only this node", nil
+		}
+	}
+	for _, volume := range pod.Spec.Volumes {
+		if volume.PersistentVolumeClaim == nil {
+			continue
+		}
+		claim, err := c.client.CoreV1().PersistentVolumeClaims(pod.Namespace).Get(
+			volume.PersistentVolumeClaim.ClaimName, metav1.GetOptions{})
+		if err != nil {
+			return "", err
+		}
+		if claim.Spec.VolumeName == "" {
+			continue
+		}
+		pv, err := c.client.CoreV1().PersistentVolumes().Get(claim.Spec.VolumeName, metav1.GetOptions{})
+		if err != nil {
+			return "", err
+		}
+		if pv.Spec.NodeAffinity == nil || pv.Spec.NodeAffinity.Required == nil {
+			continue
+		}
+		nodes, err := listNodes()
+		if err != nil {
+			return "", err
+		}
+		if !c.schedulableElsewhere(nodes, nil, pv.Spec.NodeAffinity.Required) {
+			return fmt.Sprintf("volume %s is bound to persistent volume %s of this node", volume.Name, pv.Name), nil
+		}
+	}
+	return "", nil
+}
+
+// schedulableElsewhere returns true if any node other than current node
+// matches selector and required
+func (c *evictionClient) schedulableElsewhere(nodes []v1.Node, selector map[string]string, required *v1.NodeSelector) bool {
+	for i := range nodes {
+		node := &nodes[i]
+		if node.Name != c.nodeName && matchesNodeSelector(node, selector) && matchesNodeAffinity(node, required) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesNodeSelector(node *v1.Node, selector map[string]string) bool {
+	for key, value := range selector {
+		if v, ok := node.Labels[key]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesNodeAffinity returns true if node matches any term of required,
+// nil matches all nodes and empty terms match none
+func matchesNodeAffinity(node *v1.Node, required *v1.NodeSelector) bool {
+	if required == nil {
+		return true
+	}
+	fields := map[string]string{"metadata.name": node.Name}
+	for _, term := range required.NodeSelectorTerms {
+		if len(term.MatchExpressions) == 0 && len(term.MatchFields) == 0 {
+			continue
+		}
+		if matchesRequirements(node.Labels, term.MatchExpressions) && matchesRequirements(fields, term.MatchFields) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesRequirements(labels map[string]string, requirements []v1.NodeSelectorRequirement) bool {
+	for _, requirement := range requirements {
+		value, ok := labels[requirement.Key]
+		switch requirement.Operator {
+		case v1.NodeSelectorOpIn:
+			if !ok || !containsValue(requirement.Values, value) {
+				return false
+			}
+		case v1.NodeSelectorOpNotIn:
+			if ok && containsValue(requirement.Values, value) {
+				return false
+			}
+		case v1.NodeSelectorOpExists:
+			if !ok {
+				return false
+			}
+		case v1.NodeSelectorOpDoesNotExist:
+			if ok {
+				return false
+			}
+		case v1.NodeSelectorOpGt, v1.NodeSelectorOpLt:
+			if !ok || len(requirement.Values) != 1 {
+				return false
+			}
+			have, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return false
+			}
+			want, err := strconv.ParseInt(requirement.Values[0], 10, 64)
+			if err != nil {
+				return false
+			}
+			if requirement.Operator == v1.NodeSelectorOpGt && have <= want ||
+				requirement.Operator == v1.NodeSelectorOpLt && have >= want {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func containsValue(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,133 @@
+package evictionclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+
+	"eviction-agent/pkg/apis/v1alpha1"
+	"eviction-agent/pkg/log"
+)
+
+// policyWatchTimeoutSeconds is the timeout of a watch of EvictionPolicy, it's
+// watched again from the last resource version after that
+const policyWatchTimeoutSeconds = 600
+
+// PolicyEvent is a change of an EvictionPolicy custom resource
+type PolicyEvent struct {
+	// Type is one of Added, Modified and Deleted
+	Type   watch.EventType
+	Policy v1alpha1.EvictionPolicy
+}
+
+// PolicyWatch is a watch of EvictionPolicy custom resources
+type PolicyWatch interface {
+	// ResultChan returns changes of policies, it's closed when the watch ends
+	ResultChan() <-chan PolicyEvent
+	// Err returns why the watch ends once ResultChan is closed, nil if it's
+	// stopped or times out, e.g. an expired resource version
+	Err() error
+	// Stop ends the watch
+	Stop()
+}
+
+// WatchEvictionPolicies watch changes of EvictionPolicy custom resources after resourceVersion
+func (c *evictionClient) WatchEvictionPolicies(resourceVersion string) (PolicyWatch, error) {
+	stream, err := c.client.CoreV1().RESTClient().Get().
+		AbsPath("/apis", v1alpha1.GroupName, v1alpha1.Version, v1alpha1.EvictionPolicyPlural).
+		Param("watch", "true").
+		Param("resourceVersion", resourceVersion).
+		Param("timeoutSeconds", strconv.Itoa(policyWatchTimeoutSeconds)).
+		Stream()
+	if err != nil {
+		log.Errorf("watch eviction policies error %v", err)
+		return nil, err
+	}
+	w := &policyWatch{
+		stream: stream,
+		result: make(chan PolicyEvent),
+		done:   make(chan struct{}),
+	}
+	go w.receive()
+	return w, nil
+}
+
+// policyWatch decodes watch events of EvictionPolicy from the stream of api server
+type policyWatch struct {
+	stream io.ReadCloser
+	result chan PolicyEvent
+	done   chan struct{}
+	once   sync.Once
+	err    error // set before result is closed
+}
+
+func (w *policyWatch) ResultChan() <-chan PolicyEvent {
+	return w.result
+}
+
+func (w *policyWatch) Err() error {
+	return w.err
+}
+
+func (w *policyWatch) Stop() {
+	w.once.Do(func() {
+		close(w.done)
+		w.stream.Close()
+	})
+}
+
+func (w *policyWatch) stopped() bool {
+	select {
+	case <-w.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (w *policyWatch) receive() {
+	defer close(w.result)
+	defer w.stream.Close()
+	decoder := json.NewDecoder(w.stream)
+	for {
+		var event struct {
+			Type   watch.EventType `json:"type"`
+			Object json.RawMessage `json:"object"`
+		}
+		if err := decoder.Decode(&event); err != nil {
+			if err != io.EOF && !w.stopped() {
+				w.err = fmt.Errorf("failed to decode eviction policy event: %v", err)
+			}
+			return
+		}
+		switch event.Type {
+		case watch.Added, watch.Modified, watch.Deleted:
+		case watch.Error:
+			status := &metav1.Status{}
+			if err := json.Unmarshal(event.Object, status); err != nil {
+				w.err = fmt.Errorf("failed to unmarshal eviction policy watch error: %v", err)
+			} else {
+				w.err = apierrors.FromObject(status)
+			}
+			return
+		default:
+			continue
+		}
+		policy := v1alpha1.EvictionPolicy{}
+		if err := json.Unmarshal(event.Object, &policy); err != nil {
+			w.err = fmt.Errorf("failed to unmarshal eviction policy: %v", err)
+			return
+		}
+		select {
+		case w.result <- PolicyEvent{Type: event.Type, Policy: policy}:
+		case <-w.done:
+			return
+		}
+	}
+}
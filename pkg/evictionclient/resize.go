@@ -0,0 +1,79 @@
+package evictionclient
+
+import (
+	"encoding/json"
+
+	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/types"
+)
+
+// GetPodResources returns requests and limits of containers of pod
+func (c *evictionClient) GetPodResources(podInfo *types.PodInfo) ([]types.ContainerResources, error) {
+	pod, err := c.client.CoreV1().Pods(podInfo.Namespace).Get(podInfo.Name, metav1.GetOptions{})
+	if err != nil {
+		log.Errorf("get pod %s/%s error %v", podInfo.Namespace, podInfo.Name, err)
+		return nil, err
+	}
+	var containers []types.ContainerResources
+	for _, container := range pod.Spec.Containers {
+		r := container.Resources
+		containers = append(containers, types.ContainerResources{
+			Name:          container.Name,
+			CPURequest:    float64(r.Requests.Cpu().MilliValue()) / 1000,
+			CPULimit:      float64(r.Limits.Cpu().MilliValue()) / 1000,
+			MemoryRequest: float64(r.Requests.Memory().Value()),
+			MemoryLimit:   float64(r.Limits.Memory().Value()),
+		})
+	}
+	return containers, nil
+}
+
+// ResizePod patches resources of containers in place, zero values are not
+// changed. It needs InPlacePodVerticalScaling, the resize subresource is used
+// if api server has it, otherwise the pod spec is patched.
+func (c *evictionClient) ResizePod(podInfo *types.PodInfo, containers []types.ContainerResources) error {
+	var patches []map[string]interface{}
+	for _, container := range containers {
+		requests, limits := v1.ResourceList{}, v1.ResourceList{}
+		setQuantity(requests, v1.ResourceCPU, resource.NewMilliQuantity(int64(container.CPURequest*1000), resource.DecimalSI))
+		setQuantity(limits, v1.ResourceCPU, resource.NewMilliQuantity(int64(container.CPULimit*1000), resource.DecimalSI))
+		setQuantity(requests, v1.ResourceMemory, resource.NewQuantity(int64(container.MemoryRequest), resource.BinarySI))
+		setQuantity(limits, v1.ResourceMemory, resource.NewQuantity(int64(container.MemoryLimit), resource.BinarySI))
+		resources := map[string]interface{}{}
+		if len(requests) != 0 {
+			resources["requests"] = requests
+		}
+		if len(limits) != 0 {
+			resources["limits"] = limits
+		}
+		patches = append(patches, map[string]interface{}{"name": container.Name, "resources": resources})
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{"containers": patches},
+	})
+	if err != nil {
+		return err
+	}
+	return c.retry("Resize", "pod "+podInfo.Namespace+"/"+podInfo.Name, func() error {
+		pods := c.client.CoreV1().Pods(podInfo.Namespace)
+		_, err := pods.Patch(podInfo.Name, k8stypes.StrategicMergePatchType, patch, "resize")
+		if apierrors.IsNotFound(err) {
+			// api server before resize subresource
+			_, err = pods.Patch(podInfo.Name, k8stypes.StrategicMergePatchType, patch)
+		}
+		c.invalidatePods()
+		return err
+	})
+}
+
+func setQuantity(list v1.ResourceList, name v1.ResourceName, q *resource.Quantity) {
+	if !q.IsZero() {
+		list[name] = *q
+	}
+}
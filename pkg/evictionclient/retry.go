@@ -0,0 +1,147 @@
+package evictionclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/metrics"
+	"eviction-agent/pkg/types"
+)
+
+// retryBackoff is the retry policy of client operations,
+// the last try happens after about 0.5+1+2+4 seconds with jitter.
+var retryBackoff = wait.Backoff{
+	Duration: 500 * time.Millisecond,
+	Factor:   2,
+	Jitter:   0.5,
+	Steps:    5,
+}
+
+var (
+	apiRetries = metrics.NewCounterVec("eviction_agent_api_retries_total",
+		"Number of retried api operations.", "operation")
+	apiFailures = metrics.NewCounterVec("eviction_agent_api_failures_total",
+		"Number of api operations failed permanently.", "operation")
+)
+
+// isRetryable returns true if the error may be transient: network errors,
+// 429, conflicts, server timeouts and 5xx. Others, e.g. of encoding, of
+// context canceled or of other responses, are permanent.
+func isRetryable(err error) bool {
+	if status, ok := err.(apierrors.APIStatus); ok {
+		return apierrors.IsConflict(err) || apierrors.IsServerTimeout(err) ||
+			apierrors.IsTimeout(err) || apierrors.IsTooManyRequests(err) ||
+			apierrors.IsUnexpectedServerError(err) || status.Status().Code >= 500
+	}
+	return isNetworkError(err)
+}
+
+// isNetworkError returns true if err is of reaching api server, e.g. a
+// refused or reset connection or a timeout, requests canceled are not
+func isNetworkError(err error) bool {
+	if urlErr, ok := err.(*url.Error); ok {
+		err = urlErr.Err
+	}
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	// timeouts, dns and other errors of dial
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// retry calls fn with exponential backoff and jitter until it succeeds,
+// returns a non-retryable error or runs out of steps. Permanent failures
+// are counted and recorded as warning events on node.
+func (c *evictionClient) retry(operation, target string, fn func() error) error {
+	return c.retryExcept(operation, target, nil, fn)
+}
+
+// retryExcept is retry, except that errors matched by expected, e.g. of
+// evictions blocked by budgets, are returned at once without being counted
+// or recorded as failures
+func (c *evictionClient) retryExcept(operation, target string, expected func(error) bool, fn func() error) error {
+	var lastErr error
+	attempts := 0
+	err := wait.ExponentialBackoff(retryBackoff, func() (bool, error) {
+		attempts++
+		lastErr = fn()
+		if lastErr == nil {
+			return true, nil
+		}
+		if expected != nil && expected(lastErr) {
+			return false, lastErr
+		}
+		if !isRetryable(lastErr) {
+			return false, lastErr
+		}
+		apiRetries.Inc(operation)
+		log.Warnf("%s %s failed, attempt %d, will retry: %v", operation, target, attempts, lastErr)
+		return false, nil
+	})
+	if err == nil {
+		return nil
+	}
+	if err == wait.ErrWaitTimeout {
+		err = lastErr
+	}
+	if expected != nil && expected(err) {
+		log.Infof("%s %s refused: %v", operation, target, err)
+		return err
+	}
+	apiFailures.Inc(operation)
+	log.Errorf("%s %s failed after %d attempts: %v", operation, target, attempts, err)
+	c.RecordNodeEvent(types.WarningEvent, types.ActionFailedReason,
+		fmt.Sprintf("Failed to %s %s after %d attempts: %v", operation, target, attempts, err))
+	return unauthorizedError(operation, target, err)
+}
+
+// IsUnreachable returns true if err is of reaching api server, e.g. connection
+// refused or timeout, instead of a response of it
+func IsUnreachable(err error) bool {
+	if err == nil || types.AsFatalError(err) != nil {
+		return false
+	}
+	_, ok := err.(apierrors.APIStatus)
+	return !ok
+}
+
+// IsBlockedByBudget returns true if err is an eviction refused by a
+// PodDisruptionBudget, which is expected instead of a failure of agent
+func IsBlockedByBudget(err error) bool {
+	return apierrors.IsTooManyRequests(err)
+}
+
+// unauthorizedError returns err as a fatal error if it's denied by
+// authentication or RBAC, retrying it never succeeds
+func unauthorizedError(operation, target string, err error) error {
+	if isUnauthorized(err) {
+		return types.NewFatalError(types.FatalUnauthorized, fmt.Errorf("%s %s: %v", operation, target, err))
+	}
+	return err
+}
+
+// isUnauthorized returns true if err is denied by authentication or RBAC,
+// other forbidden errors, e.g. of terminating namespaces, are not
+func isUnauthorized(err error) bool {
+	if apierrors.IsUnauthorized(err) {
+		return true
+	}
+	// RBAC denies with "User ... cannot <verb> resource ..."
+	return apierrors.IsForbidden(err) && strings.Contains(err.Error(), " cannot ")
+}
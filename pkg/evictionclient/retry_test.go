@@ -0,0 +1,54 @@
+package evictionclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"syscall"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestIsRetryable(t *testing.T) {
+	pods := schema.GroupResource{Resource: "pods"}
+	refused := &url.Error{Op: "Get", URL: "https://10.0.0.1/api", Err: &net.OpError{
+		Op: "dial", Net: "tcp", Err: fmt.Errorf("connect: %w", syscall.ECONNREFUSED)}}
+	for _, c := range []struct {
+		name      string
+		err       error
+		retryable bool
+	}{
+		{"connection refused", refused, true},
+		{"timeout", &url.Error{Op: "Get", URL: "https://10.0.0.1/api", Err: context.DeadlineExceeded}, true},
+		{"too many requests", apierrors.NewTooManyRequests("slow down", 1), true},
+		{"conflict", apierrors.NewConflict(pods, "busy", errors.New("modified")), true},
+		{"server timeout", apierrors.NewServerTimeout(pods, "get", 1), true},
+		{"internal error", apierrors.NewInternalError(errors.New("etcd")), true},
+		{"service unavailable", apierrors.NewServiceUnavailable("starting"), true},
+		{"canceled", &url.Error{Op: "Get", URL: "https://10.0.0.1/api", Err: context.Canceled}, false},
+		{"encoding", errors.New("failed to create patch for node node-1"), false},
+		{"not found", apierrors.NewNotFound(pods, "busy"), false},
+		{"bad request", apierrors.NewBadRequest("invalid"), false},
+		{"forbidden", apierrors.NewForbidden(pods, "busy", errors.New("cannot get")), false},
+	} {
+		if got := isRetryable(c.err); got != c.retryable {
+			t.Errorf("isRetryable of %s is %v, want %v", c.name, got, c.retryable)
+		}
+	}
+}
+
+func TestRetryReturnsBlockedByBudgetAtOnce(t *testing.T) {
+	c := &evictionClient{}
+	attempts := 0
+	err := c.retryExcept("Evict", "pod default/busy", IsBlockedByBudget, func() error {
+		attempts++
+		return apierrors.NewTooManyRequests("Cannot evict pod as it would violate the pod's disruption budget.", 0)
+	})
+	if !IsBlockedByBudget(err) || attempts != 1 {
+		t.Errorf("retry returns %v after %d attempts, want blocked by budget after 1", err, attempts)
+	}
+}
@@ -0,0 +1,434 @@
+package evictionclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/types"
+)
+
+// maxWorkloadConflicts is the max retries of workload update on conflicts,
+// agents on other nodes may update the same workload
+const maxWorkloadConflicts = 5
+
+// objectMeta is the metadata of any object
+type objectMeta struct {
+	Metadata metav1.ObjectMeta `json:"metadata"`
+}
+
+// GetPodWorkload return the workload of pod, the controller of its controller
+// if there is one, e.g. Deployment of its ReplicaSet. Returns nil if pod has
+// no controller.
+func (c *evictionClient) GetPodWorkload(podInfo *types.PodInfo) (*types.Workload, error) {
+	pod, err := c.client.CoreV1().Pods(podInfo.Namespace).Get(podInfo.Name, metav1.GetOptions{})
+	if err != nil {
+		log.Errorf("get pod %s/%s error %v", podInfo.Namespace, podInfo.Name, err)
+		return nil, err
+	}
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return nil, nil
+	}
+	workload := newWorkload(pod.Namespace, owner)
+	meta, err := c.getWorkloadMeta(workload)
+	if err != nil {
+		return nil, err
+	}
+	if top := metav1.GetControllerOf(&meta.Metadata); top != nil {
+		workload = newWorkload(pod.Namespace, top)
+	}
+	return workload, nil
+}
+
+// GetPodController return the controller of pod, e.g. its ReplicaSet, which
+// creates the replacement of pod. Returns nil if pod has no controller.
+func (c *evictionClient) GetPodController(podInfo *types.PodInfo) (*types.Workload, error) {
+	pod, err := c.client.CoreV1().Pods(podInfo.Namespace).Get(podInfo.Name, metav1.GetOptions{})
+	if err != nil {
+		log.Errorf("get pod %s/%s error %v", podInfo.Namespace, podInfo.Name, err)
+		return nil, err
+	}
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return nil, nil
+	}
+	return newWorkload(pod.Namespace, owner), nil
+}
+
+// GetReplacementPod return the first pod of controller created since, pods
+// being deleted are not replacements. Returns nil if there is none yet.
+func (c *evictionClient) GetReplacementPod(controller *types.Workload, since time.Time) (*types.Replacement, error) {
+	podList, err := c.client.CoreV1().Pods(controller.Namespace).List(metav1.ListOptions{})
+	if err != nil {
+		log.Errorf("List pods of %s %s/%s error %v", controller.Kind, controller.Namespace, controller.Name, err)
+		return nil, err
+	}
+	var first *v1.Pod
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		owner := metav1.GetControllerOf(pod)
+		if owner == nil || string(owner.UID) != controller.UID || pod.DeletionTimestamp != nil ||
+			pod.CreationTimestamp.Time.Before(since) {
+			continue
+		}
+		if first == nil || pod.CreationTimestamp.Time.Before(first.CreationTimestamp.Time) {
+			first = pod
+		}
+	}
+	if first == nil {
+		return nil, nil
+	}
+	return &types.Replacement{Namespace: first.Namespace, Name: first.Name, NodeName: first.Spec.NodeName}, nil
+}
+
+// statefulSet is the metadata, replicas and ready replicas of a StatefulSet
+type statefulSet struct {
+	Metadata metav1.ObjectMeta `json:"metadata"`
+	Spec     struct {
+		Replicas *int32 `json:"replicas"`
+	} `json:"spec"`
+	Status struct {
+		ReadyReplicas int32 `json:"readyReplicas"`
+	} `json:"status"`
+}
+
+// GetStatefulSetPods return pods of StatefulSets on current node with their
+// ordinals, annotations and unavailable replicas of StatefulSets, each
+// StatefulSet is got once. Pods of StatefulSets failed to get are skipped.
+func (c *evictionClient) GetStatefulSetPods() (map[string]types.StatefulSetPod, error) {
+	podList, err := c.listPods()
+	if err != nil {
+		log.Errorf("List pods on %s error %v", c.nodeName, err)
+		return nil, err
+	}
+	sets := make(map[string]*statefulSet)
+	pods := make(map[string]types.StatefulSetPod)
+	for i := range podList {
+		pod := &podList[i]
+		owner := metav1.GetControllerOf(pod)
+		if owner == nil || owner.Kind != "StatefulSet" {
+			continue
+		}
+		ordinal, err := strconv.Atoi(pod.Name[strings.LastIndex(pod.Name, "-")+1:])
+		if err != nil {
+			continue
+		}
+		key := pod.Namespace + "/" + owner.Name
+		set, ok := sets[key]
+		if !ok {
+			set, err = c.getStatefulSet(newWorkload(pod.Namespace, owner))
+			if err != nil {
+				log.Errorf("get StatefulSet %s error %v", key, err)
+			}
+			sets[key] = set
+		}
+		if set == nil {
+			continue
+		}
+		replicas := int32(1)
+		if set.Spec.Replicas != nil {
+			replicas = *set.Spec.Replicas
+		}
+		statefulSetPod := types.StatefulSetPod{
+			StatefulSet:    key,
+			Ordinal:        ordinal,
+			Quorum:         set.Metadata.Annotations[types.StatefulSetQuorumAnnotation] == "true",
+			MaxUnavailable: -1,
+			Unavailable:    int(replicas - set.Status.ReadyReplicas),
+			Ready:          podReady(pod),
+		}
+		if value, ok := set.Metadata.Annotations[types.StatefulSetMaxUnavailableAnnotation]; ok {
+			if max, err := strconv.Atoi(value); err == nil && max >= 0 {
+				statefulSetPod.MaxUnavailable = max
+			} else {
+				log.Warnf("invalid %s %q of StatefulSet %s", types.StatefulSetMaxUnavailableAnnotation, value, key)
+			}
+		}
+		pods[pod.Namespace+"/"+pod.Name] = statefulSetPod
+	}
+	return pods, nil
+}
+
+func podReady(pod *v1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == v1.PodReady {
+			return condition.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// job is the metadata, deadline and start time of a Job
+type job struct {
+	Metadata metav1.ObjectMeta `json:"metadata"`
+	Spec     struct {
+		ActiveDeadlineSeconds *int64 `json:"activeDeadlineSeconds"`
+	} `json:"spec"`
+	Status struct {
+		StartTime *metav1.Time `json:"startTime"`
+	} `json:"status"`
+}
+
+// GetJobProgress return progress of pods of Jobs on current node to
+// completion, by JobProgressAnnotation of pod or its Job, or else by elapsed
+// time of active deadline of pod or its Job. Pods of unknown progress are not
+// returned, each Job is got once.
+func (c *evictionClient) GetJobProgress() (map[string]float64, error) {
+	podList, err := c.listPods()
+	if err != nil {
+		log.Errorf("List pods on %s error %v", c.nodeName, err)
+		return nil, err
+	}
+	now := time.Now()
+	jobs := make(map[string]*job)
+	progress := make(map[string]float64)
+	for i := range podList {
+		pod := &podList[i]
+		owner := metav1.GetControllerOf(pod)
+		if owner == nil || owner.Kind != "Job" {
+			continue
+		}
+		key := pod.Namespace + "/" + pod.Name
+		if p, ok := parseProgress(pod.Annotations[types.JobProgressAnnotation]); ok {
+			progress[key] = p
+			continue
+		}
+		if deadline := pod.Spec.ActiveDeadlineSeconds; deadline != nil && *deadline > 0 && pod.Status.StartTime != nil {
+			progress[key] = elapsedProgress(now, pod.Status.StartTime.Time, *deadline)
+			continue
+		}
+		jobKey := pod.Namespace + "/" + owner.Name
+		j, ok := jobs[jobKey]
+		if !ok {
+			j, err = c.getJob(newWorkload(pod.Namespace, owner))
+			if err != nil {
+				log.Errorf("get Job %s error %v", jobKey, err)
+			}
+			jobs[jobKey] = j
+		}
+		if j == nil {
+			continue
+		}
+		if p, ok := parseProgress(j.Metadata.Annotations[types.JobProgressAnnotation]); ok {
+			progress[key] = p
+		} else if deadline := j.Spec.ActiveDeadlineSeconds; deadline != nil && *deadline > 0 && j.Status.StartTime != nil {
+			progress[key] = elapsedProgress(now, j.Status.StartTime.Time, *deadline)
+		}
+	}
+	return progress, nil
+}
+
+// GetPodCosts return costs of pods on current node by label or annotation
+// key of pod, or else of its controllers, e.g. its ReplicaSet and then the
+// Deployment of it. Pods without valid cost are not returned,
+// each workload is got once.
+func (c *evictionClient) GetPodCosts(key string) (map[string]float64, error) {
+	podList, err := c.listPods()
+	if err != nil {
+		log.Errorf("List pods on %s error %v", c.nodeName, err)
+		return nil, err
+	}
+	metas := make(map[string]*objectMeta)
+	getMeta := func(namespace string, owner *metav1.OwnerReference) *objectMeta {
+		uid := string(owner.UID)
+		meta, ok := metas[uid]
+		if !ok {
+			// errors are logged by getWorkloadMeta
+			meta, _ = c.getWorkloadMeta(newWorkload(namespace, owner))
+			metas[uid] = meta
+		}
+		return meta
+	}
+	costs := make(map[string]float64)
+	for i := range podList {
+		pod := &podList[i]
+		meta := &pod.ObjectMeta
+		for meta != nil {
+			if cost, ok := parseCost(meta, key); ok {
+				costs[pod.Namespace+"/"+pod.Name] = cost
+				break
+			}
+			owner := metav1.GetControllerOf(meta)
+			if owner == nil {
+				break
+			}
+			if workload := getMeta(pod.Namespace, owner); workload != nil {
+				meta = &workload.Metadata
+			} else {
+				meta = nil
+			}
+		}
+	}
+	return costs, nil
+}
+
+// parseCost returns the cost of label or annotation key of meta, labels
+// first, returns false if it's missing or invalid
+func parseCost(meta *metav1.ObjectMeta, key string) (float64, bool) {
+	for _, values := range []map[string]string{meta.Labels, meta.Annotations} {
+		value, ok := values[key]
+		if !ok {
+			continue
+		}
+		cost, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || cost < 0 {
+			log.Warnf("invalid cost %q of %s %s/%s", value, key, meta.Namespace, meta.Name)
+			return 0, false
+		}
+		return cost, true
+	}
+	return 0, false
+}
+
+// parseProgress parses progress like "95%" or "0.95", returns false if it's
+// empty or invalid
+func parseProgress(value string) (float64, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	scale := 1.0
+	if strings.HasSuffix(value, "%") {
+		value, scale = strings.TrimSuffix(value, "%"), 100
+	}
+	p, err := strconv.ParseFloat(value, 64)
+	if err != nil || p < 0 || p > scale {
+		return 0, false
+	}
+	return p / scale, true
+}
+
+// elapsedProgress is the ratio of elapsed time of active deadline seconds
+func elapsedProgress(now, start time.Time, deadline int64) float64 {
+	p := now.Sub(start).Seconds() / float64(deadline)
+	if p < 0 {
+		return 0
+	}
+	if p > 1 {
+		return 1
+	}
+	return p
+}
+
+func (c *evictionClient) getJob(workload *types.Workload) (*job, error) {
+	body, err := c.client.CoreV1().RESTClient().Get().AbsPath(workloadPath(workload)...).DoRaw()
+	if err != nil {
+		return nil, err
+	}
+	j := &job{}
+	if err := json.Unmarshal(body, j); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal Job %s/%s: %v", workload.Namespace, workload.Name, err)
+	}
+	return j, nil
+}
+
+func (c *evictionClient) getStatefulSet(workload *types.Workload) (*statefulSet, error) {
+	body, err := c.client.CoreV1().RESTClient().Get().AbsPath(workloadPath(workload)...).DoRaw()
+	if err != nil {
+		return nil, err
+	}
+	set := &statefulSet{}
+	if err := json.Unmarshal(body, set); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal StatefulSet %s/%s: %v", workload.Namespace, workload.Name, err)
+	}
+	return set, nil
+}
+
+func newWorkload(namespace string, owner *metav1.OwnerReference) *types.Workload {
+	return &types.Workload{
+		APIVersion: owner.APIVersion,
+		Kind:       owner.Kind,
+		Namespace:  namespace,
+		Name:       owner.Name,
+		UID:        string(owner.UID),
+	}
+}
+
+// workloadPath returns the api path of workload, resources are lowercase plural kinds
+func workloadPath(workload *types.Workload) []string {
+	prefix := "/apis"
+	if !strings.Contains(workload.APIVersion, "/") {
+		prefix = "/api"
+	}
+	return []string{prefix, workload.APIVersion, "namespaces", workload.Namespace,
+		strings.ToLower(workload.Kind) + "s", workload.Name}
+}
+
+func (c *evictionClient) getWorkloadMeta(workload *types.Workload) (*objectMeta, error) {
+	body, err := c.client.CoreV1().RESTClient().Get().AbsPath(workloadPath(workload)...).DoRaw()
+	if err != nil {
+		log.Errorf("get %s %s/%s error %v", workload.Kind, workload.Namespace, workload.Name, err)
+		return nil, err
+	}
+	meta := &objectMeta{}
+	if err := json.Unmarshal(body, meta); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal %s %s/%s: %v", workload.Kind, workload.Namespace, workload.Name, err)
+	}
+	return meta, nil
+}
+
+// UpdateWorkloadMetadata updates labels and annotations of workload by update,
+// which returns false if nothing is changed. Workload is patched with its
+// resource version, update is called again on conflicts.
+func (c *evictionClient) UpdateWorkloadMetadata(workload *types.Workload,
+	update func(labels, annotations map[string]string) bool) error {
+	var err error
+	for i := 0; i < maxWorkloadConflicts; i++ {
+		var meta *objectMeta
+		meta, err = c.getWorkloadMeta(workload)
+		if err != nil {
+			return err
+		}
+		labels, annotations := meta.Metadata.Labels, meta.Metadata.Annotations
+		if labels == nil {
+			labels = make(map[string]string)
+		}
+		if annotations == nil {
+			annotations = make(map[string]string)
+		}
+		if !update(labels, annotations) {
+			return nil
+		}
+		var patch []byte
+		patch, err = json.Marshal(map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"resourceVersion": meta.Metadata.ResourceVersion,
+				"labels":          labels,
+				"annotations":     annotations,
+			},
+		})
+		if err != nil {
+			return err
+		}
+		_, err = c.client.CoreV1().RESTClient().Patch(k8stypes.MergePatchType).
+			AbsPath(workloadPath(workload)...).Body(patch).DoRaw()
+		if !apierrors.IsConflict(err) {
+			break
+		}
+	}
+	if err != nil {
+		log.Errorf("update %s %s/%s error %v", workload.Kind, workload.Namespace, workload.Name, err)
+	}
+	return err
+}
+
+// RecordWorkloadEvent records an event on workload
+func (c *evictionClient) RecordWorkloadEvent(workload *types.Workload, eventType, reason, message string) {
+	ref := &v1.ObjectReference{
+		APIVersion: workload.APIVersion,
+		Kind:       workload.Kind,
+		Name:       workload.Name,
+		Namespace:  workload.Namespace,
+		UID:        k8stypes.UID(workload.UID),
+	}
+	c.recordEvent(ref, workload.Namespace, eventType, reason, message)
+}
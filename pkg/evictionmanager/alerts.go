@@ -0,0 +1,59 @@
+package evictionmanager
+
+import (
+	"fmt"
+
+	"eviction-agent/cmd/options"
+	"eviction-agent/pkg/alertmanager"
+	"eviction-agent/pkg/condition"
+)
+
+// newAlerts creates the client of Alertmanager, returns nil if it's not configured
+func newAlerts(eao *options.EvictionAgentOptions) *alertmanager.Client {
+	if eao.AlertmanagerURL == "" {
+		return nil
+	}
+	return alertmanager.NewClient(eao.AlertmanagerURL, eao.AlertmanagerTimeout, eao.AlertmanagerResend)
+}
+
+// alert fires the alert of taintKey with the top offender once node is
+// tainted, and resolves it once node is untainted
+func (e *evictionManager) alert(taintKey string, action string, nodeCondition *condition.NodeCondition) {
+	if e.alerts == nil {
+		return
+	}
+	if action != "Taint" {
+		e.alerts.Resolve(taintKey)
+		return
+	}
+	labels := map[string]string{
+		"alertname": alertmanager.AlertName(taintKey),
+		"node":      e.nodeName,
+		"condition": taintKey,
+		"severity":  "warning",
+	}
+	if offender := e.topOffender(taintKey); offender != "" {
+		labels["offender"] = offender
+	}
+	e.alerts.Fire(taintKey, labels, map[string]string{
+		"summary":     fmt.Sprintf("Node %s is tainted with %s by eviction agent", e.nodeName, taintKey),
+		"description": conditionMessage(taintKey, nodeCondition),
+	})
+}
+
+// topOffender returns the best scored candidate of the condition of taintKey,
+// empty if there is none, e.g. node problems are not evicted by usage
+func (e *evictionManager) topOffender(taintKey string) string {
+	for _, controller := range e.controllers {
+		if controller.taintKey != taintKey {
+			continue
+		}
+		for _, evictType := range controller.evictTypes {
+			candidates, err := e.conditionManager.GetEvictionCandidates(evictType)
+			if err == nil && len(candidates) != 0 {
+				return candidates[0].Pod.Namespace + "/" + candidates[0].Pod.Name
+			}
+		}
+	}
+	return ""
+}
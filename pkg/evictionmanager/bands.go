@@ -0,0 +1,28 @@
+package evictionmanager
+
+import (
+	"eviction-agent/cmd/options"
+	"eviction-agent/pkg/condition"
+	"eviction-agent/pkg/metrics"
+)
+
+var (
+	bandBusy = metrics.NewGaugeVec("eviction_agent_band_busy",
+		"Whether pods of the priority band exceed its limit of the condition, 1 if they do.", "band", "condition")
+)
+
+// checkBands requests evictions within priority bands exceeding their limits,
+// node is not tainted by bands. It's called by taint process.
+func (e *evictionManager) checkBands(nodeCondition *condition.NodeCondition) {
+	for _, band := range nodeCondition.Bands {
+		if band.Available {
+			bandBusy.Set(0, band.Band, band.EvictType)
+			continue
+		}
+		bandBusy.Set(1, band.Band, band.EvictType)
+		if e.mode != options.ModeFull || e.observing() {
+			continue
+		}
+		e.queue.Push(newEvictionRequest(condition.BandEvictType(band.EvictType, band.Band), nodeCondition))
+	}
+}
@@ -0,0 +1,55 @@
+package evictionmanager
+
+import (
+	"fmt"
+	"time"
+
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/types"
+)
+
+// ControlResult is the result of an action of control api
+type ControlResult struct {
+	Action  string `json:"action"`
+	Message string `json:"message"`
+}
+
+// Pause pauses actions by the paused annotation of node until until, or
+// until Resume if until is zero, so that a pause of control api is the same
+// as one of kubectl. It's taken by the next evaluation, which is triggered.
+func (e *evictionManager) Pause(until time.Time) (*ControlResult, error) {
+	value := "true"
+	message := "paused until resumed"
+	if !until.IsZero() {
+		if !until.After(e.clock.Now()) {
+			return nil, fmt.Errorf("pause until %s is not in the future", until.Format(time.RFC3339))
+		}
+		value = until.UTC().Format(time.RFC3339)
+		message = "paused until " + value
+	}
+	if err := e.client.AnnotateNode(map[string]string{types.PausedAnnotation: value}); err != nil {
+		return nil, err
+	}
+	e.TriggerEvaluation()
+	return &ControlResult{Action: "Pause", Message: message}, nil
+}
+
+// Resume removes the paused annotation of node and triggers an evaluation
+func (e *evictionManager) Resume() (*ControlResult, error) {
+	if err := e.client.AnnotateNode(map[string]string{types.PausedAnnotation: ""}); err != nil {
+		return nil, err
+	}
+	e.TriggerEvaluation()
+	return &ControlResult{Action: "Resume", Message: "resumed"}, nil
+}
+
+// TriggerEvaluation makes taint process evaluate conditions now instead of
+// at its next tick, triggers of an evaluation not started yet are merged
+func (e *evictionManager) TriggerEvaluation() *ControlResult {
+	select {
+	case e.trigger <- struct{}{}:
+		log.Infof("Evaluation is triggered")
+	default:
+	}
+	return &ControlResult{Action: "TriggerEvaluation", Message: "evaluation is triggered"}
+}
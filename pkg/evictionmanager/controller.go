@@ -0,0 +1,436 @@
+package evictionmanager
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"eviction-agent/cmd/options"
+	"eviction-agent/pkg/condition"
+	"eviction-agent/pkg/config"
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/metrics"
+	"eviction-agent/pkg/tracing"
+	"eviction-agent/pkg/types"
+)
+
+// conditionDescriptor describes a condition handled by taint process,
+// a new condition is added by adding its descriptor
+type conditionDescriptor struct {
+	// name is the condition of policy, e.g. config.CPUCondition
+	name string
+	// taintKey is the taint set on node when the condition is busy
+	taintKey string
+	// evictTypes are the eviction requests of the condition
+	evictTypes []string
+	// tainted returns true if node is tainted by the condition
+	tainted func(nodeTaint *types.NodeTaintInfo) bool
+	// busy returns the eviction requests of the condition, empty if the condition is available
+	busy func(nodeCondition *condition.NodeCondition) []string
+	// taintOnly is true if node is tainted without evictions when the condition is busy
+	taintOnly bool
+	// rankBy is the evict type choosing pods of the eviction requests if
+	// they are not evict types of resources, e.g. DiskIOBusy
+	rankBy string
+	// kubeletPressure is the node condition set by kubelet eviction manager
+	// under pressure of the same resource, e.g. MemoryPressure
+	kubeletPressure string
+	// runtimeExempt is true if evictions are not triggered while containers
+	// are created, the io of image pulls is of the runtime instead of pods
+	runtimeExempt bool
+}
+
+// conditionDescriptors are all conditions in the order they are handled
+var conditionDescriptors = []conditionDescriptor{
+	{
+		name:       config.CPUCondition,
+		taintKey:   types.CPUBusy,
+		evictTypes: []string{types.CPUBusy},
+		tainted:    func(t *types.NodeTaintInfo) bool { return t.CPU },
+		busy: func(c *condition.NodeCondition) []string {
+			return busyIf(!c.CPUAvailable, types.CPUBusy)
+		},
+	},
+	{
+		name:       config.MemoryCondition,
+		taintKey:   types.MemBusy,
+		evictTypes: []string{types.MemBusy},
+		tainted:    func(t *types.NodeTaintInfo) bool { return t.Memory },
+		busy: func(c *condition.NodeCondition) []string {
+			return busyIf(!c.MemoryAvailable, types.MemBusy)
+		},
+		kubeletPressure: "MemoryPressure",
+	},
+	{
+		name:       config.DiskIOCondition,
+		taintKey:   types.DiskIO,
+		evictTypes: []string{types.DiskIO},
+		tainted:    func(t *types.NodeTaintInfo) bool { return t.DiskIO },
+		busy: func(c *condition.NodeCondition) []string {
+			return busyIf(!c.DiskIOAvailable, types.DiskIO)
+		},
+		kubeletPressure: "DiskPressure",
+		runtimeExempt:   true,
+	},
+	{
+		name:       config.NetworkIOCondition,
+		taintKey:   types.NetworkIO,
+		evictTypes: []string{types.NetworkRxBusy, types.NetworkTxBusy},
+		tainted:    func(t *types.NodeTaintInfo) bool { return t.NetworkIO },
+		// rx and tx share the taint, pods are chosen by the busy direction
+		busy: func(c *condition.NodeCondition) []string {
+			return append(busyIf(!c.NetworkRxAvailable, types.NetworkRxBusy),
+				busyIf(!c.NetworkTxAvailable, types.NetworkTxBusy)...)
+		},
+		runtimeExempt: true,
+	},
+	{
+		name:       config.WritebackCondition,
+		taintKey:   types.WritebackBusy,
+		evictTypes: []string{types.WritebackBusy},
+		tainted:    func(t *types.NodeTaintInfo) bool { return t.Taints[types.WritebackBusy] },
+		busy: func(c *condition.NodeCondition) []string {
+			return busyIf(c.Writeback != nil && c.Writeback.Busy, types.WritebackBusy)
+		},
+		runtimeExempt: true,
+	},
+	{
+		name:       config.EphemeralPortsCondition,
+		taintKey:   types.EphemeralPortsBusy,
+		evictTypes: []string{types.EphemeralPortsBusy},
+		tainted:    func(t *types.NodeTaintInfo) bool { return t.Taints[types.EphemeralPortsBusy] },
+		busy: func(c *condition.NodeCondition) []string {
+			return busyIf(c.EphemeralPorts != nil && c.EphemeralPorts.Busy, types.EphemeralPortsBusy)
+		},
+	},
+}
+
+// nodeProblemDescriptor describes a node condition, e.g. KernelDeadlock of node
+// problem detector. Node is tainted with the condition type while it's True, and
+// if rankBy is set pods are evicted, chosen by the usage of rankBy.
+func nodeProblemDescriptor(problem, rankBy string) conditionDescriptor {
+	return conditionDescriptor{
+		name:       problem,
+		taintKey:   problem,
+		evictTypes: []string{problem},
+		tainted:    func(t *types.NodeTaintInfo) bool { return t.Taints[problem] },
+		busy: func(c *condition.NodeCondition) []string {
+			return busyIf(c.Problems[problem], problem)
+		},
+		taintOnly: rankBy == "",
+		rankBy:    rankBy,
+	}
+}
+
+// extendedResourceDescriptor describes an extended resource of device plugins,
+// e.g. intel.com/fpga. Node is tainted with intel.com/fpgaBusy while its usage
+// is over the threshold of allocatable, and pods using most of it are evicted.
+func extendedResourceDescriptor(resource string) conditionDescriptor {
+	taintKey := types.ExtendedResourceTaintKey(resource)
+	return conditionDescriptor{
+		name:       resource,
+		taintKey:   taintKey,
+		evictTypes: []string{taintKey},
+		tainted:    func(t *types.NodeTaintInfo) bool { return t.Taints[taintKey] },
+		busy: func(c *condition.NodeCondition) []string {
+			r, ok := c.Extended[resource]
+			return busyIf(ok && !r.Available, taintKey)
+		},
+	}
+}
+
+var (
+	kubeletBackoffs = metrics.NewCounterVec("eviction_agent_kubelet_backoffs_total",
+		"Number of evictions left to kubelet under its pressure of the same resource.", "condition")
+)
+
+func busyIf(busy bool, evictType string) []string {
+	if busy {
+		return []string{evictType}
+	}
+	return nil
+}
+
+// conditionController taints node when its condition is busy, requests
+// evictions, and untaints node after the condition is available for
+// untaint grace period. Its phase tells which of these it's doing.
+type conditionController struct {
+	conditionDescriptor
+	// lastTaintTime is the last time the condition is busy
+	lastTaintTime time.Time
+	// period is the evaluation period of the condition
+	period time.Duration
+	// lastEvaluation is the last time the condition is evaluated
+	lastEvaluation time.Time
+	// phase is the current phase, changed only by transition
+	phase Phase
+	// phaseTime is the time of the last transition
+	phaseTime time.Time
+	// hooks are called on each transition
+	hooks []transitionHook
+	// kubeletPrecedence is true if evictions are left to kubelet while
+	// it reports kubeletPressure
+	kubeletPrecedence bool
+	// observedTaint is true if node would be tainted by the condition during observation
+	observedTaint bool
+	// busySince is since when the condition is busy, zero if it's available
+	busySince time.Time
+	// softTainted is true if node is tainted PreferNoSchedule by the
+	// condition of warning severity
+	softTainted bool
+	// transitions are the latest transitions, phaseDuration is the time in
+	// the phase left by the last one and peak is of the current phase
+	transitions   []TransitionRecord
+	phaseDuration time.Duration
+	peak          float64
+}
+
+// newConditionControllers creates controllers evaluated at period, or at
+// their own period in periods. Extended resources and then node problems are
+// handled after conditions of resources, node problems are mapped to the evict
+// type choosing pods. Conditions in kubeletPrecedence leave evictions to
+// kubelet under its pressure.
+func newConditionControllers(period time.Duration, periods map[string]time.Duration,
+	extended map[string]config.Threshold, problems map[string]string, kubeletPrecedence map[string]bool,
+	now time.Time) []*conditionController {
+	descriptors := append([]conditionDescriptor{}, conditionDescriptors...)
+	var resources []string
+	for resource := range extended {
+		resources = append(resources, resource)
+	}
+	sort.Strings(resources)
+	for _, resource := range resources {
+		descriptors = append(descriptors, extendedResourceDescriptor(resource))
+	}
+	var names []string
+	for problem := range problems {
+		names = append(names, problem)
+	}
+	sort.Strings(names)
+	for _, problem := range names {
+		descriptors = append(descriptors, nodeProblemDescriptor(problem, problems[problem]))
+	}
+
+	controllers := make([]*conditionController, 0, len(descriptors))
+	for _, descriptor := range descriptors {
+		controller := &conditionController{
+			conditionDescriptor: descriptor,
+			period:              period,
+			phase:               PhaseHealthy,
+			phaseTime:           now,
+			hooks:               defaultTransitionHooks,
+			kubeletPrecedence:   descriptor.kubeletPressure != "" && kubeletPrecedence[descriptor.name],
+		}
+		setPhaseMetric(descriptor.name, PhaseHealthy)
+		if p, ok := periods[descriptor.name]; ok {
+			controller.period = p
+		}
+		controllers = append(controllers, controller)
+	}
+	return controllers
+}
+
+// due returns true and records the evaluation if period is over. Ticks are
+// jittered, so a period which is almost over is taken as over.
+func (cc *conditionController) due(now time.Time) bool {
+	if now.Sub(cc.lastEvaluation) < cc.period*9/10 {
+		return false
+	}
+	cc.lastEvaluation = now
+	return true
+}
+
+// good returns true if the condition is available and node is not tainted by it
+func (cc *conditionController) good(nodeCondition *condition.NodeCondition, e *evictionManager) bool {
+	return len(cc.busy(nodeCondition)) == 0 && !cc.isTainted(e)
+}
+
+// isTainted returns true if node is tainted by the condition, or would be
+// tainted during observation
+func (cc *conditionController) isTainted(e *evictionManager) bool {
+	if e.observing() {
+		return cc.observedTaint
+	}
+	return cc.tainted(&e.nodeTaint)
+}
+
+// sync handles the condition of the current cycle
+func (cc *conditionController) sync(ctx context.Context, e *evictionManager, nodeCondition *condition.NodeCondition, unTaintPeriod time.Duration) {
+	ctx, span := tracing.Start(ctx, "condition.sync")
+	defer func() {
+		span.SetAttribute("phase", string(cc.phase))
+		span.End()
+	}()
+	span.SetAttribute("condition", cc.name)
+	// values of the cycle are of the phase it ends in
+	defer cc.observePeak(nodeCondition)
+	if e.mode == options.ModeMonitorOnly {
+		// busy conditions stay in soft pressure, node and pods are never changed
+		if e.conditionManager.ConditionEnabled(cc.name) && len(cc.busy(nodeCondition)) != 0 {
+			cc.transition(e, PhaseSoftPressure)
+		} else {
+			cc.transition(e, PhaseHealthy)
+		}
+		return
+	}
+	tainted := cc.isTainted(e)
+	if !e.conditionManager.ConditionEnabled(cc.name) {
+		cc.busySince = time.Time{}
+		if !tainted {
+			cc.transition(e, PhaseHealthy)
+		} else if e.untaintDisabled(cc.taintKey, nodeCondition) {
+			cc.observedTaint = false
+			cc.softTainted = false
+			cc.clearLabels(e)
+			cc.transition(e, PhaseHealthy)
+		}
+		return
+	}
+
+	evictTypes := cc.busy(nodeCondition)
+	if len(evictTypes) == 0 {
+		cc.busySince = time.Time{}
+		if !tainted {
+			// pods are labeled without taint if actions of policy have no Taint
+			if cc.phase == PhaseEvicting {
+				cc.clearLabels(e)
+			}
+			cc.transition(e, PhaseHealthy)
+			return
+		}
+		// node is tainted, untaint it after grace period
+		cc.transition(e, PhaseRecovering)
+		duration := e.clock.Since(cc.lastTaintTime)
+		log.Infof("last %s taint duration: %v", cc.taintKey, duration)
+		if duration <= unTaintPeriod {
+			return
+		}
+		if err := e.verifyUntaint(ctx, cc.name); err != nil {
+			// probe again after another grace period
+			log.Warnf("keep node tainted %s, untaint probe failed: %v", cc.taintKey, err)
+			cc.lastTaintTime = e.clock.Now()
+			return
+		}
+		log.Infof("Untaint node %s", cc.taintKey)
+		if err := cc.setTaint(ctx, e, "UnTaint"); err != nil {
+			log.Errorf("untaint node %s error: %v", cc.taintKey, err)
+			e.status.recordError(fmt.Sprintf("untaint node %s error: %v", cc.taintKey, err))
+		} else {
+			cc.softTainted = false
+			e.recordTaintEvent(cc.taintKey, "UnTaint", nodeCondition)
+			cc.clearLabels(e)
+			cc.transition(e, PhaseHealthy)
+		}
+		return
+	}
+
+	// condition is busy, update taint time
+	cc.lastTaintTime = e.clock.Now()
+	if cc.busySince.IsZero() {
+		cc.busySince = cc.lastTaintTime
+	}
+	// warning conditions taint node softly, critical ones immediately
+	severity := gradedSeverity(evictTypes, nodeCondition)
+	span.SetAttribute("severity", string(severity))
+	soft := cc.softTaint(severity)
+	if !tainted && e.taintDelay > 0 && severity != types.SeverityCritical &&
+		cc.phase != PhaseEvicting && e.clock.Since(cc.busySince) < e.taintDelay {
+		// short spikes don't taint node or evict pods
+		cc.transition(e, PhaseSoftPressure)
+		log.Infof("%s is busy for %v, taint node after %v", cc.name, e.clock.Since(cc.busySince), e.taintDelay)
+		return
+	}
+	if !tainted && !cc.taintOnly && !soft && e.headroomExhausted() && types.TaintEffect(cc.taintKey) == "NoSchedule" {
+		// tainting node takes away capacity of cluster, pods are labeled
+		cc.transition(e, PhaseSoftPressure)
+		log.Infof("cluster has no headroom, don't taint node %s", cc.taintKey)
+	} else if !tainted && !e.conditionManager.ActionEnabled(config.TaintAction) {
+		// pods are evicted or labeled without taint, the phase moves to
+		// Evicting once they are
+	} else if !tainted {
+		action := "Taint"
+		if soft {
+			action = "SoftTaint"
+		}
+		log.Infof("taint node %s by %s", cc.taintKey, action)
+		if err := cc.setTaint(ctx, e, action); err != nil {
+			log.Errorf("add taint %s error: %v", cc.taintKey, err)
+			e.status.recordError(fmt.Sprintf("add taint %s error: %v", cc.taintKey, err))
+			cc.transition(e, PhaseSoftPressure)
+		} else {
+			cc.softTainted = soft
+			e.recordTaintEvent(cc.taintKey, "Taint", nodeCondition)
+			cc.transition(e, PhaseTainted)
+		}
+	} else if cc.softTainted && severity == types.SeverityCritical && !e.headroomExhausted() {
+		cc.hardenTaint(ctx, e, nodeCondition)
+	} else if cc.phase != PhaseEvicting {
+		cc.transition(e, PhaseTainted)
+	}
+	if cc.taintOnly || e.mode == options.ModeTaintOnly {
+		cc.holdSoftPressure(e)
+		return
+	}
+	if cc.kubeletEvicting(nodeCondition) {
+		log.Infof("kubelet reports %s, leave evictions of %s to kubelet", cc.kubeletPressure, cc.name)
+		kubeletBackoffs.Inc(cc.name)
+		cc.holdSoftPressure(e)
+		return
+	}
+	if cc.runtimeExempt && severity != types.SeverityCritical && e.runtimeExempted() {
+		log.Infof("containers are created on node, exempt %s from evictions", cc.name)
+		runtimeExemptions.Inc(cc.name)
+		cc.holdSoftPressure(e)
+		return
+	}
+	// evict pods to reclaim resources, requests are ordered by priority
+	for _, evictType := range evictTypes {
+		e.queue.Push(newEvictionRequest(evictType, nodeCondition))
+	}
+	span.SetAttribute("evict_types", fmt.Sprint(evictTypes))
+	cc.transition(e, PhaseEvicting)
+}
+
+// holdSoftPressure moves the busy condition to soft pressure if it's still
+// healthy, i.e. node is neither tainted nor are pods evicted for it
+func (cc *conditionController) holdSoftPressure(e *evictionManager) {
+	if cc.phase == PhaseHealthy || cc.phase == PhaseRecovering {
+		cc.transition(e, PhaseSoftPressure)
+	}
+}
+
+// setTaint taints or untaints node by the condition
+func (cc *conditionController) setTaint(ctx context.Context, e *evictionManager, action string) error {
+	if e.observing() {
+		cc.observedTaint = action != "UnTaint"
+		return nil
+	}
+	apiCtx, span := tracing.StartClient(ctx, "api."+strings.ToLower(action))
+	defer span.End()
+	span.SetAttribute("taint", cc.taintKey)
+	err := e.client.WithContext(apiCtx).SetTaintConditions(cc.taintKey, action)
+	span.SetError(err)
+	e.recordAction(strings.ToLower(action)+" node "+cc.taintKey, err)
+	e.failOnFatal(err)
+	return err
+}
+
+// kubeletEvicting returns true if kubelet takes precedence and reports pressure
+// of the resource. Kubelet keeps the condition for its pressure transition
+// period after evictions, so it covers kubelet evicting too.
+func (cc *conditionController) kubeletEvicting(nodeCondition *condition.NodeCondition) bool {
+	return cc.kubeletPrecedence && nodeCondition.Problems[cc.kubeletPressure]
+}
+
+// clearLabels removes evict labels added for the condition after untaint
+func (cc *conditionController) clearLabels(e *evictionManager) {
+	if !e.labelsEnabled() {
+		return
+	}
+	if err := e.client.ClearEvictLabels(cc.evictTypes); err != nil {
+		log.Errorf("clear evict labels of %s error: %v", cc.name, err)
+		e.status.recordError(fmt.Sprintf("clear evict labels of %s error: %v", cc.name, err))
+	}
+}
@@ -0,0 +1,172 @@
+package evictionmanager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/clock"
+
+	"eviction-agent/cmd/options"
+	"eviction-agent/pkg/condition"
+	conditionfake "eviction-agent/pkg/condition/fake"
+	"eviction-agent/pkg/config"
+	clientfake "eviction-agent/pkg/evictionclient/fake"
+	"eviction-agent/pkg/types"
+)
+
+// newTestManager creates an eviction manager of fakes evaluating every period
+func newTestManager(period time.Duration) (*evictionManager, *clientfake.Client, *conditionfake.ConditionManager, *clock.FakeClock) {
+	clk := clock.NewFakeClock(time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC))
+	client := clientfake.NewClient()
+	conditions := conditionfake.NewConditionManagerWithClock(clk)
+	eao := options.NewEvictionAgentOptions()
+	eao.NodeName = "node-1"
+	eao.EvaluationPeriod = period
+	e := newEvictionManager(client, conditions, eao, clk)
+	return e, client, conditions, clk
+}
+
+func controllerOf(e *evictionManager, name string) *conditionController {
+	for _, controller := range e.controllers {
+		if controller.name == name {
+			return controller
+		}
+	}
+	return nil
+}
+
+func cpuTainted(client *clientfake.Client) bool {
+	client.Lock()
+	defer client.Unlock()
+	return client.Taints[types.CPUBusy]
+}
+
+func TestUntaintAfterGracePeriod(t *testing.T) {
+	const period = time.Second
+	for _, grace := range []time.Duration{30 * time.Second, time.Minute, 5 * time.Minute} {
+		t.Run(grace.String(), func(t *testing.T) {
+			e, client, conditions, clk := newTestManager(period)
+			conditions.SetUnTaintGracePeriod(grace)
+			ctx := context.Background()
+
+			conditions.Update(func(c *condition.NodeCondition) { c.CPUAvailable = false })
+			clk.Step(period)
+			e.evaluate(ctx)
+			if !cpuTainted(client) {
+				t.Fatalf("node is not tainted %s while CPU is busy", types.CPUBusy)
+			}
+			// taint time is of the last cycle the condition is busy
+			busyUntil := clk.Now()
+
+			conditions.Update(func(c *condition.NodeCondition) { c.CPUAvailable = true })
+			for clk.Since(busyUntil) < grace {
+				clk.Step(period)
+				e.evaluate(ctx)
+				if !cpuTainted(client) {
+					t.Fatalf("node is untainted %v after recovery, before grace period %v", clk.Since(busyUntil), grace)
+				}
+			}
+			if phase := controllerOf(e, config.CPUCondition).phase; phase != PhaseRecovering {
+				t.Errorf("phase at grace period is %s, want %s", phase, PhaseRecovering)
+			}
+
+			clk.Step(period)
+			e.evaluate(ctx)
+			if cpuTainted(client) {
+				t.Fatalf("node is still tainted %v after recovery, past grace period %v", clk.Since(busyUntil), grace)
+			}
+			if phase := controllerOf(e, config.CPUCondition).phase; phase != PhaseHealthy {
+				t.Errorf("phase after untaint is %s, want %s", phase, PhaseHealthy)
+			}
+		})
+	}
+}
+
+func TestBusyAgainDuringGracePeriodKeepsTaint(t *testing.T) {
+	const period, grace = time.Second, 30 * time.Second
+	e, client, conditions, clk := newTestManager(period)
+	conditions.SetUnTaintGracePeriod(grace)
+	ctx := context.Background()
+
+	conditions.Update(func(c *condition.NodeCondition) { c.CPUAvailable = false })
+	clk.Step(period)
+	e.evaluate(ctx)
+	conditions.Update(func(c *condition.NodeCondition) { c.CPUAvailable = true })
+	clk.Step(grace / 2)
+	e.evaluate(ctx)
+
+	// the grace period starts again from the last busy cycle
+	conditions.Update(func(c *condition.NodeCondition) { c.CPUAvailable = false })
+	clk.Step(period)
+	e.evaluate(ctx)
+	busyUntil := clk.Now()
+	conditions.Update(func(c *condition.NodeCondition) { c.CPUAvailable = true })
+	clk.Step(grace)
+	e.evaluate(ctx)
+	if !cpuTainted(client) {
+		t.Fatalf("node is untainted %v after the last busy cycle, grace period is %v", clk.Since(busyUntil), grace)
+	}
+	clk.Step(period)
+	e.evaluate(ctx)
+	if cpuTainted(client) {
+		t.Fatalf("node is still tainted %v after the last busy cycle, grace period is %v", clk.Since(busyUntil), grace)
+	}
+}
+
+func TestTaintDelayDwellsInSoftPressure(t *testing.T) {
+	const period, delay = time.Second, 10 * time.Second
+	e, client, conditions, clk := newTestManager(period)
+	e.taintDelay = delay
+	ctx := context.Background()
+	cpu := controllerOf(e, config.CPUCondition)
+
+	conditions.Update(func(c *condition.NodeCondition) { c.CPUAvailable = false })
+	clk.Step(period)
+	e.evaluate(ctx)
+	busySince := clk.Now()
+	for clk.Since(busySince) < delay {
+		if cpuTainted(client) {
+			t.Fatalf("node is tainted %v after CPU is busy, before taint delay %v", clk.Since(busySince), delay)
+		}
+		if cpu.phase != PhaseSoftPressure {
+			t.Fatalf("phase during taint delay is %s, want %s", cpu.phase, PhaseSoftPressure)
+		}
+		clk.Step(period)
+		e.evaluate(ctx)
+	}
+	if !cpuTainted(client) {
+		t.Fatalf("node is not tainted after taint delay %v", delay)
+	}
+	if cpu.phase != PhaseEvicting {
+		t.Errorf("phase after taint delay is %s, want %s", cpu.phase, PhaseEvicting)
+	}
+}
+
+func TestTaintWithoutDelaySkipsSoftPressure(t *testing.T) {
+	e, _, conditions, clk := newTestManager(time.Second)
+	cpu := controllerOf(e, config.CPUCondition)
+
+	conditions.Update(func(c *condition.NodeCondition) { c.CPUAvailable = false })
+	clk.Step(time.Second)
+	e.evaluate(context.Background())
+	if cpu.phase != PhaseEvicting || len(cpu.transitions) == 0 {
+		t.Fatalf("phase is %s after transitions %+v, want %s", cpu.phase, cpu.transitions, PhaseEvicting)
+	}
+	for _, record := range cpu.transitions {
+		if record.From == PhaseSoftPressure || record.To == PhaseSoftPressure {
+			t.Errorf("condition passes through %s without taint delay: %+v", PhaseSoftPressure, cpu.transitions)
+		}
+	}
+}
+
+func TestInvalidTransitionIsRefused(t *testing.T) {
+	e, _, _, _ := newTestManager(time.Second)
+	cpu := controllerOf(e, config.CPUCondition)
+	cpu.phase = PhaseRecovering
+
+	cpu.transition(e, PhaseEvicting)
+	if cpu.phase != PhaseRecovering {
+		t.Errorf("phase after invalid transition is %s, want %s", cpu.phase, PhaseRecovering)
+	}
+}
@@ -0,0 +1,182 @@
+package evictionmanager
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"eviction-agent/cmd/options"
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/metrics"
+	"eviction-agent/pkg/tracing"
+	"eviction-agent/pkg/types"
+)
+
+// drainEvictType is the eviction request of drain, handled after all conditions
+const drainEvictType = "Drain"
+
+// drainPriority is the priority of drain requests in eviction queue
+const drainPriority = nodeProblemPriority + 1
+
+var (
+	drains = metrics.NewCounterVec("eviction_agent_drains_total",
+		"Number of drains started by the unrecoverable condition.", "condition")
+	drainEvictions = metrics.NewCounterVec("eviction_agent_drain_evictions_total",
+		"Number of pods evicted by drain by result.", "result")
+)
+
+// drain escalates a condition busy for after despite evictions, e.g. of a
+// dying disk or NIC, to draining node. Node is cordoned and pods not protected
+// are evicted one by one at pace, evictions respect PDBs. The condition is
+// kept in a node annotation, so that a restarted agent goes on draining.
+type drain struct {
+	after time.Duration
+	pace  time.Duration
+	// condition is draining node, empty if node is not drained. It and
+	// lastRequest are only used by taint process.
+	condition   string
+	lastRequest time.Time
+	// blocked are pods failed to evict in this round, e.g. by PDBs, only used by eviction loop
+	blocked map[string]bool
+	drained int32 // set to 1 after drained is reported
+	// preempted is set to 1 once instance of node is preempted, pods of
+	// higher priority are evicted first then
+	preempted int32
+}
+
+func newDrain(eao *options.EvictionAgentOptions) *drain {
+	if (eao.DrainAfter <= 0 && eao.SpotPreemption == "") || eao.Mode != options.ModeFull {
+		return nil
+	}
+	return &drain{after: eao.DrainAfter, pace: eao.DrainPace, blocked: make(map[string]bool)}
+}
+
+// restoreDrain goes on draining node drained by the last run
+func (e *evictionManager) restoreDrain() {
+	if e.drain == nil {
+		return
+	}
+	annotations, err := e.client.GetNodeAnnotations()
+	if err != nil {
+		log.Errorf("restore drain get node annotations error: %v", err)
+		return
+	}
+	if condition := annotations[types.DrainingAnnotation]; condition != "" {
+		log.Warnf("Node is drained by %s, go on draining", condition)
+		e.drain.condition = condition
+	}
+}
+
+// checkDrain starts draining node once a condition is unrecoverable, and
+// requests an eviction of drain at pace. It's called by taint process.
+func (e *evictionManager) checkDrain() {
+	d := e.drain
+	if d == nil || e.observing() {
+		return
+	}
+	now := e.clock.Now()
+	if d.condition == "" && d.after > 0 {
+		for _, controller := range e.controllers {
+			if controller.phase == PhaseEvicting && !controller.busySince.IsZero() &&
+				now.Sub(controller.busySince) >= d.after {
+				e.startDrain(controller.name, now.Sub(controller.busySince))
+				break
+			}
+		}
+		if d.condition == "" {
+			return
+		}
+	}
+	if now.Sub(d.lastRequest) >= d.pace {
+		d.lastRequest = now
+		e.queue.Push(types.Condition{Type: drainEvictType, Severity: types.SeverityCritical})
+	}
+}
+
+// startDrain cordons node and records the condition draining it
+func (e *evictionManager) startDrain(condition string, busy time.Duration) {
+	message := fmt.Sprintf("%s is busy for %v despite evictions, node is cordoned and drained",
+		condition, busy.Round(time.Second))
+	log.Warnf("%s", message)
+	if err := e.client.CordonNode(); err != nil {
+		log.Errorf("drain cordon node error: %v", err)
+		e.status.recordError(fmt.Sprintf("drain cordon node error: %v", err))
+		e.failOnFatal(err)
+		return
+	}
+	if err := e.client.AnnotateNode(map[string]string{types.DrainingAnnotation: condition}); err != nil {
+		// drain goes on, but it's not restored after restart
+		log.Errorf("drain annotate node error: %v", err)
+	}
+	e.drain.condition = condition
+	drains.Inc(condition)
+	e.client.RecordNodeEvent(types.WarningEvent, types.NodeDrainingReason, message)
+}
+
+// drainOnePod evicts the first pod not blocked in this round, all pods are
+// tried again once every one of them is blocked. evictType is drainEvictType
+// or preemptionEvictType. It's called by eviction loop.
+func (e *evictionManager) drainOnePod(ctx context.Context, evictType string) {
+	d := e.drain
+	if e.apiUnreachable() {
+		log.Infof("api server is unreachable, skip drain")
+		return
+	}
+	pods, err := e.conditionManager.GetDrainCandidates()
+	if err != nil {
+		log.Errorf("drain get pods error: %v", err)
+		e.status.recordError(fmt.Sprintf("drain get pods error: %v", err))
+		e.failOnFatal(err)
+		return
+	}
+	if len(pods) == 0 {
+		if atomic.CompareAndSwapInt32(&d.drained, 0, 1) {
+			log.Warnf("Node is drained, all pods not protected are evicted")
+			e.client.RecordNodeEvent(types.NormalEvent, types.NodeDrainedReason,
+				"All pods not protected are evicted by drain")
+		}
+		return
+	}
+	if atomic.LoadInt32(&d.preempted) == 1 {
+		// pods of higher priority get more time to be scheduled elsewhere
+		for i, j := 0, len(pods)-1; i < j; i, j = i+1, j-1 {
+			pods[i], pods[j] = pods[j], pods[i]
+		}
+	}
+	var pod *types.PodInfo
+	for i := range pods {
+		if !d.blocked[pods[i].Namespace+"/"+pods[i].Name] {
+			pod = &pods[i]
+			break
+		}
+	}
+	if pod == nil {
+		log.Infof("Evictions of all %d pods of drain are blocked, e.g. by PDBs, try them again", len(pods))
+		d.blocked = make(map[string]bool)
+		pod = &pods[0]
+	}
+	owner := e.podOwner(pod)
+	apiCtx, span := tracing.StartClient(ctx, "api.drain")
+	err = e.client.WithContext(apiCtx).EvictOnePod(pod)
+	span.SetError(err)
+	span.End()
+	e.recordAction("evict pod "+pod.Namespace+"/"+pod.Name, err)
+	e.status.recordEviction(evictType, pod, "Evict", nil, err)
+	e.notify("Evict", evictType, pod, err)
+	e.auditAction("Evict", evictType, pod, owner, nil, err)
+	if err != nil {
+		log.Errorf("drain evict pod %s/%s error: %v", pod.Namespace, pod.Name, err)
+		d.blocked[pod.Namespace+"/"+pod.Name] = true
+		drainEvictions.Inc("failed")
+		e.failOnFatal(err)
+		return
+	}
+	drainEvictions.Inc("evicted")
+	log.Infof("Pod %s/%s is evicted by drain, %d pods are left", pod.Namespace, pod.Name, len(pods)-1)
+	message := "Pod is evicted by eviction agent because node is drained"
+	if evictType == preemptionEvictType {
+		message = "Pod is evicted by eviction agent because instance of node is preempted"
+	}
+	e.client.RecordPodEvent(pod, types.NormalEvent, types.PodEvictedReason, message)
+}
@@ -0,0 +1,149 @@
+package evictionmanager
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/util/clock"
+
+	"eviction-agent/cmd/options"
+	"eviction-agent/pkg/condition"
+	"eviction-agent/pkg/config"
+	"eviction-agent/pkg/evictionclient"
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/probes"
+	"eviction-agent/pkg/summary"
+	"eviction-agent/pkg/types"
+)
+
+// ActionHandler is called after each taint, untaint, eviction, label and
+// resize taken by eviction manager, pod is nil for taints
+type ActionHandler func(action, conditionType string, pod *types.PodInfo, err error)
+
+// Option configures the eviction manager created by New
+type Option func(*embedOptions)
+
+type embedOptions struct {
+	client           evictionclient.Client
+	conditionManager condition.ConditionManager
+	stats            summary.SummaryStatsApi
+	policy           *config.PolicyConfig
+	clock            clock.Clock
+	logger           log.Logger
+	handlers         []ActionHandler
+	untaintProbes    map[string]probes.Probe
+}
+
+// WithClient uses client for api calls instead of the one created of options
+func WithClient(client evictionclient.Client) Option {
+	return func(o *embedOptions) {
+		o.client = client
+	}
+}
+
+// WithConditionManager uses conditionManager instead of the one of client,
+// WithStatsSource and WithPolicy are ignored with it
+func WithConditionManager(conditionManager condition.ConditionManager) Option {
+	return func(o *embedOptions) {
+		o.conditionManager = conditionManager
+	}
+}
+
+// WithStatsSource gets node and pod stats from source instead of kubelet
+// summary api
+func WithStatsSource(source summary.SummaryStatsApi) Option {
+	return func(o *embedOptions) {
+		o.stats = source
+	}
+}
+
+// WithPolicy uses policy instead of the policy configuration file
+func WithPolicy(policy *config.PolicyConfig) Option {
+	return func(o *embedOptions) {
+		o.policy = policy
+	}
+}
+
+// WithClock uses clk for all timing
+func WithClock(clk clock.Clock) Option {
+	return func(o *embedOptions) {
+		o.clock = clk
+	}
+}
+
+// WithLogger logs with logger. Logging is global, it replaces the logger of
+// the process.
+func WithLogger(logger log.Logger) Option {
+	return func(o *embedOptions) {
+		o.logger = logger
+	}
+}
+
+// WithActionHandler calls handler after each action, it can be repeated
+func WithActionHandler(handler ActionHandler) Option {
+	return func(o *embedOptions) {
+		o.handlers = append(o.handlers, handler)
+	}
+}
+
+// WithUntaintProbe runs probe before untainting node of condition, replacing
+// the one of --untaint-probes, e.g. a benchmark of the device of condition.
+// Node is kept tainted for another grace period if it returns error.
+func WithUntaintProbe(condition string, probe probes.Probe) Option {
+	return func(o *embedOptions) {
+		if o.untaintProbes == nil {
+			o.untaintProbes = make(map[string]probes.Probe)
+		}
+		o.untaintProbes[condition] = probe
+	}
+}
+
+// New creates the eviction manager configured by eao and opts, for other
+// node agents embedding it. The client of eao.NodeName is created unless
+// WithClient is given.
+func New(eao *options.EvictionAgentOptions, opts ...Option) (EvictionManager, error) {
+	o := embedOptions{clock: clock.RealClock{}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.logger != nil {
+		log.DefaultLogger = o.logger
+	}
+	client := o.client
+	if client == nil {
+		var err error
+		if client, err = evictionclient.NewClient(eao); err != nil {
+			return nil, err
+		}
+	}
+	if o.stats != nil {
+		client = &statsClient{Client: client, stats: o.stats}
+	}
+	conditionManager := o.conditionManager
+	if conditionManager == nil {
+		conditionOpts := []condition.Option{condition.WithClock(o.clock)}
+		if o.policy != nil {
+			conditionOpts = append(conditionOpts, condition.WithPolicy(o.policy))
+		}
+		conditionManager = condition.New(client, eao, conditionOpts...)
+	}
+	e := newEvictionManager(client, conditionManager, eao, o.clock)
+	e.actionHandlers = o.handlers
+	for condition, probe := range o.untaintProbes {
+		e.untaintProbes.probes[condition] = probe
+	}
+	return e, nil
+}
+
+// statsClient gets stats from the source of WithStatsSource
+type statsClient struct {
+	evictionclient.Client
+	stats summary.SummaryStatsApi
+}
+
+func (c *statsClient) GetSummaryStats() (*summary.ConditionStats, error) {
+	return c.stats.GetSummaryStats()
+}
+
+func (c *statsClient) WithContext(ctx context.Context) evictionclient.Client {
+	return &statsClient{Client: c.Client.WithContext(ctx), stats: c.stats}
+}
@@ -0,0 +1,281 @@
+package evictionmanager
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"eviction-agent/cmd/options"
+	"eviction-agent/pkg/condition"
+	conditionfake "eviction-agent/pkg/condition/fake"
+	"eviction-agent/pkg/evictionclient"
+	"eviction-agent/pkg/types"
+)
+
+// envtestNode is the node of the agent, created in envtest
+const envtestNode = "node-1"
+
+// testEnv is the control plane of envtest, etcd and kube-apiserver of
+// KUBEBUILDER_ASSETS, e.g. installed by setup-envtest
+type testEnv struct {
+	kubeconfig string
+	client     kubernetes.Interface
+}
+
+// startTestEnv starts etcd and kube-apiserver, they are stopped when t ends.
+// t is skipped if KUBEBUILDER_ASSETS is not set.
+func startTestEnv(t *testing.T) *testEnv {
+	assets := os.Getenv("KUBEBUILDER_ASSETS")
+	if assets == "" {
+		t.Skip("KUBEBUILDER_ASSETS is not set, envtest needs etcd and kube-apiserver")
+	}
+	dir := t.TempDir()
+	etcdPort, peerPort, apiPort := freePort(t), freePort(t), freePort(t)
+	etcdURL := fmt.Sprintf("http://127.0.0.1:%d", etcdPort)
+	peerURL := fmt.Sprintf("http://127.0.0.1:%d", peerPort)
+	startProcess(t, dir, filepath.Join(assets, "etcd"),
+		"--data-dir="+filepath.Join(dir, "etcd"),
+		"--listen-client-urls="+etcdURL,
+		"--advertise-client-urls="+etcdURL,
+		"--listen-peer-urls="+peerURL,
+		"--initial-advertise-peer-urls="+peerURL,
+		"--initial-cluster=default="+peerURL)
+
+	token := randomHex(t)
+	writeFile(t, filepath.Join(dir, "tokens.csv"), token+",admin,admin,system:masters\n")
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(dir, "sa.key"), string(pem.EncodeToMemory(&pem.Block{
+		Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})))
+	startProcess(t, dir, filepath.Join(assets, "kube-apiserver"),
+		"--etcd-servers="+etcdURL,
+		"--cert-dir="+filepath.Join(dir, "certs"),
+		"--bind-address=127.0.0.1",
+		"--advertise-address=127.0.0.1",
+		fmt.Sprintf("--secure-port=%d", apiPort),
+		"--token-auth-file="+filepath.Join(dir, "tokens.csv"),
+		"--authorization-mode=AlwaysAllow",
+		"--service-cluster-ip-range=10.0.0.0/24",
+		"--service-account-issuer=https://kubernetes.default.svc",
+		"--service-account-key-file="+filepath.Join(dir, "sa.key"),
+		"--service-account-signing-key-file="+filepath.Join(dir, "sa.key"),
+		// pods are created without service accounts, there is no controller manager
+		"--disable-admission-plugins=ServiceAccount",
+		"--allow-privileged=true")
+
+	env := &testEnv{kubeconfig: filepath.Join(dir, "kubeconfig")}
+	writeFile(t, env.kubeconfig, fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: envtest
+  cluster:
+    server: https://127.0.0.1:%d
+    insecure-skip-tls-verify: true
+users:
+- name: admin
+  user:
+    token: %s
+contexts:
+- name: envtest
+  context:
+    cluster: envtest
+    user: admin
+current-context: envtest
+`, apiPort, token))
+	config, err := clientcmd.BuildConfigFromFlags("", env.kubeconfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.client = client
+	// the default namespace is created once kube-apiserver is ready
+	deadline := time.Now().Add(time.Minute)
+	for {
+		_, err := client.CoreV1().Namespaces().Get(metav1.NamespaceDefault, metav1.GetOptions{})
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("kube-apiserver is not ready in a minute: %v", err)
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	return env
+}
+
+// startProcess runs binary in background, it's killed when t ends
+func startProcess(t *testing.T, dir, binary string, args ...string) {
+	logFile, err := os.Create(filepath.Join(dir, filepath.Base(binary)+".log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(binary, args...)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	if err := cmd.Start(); err != nil {
+		logFile.Close()
+		t.Fatalf("start %s error: %v", binary, err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+		logFile.Close()
+		if t.Failed() {
+			if data, err := ioutil.ReadFile(logFile.Name()); err == nil {
+				t.Logf("%s log:\n%s", filepath.Base(binary), data)
+			}
+		}
+	})
+}
+
+func freePort(t *testing.T) int {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func randomHex(t *testing.T) string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+func writeFile(t *testing.T, path, data string) {
+	if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newEnvtestManager creates the node in env, and an eviction manager of the
+// real client and the fake condition manager evaluating every period
+func newEnvtestManager(t *testing.T, env *testEnv, period time.Duration) (*evictionManager, *conditionfake.ConditionManager, *clock.FakeClock) {
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: envtestNode},
+		Status: v1.NodeStatus{
+			Addresses: []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: "127.0.0.1"}},
+		},
+	}
+	if _, err := env.client.CoreV1().Nodes().Create(node); err != nil {
+		t.Fatal(err)
+	}
+	eao := options.NewEvictionAgentOptions()
+	eao.NodeName = envtestNode
+	eao.KubeconfigFile = env.kubeconfig
+	eao.EvaluationPeriod = period
+	client, err := evictionclient.NewClient(eao)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clk := clock.NewFakeClock(time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC))
+	conditions := conditionfake.NewConditionManagerWithClock(clk)
+	e := NewEvictionManagerWithClock(client, conditions, eao, clk).(*evictionManager)
+	return e, conditions, clk
+}
+
+// nodeTainted returns true if the node in env has taint key
+func nodeTainted(t *testing.T, env *testEnv, key string) bool {
+	node, err := env.client.CoreV1().Nodes().Get(envtestNode, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, taint := range node.Spec.Taints {
+		if taint.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// TestEnvtest runs the eviction manager against kube-apiserver of envtest
+func TestEnvtest(t *testing.T) {
+	env := startTestEnv(t)
+
+	t.Run("TaintAndUntaint", func(t *testing.T) {
+		const period, grace = time.Second, 30 * time.Second
+		e, conditions, clk := newEnvtestManager(t, env, period)
+		defer env.client.CoreV1().Nodes().Delete(envtestNode, &metav1.DeleteOptions{})
+		conditions.SetUnTaintGracePeriod(grace)
+		ctx := context.Background()
+
+		conditions.Update(func(c *condition.NodeCondition) { c.CPUAvailable = false })
+		clk.Step(period)
+		e.evaluate(ctx)
+		if !nodeTainted(t, env, types.CPUBusy) {
+			t.Fatalf("node is not tainted %s while CPU is busy", types.CPUBusy)
+		}
+
+		conditions.Update(func(c *condition.NodeCondition) { c.CPUAvailable = true })
+		clk.Step(grace)
+		e.evaluate(ctx)
+		if !nodeTainted(t, env, types.CPUBusy) {
+			t.Fatalf("node is untainted at grace period %v", grace)
+		}
+		clk.Step(period)
+		e.evaluate(ctx)
+		if nodeTainted(t, env, types.CPUBusy) {
+			t.Fatalf("node is still tainted past grace period %v", grace)
+		}
+	})
+
+	t.Run("EvictPod", func(t *testing.T) {
+		e, conditions, _ := newEnvtestManager(t, env, time.Second)
+		defer env.client.CoreV1().Nodes().Delete(envtestNode, &metav1.DeleteOptions{})
+		pod, err := env.client.CoreV1().Pods(metav1.NamespaceDefault).Create(&v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "busy"},
+			Spec: v1.PodSpec{
+				NodeName:   envtestNode,
+				Containers: []v1.Container{{Name: "busy", Image: "busybox"}},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		conditions.SetAutoEvict(true)
+		conditions.SetCandidates(types.CPUBusy, condition.Candidate{
+			Pod:   types.PodInfo{Name: pod.Name, Namespace: pod.Namespace, UID: string(pod.UID)},
+			Score: 1,
+			Label: types.NeedEvict,
+		})
+
+		decision := e.evictOnePod(context.Background(), types.Condition{Type: types.CPUBusy}, false)
+		if decision.Error != "" || decision.Action != "Evict" {
+			t.Fatalf("pod is not evicted, decision is %+v", decision)
+		}
+		// pods on a node are deleted gracefully, there is no kubelet to finish it
+		evicted, err := env.client.CoreV1().Pods(pod.Namespace).Get(pod.Name, metav1.GetOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			t.Fatal(err)
+		}
+		if err == nil && evicted.DeletionTimestamp == nil {
+			t.Errorf("pod is not evicted, decision is %+v", decision)
+		}
+	})
+}
@@ -1,41 +1,186 @@
 package evictionmanager
 
 import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"k8s.io/apimachinery/pkg/util/clock"
+
+	"eviction-agent/cmd/options"
+	"eviction-agent/pkg/apis/v1alpha1"
 	"eviction-agent/pkg/types"
 	"eviction-agent/pkg/evictionclient"
 	"eviction-agent/pkg/condition"
 	"eviction-agent/pkg/log"
-)
-
-const (
-	// updatePeriod is the period
-	taintUpdatePeriod = 10 * time.Second
+	"eviction-agent/pkg/webhook"
+	"eviction-agent/pkg/alertmanager"
+	"eviction-agent/pkg/audit"
+	"eviction-agent/pkg/certs"
+	"eviction-agent/pkg/dns"
+	"eviction-agent/pkg/links"
+	"eviction-agent/pkg/preemption"
+	"eviction-agent/pkg/services"
+	"eviction-agent/pkg/timesync"
+	"eviction-agent/pkg/tracing"
+	"eviction-agent/pkg/config"
 )
 
 type EvictionManager interface {
-	Run() error
+	// Run runs until ctx is done, the action in flight is finished before it returns
+	Run(ctx context.Context) error
+	// HealthCheck returns error if taint loop or stats sync is stuck
+	HealthCheck() error
+	// ReadyCheck returns error if there is no valid sample or api server is unreachable
+	ReadyCheck() error
+	// GetStatsSamples returns stats samples of condition manager
+	GetStatsSamples() []condition.StatsSample
+	// GetLastEvictions returns the latest eviction decisions
+	GetLastEvictions() []v1alpha1.EvictionRecord
+	// GetHistory returns the latest decisions choosing or considering pod, or all of them if pod is empty
+	GetHistory(pod string) []Decision
+	// GetExplanation returns the latest decision choosing pod
+	GetExplanation(pod string) (*Decision, error)
+	// GetConditions returns node conditions, taints and phases of the latest cycle
+	GetConditions() *Conditions
+	// GetEvictionCandidates returns the ranked candidates of evictType
+	GetEvictionCandidates(evictType string) ([]condition.Candidate, error)
+	// GetTopPods returns the top pods by usage of each resource
+	GetTopPods() (*condition.TopPods, error)
+	// Evict chooses and evicts a pod of evictType on demand
+	Evict(ctx context.Context, evictType string) (*Decision, error)
+	// Pause pauses actions until until, or until Resume if it's zero
+	Pause(until time.Time) (*ControlResult, error)
+	// Resume resumes actions paused
+	Resume() (*ControlResult, error)
+	// TriggerEvaluation evaluates conditions now instead of at the next tick
+	TriggerEvaluation() *ControlResult
+}
+
+// Conditions is the node conditions and taints seen by eviction manager
+type Conditions struct {
+	Condition condition.NodeCondition `json:"condition"`
+	Taints    types.NodeTaintInfo     `json:"taints"`
+	Phases    []PhaseStatus           `json:"phases"`
+	// Mode is full, taint-only or monitor-only
+	Mode string `json:"mode"`
 }
 
 type evictionManager struct {
 	client              evictionclient.Client
 	conditionManager    condition.ConditionManager
-	evictChan           chan string
+	queue               *evictionQueue
 	nodeTaint           types.NodeTaintInfo
 	unTaintGracePeriod  time.Duration
-	lastTaintDiskIOTime time.Time
-	lastTaintNetIOTime  time.Time
-	lastTaintCPUTime    time.Time
-	lastTaintMemTime    time.Time
+	controllers         []*conditionController
+	tickPeriod          time.Duration // the shortest period of controllers
+	tickJitter          float64
+	trigger             chan struct{} // evaluates at once, of TriggerEvaluation
+	clock               clock.Clock
+	status              *statusReporter
+	stuckThreshold      time.Duration
+	lastTaintLoopTime   int64 // unix nano
+	lastAPISuccessTime  int64 // unix nano
+	reachability        reachability // of api server, evictions are suspended while it's unreachable
+	manual              manualEvictions // waiters of evictions requested by api
+	pause               pause // of node annotation, actions are paused like observation
+	lastCondition       atomic.Value // condition.NodeCondition of the latest cycle
+	lastTaint           atomic.Value // types.NodeTaintInfo of the latest cycle
+	lastPhases          atomic.Value // []PhaseStatus of the latest cycle
+	nodeName            string
+	notifier            *webhook.Notifier
+	alerts              *alertmanager.Client // disabled if nil
+	audit               *audit.Logger
+	history             *history
+	topPodsPeriod       time.Duration // disabled if zero
+	topPodsCount        int
+	activity            *activity       // summary of activity, disabled if nil
+	rebalanceHints      *rebalanceHints // disabled if nil, only used by taint process
+	resizeAction        string          // disabled if empty
+	resizeRatio         float64
+	services            *services.Monitor // checks critical services, disabled if nil
+	links               *links.Monitor // checks monitored links, disabled if nil
+	dns                 *dns.Prober // probes names of DNS, disabled if nil
+	timesync            *timesync.Monitor // checks clock of node, disabled if nil
+	preemption          *preemption.Monitor // checks preemption notice of spot instance, disabled if nil
+	spotDrainPace       time.Duration
+	clockSkewed         bool // of the last check, only used by clock reporter
+	certs               *certs.Monitor // checks kubelet certificates, disabled if nil
+	tenants             *tenantBudgets    // eviction budgets of tenants, disabled if nil
+	quarantine          *quarantine       // quarantines repeat offenders, disabled if nil
+	mode                string            // full, taint-only or monitor-only
+	namespacePreferences bool             // namespace annotations are read by condition manager
+	observation         *observation      // canary of --observe-for, disabled if nil
+	drain               *drain            // drains node on unrecoverable conditions, disabled if nil
+	runtime             *runtimeExemption // exempts io conditions while containers are created, disabled if nil
+	untaintProbes       untaintProbes     // verify conditions before untainting node
+	taintDelay          time.Duration     // conditions stay busy in soft pressure before taint
+	placement           *placement        // paces evictions by placement of replacements, disabled if nil
+	planner             *planner          // plans multiple victims, disabled if nil
+	headroom            *headroom         // spare capacity of cluster, disabled if nil
+	watchdog            *watchdog         // checks loops complete their cycles, disabled if nil
+	textfile            *textfile         // writes conditions for node_exporter, disabled if nil
+	concurrentPace      time.Duration     // min interval between evictions of busy conditions
+	safeMode            *safeMode         // stops actions after repeated failures, disabled if nil
+	actionHandlers      []ActionHandler   // of WithActionHandler
+	cancel              context.CancelFunc // stops Run on fatal error
+	fatalOnce           sync.Once
+	fatalErr            error // *types.FatalError returned by Run
 }
 
 // NewEvictionManager creates the eviction manager.
-func NewEvictionManager(client evictionclient.Client, configFile string) EvictionManager {
-	return &evictionManager{
+func NewEvictionManager(client evictionclient.Client, eao *options.EvictionAgentOptions) EvictionManager {
+	return newEvictionManager(client, nil, eao, clock.RealClock{})
+}
+
+// NewEvictionManagerWithClock creates the eviction manager of conditionManager
+// using clk for all timing, e.g. with fakes of evictionclient and condition.
+// The condition manager of client is created if conditionManager is nil.
+func NewEvictionManagerWithClock(client evictionclient.Client, conditionManager condition.ConditionManager,
+	eao *options.EvictionAgentOptions, clk clock.Clock) EvictionManager {
+	return newEvictionManager(client, conditionManager, eao, clk)
+}
+
+// newEvictionManager creates the eviction manager using clk for all timing
+func newEvictionManager(client evictionclient.Client, conditionManager condition.ConditionManager,
+	eao *options.EvictionAgentOptions, clk clock.Clock) *evictionManager {
+	if conditionManager == nil {
+		conditionManager = condition.NewConditionManagerWithClock(client, eao, clk)
+	}
+	e := &evictionManager{
 		client:           client,
-		conditionManager: condition.NewConditionManager(client, configFile),
-		evictChan:        make(chan string, 1),
+		clock:            clk,
+		conditionManager: conditionManager,
+		status:           newStatusReporter(client, eao.NodeName, eao.StatusNamespace, clk),
+		stuckThreshold:   eao.HealthStuckThreshold,
+		watchdog:         newWatchdog(eao),
+		textfile:         newTextfile(eao.TextfileDir),
+		safeMode:         newSafeMode(eao),
+		nodeName:         eao.NodeName,
+		notifier:         newNotifier(eao),
+		alerts:           newAlerts(eao),
+		audit:            newAuditLoggerOrDie(eao),
+		queue:            newEvictionQueue(eao.GetConditionPriorityOrDie(),
+			eao.ConcurrentPressure == options.ConcurrentPressureMostSevere),
+		concurrentPace:   eao.ConcurrentPressurePace,
+		history:          newHistory(eao.HistorySize),
+		controllers:      newConditionControllers(eao.EvaluationPeriod, eao.GetConditionPeriodsOrDie(),
+			eao.GetExtendedResourcesOrDie(), eao.GetNodeProblemConditionsOrDie(), eao.GetKubeletPrecedenceOrDie(),
+			clk.Now()),
+		tickPeriod:       eao.GetStatsPeriod(),
+		tickJitter:       eao.EvaluationJitter,
+		trigger:          make(chan struct{}, 1),
+		topPodsPeriod:    eao.TopPodsPeriod,
+		topPodsCount:     eao.TopPodsCount,
+		resizeAction:     eao.ResizeAction,
+		resizeRatio:      eao.ResizeRatio,
+		untaintProbes:    untaintProbes{probes: eao.GetUntaintProbesOrDie(), timeout: eao.UntaintProbeTimeout},
+		taintDelay:       eao.TaintDelay,
+		mode:             eao.Mode,
+		namespacePreferences: eao.NamespacePreferences,
 		nodeTaint:        types.NodeTaintInfo{
 			DiskIO:    false,
 			NetworkIO: false,
@@ -43,219 +188,939 @@ func NewEvictionManager(client evictionclient.Client, configFile string) Evictio
 			Memory:    false,
 		},
 	}
+	if eao.RebalanceHints {
+		e.rebalanceHints = newRebalanceHints(eao.RebalanceHintDelay)
+	}
+	if eao.TenantLabel != "" && eao.TenantEvictionBudget > 0 {
+		e.tenants = newTenantBudgets(eao.TenantLabel, eao.TenantEvictionBudget, eao.TenantBudgetWindow, clk)
+	}
+	if eao.ObserveFor > 0 {
+		e.observation = &observation{duration: eao.ObserveFor}
+	}
+	if eao.QuarantineThreshold > 0 {
+		e.quarantine = &quarantine{threshold: eao.QuarantineThreshold, window: eao.QuarantineWindow}
+	}
+	e.drain = newDrain(eao)
+	if e.drain != nil && eao.SpotPreemption != "" {
+		e.preemption = preemption.NewMonitor(eao.SpotPreemption, eao.SpotPreemptionPeriod)
+		e.spotDrainPace = eao.SpotDrainPace
+	}
+	e.activity = newActivity(eao.SummaryPeriod, e.clock.Now())
+	if eao.RuntimeExemption > 0 {
+		e.runtime = &runtimeExemption{window: eao.RuntimeExemption}
+	}
+	if eao.PlacementWait > 0 {
+		e.placement = &placement{wait: eao.PlacementWait, maxReturns: eao.PlacementReturns, backoff: eao.PlacementBackoff}
+	}
+	e.headroom = newHeadroom(eao)
+	e.history.store = newHistoryStoreOrDie(client, eao)
+	if eao.PlanVictims {
+		e.planner = &planner{pace: eao.PlanPace, maxVictims: eao.PlanMaxVictims}
+	}
+	if units := eao.GetCriticalServices(); len(units) != 0 {
+		e.services = services.NewMonitor(units, eao.ServiceCommandPrefix, eao.ServiceCheckPeriod, eao.ServiceJournalErrors)
+	}
+	if interfaces := eao.GetMonitoredLinks(); len(interfaces) != 0 {
+		e.links = links.NewMonitor(interfaces, eao.LinkCheckPeriod, eao.LinkFlaps)
+	}
+	if names := eao.GetDNSProbeNames(); len(names) != 0 {
+		e.dns = dns.NewProber(names, eao.DNSProbeServer, eao.DNSProbePeriod, eao.DNSProbeTimeout, eao.DNSFailureThreshold)
+	}
+	if eao.MaxClockOffset > 0 {
+		e.timesync = timesync.NewMonitor(eao.ServiceCommandPrefix, eao.ClockCheckPeriod, eao.MaxClockOffset)
+	}
+	if paths := eao.GetKubeletCertificates(); len(paths) != 0 {
+		e.certs = certs.NewMonitor(paths, eao.CertificateCheckPeriod, eao.CertificateExpiryThreshold)
+	}
+	e.lastPhases.Store(e.phases())
+	return e
+}
+
+// newNotifier creates webhook notifier, returns nil if webhook is not configured
+func newNotifier(eao *options.EvictionAgentOptions) *webhook.Notifier {
+	if eao.WebhookURL == "" {
+		return nil
+	}
+	return webhook.NewNotifier(eao.WebhookURL, eao.WebhookTimeout, eao.WebhookRetries)
+}
+
+// newAuditLoggerOrDie creates audit logger, returns nil if audit log is not configured
+func newAuditLoggerOrDie(eao *options.EvictionAgentOptions) *audit.Logger {
+	if eao.AuditLogFile == "" {
+		return nil
+	}
+	l, err := audit.NewLogger(eao.AuditLogFile, int64(eao.AuditLogMaxSize)*1024*1024, eao.AuditLogMaxBackups)
+	if err != nil {
+		log.Errorf("Open audit log %s error: %v", eao.AuditLogFile, err)
+		panic(err)
+	}
+	return l
 }
 
 // Run starts the eviction manager
-func (e *evictionManager) Run() error {
+func (e *evictionManager) Run(ctx context.Context) error {
 	// Start condition manager
 	// get and update node condition and pod condition
-	err := e.conditionManager.Start()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	e.cancel = cancel
+	if err := e.checkPermissions(); err != nil {
+		return err
+	}
+	err := e.conditionManager.Start(ctx)
 	if err != nil {
 		return err
 	}
 
+	e.startObservation()
+	e.checkPause()
+	e.restoreState()
+	e.restoreDrain()
+	e.history.load()
+	e.checkUntaintProbes()
+
 	// Taint process
-	go e.taintProcess()
+	atomic.StoreInt64(&e.lastTaintLoopTime, e.clock.Now().UnixNano())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		e.taintProcess(ctx)
+	}()
+	if e.topPodsPeriod > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			e.reportTopPods(ctx)
+		}()
+	}
+	if e.alerts != nil {
+		go e.alerts.Run(ctx)
+	}
+	if e.headroom != nil {
+		go e.checkHeadroom(ctx)
+	}
+	if e.watchdog != nil {
+		go e.runWatchdog(ctx)
+	}
+	if e.history.store != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			e.saveHistory(ctx)
+		}()
+	}
+	if e.activity != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			e.reportActivity(ctx)
+		}()
+	}
+	if e.services != nil {
+		go e.services.Run(ctx, e.reportServices)
+	}
+	if e.links != nil {
+		go e.links.Run(ctx, e.reportLinks)
+	}
+	if e.dns != nil {
+		go e.dns.Run(ctx, e.reportDNS)
+	}
+	if e.timesync != nil {
+		go e.timesync.Run(ctx, e.reportClock)
+	}
+	if e.preemption != nil {
+		go e.preemption.Run(ctx, func(notice *preemption.Notice) {
+			e.drainPreempted(ctx, notice)
+		})
+	}
+	if e.certs != nil {
+		go e.certs.Run(ctx, e.reportCertificates)
+	}
 
 	// Main run loop waiting on evicting request
 	for {
-		// wait for evict event
-		select {
-		case evictType := <-e.evictChan:
-			log.Infof("evict pod because %s is not available", evictType)
-		    e.evictOnePod(evictType)
+		// wait for evict request with the highest priority
+		request, ok := e.queue.Pop(ctx)
+		if !ok {
+			// no new eviction is started, wait for taint process to finish its cycle
+			log.Infof("Stop eviction manager, wait for taint process")
+			wg.Wait()
+			log.Infof("Eviction manager stopped")
+			return e.fatalErr
+		}
+		if request.Type == drainEvictType || request.Type == preemptionEvictType {
+			e.drainOnePod(ctx, request.Type)
+			continue
+		}
+		if manualEvictType, ok := parseManualEvictType(request.Type); ok {
+			request.Type = manualEvictType
+			e.evictManually(ctx, request)
+			continue
+		}
+		log.Infof("evict pod because %s is not available, severity %s", request.Type, request.Severity)
+		e.evictOnePod(ctx, request, false)
+		if e.concurrentPace > 0 {
+			// usage is measured again before evicting for the next condition
+			select {
+			case <-ctx.Done():
+			case <-e.clock.After(e.concurrentPace):
+			}
 		}
 	}
-	return nil
 }
 
-// evictOnePod call client to evict pod
-func (e *evictionManager) evictOnePod(evictType string) {
-	podToEvict, isEvict, priority, err:= e.conditionManager.ChooseOnePodToEvict(evictType)
+// restoreState reconciles taints and evict labels left by the last run.
+// Taints of enabled conditions are adopted, so that a restarted agent waits
+// untaint grace period again instead of untainting a node which may be still
+// busy, and the labels added for them are kept. Taints of disabled conditions
+// and labels of untainted conditions are removed. Taint times are not kept on
+// node, they are taken as now, which is never earlier than the real ones.
+func (e *evictionManager) restoreState() {
+	nodeTaint, err := e.client.GetTaintConditions()
 	if err != nil {
+		log.Errorf("restore state get taint condition error: %v", err)
+		return
+	}
+	e.nodeTaint = nodeTaint
+	if e.mode == options.ModeMonitorOnly || e.observing() {
+		// taints and labels are left as they are
+		e.lastTaint.Store(nodeTaint)
+		if e.paused() {
+			e.adoptObservedTaints()
+		}
+		return
+	}
+	nodeCondition := e.conditionManager.GetNodeCondition()
+	var staleEvictTypes []string
+	adopted, removed := false, false
+	for _, controller := range e.controllers {
+		tainted := controller.tainted(&nodeTaint)
+		if tainted && !e.conditionManager.ConditionEnabled(controller.name) &&
+			e.untaintDisabled(controller.taintKey, nodeCondition) {
+			tainted, removed = false, true
+		}
+		if !tainted {
+			staleEvictTypes = append(staleEvictTypes, controller.evictTypes...)
+			continue
+		}
+		adopted = true
+		controller.lastTaintTime = e.clock.Now()
+		controller.transition(e, PhaseTainted)
+		log.Infof("Restore taint %s, untaint it after grace period", controller.taintKey)
+		// alerts of the last run are resolved by Alertmanager once they are not sent
+		e.alert(controller.taintKey, "Taint", nodeCondition)
+	}
+	if removed {
+		if t, err := e.client.GetTaintConditions(); err == nil {
+			nodeTaint = t
+			e.nodeTaint = t
+		}
+	}
+	e.lastTaint.Store(nodeTaint)
+	e.lastPhases.Store(e.phases())
+
+	if !e.labelsEnabled() {
+		return
+	}
+	if adopted {
+		// labels of the adopted taints, or added by an older agent, are kept
+		log.Infof("Clear evict labels of untainted conditions left by the last run")
+		err = e.client.ClearEvictLabels(staleEvictTypes)
+	} else {
+		log.Infof("Node is not tainted, clear evict labels left by the last run")
+		err = e.client.ClearAllEvictLabels()
+	}
+	if err != nil {
+		log.Errorf("restore state clear evict labels error: %v", err)
+	}
+}
+
+// evictOnePod call client to evict pod for the request, manual is true if
+// it's requested by api instead of the condition. Returns the decision.
+func (e *evictionManager) evictOnePod(ctx context.Context, request types.Condition, manual bool) (decision Decision) {
+	evictType := request.Type
+	ctx, span := tracing.Start(ctx, "eviction")
+	defer span.End()
+	span.SetAttribute("condition", evictType)
+	span.SetAttribute("severity", string(request.Severity))
+	// pods on node are listed once for choosing, protection and tenants
+	e.client.BeginCycle()
+	defer e.client.EndCycle()
+	nodeCondition, _ := e.lastCondition.Load().(condition.NodeCondition)
+	decision = Decision{
+		Time:         e.clock.Now(),
+		Condition:    evictType,
+		Manual:       manual,
+		Severity:     request.Severity,
+		Measurements: measurements(&nodeCondition),
+		Result:       "Skipped",
+	}
+	defer func() {
+		e.history.add(decision)
+		e.activity.recordDecision(&decision)
+	}()
+	for _, controller := range e.controllers {
+		if share, ok := nodeCondition.Daemons[controller.name]; ok && containsString(controller.evictTypes, evictType) {
+			decision.Daemon = &share
+		}
+	}
+	// pods are labeled under warning, and evicted at once under critical
+	var severity types.Severity
+	if !manual {
+		severity = gradedSeverity([]string{evictType}, &nodeCondition)
+	}
+	if severity != "" {
+		decision.Severity = severity
+	}
+
+	// pods are chosen by stale pods and taints, requests are pushed again once api server is reached
+	if e.apiUnreachable() {
+		log.Infof("api server is unreachable, skip eviction of %s", evictType)
+		decision.Error = "suspended while api server is unreachable"
+		return
+	}
+	// pods are evicted one at a time by scheduler placement of replacements
+	if reason := e.placementBlocked(); reason != "" {
+		log.Infof("skip eviction of %s: %s", evictType, reason)
+		decision.Error = reason
+		return
+	}
+	// requests queued before kubelet reports pressure, or containers are created
+	for _, controller := range e.controllers {
+		if containsString(controller.evictTypes, evictType) && controller.kubeletEvicting(&nodeCondition) {
+			log.Infof("kubelet reports %s, skip eviction of %s", controller.kubeletPressure, evictType)
+			kubeletBackoffs.Inc(controller.name)
+			decision.Error = fmt.Sprintf("left to kubelet under %s", controller.kubeletPressure)
+			return
+		}
+		if containsString(controller.evictTypes, evictType) && controller.runtimeExempt &&
+			severity != types.SeverityCritical && e.runtimeExempted() {
+			log.Infof("containers are created on node, skip eviction of %s", evictType)
+			runtimeExemptions.Inc(controller.name)
+			decision.Error = "exempted while containers are created"
+			return
+		}
+	}
+
+	if err := e.skipExhaustedTenants(); err != nil {
+		log.Errorf("evictOnePod skip exhausted tenants error: %v", err)
+		e.status.recordError(fmt.Sprintf("skip exhausted tenants error: %v", err))
+		decision.Error = err.Error()
+		return
+	}
+	_, scoreSpan := tracing.Start(ctx, "candidates.score")
+	rankBy := request
+	rankBy.Type = e.rankBy(evictType)
+	podToEvict, isEvict, priority, err:= e.conditionManager.ChooseOnePodToEvict(rankBy)
+	decision.setRanking(e.conditionManager.GetLastRanking())
+	scoreSpan.SetAttribute("candidates", len(decision.Candidates))
+	scoreSpan.SetAttribute("excluded", len(decision.Excluded))
+	scoreSpan.SetError(err)
+	scoreSpan.End()
+	if err != nil {
+		span.SetError(err)
 		log.Errorf("evictOnePod choose one pod to evict error: %v", err)
+		e.status.recordError(fmt.Sprintf("choose one pod to evict error: %v", err))
+		decision.Error = err.Error()
+		e.failOnFatal(err)
+		return
+	}
+	if severity != "" {
+		severityDecisions.Inc(evictType, string(severity))
+		isEvict = severity == types.SeverityCritical
+	}
+	// evicted pods would be Pending without spare capacity of cluster
+	if isEvict && e.headroomExhausted() {
+		log.Infof("cluster has no headroom, label pod instead of evicting it for %s", evictType)
+		isEvict = false
+	}
+	// actions of policy apply to requests of conditions, not the ones of api
+	if !manual && isEvict && !e.conditionManager.ActionEnabled(config.EvictAction) {
+		isEvict = false
+	}
+	if !manual && !isEvict && !e.conditionManager.ActionEnabled(config.LabelAction) {
+		log.Infof("actions of policy have neither Evict nor Label, skip %s", evictType)
+		decision.Error = "neither evicted nor labeled by actions of policy"
 		return
 	}
+	// pods relieving the excess together are planned and evicted at pace
+	if isEvict && severity != types.SeverityCritical {
+		victim, reason := e.planEviction(evictType, &nodeCondition, &decision)
+		if reason != "" {
+			log.Infof("skip eviction of %s: %s", evictType, reason)
+			decision.Error = reason
+			return
+		}
+		if victim != nil {
+			podToEvict = victim
+		}
+	}
 	log.Infof("Get pod: %v to evict.\n", podToEvict.Name)
+	owner := e.podOwner(podToEvict)
+	if podToEvict.Name != "" {
+		decision.Pod = podToEvict.Namespace + "/" + podToEvict.Name
+	}
+	span.SetAttribute("pod", decision.Pod)
+	decision.Explanation = decision.explain()
+	log.Infow("eviction explanation", "condition", evictType, "pod", decision.Pod,
+		"explanation", decision.Explanation, "excluded", decision.ExcludedCounts)
+	if e.observing() {
+		decision.Action = "Label " + priority
+		if isEvict {
+			decision.Action = "Evict"
+		}
+		decision.Result = "Observed"
+		observedDecisions.Inc(evictType, decision.Action)
+		return
+	}
+	defer func() {
+		span.SetAttribute("action", decision.Action)
+		span.SetError(err)
+		decision.Result = "Succeeded"
+		if err != nil {
+			decision.Result = "Failed"
+			decision.Error = err.Error()
+		}
+	}()
 
+	if e.resizeInstead(ctx, evictType, podToEvict, &decision) {
+		return
+	}
+	snapshot := decision.snapshot()
 	if isEvict {
-		err = e.client.EvictOnePod(podToEvict)
+		decision.Action = "Evict"
+		controller := e.replacementController(podToEvict)
+		apiCtx, apiSpan := tracing.StartClient(ctx, "api.evict")
+		err = e.client.WithContext(apiCtx).EvictOnePod(podToEvict)
+		apiSpan.SetError(err)
+		apiSpan.End()
+		if podToEvict.Name != "" {
+			e.recordAction("evict pod "+decision.Pod, err)
+		}
+		e.status.recordEviction(evictType, podToEvict, "Evict", snapshot, err)
+		e.notify("Evict", evictType, podToEvict, err)
+		e.auditAction("Evict", evictType, podToEvict, owner, snapshot, err)
+		if err == nil {
+			e.recordTenantEviction(podToEvict)
+			e.conditionManager.RecordEviction(*podToEvict)
+			e.recordOffense(evictType, podToEvict)
+			e.awaitReplacement(podToEvict, controller)
+			e.client.RecordPodEvent(podToEvict, types.NormalEvent, types.PodEvictedReason,
+				decision.eventMessage(fmt.Sprintf("Pod is evicted by eviction agent %s", decision.cause())))
+		}
 	} else {
-		err = e.client.LabelPod(podToEvict, priority, "Add")
+		decision.Action = "Label " + priority
+		apiCtx, apiSpan := tracing.StartClient(ctx, "api.label")
+		err = e.client.WithContext(apiCtx).LabelPod(podToEvict, priority, evictType, "Add")
+		apiSpan.SetError(err)
+		apiSpan.End()
+		if podToEvict.Name != "" {
+			e.recordAction("label pod "+decision.Pod, err)
+		}
+		e.status.recordEviction(evictType, podToEvict, "Label "+priority, snapshot, err)
+		e.notify("Label", evictType, podToEvict, err)
+		e.auditAction("Label "+priority, evictType, podToEvict, owner, snapshot, err)
+		if err == nil {
+			e.client.RecordPodEvent(podToEvict, types.NormalEvent, types.PodLabeledReason,
+				decision.eventMessage(fmt.Sprintf("Pod is labeled %s by eviction agent %s", priority, decision.cause())))
+		}
 	}
-	log.Infof("Evict pod : %v", err)
+	log.Infow("eviction action done", "condition", evictType, "pod", decision.Pod,
+		"action", decision.Action, "error", err)
+	e.failOnFatal(err)
 	return
 }
 
-func (e *evictionManager) taintProcess() {
-	// taint process cycle
-	var err error
-	for {
-		// wait for some second
-		time.Sleep(taintUpdatePeriod)
-		unTaintPeriod := e.conditionManager.GetUnTaintGracePeriod()
-		// get taint condition
-		e.nodeTaint, err = e.client.GetTaintConditions()
-		if err != nil {
-			log.Errorf("get taint condition error: %v", err)
-			continue
+// HealthCheck returns error if taint loop or stats sync has no progress for stuckThreshold
+func (e *evictionManager) HealthCheck() error {
+	lastTaintLoop := time.Unix(0, atomic.LoadInt64(&e.lastTaintLoopTime))
+	if d := e.clock.Since(lastTaintLoop); d > e.stuckThreshold {
+		return fmt.Errorf("taint loop is stuck for %v", d)
+	}
+	if d := e.clock.Since(e.conditionManager.LastSyncTime()); d > e.stuckThreshold {
+		return fmt.Errorf("stats sync is stuck for %v", d)
+	}
+	return nil
+}
+
+// ReadyCheck returns error if condition manager has no valid sample, api
+// server is not reached for stuckThreshold, watchdog finds a stalled loop or
+// actions are stopped in safe mode
+func (e *evictionManager) ReadyCheck() error {
+	if !e.conditionManager.HasSynced() {
+		return fmt.Errorf("condition manager has no valid sample yet")
+	}
+	if err := e.watchdogCheck(); err != nil {
+		return err
+	}
+	if err := e.safeModeCheck(); err != nil {
+		return err
+	}
+	lastAPISuccess := atomic.LoadInt64(&e.lastAPISuccessTime)
+	if lastAPISuccess == 0 {
+		return fmt.Errorf("api server is not reached yet")
+	}
+	if d := e.clock.Since(time.Unix(0, lastAPISuccess)); d > e.stuckThreshold {
+		return fmt.Errorf("api server is unreachable for %v", d)
+	}
+	return nil
+}
+
+// GetStatsSamples returns stats samples of condition manager
+func (e *evictionManager) GetStatsSamples() []condition.StatsSample {
+	return e.conditionManager.GetStatsSamples()
+}
+
+// GetLastEvictions returns the latest eviction decisions
+func (e *evictionManager) GetLastEvictions() []v1alpha1.EvictionRecord {
+	return e.status.getEvictions()
+}
+
+// GetHistory returns the latest decisions choosing or considering pod, or all of them if pod is empty
+func (e *evictionManager) GetHistory(pod string) []Decision {
+	return e.history.list(pod)
+}
+
+// GetExplanation returns the latest decision choosing pod, pod is namespace/name
+func (e *evictionManager) GetExplanation(pod string) (*Decision, error) {
+	if pod == "" {
+		return nil, fmt.Errorf("pod is required, e.g. ?pod=default/nginx")
+	}
+	decision, ok := e.history.explanation(pod)
+	if !ok {
+		return nil, fmt.Errorf("no eviction decision of pod %s in history", pod)
+	}
+	return &decision, nil
+}
+
+// GetConditions returns node conditions, taints and phases of the latest cycle
+func (e *evictionManager) GetConditions() *Conditions {
+	nodeCondition, _ := e.lastCondition.Load().(condition.NodeCondition)
+	nodeTaint, _ := e.lastTaint.Load().(types.NodeTaintInfo)
+	phases, _ := e.lastPhases.Load().([]PhaseStatus)
+	return &Conditions{
+		Condition: nodeCondition,
+		Taints:    nodeTaint,
+		Phases:    phases,
+		Mode:      e.mode,
+	}
+}
+
+// Measurements returns the measured values of node, e.g. cpuUsage
+func (c *Conditions) Measurements() map[string]float64 {
+	return measurements(&c.Condition)
+}
+
+// phases returns the current phases of controllers, only called by taint process
+func (e *evictionManager) phases() []PhaseStatus {
+	phases := make([]PhaseStatus, 0, len(e.controllers))
+	for _, controller := range e.controllers {
+		phases = append(phases, controller.phaseStatus())
+	}
+	return phases
+}
+
+// GetEvictionCandidates returns the ranked candidates of evictType
+func (e *evictionManager) GetEvictionCandidates(evictType string) ([]condition.Candidate, error) {
+	switch evictType {
+	case types.CPUBusy, types.MemBusy, types.DiskIO, types.NetworkRxBusy, types.NetworkTxBusy:
+	default:
+		return nil, fmt.Errorf("unknown type %q, should be one of %v", evictType,
+			[]string{types.CPUBusy, types.MemBusy, types.DiskIO, types.NetworkRxBusy, types.NetworkTxBusy})
+	}
+	return e.conditionManager.GetEvictionCandidates(evictType)
+}
+
+// recordTaintEvent records taint or untaint event with measured values on node
+func (e *evictionManager) recordTaintEvent(taintKey string, action string, nodeCondition *condition.NodeCondition) {
+	if action == "Taint" {
+		e.activity.recordTaint(taintKey)
+	}
+	if e.observing() {
+		e.observeTaint(taintKey, action, nodeCondition)
+		return
+	}
+	reason := types.NodeTaintedReason
+	message := fmt.Sprintf("Node is tainted with %s, %s", taintKey, conditionMessage(taintKey, nodeCondition))
+	if action == "UnTaint" {
+		reason = types.NodeUntaintedReason
+		message = fmt.Sprintf("Node is untainted %s, %s", taintKey, conditionMessage(taintKey, nodeCondition))
+	}
+	log.Infow(message, "condition", taintKey, "action", action, "values", measurements(nodeCondition))
+	e.client.RecordNodeEvent(types.NormalEvent, reason, message)
+	e.notify(action, taintKey, nil, nil)
+	e.alert(taintKey, action, nodeCondition)
+}
+
+// notify calls action handlers and sends webhook notification with measured
+// values of the latest cycle
+func (e *evictionManager) notify(action string, conditionType string, pod *types.PodInfo, err error) {
+	for _, handler := range e.actionHandlers {
+		handler(action, conditionType, pod, err)
+	}
+	if e.notifier == nil {
+		return
+	}
+	nodeCondition, _ := e.lastCondition.Load().(condition.NodeCondition)
+	notification := &webhook.Notification{
+		Time:         e.clock.Now(),
+		Node:         e.nodeName,
+		Action:       action,
+		Condition:    conditionType,
+		Message:      conditionMessage(conditionType, &nodeCondition),
+		Measurements: measurements(&nodeCondition),
+	}
+	if pod != nil {
+		notification.Pod = pod.Namespace + "/" + pod.Name
+	}
+	if err != nil {
+		notification.Error = err.Error()
+	}
+	e.notifier.Notify(notification)
+}
+
+// podOwner returns the controller of pod for audit, only when audit log is enabled
+func (e *evictionManager) podOwner(pod *types.PodInfo) string {
+	if e.audit == nil || pod == nil || pod.Name == "" {
+		return ""
+	}
+	owner, err := e.client.GetPodOwner(pod)
+	if err != nil {
+		return ""
+	}
+	return owner
+}
+
+// auditAction writes an audit record of the eviction or label action with
+// the snapshot of pods it's chosen from
+func (e *evictionManager) auditAction(action string, conditionType string, pod *types.PodInfo, owner string,
+	snapshot []v1alpha1.PodUsage, err error) {
+	if e.audit == nil {
+		return
+	}
+	nodeCondition, _ := e.lastCondition.Load().(condition.NodeCondition)
+	record := &audit.Record{
+		Time:         e.clock.Now(),
+		Node:         e.nodeName,
+		Action:       action,
+		Condition:    conditionType,
+		Measurements: measurements(&nodeCondition),
+		Pod:          pod.Namespace + "/" + pod.Name,
+		PodUID:       pod.UID,
+		Container:    pod.Container,
+		Priority:     pod.Priority,
+		Owner:        owner,
+		Outcome:      "Succeeded",
+		Snapshot:     snapshot,
+	}
+	if err != nil {
+		record.Outcome = "Failed"
+		record.Error = err.Error()
+	}
+	e.audit.Write(record)
+}
+
+// measurements returns the measured values of node condition
+func measurements(nodeCondition *condition.NodeCondition) map[string]float64 {
+	return map[string]float64{
+		"cpuUsage":     nodeCondition.CPUUsage,
+		"memoryUsage":  float64(nodeCondition.MemoryUsage),
+		"diskIOPS":     nodeCondition.DiskIOPS,
+		"networkRxBps": nodeCondition.NetworkRxBps,
+		"networkTxBps": nodeCondition.NetworkTxBps,
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
 		}
+	}
+	return false
+}
+
+// reportServices sets NodeServiceDegraded condition of node by failures of
+// critical services, the condition is tainted as a node problem
+func (e *evictionManager) reportServices(failures []string) {
+	degraded := len(failures) != 0
+	reason, message := types.ServicesRunningReason, "critical services are running"
+	if degraded {
+		reason, message = types.ServiceFailedReason, strings.Join(failures, "; ")
+	}
+	if err := e.client.SetNodeCondition(types.NodeServiceDegraded, degraded, reason, message); err != nil {
+		log.Errorf("set node condition %s error: %v", types.NodeServiceDegraded, err)
+		e.status.recordError(fmt.Sprintf("set node condition %s error: %v", types.NodeServiceDegraded, err))
+		e.queueAction("node condition "+types.NodeServiceDegraded, err, func() error {
+			return e.client.SetNodeCondition(types.NodeServiceDegraded, degraded, reason, message)
+		})
+	}
+}
 
-		// get node condition
-		condition := e.conditionManager.GetNodeCondition()
+// reportLinks sets NetworkLinkDegraded condition of node by failures of
+// monitored links, the condition is tainted as a node problem
+func (e *evictionManager) reportLinks(failures []string) {
+	degraded := len(failures) != 0
+	reason, message := types.LinksUpReason, "monitored links are up"
+	if degraded {
+		reason, message = types.LinkDegradedReason, strings.Join(failures, "; ")
+	}
+	if err := e.client.SetNodeCondition(types.NetworkLinkDegraded, degraded, reason, message); err != nil {
+		log.Errorf("set node condition %s error: %v", types.NetworkLinkDegraded, err)
+		e.status.recordError(fmt.Sprintf("set node condition %s error: %v", types.NetworkLinkDegraded, err))
+		e.queueAction("node condition "+types.NetworkLinkDegraded, err, func() error {
+			return e.client.SetNodeCondition(types.NetworkLinkDegraded, degraded, reason, message)
+		})
+	}
+}
 
-		// node is in good condition currently
-		if condition.NetworkRxAvailabel  && condition.NetworkTxAvailabel && condition.DiskIOAvailable &&
-			condition.CPUAvailable && condition.MemoryAvailable &&
-			!e.nodeTaint.DiskIO && !e.nodeTaint.NetworkIO && !e.nodeTaint.CPU && !e.nodeTaint.Memory {
-			// node is in good condition, there is no need to taint or un-taint
-			// there is no need to evict any pod either
-			// only need to clear all annotations on pods
-			e.client.ClearAllEvictLabels()
-			continue
+// reportDNS sets DNSUnhealthy condition of node by failures of probed names,
+// the condition is tainted as a node problem
+func (e *evictionManager) reportDNS(failures []string) {
+	unhealthy := len(failures) != 0
+	reason, message := types.DNSResolvingReason, "probed names are resolved"
+	if unhealthy {
+		reason, message = types.DNSProbeFailedReason, strings.Join(failures, "; ")
+	}
+	if err := e.client.SetNodeCondition(types.DNSUnhealthy, unhealthy, reason, message); err != nil {
+		log.Errorf("set node condition %s error: %v", types.DNSUnhealthy, err)
+		e.status.recordError(fmt.Sprintf("set node condition %s error: %v", types.DNSUnhealthy, err))
+		e.queueAction("node condition "+types.DNSUnhealthy, err, func() error {
+			return e.client.SetNodeCondition(types.DNSUnhealthy, unhealthy, reason, message)
+		})
+	}
+}
+
+// reportClock sets ClockSkewed condition of node by failures of clock, the
+// condition is tainted as a node problem. An event is recorded once clock is
+// skewed, since taints of node problems may be delayed by their period.
+func (e *evictionManager) reportClock(failures []string) {
+	skewed := len(failures) != 0
+	reason, message := types.ClockSynchronizedReason, "clock is synchronized"
+	if skewed {
+		reason, message = types.ClockSkewedReason, strings.Join(failures, "; ")
+	}
+	if skewed && !e.clockSkewed {
+		e.client.RecordNodeEvent(types.WarningEvent, types.ClockSkewedReason, message)
+	}
+	e.clockSkewed = skewed
+	if err := e.client.SetNodeCondition(types.ClockSkewed, skewed, reason, message); err != nil {
+		log.Errorf("set node condition %s error: %v", types.ClockSkewed, err)
+		e.status.recordError(fmt.Sprintf("set node condition %s error: %v", types.ClockSkewed, err))
+		e.queueAction("node condition "+types.ClockSkewed, err, func() error {
+			return e.client.SetNodeCondition(types.ClockSkewed, skewed, reason, message)
+		})
+	}
+}
+
+// reportCertificates sets KubeletCertificateExpiring condition of node by
+// failures of kubelet certificates, the condition is tainted as a node problem
+func (e *evictionManager) reportCertificates(failures []string) {
+	expiring := len(failures) != 0
+	reason, message := types.CertificatesValidReason, "kubelet certificates are valid"
+	if expiring {
+		reason, message = types.CertificateExpiringReason, strings.Join(failures, "; ")
+	}
+	if err := e.client.SetNodeCondition(types.KubeletCertificateExpiring, expiring, reason, message); err != nil {
+		log.Errorf("set node condition %s error: %v", types.KubeletCertificateExpiring, err)
+		e.status.recordError(fmt.Sprintf("set node condition %s error: %v", types.KubeletCertificateExpiring, err))
+		e.queueAction("node condition "+types.KubeletCertificateExpiring, err, func() error {
+			return e.client.SetNodeCondition(types.KubeletCertificateExpiring, expiring, reason, message)
+		})
+	}
+}
+
+// rankBy returns the evict type choosing pods of evictType, node problems
+// are mapped to evict types of resources
+func (e *evictionManager) rankBy(evictType string) string {
+	for _, controller := range e.controllers {
+		if controller.rankBy != "" && controller.name == evictType {
+			return controller.rankBy
 		}
+	}
+	return evictType
+}
 
-		isEvicted := false
-		// CPU condition process
-		if condition.CPUAvailable {
-			if e.nodeTaint.CPU {
-				// node is tainted CPU busy
-				// TODO: wait taintGraceTime
-				duration := time.Now().Sub(e.lastTaintCPUTime)
-				log.Infof("last taint duration: %v", duration)
-				if duration.Minutes() > unTaintPeriod.Minutes() {
-					err = e.client.SetTaintConditions(types.CPUBusy, "UnTaint")
-					log.Infof("Untaint node %s", types.CPUBusy)
-					if err != nil {
-						log.Errorf("untaint node %s error: %v", types.CPUBusy, err)
-					}
-					// TODO: clear annotations
-				}
-			}
-		} else {
-			// node is in CPU busy
-			// update taint time
-			e.lastTaintCPUTime = time.Now()
-			if !e.nodeTaint.CPU {
-				// taint node, evict pod
-				log.Infof("taint node %s ", types.CPUBusy)
-				err = e.client.SetTaintConditions(types.CPUBusy, "Taint")
-				if err != nil {
-					log.Errorf("add taint %s error: %v", types.CPUBusy, err)
-				}
-			}
-			// evict one pod to reclaim resources
-			if !isEvicted {
-				isEvicted = true
-				e.evictChan <- types.CPUBusy
-			}
+// conditionMessage describes the measured values of the condition
+func conditionMessage(taintKey string, nodeCondition *condition.NodeCondition) string {
+	switch taintKey {
+	case types.CPUBusy:
+		return fmt.Sprintf("cpu usage: %.2f cores", nodeCondition.CPUUsage)
+	case types.MemBusy:
+		if r := nodeCondition.MemoryReclaim; r != nil && r.Busy {
+			return fmt.Sprintf("memory usage: %v Bytes, kswapd scan: %.0f pages/s, direct scan: %.0f pages/s, "+
+				"allocation stalls: %.2f/s", nodeCondition.MemoryUsage, r.KswapdScanRate, r.DirectScanRate, r.AllocStallRate)
+		}
+		return fmt.Sprintf("memory usage: %v Bytes", nodeCondition.MemoryUsage)
+	case types.DiskIO:
+		return fmt.Sprintf("disk iops: %v", int(nodeCondition.DiskIOPS))
+	case types.WritebackBusy:
+		if w := nodeCondition.Writeback; w != nil {
+			return fmt.Sprintf("dirty: %v Bytes, writeback: %v Bytes since %v",
+				w.DirtyBytes, w.WritebackBytes, w.Since.Format(time.RFC3339))
+		}
+	case types.EphemeralPortsBusy:
+		if p := nodeCondition.EphemeralPorts; p != nil {
+			return fmt.Sprintf("ephemeral ports used: %d of %d, time wait: %d", p.Used, p.Total, p.TimeWait)
+		}
+	case types.NetworkIO, types.NetworkRxBusy, types.NetworkTxBusy:
+		return fmt.Sprintf("network Rx bps: %v Bytes/s, Tx bps: %v Bytes/s",
+			int(nodeCondition.NetworkRxBps), int(nodeCondition.NetworkTxBps))
+	}
+	for resource, r := range nodeCondition.Extended {
+		if types.ExtendedResourceTaintKey(resource) == taintKey {
+			return fmt.Sprintf("%s usage: %v of allocatable %v", resource, r.Usage, r.Allocatable)
 		}
+	}
+	return fmt.Sprintf("node condition %s: %v", taintKey, nodeCondition.Problems[taintKey])
+}
 
-		// Memory condition process
-		if condition.MemoryAvailable {
-			if e.nodeTaint.Memory {
-				// node is tainted Memory busy
-				// TODO: wait taintGraceTime
-				duration := time.Now().Sub(e.lastTaintMemTime)
-				log.Infof("last taint duration: %v\n", duration)
-				if duration.Minutes() > unTaintPeriod.Minutes() {
-					err = e.client.SetTaintConditions(types.MemBusy, "UnTaint")
-					log.Infof("Untaint node %s", types.MemBusy)
-					if err != nil {
-						log.Errorf("untaint node %s error: %v", types.MemBusy, err)
-					}
-					// TODO: clear annotations
-				}
-			}
-		} else {
-			// node is in Memory busy
-			// update taint time
-			e.lastTaintMemTime = time.Now()
-			if !e.nodeTaint.Memory {
-				// taint node, evict pod
-				log.Infof("taint node %s ", types.MemBusy)
-				err = e.client.SetTaintConditions(types.MemBusy, "Taint")
-				if err != nil {
-					log.Errorf("add taint %s error: %v", types.MemBusy, err)
-				}
-			}
-			// evict one pod to reclaim resources
-			if !isEvicted {
-				isEvicted = true
-				e.evictChan <- types.MemBusy
-			}
+func (e *evictionManager) taintProcess(ctx context.Context) {
+	// taint process cycle
+	for {
+		// wait for some second
+		select {
+		case <-ctx.Done():
+			log.Infof("Stop taint process")
+			return
+		case <-e.clock.After(condition.Jitter(e.tickPeriod, e.tickJitter)):
+		case <-e.trigger:
 		}
+		e.evaluate(ctx)
+	}
+}
 
-		// DiskIO condition process
-		if condition.DiskIOAvailable {
-			if e.nodeTaint.DiskIO {
-				// node is tainted DiskIO busy
-				// TODO: wait taintGraceTime
-				duration := time.Now().Sub(e.lastTaintDiskIOTime)
-				log.Infof("last taint duration: %v", duration)
-				if duration.Minutes() > unTaintPeriod.Minutes() {
-					err = e.client.SetTaintConditions(types.DiskIO, "UnTaint")
-					log.Infof("Untaint node %s", types.DiskIO)
-					if err != nil {
-						log.Errorf("untaint node %s error: %v", types.DiskIO, err)
-					}
-					// TODO: clear annotations
-				}
-			}
-		} else {
-			// node is in DiskIO busy
-			// update taint time
-			e.lastTaintDiskIOTime = time.Now()
-			if !e.nodeTaint.DiskIO {
-				// taint node, evict pod
-				log.Infof("taint node %s ", types.DiskIO)
-				err = e.client.SetTaintConditions(types.DiskIO, "Taint")
-				if err != nil {
-					log.Errorf("add taint %s error: %v", types.DiskIO, err)
-				}
-			}
-			// evict one pod to reclaim resources
-			if !isEvicted {
-				isEvicted = true
-				e.evictChan <- types.DiskIO
-			}
+// evaluate is one cycle of taint process
+func (e *evictionManager) evaluate(ctx context.Context) {
+	ctx, span := tracing.Start(ctx, "evaluation")
+	defer span.End()
+	// node and pods on node are got once for taints, conditions and labels
+	e.client.BeginCycle()
+	defer e.client.EndCycle()
+	atomic.StoreInt64(&e.lastTaintLoopTime, e.clock.Now().UnixNano())
+	if e.failOnFatal(e.conditionManager.Fatal()) {
+		return
+	}
+	unTaintPeriod := e.conditionManager.GetUnTaintGracePeriod()
+	// get taint condition
+	taintCtx, taintSpan := tracing.StartClient(ctx, "api.get_taints")
+	nodeTaint, err := e.client.WithContext(taintCtx).GetTaintConditions()
+	taintSpan.SetError(err)
+	taintSpan.End()
+	e.checkReachability(err)
+	if err != nil {
+		log.Errorf("get taint condition error: %v", err)
+		e.status.recordError(fmt.Sprintf("get taint condition error: %v", err))
+		span.SetError(err)
+		e.failOnFatal(err)
+		return
+	}
+	e.nodeTaint = nodeTaint
+	atomic.StoreInt64(&e.lastAPISuccessTime, e.clock.Now().UnixNano())
+
+	e.checkPause()
+	e.checkSafeMode()
+	e.checkRuntime()
+
+	// controllers whose period is over in this cycle
+	var due []*conditionController
+	for _, controller := range e.controllers {
+		if controller.due(e.clock.Now()) {
+			due = append(due, controller)
 		}
+	}
 
-		// NetworkIO condition process
-		if condition.NetworkRxAvailabel && condition.NetworkTxAvailabel {
-			if e.nodeTaint.NetworkIO {
-				duration := time.Now().Sub(e.lastTaintNetIOTime)
-				log.Infof("last taint duration: %v", duration)
-				if duration.Minutes() > unTaintPeriod.Minutes() {
-					err = e.client.SetTaintConditions(types.NetworkIO, "UnTaint")
-					if err != nil {
-						log.Errorf("untaint node %s error: %v", types.NetworkIO, err)
-					}
-					// TODO: clear annotations
-					log.Infof("untaint node %s", types.NetworkIO)
-				}
-			}
-		} else {
-			// node is in NetworkIO busy
-			e.lastTaintNetIOTime = time.Now()
-			if !e.nodeTaint.NetworkIO {
-				log.Infof("taint node %s unavailable", types.NetworkIO)
-				// taint node, evict pod
-				err = e.client.SetTaintConditions(types.NetworkIO, "Taint")
-				if err != nil {
-					log.Errorf("add taint %s error: %v", types.NetworkIO, err)
-				}
-			}
-			// evict one pod to reclaim resources
-			if !isEvicted {
-				isEvicted = true
-				if !condition.NetworkTxAvailabel {
-					e.evictChan <- types.NetworkRxBusy
-				} else if !condition.NetworkTxAvailabel {
-					e.evictChan <- types.NetworkTxBusy
-				}
+	// get node condition, node problems are got with taints
+	_, conditionSpan := tracing.Start(ctx, "condition.evaluate")
+	nodeCondition := *e.conditionManager.GetNodeCondition()
+	nodeCondition.Problems = nodeTaint.Problems
+	condition := &nodeCondition
+	conditionSpan.SetAttribute("cpu_available", condition.CPUAvailable)
+	conditionSpan.SetAttribute("memory_available", condition.MemoryAvailable)
+	conditionSpan.SetAttribute("disk_io_available", condition.DiskIOAvailable)
+	conditionSpan.SetAttribute("network_rx_available", condition.NetworkRxAvailable)
+	conditionSpan.SetAttribute("network_tx_available", condition.NetworkTxAvailable)
+	conditionSpan.End()
+	e.lastCondition.Store(*condition)
+	e.lastTaint.Store(e.nodeTaint)
 
-			}
+	// node is in good condition currently
+	good := true
+	for _, controller := range e.controllers {
+		good = good && controller.good(condition, e)
+	}
+	if !e.conditionManager.HasSynced() {
+		// conditions are unknown, keep the state restored from node
+		log.Infof("wait for enough stats to evaluate conditions")
+	} else if good {
+		// node is in good condition, there is no need to taint or un-taint
+		// there is no need to evict any pod either
+		// only need to clear all annotations on pods
+		for _, controller := range e.controllers {
+			controller.busySince = time.Time{}
+			controller.transition(e, PhaseHealthy)
+		}
+		if len(due) != 0 && e.labelsEnabled() {
+			e.client.ClearAllEvictLabels()
+		}
+	} else {
+		for _, controller := range due {
+			controller.sync(ctx, e, condition, unTaintPeriod)
 		}
 	}
+	if e.conditionManager.HasSynced() {
+		e.updateRebalanceHints()
+		e.checkBands(condition)
+		e.checkDrain()
+	}
+	phases := e.phases()
+	e.lastPhases.Store(phases)
+	e.status.report(condition, e.nodeTaint, phases)
+	if e.textfile != nil {
+		e.textfile.write(e.controllers, condition, &e.nodeTaint, phases)
+	}
+}
+
+// labelsEnabled returns false if pods are never evicted or labeled in the mode,
+// so evict labels are not cleared either
+func (e *evictionManager) labelsEnabled() bool {
+	return e.mode != options.ModeTaintOnly && e.mode != options.ModeMonitorOnly && !e.observing()
+}
+
+// untaintDisabled removes the taint of a disabled condition, so that taints
+// set before the condition is disabled are not left on node. Returns false on error.
+func (e *evictionManager) untaintDisabled(taintKey string, nodeCondition *condition.NodeCondition) bool {
+	log.Infof("Untaint node %s, the condition is disabled", taintKey)
+	if e.observing() {
+		e.observeTaint(taintKey, "UnTaint", nodeCondition)
+		return true
+	}
+	err := e.client.SetTaintConditions(taintKey, "UnTaint")
+	e.recordAction("untaint node "+taintKey, err)
+	if err != nil {
+		log.Errorf("untaint node %s error: %v", taintKey, err)
+		e.status.recordError(fmt.Sprintf("untaint node %s error: %v", taintKey, err))
+		e.failOnFatal(err)
+		return false
+	}
+	e.recordTaintEvent(taintKey, "UnTaint", nodeCondition)
+	return true
+}
+
+// failOnFatal stops Run with err if it's a fatal error, returns true if it is.
+// Only the first fatal error is returned by Run.
+func (e *evictionManager) failOnFatal(err error) bool {
+	fatal := types.AsFatalError(err)
+	if fatal == nil {
+		return false
+	}
+	e.fatalOnce.Do(func() {
+		log.Errorf("Stop eviction manager on fatal error: %v", fatal)
+		e.fatalErr = fatal
+		if e.cancel != nil {
+			e.cancel()
+		}
+	})
+	return true
 }
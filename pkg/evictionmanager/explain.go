@@ -0,0 +1,153 @@
+package evictionmanager
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"eviction-agent/pkg/apis/v1alpha1"
+	"eviction-agent/pkg/condition"
+)
+
+const (
+	// maxDecisionExclusions is the max number of excluded pods kept in a decision
+	maxDecisionExclusions = 50
+	// maxExplainedCandidates is the max number of candidates in explanation
+	maxExplainedCandidates = 3
+	// maxEventMessage is the max length of event message with explanation
+	maxEventMessage = 1024
+)
+
+// setRanking keeps candidates and excluded pods of ranking, the number of
+// pods excluded by each filter is kept before excluded pods are truncated
+func (d *Decision) setRanking(ranking condition.Ranking) {
+	d.Candidates = ranking.Candidates
+	d.Excluded = ranking.Excluded
+	if len(ranking.Excluded) == 0 {
+		return
+	}
+	d.ExcludedCounts = make(map[string]int)
+	for _, exclusion := range ranking.Excluded {
+		d.ExcludedCounts[exclusion.Reason]++
+	}
+}
+
+// explain returns a one-line explanation of decision, similar to the reason of scheduler, e.g.
+// "chose default/a (score 2.5, usage 2.5) from 3 candidates, next default/b (score 1);
+// excluded 4 pods: 3 NoUsage, 1 ProtectedNamespace; system daemons use 1.2 of 1 reserved"
+func (d *Decision) explain() string {
+	var parts []string
+	if len(d.Candidates) == 0 {
+		parts = append(parts, fmt.Sprintf("no pod consumes resource of %s", d.Condition))
+	} else {
+		chosen := d.Candidates[0]
+		// the victim of a plan may not be the best scored candidate
+		for _, candidate := range d.Candidates {
+			if d.Plan != nil && candidate.Pod.Namespace+"/"+candidate.Pod.Name == d.Pod {
+				chosen = candidate
+			}
+		}
+		progress := ""
+		if chosen.Progress > 0 {
+			progress = fmt.Sprintf(", job %.0f%% done", chosen.Progress*100)
+		}
+		if chosen.Pod.Container != "" {
+			progress += ", container " + chosen.Pod.Container
+		}
+		part := fmt.Sprintf("chose %s/%s (score %.4g, usage %.4g%s) from %d candidates",
+			chosen.Pod.Namespace, chosen.Pod.Name, chosen.Score, chosen.Usage, progress, len(d.Candidates))
+		var next []string
+		for _, c := range d.Candidates {
+			if len(next) == maxExplainedCandidates {
+				break
+			}
+			if c.Pod.Namespace != chosen.Pod.Namespace || c.Pod.Name != chosen.Pod.Name {
+				next = append(next, fmt.Sprintf("%s/%s (score %.4g)", c.Pod.Namespace, c.Pod.Name, c.Score))
+			}
+		}
+		if len(next) != 0 {
+			part += ", next " + strings.Join(next, ", ")
+		}
+		parts = append(parts, part)
+		if d.Plan != nil {
+			parts = append(parts, d.Plan.explain())
+		}
+		if chosen.Returns {
+			parts = append(parts, "all candidates tolerate the taint or are required to run on node")
+		}
+	}
+	if len(d.ExcludedCounts) != 0 {
+		var reasons []string
+		for reason := range d.ExcludedCounts {
+			reasons = append(reasons, reason)
+		}
+		sort.Strings(reasons)
+		var counts []string
+		total := 0
+		for _, reason := range reasons {
+			counts = append(counts, fmt.Sprintf("%d %s", d.ExcludedCounts[reason], reason))
+			total += d.ExcludedCounts[reason]
+		}
+		parts = append(parts, fmt.Sprintf("excluded %d pods: %s", total, strings.Join(counts, ", ")))
+	}
+	if d.Daemon != nil {
+		parts = append(parts, fmt.Sprintf("system daemons use %.4g of %.4g reserved",
+			d.Daemon.Usage, d.Daemon.Reserved))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// explain returns a summary of plan, e.g. "victim 1 of plan default/a,
+// default/b relieving 2.5 of excess 2"
+func (p *Plan) explain() string {
+	pods := make([]string, len(p.Victims))
+	for i, victim := range p.Victims {
+		pods[i] = victim.Pod
+	}
+	relief := "relieving"
+	if !p.Covered {
+		relief = "relieving only"
+	}
+	return fmt.Sprintf("victim %d of plan %s %s %.4g of excess %.4g",
+		p.Next+1, strings.Join(pods, ", "), relief, p.Relief, p.Excess)
+}
+
+// snapshot returns the usage of candidates and excluded pods of decision, nil
+// if there are none
+func (d *Decision) snapshot() []v1alpha1.PodUsage {
+	if len(d.Candidates) == 0 && len(d.Excluded) == 0 {
+		return nil
+	}
+	snapshot := make([]v1alpha1.PodUsage, 0, len(d.Candidates)+len(d.Excluded))
+	for _, candidate := range d.Candidates {
+		snapshot = append(snapshot, v1alpha1.PodUsage{
+			Pod:   candidate.Pod.Namespace + "/" + candidate.Pod.Name,
+			Usage: candidate.Usage,
+			Score: candidate.Score,
+			Label: candidate.Label,
+		})
+	}
+	for _, exclusion := range d.Excluded {
+		snapshot = append(snapshot, v1alpha1.PodUsage{Pod: exclusion.Pod, Excluded: exclusion.Reason})
+	}
+	return snapshot
+}
+
+// cause describes why the decision is made in messages of pod events
+func (d *Decision) cause() string {
+	if d.Manual {
+		return "on manual request of " + d.Condition
+	}
+	return "because node is " + d.Condition
+}
+
+// eventMessage appends explanation of decision to message of pod event
+func (d *Decision) eventMessage(message string) string {
+	if d.Explanation != "" {
+		message += ": " + d.Explanation
+	}
+	if len(message) > maxEventMessage {
+		message = message[:maxEventMessage-3] + "..."
+	}
+	return message
+}
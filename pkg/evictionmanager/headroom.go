@@ -0,0 +1,133 @@
+package evictionmanager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"eviction-agent/cmd/options"
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/metrics"
+	"eviction-agent/pkg/types"
+)
+
+// headroomTimeout is the timeout of getting spare capacity of cluster from url
+const headroomTimeout = 10 * time.Second
+
+var (
+	clusterHeadroom = metrics.NewGaugeVec("eviction_agent_cluster_headroom",
+		"Spare capacity of other nodes of cluster by resource, cores of cpu and bytes of memory.", "resource")
+	headroomExhausted = metrics.NewGaugeVec("eviction_agent_cluster_headroom_exhausted",
+		"1 while spare capacity of cluster is below --cluster-headroom-min, node is not tainted and pods are labeled.")
+)
+
+// headroom checks spare capacity of cluster, evicted pods would be Pending
+// without it and tainting node takes away more capacity. Node is not tainted
+// NoSchedule and pods are labeled instead of evicted while it's exhausted.
+type headroom struct {
+	// source is options.ClusterHeadroomNodes or the url of spare capacity
+	source string
+	min    types.ClusterHeadroom
+	period time.Duration
+	client *http.Client
+	// exhausted is 1 while spare capacity is below min, the last state is kept
+	// if it can't be got
+	exhausted int32
+}
+
+func newHeadroom(eao *options.EvictionAgentOptions) *headroom {
+	min := eao.GetClusterHeadroomMinOrDie()
+	if eao.ClusterHeadroom == "" {
+		return nil
+	}
+	return &headroom{
+		source: eao.ClusterHeadroom,
+		min:    min,
+		period: eao.ClusterHeadroomPeriod,
+		client: &http.Client{Timeout: headroomTimeout},
+	}
+}
+
+// headroomExhausted returns true if cluster has no spare capacity for pods
+// evicted from node
+func (e *evictionManager) headroomExhausted() bool {
+	return e.headroom != nil && atomic.LoadInt32(&e.headroom.exhausted) == 1
+}
+
+// checkHeadroom gets spare capacity of cluster every period
+func (e *evictionManager) checkHeadroom(ctx context.Context) {
+	h := e.headroom
+	for {
+		spare, err := h.get(e)
+		if err != nil {
+			log.Errorf("get headroom of cluster error: %v", err)
+		} else {
+			clusterHeadroom.Set(spare.CPU, "cpu")
+			clusterHeadroom.Set(spare.Memory, "memory")
+			clusterHeadroom.Set(float64(spare.Pods), "pods")
+			e.setHeadroomExhausted(spare)
+		}
+		select {
+		case <-ctx.Done():
+			log.Infof("Stop cluster headroom check")
+			return
+		case <-e.clock.After(h.period):
+		}
+	}
+}
+
+func (e *evictionManager) setHeadroomExhausted(spare *types.ClusterHeadroom) {
+	h := e.headroom
+	var lacking []string
+	if spare.CPU < h.min.CPU {
+		lacking = append(lacking, fmt.Sprintf("cpu %.4g < %.4g", spare.CPU, h.min.CPU))
+	}
+	if spare.Memory < h.min.Memory {
+		lacking = append(lacking, fmt.Sprintf("memory %.4g < %.4g", spare.Memory, h.min.Memory))
+	}
+	if spare.Pods < h.min.Pods {
+		lacking = append(lacking, fmt.Sprintf("pods %d < %d", spare.Pods, h.min.Pods))
+	}
+	exhausted := int32(0)
+	if len(lacking) != 0 {
+		exhausted = 1
+	}
+	if atomic.SwapInt32(&h.exhausted, exhausted) == exhausted {
+		return
+	}
+	headroomExhausted.Set(float64(exhausted))
+	if exhausted == 1 {
+		message := fmt.Sprintf("Cluster has no headroom for evicted pods: %v, node is not tainted and pods are "+
+			"labeled instead of evicted", lacking)
+		log.Warnf("%s", message)
+		e.client.RecordNodeEvent(types.WarningEvent, types.HeadroomExhaustedReason, message)
+		return
+	}
+	message := fmt.Sprintf("Cluster has headroom for evicted pods again, cpu %.4g, memory %.4g, pods %d",
+		spare.CPU, spare.Memory, spare.Pods)
+	log.Infof("%s", message)
+	e.client.RecordNodeEvent(types.NormalEvent, types.HeadroomAvailableReason, message)
+}
+
+// get returns spare capacity of cluster from nodes or url
+func (h *headroom) get(e *evictionManager) (*types.ClusterHeadroom, error) {
+	if h.source == options.ClusterHeadroomNodes {
+		return e.client.GetClusterHeadroom()
+	}
+	resp, err := h.client.Get(h.source)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s responds %v", h.source, resp.Status)
+	}
+	spare := &types.ClusterHeadroom{}
+	if err := json.NewDecoder(resp.Body).Decode(spare); err != nil {
+		return nil, fmt.Errorf("decode headroom of %s: %v", h.source, err)
+	}
+	return spare, nil
+}
@@ -0,0 +1,178 @@
+package evictionmanager
+
+import (
+	"sync"
+	"time"
+
+	"eviction-agent/pkg/condition"
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/types"
+)
+
+// maxDecisionCandidates is the max number of candidates kept in a decision
+const maxDecisionCandidates = 10
+
+// Decision is one eviction decision with what it is based on
+type Decision struct {
+	Time      time.Time `json:"time"`
+	Condition string    `json:"condition"`
+	// Manual is true if the decision is requested by api instead of the condition
+	Manual   bool           `json:"manual,omitempty"`
+	Severity types.Severity `json:"severity,omitempty"`
+	// Measurements are the measured values of node at decision time
+	Measurements map[string]float64 `json:"measurements"`
+	// Candidates are the top ranked candidates, the first one is chosen
+	Candidates []condition.Candidate `json:"candidates"`
+	// Excluded are pods filtered out of candidates, ExcludedCounts are
+	// the number of them of each filter before they are truncated
+	Excluded       []condition.Exclusion `json:"excluded,omitempty"`
+	ExcludedCounts map[string]int        `json:"excludedCounts,omitempty"`
+	// Daemon is the share of system daemons of the condition, nil if no headroom is reserved
+	Daemon *condition.DaemonShare `json:"daemon,omitempty"`
+	// Plan is the plan of multiple victims the pod is chosen from, nil if pod
+	// is chosen alone
+	Plan *Plan `json:"plan,omitempty"`
+	// Explanation is a summary of why pod is chosen
+	Explanation string `json:"explanation,omitempty"`
+	// Pod is the chosen pod, empty if no pod is chosen
+	Pod    string `json:"pod,omitempty"`
+	Action string `json:"action,omitempty"`
+	// Result is Succeeded, Failed, Skipped if no action is taken,
+	// or Observed if the action is not taken during observation
+	Result string `json:"result"`
+	Error  string `json:"error,omitempty"`
+}
+
+// history is a ring buffer of the latest decisions, kept in store across
+// restarts if store is not nil
+type history struct {
+	lock      sync.Mutex
+	decisions []Decision
+	next      int
+	full      bool
+	store     historyStore
+	dirty     bool // decisions are added since the last save
+}
+
+func newHistory(size int) *history {
+	if size <= 0 {
+		size = 1
+	}
+	return &history{decisions: make([]Decision, size)}
+}
+
+// add keeps decision, the oldest one is dropped if history is full
+func (h *history) add(decision Decision) {
+	if len(decision.Candidates) > maxDecisionCandidates {
+		decision.Candidates = decision.Candidates[:maxDecisionCandidates]
+	}
+	if len(decision.Excluded) > maxDecisionExclusions {
+		decision.Excluded = decision.Excluded[:maxDecisionExclusions]
+	}
+	log.Debugw("eviction decision", "condition", decision.Condition, "pod", decision.Pod,
+		"action", decision.Action, "result", decision.Result, "error", decision.Error,
+		"values", decision.Measurements, "candidates", decision.Candidates, "excluded", decision.Excluded)
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.push(decision)
+	h.dirty = true
+}
+
+// push keeps decision in ring buffer, lock must be held
+func (h *history) push(decision Decision) {
+	h.decisions[h.next] = decision
+	h.next = (h.next + 1) % len(h.decisions)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// ordered returns all decisions, the oldest first. lock must be held.
+func (h *history) ordered() []Decision {
+	var ordered []Decision
+	if h.full {
+		ordered = append(ordered, h.decisions[h.next:]...)
+	}
+	return append(ordered, h.decisions[:h.next]...)
+}
+
+// list returns decisions of pod, or all decisions if pod is empty, the oldest first
+func (h *history) list(pod string) []Decision {
+	h.lock.Lock()
+	ordered := h.ordered()
+	h.lock.Unlock()
+
+	decisions := []Decision{}
+	for _, decision := range ordered {
+		if pod == "" || decision.Pod == pod || considered(decision, pod) {
+			decisions = append(decisions, decision)
+		}
+	}
+	return decisions
+}
+
+// considered returns true if pod is one of the candidates or excluded pods of decision
+func considered(decision Decision, pod string) bool {
+	for _, candidate := range decision.Candidates {
+		if candidate.Pod.Namespace+"/"+candidate.Pod.Name == pod {
+			return true
+		}
+	}
+	for _, exclusion := range decision.Excluded {
+		if exclusion.Pod == pod {
+			return true
+		}
+	}
+	return false
+}
+
+// explanation returns the latest decision choosing pod
+func (h *history) explanation(pod string) (Decision, bool) {
+	decisions := h.list(pod)
+	for i := len(decisions) - 1; i >= 0; i-- {
+		if decisions[i].Pod == pod {
+			return decisions[i], true
+		}
+	}
+	return Decision{}, false
+}
+
+// load adds decisions of store before the ones of this run, only the latest
+// ones fitting history are kept
+func (h *history) load() {
+	if h.store == nil {
+		return
+	}
+	decisions, err := h.store.Load()
+	if err != nil {
+		log.Errorf("load decision history from %s error: %v", h.store, err)
+		return
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	current := h.ordered()
+	h.next, h.full = 0, false
+	for _, decision := range append(decisions, current...) {
+		h.push(decision)
+	}
+	log.Infof("Loaded %d decisions of history from %s", len(decisions), h.store)
+}
+
+// save keeps decisions in store if any is added since the last save
+func (h *history) save() {
+	h.lock.Lock()
+	if h.store == nil || !h.dirty {
+		h.lock.Unlock()
+		return
+	}
+	decisions := h.ordered()
+	h.dirty = false
+	h.lock.Unlock()
+	if err := h.store.Save(decisions); err != nil {
+		log.Errorf("save decision history to %s error: %v", h.store, err)
+		h.lock.Lock()
+		h.dirty = true
+		h.lock.Unlock()
+	}
+}
@@ -0,0 +1,193 @@
+package evictionmanager
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"eviction-agent/cmd/options"
+	"eviction-agent/pkg/evictionclient"
+	"eviction-agent/pkg/log"
+)
+
+const (
+	// historySavePeriod is the period of saving decisions added to history
+	historySavePeriod = 30 * time.Second
+	// historyConfigMapKey is the key of decisions in ConfigMap
+	historyConfigMapKey = "decisions.json"
+	// maxHistoryConfigMapSize keeps ConfigMap under the 1MiB limit of objects,
+	// the oldest decisions are dropped to fit it
+	maxHistoryConfigMapSize = 900 * 1024
+	// historyOpenTimeout is the time to wait for the lock of bolt file, which
+	// is held by another agent on the same hostPath
+	historyOpenTimeout = 10 * time.Second
+)
+
+// historyBucket is the bucket of decisions in bolt file, keyed by sequence
+var historyBucket = []byte("decisions")
+
+// historyStore keeps decisions of history across restarts of agent
+type historyStore interface {
+	// Load returns the decisions kept, the oldest first
+	Load() ([]Decision, error)
+	// Save replaces the decisions kept, the oldest first
+	Save(decisions []Decision) error
+	// String describes where decisions are kept
+	String() string
+}
+
+// newHistoryStoreOrDie returns the store of --history-store, nil if
+// decisions are kept in memory only
+func newHistoryStoreOrDie(client evictionclient.Client, eao *options.EvictionAgentOptions) historyStore {
+	switch eao.HistoryStore {
+	case options.HistoryStoreFile:
+		if err := os.MkdirAll(filepath.Dir(eao.HistoryStorePath), 0755); err != nil {
+			log.Errorf("Create dir of history store %s error: %v", eao.HistoryStorePath, err)
+			panic(err)
+		}
+		store, err := newFileHistoryStore(eao.HistoryStorePath)
+		if err != nil {
+			log.Errorf("Open history store %s error: %v", eao.HistoryStorePath, err)
+			panic(err)
+		}
+		return store
+	case options.HistoryStoreConfigMap:
+		return &configMapHistoryStore{
+			client:    client,
+			namespace: eao.StatusNamespace,
+			name:      "eviction-history-" + eao.NodeName,
+		}
+	}
+	return nil
+}
+
+// saveHistory saves decisions added to history every historySavePeriod, and
+// once more when ctx is done
+func (e *evictionManager) saveHistory(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			e.history.save()
+			return
+		case <-e.clock.After(historySavePeriod):
+		}
+		e.history.save()
+	}
+}
+
+// fileHistoryStore keeps decisions in a bolt file of local disk, e.g. on a
+// hostPath volume, each decision is a record replaced in one transaction
+type fileHistoryStore struct {
+	path string
+	db   *bolt.DB
+}
+
+// newFileHistoryStore opens or creates bolt file path, which is kept open
+// until agent exits
+func newFileHistoryStore(path string) (*fileHistoryStore, error) {
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: historyOpenTimeout})
+	if err != nil {
+		return nil, err
+	}
+	return &fileHistoryStore{path: path, db: db}, nil
+}
+
+func (s *fileHistoryStore) Load() ([]Decision, error) {
+	var decisions []Decision
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(historyBucket)
+		if bucket == nil {
+			return nil
+		}
+		// keys are big endian sequences, so the oldest is the first
+		return bucket.ForEach(func(key, value []byte) error {
+			var decision Decision
+			if err := json.Unmarshal(value, &decision); err != nil {
+				return fmt.Errorf("decode decision %d of %s: %v", binary.BigEndian.Uint64(key), s.path, err)
+			}
+			decisions = append(decisions, decision)
+			return nil
+		})
+	})
+	return decisions, err
+}
+
+func (s *fileHistoryStore) Save(decisions []Decision) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(historyBucket); err != nil && err != bolt.ErrBucketNotFound {
+			return err
+		}
+		bucket, err := tx.CreateBucket(historyBucket)
+		if err != nil {
+			return err
+		}
+		key := make([]byte, 8)
+		for i, decision := range decisions {
+			value, err := json.Marshal(decision)
+			if err != nil {
+				return err
+			}
+			binary.BigEndian.PutUint64(key, uint64(i))
+			if err := bucket.Put(key, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *fileHistoryStore) String() string {
+	return "bolt file " + s.path
+}
+
+// configMapHistoryStore keeps decisions in a ConfigMap of node, so that they
+// survive the node itself, the oldest ones are dropped to fit its size limit
+type configMapHistoryStore struct {
+	client    evictionclient.Client
+	namespace string
+	name      string
+}
+
+func (s *configMapHistoryStore) Load() ([]Decision, error) {
+	data, err := s.client.GetConfigMap(s.namespace, s.name)
+	if err != nil {
+		return nil, err
+	}
+	value, ok := data[historyConfigMapKey]
+	if !ok {
+		return nil, nil
+	}
+	var decisions []Decision
+	if err := json.Unmarshal([]byte(value), &decisions); err != nil {
+		return nil, fmt.Errorf("decode %s: %v", historyConfigMapKey, err)
+	}
+	return decisions, nil
+}
+
+func (s *configMapHistoryStore) Save(decisions []Decision) error {
+	for {
+		data, err := json.Marshal(decisions)
+		if err != nil {
+			return err
+		}
+		if len(data) <= maxHistoryConfigMapSize || len(decisions) == 0 {
+			return s.client.UpdateConfigMap(s.namespace, s.name, map[string]string{historyConfigMapKey: string(data)})
+		}
+		// drop the oldest decisions by the share of the excess
+		drop := len(decisions) * (len(data) - maxHistoryConfigMapSize) / len(data)
+		if drop == 0 {
+			drop = 1
+		}
+		decisions = decisions[drop:]
+	}
+}
+
+func (s *configMapHistoryStore) String() string {
+	return "ConfigMap " + s.namespace + "/" + s.name
+}
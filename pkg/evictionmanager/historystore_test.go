@@ -0,0 +1,52 @@
+package evictionmanager
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileHistoryStoreReplacesDecisions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	store, err := newFileHistoryStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decisions, err := store.Load(); err != nil || len(decisions) != 0 {
+		t.Fatalf("new store has decisions %+v, error %v", decisions, err)
+	}
+
+	at := time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC)
+	var saved []Decision
+	for i := 0; i < 300; i++ {
+		saved = append(saved, Decision{Time: at.Add(time.Duration(i) * time.Second), Condition: "cpu", Pod: "default/busy"})
+	}
+	if err := store.Save(saved); err != nil {
+		t.Fatal(err)
+	}
+	// a shorter history replaces all decisions of the last save
+	saved = saved[len(saved)-2:]
+	if err := store.Save(saved); err != nil {
+		t.Fatal(err)
+	}
+	store.db.Close()
+
+	// decisions survive reopening the file, as a restart of agent
+	store, err = newFileHistoryStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.db.Close()
+	loaded, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded) != len(saved) {
+		t.Fatalf("loaded %d decisions, want %d", len(loaded), len(saved))
+	}
+	for i := range saved {
+		if !loaded[i].Time.Equal(saved[i].Time) || loaded[i].Pod != saved[i].Pod {
+			t.Errorf("decision %d is %+v, want %+v", i, loaded[i], saved[i])
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package evictionmanager
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"eviction-agent/cmd/options"
+	"eviction-agent/pkg/condition"
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/metrics"
+	"eviction-agent/pkg/types"
+)
+
+// manualEvictPrefix prefixes eviction requests by api in eviction queue,
+// e.g. Manual:DiskIOBusy
+const manualEvictPrefix = "Manual:"
+
+// manualEvictionTimeout is how long a request by api waits for eviction loop
+const manualEvictionTimeout = time.Minute
+
+var (
+	manualEvictionRequests = metrics.NewCounterVec("eviction_agent_manual_evictions_total",
+		"Number of evictions requested by api by condition and result of decision.", "condition", "result")
+)
+
+// manualEvictions are the waiters of evictions requested by api keyed by
+// evict type, requests of the same type in queue share the decision
+type manualEvictions struct {
+	lock    sync.Mutex
+	waiters map[string][]chan Decision
+}
+
+// parseManualEvictType returns the evict type of a request by api
+func parseManualEvictType(evictType string) (string, bool) {
+	if !strings.HasPrefix(evictType, manualEvictPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(evictType, manualEvictPrefix), true
+}
+
+// Evict requests the eviction loop to choose and evict a pod of evictType
+// now, e.g. a DiskIOBusy offender, with the same scoring and filters as the
+// condition. A pod may be labeled instead as usual. Returns the decision.
+func (e *evictionManager) Evict(ctx context.Context, evictType string) (*Decision, error) {
+	if e.mode != options.ModeFull {
+		return nil, fmt.Errorf("pods are never evicted in %s mode", e.mode)
+	}
+	var evictTypes []string
+	for _, controller := range e.controllers {
+		evictTypes = append(evictTypes, controller.evictTypes...)
+	}
+	if !containsString(evictTypes, evictType) {
+		return nil, fmt.Errorf("unknown type %q, should be one of %v", evictType, evictTypes)
+	}
+	done := make(chan Decision, 1)
+	e.manual.lock.Lock()
+	if e.manual.waiters == nil {
+		e.manual.waiters = make(map[string][]chan Decision)
+	}
+	e.manual.waiters[evictType] = append(e.manual.waiters[evictType], done)
+	e.manual.lock.Unlock()
+	nodeCondition, _ := e.lastCondition.Load().(condition.NodeCondition)
+	request := newEvictionRequest(evictType, &nodeCondition)
+	request.Type = manualEvictPrefix + evictType
+	e.queue.Push(request)
+	select {
+	case decision := <-done:
+		return &decision, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("eviction of %s is not done: %v", evictType, ctx.Err())
+	case <-time.After(manualEvictionTimeout):
+		return nil, fmt.Errorf("eviction of %s is not done in %v", evictType, manualEvictionTimeout)
+	}
+}
+
+// evictManually evicts a pod for the waiting requests by api of the type of
+// request and passes the decision to them, requests coming meanwhile wait for
+// the next one. It's called by eviction loop.
+func (e *evictionManager) evictManually(ctx context.Context, request types.Condition) {
+	evictType := request.Type
+	e.manual.lock.Lock()
+	waiters := e.manual.waiters[evictType]
+	delete(e.manual.waiters, evictType)
+	e.manual.lock.Unlock()
+	if len(waiters) == 0 {
+		// waiters are served by the last eviction
+		return
+	}
+	log.Infof("evict pod of %s on manual request", evictType)
+	decision := e.evictOnePod(ctx, request, true)
+	manualEvictionRequests.Inc(evictType, decision.Result)
+	for _, done := range waiters {
+		done <- decision
+	}
+}
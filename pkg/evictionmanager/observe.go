@@ -0,0 +1,87 @@
+package evictionmanager
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"eviction-agent/pkg/condition"
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/metrics"
+	"eviction-agent/pkg/types"
+)
+
+var (
+	observedDecisions = metrics.NewCounterVec("eviction_agent_observed_decisions_total",
+		"Number of actions not taken during observation by condition and action.", "condition", "action")
+)
+
+// observation is the canary of --observe-for, every decision is recorded in
+// history but no action is taken until it ends. The start is kept in a node
+// annotation, so that restarts don't extend it.
+type observation struct {
+	duration time.Duration
+	until    time.Time
+	ended    int32 // set to 1 after the end is reported
+}
+
+// startObservation loads the start of observation from node, or sets it to now
+func (e *evictionManager) startObservation() {
+	if e.observation == nil {
+		return
+	}
+	now := e.clock.Now()
+	since := now
+	annotations, err := e.client.GetNodeAnnotations()
+	if err == nil {
+		if t, parseErr := time.Parse(time.RFC3339, annotations[types.ObservingSinceAnnotation]); parseErr == nil {
+			since = t
+		} else {
+			err = e.client.AnnotateNode(map[string]string{types.ObservingSinceAnnotation: now.Format(time.RFC3339)})
+		}
+	}
+	if err != nil {
+		log.Errorf("get start of observation error, observe for %v from now: %v", e.observation.duration, err)
+	}
+	e.observation.until = since.Add(e.observation.duration)
+	if !now.Before(e.observation.until) {
+		atomic.StoreInt32(&e.observation.ended, 1)
+		return
+	}
+	log.Warnf("Observe until %v, decisions are recorded but no action is taken", e.observation.until)
+}
+
+// observing returns true during observation or while actions are paused or
+// stopped in safe mode, decisions are recorded but no action is taken
+func (e *evictionManager) observing() bool {
+	return e.paused() || e.inSafeMode() || e.inObservation()
+}
+
+// inObservation returns true during observation, the end is reported once
+func (e *evictionManager) inObservation() bool {
+	if e.observation == nil {
+		return false
+	}
+	if e.clock.Now().Before(e.observation.until) {
+		return true
+	}
+	if atomic.CompareAndSwapInt32(&e.observation.ended, 0, 1) {
+		message := fmt.Sprintf("Observation of %v ended, eviction agent takes actions in %s mode",
+			e.observation.duration, e.mode)
+		log.Warnf("%s", message)
+		e.client.RecordNodeEvent(types.NormalEvent, types.ObservationEndedReason, message)
+	}
+	return false
+}
+
+// observeTaint records the taint or untaint not taken during observation
+func (e *evictionManager) observeTaint(taintKey, action string, nodeCondition *condition.NodeCondition) {
+	observedDecisions.Inc(taintKey, action)
+	e.history.add(Decision{
+		Time:         e.clock.Now(),
+		Condition:    taintKey,
+		Measurements: measurements(nodeCondition),
+		Action:       action,
+		Result:       "Observed",
+	})
+}
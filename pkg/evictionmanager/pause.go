@@ -0,0 +1,93 @@
+package evictionmanager
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/metrics"
+	"eviction-agent/pkg/types"
+)
+
+var (
+	pausedActions = metrics.NewGaugeVec("eviction_agent_paused",
+		"1 while actions are paused by the node annotation sncloud.com/paused.")
+)
+
+// pause freezes actions while node is annotated sncloud.com/paused, e.g.
+// during maintenance, without editing the DaemonSet. Like observation,
+// decisions are made and recorded but node is neither tainted nor untainted
+// and pods are not evicted or labeled. It's set by taint process.
+type pause struct {
+	paused int32     // set to 1 while paused
+	until  time.Time // expiry of pause, zero if it's paused until the annotation is removed
+}
+
+// paused returns true while actions are paused by node annotation
+func (e *evictionManager) paused() bool {
+	return atomic.LoadInt32(&e.pause.paused) == 1
+}
+
+// parsePause returns whether the value of paused annotation pauses actions
+// at now and its expiry. The value is true, or the expiry in RFC3339. Other
+// values pause actions too, since operators mean to pause.
+func parsePause(value string, now time.Time) (bool, time.Time, error) {
+	switch value {
+	case "", "false":
+		return false, time.Time{}, nil
+	case "true":
+		return true, time.Time{}, nil
+	}
+	until, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return true, time.Time{}, fmt.Errorf("%s should be true or an expiry in RFC3339: %q", types.PausedAnnotation, value)
+	}
+	return now.Before(until), until, nil
+}
+
+// checkPause pauses or resumes actions by the paused annotation of node, the
+// last state is kept if node can't be got. Controllers take taints of node as
+// the ones during pause. It's called by taint process.
+func (e *evictionManager) checkPause() {
+	annotations, err := e.client.GetNodeAnnotations()
+	if err != nil {
+		log.Errorf("check pause get node annotations error: %v", err)
+		return
+	}
+	paused, until, err := parsePause(annotations[types.PausedAnnotation], e.clock.Now())
+	if err != nil {
+		log.Warnf("Invalid pause annotation, pause until it's removed: %v", err)
+	}
+	if paused == e.paused() && until.Equal(e.pause.until) {
+		return
+	}
+	e.pause.until = until
+	if !paused {
+		atomic.StoreInt32(&e.pause.paused, 0)
+		pausedActions.Set(0)
+		message := "Eviction agent is resumed, actions are taken again"
+		log.Warnf("%s", message)
+		e.client.RecordNodeEvent(types.NormalEvent, types.ResumedReason, message)
+		return
+	}
+	if !e.paused() && !e.inObservation() {
+		e.adoptObservedTaints()
+	}
+	atomic.StoreInt32(&e.pause.paused, 1)
+	pausedActions.Set(1)
+	message := fmt.Sprintf("Eviction agent is paused by annotation %s, node is neither tainted nor untainted and "+
+		"pods are not evicted or labeled until it's removed", types.PausedAnnotation)
+	if !until.IsZero() {
+		message += fmt.Sprintf(" or until %s", until.Format(time.RFC3339))
+	}
+	log.Warnf("%s", message)
+	e.client.RecordNodeEvent(types.NormalEvent, types.PausedReason, message)
+}
+
+// adoptObservedTaints takes taints of node as the ones during pause
+func (e *evictionManager) adoptObservedTaints() {
+	for _, controller := range e.controllers {
+		controller.observedTaint = controller.tainted(&e.nodeTaint)
+	}
+}
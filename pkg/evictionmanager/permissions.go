@@ -0,0 +1,63 @@
+package evictionmanager
+
+import (
+	"fmt"
+	"strings"
+
+	"eviction-agent/cmd/options"
+	"eviction-agent/pkg/evictionclient"
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/types"
+)
+
+var (
+	patchNodes     = evictionclient.Permission{Verb: "patch", Resource: "nodes"}
+	evictPods      = evictionclient.Permission{Verb: "create", Resource: "pods", Subresource: "eviction"}
+	patchPods      = evictionclient.Permission{Verb: "patch", Resource: "pods"}
+	listNamespaces = evictionclient.Permission{Verb: "list", Resource: "namespaces"}
+)
+
+// requiredPermissions returns permissions needed by actions of mode, pods
+// are labeled and resized by patch. Namespaces are listed for preferences.
+func requiredPermissions(mode string, namespacePreferences bool) []evictionclient.Permission {
+	var permissions []evictionclient.Permission
+	switch mode {
+	case options.ModeMonitorOnly:
+	case options.ModeTaintOnly:
+		permissions = []evictionclient.Permission{patchNodes}
+	default:
+		permissions = []evictionclient.Permission{patchNodes, evictPods, patchPods}
+	}
+	if namespacePreferences {
+		permissions = append(permissions, listNamespaces)
+	}
+	return permissions
+}
+
+// checkPermissions fails fast with missing permissions at startup, instead of
+// failing actions at the first pressure. The check is skipped if the review
+// itself fails, e.g. api server is unreachable.
+func (e *evictionManager) checkPermissions() error {
+	permissions := requiredPermissions(e.mode, e.namespacePreferences)
+	if len(permissions) == 0 {
+		return nil
+	}
+	denied, err := e.client.CheckPermissions(permissions)
+	if types.AsFatalError(err) != nil {
+		return err
+	}
+	if err != nil {
+		log.Warnf("check permissions error, skip it: %v", err)
+		return nil
+	}
+	if len(denied) > 0 {
+		missing := make([]string, len(denied))
+		for i, permission := range denied {
+			missing[i] = permission.String()
+		}
+		return types.NewFatalError(types.FatalUnauthorized,
+			fmt.Errorf("missing permissions of %s mode: %s", e.mode, strings.Join(missing, ", ")))
+	}
+	log.Infof("Permissions checked: %v", permissions)
+	return nil
+}
@@ -0,0 +1,178 @@
+package evictionmanager
+
+import (
+	"time"
+
+	"eviction-agent/pkg/condition"
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/metrics"
+)
+
+// Phase is the state of a condition in taint process
+type Phase string
+
+const (
+	// PhaseHealthy is a condition available and node not tainted by it
+	PhaseHealthy Phase = "Healthy"
+	// PhaseSoftPressure is a busy condition node is not tainted by and pods
+	// are not evicted for, during taint delay, while cluster has no headroom
+	// or taint fails, or with taint disabled and evictions left to kubelet
+	PhaseSoftPressure Phase = "SoftPressure"
+	// PhaseTainted is a busy condition with node tainted
+	PhaseTainted Phase = "Tainted"
+	// PhaseEvicting is a busy condition with evictions requested
+	PhaseEvicting Phase = "Evicting"
+	// PhaseRecovering is an available condition waiting for untaint grace period
+	PhaseRecovering Phase = "Recovering"
+)
+
+// maxTransitionRecords is the max number of the latest transitions kept of each condition
+const maxTransitionRecords = 10
+
+var phases = []Phase{PhaseHealthy, PhaseSoftPressure, PhaseTainted, PhaseEvicting, PhaseRecovering}
+
+// transitions are the valid next phases of each phase, others are refused
+var transitions = map[Phase][]Phase{
+	// node may be tainted before agent starts, pods are evicted without
+	// taint if actions of policy have no Taint
+	PhaseHealthy: {PhaseSoftPressure, PhaseTainted, PhaseEvicting, PhaseRecovering},
+	// evictions are requested even if taint fails, node may be tainted by others
+	PhaseSoftPressure: {PhaseTainted, PhaseEvicting, PhaseRecovering, PhaseHealthy},
+	// node may be untainted by others or the condition may be disabled
+	PhaseTainted:  {PhaseEvicting, PhaseRecovering, PhaseSoftPressure, PhaseHealthy},
+	PhaseEvicting: {PhaseTainted, PhaseRecovering, PhaseSoftPressure, PhaseHealthy},
+	// node is tainted again before pods are evicted
+	PhaseRecovering: {PhaseTainted, PhaseSoftPressure, PhaseHealthy},
+}
+
+var (
+	conditionPhase = metrics.NewGaugeVec("eviction_agent_condition_phase",
+		"Current phase of each condition, 1 for the current phase and 0 for others.", "condition", "phase")
+	conditionTransitions = metrics.NewCounterVec("eviction_agent_condition_transitions_total",
+		"Number of phase transitions of each condition.", "condition", "from", "to")
+	lastTransitionTime = metrics.NewGaugeVec("eviction_agent_condition_last_transition_timestamp_seconds",
+		"Unix time of the last phase transition of each condition.", "condition")
+	lastPhaseDuration = metrics.NewGaugeVec("eviction_agent_condition_last_phase_duration_seconds",
+		"Seconds each condition stayed in a phase the last time it left it.", "condition", "phase")
+	invalidTransitions = metrics.NewCounterVec("eviction_agent_condition_invalid_transitions_total",
+		"Number of refused phase transitions of each condition, which are bugs of agent.", "condition", "from", "to")
+)
+
+// transitionHook is called after a condition moves from one phase to another
+type transitionHook func(cc *conditionController, from, to Phase)
+
+// defaultTransitionHooks are the hooks of all controllers
+var defaultTransitionHooks = []transitionHook{logTransition, recordTransition, recordHistory}
+
+// PhaseStatus is the phase of one condition
+type PhaseStatus struct {
+	Condition string    `json:"condition"`
+	TaintKey  string    `json:"taintKey"`
+	Phase     Phase     `json:"phase"`
+	Since     time.Time `json:"since"`
+	// Transitions are the latest transitions of the condition, the oldest first
+	Transitions []TransitionRecord `json:"transitions,omitempty"`
+}
+
+// TransitionRecord is a phase transition of a condition, with how long and
+// how far past threshold the condition was in the phase it left
+type TransitionRecord struct {
+	From     Phase         `json:"from"`
+	To       Phase         `json:"to"`
+	Time     time.Time     `json:"time"`
+	Duration time.Duration `json:"duration"`
+	// Peak is the peak of measured values by ratio of threshold in phase
+	// from, e.g. 1.2 for 20% past threshold, zero if it's not measured
+	Peak float64 `json:"peak,omitempty"`
+}
+
+// validTransition returns true if to is an expected next phase of from
+func validTransition(from, to Phase) bool {
+	for _, phase := range transitions[from] {
+		if phase == to {
+			return true
+		}
+	}
+	return false
+}
+
+// transition moves the condition to phase and calls hooks, it's a no-op
+// if the condition is already in phase. An invalid transition is refused
+// and counted, the condition stays in its phase.
+func (cc *conditionController) transition(e *evictionManager, to Phase) {
+	from := cc.phase
+	if from == to {
+		return
+	}
+	if !validTransition(from, to) {
+		invalidTransitions.Inc(cc.name, string(from), string(to))
+		log.Errorf("refuse invalid phase transition of %s: %s -> %s", cc.name, from, to)
+		return
+	}
+	now := e.clock.Now()
+	cc.phaseDuration = now.Sub(cc.phaseTime)
+	cc.phase = to
+	cc.phaseTime = now
+	for _, hook := range cc.hooks {
+		hook(cc, from, to)
+	}
+}
+
+// phaseStatus returns the current phase of the condition
+func (cc *conditionController) phaseStatus() PhaseStatus {
+	return PhaseStatus{
+		Condition:   cc.name,
+		TaintKey:    cc.taintKey,
+		Phase:       cc.phase,
+		Since:       cc.phaseTime,
+		Transitions: append([]TransitionRecord(nil), cc.transitions...),
+	}
+}
+
+// observePeak keeps the peak of measured values of the condition by ratio of
+// threshold in the current phase
+func (cc *conditionController) observePeak(nodeCondition *condition.NodeCondition) {
+	for _, evictType := range cc.evictTypes {
+		value, threshold, ok := nodeCondition.Measured(evictType)
+		if ok && threshold > 0 && value/threshold > cc.peak {
+			cc.peak = value / threshold
+		}
+	}
+}
+
+func logTransition(cc *conditionController, from, to Phase) {
+	log.Infow("condition phase changed", "condition", cc.name, "from", from, "to", to)
+}
+
+func recordTransition(cc *conditionController, from, to Phase) {
+	conditionTransitions.Inc(cc.name, string(from), string(to))
+	setPhaseMetric(cc.name, to)
+}
+
+// recordHistory keeps the transition in the latest transitions of the
+// condition, the peak of the next phase starts over
+func recordHistory(cc *conditionController, from, to Phase) {
+	cc.transitions = append(cc.transitions, TransitionRecord{
+		From:     from,
+		To:       to,
+		Time:     cc.phaseTime,
+		Duration: cc.phaseDuration,
+		Peak:     cc.peak,
+	})
+	if len(cc.transitions) > maxTransitionRecords {
+		cc.transitions = cc.transitions[1:]
+	}
+	cc.peak = 0
+	lastTransitionTime.Set(float64(cc.phaseTime.Unix()), cc.name)
+	lastPhaseDuration.Set(cc.phaseDuration.Seconds(), cc.name, string(from))
+}
+
+func setPhaseMetric(condition string, current Phase) {
+	for _, phase := range phases {
+		value := 0.0
+		if phase == current {
+			value = 1
+		}
+		conditionPhase.Set(value, condition, string(phase))
+	}
+}
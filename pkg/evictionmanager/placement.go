@@ -0,0 +1,130 @@
+package evictionmanager
+
+import (
+	"fmt"
+	"time"
+
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/metrics"
+	"eviction-agent/pkg/types"
+)
+
+var (
+	replacementPlacements = metrics.NewCounterVec("eviction_agent_replacement_placements_total",
+		"Number of replacements of evicted pods by where they are scheduled, node, other or unknown if not scheduled in time.", "placement")
+	placementStopped = metrics.NewGaugeVec("eviction_agent_placement_stopped",
+		"1 while evictions are stopped because replacements of evicted pods are scheduled back on node.")
+)
+
+// placement paces evictions by scheduler placement of replacements. After a
+// pod is evicted the next one is evicted only once its replacement is
+// scheduled, or it's not scheduled in wait. Replacements scheduled back on
+// node, e.g. by tolerations of taints, don't relieve node but churn pods, so
+// evictions are stopped for backoff once maxReturns of them land in a row.
+// It's only used by the eviction loop.
+type placement struct {
+	wait       time.Duration
+	maxReturns int
+	backoff    time.Duration
+	// pending is the last evicted pod whose replacement is not scheduled yet
+	pending *pendingReplacement
+	// returns are consecutive replacements scheduled back on node
+	returns int
+	// stoppedUntil is the end of backoff, zero if evictions are not stopped
+	stoppedUntil time.Time
+}
+
+// pendingReplacement is an evicted pod of controller
+type pendingReplacement struct {
+	pod        string // namespace/name
+	controller *types.Workload
+	evictedAt  time.Time
+}
+
+// replacementController returns the controller creating the replacement of
+// pod, nil if placement is disabled or pod has no controller. It's got before
+// eviction, the controller of pod can't be got once it's deleted.
+func (e *evictionManager) replacementController(pod *types.PodInfo) *types.Workload {
+	if e.placement == nil || pod.Name == "" {
+		return nil
+	}
+	controller, err := e.client.GetPodController(pod)
+	if err != nil {
+		log.Errorf("get controller of pod %s/%s error: %v", pod.Namespace, pod.Name, err)
+		return nil
+	}
+	return controller
+}
+
+// awaitReplacement waits for the replacement of pod evicted, pods without
+// controller are not replaced
+func (e *evictionManager) awaitReplacement(pod *types.PodInfo, controller *types.Workload) {
+	if e.placement == nil || controller == nil {
+		return
+	}
+	e.placement.pending = &pendingReplacement{
+		pod:        pod.Namespace + "/" + pod.Name,
+		controller: controller,
+		evictedAt:  e.clock.Now(),
+	}
+}
+
+// placementBlocked returns why the next eviction should wait, empty if it can
+// be taken
+func (e *evictionManager) placementBlocked() string {
+	p := e.placement
+	if p == nil {
+		return ""
+	}
+	now := e.clock.Now()
+	if !p.stoppedUntil.IsZero() {
+		if now.Before(p.stoppedUntil) {
+			return fmt.Sprintf("stopped until %s, replacements of evicted pods are scheduled back on node",
+				p.stoppedUntil.Format(time.RFC3339))
+		}
+		log.Infof("resume evictions stopped by replacements scheduled back on node")
+		p.stoppedUntil = time.Time{}
+		placementStopped.Set(0)
+	}
+	if p.pending == nil {
+		return ""
+	}
+	pending := p.pending
+	replacement, err := e.client.GetReplacementPod(pending.controller, pending.evictedAt)
+	if err != nil {
+		log.Errorf("get replacement of pod %s error: %v", pending.pod, err)
+	}
+	switch {
+	case replacement != nil && replacement.NodeName == e.nodeName:
+		p.pending = nil
+		p.returns++
+		replacementPlacements.Inc("node")
+		log.Warnf("replacement %s/%s of evicted pod %s is scheduled back on node, %d in a row",
+			replacement.Namespace, replacement.Name, pending.pod, p.returns)
+		if p.returns < p.maxReturns {
+			return ""
+		}
+		p.returns = 0
+		p.stoppedUntil = now.Add(p.backoff)
+		placementStopped.Set(1)
+		message := fmt.Sprintf("Evictions are stopped for %v, replacements of %d evicted pods in a row are scheduled "+
+			"back on node, e.g. %s %s/%s tolerating taints of node", p.backoff, p.maxReturns,
+			pending.controller.Kind, pending.controller.Namespace, pending.controller.Name)
+		log.Warnf("%s", message)
+		e.client.RecordNodeEvent(types.WarningEvent, types.EvictionChurnReason, message)
+		return message
+	case replacement != nil && replacement.NodeName != "":
+		p.pending = nil
+		p.returns = 0
+		replacementPlacements.Inc("other")
+		log.Infof("replacement %s/%s of evicted pod %s is scheduled on %s",
+			replacement.Namespace, replacement.Name, pending.pod, replacement.NodeName)
+		return ""
+	case now.Sub(pending.evictedAt) < p.wait:
+		return fmt.Sprintf("waiting for replacement of %s to be scheduled", pending.pod)
+	}
+	p.pending = nil
+	replacementPlacements.Inc("unknown")
+	log.Infof("replacement of evicted pod %s is not scheduled in %v, stop waiting", pending.pod, p.wait)
+	return ""
+}
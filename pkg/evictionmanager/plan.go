@@ -0,0 +1,194 @@
+package evictionmanager
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"eviction-agent/pkg/condition"
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/metrics"
+	"eviction-agent/pkg/types"
+)
+
+var (
+	plans = metrics.NewCounterVec("eviction_agent_plans_total",
+		"Number of plans of multiple victims by condition and whether their usage covers the excess over threshold.",
+		"condition", "covered")
+	plannedVictims = metrics.NewGaugeVec("eviction_agent_planned_victims",
+		"Victims of the current plan left to evict by condition.", "condition")
+)
+
+// planner plans the fewest pods whose usage relieves the excess over
+// threshold when a pod is not enough, instead of evicting the top consumer
+// each time and waiting for stats to catch up. The victims are evicted at
+// pace. It's only used by the eviction loop.
+type planner struct {
+	pace       time.Duration
+	maxVictims int
+	// current is the plan of condition being executed, nil if there is none
+	current   *Plan
+	condition string
+	// left are victims of current not evicted yet
+	left []PlanVictim
+	// nextAt is the earliest time of the next eviction of current
+	nextAt time.Time
+	// expiresAt is when current is done or abandoned, e.g. victims are not
+	// evicted in time once the condition is relieved
+	expiresAt time.Time
+}
+
+// Plan is a set of victims of a condition whose usage relieves the excess
+type Plan struct {
+	// Excess is the measured value of the condition over its threshold
+	Excess float64 `json:"excess"`
+	// Relief is the total usage of victims
+	Relief  float64      `json:"relief"`
+	Covered bool         `json:"covered"`
+	Victims []PlanVictim `json:"victims"`
+	// Next is the index of the victim of the decision
+	Next int `json:"next"`
+}
+
+// PlanVictim is a pod of a plan
+type PlanVictim struct {
+	Pod   string  `json:"pod"`
+	Usage float64 `json:"usage"`
+	Score float64 `json:"score"`
+	// Budget is the PodDisruptionBudget of pod, empty if there is none
+	Budget string `json:"budget,omitempty"`
+}
+
+// planExcess returns the measured value of evictType over its threshold,
+// false if evictType is not measured against a threshold of node, e.g. priority bands
+func planExcess(evictType string, nodeCondition *condition.NodeCondition) (float64, bool) {
+	if _, _, ok := condition.ParseBandEvictType(evictType); ok {
+		return 0, false
+	}
+	value, threshold, ok := nodeCondition.Measured(evictType)
+	return value - threshold, ok
+}
+
+// planVictims picks victims from candidates ordered by score, greedy over
+// usage: the best scored candidate covering the rest of excess alone is
+// taken, or else the best scored one. Candidates scheduled back on node
+// relieve nothing, and candidates whose PDB allows no more disruptions are
+// skipped. Victims not needed to cover excess are dropped at last, the worst
+// scored first.
+func planVictims(excess float64, candidates []condition.Candidate, budgets map[string]types.DisruptionBudget, maxVictims int) *Plan {
+	plan := &Plan{Excess: excess}
+	disruptions := make(map[string]int)
+	taken := make([]bool, len(candidates))
+	for plan.Relief < excess && len(plan.Victims) < maxVictims {
+		best := -1
+		for i, candidate := range candidates {
+			if taken[i] || candidate.Returns || candidate.Usage <= 0 {
+				continue
+			}
+			pod := candidate.Pod.Namespace + "/" + candidate.Pod.Name
+			if budget, ok := budgets[pod]; ok && disruptions[budget.Name] >= budget.Allowed {
+				continue
+			}
+			if best < 0 {
+				best = i
+			}
+			if candidate.Usage >= excess-plan.Relief {
+				best = i
+				break
+			}
+		}
+		if best < 0 {
+			break
+		}
+		taken[best] = true
+		candidate := candidates[best]
+		victim := PlanVictim{
+			Pod:   candidate.Pod.Namespace + "/" + candidate.Pod.Name,
+			Usage: candidate.Usage,
+			Score: candidate.Score,
+		}
+		if budget, ok := budgets[victim.Pod]; ok {
+			victim.Budget = budget.Name
+			disruptions[budget.Name]++
+		}
+		plan.Victims = append(plan.Victims, victim)
+		plan.Relief += victim.Usage
+	}
+	sort.SliceStable(plan.Victims, func(i, j int) bool {
+		return plan.Victims[i].Score > plan.Victims[j].Score
+	})
+	for i := len(plan.Victims) - 1; i >= 0 && plan.Relief >= excess; i-- {
+		if plan.Relief-plan.Victims[i].Usage >= excess {
+			plan.Relief -= plan.Victims[i].Usage
+			plan.Victims = append(plan.Victims[:i], plan.Victims[i+1:]...)
+		}
+	}
+	plan.Covered = plan.Relief >= excess
+	return plan
+}
+
+// planEviction returns the next victim of the plan of evictType, a new plan
+// is made from ranking if there is none or the last one expired. Returns nil
+// if the chosen pod is evicted as usual, e.g. the excess is not measured, or
+// why the eviction should wait for pace of the plan.
+func (e *evictionManager) planEviction(evictType string, nodeCondition *condition.NodeCondition,
+	decision *Decision) (*types.PodInfo, string) {
+	p := e.planner
+	if p == nil {
+		return nil, ""
+	}
+	now := e.clock.Now()
+	if p.current != nil && (evictType != p.condition || !now.Before(p.expiresAt)) {
+		if len(p.left) != 0 {
+			log.Infof("abandon plan of %s, %d victims are left", p.condition, len(p.left))
+		}
+		plannedVictims.Set(0, p.condition)
+		p.current, p.left = nil, nil
+	}
+	if p.current == nil {
+		excess, ok := planExcess(evictType, nodeCondition)
+		if !ok || excess <= 0 {
+			return nil, ""
+		}
+		budgets, err := e.client.GetDisruptionBudgets()
+		if err != nil {
+			log.Errorf("plan victims of %s get disruption budgets error: %v", evictType, err)
+			return nil, ""
+		}
+		plan := planVictims(excess, decision.Candidates, budgets, p.maxVictims)
+		if len(plan.Victims) == 0 {
+			return nil, ""
+		}
+		plans.Inc(evictType, fmt.Sprint(plan.Covered))
+		log.Infof("plan %d victims of %s relieving %.4g of excess %.4g: %+v",
+			len(plan.Victims), evictType, plan.Relief, plan.Excess, plan.Victims)
+		plan.Next = -1
+		p.current, p.left, p.condition = plan, plan.Victims, evictType
+		p.nextAt = now
+	}
+	if now.Before(p.nextAt) {
+		return nil, fmt.Sprintf("waiting for pace of plan, the next victim is evicted at %s",
+			p.nextAt.Format(time.RFC3339))
+	}
+	// victims gone or filtered out since the plan is made are skipped
+	for len(p.left) != 0 {
+		victim := p.left[0]
+		p.left = p.left[1:]
+		p.current.Next++
+		for _, candidate := range decision.Candidates {
+			if candidate.Pod.Namespace+"/"+candidate.Pod.Name != victim.Pod {
+				continue
+			}
+			plan := *p.current
+			decision.Plan = &plan
+			// stats of the last victim are relieved in two paces, a new plan is made then
+			p.nextAt = now.Add(p.pace)
+			p.expiresAt = now.Add(2 * p.pace)
+			plannedVictims.Set(float64(len(p.left)), evictType)
+			pod := candidate.Pod
+			return &pod, ""
+		}
+	}
+	plannedVictims.Set(0, evictType)
+	return nil, fmt.Sprintf("waiting for plan to relieve %s until %s", evictType, p.expiresAt.Format(time.RFC3339))
+}
@@ -0,0 +1,59 @@
+package evictionmanager
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/metrics"
+	"eviction-agent/pkg/preemption"
+	"eviction-agent/pkg/types"
+)
+
+// preemptionEvictType is the eviction request of drain of preemption
+const preemptionEvictType = "Preemption"
+
+// preemptionPriority is the priority of preemption requests in eviction
+// queue, they are handled before all conditions since node is terminated soon
+const preemptionPriority = -1
+
+var (
+	preemptions = metrics.NewCounterVec("eviction_agent_preemptions_total",
+		"Number of preemption notices of instance of node by cloud provider.", "provider")
+)
+
+// drainPreempted cordons node once instance of node is preempted, and
+// requests evictions of drain at spotDrainPace until node is drained or ctx
+// is done. It's called by preemption monitor.
+func (e *evictionManager) drainPreempted(ctx context.Context, notice *preemption.Notice) {
+	message := fmt.Sprintf("Instance of node is preempted by %s to %s at %s",
+		notice.Provider, notice.Action, notice.Time.Format(time.RFC3339))
+	preemptions.Inc(notice.Provider)
+	if e.observing() {
+		message += ", node is not drained while observing"
+	} else {
+		message += ", node is cordoned and drained"
+	}
+	log.Warnf("%s", message)
+	e.client.RecordNodeEvent(types.WarningEvent, types.NodePreemptedReason, message)
+	if e.observing() {
+		return
+	}
+	if err := e.client.CordonNode(); err != nil {
+		// pods are evicted anyway, replacements may be scheduled back until node is gone
+		log.Errorf("preemption cordon node error: %v", err)
+		e.status.recordError(fmt.Sprintf("preemption cordon node error: %v", err))
+	}
+	d := e.drain
+	atomic.StoreInt32(&d.preempted, 1)
+	for atomic.LoadInt32(&d.drained) == 0 {
+		e.queue.Push(types.Condition{Type: preemptionEvictType, Severity: types.SeverityCritical})
+		select {
+		case <-ctx.Done():
+			return
+		case <-e.clock.After(e.spotDrainPace):
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package evictionmanager
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/metrics"
+	"eviction-agent/pkg/types"
+)
+
+var (
+	quarantinedWorkloads = metrics.NewCounterVec("eviction_agent_quarantined_workloads_total",
+		"Number of workloads quarantined by evict type.", "condition")
+)
+
+// quarantine labels workloads whose pods are evicted for the same evict type
+// threshold times in window, so that platform teams can follow up. Eviction
+// times are kept in an annotation of workload, so evictions by agents of all
+// nodes are counted.
+type quarantine struct {
+	threshold int
+	window    time.Duration
+}
+
+// offenses are eviction times of pods of a workload by evict type, the oldest first
+type offenses map[string][]time.Time
+
+// add records an eviction at now, evictions out of window are dropped and
+// only the latest threshold ones are kept. Returns evictions of evictType.
+func (o offenses) add(evictType string, now time.Time, q *quarantine) int {
+	o[evictType] = append(o[evictType], now)
+	start := now.Add(-q.window)
+	for t, times := range o {
+		i := 0
+		for i < len(times) && times[i].Before(start) {
+			i++
+		}
+		if len(times)-i > q.threshold {
+			i = len(times) - q.threshold
+		}
+		if i == len(times) {
+			delete(o, t)
+		} else {
+			o[t] = times[i:]
+		}
+	}
+	return len(o[evictType])
+}
+
+// recordOffense counts eviction of pod against its workload, and quarantines
+// the workload once it reaches threshold
+func (e *evictionManager) recordOffense(evictType string, pod *types.PodInfo) {
+	if e.quarantine == nil {
+		return
+	}
+	workload, err := e.client.GetPodWorkload(pod)
+	if err != nil || workload == nil {
+		return
+	}
+	var message string
+	err = e.client.UpdateWorkloadMetadata(workload, func(labels, annotations map[string]string) bool {
+		message = ""
+		o := offenses{}
+		if data, ok := annotations[types.EvictionOffensesAnnotation]; ok {
+			if err := json.Unmarshal([]byte(data), &o); err != nil {
+				log.Warnf("invalid %s of %s %s/%s, reset it: %v", types.EvictionOffensesAnnotation,
+					workload.Kind, workload.Namespace, workload.Name, err)
+				o = offenses{}
+			}
+		}
+		count := o.add(evictType, e.clock.Now(), e.quarantine)
+		data, err := json.Marshal(o)
+		if err != nil {
+			return false
+		}
+		annotations[types.EvictionOffensesAnnotation] = string(data)
+		if count >= e.quarantine.threshold && labels[types.QuarantineLabel] != "true" {
+			message = fmt.Sprintf("Pods of %s %s are evicted %d times for %s in %v",
+				workload.Kind, workload.Name, count, evictType, e.quarantine.window)
+			labels[types.QuarantineLabel] = "true"
+			annotations[types.QuarantineReasonAnnotation] = message
+		}
+		return true
+	})
+	if err != nil || message == "" {
+		return
+	}
+	quarantinedWorkloads.Inc(evictType)
+	log.Warnw("workload quarantined", "condition", evictType, "pod", pod.Namespace+"/"+pod.Name,
+		"workload", workload.Kind+"/"+workload.Name)
+	e.client.RecordWorkloadEvent(workload, types.WarningEvent, types.WorkloadQuarantinedReason, message)
+}
@@ -0,0 +1,221 @@
+package evictionmanager
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"eviction-agent/pkg/condition"
+	"eviction-agent/pkg/metrics"
+	"eviction-agent/pkg/types"
+)
+
+// evictionPriority orders eviction requests, lower value is popped first.
+// Memory pressure leads to OOM kills, so it's handled first.
+var evictionPriority = map[string]int{
+	types.MemBusy:            0,
+	types.CPUBusy:            1,
+	types.DiskIO:             2,
+	types.WritebackBusy:      2,
+	types.NetworkRxBusy:      3,
+	types.NetworkTxBusy:      3,
+	types.EphemeralPortsBusy: 3,
+}
+
+// extendedResourcePriority is the priority of extended resources, whose taint
+// keys have the domain of resource while node problems have none
+const extendedResourcePriority = 4
+
+// nodeProblemPriority is the priority of node problems, they are handled
+// after resources since usage of them is not reclaimed by evictions
+const nodeProblemPriority = 5
+
+func priority(evictType string) int {
+	evictType = baseEvictType(evictType)
+	if p, ok := evictionPriority[evictType]; ok {
+		return p
+	}
+	if evictType == drainEvictType {
+		return drainPriority
+	}
+	if evictType == preemptionEvictType {
+		return preemptionPriority
+	}
+	if strings.Contains(evictType, "/") {
+		return extendedResourcePriority
+	}
+	return nodeProblemPriority
+}
+
+// baseEvictType returns the evict type of resource of manual and band requests
+func baseEvictType(evictType string) string {
+	if manualEvictType, ok := parseManualEvictType(evictType); ok {
+		evictType = manualEvictType
+	}
+	if bandEvictType, _, ok := condition.ParseBandEvictType(evictType); ok {
+		evictType = bandEvictType
+	}
+	return evictType
+}
+
+var severityRanks = map[types.Severity]int{types.SeverityCritical: 2, types.SeverityWarning: 1}
+
+var (
+	evictionRequests = metrics.NewCounterVec("eviction_agent_eviction_requests_total",
+		"Number of eviction requests by condition and result, queued, deduplicated or superseded.", "condition", "result")
+)
+
+// evictionQueue is a priority queue of eviction requests keyed by evict
+// type, a request for the condition already in queue replaces it
+type evictionQueue struct {
+	lock    sync.Mutex
+	pending map[string]types.Condition
+	ready   chan struct{} // not empty if pending may be not empty
+	// order are evict types of --condition-priority by their positions
+	order map[string]int
+	// mostSevere pops the most severe of requests of busy conditions, the others are dropped
+	mostSevere bool
+}
+
+func newEvictionQueue(order []string, mostSevere bool) *evictionQueue {
+	q := &evictionQueue{
+		pending:    make(map[string]types.Condition),
+		ready:      make(chan struct{}, 1),
+		order:      make(map[string]int, len(order)),
+		mostSevere: mostSevere,
+	}
+	for i, evictType := range order {
+		q.order[evictType] = i
+	}
+	return q
+}
+
+// rank returns the order of evictType in queue, lower is popped first.
+// Preemption is the first, then evict types of --condition-priority as
+// listed, and the others by priority.
+func (q *evictionQueue) rank(evictType string) int {
+	p := priority(evictType)
+	if p == preemptionPriority {
+		return p - len(q.order)
+	}
+	if i, ok := q.order[baseEvictType(evictType)]; ok {
+		return i - len(q.order)
+	}
+	return p
+}
+
+// Push adds a request of the condition, the measurements of a request
+// already in queue are updated. It never blocks.
+func (q *evictionQueue) Push(request types.Condition) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	_, ok := q.pending[request.Type]
+	q.pending[request.Type] = request
+	if ok {
+		evictionRequests.Inc(request.Type, "deduplicated")
+		return
+	}
+	evictionRequests.Inc(request.Type, "queued")
+	q.signal()
+}
+
+// Pop waits for the request with the highest priority, returns false if ctx is done
+func (q *evictionQueue) Pop(ctx context.Context) (types.Condition, bool) {
+	for {
+		q.lock.Lock()
+		if evictType := q.first(); evictType != "" {
+			if q.mostSevere && isPressure(evictType) {
+				evictType = q.supersede()
+			}
+			request := q.pending[evictType]
+			delete(q.pending, evictType)
+			if len(q.pending) != 0 {
+				q.signal()
+			}
+			q.lock.Unlock()
+			return request, true
+		}
+		q.lock.Unlock()
+
+		select {
+		case <-q.ready:
+		case <-ctx.Done():
+			return types.Condition{}, false
+		}
+	}
+}
+
+// first returns the pending request with the highest priority, lock must be held
+func (q *evictionQueue) first() string {
+	first := ""
+	for evictType := range q.pending {
+		if first == "" || q.rank(evictType) < q.rank(first) ||
+			(q.rank(evictType) == q.rank(first) && evictType < first) {
+			first = evictType
+		}
+	}
+	return first
+}
+
+// supersede returns the most severe of pending requests of busy conditions by
+// severity, excess over threshold by ratio and then priority. The others are
+// dropped, they're pushed again by next cycles if conditions are still busy.
+// Lock must be held.
+func (q *evictionQueue) supersede() string {
+	most := ""
+	for evictType, request := range q.pending {
+		if !isPressure(evictType) {
+			continue
+		}
+		if most == "" || q.moreSevere(request, q.pending[most]) {
+			most = evictType
+		}
+	}
+	for evictType := range q.pending {
+		if evictType != most && isPressure(evictType) {
+			delete(q.pending, evictType)
+			evictionRequests.Inc(evictType, "superseded")
+		}
+	}
+	return most
+}
+
+// moreSevere returns true if request a is more severe than b
+func (q *evictionQueue) moreSevere(a, b types.Condition) bool {
+	if severityRanks[a.Severity] != severityRanks[b.Severity] {
+		return severityRanks[a.Severity] > severityRanks[b.Severity]
+	}
+	if ea, eb := excess(a), excess(b); ea != eb {
+		return ea > eb
+	}
+	if q.rank(a.Type) != q.rank(b.Type) {
+		return q.rank(a.Type) < q.rank(b.Type)
+	}
+	return a.Type < b.Type
+}
+
+// excess returns the excess of request over its threshold by ratio, zero if
+// it's not measured against a threshold
+func excess(request types.Condition) float64 {
+	if request.Threshold <= 0 {
+		return 0
+	}
+	return (request.Value - request.Threshold) / request.Threshold
+}
+
+// isPressure returns true if requests of evictType are of busy conditions,
+// instead of manual evictions, drains and preemptions
+func isPressure(evictType string) bool {
+	if _, ok := parseManualEvictType(evictType); ok {
+		return false
+	}
+	return evictType != drainEvictType && evictType != preemptionEvictType
+}
+
+// signal wakes up Pop, lock must be held
+func (q *evictionQueue) signal() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
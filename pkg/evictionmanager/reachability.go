@@ -0,0 +1,108 @@
+package evictionmanager
+
+import (
+	"sync"
+	"time"
+
+	"eviction-agent/pkg/evictionclient"
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/metrics"
+)
+
+var (
+	apiReachable = metrics.NewGaugeVec("eviction_agent_api_reachable",
+		"1 if api server is reached by the latest cycle, 0 while it's unreachable.")
+	apiDisconnectedSeconds = metrics.NewGaugeVec("eviction_agent_api_disconnected_seconds",
+		"Duration of the current disconnection from api server in seconds, 0 while it's reachable.")
+	apiDisconnectedSecondsTotal = metrics.NewCounterVec("eviction_agent_api_disconnected_seconds_total",
+		"Total duration of disconnections from api server in seconds.")
+	apiDisconnections = metrics.NewCounterVec("eviction_agent_api_disconnections_total",
+		"Number of disconnections from api server.")
+	replayedActions = metrics.NewCounterVec("eviction_agent_replayed_actions_total",
+		"Number of actions queued while api server is unreachable and replayed after it's reached, by result.", "result")
+)
+
+// pendingAction is an action failed while api server is unreachable
+type pendingAction struct {
+	key string
+	run func() error
+}
+
+// reachability tracks whether api server is reached, by the node got in each
+// cycle. While it's unreachable taints and pods of node are stale, so pods are
+// not evicted or labeled by them, and node conditions reported are queued and
+// replayed once it's reached. Taints are not queued, controllers take them
+// again by fresh node in the cycle reaching api server.
+type reachability struct {
+	lock sync.Mutex
+	// since is the start of the current disconnection, zero while connected
+	since   time.Time
+	pending []pendingAction // the latest one of each key, the oldest first
+}
+
+// checkReachability updates reachability by err of getting node in a cycle,
+// and replays actions queued once api server is reached again
+func (e *evictionManager) checkReachability(err error) {
+	r := &e.reachability
+	now := e.clock.Now()
+	r.lock.Lock()
+	if err == nil {
+		since := r.since
+		pending := r.pending
+		r.since, r.pending = time.Time{}, nil
+		r.lock.Unlock()
+		apiReachable.Set(1)
+		apiDisconnectedSeconds.Set(0)
+		if !since.IsZero() {
+			duration := now.Sub(since)
+			apiDisconnectedSecondsTotal.Add(duration.Seconds())
+			log.Infof("api server is reached after disconnected for %v, replay %d actions", duration, len(pending))
+		}
+		for _, action := range pending {
+			if err := action.run(); err != nil {
+				replayedActions.Inc("failed")
+				log.Errorf("replay %s error: %v", action.key, err)
+				continue
+			}
+			replayedActions.Inc("succeeded")
+		}
+		return
+	}
+	defer r.lock.Unlock()
+	if !evictionclient.IsUnreachable(err) {
+		return
+	}
+	if r.since.IsZero() {
+		r.since = now
+		apiDisconnections.Inc()
+		log.Warnf("api server is unreachable, suspend evictions until it's reached: %v", err)
+	}
+	apiReachable.Set(0)
+	apiDisconnectedSeconds.Set(now.Sub(r.since).Seconds())
+}
+
+// apiUnreachable returns true while api server is unreachable
+func (e *evictionManager) apiUnreachable() bool {
+	e.reachability.lock.Lock()
+	defer e.reachability.lock.Unlock()
+	return !e.reachability.since.IsZero()
+}
+
+// queueAction queues run of key for replay if err is of api server
+// unreachable, replacing the one queued of key
+func (e *evictionManager) queueAction(key string, err error, run func() error) {
+	if !evictionclient.IsUnreachable(err) {
+		return
+	}
+	r := &e.reachability
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for i, action := range r.pending {
+		if action.key == key {
+			r.pending = append(r.pending[:i], r.pending[i+1:]...)
+			break
+		}
+	}
+	r.pending = append(r.pending, pendingAction{key: key, run: run})
+	log.Infof("queue %s for replay once api server is reached", key)
+}
@@ -0,0 +1,73 @@
+package evictionmanager
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/types"
+)
+
+// rebalanceHints annotates node with resources tainted for rebalanceDelay,
+// so that descheduler policies and cluster autoscaler can move pods away
+// instead of the agent evicting them
+type rebalanceHints struct {
+	delay time.Duration
+	// since is the time each condition is tainted from
+	since map[string]time.Time
+	// last is the pressured resources annotated, nil before the first update
+	last *string
+}
+
+func newRebalanceHints(delay time.Duration) *rebalanceHints {
+	return &rebalanceHints{
+		delay: delay,
+		since: make(map[string]time.Time),
+	}
+}
+
+// updateRebalanceHints annotates node if the resources under sustained
+// pressure are changed, annotations are removed if there are none
+func (e *evictionManager) updateRebalanceHints() {
+	h := e.rebalanceHints
+	if h == nil {
+		return
+	}
+	now := e.clock.Now()
+	var pressured []string
+	for _, controller := range e.controllers {
+		if controller.phase != PhaseTainted && controller.phase != PhaseEvicting {
+			delete(h.since, controller.name)
+			continue
+		}
+		since, ok := h.since[controller.name]
+		if !ok {
+			since = now
+			h.since[controller.name] = now
+		}
+		if now.Sub(since) >= h.delay {
+			pressured = append(pressured, controller.taintKey)
+		}
+	}
+
+	value := strings.Join(pressured, ",")
+	if h.last != nil && *h.last == value {
+		return
+	}
+	needsRebalance := ""
+	if value != "" {
+		needsRebalance = "true"
+	}
+	err := e.client.AnnotateNode(map[string]string{
+		types.NeedsRebalanceAnnotation:     needsRebalance,
+		types.PressuredResourcesAnnotation: value,
+	})
+	if err != nil {
+		log.Errorf("annotate node with rebalance hints error: %v", err)
+		e.status.recordError(fmt.Sprintf("annotate node with rebalance hints error: %v", err))
+		return
+	}
+	log.Infow("rebalance hints updated", "pressured", value)
+	h.last = &value
+}
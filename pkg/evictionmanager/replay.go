@@ -0,0 +1,103 @@
+package evictionmanager
+
+import (
+	"fmt"
+	"time"
+
+	"eviction-agent/pkg/condition"
+	"eviction-agent/pkg/config"
+	"eviction-agent/pkg/types"
+)
+
+// Actions of replay events
+const (
+	ReplayTaint   = "Taint"
+	ReplayUntaint = "Untaint"
+	ReplayEvict   = "Evict"
+	ReplayLabel   = "Label"
+)
+
+// ReplayEvent is what would have occurred at a record
+type ReplayEvent struct {
+	Time time.Time `json:"time"`
+	// Action is Taint, Untaint, Evict, or Label if the chosen pod would only be labeled
+	Action string `json:"action"`
+	Taint  string `json:"taint"`
+	// EvictType is the eviction request of Evict and Label, e.g. NetworkRxBusy
+	EvictType string `json:"evictType,omitempty"`
+	// Pod is namespace/name of the chosen pod of Evict and Label
+	Pod string `json:"pod,omitempty"`
+	// Message is the measured values of Taint, or the explanation of Evict and Label
+	Message string `json:"message"`
+}
+
+// Replay replays records of --record with policy offline, and reports taints
+// and evictions which would have occurred. Taints are removed after untaint
+// grace period of policy, the chosen pod of each eviction request is reported
+// when it changes. Only conditions of node stats are replayed.
+func Replay(policy *config.PolicyConfig, records []condition.Record, report func(ReplayEvent)) error {
+	if len(records) == 0 {
+		return fmt.Errorf("there are no records")
+	}
+	replayer, err := condition.NewReplayer(policy, records[0].Totals)
+	if err != nil {
+		return err
+	}
+	// time since which each tainted condition is available, zero while it's busy
+	tainted := make(map[string]time.Time)
+	chosen := make(map[string]string)
+	for i := range records {
+		record := &records[i]
+		nodeCondition := replayer.Add(record)
+		if nodeCondition == nil {
+			continue
+		}
+		for _, d := range conditionDescriptors {
+			busy := d.busy(nodeCondition)
+			since, isTainted := tainted[d.taintKey]
+			if len(busy) == 0 {
+				switch {
+				case !isTainted:
+				case since.IsZero():
+					tainted[d.taintKey] = record.Time
+				case record.Time.Sub(since) >= replayer.UntaintGracePeriod():
+					delete(tainted, d.taintKey)
+					for _, evictType := range d.evictTypes {
+						delete(chosen, evictType)
+					}
+					report(ReplayEvent{Time: record.Time, Action: ReplayUntaint, Taint: d.taintKey,
+						Message: conditionMessage(d.taintKey, nodeCondition)})
+				}
+				continue
+			}
+			if !isTainted {
+				report(ReplayEvent{Time: record.Time, Action: ReplayTaint, Taint: d.taintKey,
+					Message: conditionMessage(d.taintKey, nodeCondition)})
+			}
+			tainted[d.taintKey] = time.Time{}
+			if d.taintOnly {
+				continue
+			}
+			for _, evictType := range busy {
+				decision := Decision{Condition: evictType}
+				decision.setRanking(replayer.Rank(evictType, record))
+				if len(decision.Candidates) == 0 {
+					continue
+				}
+				candidate := decision.Candidates[0]
+				pod := candidate.Pod.Namespace + "/" + candidate.Pod.Name
+				if chosen[evictType] == pod {
+					continue
+				}
+				chosen[evictType] = pod
+				action := ReplayLabel
+				if replayer.AutoEvict() && candidate.Label == types.NeedEvict {
+					action = ReplayEvict
+				}
+				report(ReplayEvent{Time: record.Time, Action: action, Taint: d.taintKey, EvictType: evictType,
+					Pod: pod, Message: decision.explain()})
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package evictionmanager
+
+import (
+	"eviction-agent/pkg/condition"
+	"eviction-agent/pkg/types"
+)
+
+// criticalExcess is the excess over threshold by ratio of threshold from
+// which requests are critical if conditions are not graded by policy
+const criticalExcess = 0.1
+
+// requestResources are the resources of evict types of resources
+var requestResources = map[string]string{
+	types.CPUBusy:            "cpu",
+	types.MemBusy:            "memory",
+	types.DiskIO:             "disk",
+	types.NetworkRxBusy:      "network",
+	types.NetworkTxBusy:      "network",
+	types.WritebackBusy:      "memory",
+	types.EphemeralPortsBusy: "ephemeral-ports",
+}
+
+// newEvictionRequest describes the eviction request of evictType by the
+// measurements of node. Requests of node problems and drains are critical.
+func newEvictionRequest(evictType string, nodeCondition *condition.NodeCondition) types.Condition {
+	request := types.Condition{Type: evictType, Severity: types.SeverityCritical}
+	resourceType := evictType
+	if bandEvictType, _, ok := condition.ParseBandEvictType(evictType); ok {
+		resourceType = bandEvictType
+	}
+	request.Resource = requestResources[resourceType]
+	for resource := range nodeCondition.Extended {
+		if types.ExtendedResourceTaintKey(resource) == resourceType {
+			request.Resource = resource
+		}
+	}
+	if request.Resource == "" {
+		return request
+	}
+	switch resourceType {
+	case types.DiskIO:
+		if len(nodeCondition.DiskDevices) != 0 {
+			request.Devices = append([]string(nil), nodeCondition.DiskDevices...)
+		} else if nodeCondition.DiskDevice != "" {
+			request.Devices = []string{nodeCondition.DiskDevice}
+		}
+	case types.NetworkRxBusy, types.NetworkTxBusy:
+		request.Devices = append([]string(nil), nodeCondition.NetworkInterfaces...)
+	}
+	request.Severity = types.SeverityWarning
+	value, threshold, ok := nodeCondition.Measured(evictType)
+	if !ok {
+		return request
+	}
+	request.Value, request.Threshold = value, threshold
+	if severity, ok := nodeCondition.Severities[evictType]; ok {
+		request.Severity = severity
+	} else if nodeCondition.Severities == nil && value-threshold >= criticalExcess*threshold {
+		request.Severity = types.SeverityCritical
+	}
+	return request
+}
@@ -0,0 +1,131 @@
+package evictionmanager
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/tracing"
+	"eviction-agent/pkg/types"
+)
+
+// actions taken instead of evicting pods using far more than their requests
+const (
+	// ResizeActionResize patches requests of pod in place, it needs InPlacePodVerticalScaling
+	ResizeActionResize = "Resize"
+	// ResizeActionRecommend only records an event with the recommended requests
+	ResizeActionRecommend = "Recommend"
+)
+
+// resizeHeadroom is the recommended requests over usage
+const resizeHeadroom = 1.2
+
+// resizeInstead resizes pod, or recommends its requests, instead of evicting
+// it if pod uses more than resizeRatio times of its requests of the resource.
+// Only cpu and memory are resized. Returns false if pod should be evicted,
+// e.g. pod uses no more than its requests or resize fails.
+func (e *evictionManager) resizeInstead(ctx context.Context, evictType string, pod *types.PodInfo, decision *Decision) bool {
+	if e.resizeAction == "" || (evictType != types.CPUBusy && evictType != types.MemBusy) {
+		return false
+	}
+	if len(decision.Candidates) == 0 || decision.Candidates[0].Pod.Name != pod.Name ||
+		decision.Candidates[0].Pod.Namespace != pod.Namespace {
+		return false
+	}
+	usage := decision.Candidates[0].Usage
+	containers, err := e.client.GetPodResources(pod)
+	if err != nil {
+		return false
+	}
+	recommended, requests := recommendResources(evictType, containers, usage)
+	if requests == 0 || usage < e.resizeRatio*requests {
+		return false
+	}
+	message := fmt.Sprintf("Pod uses %.4g of %s, %.2g times of its requests, recommended requests: %s",
+		usage, resourceName(evictType), usage/requests, describeResources(evictType, recommended))
+
+	if e.resizeAction == ResizeActionRecommend {
+		decision.Action = ResizeActionRecommend
+		e.client.RecordPodEvent(pod, types.NormalEvent, types.ResizeRecommendedReason, decision.eventMessage(message))
+		log.Infow("resize recommended instead of eviction", "condition", evictType, "pod", decision.Pod,
+			"usage", usage, "requests", requests)
+		return true
+	}
+
+	apiCtx, span := tracing.StartClient(ctx, "api.resize")
+	err = e.client.WithContext(apiCtx).ResizePod(pod, recommended)
+	span.SetError(err)
+	span.End()
+	e.status.recordEviction(evictType, pod, ResizeActionResize, decision.snapshot(), err)
+	e.auditAction(ResizeActionResize, evictType, pod, e.podOwner(pod), decision.snapshot(), err)
+	if err != nil {
+		// e.g. InPlacePodVerticalScaling is disabled or resize is infeasible
+		log.Warnf("resize pod %s error, evict it instead: %v", decision.Pod, err)
+		return false
+	}
+	decision.Action = ResizeActionResize
+	e.notify(ResizeActionResize, evictType, pod, nil)
+	e.client.RecordPodEvent(pod, types.NormalEvent, types.PodResizedReason, decision.eventMessage(message))
+	log.Infow("pod resized instead of eviction", "condition", evictType, "pod", decision.Pod,
+		"usage", usage, "requests", requests)
+	return true
+}
+
+// recommendResources scales requests of the resource of evictType in proportion,
+// so that the sum of them is usage with headroom. Limits below the recommended
+// requests are raised to them. Returns the recommended resources and the sum
+// of requests, containers without requests are not changed.
+func recommendResources(evictType string, containers []types.ContainerResources, usage float64) ([]types.ContainerResources, float64) {
+	var requests float64
+	for _, c := range containers {
+		if evictType == types.CPUBusy {
+			requests += c.CPURequest
+		} else {
+			requests += c.MemoryRequest
+		}
+	}
+	if requests == 0 {
+		return nil, 0
+	}
+	scale := usage * resizeHeadroom / requests
+	var recommended []types.ContainerResources
+	for _, c := range containers {
+		r := types.ContainerResources{Name: c.Name}
+		if evictType == types.CPUBusy && c.CPURequest > 0 {
+			r.CPURequest = c.CPURequest * scale
+			if c.CPULimit != 0 && c.CPULimit < r.CPURequest {
+				r.CPULimit = r.CPURequest
+			}
+		} else if evictType == types.MemBusy && c.MemoryRequest > 0 {
+			r.MemoryRequest = c.MemoryRequest * scale
+			if c.MemoryLimit != 0 && c.MemoryLimit < r.MemoryRequest {
+				r.MemoryLimit = r.MemoryRequest
+			}
+		} else {
+			continue
+		}
+		recommended = append(recommended, r)
+	}
+	return recommended, requests
+}
+
+func resourceName(evictType string) string {
+	if evictType == types.CPUBusy {
+		return "cpu cores"
+	}
+	return "memory bytes"
+}
+
+// describeResources returns requests as container=value
+func describeResources(evictType string, containers []types.ContainerResources) string {
+	var values []string
+	for _, c := range containers {
+		if evictType == types.CPUBusy {
+			values = append(values, fmt.Sprintf("%s=%dm", c.Name, int64(c.CPURequest*1000)))
+		} else {
+			values = append(values, fmt.Sprintf("%s=%dMi", c.Name, int64(c.MemoryRequest)/(1024*1024)))
+		}
+	}
+	return strings.Join(values, ",")
+}
@@ -0,0 +1,50 @@
+package evictionmanager
+
+import (
+	"sync/atomic"
+	"time"
+
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/metrics"
+)
+
+var (
+	runtimeExemptions = metrics.NewCounterVec("eviction_agent_runtime_exemptions_total",
+		"Number of evictions not triggered while containers are created on node by condition.", "condition")
+)
+
+// runtimeExemption exempts io conditions from evictions for window after
+// containers are created on node. Image pulls and container creation of
+// rolling deployments trip io thresholds, but the offender is the container
+// runtime rather than a pod to evict.
+type runtimeExemption struct {
+	window time.Duration
+	// lastActivity is the last time containers are created, unix nano
+	lastActivity int64
+}
+
+// checkRuntime records containers being created on node, it's called by
+// taint process. The last activity is kept if it fails.
+func (e *evictionManager) checkRuntime() {
+	if e.runtime == nil {
+		return
+	}
+	containers, err := e.client.GetCreatingContainers()
+	if err != nil {
+		log.Errorf("get containers being created error: %v", err)
+		return
+	}
+	if len(containers) != 0 {
+		log.Debugf("containers are created on node: %v", containers)
+		atomic.StoreInt64(&e.runtime.lastActivity, e.clock.Now().UnixNano())
+	}
+}
+
+// runtimeExempted returns true if containers are created in the window
+func (e *evictionManager) runtimeExempted() bool {
+	if e.runtime == nil {
+		return false
+	}
+	last := atomic.LoadInt64(&e.runtime.lastActivity)
+	return last != 0 && e.clock.Since(time.Unix(0, last)) < e.runtime.window
+}
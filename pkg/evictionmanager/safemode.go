@@ -0,0 +1,156 @@
+package evictionmanager
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"eviction-agent/cmd/options"
+	"eviction-agent/pkg/evictionclient"
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/metrics"
+	"eviction-agent/pkg/types"
+)
+
+// safeModeAlertKey is the key of the alert of safe mode, taint keys never have spaces
+const safeModeAlertKey = "safe mode"
+
+var (
+	safeModeActive = metrics.NewGaugeVec("eviction_agent_safe_mode",
+		"1 while actions are stopped in safe mode after repeated failures of taints, evictions and labels.")
+	consecutiveFailures = metrics.NewGaugeVec("eviction_agent_consecutive_action_failures",
+		"Number of consecutive failures of taints, evictions and labels.")
+)
+
+// safeMode stops actions after consecutive failures of taints, evictions and
+// labels, e.g. RBAC revoked or a webhook denying evictions, instead of failing
+// the same way every cycle while operators take node as protected. Like pause,
+// decisions are recorded but no action is taken. Actions are attempted again
+// every retry period, the first one succeeding leaves safe mode.
+type safeMode struct {
+	threshold   int
+	retryPeriod time.Duration
+	lock        sync.Mutex
+	failures    int
+	lastErr     string
+	// since is when safe mode is entered, zero if it's not
+	since time.Time
+	// retryAt is when actions are attempted again
+	retryAt time.Time
+	// stopped is 1 while actions are stopped, set by taint process
+	stopped int32
+}
+
+func newSafeMode(eao *options.EvictionAgentOptions) *safeMode {
+	if eao.SafeModeFailures <= 0 {
+		return nil
+	}
+	return &safeMode{threshold: eao.SafeModeFailures, retryPeriod: eao.SafeModeRetryPeriod}
+}
+
+// recordAction counts the result of an action, errors of api server
+// unreachable and evictions blocked by PDBs are not failures of agent
+func (e *evictionManager) recordAction(operation string, err error) {
+	s := e.safeMode
+	if s == nil || evictionclient.IsUnreachable(err) || evictionclient.IsBlockedByBudget(err) {
+		return
+	}
+	now := e.clock.Now()
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err == nil {
+		s.failures = 0
+		consecutiveFailures.Set(0)
+		if s.since.IsZero() {
+			return
+		}
+		message := fmt.Sprintf("%s succeeded, eviction agent leaves safe mode entered at %s and takes actions again",
+			operation, s.since.Format(time.RFC3339))
+		s.since = time.Time{}
+		log.Warnf("%s", message)
+		e.client.RecordNodeEvent(types.NormalEvent, types.SafeModeLeftReason, message)
+		if e.alerts != nil {
+			e.alerts.Resolve(safeModeAlertKey)
+		}
+		return
+	}
+	s.failures++
+	s.lastErr = fmt.Sprintf("%s: %v", operation, err)
+	consecutiveFailures.Set(float64(s.failures))
+	if !s.since.IsZero() {
+		// the attempt in safe mode failed, attempt again after another period
+		s.retryAt = now.Add(s.retryPeriod)
+		log.Warnf("%s failed in safe mode, attempt actions again at %s", operation, s.retryAt.Format(time.RFC3339))
+		return
+	}
+	if s.failures < s.threshold {
+		return
+	}
+	s.since, s.retryAt = now, now.Add(s.retryPeriod)
+	message := fmt.Sprintf("Eviction agent enters safe mode after %d consecutive failures of actions, the last is %s. "+
+		"Node is neither tainted nor untainted and pods are not evicted or labeled, actions are attempted again every %v",
+		s.failures, s.lastErr, s.retryPeriod)
+	log.Errorf("%s", message)
+	e.client.RecordNodeEvent(types.WarningEvent, types.SafeModeReason, message)
+	if e.alerts != nil {
+		e.alerts.Fire(safeModeAlertKey, map[string]string{
+			"alertname": types.SafeModeReason,
+			"node":      e.nodeName,
+			"severity":  "critical",
+		}, map[string]string{
+			"summary":     fmt.Sprintf("Eviction agent on node %s stops actions in safe mode", e.nodeName),
+			"description": message,
+		})
+	}
+}
+
+// checkSafeMode stops actions in safe mode until its retry time, controllers
+// take taints of node as the ones while actions are stopped. It's called by
+// taint process.
+func (e *evictionManager) checkSafeMode() {
+	s := e.safeMode
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	stopped := !s.since.IsZero() && e.clock.Now().Before(s.retryAt)
+	attempting := !s.since.IsZero() && !stopped
+	s.lock.Unlock()
+	if stopped == e.inSafeMode() {
+		return
+	}
+	if stopped {
+		if !e.paused() && !e.inObservation() {
+			e.adoptObservedTaints()
+		}
+		atomic.StoreInt32(&s.stopped, 1)
+		safeModeActive.Set(1)
+		return
+	}
+	atomic.StoreInt32(&s.stopped, 0)
+	safeModeActive.Set(0)
+	if attempting {
+		log.Infof("Attempt actions again in safe mode")
+	}
+}
+
+// inSafeMode returns true while actions are stopped in safe mode
+func (e *evictionManager) inSafeMode() bool {
+	return e.safeMode != nil && atomic.LoadInt32(&e.safeMode.stopped) == 1
+}
+
+// safeModeCheck returns error since safe mode is entered until it's left
+func (e *evictionManager) safeModeCheck() error {
+	s := e.safeMode
+	if s == nil {
+		return nil
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.since.IsZero() {
+		return nil
+	}
+	return fmt.Errorf("actions are stopped in safe mode since %s after %d consecutive failures, the last is %s",
+		s.since.Format(time.RFC3339), s.failures, s.lastErr)
+}
@@ -0,0 +1,59 @@
+package evictionmanager
+
+import (
+	"context"
+	"fmt"
+
+	"eviction-agent/pkg/condition"
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/metrics"
+	"eviction-agent/pkg/types"
+)
+
+var (
+	severityDecisions = metrics.NewCounterVec("eviction_agent_severity_decisions_total",
+		"Number of eviction decisions of graded conditions by condition and severity.", "condition", "severity")
+)
+
+// gradedSeverity returns the severity of evict types graded by policy,
+// critical if any of them is critical. Returns empty if none of them is
+// graded, they are handled as before.
+func gradedSeverity(evictTypes []string, nodeCondition *condition.NodeCondition) types.Severity {
+	var severity types.Severity
+	for _, evictType := range evictTypes {
+		s, ok := nodeCondition.Severities[evictType]
+		if !ok {
+			continue
+		}
+		if s == types.SeverityCritical {
+			return s
+		}
+		severity = s
+	}
+	return severity
+}
+
+// softTaint returns true if node is tainted PreferNoSchedule instead of
+// NoSchedule by the condition of severity
+func (cc *conditionController) softTaint(severity types.Severity) bool {
+	return severity == types.SeverityWarning && types.TaintEffect(cc.taintKey) == "NoSchedule"
+}
+
+// hardenTaint taints node NoSchedule instead of PreferNoSchedule once the
+// condition is critical
+func (cc *conditionController) hardenTaint(ctx context.Context, e *evictionManager, nodeCondition *condition.NodeCondition) {
+	log.Infof("harden taint %s, the condition is critical", cc.taintKey)
+	if err := cc.setTaint(ctx, e, "Taint"); err != nil {
+		log.Errorf("harden taint %s error: %v", cc.taintKey, err)
+		e.status.recordError(fmt.Sprintf("harden taint %s error: %v", cc.taintKey, err))
+		return
+	}
+	cc.softTainted = false
+	if e.observing() {
+		return
+	}
+	message := fmt.Sprintf("Node is tainted NoSchedule with %s, the condition is critical, %s",
+		cc.taintKey, conditionMessage(cc.taintKey, nodeCondition))
+	log.Infof("%s", message)
+	e.client.RecordNodeEvent(types.NormalEvent, types.NodeTaintedReason, message)
+}
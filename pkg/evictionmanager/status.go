@@ -0,0 +1,203 @@
+package evictionmanager
+
+import (
+	"reflect"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+
+	"eviction-agent/pkg/apis/v1alpha1"
+	"eviction-agent/pkg/condition"
+	"eviction-agent/pkg/evictionclient"
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/types"
+)
+
+const (
+	// maxStatusRecords is the max number of evictions and errors kept in status
+	maxStatusRecords = 10
+	// maxStatusSnapshot is the max number of pods of snapshot of each eviction
+	// kept in status, the audit log keeps all of them
+	maxStatusSnapshot = 20
+	// statusHeartbeatPeriod is the max period between two status updates
+	statusHeartbeatPeriod = time.Minute
+)
+
+// statusReporter keeps the latest evictions and errors of agent, and
+// reports them with node conditions to NodeEvictionStatus of current node
+type statusReporter struct {
+	client     evictionclient.Client
+	nodeName   string
+	namespace  string
+	lock       sync.Mutex
+	evictions  []v1alpha1.EvictionRecord
+	errors     []v1alpha1.ErrorRecord
+	lastStatus *v1alpha1.AgentStatus
+	lastUpdate time.Time
+	clock      clock.Clock
+}
+
+func newStatusReporter(client evictionclient.Client, nodeName, namespace string, clk clock.Clock) *statusReporter {
+	return &statusReporter{
+		clock:     clk,
+		client:    client,
+		nodeName:  nodeName,
+		namespace: namespace,
+	}
+}
+
+// recordEviction keeps an eviction or label action with the snapshot of pods
+// it's chosen from
+func (r *statusReporter) recordEviction(evictType string, pod *types.PodInfo, action string,
+	snapshot []v1alpha1.PodUsage, err error) {
+	if len(snapshot) > maxStatusSnapshot {
+		snapshot = snapshot[:maxStatusSnapshot]
+	}
+	record := v1alpha1.EvictionRecord{
+		Time:      metav1.Now(),
+		Condition: evictType,
+		Pod:       pod.Namespace + "/" + pod.Name,
+		Action:    action,
+		Snapshot:  snapshot,
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.evictions = append(r.evictions, record)
+	if len(r.evictions) > maxStatusRecords {
+		r.evictions = r.evictions[1:]
+	}
+}
+
+// getEvictions returns the latest evictions
+func (r *statusReporter) getEvictions() []v1alpha1.EvictionRecord {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]v1alpha1.EvictionRecord{}, r.evictions...)
+}
+
+// recordError keeps an error message
+func (r *statusReporter) recordError(message string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.errors = append(r.errors, v1alpha1.ErrorRecord{
+		Time:    metav1.Now(),
+		Message: message,
+	})
+	if len(r.errors) > maxStatusRecords {
+		r.errors = r.errors[1:]
+	}
+}
+
+// report updates NodeEvictionStatus if the status is changed or
+// it has not been updated for statusHeartbeatPeriod
+func (r *statusReporter) report(nodeCondition *condition.NodeCondition, nodeTaint types.NodeTaintInfo, phases []PhaseStatus) {
+	if r.namespace == "" {
+		return
+	}
+
+	status := &v1alpha1.AgentStatus{
+		NodeName: r.nodeName,
+		Conditions: []v1alpha1.ConditionStatus{
+			{Type: types.CPUBusy, Available: nodeCondition.CPUAvailable,
+				Message: conditionMessage(types.CPUBusy, nodeCondition)},
+			{Type: types.MemBusy, Available: nodeCondition.MemoryAvailable,
+				Message: conditionMessage(types.MemBusy, nodeCondition)},
+			{Type: types.DiskIO, Available: nodeCondition.DiskIOAvailable,
+				Message: conditionMessage(types.DiskIO, nodeCondition)},
+			{Type: types.NetworkIO, Available: nodeCondition.NetworkRxAvailable && nodeCondition.NetworkTxAvailable,
+				Message: conditionMessage(types.NetworkIO, nodeCondition)},
+		},
+		Taints: []string{},
+	}
+	if w := nodeCondition.Writeback; w != nil {
+		status.Conditions = append(status.Conditions, v1alpha1.ConditionStatus{Type: types.WritebackBusy,
+			Available: !w.Busy, Message: conditionMessage(types.WritebackBusy, nodeCondition)})
+	}
+	if p := nodeCondition.EphemeralPorts; p != nil {
+		status.Conditions = append(status.Conditions, v1alpha1.ConditionStatus{Type: types.EphemeralPortsBusy,
+			Available: !p.Busy, Message: conditionMessage(types.EphemeralPortsBusy, nodeCondition)})
+	}
+	for i := range status.Conditions {
+		for _, phase := range phases {
+			if phase.TaintKey == status.Conditions[i].Type {
+				status.Conditions[i].Phase = string(phase.Phase)
+				status.Conditions[i].Transitions = transitionRecords(phase.Transitions)
+			}
+		}
+	}
+	// extended resources and node problems follow conditions of resources in phases
+	for _, phase := range phases[len(conditionDescriptors):] {
+		available := !nodeCondition.Problems[phase.Condition]
+		if r, ok := nodeCondition.Extended[phase.Condition]; ok {
+			available = r.Available
+		}
+		status.Conditions = append(status.Conditions, v1alpha1.ConditionStatus{
+			Type:        phase.TaintKey,
+			Available:   available,
+			Message:     conditionMessage(phase.TaintKey, nodeCondition),
+			Phase:       string(phase.Phase),
+			Transitions: transitionRecords(phase.Transitions),
+		})
+		if nodeTaint.Taints[phase.TaintKey] {
+			status.Taints = append(status.Taints, phase.TaintKey)
+		}
+	}
+	if nodeTaint.CPU {
+		status.Taints = append(status.Taints, types.CPUBusy)
+	}
+	if nodeTaint.Memory {
+		status.Taints = append(status.Taints, types.MemBusy)
+	}
+	if nodeTaint.DiskIO {
+		status.Taints = append(status.Taints, types.DiskIO)
+	}
+	if nodeTaint.NetworkIO {
+		status.Taints = append(status.Taints, types.NetworkIO)
+	}
+	if nodeTaint.Taints[types.WritebackBusy] {
+		status.Taints = append(status.Taints, types.WritebackBusy)
+	}
+	if nodeTaint.Taints[types.EphemeralPortsBusy] {
+		status.Taints = append(status.Taints, types.EphemeralPortsBusy)
+	}
+	r.lock.Lock()
+	status.LastEvictions = append([]v1alpha1.EvictionRecord{}, r.evictions...)
+	status.LastErrors = append([]v1alpha1.ErrorRecord{}, r.errors...)
+	r.lock.Unlock()
+
+	// compare without update time
+	if r.lastStatus != nil && r.clock.Since(r.lastUpdate) < statusHeartbeatPeriod {
+		status.UpdateTime = r.lastStatus.UpdateTime
+		if reflect.DeepEqual(status, r.lastStatus) {
+			return
+		}
+	}
+
+	status.UpdateTime = metav1.Now()
+	if err := r.client.UpdateNodeEvictionStatus(r.namespace, status); err != nil {
+		log.Errorf("update node eviction status error: %v", err)
+		return
+	}
+	r.lastStatus = status
+	r.lastUpdate = r.clock.Now()
+}
+
+// transitionRecords returns transitions of a condition in status
+func transitionRecords(transitions []TransitionRecord) []v1alpha1.TransitionRecord {
+	var records []v1alpha1.TransitionRecord
+	for _, t := range transitions {
+		records = append(records, v1alpha1.TransitionRecord{
+			From:     string(t.From),
+			To:       string(t.To),
+			Time:     metav1.NewTime(t.Time),
+			Duration: t.Duration.Round(time.Second).String(),
+			Peak:     t.Peak,
+		})
+	}
+	return records
+}
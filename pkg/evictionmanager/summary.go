@@ -0,0 +1,185 @@
+package evictionmanager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/types"
+)
+
+// maxSummarySources is the max number of top pressure sources of a summary
+const maxSummarySources = 5
+
+// ActivitySummary is the activity of agent in a period, reported as a node
+// event and annotation for clusters without Prometheus
+type ActivitySummary struct {
+	Since time.Time `json:"since"`
+	Until time.Time `json:"until"`
+	// Taints are the number of taints added by condition
+	Taints map[string]int `json:"taints,omitempty"`
+	// Actions are the number of succeeded actions by kind, e.g. Evict or Label
+	Actions map[string]int `json:"actions,omitempty"`
+	Failed  int            `json:"failed,omitempty"`
+	// Suppressed are the number of decisions without action by condition,
+	// e.g. skipped while kubelet is evicting, or observed
+	Suppressed map[string]int `json:"suppressed,omitempty"`
+	// TopSources are the pods chosen most often
+	TopSources []PressureSource `json:"topSources,omitempty"`
+}
+
+// PressureSource is a pod chosen by decisions of a condition
+type PressureSource struct {
+	Pod       string `json:"pod"`
+	Condition string `json:"condition"`
+	Decisions int    `json:"decisions"`
+}
+
+// activity counts taints and decisions since the last summary, it's nil-safe
+type activity struct {
+	period  time.Duration
+	lock    sync.Mutex
+	summary ActivitySummary
+	sources map[PressureSource]int // Decisions of keys are zero
+}
+
+func newActivity(period time.Duration, now time.Time) *activity {
+	if period <= 0 {
+		return nil
+	}
+	a := &activity{period: period}
+	a.reset(now)
+	return a
+}
+
+func (a *activity) reset(now time.Time) {
+	a.summary = ActivitySummary{
+		Since:      now,
+		Taints:     make(map[string]int),
+		Actions:    make(map[string]int),
+		Suppressed: make(map[string]int),
+	}
+	a.sources = make(map[PressureSource]int)
+}
+
+func (a *activity) recordTaint(taintKey string) {
+	if a == nil {
+		return
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.summary.Taints[taintKey]++
+}
+
+func (a *activity) recordDecision(decision *Decision) {
+	if a == nil {
+		return
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	switch decision.Result {
+	case "Succeeded":
+		// e.g. Evict, or Label of "Label NeedsEviction"
+		if kind := strings.Fields(decision.Action); len(kind) != 0 {
+			a.summary.Actions[kind[0]]++
+		}
+	case "Failed":
+		a.summary.Failed++
+	default:
+		a.summary.Suppressed[decision.Condition]++
+	}
+	if decision.Pod != "" {
+		a.sources[PressureSource{Pod: decision.Pod, Condition: decision.Condition}]++
+	}
+}
+
+// take returns the summary since the last one and starts a new one
+func (a *activity) take(now time.Time) ActivitySummary {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	summary := a.summary
+	summary.Until = now
+	for source, decisions := range a.sources {
+		source.Decisions = decisions
+		summary.TopSources = append(summary.TopSources, source)
+	}
+	sort.Slice(summary.TopSources, func(i, j int) bool {
+		a, b := summary.TopSources[i], summary.TopSources[j]
+		if a.Decisions != b.Decisions {
+			return a.Decisions > b.Decisions
+		}
+		return a.Pod+a.Condition < b.Pod+b.Condition
+	})
+	if len(summary.TopSources) > maxSummarySources {
+		summary.TopSources = summary.TopSources[:maxSummarySources]
+	}
+	a.reset(now)
+	return summary
+}
+
+// message returns a one-line summary, e.g. "Since 10:00:00: 1 taints (CPUBusy 1),
+// 2 actions (Evict 2), 0 failed, 3 suppressed (CPUBusy 3); top pressure sources:
+// default/a CPUBusy 4"
+func (s *ActivitySummary) message() string {
+	count := func(counts map[string]int) (int, string) {
+		var keys []string
+		total := 0
+		for key, n := range counts {
+			keys = append(keys, key)
+			total += n
+		}
+		sort.Strings(keys)
+		parts := make([]string, len(keys))
+		for i, key := range keys {
+			parts[i] = fmt.Sprintf("%s %d", key, counts[key])
+		}
+		if len(parts) == 0 {
+			return 0, ""
+		}
+		return total, " (" + strings.Join(parts, ", ") + ")"
+	}
+	taints, taintCounts := count(s.Taints)
+	actions, actionCounts := count(s.Actions)
+	suppressed, suppressedCounts := count(s.Suppressed)
+	message := fmt.Sprintf("Since %s: %d taints%s, %d actions%s, %d failed, %d suppressed%s",
+		s.Since.Format(time.RFC3339), taints, taintCounts, actions, actionCounts, s.Failed, suppressed, suppressedCounts)
+	if len(s.TopSources) != 0 {
+		sources := make([]string, len(s.TopSources))
+		for i, source := range s.TopSources {
+			sources[i] = fmt.Sprintf("%s %s %d", source.Pod, source.Condition, source.Decisions)
+		}
+		message += "; top pressure sources: " + strings.Join(sources, ", ")
+	}
+	return message
+}
+
+// reportActivity records a node event and annotates node with the summary of
+// activity every period
+func (e *evictionManager) reportActivity(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			log.Infof("Stop activity summary report")
+			return
+		case <-e.clock.After(e.activity.period):
+		}
+		summary := e.activity.take(e.clock.Now())
+		message := summary.message()
+		log.Infof("activity summary: %s", message)
+		e.client.RecordNodeEvent(types.NormalEvent, types.ActivitySummaryReason, message)
+		data, err := json.Marshal(&summary)
+		if err != nil {
+			log.Errorf("marshal activity summary error: %v", err)
+			continue
+		}
+		if err := e.client.AnnotateNode(map[string]string{types.ActivitySummaryAnnotation: string(data)}); err != nil {
+			log.Errorf("annotate node with activity summary error: %v", err)
+			e.status.recordError(fmt.Sprintf("annotate node with activity summary error: %v", err))
+		}
+	}
+}
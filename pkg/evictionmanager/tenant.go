@@ -0,0 +1,134 @@
+package evictionmanager
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/clock"
+
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/metrics"
+	"eviction-agent/pkg/types"
+)
+
+var (
+	tenantEvictions = metrics.NewCounterVec("eviction_agent_tenant_evictions_total",
+		"Number of pods evicted by tenant.", "tenant")
+	tenantBudgetSkips = metrics.NewCounterVec("eviction_agent_tenant_budget_skips_total",
+		"Number of evictions skipping pods of tenants out of eviction budget.", "tenant")
+)
+
+// tenantBudgets limits evictions of each tenant in a window, so that one
+// incident can't evict all pods of a tenant. Tenant of a namespace is the
+// value of its tenant label, namespaces without the label are not limited.
+type tenantBudgets struct {
+	label  string
+	limit  int
+	window time.Duration
+	clock  clock.Clock
+
+	lock      sync.Mutex
+	evictions map[string][]time.Time // by tenant, the oldest first
+}
+
+func newTenantBudgets(label string, limit int, window time.Duration, clk clock.Clock) *tenantBudgets {
+	return &tenantBudgets{
+		label:     label,
+		limit:     limit,
+		window:    window,
+		clock:     clk,
+		evictions: make(map[string][]time.Time),
+	}
+}
+
+// record adds an eviction of tenant, returns true if the tenant runs out of budget by it
+func (b *tenantBudgets) record(tenant string) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.prune()
+	b.evictions[tenant] = append(b.evictions[tenant], b.clock.Now())
+	return len(b.evictions[tenant]) == b.limit
+}
+
+// exhausted returns tenants out of budget in the current window
+func (b *tenantBudgets) exhausted() map[string]bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.prune()
+	tenants := make(map[string]bool)
+	for tenant, times := range b.evictions {
+		if len(times) >= b.limit {
+			tenants[tenant] = true
+		}
+	}
+	return tenants
+}
+
+// prune drops evictions out of window, lock must be held
+func (b *tenantBudgets) prune() {
+	start := b.clock.Now().Add(-b.window)
+	for tenant, times := range b.evictions {
+		i := 0
+		for i < len(times) && times[i].Before(start) {
+			i++
+		}
+		if i == len(times) {
+			delete(b.evictions, tenant)
+		} else {
+			b.evictions[tenant] = times[i:]
+		}
+	}
+}
+
+// skipExhaustedTenants makes condition manager skip namespaces of tenants out
+// of budget, candidates of other tenants are chosen instead
+func (e *evictionManager) skipExhaustedTenants() error {
+	if e.tenants == nil {
+		return nil
+	}
+	exhausted := e.tenants.exhausted()
+	if len(exhausted) == 0 {
+		e.conditionManager.SetSkippedNamespaces(nil)
+		return nil
+	}
+	namespaces, err := e.client.GetTenantNamespaces(e.tenants.label)
+	if err != nil {
+		return fmt.Errorf("get namespaces of tenants: %v", err)
+	}
+	skipped := make(map[string]bool)
+	for namespace, tenant := range namespaces {
+		if exhausted[tenant] {
+			skipped[namespace] = true
+		}
+	}
+	for tenant := range exhausted {
+		tenantBudgetSkips.Inc(tenant)
+	}
+	e.conditionManager.SetSkippedNamespaces(skipped)
+	return nil
+}
+
+// recordTenantEviction counts an eviction of pod against budget of its
+// tenant, and records a node event once the tenant is out of budget
+func (e *evictionManager) recordTenantEviction(pod *types.PodInfo) {
+	if e.tenants == nil {
+		return
+	}
+	namespaces, err := e.client.GetTenantNamespaces(e.tenants.label)
+	if err != nil {
+		log.Errorf("get tenant of pod %s/%s error: %v", pod.Namespace, pod.Name, err)
+		return
+	}
+	tenant, ok := namespaces[pod.Namespace]
+	if !ok {
+		return
+	}
+	tenantEvictions.Inc(tenant)
+	if e.tenants.record(tenant) {
+		message := fmt.Sprintf("Tenant %s has %d evictions in %v, its pods are skipped until the window passes",
+			tenant, e.tenants.limit, e.tenants.window)
+		log.Warnw("tenant eviction budget exhausted", "tenant", tenant, "pod", pod.Namespace+"/"+pod.Name)
+		e.client.RecordNodeEvent(types.WarningEvent, types.TenantBudgetExhaustedReason, message)
+	}
+}
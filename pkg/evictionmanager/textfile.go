@@ -0,0 +1,104 @@
+package evictionmanager
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"eviction-agent/pkg/condition"
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/metrics"
+	"eviction-agent/pkg/types"
+)
+
+// textfileName is the file written into the directory of the textfile
+// collector, which reads files of *.prom only
+const textfileName = "eviction_agent.prom"
+
+// textfile writes conditions and taints of each cycle into the directory of
+// the textfile collector of node_exporter, so they're scraped with node
+// metrics where agent itself is not scraped
+type textfile struct {
+	dir string
+	// failing is true if the last write failed, errors are logged once until it succeeds
+	failing bool
+}
+
+func newTextfile(dir string) *textfile {
+	if dir == "" {
+		return nil
+	}
+	return &textfile{dir: dir}
+}
+
+// write replaces the textfile with conditions, taints and phases of the cycle,
+// the file is renamed into place so the collector never reads a partial one
+func (t *textfile) write(controllers []*conditionController, nodeCondition *condition.NodeCondition,
+	nodeTaint *types.NodeTaintInfo, statuses []PhaseStatus) {
+	available := metrics.NewLocalGaugeVec("eviction_agent_condition_available",
+		"Whether each condition is available, 1 for available and 0 for busy.", "condition")
+	value := metrics.NewLocalGaugeVec("eviction_agent_condition_value",
+		"Measured value of each evict type of the latest stats.", "condition", "type")
+	threshold := metrics.NewLocalGaugeVec("eviction_agent_condition_threshold",
+		"Threshold compared with the measured value of each evict type.", "condition", "type")
+	tainted := metrics.NewLocalGaugeVec("eviction_agent_node_tainted",
+		"Whether node is tainted by each condition, 1 for tainted.", "condition", "taint")
+	phase := metrics.NewLocalGaugeVec("eviction_agent_condition_phase",
+		"Current phase of each condition, 1 for the current phase and 0 for others.", "condition", "phase")
+	for _, controller := range controllers {
+		available.Set(boolValue(len(controller.busy(nodeCondition)) == 0), controller.name)
+		tainted.Set(boolValue(controller.tainted(nodeTaint)), controller.name, controller.taintKey)
+		for _, evictType := range controller.evictTypes {
+			if v, limit, ok := nodeCondition.Measured(evictType); ok {
+				value.Set(v, controller.name, evictType)
+				threshold.Set(limit, controller.name, evictType)
+			}
+		}
+	}
+	for _, status := range statuses {
+		for _, p := range phases {
+			phase.Set(boolValue(status.Phase == p), status.Condition, string(p))
+		}
+	}
+	var buf bytes.Buffer
+	metrics.WriteGauges(&buf, available, value, threshold, tainted, phase)
+	if err := t.replace(buf.Bytes()); err != nil {
+		if !t.failing {
+			log.Errorf("write textfile of node_exporter into %s error: %v", t.dir, err)
+		}
+		t.failing = true
+		return
+	}
+	if t.failing {
+		log.Infof("write textfile of node_exporter into %s again", t.dir)
+	}
+	t.failing = false
+}
+
+func (t *textfile) replace(data []byte) error {
+	tmp, err := ioutil.TempFile(t.dir, "."+textfileName)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(t.dir, textfileName))
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,73 @@
+package evictionmanager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"eviction-agent/pkg/condition"
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/types"
+)
+
+// reportTopPods annotates node with the top pods by usage of each resource
+// every topPodsPeriod, even if no condition is busy. The annotation is
+// not updated if top pods are not changed.
+func (e *evictionManager) reportTopPods(ctx context.Context) {
+	var last string
+	for {
+		select {
+		case <-ctx.Done():
+			log.Infof("Stop top pods report")
+			return
+		case <-e.clock.After(e.topPodsPeriod):
+		}
+		top, err := e.GetTopPods()
+		if err != nil {
+			log.Infof("skip top pods report: %v", err)
+			continue
+		}
+		data, err := json.Marshal(roundTopPods(top))
+		if err != nil {
+			log.Errorf("marshal top pods error: %v", err)
+			continue
+		}
+		if string(data) == last {
+			continue
+		}
+		if err := e.client.AnnotateNode(map[string]string{types.TopPodsAnnotation: string(data)}); err != nil {
+			log.Errorf("annotate node with top pods error: %v", err)
+			e.status.recordError(fmt.Sprintf("annotate node with top pods error: %v", err))
+			continue
+		}
+		last = string(data)
+	}
+}
+
+// GetTopPods returns the top pods by usage of each resource
+func (e *evictionManager) GetTopPods() (*condition.TopPods, error) {
+	return e.conditionManager.GetTopPods(e.topPodsCount)
+}
+
+// roundTopPods rounds usages to 3 significant digits, so that the
+// annotation is not updated for small changes
+func roundTopPods(top *condition.TopPods) *condition.TopPods {
+	round := func(usages []condition.PodUsage) []condition.PodUsage {
+		rounded := make([]condition.PodUsage, 0, len(usages))
+		for _, u := range usages {
+			if u.Usage > 0 {
+				scale := math.Pow(10, 2-math.Floor(math.Log10(u.Usage)))
+				u.Usage = math.Round(u.Usage*scale) / scale
+			}
+			rounded = append(rounded, u)
+		}
+		return rounded
+	}
+	return &condition.TopPods{
+		CPU:     round(top.CPU),
+		Memory:  round(top.Memory),
+		DiskIO:  round(top.DiskIO),
+		Network: round(top.Network),
+	}
+}
@@ -0,0 +1,60 @@
+package evictionmanager
+
+import (
+	"context"
+	"time"
+
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/metrics"
+	"eviction-agent/pkg/probes"
+)
+
+var (
+	untaintProbeResults = metrics.NewCounterVec("eviction_agent_untaint_probes_total",
+		"Number of probes run before untainting node by condition and result.", "condition", "result")
+	untaintProbeDuration = metrics.NewGaugeVec("eviction_agent_untaint_probe_seconds",
+		"Duration of the last probe run before untainting node in seconds.", "condition")
+)
+
+// untaintProbes verify conditions are genuinely healthy before untainting
+// node, not just idle because their load is evicted. A condition without a
+// probe is untainted after grace period.
+type untaintProbes struct {
+	probes  map[string]probes.Probe // keyed by condition
+	timeout time.Duration
+}
+
+// verifyUntaint runs the probe of condition, returns error if the condition
+// should be kept tainted
+func (e *evictionManager) verifyUntaint(ctx context.Context, condition string) error {
+	probe, ok := e.untaintProbes.probes[condition]
+	if !ok {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, e.untaintProbes.timeout)
+	defer cancel()
+	start := time.Now()
+	err := probe(ctx)
+	untaintProbeDuration.Set(time.Since(start).Seconds(), condition)
+	if err != nil {
+		untaintProbeResults.Inc(condition, "failed")
+		return err
+	}
+	untaintProbeResults.Inc(condition, "succeeded")
+	log.Infof("untaint probe of %s succeeded in %v", condition, time.Since(start))
+	return nil
+}
+
+// checkUntaintProbes warns of probes of conditions without controllers,
+// which are never run
+func (e *evictionManager) checkUntaintProbes() {
+	names := make(map[string]bool)
+	for _, controller := range e.controllers {
+		names[controller.name] = true
+	}
+	for condition := range e.untaintProbes.probes {
+		if !names[condition] {
+			log.Warnf("untaint probe of %s is never run, no such condition", condition)
+		}
+	}
+}
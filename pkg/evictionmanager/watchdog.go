@@ -0,0 +1,118 @@
+package evictionmanager
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"eviction-agent/cmd/options"
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/metrics"
+	"eviction-agent/pkg/types"
+)
+
+// loops checked by watchdog
+const (
+	statsLoop = "stats"
+	taintLoop = "taint"
+)
+
+var (
+	loopStalled = metrics.NewGaugeVec("eviction_agent_loop_stalled",
+		"1 while loop completes no cycle in --watchdog-periods of its period, by loop of stats or taint.", "loop")
+	loopCycleAge = metrics.NewGaugeVec("eviction_agent_loop_cycle_age_seconds",
+		"Seconds since loop completed or started its last cycle, by loop of stats or taint.", "loop")
+	loopRestarts = metrics.NewCounterVec("eviction_agent_loop_restarts_total",
+		"Number of restarts of stalled loops by watchdog.", "loop")
+)
+
+// watchdog checks stats sync and taint loop complete their cycles, a hung
+// read of /proc or a deadlock freezes remediation silently. Agent is not
+// ready while any of them is stalled, stalled stats sync is restarted if
+// restart is true.
+type watchdog struct {
+	periods int
+	restart bool
+	lock    sync.Mutex
+	stalled map[string]string // why loops are stalled, protected by lock
+}
+
+func newWatchdog(eao *options.EvictionAgentOptions) *watchdog {
+	if eao.WatchdogPeriods <= 0 {
+		return nil
+	}
+	return &watchdog{
+		periods: eao.WatchdogPeriods,
+		restart: eao.WatchdogRestart,
+		stalled: make(map[string]string),
+	}
+}
+
+// runWatchdog checks loops every tick period until ctx is done
+func (e *evictionManager) runWatchdog(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			log.Infof("Stop watchdog")
+			return
+		case <-e.clock.After(e.tickPeriod):
+		}
+		lastCycle, period := e.conditionManager.StatsCycle()
+		if e.checkLoop(statsLoop, lastCycle, period) && e.watchdog.restart {
+			log.Warnf("restart stalled stats sync")
+			loopRestarts.Inc(statsLoop)
+			e.conditionManager.RestartStatsSync()
+		}
+		e.checkLoop(taintLoop, time.Unix(0, atomic.LoadInt64(&e.lastTaintLoopTime)), e.tickPeriod)
+	}
+}
+
+// checkLoop returns true if loop completes no cycle since lastCycle in
+// watchdog periods of period, an event is recorded when it stalls or recovers
+func (e *evictionManager) checkLoop(loop string, lastCycle time.Time, period time.Duration) bool {
+	w := e.watchdog
+	age := e.clock.Since(lastCycle)
+	loopCycleAge.Set(age.Seconds(), loop)
+	stalled := age > time.Duration(w.periods)*period
+	w.lock.Lock()
+	_, wasStalled := w.stalled[loop]
+	if stalled {
+		w.stalled[loop] = fmt.Sprintf("%s loop completes no cycle for %v, period %v", loop, age.Round(time.Second), period)
+	} else {
+		delete(w.stalled, loop)
+	}
+	message := w.stalled[loop]
+	w.lock.Unlock()
+	if stalled == wasStalled {
+		return stalled
+	}
+	if stalled {
+		loopStalled.Set(1, loop)
+		log.Errorf("%s", message)
+		e.client.RecordNodeEvent(types.WarningEvent, types.LoopStalledReason, message)
+		return true
+	}
+	loopStalled.Set(0, loop)
+	message = fmt.Sprintf("%s loop completes its cycles again", loop)
+	log.Infof("%s", message)
+	e.client.RecordNodeEvent(types.NormalEvent, types.LoopRecoveredReason, message)
+	return false
+}
+
+// watchdogCheck returns error while any loop is stalled
+func (e *evictionManager) watchdogCheck() error {
+	if e.watchdog == nil {
+		return nil
+	}
+	w := e.watchdog
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	for _, loop := range []string{statsLoop, taintLoop} {
+		if message, ok := w.stalled[loop]; ok {
+			return fmt.Errorf("%s", message)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package links
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/metrics"
+)
+
+const (
+	// sysClassNet has link state of interfaces, of host network with hostNetwork
+	sysClassNet = "/sys/class/net"
+	// procNetBonding has status of bond members by bond
+	procNetBonding = "/proc/net/bonding"
+)
+
+var (
+	linkDegraded = metrics.NewGaugeVec("eviction_agent_link_degraded",
+		"1 if the network interface is down, flapping or has a bond member failed.", "interface")
+	checkErrors = metrics.NewCounterVec("eviction_agent_link_check_errors_total",
+		"Number of checks of network interfaces failed to read their link state.", "interface")
+)
+
+// Monitor checks link state of network interfaces of host periodically. An
+// interface is degraded if it's not up, its carrier changes more than flaps
+// times in a period, or a member of it as a bond is down or fails since the
+// last check. Carrier changes are counted by kernel, so flaps between checks
+// are not missed.
+type Monitor struct {
+	interfaces []string
+	period     time.Duration
+	// flaps is the max carrier changes of an interface in a period
+	flaps int64
+	// carrierChanges are carrier_changes of interfaces of the last check
+	carrierChanges map[string]int64
+	// memberFailures are link failure counts of bond members of the last check,
+	// keyed by bond/member
+	memberFailures map[string]int64
+}
+
+// NewMonitor creates monitor of interfaces, e.g. eth0 and bond0
+func NewMonitor(interfaces []string, period time.Duration, flaps int) *Monitor {
+	return &Monitor{
+		interfaces:     interfaces,
+		period:         period,
+		flaps:          int64(flaps),
+		carrierChanges: make(map[string]int64),
+		memberFailures: make(map[string]int64),
+	}
+}
+
+// Run checks interfaces until ctx is done, report is called after each check
+// with the failures of interfaces, empty if all of them are healthy.
+// Interfaces which can't be checked are neither healthy nor degraded, report
+// is skipped if all of them can't be checked.
+func (m *Monitor) Run(ctx context.Context, report func(failures []string)) {
+	for {
+		if failures, ok := m.check(); ok {
+			report(failures)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(m.period):
+		}
+	}
+}
+
+func (m *Monitor) check() ([]string, bool) {
+	var failures []string
+	checked := false
+	for _, iface := range m.interfaces {
+		ifaceFailures, err := m.checkInterface(iface)
+		if err != nil {
+			checkErrors.Inc(iface)
+			log.Errorf("check network interface %s error: %v", iface, err)
+			continue
+		}
+		checked = true
+		if len(ifaceFailures) != 0 {
+			linkDegraded.Set(1, iface)
+			failures = append(failures, ifaceFailures...)
+			log.Warnw("network link degraded", "interface", iface, "failures", strings.Join(ifaceFailures, "; "))
+		} else {
+			linkDegraded.Set(0, iface)
+		}
+	}
+	return failures, checked
+}
+
+// checkInterface returns the failures of iface, empty if it's healthy
+func (m *Monitor) checkInterface(iface string) ([]string, error) {
+	dir := filepath.Join(sysClassNet, iface)
+	state, err := readString(filepath.Join(dir, "operstate"))
+	if err != nil {
+		return nil, err
+	}
+	var failures []string
+	// operstate of interfaces without carrier detection, e.g. of some virtual ones, is unknown
+	if state != "up" && state != "unknown" {
+		failures = append(failures, fmt.Sprintf("%s is %s", iface, state))
+	}
+	// carrier_changes is missing before kernel 3.15
+	if changes, err := readInt(filepath.Join(dir, "carrier_changes")); err == nil {
+		last, seen := m.carrierChanges[iface]
+		m.carrierChanges[iface] = changes
+		if seen && changes-last > m.flaps {
+			failures = append(failures, fmt.Sprintf("%s flapped %d times in %v", iface, changes-last, m.period))
+		}
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(procNetBonding, iface))
+	if os.IsNotExist(err) {
+		// not a bond
+		return failures, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, member := range parseBonding(data) {
+		key := iface + "/" + member.name
+		last, seen := m.memberFailures[key]
+		m.memberFailures[key] = member.failures
+		switch {
+		case member.status != "up":
+			failures = append(failures, fmt.Sprintf("%s member %s is %s", iface, member.name, member.status))
+		case seen && member.failures > last:
+			failures = append(failures, fmt.Sprintf("%s member %s failed %d times", iface, member.name, member.failures-last))
+		}
+	}
+	return failures, nil
+}
+
+// bondMember is the status of a member of a bond
+type bondMember struct {
+	name     string
+	status   string // MII status, up or down
+	failures int64  // link failure count
+}
+
+// parseBonding returns members of a bond of /proc/net/bonding, e.g.
+//
+//	Slave Interface: eth0
+//	MII Status: up
+//	Link Failure Count: 0
+func parseBonding(data []byte) []bondMember {
+	var members []bondMember
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		key, value := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		if key == "Slave Interface" {
+			members = append(members, bondMember{name: value})
+			continue
+		}
+		// status of the bond itself is before members
+		if len(members) == 0 {
+			continue
+		}
+		member := &members[len(members)-1]
+		switch key {
+		case "MII Status":
+			member.status = value
+		case "Link Failure Count":
+			member.failures, _ = strconv.ParseInt(value, 10, 64)
+		}
+	}
+	return members
+}
+
+func readString(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func readInt(path string) (int64, error) {
+	s, err := readString(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
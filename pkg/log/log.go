@@ -1,10 +1,13 @@
 package log
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"sync/atomic"
 
 	"github.com/elastic/beats/libbeat/logp"
+	"go.uber.org/zap"
 )
 
 var (
@@ -12,12 +15,22 @@ var (
 		logp.DebugLevel.String():    logp.DebugLevel,
 		logp.InfoLevel.String():     logp.InfoLevel,
 		logp.WarnLevel.String():     logp.WarnLevel,
+		"warn":                      logp.WarnLevel,
 		logp.ErrorLevel.String():    logp.ErrorLevel,
 		logp.CriticalLevel.String(): logp.CriticalLevel,
 	}
 
 	// DefaultLogger provides global logging motheds
 	DefaultLogger Logger = logp.NewLogger("nop")
+
+	// level is the logp.Level of global logging methods, it can be changed at runtime
+	level = int32(logp.InfoLevel)
+)
+
+// Formats of log
+const (
+	TextFormat = "text"
+	JSONFormat = "json"
 )
 
 // parseLevel parse string to logp.Level
@@ -28,8 +41,57 @@ func parseLevel(l string) logp.Level {
 	return logp.InfoLevel
 }
 
-// Config initialize logp package
-func Config(level, logPath string, toStderr bool, maxSize, maxBackups uint) {
+// ValidateLevel returns error if l is not a known level
+func ValidateLevel(l string) error {
+	if _, exist := levelMap[l]; !exist {
+		return fmt.Errorf("unknown log level %q, should be debug, info, warning or error", l)
+	}
+	return nil
+}
+
+// ValidateFormat returns error if f is not text or json
+func ValidateFormat(f string) error {
+	if f != TextFormat && f != JSONFormat {
+		return fmt.Errorf("unknown log format %q, should be %s or %s", f, TextFormat, JSONFormat)
+	}
+	return nil
+}
+
+// SetLevel changes the level of global logging methods at runtime
+func SetLevel(l string) error {
+	if err := ValidateLevel(l); err != nil {
+		return err
+	}
+	atomic.StoreInt32(&level, int32(parseLevel(l)))
+	return nil
+}
+
+// GetLevel returns the current level
+func GetLevel() string {
+	return logp.Level(atomic.LoadInt32(&level)).String()
+}
+
+// ShiftLevel lowers the level by n for more logs, or raises it if n is negative,
+// the level is kept between debug and error. Returns the new level.
+func ShiftLevel(n int) string {
+	l := logp.Level(atomic.LoadInt32(&level)) - logp.Level(n)
+	if l < logp.DebugLevel {
+		l = logp.DebugLevel
+	}
+	if l > logp.ErrorLevel {
+		l = logp.ErrorLevel
+	}
+	atomic.StoreInt32(&level, int32(l))
+	return l.String()
+}
+
+func enabled(l logp.Level) bool {
+	return logp.Level(atomic.LoadInt32(&level)).Enabled(l)
+}
+
+// Config initialize logp package. Logs are written in format, fields are key
+// value pairs added to all logs, e.g. "node", nodeName.
+func Config(level, format, logPath string, toStderr bool, maxSize, maxBackups uint, fields ...interface{}) {
 	wd, err := os.Getwd()
 	if err != nil {
 		panic(err)
@@ -37,9 +99,16 @@ func Config(level, logPath string, toStderr bool, maxSize, maxBackups uint) {
 	if logPath == "" {
 		logPath = filepath.Join(wd, "logs")
 	}
-	l := parseLevel(level)
+	if err := SetLevel(level); err != nil {
+		panic(err)
+	}
+	if err := ValidateFormat(format); err != nil {
+		panic(err)
+	}
 	config := logp.DefaultConfig()
-	config.Level = l
+	// logp logs everything, the level is checked by global logging methods
+	config.Level = logp.DebugLevel
+	config.JSON = format == JSONFormat
 	config.ToStderr = toStderr
 	config.Files.Name = "eviction-agent.log"
 	config.Files.Path = logPath
@@ -49,7 +118,8 @@ func Config(level, logPath string, toStderr bool, maxSize, maxBackups uint) {
 		panic(err)
 	}
 
-	DefaultLogger = logp.NewLogger("eviction-agent")
+	// skip global logging methods, so that the caller of them is logged
+	DefaultLogger = logp.NewLogger("eviction-agent", zap.AddCallerSkip(1)).With(fields...)
 }
 
 // Fatal calls the same method of DefaultLogger
@@ -74,40 +144,84 @@ func Panicf(format string, args ...interface{}) {
 
 // Debug calls the same method of DefaultLogger
 func Debug(args ...interface{}) {
-	DefaultLogger.Debug(args...)
+	if enabled(logp.DebugLevel) {
+		DefaultLogger.Debug(args...)
+	}
 }
 
 // Debugf calls the same method of DefaultLogger
 func Debugf(format string, args ...interface{}) {
-	DefaultLogger.Debugf(format, args...)
+	if enabled(logp.DebugLevel) {
+		DefaultLogger.Debugf(format, args...)
+	}
 }
 
 // Error calls the same method of DefaultLogger
 func Error(args ...interface{}) {
-	DefaultLogger.Error(args...)
+	if enabled(logp.ErrorLevel) {
+		DefaultLogger.Error(args...)
+	}
 }
 
 // Errorf calls the same method of DefaultLogger
 func Errorf(format string, args ...interface{}) {
-	DefaultLogger.Errorf(format, args...)
+	if enabled(logp.ErrorLevel) {
+		DefaultLogger.Errorf(format, args...)
+	}
 }
 
 // Info calls the same method of DefaultLogger
 func Info(args ...interface{}) {
-	DefaultLogger.Info(args...)
+	if enabled(logp.InfoLevel) {
+		DefaultLogger.Info(args...)
+	}
 }
 
 // Infof calls the same method of DefaultLogger
 func Infof(format string, args ...interface{}) {
-	DefaultLogger.Infof(format, args...)
+	if enabled(logp.InfoLevel) {
+		DefaultLogger.Infof(format, args...)
+	}
 }
 
 // Warn calls the same method of DefaultLogger
 func Warn(args ...interface{}) {
-	DefaultLogger.Warn(args...)
+	if enabled(logp.WarnLevel) {
+		DefaultLogger.Warn(args...)
+	}
 }
 
 // Warnf calls the same method of DefaultLogger
 func Warnf(format string, args ...interface{}) {
-	DefaultLogger.Warnf(format, args...)
+	if enabled(logp.WarnLevel) {
+		DefaultLogger.Warnf(format, args...)
+	}
+}
+
+// Debugw logs msg with structured key value pairs, e.g. "condition", "CPU"
+func Debugw(msg string, keysAndValues ...interface{}) {
+	if enabled(logp.DebugLevel) {
+		DefaultLogger.Debugw(msg, keysAndValues...)
+	}
+}
+
+// Infow logs msg with structured key value pairs, e.g. "condition", "CPU"
+func Infow(msg string, keysAndValues ...interface{}) {
+	if enabled(logp.InfoLevel) {
+		DefaultLogger.Infow(msg, keysAndValues...)
+	}
+}
+
+// Warnw logs msg with structured key value pairs, e.g. "condition", "CPU"
+func Warnw(msg string, keysAndValues ...interface{}) {
+	if enabled(logp.WarnLevel) {
+		DefaultLogger.Warnw(msg, keysAndValues...)
+	}
+}
+
+// Errorw logs msg with structured key value pairs, e.g. "condition", "CPU"
+func Errorw(msg string, keysAndValues ...interface{}) {
+	if enabled(logp.ErrorLevel) {
+		DefaultLogger.Errorw(msg, keysAndValues...)
+	}
 }
@@ -0,0 +1,179 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// metric is the interface of all registered metrics
+type metric interface {
+	write(w io.Writer)
+}
+
+var (
+	registryLock sync.Mutex
+	registry     = make(map[string]metric)
+)
+
+// register adds metric into global registry, panics on duplicated name
+func register(name string, m metric) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	if _, ok := registry[name]; ok {
+		panic(fmt.Errorf("metric %s is registered twice", name))
+	}
+	registry[name] = m
+}
+
+// WriteText writes all registered metrics in prometheus text format
+func WriteText(w io.Writer) {
+	registryLock.Lock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	registryLock.Unlock()
+	sort.Strings(names)
+
+	for _, name := range names {
+		registryLock.Lock()
+		m := registry[name]
+		registryLock.Unlock()
+		m.write(w)
+	}
+}
+
+// vec holds values of one metric keyed by label values
+type vec struct {
+	name       string
+	help       string
+	metricType string
+	labels     []string
+	lock       sync.Mutex
+	values     map[string]float64
+	labelPairs map[string][]string
+}
+
+func newVec(name, help, metricType string, labels []string) *vec {
+	return &vec{
+		name:       name,
+		help:       help,
+		metricType: metricType,
+		labels:     labels,
+		values:     make(map[string]float64),
+		labelPairs: make(map[string][]string),
+	}
+}
+
+func (v *vec) add(delta float64, labelValues []string) {
+	v.update(labelValues, func(old float64) float64 { return old + delta })
+}
+
+func (v *vec) set(value float64, labelValues []string) {
+	v.update(labelValues, func(float64) float64 { return value })
+}
+
+func (v *vec) update(labelValues []string, f func(float64) float64) {
+	if len(labelValues) != len(v.labels) {
+		panic(fmt.Errorf("metric %s expects %d label values, got %d", v.name, len(v.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	if _, ok := v.labelPairs[key]; !ok {
+		v.labelPairs[key] = append([]string(nil), labelValues...)
+	}
+	v.values[key] = f(v.values[key])
+}
+
+func (v *vec) write(w io.Writer) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n", v.name, v.help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", v.name, v.metricType)
+	keys := make([]string, 0, len(v.values))
+	for key := range v.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(w, "%s%s %v\n", v.name, v.formatLabels(v.labelPairs[key]), v.values[key])
+	}
+}
+
+func (v *vec) formatLabels(labelValues []string) string {
+	if len(v.labels) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(v.labels))
+	for i, label := range v.labels {
+		value := strings.Replace(labelValues[i], `\`, `\\`, -1)
+		value = strings.Replace(value, `"`, `\"`, -1)
+		value = strings.Replace(value, "\n", `\n`, -1)
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, label, value))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// CounterVec is a monotonically increasing metric partitioned by labels
+type CounterVec struct {
+	v *vec
+}
+
+// NewCounterVec creates and registers a counter
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{v: newVec(name, help, "counter", labels)}
+	register(name, c)
+	return c
+}
+
+// Inc increases the counter of the given label values by 1
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.v.add(1, labelValues)
+}
+
+// Add increases the counter of the given label values by delta, which must not be negative
+func (c *CounterVec) Add(delta float64, labelValues ...string) {
+	c.v.add(delta, labelValues)
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	c.v.write(w)
+}
+
+// GaugeVec is a metric which can go up and down, partitioned by labels
+type GaugeVec struct {
+	v *vec
+}
+
+// NewGaugeVec creates and registers a gauge
+func NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	g := &GaugeVec{v: newVec(name, help, "gauge", labels)}
+	register(name, g)
+	return g
+}
+
+// NewLocalGaugeVec creates a gauge which is not registered, it's written by
+// WriteGauges instead of WriteText, e.g. into a textfile of node_exporter
+func NewLocalGaugeVec(name, help string, labels ...string) *GaugeVec {
+	return &GaugeVec{v: newVec(name, help, "gauge", labels)}
+}
+
+// Set sets the gauge of the given label values
+func (g *GaugeVec) Set(value float64, labelValues ...string) {
+	g.v.set(value, labelValues)
+}
+
+// WriteGauges writes gauges in prometheus text format in the given order
+func WriteGauges(w io.Writer, gauges ...*GaugeVec) {
+	for _, g := range gauges {
+		g.write(w)
+	}
+}
+
+func (g *GaugeVec) write(w io.Writer) {
+	g.v.write(w)
+}
@@ -0,0 +1,187 @@
+package preemption
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/metrics"
+)
+
+// Cloud providers of preemption notices
+const (
+	// AWS is the interruption notice of EC2 spot instances
+	AWS = "aws"
+	// GCP is the preemption notice of preemptible and spot VMs of GCE
+	GCP = "gcp"
+)
+
+const (
+	awsEndpoint = "http://169.254.169.254"
+	gcpEndpoint = "http://metadata.google.internal"
+	// gcpNotice is the time between the preemption notice of GCE and termination
+	gcpNotice = 30 * time.Second
+	// requestTimeout is the timeout of each request of metadata server
+	requestTimeout = 2 * time.Second
+)
+
+var (
+	checkErrors = metrics.NewCounterVec("eviction_agent_preemption_check_errors_total",
+		"Number of checks of preemption notice failed by cloud provider.", "provider")
+)
+
+// Providers returns all cloud providers of preemption notices
+func Providers() []string {
+	return []string{AWS, GCP}
+}
+
+// Notice is a preemption notice of instance of node
+type Notice struct {
+	Provider string
+	// Action is what happens to instance, e.g. terminate, stop or hibernate
+	Action string
+	// Time is when instance is preempted
+	Time time.Time
+}
+
+// Monitor checks the preemption notice of the instance of node periodically
+// from the metadata server of cloud provider
+type Monitor struct {
+	provider string
+	endpoint string
+	period   time.Duration
+	client   *http.Client
+}
+
+// NewMonitor creates monitor of preemption notices of provider, which should
+// be one of Providers
+func NewMonitor(provider string, period time.Duration) *Monitor {
+	endpoint := awsEndpoint
+	if provider == GCP {
+		endpoint = gcpEndpoint
+	}
+	return &Monitor{
+		provider: provider,
+		endpoint: endpoint,
+		period:   period,
+		client:   &http.Client{Timeout: requestTimeout},
+	}
+}
+
+// Run checks preemption notice until ctx is done or a notice is found, report
+// is called once with the notice
+func (m *Monitor) Run(ctx context.Context, report func(notice *Notice)) {
+	log.Infof("Start preemption monitor of %s", m.provider)
+	for {
+		notice, err := m.check()
+		if err != nil {
+			checkErrors.Inc(m.provider)
+			log.Errorf("check preemption notice of %s error: %v", m.provider, err)
+		} else if notice != nil {
+			report(notice)
+			return
+		}
+		select {
+		case <-ctx.Done():
+			log.Infof("Stop preemption monitor")
+			return
+		case <-time.After(m.period):
+		}
+	}
+}
+
+// check returns the notice, nil if instance is not preempted
+func (m *Monitor) check() (*Notice, error) {
+	if m.provider == GCP {
+		return m.checkGCP()
+	}
+	return m.checkAWS()
+}
+
+// checkAWS gets spot/instance-action, which is not found until the
+// interruption notice two minutes before. A token of IMDSv2 is used if it can
+// be got, or else IMDSv1.
+func (m *Monitor) checkAWS() (*Notice, error) {
+	req, err := http.NewRequest(http.MethodGet, m.endpoint+"/latest/meta-data/spot/instance-action", nil)
+	if err != nil {
+		return nil, err
+	}
+	if token, err := m.awsToken(); err == nil {
+		req.Header.Set("X-aws-ec2-metadata-token", token)
+	} else {
+		log.Debugf("get IMDSv2 token error, use IMDSv1: %v", err)
+	}
+	body, status, err := m.do(req)
+	if err != nil {
+		return nil, err
+	}
+	if status == http.StatusNotFound {
+		return nil, nil
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("spot/instance-action responds %d", status)
+	}
+	var action struct {
+		Action string    `json:"action"`
+		Time   time.Time `json:"time"`
+	}
+	if err := json.Unmarshal(body, &action); err != nil {
+		return nil, fmt.Errorf("decode spot/instance-action %q: %v", body, err)
+	}
+	return &Notice{Provider: AWS, Action: action.Action, Time: action.Time}, nil
+}
+
+func (m *Monitor) awsToken() (string, error) {
+	req, err := http.NewRequest(http.MethodPut, m.endpoint+"/latest/api/token", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "60")
+	body, status, err := m.do(req)
+	if err != nil {
+		return "", err
+	}
+	if status != http.StatusOK {
+		return "", fmt.Errorf("api/token responds %d", status)
+	}
+	return string(body), nil
+}
+
+// checkGCP gets instance/preempted, which is TRUE once instance is preempted,
+// it's terminated in 30 seconds
+func (m *Monitor) checkGCP() (*Notice, error) {
+	req, err := http.NewRequest(http.MethodGet, m.endpoint+"/computeMetadata/v1/instance/preempted", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	body, status, err := m.do(req)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("instance/preempted responds %d", status)
+	}
+	if strings.TrimSpace(string(body)) != "TRUE" {
+		return nil, nil
+	}
+	return &Notice{Provider: GCP, Action: "terminate", Time: time.Now().Add(gcpNotice)}, nil
+}
+
+func (m *Monitor) do(req *http.Request) ([]byte, int, error) {
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+	return body, resp.StatusCode, nil
+}
@@ -0,0 +1,142 @@
+package probes
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"eviction-agent/pkg/config"
+)
+
+// probeChunk is the size of each write of disk probes
+const probeChunk = 1 << 20
+
+// Probe verifies a resource is healthy, e.g. before its taint is removed,
+// returns error if it's not
+type Probe func(ctx context.Context) error
+
+// Parse returns the probe of spec, fields separated by spaces:
+//
+//	disk <dir> <size> <min rate>   writes size bytes to a file in dir with fsync, e.g. disk /var/lib/kubelet 64Mi 100Mi/s
+//	http <url> <min rate>          downloads url, e.g. http http://mirror/100M 10Mi/s
+//	exec <command> [args...]       runs command, it's healthy if command exits 0
+func Parse(spec string) (Probe, error) {
+	fields := strings.Fields(spec)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty probe")
+	}
+	switch fields[0] {
+	case "disk":
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("invalid probe %q, should be disk <dir> <size> <min rate>", spec)
+		}
+		size, err := config.ParseValue(fields[2])
+		if err != nil || size < 1 {
+			return nil, fmt.Errorf("invalid size %q of probe %q", fields[2], spec)
+		}
+		rate, err := config.ParseValue(fields[3])
+		if err != nil {
+			return nil, fmt.Errorf("invalid min rate of probe %q: %v", spec, err)
+		}
+		return diskProbe(fields[1], int64(size), rate), nil
+	case "http":
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("invalid probe %q, should be http <url> <min rate>", spec)
+		}
+		rate, err := config.ParseValue(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("invalid min rate of probe %q: %v", spec, err)
+		}
+		return httpProbe(fields[1], rate), nil
+	case "exec":
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("invalid probe %q, should be exec <command> [args...]", spec)
+		}
+		return execProbe(fields[1:]), nil
+	}
+	return nil, fmt.Errorf("unknown probe %q, should be disk, http or exec", fields[0])
+}
+
+// diskProbe writes size bytes to a temporary file in dir with fsync, it's
+// healthy if the rate is at least minRate bytes per second
+func diskProbe(dir string, size int64, minRate float64) Probe {
+	return func(ctx context.Context) error {
+		file, err := ioutil.TempFile(dir, ".eviction-agent-probe-")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(file.Name())
+		defer file.Close()
+		chunk := bytes.Repeat([]byte{0xa5}, probeChunk)
+		start := time.Now()
+		for written := int64(0); written < size; written += probeChunk {
+			if err := ctx.Err(); err != nil {
+				return fmt.Errorf("disk probe of %s: %v after %d bytes", dir, err, written)
+			}
+			n := size - written
+			if n > probeChunk {
+				n = probeChunk
+			}
+			if _, err := file.Write(chunk[:n]); err != nil {
+				return err
+			}
+		}
+		if err := file.Sync(); err != nil {
+			return err
+		}
+		return checkRate("disk probe of "+dir, size, time.Since(start), minRate)
+	}
+}
+
+// httpProbe downloads url, it's healthy if the rate is at least minRate
+// bytes per second
+func httpProbe(url string, minRate float64) Probe {
+	return func(ctx context.Context) error {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		start := time.Now()
+		resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("http probe of %s: status %s", url, resp.Status)
+		}
+		n, err := io.Copy(ioutil.Discard, resp.Body)
+		if err != nil {
+			return fmt.Errorf("http probe of %s: %v after %d bytes", url, err, n)
+		}
+		return checkRate("http probe of "+url, n, time.Since(start), minRate)
+	}
+}
+
+// execProbe runs command, it's healthy if command exits 0
+func execProbe(command []string) Probe {
+	return func(ctx context.Context) error {
+		out, err := exec.CommandContext(ctx, command[0], command[1:]...).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("%s: %v: %s", strings.Join(command, " "), err, strings.TrimSpace(string(out)))
+		}
+		return nil
+	}
+}
+
+func checkRate(probe string, n int64, duration time.Duration, minRate float64) error {
+	if duration <= 0 {
+		return nil
+	}
+	if rate := float64(n) / duration.Seconds(); rate < minRate {
+		return fmt.Errorf("%s: %.0f bytes/s is less than %.0f bytes/s", probe, rate, minRate)
+	}
+	return nil
+}
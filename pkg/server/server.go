@@ -0,0 +1,245 @@
+package server
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/metrics"
+	"eviction-agent/pkg/tracing"
+)
+
+// Checker returns error if the check fails
+type Checker func() error
+
+// Server is the http server of eviction agent, serves health checks and metrics
+type Server struct {
+	addr          string
+	token         string // bearer token required by all handlers, if not empty
+	mux           *http.ServeMux
+	lock          sync.Mutex
+	healthzChecks map[string]Checker
+	readyzChecks  map[string]Checker
+}
+
+func newServer(addr string) *Server {
+	return &Server{
+		addr:          addr,
+		mux:           http.NewServeMux(),
+		healthzChecks: make(map[string]Checker),
+		readyzChecks:  make(map[string]Checker),
+	}
+}
+
+// NewServer creates a server listening on addr with /healthz, /readyz and /metrics
+func NewServer(addr string) *Server {
+	s := newServer(addr)
+	s.mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		s.serveChecks(w, s.healthzChecks)
+	})
+	s.mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		s.serveChecks(w, s.readyzChecks)
+	})
+	s.mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		metrics.WriteText(w)
+	})
+	return s
+}
+
+// NewDebugServer creates a server listening on addr with /debug/pprof and /debug/loglevel
+func NewDebugServer(addr string) *Server {
+	s := newServer(addr)
+	s.mux.Handle("/debug/loglevel", logLevelHandler())
+	s.mux.HandleFunc("/debug/pprof/", pprof.Index)
+	s.mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	s.mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	s.mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	s.mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return s
+}
+
+// NewAPIServer creates a server listening on addr, all handlers
+// registered require the bearer token
+func NewAPIServer(addr, token string) *Server {
+	s := newServer(addr)
+	s.token = token
+	return s
+}
+
+// UnixPrefix is the prefix of addresses of unix sockets
+const UnixPrefix = "unix://"
+
+// JSONHandler responds with the json encoded value returned by get
+func JSONHandler(get func() interface{}) http.Handler {
+	return JSONRequestHandler(func(r *http.Request) (interface{}, error) {
+		return get(), nil
+	})
+}
+
+// JSONRequestHandler serves GET requests only, responds with the json encoded
+// value returned by get, or 400 with the error
+func JSONRequestHandler(get func(r *http.Request) (interface{}, error)) http.Handler {
+	return jsonHandler(http.MethodGet, get)
+}
+
+// JSONActionHandler serves POST requests only, responds with the json encoded
+// value returned by do, or 400 with the error
+func JSONActionHandler(do func(r *http.Request) (interface{}, error)) http.Handler {
+	return jsonHandler(http.MethodPost, do)
+}
+
+func jsonHandler(method string, get func(r *http.Request) (interface{}, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		value, err := get(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		data, err := json.MarshalIndent(value, "", "  ")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	})
+}
+
+// logLevelHandler responds with the log level on GET, and changes it
+// on PUT with ?level=debug, so that it's changed without restart
+func logLevelHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut:
+			level := r.URL.Query().Get("level")
+			if err := log.SetLevel(level); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			log.DefaultLogger.Warnf("Log level is changed to %s by %s", level, r.RemoteAddr)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		fmt.Fprintln(w, log.GetLevel())
+	})
+}
+
+// AddHealthzCheck adds a liveness check
+func (s *Server) AddHealthzCheck(name string, check Checker) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.healthzChecks[name] = check
+}
+
+// AddReadyzCheck adds a readiness check
+func (s *Server) AddReadyzCheck(name string, check Checker) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.readyzChecks[name] = check
+}
+
+// Handle registers handler for the given pattern, requests are traced as
+// children of their traceparent
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	if s.token != "" {
+		handler = authenticate(s.token, handler)
+	}
+	s.mux.Handle(pattern, tracing.Handler("http "+pattern, handler))
+}
+
+// authenticate rejects requests without the bearer token
+func authenticate(token string, handler http.Handler) http.Handler {
+	expected := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := []byte(r.Header.Get("Authorization"))
+		if subtle.ConstantTimeCompare(got, expected) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// Start serves http requests in background
+func (s *Server) Start() {
+	log.Infof("Start http server on %v", s.addr)
+	listener, err := Listen(s.addr)
+	if err != nil {
+		log.Errorf("http server on %v stopped: %v", s.addr, err)
+		return
+	}
+	go func() {
+		if err := http.Serve(listener, s.mux); err != nil {
+			log.Errorf("http server on %v stopped: %v", s.addr, err)
+		}
+	}()
+}
+
+// Listen listens on tcp address addr, or unix socket if addr has UnixPrefix,
+// the socket left by the last run is removed and a new one is accessible to
+// owner only
+func Listen(addr string) (net.Listener, error) {
+	if !strings.HasPrefix(addr, UnixPrefix) {
+		return net.Listen("tcp", addr)
+	}
+	path := strings.TrimPrefix(addr, UnixPrefix)
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// serveChecks runs all checks, responds 500 with failed checks if any fails
+func (s *Server) serveChecks(w http.ResponseWriter, checks map[string]Checker) {
+	s.lock.Lock()
+	names := make([]string, 0, len(checks))
+	for name := range checks {
+		names = append(names, name)
+	}
+	s.lock.Unlock()
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	failed := false
+	for _, name := range names {
+		s.lock.Lock()
+		check := checks[name]
+		s.lock.Unlock()
+		if err := check(); err != nil {
+			failed = true
+			fmt.Fprintf(&buf, "[-]%s failed: %v\n", name, err)
+		} else {
+			fmt.Fprintf(&buf, "[+]%s ok\n", name)
+		}
+	}
+	if failed {
+		w.WriteHeader(http.StatusInternalServerError)
+		buf.WriteTo(w)
+		return
+	}
+	w.Write([]byte("ok\n"))
+}
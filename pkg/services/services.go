@@ -0,0 +1,147 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/metrics"
+)
+
+var (
+	unitDegraded = metrics.NewGaugeVec("eviction_agent_service_degraded",
+		"1 if the critical systemd unit is failed, not running, restarted or logging errors.", "unit")
+	checkErrors = metrics.NewCounterVec("eviction_agent_service_check_errors_total",
+		"Number of checks of critical systemd units failed to run systemctl or journalctl.", "unit")
+)
+
+// Monitor checks critical systemd units of host periodically, e.g. containerd
+// and kubelet helpers. A unit is degraded if it's not active, it's restarted
+// since the last check, or it logs more errors to journald than journalErrors
+// in a period.
+type Monitor struct {
+	units  []string
+	prefix []string
+	period time.Duration
+	// journalErrors is the max entries of priority err of a unit in a period, disabled if zero
+	journalErrors int
+	// restarts are NRestarts of units of the last check
+	restarts map[string]int64
+}
+
+// NewMonitor creates monitor of units, systemctl and journalctl are run after
+// prefix, e.g. "nsenter -t 1 -m --" to run them in host mount namespace
+func NewMonitor(units []string, prefix string, period time.Duration, journalErrors int) *Monitor {
+	return &Monitor{
+		units:         units,
+		prefix:        strings.Fields(prefix),
+		period:        period,
+		journalErrors: journalErrors,
+		restarts:      make(map[string]int64),
+	}
+}
+
+// Run checks units until ctx is done, report is called after each check with
+// the failures of units, empty if all of them are healthy. Units which can't
+// be checked are neither healthy nor degraded, report is skipped if all of
+// them can't be checked.
+func (m *Monitor) Run(ctx context.Context, report func(failures []string)) {
+	for {
+		if failures, ok := m.check(ctx); ok {
+			report(failures)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(m.period):
+		}
+	}
+}
+
+func (m *Monitor) check(ctx context.Context) ([]string, bool) {
+	var failures []string
+	checked := false
+	for _, unit := range m.units {
+		failure, err := m.checkUnit(ctx, unit)
+		if err != nil {
+			checkErrors.Inc(unit)
+			log.Errorf("check systemd unit %s error: %v", unit, err)
+			continue
+		}
+		checked = true
+		if failure != "" {
+			unitDegraded.Set(1, unit)
+			failures = append(failures, failure)
+			log.Warnw("critical service degraded", "unit", unit, "failure", failure)
+		} else {
+			unitDegraded.Set(0, unit)
+		}
+	}
+	return failures, checked
+}
+
+// checkUnit returns the failure of unit, empty if it's healthy
+func (m *Monitor) checkUnit(ctx context.Context, unit string) (string, error) {
+	out, err := m.run(ctx, "systemctl", "show", "--property=ActiveState,SubState,NRestarts", unit)
+	if err != nil {
+		return "", err
+	}
+	properties := make(map[string]string)
+	for _, line := range strings.Split(string(out), "\n") {
+		if parts := strings.SplitN(line, "=", 2); len(parts) == 2 {
+			properties[parts[0]] = strings.TrimSpace(parts[1])
+		}
+	}
+	state := properties["ActiveState"]
+	// NRestarts is missing before systemd 235
+	restarts, _ := strconv.ParseInt(properties["NRestarts"], 10, 64)
+	last, seen := m.restarts[unit]
+	m.restarts[unit] = restarts
+	switch {
+	case state == "":
+		return "", fmt.Errorf("no ActiveState of unit in %q", strings.TrimSpace(string(out)))
+	case state != "active" && state != "reloading":
+		return fmt.Sprintf("%s is %s (%s)", unit, state, properties["SubState"]), nil
+	case seen && restarts > last:
+		return fmt.Sprintf("%s restarted %d times", unit, restarts-last), nil
+	}
+
+	if m.journalErrors <= 0 {
+		return "", nil
+	}
+	out, err = m.run(ctx, "journalctl", "--unit="+unit, "--priority=err", "--quiet", "--no-pager",
+		"--output=cat", fmt.Sprintf("--since=-%ds", int(m.period.Seconds())))
+	if err != nil {
+		return "", err
+	}
+	errors := 0
+	for _, line := range strings.Split(string(out), "\n") {
+		if strings.TrimSpace(line) != "" {
+			errors++
+		}
+	}
+	if errors > m.journalErrors {
+		return fmt.Sprintf("%s logged %d errors in %v", unit, errors, m.period), nil
+	}
+	return "", nil
+}
+
+// run runs command after prefix, in timeout of a period
+func (m *Monitor) run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.period)
+	defer cancel()
+	command := append(append(append([]string{}, m.prefix...), name), args...)
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v: %s", strings.Join(command, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
@@ -1,12 +1,15 @@
 package summary
 
 import (
+	"bytes"
 	"fmt"
 	"net/http"
 	"net/url"
 	"net"
 	"strconv"
-	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
 	"encoding/json"
 
 	stats "k8s.io/kubernetes/pkg/kubelet/apis/stats/v1alpha1"
@@ -19,6 +22,7 @@ type NodeInfo struct {
 	Name           string
 	Port           int
 	ConnectAddress string
+	Timeout        time.Duration // timeout of each kubelet request, no timeout if 0
 }
 
 type ConditionStats struct {
@@ -36,30 +40,64 @@ type SummaryStatsApi interface {
 	GetSummaryStats() (*ConditionStats, error)
 }
 
+// maxBufferSize is the max capacity of response buffers kept for reuse
+const maxBufferSize = 16 << 20
+
+// buffers are reused across requests, summary of a dense node is megabytes
+var buffers = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+// unavailableError is summary api not served by kubelet, e.g. the read-only
+// port is disabled
+type unavailableError struct {
+	err error
+}
+
+func (e *unavailableError) Error() string {
+	return e.err.Error()
+}
+
+// IsUnavailable returns true if err is summary api not found or refused,
+// rather than failing for a while
+func IsUnavailable(err error) bool {
+	_, ok := err.(*unavailableError)
+	return ok
+}
+
 type kubeletClient struct {
-	port   int
-	host   string  // Connect address
-	client *http.Client
-	stats  ConditionStats
+	port       int
+	host       string  // Connect address
+	client     *http.Client
+	summaryURL string
 }
 
 func NewSummaryStatsApi(transport http.RoundTripper, nodeInfo NodeInfo) (SummaryStatsApi, error) {
 	c := &http.Client{
 		Transport: transport,
+		Timeout:   nodeInfo.Timeout,
+	}
+	summaryURL := url.URL{
+		Scheme: "http",
+		Host:   net.JoinHostPort(nodeInfo.ConnectAddress, strconv.Itoa(nodeInfo.Port)),
+		Path:   "/stats/summary/",
 	}
 	return &kubeletClient{
-		port:   nodeInfo.Port,
-		host:   nodeInfo.ConnectAddress,
-		client: c,
+		port:       nodeInfo.Port,
+		host:       nodeInfo.ConnectAddress,
+		client:     c,
+		summaryURL: summaryURL.String(),
 	}, nil
 }
 
+// GetSummaryStats returns new stats each time, so that stats in use are never changed
 func (kc *kubeletClient) GetSummaryStats() (*ConditionStats, error) {
-	err := kc.collect()
-	return &kc.stats, err
+	stats := &ConditionStats{}
+	err := kc.collect(stats)
+	return stats, err
 }
 
-func (kc *kubeletClient) collect() error {
+func (kc *kubeletClient) collect(stats *ConditionStats) error {
 	summary, err := kc.getSummary()
 	if err != nil {
 		log.Errorf("get summary error: %v", err)
@@ -70,28 +108,39 @@ func (kc *kubeletClient) collect() error {
 			return fmt.Errorf("Get summary nil data")
 	}
 
-	kc.stats.NodeNetStats = summary.Node.Network
-	kc.stats.NodeDiskIoStats = summary.Node.Diskio
-	kc.stats.PodStats = summary.Pods
-	kc.stats.NodeName = summary.Node.NodeName
-	kc.stats.NodeCPUStats = summary.Node.CPU
-	kc.stats.NodeMemoryStats = summary.Node.Memory
-	kc.stats.SysContainers = summary.Node.SystemContainers
+	stats.NodeNetStats = summary.Node.Network
+	stats.NodeDiskIoStats = summary.Node.Diskio
+	stats.PodStats = summary.Pods
+	stats.NodeName = summary.Node.NodeName
+	stats.NodeCPUStats = summary.Node.CPU
+	stats.NodeMemoryStats = summary.Node.Memory
+	stats.SysContainers = summary.Node.SystemContainers
 	return nil
 }
 
 func (kc *kubeletClient) makeRequestAndGetValue(client *http.Client, req *http.Request, value interface{}) error {
 	response, err := client.Do(req)
 	if err != nil {
-		return fmt.Errorf("do http request error: %v", err)
+		err = fmt.Errorf("do http request error: %v", err)
+		if strings.Contains(err.Error(), "connection refused") {
+			return &unavailableError{err}
+		}
+		return err
 	}
 	defer response.Body.Close()
-	body, err := ioutil.ReadAll(response.Body)
-	if err != nil {
+	buf := buffers.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer func() {
+		if buf.Cap() <= maxBufferSize {
+			buffers.Put(buf)
+		}
+	}()
+	if _, err := buf.ReadFrom(response.Body); err != nil {
 		return fmt.Errorf("failed to read response body - %v", err)
 	}
+	body := buf.Bytes()
 	if response.StatusCode == http.StatusNotFound {
-		return fmt.Errorf("request not found: %v", req.URL.String())
+		return &unavailableError{fmt.Errorf("request not found: %v", req.URL.String())}
 	} else if response.StatusCode != http.StatusOK {
 		return fmt.Errorf("request failed - %q, response: %q", response.Status, string(body))
 	}
@@ -100,7 +149,8 @@ func (kc *kubeletClient) makeRequestAndGetValue(client *http.Client, req *http.R
 	if req.URL != nil {
 		kubeletAddr = req.URL.Host
 	}
-	log.Debugf("Raw response from Kubelet at %s: %s", kubeletAddr, string(body))
+	// body is not converted to string unless it's logged
+	log.Debugf("Raw response from Kubelet at %s: %s", kubeletAddr, body)
 
 	err = json.Unmarshal(body, value)
 	if err != nil {
@@ -110,15 +160,7 @@ func (kc *kubeletClient) makeRequestAndGetValue(client *http.Client, req *http.R
 }
 
 func (kc *kubeletClient) getSummary() (*stats.Summary, error) {
-	scheme := "http"
-
-	url := url.URL{
-		Scheme: scheme,
-		Host:   net.JoinHostPort(kc.host, strconv.Itoa(kc.port)),
-		Path:   "/stats/summary/",
-	}
-
-	req, err := http.NewRequest("GET", url.String(), nil)
+	req, err := http.NewRequest("GET", kc.summaryURL, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,85 @@
+package summary
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	cadvisorapiv1 "github.com/google/cadvisor/info/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	statsapi "k8s.io/kubernetes/pkg/kubelet/apis/stats/v1alpha1"
+)
+
+// denseSummary returns the summary of a dense node of pods, each with
+// containers doing io on a few devices
+func denseSummary(pods, containers int) *statsapi.Summary {
+	now := metav1.NewTime(time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC))
+	value := uint64(123456789)
+	diskio := func() *statsapi.DiskioStats {
+		var serviced []cadvisorapiv1.PerDiskStats
+		for d := 0; d < 4; d++ {
+			serviced = append(serviced, cadvisorapiv1.PerDiskStats{
+				Device: fmt.Sprintf("/dev/sd%c", 'a'+d), Major: 8, Minor: uint64(16 * d),
+				Stats: map[string]uint64{"Read": value, "Write": value, "Sync": value, "Async": value, "Total": value},
+			})
+		}
+		return &statsapi.DiskioStats{Time: now, HasDiskIo: true,
+			DiskIoStats: &cadvisorapiv1.DiskIoStats{IoServiced: serviced, IoServiceBytes: serviced}}
+	}
+	network := &statsapi.NetworkStats{Time: now, InterfaceStats: statsapi.InterfaceStats{
+		Name: "eth0", RxBytes: &value, RxPackets: &value, TxBytes: &value, TxPackets: &value}}
+	cpu := &statsapi.CPUStats{Time: now, UsageNanoCores: &value, UsageCoreNanoSeconds: &value}
+	memory := &statsapi.MemoryStats{Time: now, UsageBytes: &value, WorkingSetBytes: &value, RSSBytes: &value}
+
+	summary := &statsapi.Summary{Node: statsapi.NodeStats{
+		NodeName: "node-1", StartTime: now, CPU: cpu, Memory: memory, Network: network, Diskio: diskio(),
+	}}
+	for p := 0; p < pods; p++ {
+		pod := statsapi.PodStats{
+			PodRef:    statsapi.PodReference{Name: fmt.Sprintf("pod-%d", p), Namespace: "default", UID: strconv.Itoa(p)},
+			StartTime: now, CPU: cpu, Memory: memory, Network: network, Diskio: diskio(),
+		}
+		for c := 0; c < containers; c++ {
+			pod.Containers = append(pod.Containers, statsapi.ContainerStats{
+				Name: fmt.Sprintf("container-%d", c), StartTime: now, CPU: cpu, Memory: memory, Diskio: diskio(),
+			})
+		}
+		summary.Pods = append(summary.Pods, pod)
+	}
+	return summary
+}
+
+func BenchmarkGetSummaryStats(b *testing.B) {
+	body, err := json.Marshal(denseSummary(500, 4))
+	if err != nil {
+		b.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	}))
+	defer server.Close()
+	host, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		b.Fatal(err)
+	}
+	p, _ := strconv.Atoi(port)
+	api, err := NewSummaryStatsApi(http.DefaultTransport, NodeInfo{Name: "node-1", Port: p, ConnectAddress: host})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.SetBytes(int64(len(body)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := api.GetSummaryStats(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
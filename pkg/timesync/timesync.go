@@ -0,0 +1,161 @@
+package timesync
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/metrics"
+)
+
+var (
+	clockOffset = metrics.NewGaugeVec("eviction_agent_clock_offset_seconds",
+		"Offset of the clock of node to its time source in seconds, by the daemon reporting it.", "daemon")
+	clockSynchronized = metrics.NewGaugeVec("eviction_agent_clock_synchronized",
+		"1 if the clock of node is synchronized by the daemon, 0 if it's not.", "daemon")
+	checkErrors = metrics.NewCounterVec("eviction_agent_clock_check_errors_total",
+		"Number of checks of clock failed to run both chronyc and ntpq.")
+)
+
+// status is the sync status of clock reported by a daemon
+type status struct {
+	daemon       string // chronyd or ntpd
+	synchronized bool
+	offset       time.Duration // clock minus time source
+}
+
+// Monitor checks the sync status and offset of the clock of host periodically,
+// by chronyd, or ntpd if chronyc is not available. The clock is skewed if it's
+// not synchronized or its offset is over maxOffset, which breaks certificates
+// and leader elections of pods.
+type Monitor struct {
+	prefix    []string
+	period    time.Duration
+	maxOffset time.Duration
+}
+
+// NewMonitor creates monitor of clock, chronyc and ntpq are run after prefix,
+// e.g. "nsenter -t 1 -m --" to run them in host mount namespace
+func NewMonitor(prefix string, period, maxOffset time.Duration) *Monitor {
+	return &Monitor{
+		prefix:    strings.Fields(prefix),
+		period:    period,
+		maxOffset: maxOffset,
+	}
+}
+
+// Run checks clock until ctx is done, report is called after each check with
+// the failures of clock, empty if it's synchronized in maxOffset. Report is
+// skipped if clock can't be checked.
+func (m *Monitor) Run(ctx context.Context, report func(failures []string)) {
+	for {
+		if failures, ok := m.check(ctx); ok {
+			report(failures)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(m.period):
+		}
+	}
+}
+
+func (m *Monitor) check(ctx context.Context) ([]string, bool) {
+	s, err := m.chronyStatus(ctx)
+	if err != nil {
+		var ntpErr error
+		if s, ntpErr = m.ntpStatus(ctx); ntpErr != nil {
+			checkErrors.Inc()
+			log.Errorf("check clock error, chronyc: %v, ntpq: %v", err, ntpErr)
+			return nil, false
+		}
+	}
+	clockOffset.Set(s.offset.Seconds(), s.daemon)
+	var failures []string
+	if !s.synchronized {
+		clockSynchronized.Set(0, s.daemon)
+		failures = append(failures, fmt.Sprintf("clock is not synchronized by %s", s.daemon))
+	} else {
+		clockSynchronized.Set(1, s.daemon)
+	}
+	if offset := time.Duration(math.Abs(float64(s.offset))); offset > m.maxOffset {
+		failures = append(failures, fmt.Sprintf("clock offset %v of %s is over %v", s.offset, s.daemon, m.maxOffset))
+	}
+	if len(failures) != 0 {
+		log.Warnw("clock skewed", "daemon", s.daemon, "failures", strings.Join(failures, "; "))
+	}
+	return failures, true
+}
+
+// chronyStatus returns the status of `chronyc -c tracking`, a csv line of
+// reference id, address, stratum, reference time, system time offset,
+// last offset, ..., leap status
+func (m *Monitor) chronyStatus(ctx context.Context) (status, error) {
+	out, err := m.run(ctx, "chronyc", "-c", "tracking")
+	if err != nil {
+		return status{}, err
+	}
+	fields := strings.Split(strings.TrimSpace(string(out)), ",")
+	if len(fields) < 6 {
+		return status{}, fmt.Errorf("invalid chronyc tracking %q", strings.TrimSpace(string(out)))
+	}
+	// system time offset is positive if clock is slow
+	seconds, err := strconv.ParseFloat(fields[4], 64)
+	if err != nil {
+		return status{}, fmt.Errorf("invalid system time offset %q", fields[4])
+	}
+	return status{
+		daemon:       "chronyd",
+		synchronized: fields[len(fields)-1] != "Not synchronised",
+		offset:       time.Duration(-seconds * float64(time.Second)),
+	}, nil
+}
+
+// ntpStatus returns the status of `ntpq -c "rv 0 leap,offset"`, e.g.
+// leap=00, offset=-0.123 of milliseconds, leap 11 is not synchronized
+func (m *Monitor) ntpStatus(ctx context.Context) (status, error) {
+	out, err := m.run(ctx, "ntpq", "-c", "rv 0 leap,offset")
+	if err != nil {
+		return status{}, err
+	}
+	variables := make(map[string]string)
+	for _, item := range strings.Split(strings.Replace(string(out), "\n", ",", -1), ",") {
+		if parts := strings.SplitN(strings.TrimSpace(item), "=", 2); len(parts) == 2 {
+			variables[parts[0]] = parts[1]
+		}
+	}
+	leap, ok := variables["leap"]
+	if !ok {
+		return status{}, fmt.Errorf("no leap in ntpq output %q", strings.TrimSpace(string(out)))
+	}
+	milliseconds, err := strconv.ParseFloat(variables["offset"], 64)
+	if err != nil {
+		return status{}, fmt.Errorf("invalid offset %q", variables["offset"])
+	}
+	return status{
+		daemon:       "ntpd",
+		synchronized: leap != "11",
+		offset:       time.Duration(milliseconds * float64(time.Millisecond)),
+	}, nil
+}
+
+// run runs command after prefix, in timeout of a period
+func (m *Monitor) run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.period)
+	defer cancel()
+	command := append(append(append([]string{}, m.prefix...), name), args...)
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v: %s", strings.Join(command, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
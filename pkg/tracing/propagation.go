@@ -0,0 +1,137 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// traceparentHeader is the W3C trace context header, e.g.
+// 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+const traceparentHeader = "traceparent"
+
+// Traced returns true if ctx has a span to propagate
+func Traced(ctx context.Context) bool {
+	span, ok := ctx.Value(spanKey{}).(*Span)
+	return ok && span != nil
+}
+
+// Inject sets traceparent of the span in ctx to header, header is not changed
+// if ctx has no span. Spans are always recorded, so they are sampled.
+func Inject(ctx context.Context, header http.Header) {
+	span, ok := ctx.Value(spanKey{}).(*Span)
+	if !ok || span == nil {
+		return
+	}
+	header.Set(traceparentHeader, fmt.Sprintf("00-%s-%s-01",
+		hex.EncodeToString(span.traceID[:]), hex.EncodeToString(span.spanID[:])))
+}
+
+// Extract returns ctx with the remote span of traceparent in header, so that
+// spans started from it are children of the caller. ctx is returned if header
+// has no valid traceparent.
+func Extract(ctx context.Context, header http.Header) context.Context {
+	parent, ok := parseTraceparent(header.Get(traceparentHeader))
+	if !ok {
+		return ctx
+	}
+	return context.WithValue(ctx, spanKey{}, parent)
+}
+
+// parseTraceparent returns the remote span of value, which is never ended
+func parseTraceparent(value string) (*Span, bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return nil, false
+	}
+	// future versions may append fields
+	if parts[0] == "00" && len(parts) != 4 {
+		return nil, false
+	}
+	span := &Span{}
+	if !decodeID(span.traceID[:], parts[1]) || !decodeID(span.spanID[:], parts[2]) {
+		return nil, false
+	}
+	if _, err := hex.DecodeString(parts[0] + parts[3]); err != nil || len(parts[3]) != 2 {
+		return nil, false
+	}
+	return span, true
+}
+
+// decodeID decodes lowercase hex value into id, all zero ids are invalid
+func decodeID(id []byte, value string) bool {
+	if len(value) != 2*len(id) || strings.ToLower(value) != value {
+		return false
+	}
+	if _, err := hex.Decode(id, []byte(value)); err != nil {
+		return false
+	}
+	for _, b := range id {
+		if b != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Transport injects traceparent into requests of rt, of the span in context of
+// request, or of ctx if request has none, e.g. requests of client-go which
+// has no context.
+func Transport(ctx context.Context, rt http.RoundTripper) http.RoundTripper {
+	return &transport{ctx: ctx, rt: rt}
+}
+
+type transport struct {
+	ctx context.Context
+	rt  http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if !Traced(ctx) {
+		ctx = t.ctx
+	}
+	if !Traced(ctx) {
+		return t.rt.RoundTrip(req)
+	}
+	// round trippers should not modify the request
+	req = req.Clone(req.Context())
+	Inject(ctx, req.Header)
+	return t.rt.RoundTrip(req)
+}
+
+// Handler serves requests by handler in server spans named name, which are
+// children of the traceparent of requests if any. The span is in context of
+// the request passed to handler.
+func Handler(name string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := start(Extract(r.Context(), r.Header), name, kindServer)
+		if span == nil {
+			handler.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+		defer span.End()
+		span.SetAttribute("http.method", r.Method)
+		span.SetAttribute("http.target", r.URL.Path)
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		handler.ServeHTTP(sw, r.WithContext(ctx))
+		span.SetAttribute("http.status_code", sw.status)
+		// handlers respond 400 with their errors
+		if sw.status >= http.StatusBadRequest {
+			span.SetError(fmt.Errorf("%d %s", sw.status, http.StatusText(sw.status)))
+		}
+	})
+}
+
+// statusWriter records the status responded
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,312 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/metrics"
+)
+
+const (
+	// maxQueuedSpans is the max number of ended spans waiting for export
+	maxQueuedSpans = 2048
+	// maxBatchSpans is the max number of spans in one export
+	maxBatchSpans = 256
+	// exportPeriod is the max period between two exports
+	exportPeriod = 5 * time.Second
+	// otlp status codes
+	statusOK    = 1
+	statusError = 2
+	// otlp span kinds
+	kindInternal = 1
+	kindServer   = 2
+	kindClient   = 3
+)
+
+var (
+	droppedSpans = metrics.NewCounterVec("eviction_agent_tracing_dropped_spans_total",
+		"Number of spans dropped because export queue is full or export failed.", "reason")
+
+	exporterLock sync.RWMutex
+	exporter     *otlpExporter
+)
+
+type spanKey struct{}
+
+// Span is an operation of the eviction flow, all methods are no-op on nil span
+type Span struct {
+	traceID    [16]byte
+	spanID     [8]byte
+	parentID   [8]byte
+	name       string
+	kind       int
+	start      time.Time
+	lock       sync.Mutex // protects fields below
+	end        time.Time
+	attributes map[string]interface{}
+	err        error
+	ended      bool
+}
+
+// Init exports spans to the OTLP/HTTP endpoint, e.g. http://otel-collector:4318.
+// Spans are not recorded if Init is not called.
+func Init(endpoint, serviceName, nodeName string) {
+	e := &otlpExporter{
+		url:    endpoint + "/v1/traces",
+		client: &http.Client{Timeout: 10 * time.Second},
+		spans:  make(chan *Span, maxQueuedSpans),
+		done:   make(chan struct{}),
+		resource: attributes(map[string]interface{}{
+			"service.name": serviceName,
+			"host.name":    nodeName,
+		}),
+	}
+	go e.run()
+	exporterLock.Lock()
+	exporter = e
+	exporterLock.Unlock()
+	log.Infof("Export traces to %s", e.url)
+}
+
+// Shutdown exports spans left, waits at most timeout
+func Shutdown(timeout time.Duration) {
+	exporterLock.Lock()
+	e := exporter
+	exporter = nil
+	exporterLock.Unlock()
+	if e == nil {
+		return
+	}
+	close(e.spans)
+	select {
+	case <-e.done:
+	case <-time.After(timeout):
+		log.Warnf("Export traces is not finished in %v", timeout)
+	}
+}
+
+// Start starts a span named name, it's a child of the span in ctx if any
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	return start(ctx, name, kindInternal)
+}
+
+// StartClient starts a span of a call to a remote service, e.g. api server
+func StartClient(ctx context.Context, name string) (context.Context, *Span) {
+	return start(ctx, name, kindClient)
+}
+
+// StartServer starts a span of a call served, e.g. of control api
+func StartServer(ctx context.Context, name string) (context.Context, *Span) {
+	return start(ctx, name, kindServer)
+}
+
+func start(ctx context.Context, name string, kind int) (context.Context, *Span) {
+	exporterLock.RLock()
+	enabled := exporter != nil
+	exporterLock.RUnlock()
+	if !enabled {
+		return ctx, nil
+	}
+	span := &Span{
+		name:       name,
+		kind:       kind,
+		start:      time.Now(),
+		attributes: make(map[string]interface{}),
+	}
+	if parent, ok := ctx.Value(spanKey{}).(*Span); ok && parent != nil {
+		span.traceID = parent.traceID
+		span.parentID = parent.spanID
+	} else {
+		rand.Read(span.traceID[:])
+	}
+	rand.Read(span.spanID[:])
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// SetAttribute sets an attribute, value is a string, bool, int, int64 or float64
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.attributes[key] = value
+}
+
+// SetError marks the span failed if err is not nil
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.err = err
+}
+
+// End ends the span and queues it for export, spans are dropped if queue is full
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	if s.ended {
+		s.lock.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.lock.Unlock()
+
+	exporterLock.RLock()
+	defer exporterLock.RUnlock()
+	if exporter == nil {
+		return
+	}
+	select {
+	case exporter.spans <- s:
+	default:
+		droppedSpans.Inc("QueueFull")
+	}
+}
+
+// otlpExporter posts batches of spans in OTLP/HTTP json encoding
+type otlpExporter struct {
+	url      string
+	client   *http.Client
+	spans    chan *Span
+	done     chan struct{}
+	resource []otlpAttribute
+}
+
+func (e *otlpExporter) run() {
+	defer close(e.done)
+	ticker := time.NewTicker(exportPeriod)
+	defer ticker.Stop()
+	var batch []*Span
+	for {
+		select {
+		case span, ok := <-e.spans:
+			if !ok {
+				e.export(batch)
+				return
+			}
+			batch = append(batch, span)
+			if len(batch) < maxBatchSpans {
+				continue
+			}
+		case <-ticker.C:
+		}
+		e.export(batch)
+		batch = nil
+	}
+}
+
+func (e *otlpExporter) export(batch []*Span) {
+	if len(batch) == 0 {
+		return
+	}
+	spans := make([]otlpSpan, 0, len(batch))
+	for _, s := range batch {
+		spans = append(spans, s.otlp())
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{"attributes": e.resource},
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]string{"name": "eviction-agent"},
+				"spans": spans,
+			}},
+		}},
+	})
+	if err == nil {
+		err = e.post(body)
+	}
+	if err != nil {
+		for range batch {
+			droppedSpans.Inc("ExportFailed")
+		}
+		log.Warnf("Export %d spans to %s error: %v", len(batch), e.url, err)
+	}
+}
+
+func (e *otlpExporter) post(body []byte) error {
+	resp, err := e.client.Post(e.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+type otlpAttribute struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+type otlpSpan struct {
+	TraceID      string                 `json:"traceId"`
+	SpanID       string                 `json:"spanId"`
+	ParentSpanID string                 `json:"parentSpanId,omitempty"`
+	Name         string                 `json:"name"`
+	Kind         int                    `json:"kind"`
+	Start        string                 `json:"startTimeUnixNano"`
+	End          string                 `json:"endTimeUnixNano"`
+	Attributes   []otlpAttribute        `json:"attributes,omitempty"`
+	Status       map[string]interface{} `json:"status"`
+}
+
+func (s *Span) otlp() otlpSpan {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	span := otlpSpan{
+		TraceID:    hex.EncodeToString(s.traceID[:]),
+		SpanID:     hex.EncodeToString(s.spanID[:]),
+		Name:       s.name,
+		Kind:       s.kind,
+		Start:      strconv.FormatInt(s.start.UnixNano(), 10),
+		End:        strconv.FormatInt(s.end.UnixNano(), 10),
+		Attributes: attributes(s.attributes),
+		Status:     map[string]interface{}{"code": statusOK},
+	}
+	if s.parentID != ([8]byte{}) {
+		span.ParentSpanID = hex.EncodeToString(s.parentID[:])
+	}
+	if s.err != nil {
+		span.Status = map[string]interface{}{"code": statusError, "message": s.err.Error()}
+	}
+	return span
+}
+
+// attributes converts values into otlp any values
+func attributes(values map[string]interface{}) []otlpAttribute {
+	var attrs []otlpAttribute
+	for k, v := range values {
+		var value map[string]interface{}
+		switch v := v.(type) {
+		case bool:
+			value = map[string]interface{}{"boolValue": v}
+		case int:
+			value = map[string]interface{}{"intValue": strconv.Itoa(v)}
+		case int64:
+			value = map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}
+		case float64:
+			value = map[string]interface{}{"doubleValue": v}
+		default:
+			value = map[string]interface{}{"stringValue": fmt.Sprint(v)}
+		}
+		attrs = append(attrs, otlpAttribute{Key: k, Value: value})
+	}
+	return attrs
+}
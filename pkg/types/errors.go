@@ -0,0 +1,41 @@
+package types
+
+import "fmt"
+
+// Kinds of fatal errors
+const (
+	// FatalConfigInvalid is a policy configuration or flag which can't be used
+	FatalConfigInvalid = "ConfigInvalid"
+	// FatalUnauthorized is an api call denied by authentication or RBAC
+	FatalUnauthorized = "Unauthorized"
+	// FatalStatsSourceGone is kubelet summary api unavailable for long
+	FatalStatsSourceGone = "StatsSourceGone"
+)
+
+// FatalError is an error eviction agent can't recover from, Run of eviction
+// manager returns it instead of retrying forever
+type FatalError struct {
+	Kind string
+	Err  error
+}
+
+func (e *FatalError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Kind, e.Err)
+}
+
+// NewFatalError returns err as a fatal error of kind, nil if err is nil
+func NewFatalError(kind string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if fatal := AsFatalError(err); fatal != nil {
+		return fatal
+	}
+	return &FatalError{Kind: kind, Err: err}
+}
+
+// AsFatalError returns err if it's a fatal error, otherwise nil
+func AsFatalError(err error) *FatalError {
+	fatal, _ := err.(*FatalError)
+	return fatal
+}
@@ -1,9 +1,15 @@
 package types
 
+import "time"
+
 type PodInfo struct {
 	Name      string
 	Namespace string
+	UID       string
 	Priority  int
+	// Container is the container using the most of the resource pod is
+	// chosen for, empty if it's not known or pod has a single container
+	Container string
 }
 
 type NodeTaintInfo struct {
@@ -11,6 +17,119 @@ type NodeTaintInfo struct {
 	NetworkIO bool
 	CPU       bool
 	Memory    bool
+	// Taints are keys of all taints on node
+	Taints map[string]bool
+	// Problems are node conditions of status True, got with taints from the same
+	// node, e.g. KernelDeadlock of node problem detector
+	Problems map[string]bool
+}
+
+// PodProfile is the QoS class and controller kind of a pod, controller kind
+// is empty if pod has no controller
+type PodProfile struct {
+	QOSClass  string
+	OwnerKind string
+	// Images are images of containers keyed by container name
+	Images map[string]string
+}
+
+// ContainerResources are requests and limits of a container, zero if not set
+type ContainerResources struct {
+	Name          string
+	CPURequest    float64 // cores
+	CPULimit      float64 // cores
+	MemoryRequest float64 // bytes
+	MemoryLimit   float64 // bytes
+}
+
+// Workload is the controller object of pods, e.g. a Deployment
+type Workload struct {
+	APIVersion string
+	Kind       string
+	Namespace  string
+	Name       string
+	UID        string
+}
+
+// StatefulSetPod is a pod of a StatefulSet with the ordering rules of its
+// StatefulSet annotations
+type StatefulSetPod struct {
+	StatefulSet string // namespace/name
+	Ordinal     int
+	// Quorum is true if StatefulSetQuorumAnnotation of StatefulSet is "true"
+	Quorum bool
+	// MaxUnavailable is of StatefulSetMaxUnavailableAnnotation, -1 if not set
+	MaxUnavailable int
+	// Unavailable are replicas of StatefulSet not ready
+	Unavailable int
+	// Ready is true if the pod is ready, evicting a pod not ready doesn't
+	// make more replicas unavailable
+	Ready bool
+}
+
+// Severity of an eviction request
+type Severity string
+
+const (
+	// SeverityWarning is a condition over its threshold by less than a tenth of it
+	SeverityWarning Severity = "Warning"
+	// SeverityCritical is a condition over its threshold by a tenth of it or
+	// more, a node problem, or a drain of node
+	SeverityCritical Severity = "Critical"
+)
+
+// Condition is an eviction request of a condition, it flows through the
+// eviction queue to choosing a pod instead of the bare evict type
+type Condition struct {
+	// Type is the evict type, e.g. CPUBusy, requests of the same type are deduplicated
+	Type string `json:"type"`
+	// Resource is the resource under pressure, e.g. cpu, disk or intel.com/fpga,
+	// empty for node problems and drains
+	Resource string   `json:"resource,omitempty"`
+	Severity Severity `json:"severity"`
+	// Value is the measured value compared with Threshold, both are zero if
+	// the condition is not measured against a threshold
+	Value     float64 `json:"value,omitempty"`
+	Threshold float64 `json:"threshold,omitempty"`
+	// Devices are the disk device or network interfaces measured
+	Devices []string `json:"devices,omitempty"`
+}
+
+// ClusterHeadroom is the spare capacity of other nodes of cluster, which
+// evicted pods are scheduled on
+type ClusterHeadroom struct {
+	CPU    float64 `json:"cpu"`    // cores
+	Memory float64 `json:"memory"` // bytes
+	Pods   int     `json:"pods"`
+}
+
+// DisruptionBudget is the PodDisruptionBudget of a pod
+type DisruptionBudget struct {
+	Name    string // namespace/name
+	Allowed int    // disruptions allowed by status of PDB
+}
+
+// PodLifecycle is the start time of a pod and restarts of its containers
+type PodLifecycle struct {
+	StartTime time.Time
+	Restarts  int
+}
+
+// IOBurst is a temporary high io phase declared by a pod, e.g. a checkpoint
+// or compaction, by IOBurstAnnotation
+type IOBurst struct {
+	// Start is when the phase starts, zero if not declared, the phase starts
+	// when the declaration is seen
+	Start    time.Time
+	Duration time.Duration
+}
+
+// Replacement is a pod created by the controller of an evicted pod after its
+// eviction, NodeName is empty until it's scheduled
+type Replacement struct {
+	Namespace string
+	Name      string
+	NodeName  string
 }
 
 type NodeIOPSTotal struct {
@@ -29,7 +148,158 @@ const (
 	NodeNetworkBPSTotal = "sncloud.com/networkBandwidthCapacity"
 	NetworkTxBusy = "NetworkTxBusy"
 	NetworkRxBusy = "NetworkRxBusy"
+	// WritebackBusy is the taint of a sustained backlog of dirty and writeback pages
+	WritebackBusy = "WritebackBusy"
+	// EphemeralPortsBusy is the taint of ephemeral ports near exhaustion
+	EphemeralPortsBusy = "EphemeralPortsBusy"
 	NeedEvict = "NeedsEviction"
 	EvictCandidate = "EvictionCandidate"
+	// EvictTypesAnnotation is the comma separated evict types labeling pod
+	EvictTypesAnnotation = "sncloud.com/evictTypes"
+	// OffenderContainerAnnotation is the container using the most of the
+	// resource of a labeled pod, so that owners know which one to fix
+	OffenderContainerAnnotation = "sncloud.com/offenderContainer"
+	// LabeledPodsAnnotation is the comma separated namespace/name of pods on node labeled by agent
+	LabeledPodsAnnotation = "sncloud.com/labeledPods"
+	// TopPodsAnnotation is the json of top pods by usage of each resource on node
+	TopPodsAnnotation = "sncloud.com/topPods"
+	// NeedsRebalanceAnnotation is "true" if node is under sustained pressure,
+	// a hint for descheduler and cluster autoscaler
+	NeedsRebalanceAnnotation = "sncloud.com/needsRebalance"
+	// PressuredResourcesAnnotation is the comma separated taints of resources under sustained pressure
+	PressuredResourcesAnnotation = "sncloud.com/pressuredResources"
 	LowestPriority = 0
+	// ObservingSinceAnnotation is the start of observation of --observe-for in RFC3339
+	ObservingSinceAnnotation = "sncloud.com/observingSince"
+	// PausedAnnotation pauses actions of agent on node, true or the expiry in RFC3339
+	PausedAnnotation = "sncloud.com/paused"
+	// QuarantineLabel is "true" on workloads whose pods are evicted repeatedly
+	QuarantineLabel = "sncloud.com/quarantined"
+	// QuarantineReasonAnnotation is why workload is quarantined
+	QuarantineReasonAnnotation = "sncloud.com/quarantineReason"
+	// EvictionOffensesAnnotation is the json of eviction times of pods of workload by evict type
+	EvictionOffensesAnnotation = "sncloud.com/evictionOffenses"
+	// UsageBaselineAnnotation is the json of daily p95 usage of conditions of --calibrate
+	UsageBaselineAnnotation = "sncloud.com/usageBaseline"
+	// ActivitySummaryAnnotation is the json of the last summary of activity of agent
+	ActivitySummaryAnnotation = "sncloud.com/activitySummary"
+	// DrainingAnnotation is the condition which is draining node, e.g. a dying disk
+	DrainingAnnotation = "sncloud.com/draining"
+	// EvictionWeightAnnotation of namespace weights scores of its pods, 1 by default
+	EvictionWeightAnnotation = "sncloud.com/evictionWeight"
+	// MaxEvictionsPerHourAnnotation of namespace limits evictions of its pods in an hour
+	MaxEvictionsPerHourAnnotation = "sncloud.com/maxEvictionsPerHour"
+	// EvictionProtectedAnnotation of namespace is "true" if its pods are never chosen
+	EvictionProtectedAnnotation = "sncloud.com/evictionProtected"
+	// NodeServiceDegraded is the node condition set while critical systemd units are degraded
+	NodeServiceDegraded = "NodeServiceDegraded"
+	// NetworkLinkDegraded is the node condition set while network interfaces are down or flapping
+	NetworkLinkDegraded = "NetworkLinkDegraded"
+	// JobProgressAnnotation of pods or Jobs is the progress of Job to completion, e.g. "95%" or "0.95"
+	JobProgressAnnotation = "sncloud.com/progress"
+	// StatefulSetQuorumAnnotation of StatefulSet is "true" if ordinal 0 of it is never evicted, e.g. of quorum members
+	StatefulSetQuorumAnnotation = "sncloud.com/quorum"
+	// StatefulSetMaxUnavailableAnnotation of StatefulSet is the max replicas not ready before its pods are evicted
+	StatefulSetMaxUnavailableAnnotation = "sncloud.com/maxUnavailable"
+	// LatencySensitiveAnnotation of pod is "true" if it's evicted from nodes of degraded links
+	LatencySensitiveAnnotation = "sncloud.com/latencySensitive"
+	// IOBurstAnnotation of pod declares a temporary high io phase, e.g. a checkpoint, which is
+	// tolerated up to its duration, e.g. "10m" from now or "2026-10-14T06:00:00Z/10m"
+	IOBurstAnnotation = "sncloud.com/ioBurst"
+	// DNSUnhealthy is the node condition set while names fail to resolve through node's resolver
+	DNSUnhealthy = "DNSUnhealthy"
+	// ClockSkewed is the node condition set while clock is not synchronized or drifts over max offset
+	ClockSkewed = "ClockSkewed"
+	// KubeletCertificateExpiring is the node condition set while certificates of kubelet expire soon without rotation
+	KubeletCertificateExpiring = "KubeletCertificateExpiring"
+)
+
+// TaintEffect returns the effect of taint key, PreferNoSchedule for node
+// conditions which only degrade pods, NoSchedule for others
+func TaintEffect(taintKey string) string {
+	if taintKey == NetworkLinkDegraded || taintKey == KubeletCertificateExpiring {
+		return "PreferNoSchedule"
+	}
+	return "NoSchedule"
+}
+
+// ExtendedResourceTaintKey is the taint of an extended resource of device
+// plugins under pressure, e.g. intel.com/fpgaBusy
+func ExtendedResourceTaintKey(resource string) string {
+	return resource + "Busy"
+}
+
+// Event types and reasons of events emitted by eviction agent
+const (
+	NormalEvent = "Normal"
+	WarningEvent = "Warning"
+	NodeTaintedReason = "TaintedByEvictionAgent"
+	NodeUntaintedReason = "UntaintedByEvictionAgent"
+	PodLabeledReason = "LabeledByEvictionAgent"
+	PodEvictedReason = "EvictedByEvictionAgent"
+	ActionFailedReason = "EvictionAgentActionFailed"
+	PolicyRejectedReason = "EvictionPolicyRejected"
+	PodResizedReason = "ResizedByEvictionAgent"
+	ResizeRecommendedReason = "ResizeRecommendedByEvictionAgent"
+	TenantBudgetExhaustedReason = "TenantEvictionBudgetExhausted"
+	WorkloadQuarantinedReason = "QuarantinedByEvictionAgent"
+	// ObservationEndedReason is the node event when agent starts to enforce decisions after observation
+	ObservationEndedReason = "ObservationEnded"
+	// PausedReason is the node event when actions are paused by node annotation
+	PausedReason = "EvictionAgentPaused"
+	// ResumedReason is the node event when actions are taken again after pause
+	ResumedReason = "EvictionAgentResumed"
+	// NodeDrainingReason is the node event when a condition is unrecoverable and node is drained
+	NodeDrainingReason = "DrainingByEvictionAgent"
+	// NodeDrainedReason is the node event when all pods are evicted by drain
+	NodeDrainedReason = "DrainedByEvictionAgent"
+	// NodePreemptedReason is the node event when cloud provider preempts instance of node
+	NodePreemptedReason = "PreemptedByCloud"
+	// EvictionChurnReason is the node event when evictions are stopped because
+	// replacements of evicted pods are scheduled back on node
+	EvictionChurnReason = "EvictionChurn"
+	// ActivitySummaryReason is the periodic node event of taints, actions and pressure sources
+	ActivitySummaryReason = "EvictionAgentSummary"
+	// HeadroomExhaustedReason is the node event when cluster has no spare capacity for evicted pods
+	HeadroomExhaustedReason = "ClusterHeadroomExhausted"
+	// HeadroomAvailableReason is the node event when cluster has spare capacity again
+	HeadroomAvailableReason = "ClusterHeadroomAvailable"
+	// LoopStalledReason is the node event when stats sync or taint loop completes no cycle in watchdog periods
+	LoopStalledReason = "EvictionAgentLoopStalled"
+	// LoopRecoveredReason is the node event when a stalled loop completes its cycles again
+	LoopRecoveredReason = "EvictionAgentLoopRecovered"
+	// SafeModeReason is the node event when actions are stopped after repeated failures
+	SafeModeReason = "EvictionAgentSafeMode"
+	// SafeModeLeftReason is the node event when an action succeeds again in safe mode
+	SafeModeLeftReason = "EvictionAgentSafeModeLeft"
+)
+
+// Reasons of NodeServiceDegraded condition
+const (
+	ServiceFailedReason = "CriticalServiceFailed"
+	ServicesRunningReason = "CriticalServicesRunning"
+)
+
+// Reasons of NetworkLinkDegraded condition
+const (
+	LinkDegradedReason = "NetworkLinkDegraded"
+	LinksUpReason = "NetworkLinksUp"
+)
+
+// Reasons of DNSUnhealthy condition
+const (
+	DNSProbeFailedReason = "DNSProbeFailed"
+	DNSResolvingReason = "DNSResolving"
+)
+
+// Reasons of ClockSkewed condition
+const (
+	ClockSkewedReason = "ClockSkewed"
+	ClockSynchronizedReason = "ClockSynchronized"
+)
+
+// Reasons of KubeletCertificateExpiring condition
+const (
+	CertificateExpiringReason = "CertificateExpiring"
+	CertificatesValidReason = "CertificatesValid"
 )
@@ -0,0 +1,106 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"eviction-agent/pkg/log"
+	"eviction-agent/pkg/metrics"
+)
+
+const (
+	// queueLen is the max number of notifications waiting to be sent
+	queueLen = 100
+	// retryInterval is the interval between two tries
+	retryInterval = 2 * time.Second
+)
+
+var (
+	notificationFailures = metrics.NewCounterVec("eviction_agent_webhook_failures_total",
+		"Number of webhook notifications failed to send or dropped.", "action")
+)
+
+// Notification is the json body posted to webhook
+type Notification struct {
+	Time         time.Time          `json:"time"`
+	Node         string             `json:"node"`
+	Action       string             `json:"action"`
+	Condition    string             `json:"condition"`
+	Pod          string             `json:"pod,omitempty"`
+	Message      string             `json:"message"`
+	Measurements map[string]float64 `json:"measurements"`
+	Error        string             `json:"error,omitempty"`
+}
+
+// Notifier posts notifications to webhook in background
+type Notifier struct {
+	url     string
+	retries int
+	client  *http.Client
+	queue   chan *Notification
+}
+
+// NewNotifier creates a notifier and starts sending notifications
+func NewNotifier(url string, timeout time.Duration, retries int) *Notifier {
+	n := &Notifier{
+		url:     url,
+		retries: retries,
+		client:  &http.Client{Timeout: timeout},
+		queue:   make(chan *Notification, queueLen),
+	}
+	go n.run()
+	return n
+}
+
+// Notify queues a notification, it never blocks and drops
+// the notification if the queue is full. It's a no-op on nil notifier.
+func (n *Notifier) Notify(notification *Notification) {
+	if n == nil {
+		return
+	}
+	select {
+	case n.queue <- notification:
+	default:
+		notificationFailures.Inc(notification.Action)
+		log.Errorf("webhook queue is full, drop notification %s %s", notification.Action, notification.Condition)
+	}
+}
+
+func (n *Notifier) run() {
+	for notification := range n.queue {
+		var err error
+		for i := 0; i <= n.retries; i++ {
+			if i > 0 {
+				time.Sleep(retryInterval)
+			}
+			if err = n.send(notification); err == nil {
+				break
+			}
+			log.Warnf("send webhook notification %s %s error: %v", notification.Action, notification.Condition, err)
+		}
+		if err != nil {
+			notificationFailures.Inc(notification.Action)
+			log.Errorf("send webhook notification %s %s failed after %d retries: %v",
+				notification.Action, notification.Condition, n.retries, err)
+		}
+	}
+}
+
+func (n *Notifier) send(notification *Notification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+	resp, err := n.client.Post(n.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responds %v", resp.Status)
+	}
+	return nil
+}